@@ -0,0 +1,366 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yanhuangpai/voyager/pkg/api"
+)
+
+const (
+	optionNameAPIAddr       = "api-addr"
+	optionNamePin           = "pin"
+	optionNameEncrypt       = "encrypt"
+	optionNameTag           = "tag"
+	optionNameIndexDocument = "index-document"
+	optionNameErrorDocument = "error-document"
+	optionNamePinType       = "type"
+
+	defaultAPIAddr = "http://127.0.0.1:11633"
+	clientTimeout  = 10 * time.Minute
+)
+
+// pinTypes are the kinds of references that can be pinned mapped to their
+// API path segments.
+var pinTypes = map[string]string{
+	"file":  "files",
+	"bytes": "bytes",
+	"chunk": "chunks",
+}
+
+// apiClient calls the HTTP API of a running node.
+type apiClient struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+}
+
+func newAPIClient(addr string) (*apiClient, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("api address: %w", err)
+	}
+	return &apiClient{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: clientTimeout},
+	}, nil
+}
+
+// request sends the request to the API endpoint and decodes the JSON response
+// into v if it is not nil. The response headers are returned.
+func (c *apiClient) request(method, endpoint string, query url.Values, header http.Header, body io.Reader, v interface{}) (http.Header, error) {
+	u := *c.baseURL
+	u.Path = path.Join(u.Path, endpoint)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	if f, ok := body.(*os.File); ok {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = fi.Size()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Message == "" {
+			return nil, fmt.Errorf("%s %s: %s", method, endpoint, resp.Status)
+		}
+		return nil, fmt.Errorf("%s %s: %s: %s", method, endpoint, resp.Status, e.Message)
+	}
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return resp.Header, nil
+}
+
+func (c *command) initUploadCmd() {
+	cmd := &cobra.Command{
+		Use:   "upload <path>",
+		Short: "Upload a file or a directory to a running node",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			client, err := newAPIClient(c.config.GetString(optionNameAPIAddr))
+			if err != nil {
+				return err
+			}
+
+			fi, err := os.Stat(args[0])
+			if err != nil {
+				return err
+			}
+
+			header := make(http.Header)
+			if c.config.GetBool(optionNamePin) {
+				header.Set(api.InfinityPinHeader, "true")
+			}
+			if c.config.GetBool(optionNameEncrypt) {
+				header.Set(api.InfinityEncryptHeader, "true")
+			}
+			if tag := c.config.GetUint32(optionNameTag); tag != 0 {
+				header.Set(api.InfinityTagHeader, strconv.FormatUint(uint64(tag), 10))
+			}
+
+			var (
+				endpoint string
+				query    url.Values
+				body     io.Reader
+			)
+			if fi.IsDir() {
+				buf := new(bytes.Buffer)
+				if err := tarDir(buf, args[0]); err != nil {
+					return fmt.Errorf("archive directory: %w", err)
+				}
+				if v := c.config.GetString(optionNameIndexDocument); v != "" {
+					header.Set(api.InfinityIndexDocumentHeader, v)
+				}
+				if v := c.config.GetString(optionNameErrorDocument); v != "" {
+					header.Set(api.InfinityErrorDocumentHeader, v)
+				}
+				header.Set("Content-Type", "application/x-tar")
+				endpoint, body = "/dirs", buf
+			} else {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				contentType := mime.TypeByExtension(filepath.Ext(fi.Name()))
+				if contentType == "" {
+					contentType = "application/octet-stream"
+				}
+				header.Set("Content-Type", contentType)
+				endpoint, query, body = "/files", url.Values{"name": {fi.Name()}}, f
+			}
+
+			var r struct {
+				Reference string `json:"reference"`
+			}
+			respHeader, err := client.request(http.MethodPost, endpoint, query, header, body, &r)
+			if err != nil {
+				return err
+			}
+
+			cmd.Printf("reference: %s\n", r.Reference)
+			if tag := respHeader.Get(api.InfinityTagHeader); tag != "" {
+				cmd.Printf("tag: %s\n", tag)
+			}
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.config.BindPFlags(cmd.Flags())
+		},
+	}
+
+	cmd.Flags().String(optionNameAPIAddr, defaultAPIAddr, "HTTP API address of the node")
+	cmd.Flags().Bool(optionNamePin, false, "pin the uploaded content")
+	cmd.Flags().Bool(optionNameEncrypt, false, "encrypt the uploaded content")
+	cmd.Flags().Uint32(optionNameTag, 0, "associate the upload with an existing tag")
+	cmd.Flags().String(optionNameIndexDocument, "", "default file of the uploaded directory")
+	cmd.Flags().String(optionNameErrorDocument, "", "error file of the uploaded directory")
+
+	c.root.AddCommand(cmd)
+}
+
+func (c *command) initPinCmd() {
+	pinCmd := func(use, short, method string) *cobra.Command {
+		cmd := &cobra.Command{
+			Use:   use + " <reference>",
+			Short: short,
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) (err error) {
+				client, err := newAPIClient(c.config.GetString(optionNameAPIAddr))
+				if err != nil {
+					return err
+				}
+
+				pinType := c.config.GetString(optionNamePinType)
+				p, ok := pinTypes[pinType]
+				if !ok {
+					return fmt.Errorf("unknown pin type %q", pinType)
+				}
+
+				if _, err := client.request(method, "/pin/"+p+"/"+args[0], nil, nil, nil, nil); err != nil {
+					return err
+				}
+				cmd.Println("OK")
+				return nil
+			},
+			PreRunE: func(cmd *cobra.Command, args []string) error {
+				return c.config.BindPFlags(cmd.Flags())
+			},
+		}
+		cmd.Flags().String(optionNameAPIAddr, defaultAPIAddr, "HTTP API address of the node")
+		cmd.Flags().String(optionNamePinType, "file", "type of the referenced content: file, bytes or chunk")
+		return cmd
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List pinned chunks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			client, err := newAPIClient(c.config.GetString(optionNameAPIAddr))
+			if err != nil {
+				return err
+			}
+
+			var r struct {
+				Chunks []struct {
+					Address    string `json:"address"`
+					PinCounter uint64 `json:"pinCounter"`
+				} `json:"chunks"`
+			}
+			if _, err := client.request(http.MethodGet, "/pin/chunks", nil, nil, nil, &r); err != nil {
+				return err
+			}
+			for _, ch := range r.Chunks {
+				cmd.Printf("%s %d\n", ch.Address, ch.PinCounter)
+			}
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.config.BindPFlags(cmd.Flags())
+		},
+	}
+	listCmd.Flags().String(optionNameAPIAddr, defaultAPIAddr, "HTTP API address of the node")
+
+	cmd := &cobra.Command{
+		Use:   "pin",
+		Short: "Manage pinned content of a running node",
+	}
+	cmd.AddCommand(
+		pinCmd("add", "Pin the referenced content", http.MethodPost),
+		pinCmd("remove", "Unpin the referenced content", http.MethodDelete),
+		listCmd,
+	)
+
+	c.root.AddCommand(cmd)
+}
+
+func (c *command) initTagCmd() {
+	cmd := &cobra.Command{
+		Use:   "tag <uid>",
+		Short: "Show the upload progress of a tag",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			client, err := newAPIClient(c.config.GetString(optionNameAPIAddr))
+			if err != nil {
+				return err
+			}
+
+			uid, err := strconv.ParseUint(args[0], 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid tag uid %q", args[0])
+			}
+
+			var r struct {
+				Uid       uint32    `json:"uid"`
+				StartedAt time.Time `json:"startedAt"`
+				Total     int64     `json:"total"`
+				Processed int64     `json:"processed"`
+				Synced    int64     `json:"synced"`
+			}
+			if _, err := client.request(http.MethodGet, "/tags/"+strconv.FormatUint(uid, 10), nil, nil, nil, &r); err != nil {
+				return err
+			}
+
+			cmd.Printf("uid: %d\n", r.Uid)
+			cmd.Printf("started: %s\n", r.StartedAt.Format(time.RFC3339))
+			cmd.Printf("total: %d\n", r.Total)
+			cmd.Printf("processed: %d\n", r.Processed)
+			cmd.Printf("synced: %d\n", r.Synced)
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.config.BindPFlags(cmd.Flags())
+		},
+	}
+
+	cmd.Flags().String(optionNameAPIAddr, defaultAPIAddr, "HTTP API address of the node")
+
+	c.root.AddCommand(cmd)
+}
+
+// tarDir writes the regular files under the directory to w as a tar archive
+// with paths relative to the directory.
+func tarDir(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/cmd/voyager/cmd"
+	"github.com/yanhuangpai/voyager/pkg/api"
+)
+
+const testReference = "2387e8e7d8a48c2a9339c97c1dc3461a9a7aa07e994c5cb8b38fd7c1b3e6ea48"
+
+func TestUploadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "voyager-upload-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := []byte("<h1>Smart Chain</h1>")
+	p := filepath.Join(dir, "index.html")
+	if err := ioutil.WriteFile(p, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/files" {
+			t.Errorf("got request %s %s, want POST /files", r.Method, r.URL.Path)
+		}
+		if got := r.URL.Query().Get("name"); got != "index.html" {
+			t.Errorf("got name %q, want %q", got, "index.html")
+		}
+		if got := r.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+			t.Errorf("got content type %q, want text/html", got)
+		}
+		if got := r.Header.Get(api.InfinityPinHeader); got != "true" {
+			t.Errorf("got pin header %q, want %q", got, "true")
+		}
+		if r.ContentLength != int64(len(content)) {
+			t.Errorf("got content length %v, want %v", r.ContentLength, len(content))
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if !bytes.Equal(b, content) {
+			t.Errorf("got content %q, want %q", b, content)
+		}
+		w.Header().Set(api.InfinityTagHeader, "42")
+		_, _ = io.WriteString(w, `{"reference":"`+testReference+`"}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	c := newCommand(t,
+		cmd.WithArgs("upload", p, "--pin", "--api-addr", server.URL),
+		cmd.WithOutput(&out),
+	)
+	if err := c.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "reference: " + testReference + "\ntag: 42\n"
+	if got := out.String(); got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
+
+func TestUploadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "voyager-upload-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"index.html":     "index",
+		"img/logo.png":   "logo",
+		"img/icon/a.png": "icon",
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/dirs" {
+			t.Errorf("got request %s %s, want POST /dirs", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/x-tar" {
+			t.Errorf("got content type %q, want %q", got, "application/x-tar")
+		}
+		if got := r.Header.Get(api.InfinityIndexDocumentHeader); got != "index.html" {
+			t.Errorf("got index document %q, want %q", got, "index.html")
+		}
+
+		var names []string
+		tr := tar.NewReader(r.Body)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := files[hdr.Name]; string(b) != want {
+				t.Errorf("got file %s content %q, want %q", hdr.Name, b, want)
+			}
+			names = append(names, hdr.Name)
+		}
+		sort.Strings(names)
+		if got, want := strings.Join(names, ","), "img/icon/a.png,img/logo.png,index.html"; got != want {
+			t.Errorf("got files %s, want %s", got, want)
+		}
+		_, _ = io.WriteString(w, `{"reference":"`+testReference+`"}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	c := newCommand(t,
+		cmd.WithArgs("upload", dir, "--index-document", "index.html", "--api-addr", server.URL),
+		cmd.WithOutput(&out),
+	)
+	if err := c.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "reference: " + testReference + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
+
+func TestPin(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/pin/chunks/"+testReference {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"message":"Not Found","code":404}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"message":"OK","code":200}`)
+	}))
+	defer server.Close()
+
+	for _, args := range [][]string{
+		{"pin", "add", testReference},
+		{"pin", "add", testReference, "--type", "bytes"},
+		{"pin", "remove", testReference},
+	} {
+		var out bytes.Buffer
+		c := newCommand(t,
+			cmd.WithArgs(append(args, "--api-addr", server.URL)...),
+			cmd.WithOutput(&out),
+		)
+		if err := c.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != "OK\n" {
+			t.Errorf("got output %q, want %q", got, "OK\n")
+		}
+	}
+
+	want := []string{
+		"POST /pin/files/" + testReference,
+		"POST /pin/bytes/" + testReference,
+		"DELETE /pin/files/" + testReference,
+	}
+	if got := strings.Join(requests, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("got requests %v, want %v", requests, want)
+	}
+
+	c := newCommand(t,
+		cmd.WithArgs("pin", "add", testReference, "--type", "chunk", "--api-addr", server.URL),
+		cmd.WithOutput(ioutil.Discard),
+	)
+	err := c.Execute()
+	if err == nil || !strings.Contains(err.Error(), "Not Found") {
+		t.Errorf("got error %v, want not found", err)
+	}
+}
+
+func TestTag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/tags/42" {
+			t.Errorf("got request %s %s, want GET /tags/42", r.Method, r.URL.Path)
+		}
+		_, _ = io.WriteString(w, `{"uid":42,"startedAt":"2020-11-05T10:00:00Z","total":10,"processed":8,"synced":5}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	c := newCommand(t,
+		cmd.WithArgs("tag", "42", "--api-addr", server.URL),
+		cmd.WithOutput(&out),
+	)
+	if err := c.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "uid: 42\nstarted: 2020-11-05T10:00:00Z\ntotal: 10\nprocessed: 8\nsynced: 5\n"
+	if got := out.String(); got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
@@ -52,21 +52,24 @@ func newCommand(opts ...option) (c *command, err error) {
 		return nil, err
 	}
 
-	// c.initGlobalFlags()
+	c.initGlobalFlags()
 
 	if err := c.initStartCmd(); err != nil {
 		return nil, err
 	}
 
-	// if err := c.initInitCmd(); err != nil {
-	// 	return nil, err
-	// }
+	c.initInitCmd()
+	c.initUploadCmd()
+	c.initPinCmd()
+	c.initTagCmd()
+	c.initDBCmd()
+	c.initSOCCmd()
+	c.initKeysCmd()
+	c.initConfigCmd()
 
-	// if err := c.initDeployCmd(); err != nil {
-	// 	return nil, err
-	// }
+	c.initDeployCmd()
 
-	// c.initVersionCmd()
+	c.initVersionCmd()
 
 	// if err := c.initConfigurateOptionsCmd(); err != nil {
 	// 	return nil, err
@@ -90,7 +93,7 @@ func Execute() (err error) {
 
 func (c *command) initGlobalFlags() {
 	globalFlags := c.root.PersistentFlags()
-	globalFlags.StringVar(&c.cfgFile, "config", "", "config file (default is $HOME/.voyager.yaml)")
+	globalFlags.StringVar(&c.cfgFile, "config", c.cfgFile, "config file (default is $HOME/.voyager.yaml)")
 }
 
 func (c *command) initConfig() (err error) {
@@ -114,6 +117,8 @@ func (c *command) initConfig() (err error) {
 		c.cfgFile = filepath.Join(c.homeDir, configName+".yaml")
 	}
 
+	c.config = config
+
 	// If a config file is found, read it in.
 	if err := config.ReadInConfig(); err != nil {
 		var e viper.ConfigFileNotFoundError
@@ -121,7 +126,6 @@ func (c *command) initConfig() (err error) {
 			return err
 		}
 	}
-	c.config = config
 	return nil
 }
 
@@ -0,0 +1,150 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+func (c *command) initConfigCmd() {
+	printCmd := &cobra.Command{
+		Use:   "print",
+		Short: "Print the effective configuration of the node",
+		Long: `Print the effective configuration of the node.
+
+The options of the start command are merged from the command line flags, the
+environment variables with the VOYAGER_ prefix and the config file, in that
+order of precedence, and printed in the config file format. The values of the
+passwords, the secrets and the keys are redacted.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var config yaml.MapSlice
+			c.visitStartFlags(func(f *pflag.Flag) {
+				value := configValue(c.config, f)
+				if secretOption(f) && c.config.GetString(f.Name) != "" {
+					value = redactedValue
+				}
+				config = append(config, yaml.MapItem{Key: f.Name, Value: value})
+			})
+			b, err := yaml.Marshal(config)
+			if err != nil {
+				return err
+			}
+			cmd.Print(string(b))
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.config.BindPFlags(cmd.Flags())
+		},
+	}
+	// the start options can be overridden by the flags, as on start
+	printCmd.Flags().AddFlagSet(c.root.LocalNonPersistentFlags())
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the node configuration",
+	}
+	cmd.AddCommand(printCmd)
+
+	c.root.AddCommand(cmd)
+}
+
+// redactedValue replaces the values of the secret options which are set.
+const redactedValue = "<redacted>"
+
+// secretOption reports whether the value of the option is a password, a
+// secret or a key, which is not printed. The paths of the files holding them
+// are not secret, nor are the options enabling them.
+func secretOption(f *pflag.Flag) bool {
+	if f.Value.Type() != "string" {
+		return false
+	}
+	return strings.HasSuffix(f.Name, "password") ||
+		strings.HasSuffix(f.Name, "-key") ||
+		strings.Contains(f.Name, "secret")
+}
+
+// visitStartFlags calls the function for every flag of the start command in
+// the lexicographical order.
+func (c *command) visitStartFlags(fn func(f *pflag.Flag)) {
+	c.root.LocalNonPersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Name == "help" {
+			return
+		}
+		fn(f)
+	})
+}
+
+// configValue returns the value of the option of the flag typed for the
+// config file.
+func configValue(config *viper.Viper, f *pflag.Flag) interface{} {
+	switch f.Value.Type() {
+	case "bool":
+		return config.GetBool(f.Name)
+	case "int", "int64":
+		return config.GetInt64(f.Name)
+	case "uint64":
+		return config.GetUint64(f.Name)
+	case "duration":
+		return config.GetDuration(f.Name).String()
+	case "stringSlice":
+		return config.GetStringSlice(f.Name)
+	}
+	return config.GetString(f.Name)
+}
+
+// writeConfigFile writes the config file with all start options commented
+// out with their default values and descriptions. An existing config file is
+// not overwritten and false is returned.
+func (c *command) writeConfigFile(path string) (written bool, err error) {
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	defaults := viper.New()
+	if err := defaults.BindPFlags(c.root.LocalNonPersistentFlags()); err != nil {
+		return false, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Voyager node configuration.\n")
+	buf.WriteString("#\n")
+	buf.WriteString("# Uncomment an option to change its default value. The options can also be\n")
+	buf.WriteString("# set by the command line flags of the same name and by the environment\n")
+	buf.WriteString("# variables with the VOYAGER_ prefix, for example VOYAGER_API_WRITE_TIMEOUT.\n")
+
+	var visitErr error
+	c.visitStartFlags(func(f *pflag.Flag) {
+		b, err := yaml.Marshal(yaml.MapSlice{{Key: f.Name, Value: configValue(defaults, f)}})
+		if err != nil {
+			visitErr = err
+			return
+		}
+		fmt.Fprintf(&buf, "\n# %s\n", f.Usage)
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(string(b), "\n"), "\n") {
+			buf.WriteString("# " + line)
+		}
+		buf.WriteString("\n")
+	})
+	if visitErr != nil {
+		return false, visitErr
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/cmd/voyager/cmd"
+)
+
+func TestConfigPrintCmd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "voyager-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfgFile := filepath.Join(dir, "voyager.yaml")
+	if err := ioutil.WriteFile(cfgFile, []byte("api-write-timeout: 10s\napi-max-upload-size: 100\ntelemetry-endpoint: file\nswap-endpoint: http://127.0.0.1:8545\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Setenv("VOYAGER_TELEMETRY_ENDPOINT", "env"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("VOYAGER_TELEMETRY_ENDPOINT")
+	if err := os.Setenv("VOYAGER_COLD_STORE_SECRET_KEY", "env-secret"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("VOYAGER_COLD_STORE_SECRET_KEY")
+
+	var out bytes.Buffer
+	c := newCommand(t,
+		cmd.WithArgs("config", "print", "--api-max-upload-size", "200", "--cold-store-access-key", "flag-key"),
+		cmd.WithCfgFile(cfgFile),
+		cmd.WithOutput(&out),
+	)
+	if err := c.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"api-max-upload-size: 200\n",      // flag
+		"telemetry-endpoint: env\n",       // environment
+		"api-write-timeout: 10s\n",        // config file
+		"api-upload-read-timeout: 1m0s\n", // default
+		"swap-endpoint: http://127.0.0.1:8545\n",
+		"cold-store-access-key: <redacted>\n",
+		"cold-store-secret-key: <redacted>\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output %q does not contain %q", out.String(), want)
+		}
+	}
+	for _, secret := range []string{"flag-key", "env-secret"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("output %q contains %q", out.String(), secret)
+		}
+	}
+}
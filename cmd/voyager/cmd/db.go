@@ -0,0 +1,137 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	filekeystore "github.com/yanhuangpai/voyager/pkg/keystore/file"
+	"github.com/yanhuangpai/voyager/pkg/localstore"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+)
+
+const (
+	optionNameDataDir  = "data-dir"
+	optionNameDBRemove = "remove"
+
+	defaultDataDir = "./"
+)
+
+func (c *command) initDBCmd() {
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the integrity of the locally stored chunks",
+		Long: `Verify the integrity of the locally stored chunks.
+
+Every stored chunk is checked to match its content address or single owner
+chunk address and the corrupted chunks are reported. The node must not be
+running. To retrieve the corrupted chunks from the network again, start the
+node with the db-verify option.
+
+Removing the corrupted chunks requires the overlay address of the node, so the
+keys of the node are unlocked with the password options or the prompted
+password.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			path := filepath.Join(c.config.GetString(optionNameDataDir), "localstore")
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("localstore: %w", err)
+			}
+
+			remove := c.config.GetBool(optionNameDBRemove)
+
+			// the base key is used to compute the bins of the removed
+			// chunks, so it must be the overlay address of the node
+			baseKey := make([]byte, infinity.HashSize)
+			if remove {
+				overlay, err := c.nodeOverlay()
+				if err != nil {
+					return fmt.Errorf("overlay address: %w", err)
+				}
+				baseKey = overlay.Bytes()
+			}
+
+			db, err := localstore.New(path, baseKey, nil, logging.New(ioutil.Discard, 0))
+			if err != nil {
+				return fmt.Errorf("localstore: %w", err)
+			}
+			defer func() {
+				if e := db.Close(); e != nil && err == nil {
+					err = fmt.Errorf("close localstore: %w", e)
+				}
+			}()
+
+			r, err := db.Verify(cmd.Context(), localstore.VerifyOptions{
+				Remove: remove,
+			})
+			if err != nil {
+				return err
+			}
+			for _, addr := range r.Corrupted {
+				cmd.Printf("corrupted: %s\n", addr)
+			}
+			cmd.Printf("verified: %d\n", r.Total)
+			cmd.Printf("corrupted: %d\n", len(r.Corrupted))
+			cmd.Printf("removed: %d\n", r.Removed)
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.config.BindPFlags(cmd.Flags())
+		},
+	}
+	verifyCmd.Flags().String(optionNameDataDir, defaultDataDir, "data directory of the node")
+	verifyCmd.Flags().Bool(optionNameDBRemove, false, "remove the corrupted chunks")
+	verifyCmd.Flags().String(optionNamePassword, "", "password for the keys, prompted for if not set, needed to remove the corrupted chunks")
+	verifyCmd.Flags().String(optionNamePasswordFile, "", "file to read the password for the keys from")
+
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Maintain the local store of a stopped node",
+	}
+	cmd.AddCommand(verifyCmd)
+
+	c.root.AddCommand(cmd)
+}
+
+// nodeOverlay returns the overlay address of the node in the data directory,
+// derived from its key and the overlay nonce in its state store.
+func (c *command) nodeOverlay() (infinity.Address, error) {
+	dataDir := c.config.GetString(optionNameDataDir)
+	ks := filekeystore.New(filepath.Join(dataDir, "keys"))
+	exists, err := ks.Exists("smartchain")
+	if err != nil {
+		return infinity.ZeroAddress, fmt.Errorf("smart chain key: %w", err)
+	}
+	if !exists {
+		return infinity.ZeroAddress, errors.New("smart chain key not found")
+	}
+
+	password, err := c.password(optionNamePassword, optionNamePasswordFile)
+	if err != nil {
+		return infinity.ZeroAddress, err
+	}
+	if password == "" {
+		if password, err = terminalPromptPassword(c.passwordReader, "Password"); err != nil {
+			return infinity.ZeroAddress, err
+		}
+	}
+	key, _, err := ks.Key("smartchain", password)
+	if err != nil {
+		return infinity.ZeroAddress, fmt.Errorf("smart chain key: %w", err)
+	}
+
+	nonce, err := readOverlayNonce(logging.New(ioutil.Discard, 0), dataDir)
+	if err != nil {
+		return infinity.ZeroAddress, err
+	}
+	return crypto.NewOverlayAddress(key.PublicKey, networkID, nonce)
+}
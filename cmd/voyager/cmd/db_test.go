@@ -0,0 +1,106 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/cmd/voyager/cmd"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+	filekeystore "github.com/yanhuangpai/voyager/pkg/keystore/file"
+	"github.com/yanhuangpai/voyager/pkg/localstore"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	testingc "github.com/yanhuangpai/voyager/pkg/storage/testing"
+)
+
+func TestDBVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "voyager-db-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, _, err := filekeystore.New(filepath.Join(dir, "keys")).Key("smartchain", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay, err := crypto.NewOverlayAddress(key.PublicKey, cmd.NetworkID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := localstore.New(filepath.Join(dir, "localstore"), overlay.Bytes(), nil, logging.New(ioutil.Discard, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the address of the invalid chunk does not match its data
+	corrupted := testingc.GenerateTestRandomInvalidChunk()
+	if _, err := db.Put(context.Background(), storage.ModePutUpload, testingc.GenerateTestRandomChunk(), corrupted); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("remove with wrong password", func(t *testing.T) {
+		c := newCommand(t,
+			cmd.WithArgs("db", "verify", "--data-dir", dir, "--remove", "--password", "wrong"),
+			cmd.WithOutput(ioutil.Discard),
+		)
+		if err := c.Execute(); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "report",
+			want: "corrupted: " + corrupted.Address().String() + "\nverified: 2\ncorrupted: 1\nremoved: 0\n",
+		},
+		{
+			name: "remove",
+			args: []string{"--remove", "--password", "secret"},
+			want: "corrupted: " + corrupted.Address().String() + "\nverified: 2\ncorrupted: 1\nremoved: 1\n",
+		},
+		{
+			name: "removed",
+			want: "verified: 1\ncorrupted: 0\nremoved: 0\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			c := newCommand(t,
+				cmd.WithArgs(append([]string{"db", "verify", "--data-dir", dir}, tc.args...)...),
+				cmd.WithOutput(&out),
+			)
+			if err := c.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("got output %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("no localstore", func(t *testing.T) {
+		c := newCommand(t,
+			cmd.WithArgs("db", "verify", "--data-dir", filepath.Join(dir, "missing")),
+			cmd.WithOutput(ioutil.Discard),
+		)
+		if err := c.Execute(); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"math/big"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+	filekeystore "github.com/yanhuangpai/voyager/pkg/keystore/file"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/node"
+	"github.com/yanhuangpai/voyager/pkg/settlement/swap/chequebook"
+)
+
+const (
+	optionNameSwapEndpoint       = "swap-endpoint"
+	optionNameSwapFactoryAddress = "swap-factory-address"
+	optionNameSwapInitialDeposit = "swap-initial-deposit"
+)
+
+func (c *command) initDeployCmd() {
+	cmd := &cobra.Command{
+		Use:   "deploy",
+		Short: "Deploy the chequebook of a Voyager node",
+		Long: `Deploy the chequebook of a Voyager node without starting it.
+
+The keys of the node are created in the keys directory of the data directory
+if they do not exist yet. Once the Smart Chain address of the node is funded
+with the gas and the initial deposit, the chequebook is deployed, the initial
+deposit is made and the chequebook address is printed. The chequebook is
+stored in the state store of the node and it is used by the node when it is
+started. An already deployed chequebook is not deployed again. The node must
+not be running.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			verbosity, err := logging.ParseVerbosity(c.config.GetString(optionNameVerbosity))
+			if err != nil {
+				return fmt.Errorf("%s: %w", optionNameVerbosity, err)
+			}
+			logger := logging.New(cmd.ErrOrStderr(), verbosity)
+
+			deposit := c.config.GetString(optionNameSwapInitialDeposit)
+			if _, ok := new(big.Int).SetString(deposit, 10); !ok {
+				return fmt.Errorf("%s: invalid amount %q", optionNameSwapInitialDeposit, deposit)
+			}
+
+			dataDir := c.config.GetString(optionNameDataDir)
+			keystore := filekeystore.New(filepath.Join(dataDir, "keys"))
+
+			password, err := c.keysPassword(keystore)
+			if err != nil {
+				return err
+			}
+
+			infinityPrivateKey, _, err := keystore.Key("smartchain", password)
+			if err != nil {
+				return fmt.Errorf("smart chain key: %w", err)
+			}
+			// the other keys are created as well, so that the node is
+			// started with the same password
+			for _, name := range []string{"libp2p", "pss"} {
+				if _, _, err := keystore.Key(name, password); err != nil {
+					return fmt.Errorf("%s key: %w", name, err)
+				}
+			}
+			signer := crypto.NewDefaultSigner(infinityPrivateKey)
+
+			address, err := signer.EthereumAddress()
+			if err != nil {
+				return err
+			}
+			cmd.Printf("smart chain address: %s\n", address.String())
+
+			stateStore, err := node.InitStateStore(logger, dataDir)
+			if err != nil {
+				return fmt.Errorf("state store: %w", err)
+			}
+			defer func() {
+				if e := stateStore.Close(); e != nil && err == nil {
+					err = fmt.Errorf("close state store: %w", e)
+				}
+			}()
+
+			ctx := cmd.Context()
+			swapBackend, overlayEthAddress, chainID, transactionService, err := node.InitChain(
+				ctx,
+				logger,
+				stateStore,
+				c.config.GetString(optionNameSwapEndpoint),
+				signer,
+			)
+			if err != nil {
+				return err
+			}
+			defer swapBackend.Close()
+
+			chequebookFactory, err := node.InitChequebookFactory(
+				logger,
+				swapBackend,
+				chainID,
+				transactionService,
+				c.config.GetString(optionNameSwapFactoryAddress),
+			)
+			if err != nil {
+				return err
+			}
+
+			// the balance of the address is checked before the deployment
+			// and it waits for the funding
+			chequebookService, err := node.InitChequebookService(
+				ctx,
+				logger,
+				stateStore,
+				signer,
+				chainID,
+				swapBackend,
+				overlayEthAddress,
+				transactionService,
+				chequebookFactory,
+				deposit,
+				// no cheques are issued by the deploy command
+				chequebook.Limits{},
+			)
+			if err != nil {
+				return err
+			}
+
+			cmd.Printf("chequebook address: %s\n", chequebookService.Address().String())
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.config.BindPFlags(cmd.Flags())
+		},
+	}
+	cmd.Flags().String(optionNameDataDir, defaultDataDir, "data directory of the node")
+	cmd.Flags().String(optionNamePassword, "", "password for the keys, prompted for if not set")
+	cmd.Flags().String(optionNamePasswordFile, "", "file to read the password for the keys from, for example a systemd credential or /dev/fd/3")
+	cmd.Flags().String(optionNameVerbosity, "info", "log verbosity level 0=silent, 1=error, 2=warn, 3=info, 4=debug, 5=trace")
+	cmd.Flags().String(optionNameSwapEndpoint, swapEndpoint, "smart chain blockchain endpoint")
+	cmd.Flags().String(optionNameSwapFactoryAddress, swapFactoryAddress, "chequebook factory address, the known address of the network if empty")
+	cmd.Flags().String(optionNameSwapInitialDeposit, "0", "initial deposit of the chequebook in token base units")
+
+	c.root.AddCommand(cmd)
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/cmd/voyager/cmd"
+)
+
+func TestDeployCmd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "voyager-deploy-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("invalid deposit", func(t *testing.T) {
+		c := newCommand(t,
+			cmd.WithArgs("deploy", "--data-dir", dir, "--password", "secret", "--swap-initial-deposit", "1.5"),
+			cmd.WithOutput(ioutil.Discard),
+			cmd.WithErrorOutput(ioutil.Discard),
+		)
+		err := c.Execute()
+		if err == nil || !strings.Contains(err.Error(), "swap-initial-deposit") {
+			t.Fatalf("got error %v", err)
+		}
+		// nothing is created with the invalid options
+		if _, err := os.Stat(filepath.Join(dir, "keys")); !os.IsNotExist(err) {
+			t.Fatalf("got keys directory error %v", err)
+		}
+	})
+
+	t.Run("unreachable endpoint", func(t *testing.T) {
+		var out bytes.Buffer
+		c := newCommand(t,
+			cmd.WithArgs("deploy", "--data-dir", dir, "--password", "secret", "--verbosity", "0", "--swap-endpoint", "http://127.0.0.1:1"),
+			cmd.WithOutput(&out),
+			cmd.WithErrorOutput(ioutil.Discard),
+		)
+		err := c.Execute()
+		if err == nil || !strings.Contains(err.Error(), "get chain id") {
+			t.Fatalf("got error %v", err)
+		}
+		// the keys are created before the chequebook is deployed
+		for _, name := range []string{"smartchain", "libp2p", "pss"} {
+			if _, err := os.Stat(filepath.Join(dir, "keys", name+".key")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !strings.HasPrefix(out.String(), "smart chain address: 0x") {
+			t.Fatalf("got output %q", out.String())
+		}
+	})
+}
@@ -12,6 +12,8 @@ type (
 	PasswordReader = passwordReader
 )
 
+const NetworkID = networkID
+
 var (
 	NewCommand = newCommand
 
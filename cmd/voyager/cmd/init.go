@@ -0,0 +1,179 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/keystore"
+	filekeystore "github.com/yanhuangpai/voyager/pkg/keystore/file"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/node"
+)
+
+const (
+	optionNamePassword           = "password"
+	optionNameTargetNeighborhood = "target-neighborhood"
+)
+
+// maxTargetNeighborhoodBits is the maximal number of the bits of the target
+// neighborhood prefix, as about 2^bits overlay addresses are derived to find
+// one in the neighborhood.
+const maxTargetNeighborhoodBits = 24
+
+func (c *command) initInitCmd() {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Initialise a Voyager node",
+		Long: `Initialise a Voyager node.
+
+The keys of the node are generated in the keys directory of the data directory
+and the config file with all start options commented out is written, unless
+it already exists. The keys which already exist are kept and have to be
+unlocked with their password.
+
+With the target neighborhood, the overlay address of the node is mined, so
+that it starts with the binary prefix, for example 0110. The nonce the overlay
+address is derived with is stored in the state store of the node and it is
+used when the node is started. The overlay address can not be changed once
+the node is started.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			keystore := filekeystore.New(filepath.Join(c.config.GetString(optionNameDataDir), "keys"))
+
+			password, err := c.keysPassword(keystore)
+			if err != nil {
+				return err
+			}
+
+			infinityPrivateKey, _, err := keystore.Key("smartchain", password)
+			if err != nil {
+				return fmt.Errorf("smart chain key: %w", err)
+			}
+			if _, _, err := keystore.Key("libp2p", password); err != nil {
+				return fmt.Errorf("libp2p key: %w", err)
+			}
+			pssPrivateKey, _, err := keystore.Key("pss", password)
+			if err != nil {
+				return fmt.Errorf("pss key: %w", err)
+			}
+
+			address, err := crypto.NewDefaultSigner(infinityPrivateKey).EthereumAddress()
+			if err != nil {
+				return err
+			}
+			cmd.Printf("smart chain address: %s\n", address.String())
+			cmd.Printf("public key: %x\n", crypto.EncodeSecp256k1PublicKey(&infinityPrivateKey.PublicKey))
+			cmd.Printf("pss public key: %x\n", crypto.EncodeSecp256k1PublicKey(&pssPrivateKey.PublicKey))
+
+			if n := c.config.GetString(optionNameTargetNeighborhood); n != "" {
+				prefix, bits, err := parseNeighborhood(n)
+				if err != nil {
+					return fmt.Errorf("%s: %w", optionNameTargetNeighborhood, err)
+				}
+				overlay, err := c.mineOverlay(cmd, address.Bytes(), prefix, bits)
+				if err != nil {
+					return err
+				}
+				cmd.Printf("overlay address: %s\n", overlay)
+			}
+
+			written, err := c.writeConfigFile(c.cfgFile)
+			if err != nil {
+				return fmt.Errorf("config file: %w", err)
+			}
+			if written {
+				cmd.Printf("config file written: %s\n", c.cfgFile)
+			} else {
+				cmd.Printf("config file exists: %s\n", c.cfgFile)
+			}
+			return nil
+		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// the config file is written by the command, so the config
+			// file set by the flag may not exist yet
+			if err := c.initConfig(); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.config.BindPFlags(cmd.Flags())
+		},
+	}
+	cmd.Flags().String(optionNameDataDir, defaultDataDir, "data directory of the node")
+	cmd.Flags().String(optionNamePassword, "", "password for the keys, prompted for if not set")
+	cmd.Flags().String(optionNamePasswordFile, "", "file to read the password for the keys from, for example a systemd credential or /dev/fd/3")
+	cmd.Flags().String(optionNameTargetNeighborhood, "", "binary prefix of the overlay address to mine, for example 0110")
+
+	c.root.AddCommand(cmd)
+}
+
+// mineOverlay mines the overlay nonce with which the overlay address of the
+// node with the Ethereum address is in the neighborhood and stores it in the
+// state store of the node.
+func (c *command) mineOverlay(cmd *cobra.Command, ethAddr, prefix []byte, bits int) (overlay infinity.Address, err error) {
+	stateStore, err := node.InitStateStore(logging.New(ioutil.Discard, 0), c.config.GetString(optionNameDataDir))
+	if err != nil {
+		return infinity.ZeroAddress, fmt.Errorf("state store: %w", err)
+	}
+	defer func() {
+		if e := stateStore.Close(); e != nil && err == nil {
+			err = fmt.Errorf("close state store: %w", e)
+		}
+	}()
+
+	nonce, overlay, err := crypto.MineOverlayNonce(cmd.Context(), ethAddr, networkID, prefix, bits)
+	if err != nil {
+		return infinity.ZeroAddress, fmt.Errorf("mine overlay address: %w", err)
+	}
+	if err := node.SetOverlayNonce(nonce, stateStore); err != nil {
+		return infinity.ZeroAddress, fmt.Errorf("overlay nonce: %w", err)
+	}
+	return overlay, nil
+}
+
+// parseNeighborhood parses the neighborhood given as the binary prefix of the
+// overlay addresses in it.
+func parseNeighborhood(s string) (prefix []byte, bits int, err error) {
+	if len(s) > maxTargetNeighborhoodBits {
+		return nil, 0, fmt.Errorf("prefix longer than %d bits", maxTargetNeighborhoodBits)
+	}
+	prefix = make([]byte, (len(s)+7)/8)
+	for i, b := range s {
+		switch b {
+		case '0':
+		case '1':
+			prefix[i/8] |= 0x80 >> (i % 8)
+		default:
+			return nil, 0, fmt.Errorf("invalid binary prefix %q", s)
+		}
+	}
+	return prefix, len(s), nil
+}
+
+// keysPassword returns the password of the keys of the node set with the
+// password options or prompts for it, with a confirmation if the keys do not
+// exist yet and are created with it.
+func (c *command) keysPassword(ks keystore.Service) (string, error) {
+	password, err := c.password(optionNamePassword, optionNamePasswordFile)
+	if err != nil || password != "" {
+		return password, err
+	}
+	exists, err := ks.Exists("libp2p")
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return terminalPromptPassword(c.passwordReader, "Password")
+	}
+	return terminalPromptCreatePassword(c.passwordReader)
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/yanhuangpai/voyager/cmd/voyager/cmd"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/node"
+)
+
+func TestInitCmd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "voyager-init-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfgFile := filepath.Join(dir, "voyager.yaml")
+
+	run := func(t *testing.T, password string) string {
+		t.Helper()
+
+		var out bytes.Buffer
+		c := newCommand(t,
+			cmd.WithArgs("init", "--data-dir", dir, "--password", password),
+			cmd.WithCfgFile(cfgFile),
+			cmd.WithOutput(&out),
+		)
+		if err := c.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	out := run(t, "secret")
+	if !strings.HasSuffix(out, "config file written: "+cfgFile+"\n") {
+		t.Fatalf("got output %q", out)
+	}
+	for _, name := range []string{"smartchain", "libp2p", "pss"} {
+		if _, err := os.Stat(filepath.Join(dir, "keys", name+".key")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config, err := ioutil.ReadFile(cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\n# api-write-timeout: 4s\n"; !bytes.Contains(config, []byte(want)) {
+		t.Errorf("config file %q does not contain %q", config, want)
+	}
+
+	t.Run("existing", func(t *testing.T) {
+		// the keys and the config file are kept
+		got := run(t, "secret")
+		if want := strings.Replace(out, "config file written", "config file exists", 1); got != want {
+			t.Errorf("got output %q, want %q", got, want)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		c := newCommand(t,
+			cmd.WithArgs("init", "--data-dir", dir, "--password", "wrong"),
+			cmd.WithCfgFile(cfgFile),
+			cmd.WithOutput(ioutil.Discard),
+		)
+		if err := c.Execute(); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestInitCmdTargetNeighborhood(t *testing.T) {
+	dir, err := ioutil.TempDir("", "voyager-init-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	run := func(t *testing.T, neighborhood string) (string, error) {
+		t.Helper()
+
+		var out bytes.Buffer
+		c := newCommand(t,
+			cmd.WithArgs("init", "--data-dir", dir, "--password", "secret", "--target-neighborhood", neighborhood),
+			cmd.WithCfgFile(filepath.Join(dir, "voyager.yaml")),
+			cmd.WithOutput(&out),
+		)
+		err := c.Execute()
+		return out.String(), err
+	}
+
+	out, err := run(t, "0110")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var overlay, ethAddress string
+	for _, line := range strings.Split(out, "\n") {
+		if v := strings.TrimPrefix(line, "overlay address: "); v != line {
+			overlay = v
+		}
+		if v := strings.TrimPrefix(line, "smart chain address: "); v != line {
+			ethAddress = v
+		}
+	}
+	// the first hex digit of the overlay address has the bits 0110
+	if !strings.HasPrefix(overlay, "6") {
+		t.Fatalf("got overlay address %q, want in neighborhood 0110", overlay)
+	}
+
+	logger := logging.New(ioutil.Discard, 0)
+	stateStore, err := node.InitStateStore(logger, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := node.OverlayNonce(stateStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := crypto.NewOverlayFromEthereumAddress(common.HexToAddress(ethAddress).Bytes(), cmd.NetworkID, nonce)
+	if got.String() != overlay {
+		t.Fatalf("got overlay address %s from the stored nonce, want %s", got, overlay)
+	}
+
+	// the node is started with the overlay address
+	if err := node.CheckOverlayWithStore(got, stateStore); err != nil {
+		t.Fatal(err)
+	}
+	if err := stateStore.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("overlay in use", func(t *testing.T) {
+		if _, err := run(t, "1"); !errors.Is(err, node.ErrOverlayInUse) {
+			t.Fatalf("got error %v, want %v", err, node.ErrOverlayInUse)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, n := range []string{"012", "0x1", strings.Repeat("1", 25)} {
+			if _, err := run(t, n); err == nil {
+				t.Errorf("neighborhood %q: expected error", n)
+			}
+		}
+	})
+}
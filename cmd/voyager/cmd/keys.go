@@ -0,0 +1,149 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	filekeystore "github.com/yanhuangpai/voyager/pkg/keystore/file"
+)
+
+const (
+	optionNamePasswordFile    = "password-file"
+	optionNameNewPassword     = "new-password"
+	optionNameNewPasswordFile = "new-password-file"
+)
+
+// keyNames are the names of the keys in the keystore of the node.
+var keyNames = []string{"smartchain", "libp2p", "pss"}
+
+func (c *command) initKeysCmd() {
+	rotatePasswordCmd := &cobra.Command{
+		Use:   "rotate-password",
+		Short: "Encrypt the keys of the node with a new password",
+		Long: `Encrypt the keys of the node with a new password.
+
+All existing keys in the keys directory of the data directory are first
+decrypted with the current password and written encrypted with the new one
+next to the old files, which are replaced only after all new files are
+written, so a wrong current password or a failed write leaves all keys
+unchanged. The node must not be running.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			// the passwords are not kept in the configuration after the
+			// rotation
+			defer func() {
+				c.config.Set(optionNamePassword, "")
+				c.config.Set(optionNameNewPassword, "")
+			}()
+
+			ks := filekeystore.New(filepath.Join(c.config.GetString(optionNameDataDir), "keys"))
+
+			var names []string
+			for _, name := range keyNames {
+				exists, err := ks.Exists(name)
+				if err != nil {
+					return fmt.Errorf("%s key: %w", name, err)
+				}
+				if exists {
+					names = append(names, name)
+				}
+			}
+			if len(names) == 0 {
+				return errors.New("no keys found")
+			}
+
+			password, err := c.password(optionNamePassword, optionNamePasswordFile)
+			if err != nil {
+				return err
+			}
+			if password == "" {
+				if password, err = terminalPromptPassword(c.passwordReader, "Password"); err != nil {
+					return err
+				}
+			}
+			for _, name := range names {
+				if _, _, err := ks.Key(name, password); err != nil {
+					return fmt.Errorf("%s key: %w", name, err)
+				}
+			}
+
+			newPassword, err := c.password(optionNameNewPassword, optionNameNewPasswordFile)
+			if err != nil {
+				return err
+			}
+			if newPassword == "" {
+				if newPassword, err = terminalPromptNewPassword(c.passwordReader); err != nil {
+					return err
+				}
+			}
+
+			if err := ks.SetPasswords(password, newPassword, names...); err != nil {
+				return fmt.Errorf("keys: %w", err)
+			}
+			for _, name := range names {
+				cmd.Printf("%s key encrypted with the new password\n", name)
+			}
+			return nil
+		},
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.config.BindPFlags(cmd.Flags())
+		},
+	}
+	rotatePasswordCmd.Flags().String(optionNameDataDir, defaultDataDir, "data directory of the node")
+	rotatePasswordCmd.Flags().String(optionNamePassword, "", "current password for the keys, prompted for if not set")
+	rotatePasswordCmd.Flags().String(optionNamePasswordFile, "", "file to read the current password from, for example a systemd credential or /dev/fd/3")
+	rotatePasswordCmd.Flags().String(optionNameNewPassword, "", "new password for the keys, prompted for if not set")
+	rotatePasswordCmd.Flags().String(optionNameNewPasswordFile, "", "file to read the new password from")
+
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage the keys of a stopped node",
+	}
+	cmd.AddCommand(rotatePasswordCmd)
+
+	c.root.AddCommand(cmd)
+}
+
+// password returns the password read from the file set by the file option,
+// or set by the option, or an empty string if neither is set. The file takes
+// precedence, as in the start command, where it overrides the device key.
+func (c *command) password(optionName, fileOptionName string) (string, error) {
+	if f := c.config.GetString(fileOptionName); f != "" {
+		p, err := readPasswordFile(f)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", fileOptionName, err)
+		}
+		return p, nil
+	}
+	return c.config.GetString(optionName), nil
+}
+
+// readPasswordFile reads the password from the first line of the file. The
+// file may also be a file descriptor passed by the service manager, like
+// /dev/fd/3, as only the first line is read from it.
+func readPasswordFile(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	return password, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/cmd/voyager/cmd"
+	"github.com/yanhuangpai/voyager/pkg/keystore"
+	filekeystore "github.com/yanhuangpai/voyager/pkg/keystore/file"
+)
+
+func TestKeysRotatePasswordCmd(t *testing.T) {
+	dir := t.TempDir()
+	ks := filekeystore.New(filepath.Join(dir, "keys"))
+	for _, name := range []string{"smartchain", "libp2p", "pss"} {
+		if _, _, err := ks.Key(name, "secret"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(t *testing.T, args ...string) error {
+		t.Helper()
+
+		c := newCommand(t,
+			cmd.WithArgs(append([]string{"keys", "rotate-password", "--data-dir", dir}, args...)...),
+			cmd.WithOutput(ioutil.Discard),
+		)
+		return c.Execute()
+	}
+
+	t.Run("wrong password", func(t *testing.T) {
+		if err := run(t, "--password", "wrong", "--new-password", "new secret"); !errors.Is(err, keystore.ErrInvalidPassword) {
+			t.Fatalf("got error %v, want %v", err, keystore.ErrInvalidPassword)
+		}
+		if _, _, err := ks.Key("libp2p", "secret"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("password file", func(t *testing.T) {
+		passwordFile := filepath.Join(dir, "password")
+		if err := ioutil.WriteFile(passwordFile, []byte("new secret\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		// the password file takes precedence over the password
+		if err := run(t, "--password", "secret", "--new-password", "other secret", "--new-password-file", passwordFile); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"smartchain", "libp2p", "pss"} {
+			if _, _, err := ks.Key(name, "secret"); !errors.Is(err, keystore.ErrInvalidPassword) {
+				t.Fatalf("%s key: got error %v, want %v", name, err, keystore.ErrInvalidPassword)
+			}
+			if _, _, err := ks.Key(name, "new secret"); err != nil {
+				t.Fatalf("%s key: %v", name, err)
+			}
+		}
+	})
+}
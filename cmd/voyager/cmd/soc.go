@@ -0,0 +1,70 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/soc"
+)
+
+func (c *command) initSOCCmd() {
+	verifyCmd := &cobra.Command{
+		Use:   "verify ADDRESS [FILE]",
+		Short: "Verify a single owner chunk",
+		Long: `Verify a single owner chunk.
+
+The data of the chunk with the address is read from the file, or from the
+standard input if the file is not set. It is the id, the signature and the
+wrapped content addressed chunk with its span, as the chunk is stored and
+sent to the network. The chunk is valid if its address matches the id and the
+owner recovered from the signature, so the chunks of any owner can be
+verified. The id, the owner and the wrapped chunk address of a valid chunk are
+printed, otherwise the reason why the chunk is not valid is returned.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			address, err := infinity.ParseHexAddress(args[0])
+			if err != nil {
+				return fmt.Errorf("address: %w", err)
+			}
+
+			var r io.Reader = cmd.InOrStdin()
+			if len(args) > 1 {
+				f, err := os.Open(args[1])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return fmt.Errorf("read chunk: %w", err)
+			}
+
+			s, err := soc.VerifyChunk(infinity.NewChunk(address, data))
+			if err != nil {
+				return err
+			}
+			cmd.Printf("id: %x\n", s.ID())
+			cmd.Printf("owner: %x\n", s.OwnerAddress())
+			cmd.Printf("wrapped chunk: %s\n", s.WrappedChunk().Address())
+			return nil
+		},
+	}
+
+	cmd := &cobra.Command{
+		Use:   "soc",
+		Short: "Inspect single owner chunks",
+	}
+	cmd.AddCommand(verifyCmd)
+
+	c.root.AddCommand(cmd)
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/cmd/voyager/cmd"
+	"github.com/yanhuangpai/voyager/pkg/soc"
+	soctesting "github.com/yanhuangpai/voyager/pkg/soc/testing"
+)
+
+func TestSOCVerify(t *testing.T) {
+	s := soctesting.GenerateMockSOC(t, []byte("foo"))
+	ch := s.Chunk()
+	want := fmt.Sprintf("id: %x\nowner: %x\nwrapped chunk: %s\n", s.ID, s.Owner, s.WrappedChunk.Address())
+
+	t.Run("input", func(t *testing.T) {
+		var out bytes.Buffer
+		c := newCommand(t,
+			cmd.WithArgs("soc", "verify", ch.Address().String()),
+			cmd.WithInput(bytes.NewReader(ch.Data())),
+			cmd.WithOutput(&out),
+		)
+		if err := c.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != want {
+			t.Errorf("got output %q, want %q", got, want)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "voyager-soc-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		file := filepath.Join(dir, "chunk")
+		if err := ioutil.WriteFile(file, ch.Data(), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		c := newCommand(t,
+			cmd.WithArgs("soc", "verify", ch.Address().String(), file),
+			cmd.WithOutput(&out),
+		)
+		if err := c.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.String(); got != want {
+			t.Errorf("got output %q, want %q", got, want)
+		}
+	})
+
+	t.Run("other owner", func(t *testing.T) {
+		// the chunk is signed by a different owner than the one of the address
+		other := soctesting.GenerateMockSOC(t, []byte("foo"))
+		c := newCommand(t,
+			cmd.WithArgs("soc", "verify", ch.Address().String()),
+			cmd.WithInput(bytes.NewReader(other.Chunk().Data())),
+			cmd.WithOutput(ioutil.Discard),
+		)
+		if err := c.Execute(); !errors.Is(err, soc.ErrInvalidChunk) {
+			t.Fatalf("got error %v, want %v", err, soc.ErrInvalidChunk)
+		}
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		c := newCommand(t,
+			cmd.WithArgs("soc", "verify", "invalid"),
+			cmd.WithInput(bytes.NewReader(ch.Data())),
+			cmd.WithOutput(ioutil.Discard),
+		)
+		if err := c.Execute(); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
@@ -17,9 +17,11 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/kardianos/service"
-	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	// "github.com/yanhuangpai/voyager/hpc"
 
@@ -27,6 +29,7 @@ import (
 
 	"github.com/yanhuangpai/voyager/pkg/cpc"
 	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/crypto/clef"
 	"github.com/yanhuangpai/voyager/pkg/keystore"
 	filekeystore "github.com/yanhuangpai/voyager/pkg/keystore/file"
 	"github.com/yanhuangpai/voyager/pkg/logging"
@@ -37,37 +40,178 @@ import (
 
 const (
 	serviceName = "InfinityVoyagerSvc"
+
+	// networkID is the ID of the network the node is connected to
+	networkID = 16688
+
+	// swapEndpoint is the blockchain endpoint of the network.
+	swapEndpoint = "http://52.77.248.72:18545"
+	// swapFactoryAddress is the chequebook factory address of the network.
+	swapFactoryAddress = "0x7edFFD0a5422d4A9241DB77633CAfba8b578bE75"
+
+	optionNameTelemetry         = "telemetry"
+	optionNameTelemetryEndpoint = "telemetry-endpoint"
+	optionNameAPICompression    = "api-compression"
+	optionNameAPIMaxUploadSize  = "api-max-upload-size"
+	optionNameAPIMaxImportSize  = "api-max-import-size"
+	optionNameAPIUploadTimeout  = "api-upload-read-timeout"
+	optionNameAPIWriteTimeout   = "api-write-timeout"
+	optionNameAPIBodySampleRate = "api-body-sample-rate"
+	optionNameAPIBodyMaxSize    = "api-body-max-size"
+	optionNameAPIPrefetchChunks = "api-prefetch-chunks"
+	optionNameRecoveryResponder = "recovery-responder"
+	optionNameDBVerify          = "db-verify"
+	optionNameDBPinnedCapacity  = "db-pinned-capacity"
+	optionNameDBUploadCapacity  = "db-upload-capacity"
+	optionNamePullSyncDepth     = "pullsync-within-depth"
+	optionNamePullSyncCapacity  = "pullsync-capacity"
+	optionNameShutdownDrain     = "shutdown-drain-period"
+	optionNameVerbosity         = "verbosity"
+	optionNameLogFormat         = "log-format"
+	optionNameLogLevels         = "log-levels"
+	optionCORSAllowedOrigins    = "cors-allowed-origins"
+	optionNameGatewayMode       = "gateway-mode"
+	optionNameBootnodes         = "bootnode"
+	optionNamePaymentThreshold  = "payment-threshold"
+	optionNamePaymentTolerance  = "payment-tolerance"
+	optionNamePaymentEarly      = "payment-early"
+	optionNameRetrievalAttempts = "retrieval-max-attempts"
+	optionNameRetrievalBackoff  = "retrieval-retry-backoff"
+	optionNameRetrievalCache    = "retrieval-cache-probability"
+	optionNameRetrievalCutoff   = "retrieval-cache-proximity"
+	optionNameLatencyInterval   = "latency-probe-interval"
+	optionNameAddressbookExpiry = "addressbook-expiry"
+	optionNameDialPreference    = "p2p-dial-preference"
+	optionNameAdvertisePolicy   = "p2p-advertise-policy"
+	optionNameConnectionEvents  = "p2p-connection-events"
+	optionNamePriorityMaxWait   = "p2p-priority-max-wait"
+	optionNamePriorityWeight    = "p2p-priority-weight"
+	optionNamePeerRateLimit     = "p2p-peer-rate-limit"
+	optionNameUserAgent         = "p2p-user-agent"
+	optionNameFeatures          = "features"
+	optionNameGatewayRateLimit  = "gateway-rate-limit"
+	optionNameGatewayRateBurst  = "gateway-rate-burst"
+	optionNameGatewayMaxUpload  = "gateway-max-upload-size"
+	optionNameGatewayEndpoints  = "gateway-allowed-endpoints"
+	optionNameGatewayAPIKeys    = "gateway-api-keys"
+	optionNameBootnodeResolve   = "bootnode-resolve-interval"
+	optionNameNetworkName       = "network-name"
+	optionNameColdEndpoint      = "cold-store-endpoint"
+	optionNameColdRegion        = "cold-store-region"
+	optionNameColdBucket        = "cold-store-bucket"
+	optionNameColdPrefix        = "cold-store-prefix"
+	optionNameColdAccessKey     = "cold-store-access-key"
+	optionNameColdSecretKey     = "cold-store-secret-key"
+	optionNameColdMinProximity  = "cold-store-min-proximity"
+	optionNameColdMaxIdle       = "cold-store-max-idle"
+	optionNameColdMaxAge        = "cold-store-max-age"
+	optionNameSwapAlertLimit    = "swap-settlement-alert-limit"
+	optionNameSwapAlertWindow   = "swap-settlement-alert-window"
+	optionNameSwapIssueCheques  = "swap-issue-cheques"
+	optionNameSwapMaxCheque     = "swap-max-cheque"
+	optionNameSwapMaxDaily      = "swap-max-daily-issuance"
+
+	optionNameClefSignerEnable          = "clef-signer-enable"
+	optionNameClefSignerEndpoint        = "clef-signer-endpoint"
+	optionNameClefSignerEthereumAddress = "clef-signer-ethereum-address"
 )
 
 func (c *command) initStartCmd() (err error) {
-	cmd := &cobra.Command{
-		Use:   "",
-		Short: "",
+	// the node is started when no client command is given
+	c.root.RunE = func(cmd *cobra.Command, args []string) error {
+		if err := c.config.BindPFlags(cmd.Flags()); err != nil {
+			return err
+		}
+		return c.start(cmd)
 	}
-	c.start(cmd)
+	c.root.Flags().Bool(optionNameTelemetry, false, "send anonymous usage reports, see the /telemetry debug api endpoint for the reported data")
+	c.root.Flags().String(optionNameTelemetryEndpoint, "", "endpoint to send the anonymous usage reports to")
+	c.root.Flags().Bool(optionNameAPICompression, false, "compress downloaded text content with gzip if accepted by the client")
+	c.root.Flags().Int64(optionNameAPIMaxUploadSize, 0, "maximal size of the data uploaded to the api in bytes, 0 disables the limit")
+	c.root.Flags().Int64(optionNameAPIMaxImportSize, 0, "maximal size of the pin archive imported through the api in bytes, 0 disables the limit")
+	c.root.Flags().Duration(optionNameAPIUploadTimeout, time.Minute, "maximal time to wait for the api client to send the next part of an upload, 0 disables the timeout")
+	c.root.Flags().Duration(optionNameAPIWriteTimeout, 4*time.Second, "maximal time to wait for the api client to receive the next part of a download or a websocket message")
+	c.root.Flags().Float64(optionNameAPIBodySampleRate, 0, "fraction of the api requests with the request and response bodies logged in the access log, 0 disables the body logging")
+	c.root.Flags().Int(optionNameAPIBodyMaxSize, 4096, "maximal size of a request or response body logged in the api access log in bytes")
+	c.root.Flags().Int(optionNameAPIPrefetchChunks, 8, "number of the index document chunks prefetched in the background on a collection root request, 0 disables the prefetch")
+	c.root.Flags().Bool(optionNameRecoveryResponder, true, "act as a pinner node and repair the locally stored chunks on the recovery requests of other nodes")
+	c.root.Flags().Bool(optionNameDBVerify, false, "verify the integrity of the locally stored chunks on start and retrieve the corrupted chunks again")
+	c.root.Flags().Uint64(optionNameDBPinnedCapacity, 0, "maximal number of the pinned chunks in the local store, 0 disables the limit")
+	c.root.Flags().Uint64(optionNameDBUploadCapacity, 0, "maximal number of the uploaded chunks waiting to be synced in the local store, 0 disables the limit")
+	c.root.Flags().Bool(optionNamePullSyncDepth, false, "pull only the chunks within the neighborhood depth from the peers")
+	c.root.Flags().Uint64(optionNamePullSyncCapacity, 0, "stop pulling the chunks from the peers while the local store holds this number of the cached chunks, 0 disables the limit")
+	c.root.Flags().Duration(optionNameShutdownDrain, 5*time.Second, "time to wait on shutdown for the api requests in flight to finish while the new requests are rejected")
+	c.root.Flags().String(optionNameVerbosity, "info", "log verbosity level 0=silent, 1=error, 2=warn, 3=info, 4=debug, 5=trace")
+	c.root.Flags().String(optionNameLogFormat, "text", "log output format, text or json")
+	c.root.Flags().StringSlice(optionNameLogLevels, nil, "log verbosity levels of the subsystems overriding the verbosity, for example kademlia=debug,api=warn")
+	c.root.Flags().StringSlice(optionCORSAllowedOrigins, []string{"*"}, "origins with CORS headers enabled")
+	c.root.Flags().Bool(optionNameGatewayMode, true, "disable a set of sensitive features in the api")
+	c.root.Flags().StringSlice(optionNameBootnodes, []string{"/ip4/54.252.195.103/tcp/11634/p2p/4c3948a814c430d3be4768e96a6c461f9223c0a0c47ac531df2c3e117639e28b3dc07ebfa36f5c2e718520e3b23561ba3cdf4de5f51b925eb9f139b4c80b1656"}, "initial nodes to connect to, the /dnsaddr/ entries are resolved from the DNS TXT records")
+	c.root.Flags().String(optionNamePaymentThreshold, "10000000000000", "threshold in IFIE where you expect to get paid from your peers")
+	c.root.Flags().String(optionNamePaymentTolerance, "50000000000000", "excess debt above payment threshold in IFIE where you disconnect from your peer")
+	c.root.Flags().String(optionNamePaymentEarly, "1000000000000", "amount in IFIE below the peers payment threshold when we initiate settlement")
+	c.root.Flags().Int(optionNameRetrievalAttempts, 5, "maximal number of peers requested for a chunk before the retrieval fails")
+	c.root.Flags().Duration(optionNameRetrievalBackoff, 250*time.Millisecond, "base delay before requesting the next peer after a failed chunk retrieval, doubled with every failure")
+	c.root.Flags().Float64(optionNameRetrievalCache, 0, "probability of caching a chunk retrieved for a request forwarded by this node, between 0 and 1")
+	c.root.Flags().Uint8(optionNameRetrievalCutoff, 0, "minimal proximity of a chunk retrieved for a forwarded request to this node for the chunk to be cached")
+	c.root.Flags().Duration(optionNameLatencyInterval, time.Minute, "time between the round-trip-time measurements of the connected peers, used to prefer the low latency peers in the chunk retrieval")
+	c.root.Flags().Bool(optionNameClefSignerEnable, false, "sign with the node key held by an external clef signer, which may also use a hardware wallet, instead of the keystore")
+	c.root.Flags().String(optionNameClefSignerEndpoint, "", "clef signer endpoint, the default clef ipc path if not set")
+	c.root.Flags().String(optionNameClefSignerEthereumAddress, "", "ethereum address of the clef account to use, the first account if not set")
+	c.root.Flags().String(optionNamePasswordFile, "", "file to read the password for the keys from instead of the device key, for example a systemd credential or /dev/fd/3")
+	c.root.Flags().Duration(optionNameAddressbookExpiry, 7*24*time.Hour, "period after which the peers neither connected nor learned from other peers are removed from the addressbook, 0 disables the removal")
+	c.root.Flags().String(optionNameDialPreference, "", "experimental: dial the IPv4 and IPv6 underlays of the peers in parallel, starting with the preferred address family, ipv4 or ipv6")
+	c.root.Flags().String(optionNameAdvertisePolicy, "private-allowed", "underlay addresses advertised to the peers, public-only replaces the private addresses with the public ones, private-allowed advertises the addresses observed by the peers")
+	c.root.Flags().Int(optionNameConnectionEvents, 1000, "number of the recent peer connection events kept for the debug api")
+	c.root.Flags().Duration(optionNamePriorityMaxWait, 2*time.Second, "maximal time the pull syncing streams of the peers wait for their retrieval and push syncing streams to be handled")
+	c.root.Flags().Int(optionNamePriorityWeight, 0, "number of the retrieval and push syncing streams of the peers handled for every waiting pull syncing stream, 0 lets the pull syncing streams wait for all of them")
+	c.root.Flags().Int64(optionNamePeerRateLimit, 0, "maximal number of bytes per second transferred on the streams with every peer, in both directions together, 0 disables the limit")
+	c.root.Flags().String(optionNameUserAgent, "", "user agent reported to the peers in the libp2p identify, voyager and its version if not set")
+	c.root.Flags().StringSlice(optionNameFeatures, nil, "experimental features to enable, or to disable with =false, for example retrieval-racing,erasure-coding=false, overridden by the changes made with the debug api")
+	c.root.Flags().Float64(optionNameGatewayRateLimit, 0, "maximal number of api requests per second per client ip address in the gateway mode, 0 disables the limit")
+	c.root.Flags().Int(optionNameGatewayRateBurst, 0, "number of api requests over the gateway rate limit allowed at once per client ip address, the rate limit if not set")
+	c.root.Flags().Int64(optionNameGatewayMaxUpload, 0, "maximal size of the data uploaded to the api in bytes in the gateway mode, 0 disables the limit")
+	c.root.Flags().StringSlice(optionNameGatewayEndpoints, nil, "api endpoints allowed in the gateway mode together with the endpoints under them, for example /bytes,/chunks, all if not set")
+	c.root.Flags().Bool(optionNameGatewayAPIKeys, false, "require the api keys managed with the debug api, with their daily quotas, in the gateway mode")
+	c.root.Flags().Duration(optionNameBootnodeResolve, 10*time.Minute, "time between the resolutions of the dnsaddr bootnodes, the last resolved addresses are used if the resolution fails")
+	c.root.Flags().String(optionNameNetworkName, "mainnet", "name of the network reported to the peers together with the node version in the libp2p identify user agent")
+	c.root.Flags().String(optionNameColdEndpoint, "", "endpoint of the s3 compatible object storage the chunks removed by the garbage collection are offloaded to and restored from on access, for example https://s3.amazonaws.com, the offload is disabled if not set")
+	c.root.Flags().String(optionNameColdRegion, "us-east-1", "region of the cold store bucket")
+	c.root.Flags().String(optionNameColdBucket, "", "bucket of the cold store the chunks are offloaded to")
+	c.root.Flags().String(optionNameColdPrefix, "", "prefix of the object keys of the chunks offloaded to the cold store")
+	c.root.Flags().String(optionNameColdAccessKey, "", "access key of the cold store")
+	c.root.Flags().String(optionNameColdSecretKey, "", "secret key of the cold store")
+	c.root.Flags().Uint8(optionNameColdMinProximity, 0, "minimal proximity of a chunk removed by the garbage collection to this node for the chunk to be offloaded to the cold store")
+	c.root.Flags().Duration(optionNameColdMaxIdle, 0, "maximal time since a chunk removed by the garbage collection was last accessed for the chunk to be offloaded to the cold store, 0 disables the limit")
+	c.root.Flags().Duration(optionNameColdMaxAge, 0, "maximal time a chunk is kept in the cold store since it was offloaded, 0 disables the eviction")
+	c.root.Flags().Int(optionNameSwapAlertLimit, 60, "number of the settlements with a peer within the alert window over which an anomalous settlement rate is alerted, 0 disables the alerts")
+	c.root.Flags().Duration(optionNameSwapAlertWindow, time.Hour, "period over which the settlements with a peer are counted for the anomalous settlement rate alerts")
+	c.root.Flags().Bool(optionNameSwapIssueCheques, false, "issue the cheques from the chequebook deployed with the deploy command")
+	c.root.Flags().String(optionNameSwapMaxCheque, "", "maximal amount of a single issued cheque in token base units, no limit if not set")
+	c.root.Flags().String(optionNameSwapMaxDaily, "", "maximal amount issued in the cheques to a peer during a day in UTC in token base units, no limit if not set")
+	c.root.Flags().String(optionNameSwapEndpoint, swapEndpoint, "smart chain blockchain endpoint, the same as given to the deploy command")
+	c.root.Flags().String(optionNameSwapFactoryAddress, swapFactoryAddress, "chequebook factory address, the same as given to the deploy command")
 	// c.setAllFlags(cmd)
-	// c.root.AddCommand(cmd)
 	return nil
 }
 
 func (c *command) start(cmd *cobra.Command) (err error) {
 
-	var logger logging.Logger
-	switch v := strings.ToLower("info"); v {
-	case "0", "silent":
-		logger = logging.New(cmd.OutOrStdout(), 0)
-	case "1", "error":
-		logger = logging.New(cmd.OutOrStdout(), logrus.ErrorLevel)
-	case "2", "warn":
-		logger = logging.New(cmd.OutOrStdout(), logrus.WarnLevel)
-	case "3", "info":
-		logger = logging.New(cmd.OutOrStdout(), logrus.InfoLevel)
-	case "4", "debug":
-		logger = logging.New(cmd.OutOrStdout(), logrus.DebugLevel)
-	case "5", "trace":
-		logger = logging.New(cmd.OutOrStdout(), logrus.TraceLevel)
+	runtimeOptions, err := c.runtimeOptions()
+	if err != nil {
+		return err
+	}
+	var logOptions []logging.Option
+	switch f := c.config.GetString(optionNameLogFormat); f {
+	case "text":
+	case "json":
+		logOptions = append(logOptions, logging.WithJSONFormat())
 	default:
-		return fmt.Errorf("unknown verbosity level %q", v)
+		return fmt.Errorf("unknown log format %q", f)
+	}
+	logger := logging.New(cmd.OutOrStdout(), runtimeOptions.LogLevel, logOptions...)
+	for name, level := range runtimeOptions.SubsystemLogLevels {
+		logger.SetSubsystemLevel(name, level)
 	}
 
 	isWindowsService, err := isWindowsService()
@@ -120,6 +264,77 @@ func (c *command) start(cmd *cobra.Command) (err error) {
 	logger.Infof("version: %v", voyager.Version)
 
 	newOption := getNewOption("", logger, resolverCfgs)
+	newOption.TelemetryEnabled = c.config.GetBool(optionNameTelemetry)
+	newOption.TelemetryEndpoint = c.config.GetString(optionNameTelemetryEndpoint)
+	newOption.APICompression = c.config.GetBool(optionNameAPICompression)
+	newOption.APIMaxUploadSize = c.config.GetInt64(optionNameAPIMaxUploadSize)
+	newOption.APIMaxImportSize = c.config.GetInt64(optionNameAPIMaxImportSize)
+	newOption.APIUploadReadTimeout = c.config.GetDuration(optionNameAPIUploadTimeout)
+	newOption.APIWriteTimeout = c.config.GetDuration(optionNameAPIWriteTimeout)
+	newOption.APIBodySampleRate = c.config.GetFloat64(optionNameAPIBodySampleRate)
+	newOption.APIBodyMaxSize = c.config.GetInt(optionNameAPIBodyMaxSize)
+	newOption.APIPrefetchChunks = c.config.GetInt(optionNameAPIPrefetchChunks)
+	newOption.RecoveryResponderEnabled = c.config.GetBool(optionNameRecoveryResponder)
+	newOption.DBVerify = c.config.GetBool(optionNameDBVerify)
+	newOption.DBPinnedCapacity = c.config.GetUint64(optionNameDBPinnedCapacity)
+	newOption.DBUploadCapacity = c.config.GetUint64(optionNameDBUploadCapacity)
+	newOption.PullSyncWithinDepth = c.config.GetBool(optionNamePullSyncDepth)
+	newOption.PullSyncCapacity = c.config.GetUint64(optionNamePullSyncCapacity)
+	newOption.ShutdownDrainPeriod = c.config.GetDuration(optionNameShutdownDrain)
+	newOption.CORSAllowedOrigins = runtimeOptions.CORSAllowedOrigins
+	newOption.GatewayMode = runtimeOptions.GatewayMode
+	newOption.Bootnodes = runtimeOptions.Bootnodes
+	newOption.PaymentThreshold = runtimeOptions.PaymentThreshold
+	newOption.PaymentTolerance = runtimeOptions.PaymentTolerance
+	newOption.PaymentEarly = runtimeOptions.PaymentEarly
+	newOption.ReloadConfig = c.reloadConfig
+	newOption.RetrievalMaxAttempts = c.config.GetInt(optionNameRetrievalAttempts)
+	newOption.RetrievalRetryBackoff = c.config.GetDuration(optionNameRetrievalBackoff)
+	newOption.RetrievalCacheProbability = c.config.GetFloat64(optionNameRetrievalCache)
+	newOption.RetrievalCacheProximity = uint8(c.config.GetUint(optionNameRetrievalCutoff))
+	newOption.LatencyProbeInterval = c.config.GetDuration(optionNameLatencyInterval)
+	newOption.AddressbookExpiry = c.config.GetDuration(optionNameAddressbookExpiry)
+	newOption.P2PDialPreference = c.config.GetString(optionNameDialPreference)
+	newOption.P2PAdvertisePolicy = c.config.GetString(optionNameAdvertisePolicy)
+	newOption.P2PConnectionEvents = c.config.GetInt(optionNameConnectionEvents)
+	newOption.P2PStreamPriorityMaxWait = c.config.GetDuration(optionNamePriorityMaxWait)
+	newOption.P2PStreamPriorityWeight = c.config.GetInt(optionNamePriorityWeight)
+	newOption.P2PPeerRateLimit = c.config.GetInt64(optionNamePeerRateLimit)
+	newOption.P2PUserAgent = c.config.GetString(optionNameUserAgent)
+	newOption.Features = c.config.GetStringSlice(optionNameFeatures)
+	newOption.GatewayRateLimit = c.config.GetFloat64(optionNameGatewayRateLimit)
+	newOption.GatewayRateBurst = c.config.GetInt(optionNameGatewayRateBurst)
+	newOption.GatewayMaxUploadSize = c.config.GetInt64(optionNameGatewayMaxUpload)
+	newOption.GatewayAllowedEndpoints = c.config.GetStringSlice(optionNameGatewayEndpoints)
+	newOption.GatewayAPIKeys = c.config.GetBool(optionNameGatewayAPIKeys)
+	newOption.BootnodeResolveInterval = c.config.GetDuration(optionNameBootnodeResolve)
+	newOption.NetworkName = c.config.GetString(optionNameNetworkName)
+	newOption.ColdStoreEndpoint = c.config.GetString(optionNameColdEndpoint)
+	newOption.ColdStoreRegion = c.config.GetString(optionNameColdRegion)
+	newOption.ColdStoreBucket = c.config.GetString(optionNameColdBucket)
+	newOption.ColdStorePrefix = c.config.GetString(optionNameColdPrefix)
+	newOption.ColdStoreAccessKey = c.config.GetString(optionNameColdAccessKey)
+	newOption.ColdStoreSecretKey = c.config.GetString(optionNameColdSecretKey)
+	newOption.ColdStoreMinProximity = uint8(c.config.GetUint(optionNameColdMinProximity))
+	newOption.ColdStoreMaxIdle = c.config.GetDuration(optionNameColdMaxIdle)
+	newOption.ColdStoreMaxAge = c.config.GetDuration(optionNameColdMaxAge)
+	newOption.SwapSettlementAlertLimit = c.config.GetInt(optionNameSwapAlertLimit)
+	newOption.SwapSettlementAlertWindow = c.config.GetDuration(optionNameSwapAlertWindow)
+	newOption.SwapIssueCheques = c.config.GetBool(optionNameSwapIssueCheques)
+	newOption.SwapMaxCheque = c.config.GetString(optionNameSwapMaxCheque)
+	newOption.SwapMaxDailyIssuance = c.config.GetString(optionNameSwapMaxDaily)
+	newOption.SwapEndpoint = c.config.GetString(optionNameSwapEndpoint)
+	newOption.SwapFactoryAddress = c.config.GetString(optionNameSwapFactoryAddress)
+	newOption.ClefSignerEnable = c.config.GetBool(optionNameClefSignerEnable)
+	newOption.ClefSignerEndpoint = c.config.GetString(optionNameClefSignerEndpoint)
+	newOption.ClefSignerEthereumAddress = c.config.GetString(optionNameClefSignerEthereumAddress)
+	if f := c.config.GetString(optionNamePasswordFile); f != "" {
+		password, err := readPasswordFile(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", optionNamePasswordFile, err)
+		}
+		newOption.Password = password
+	}
 
 	// fmt.Println("调试专用,取消DeviceHardWareCheck")
 	passed, msg := cpc.DeviceHardWareCheck()
@@ -128,10 +343,20 @@ func (c *command) start(cmd *cobra.Command) (err error) {
 		return nil
 	}
 
-	signerConfig, err := c.configureSigner(logger, *newOption)
+	// the overlay address is derived with the nonce mined by the init
+	// command, if the node is initialised in a target neighborhood
+	overlayNonce, err := readOverlayNonce(logger, newOption.DataDir)
 	if err != nil {
 		return err
 	}
+	newOption.OverlayNonce = overlayNonce
+
+	signerConfig, keys, err := c.configureSigner(logger, *newOption)
+	if err != nil {
+		return err
+	}
+	// the keys are unlocked, the node does not need the password anymore
+	newOption.Password = ""
 
 	b, _, ownerAddress, err := node.NewVoyager(
 		newOption.Addr,
@@ -145,8 +370,7 @@ func (c *command) start(cmd *cobra.Command) (err error) {
 
 		newOption.NetworkID,
 		logger,
-		signerConfig.Libp2pPrivateKey,
-		signerConfig.PssPrivateKey,
+		keys,
 		*newOption,
 		&flg)
 	if err != nil {
@@ -171,13 +395,26 @@ func (c *command) start(cmd *cobra.Command) (err error) {
 	interruptChannel := make(chan os.Signal, 1)
 	signal.Notify(interruptChannel, syscall.SIGINT, syscall.SIGTERM)
 
+	// Reload the runtime configuration on hangup signals.
+	reloadChannel := make(chan os.Signal, 1)
+	signal.Notify(reloadChannel, syscall.SIGHUP)
+
 	p := &program{
 		start: func() {
 			// Block main goroutine until it is interrupted
-			sig := <-interruptChannel
-
-			logger.Debugf("received signal: %v", sig)
-			logger.Info("shutting down")
+			for {
+				select {
+				case sig := <-reloadChannel:
+					logger.Debugf("received signal: %v", sig)
+					if err := b.Reload(context.Background()); err != nil {
+						logger.Errorf("reload configuration: %v", err)
+					}
+				case sig := <-interruptChannel:
+					logger.Debugf("received signal: %v", sig)
+					logger.Info("shutting down")
+					return
+				}
+			}
 		},
 		stop: func() {
 			// Shutdown
@@ -185,7 +422,9 @@ func (c *command) start(cmd *cobra.Command) (err error) {
 			go func() {
 				defer close(done)
 
-				ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+				// the drain period is not taken from the time of stopping
+				// the rest of the components
+				ctx, cancel := context.WithTimeout(context.Background(), newOption.ShutdownDrainPeriod+15*time.Second)
 				defer cancel()
 
 				if err := b.Shutdown(ctx); err != nil {
@@ -225,6 +464,38 @@ func (c *command) start(cmd *cobra.Command) (err error) {
 	return nil
 }
 
+// runtimeOptions returns the node options which can be changed without
+// restarting the node.
+func (c *command) runtimeOptions() (o node.RuntimeOptions, err error) {
+	o.LogLevel, err = logging.ParseVerbosity(c.config.GetString(optionNameVerbosity))
+	if err != nil {
+		return o, err
+	}
+	o.SubsystemLogLevels, err = logging.ParseSubsystemLevels(c.config.GetStringSlice(optionNameLogLevels))
+	if err != nil {
+		return o, err
+	}
+	o.CORSAllowedOrigins = c.config.GetStringSlice(optionCORSAllowedOrigins)
+	o.GatewayMode = c.config.GetBool(optionNameGatewayMode)
+	o.Bootnodes = c.config.GetStringSlice(optionNameBootnodes)
+	o.PaymentThreshold = c.config.GetString(optionNamePaymentThreshold)
+	o.PaymentTolerance = c.config.GetString(optionNamePaymentTolerance)
+	o.PaymentEarly = c.config.GetString(optionNamePaymentEarly)
+	return o, nil
+}
+
+// reloadConfig reads the configuration file again and returns the runtime
+// options from it.
+func (c *command) reloadConfig() (node.RuntimeOptions, error) {
+	if err := c.config.ReadInConfig(); err != nil {
+		var e viper.ConfigFileNotFoundError
+		if !errors.As(err, &e) {
+			return node.RuntimeOptions{}, err
+		}
+	}
+	return c.runtimeOptions()
+}
+
 type program struct {
 	start func()
 	stop  func()
@@ -257,9 +528,11 @@ func waitForClef(logger logging.Logger, maxRetries uint64, endpoint string) (ext
 	}
 }
 
-func (c *command) configureSigner(logger logging.Logger, option node.Options) (config *cpc.SignerConfig, err error) {
+// configureSigner returns the signer of the node key and the keys of the
+// keystore unlocked for the node.
+func (c *command) configureSigner(logger logging.Logger, option node.Options) (config *cpc.SignerConfig, keys keystore.Keys, err error) {
 	var (
-		keystore keystore.Service
+		ks keystore.Service
 		// signer   crypto.Signer
 		// address           infinity.Address
 		password string
@@ -268,7 +541,7 @@ func (c *command) configureSigner(logger logging.Logger, option node.Options) (c
 		signerInfo        *cpc.SignerInfo
 	)
 	// conf := cpc.GetConfig()
-	keystore = filekeystore.New(filepath.Join("./", "keys"))
+	ks = filekeystore.New(filepath.Join("./", "keys"))
 	if p := option.Password; p != "" {
 		// 设备码不为空
 		password = p
@@ -276,14 +549,14 @@ func (c *command) configureSigner(logger logging.Logger, option node.Options) (c
 		// if libp2p key exists we can assume all required keys exist
 		// so prompt for a password to unlock them
 		// otherwise prompt for new password with confirmation to create them
-		exists, err := keystore.Exists("libp2p")
+		exists, err := ks.Exists("libp2p")
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		if exists {
 			password, err = terminalPromptPassword(c.passwordReader, "Password")
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		}
 	}
@@ -291,16 +564,16 @@ func (c *command) configureSigner(logger logging.Logger, option node.Options) (c
 	deviceInfo, err := cpc.GetDeviceInfoFromSrv()
 	if err != nil {
 		// fmt.Println(err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	if fileExists("./keys") {
 		// 文件存在，已生成主网地址，比对本地与服务器存储设备的信息
 
-		signerInfo, err = GetSignerInfo(password, keystore, option.NetworkID)
+		signerInfo, err = c.signerInfo(logger, password, ks, option)
 		if err != nil {
 			fmt.Println("Err:", err)
-			return nil, err
+			return nil, nil, err
 		}
 		overlayEthAddress = signerInfo.OverlayEthAddress.String()
 		// 对主网进行地址比
@@ -310,14 +583,14 @@ func (c *command) configureSigner(logger logging.Logger, option node.Options) (c
 			fmt.Println("Overlay EthAddress:", overlayEthAddress)
 			fmt.Println("Registered address:", deviceInfo.Addr)
 			fmt.Println("The OwnerAddress does not match")
-			return nil, errors.New("The OwnerAddress does not match")
+			return nil, nil, errors.New("The OwnerAddress does not match")
 		}
 	} else {
 		// 文件不存在，主网地址还未生成，用户输入测试网地址,并进行绑定
 
-		signerInfo, err = GetSignerInfo(password, keystore, option.NetworkID)
+		signerInfo, err = c.signerInfo(logger, password, ks, option)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		overlayEthAddress = signerInfo.OverlayEthAddress.String()
 		deviceInfo.TestAddr = overlayEthAddress
@@ -325,37 +598,19 @@ func (c *command) configureSigner(logger logging.Logger, option node.Options) (c
 
 	logger.Infof("Smart Chain public key %x", crypto.EncodeSecp256k1PublicKey(signerInfo.PublicKey))
 
-	libp2pPrivateKey, _, err := keystore.Key("libp2p", password)
-	if err != nil {
-		return nil, fmt.Errorf("libp2p key: %w", err)
-	}
-
-	pssPrivateKey, created, err := keystore.Key("pss", password)
-	if err != nil {
-		return nil, fmt.Errorf("pss key: %w", err)
-	}
-	if created {
-		logger.Debugf("new pss key created")
-	} else {
-		logger.Debugf("using existing pss key")
-	}
-
-	logger.Infof("pss public key %x", crypto.EncodeSecp256k1PublicKey(&pssPrivateKey.PublicKey))
-
 	logger.Infof("using Smart Chain address %s", overlayEthAddress)
 
+	// the libp2p and pss keys are decrypted by the node when they are used
 	return &cpc.SignerConfig{
-		Signer:           signerInfo.Signer,
-		Address:          signerInfo.Address,
-		PublicKey:        signerInfo.PublicKey,
-		Libp2pPrivateKey: libp2pPrivateKey,
-		PssPrivateKey:    pssPrivateKey,
-		DeviceInfo:       deviceInfo,
+		Signer:     signerInfo.Signer,
+		Address:    signerInfo.Address,
+		PublicKey:  signerInfo.PublicKey,
+		DeviceInfo: deviceInfo,
 		// TestnetAddr:      deviceInfo.TestAddr,
 		// Token:            deviceInfo.Token,
 		// TureMac:          deviceInfo.DeviceMac,
 		OwnerAddress: overlayEthAddress,
-	}, nil
+	}, keystore.Unlock(ks, password), nil
 
 }
 
@@ -389,15 +644,15 @@ func getNewOption(debugAPIAddr string, logger logging.Logger, resolverCfgs []mul
 		ResolverConnectionCfgs:    resolverCfgs,
 		GatewayMode:               true,
 		BootnodeMode:              true,
-		SwapEndpoint:              "http://52.77.248.72:18545",
-		SwapFactoryAddress:        "0x7edFFD0a5422d4A9241DB77633CAfba8b578bE75",
+		SwapEndpoint:              swapEndpoint,
+		SwapFactoryAddress:        swapFactoryAddress,
 		SwapInitialDeposit:        "0",
 		SwapEnable:                true,
 		Password:                  conf.IdKey,
 		ClefSignerEnable:          false,
 		ClefSignerEndpoint:        "",
 		ClefSignerEthereumAddress: "",
-		NetworkID:                 16688,
+		NetworkID:                 networkID,
 		LogicalCores:              4,
 		MHZ:                       1.8,
 		TotalFree:                 500,
@@ -417,7 +672,66 @@ func fileExists(filename string) bool {
 	return !os.IsNotExist(err)
 }
 
-func GetSignerInfo(password string, keystore keystore.Service, NetworkID uint64) (*cpc.SignerInfo, error) {
+// signerInfo returns the signer of the node key, held by the clef signer if it
+// is enabled, so that the key never leaves it, or by the keystore otherwise.
+func (c *command) signerInfo(logger logging.Logger, password string, keystore keystore.Service, option node.Options) (*cpc.SignerInfo, error) {
+	if !option.ClefSignerEnable {
+		return GetSignerInfo(password, keystore, option.NetworkID, option.OverlayNonce)
+	}
+
+	endpoint := option.ClefSignerEndpoint
+	if endpoint == "" {
+		var err error
+		endpoint, err = clef.DefaultIpcPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	externalSigner, err := waitForClef(logger, 5, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("clef signer: %w", err)
+	}
+	clientRPC, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("clef signer: %w", err)
+	}
+
+	var ethAddress *common.Address
+	if a := option.ClefSignerEthereumAddress; a != "" {
+		if !common.IsHexAddress(a) {
+			return nil, fmt.Errorf("clef signer: invalid ethereum address %s", a)
+		}
+		addr := common.HexToAddress(a)
+		ethAddress = &addr
+	}
+	signer, err := clef.NewSigner(externalSigner, clientRPC, crypto.Recover, ethAddress)
+	if err != nil {
+		return nil, fmt.Errorf("clef signer: %w", err)
+	}
+
+	publicKey, err := signer.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	address, err := crypto.NewOverlayAddress(*publicKey, option.NetworkID, option.OverlayNonce)
+	if err != nil {
+		return nil, err
+	}
+	overlayEthAddress, err := signer.EthereumAddress()
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("using clef signer account %s", overlayEthAddress)
+
+	return &cpc.SignerInfo{
+		Signer:            signer,
+		PublicKey:         publicKey,
+		Address:           address,
+		OverlayEthAddress: overlayEthAddress,
+	}, nil
+}
+
+func GetSignerInfo(password string, keystore keystore.Service, NetworkID uint64, overlayNonce []byte) (*cpc.SignerInfo, error) {
 
 	// infinityPrivateKey, _, err := keystore.Key("smartchain", strings.ToLower(password))
 	infinityPrivateKey, _, err := keystore.Key("smartchain", password)
@@ -426,7 +740,7 @@ func GetSignerInfo(password string, keystore keystore.Service, NetworkID uint64)
 	}
 	signer := crypto.NewDefaultSigner(infinityPrivateKey)
 	publicKey := &infinityPrivateKey.PublicKey
-	address, err := crypto.NewOverlayAddress(*publicKey, NetworkID)
+	address, err := crypto.NewOverlayAddress(*publicKey, NetworkID, overlayNonce)
 	if err != nil {
 		return nil, err
 	}
@@ -443,3 +757,23 @@ func GetSignerInfo(password string, keystore keystore.Service, NetworkID uint64)
 		OverlayEthAddress:  overlayEthAddress,
 	}, nil
 }
+
+// readOverlayNonce returns the overlay nonce stored in the state store of the
+// node in the data directory.
+func readOverlayNonce(logger logging.Logger, dataDir string) (nonce []byte, err error) {
+	stateStore, err := node.InitStateStore(logger, dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("state store: %w", err)
+	}
+	defer func() {
+		if e := stateStore.Close(); e != nil && err == nil {
+			err = fmt.Errorf("close state store: %w", e)
+		}
+	}()
+
+	nonce, err = node.OverlayNonce(stateStore)
+	if err != nil {
+		return nil, fmt.Errorf("overlay nonce: %w", err)
+	}
+	return nonce, nil
+}
@@ -97,3 +97,22 @@ func (l *windowsEventLogger) WriterLevel(level logrus.Level) *io.PipeWriter {
 func (l *windowsEventLogger) NewEntry() *logrus.Entry {
 	return l.logger.NewEntry()
 }
+
+func (l *windowsEventLogger) SetLevel(level logrus.Level) {
+	l.logger.SetLevel(level)
+}
+
+func (l *windowsEventLogger) Subsystem(name string) logging.Logger {
+	return &windowsEventLogger{
+		logger: l.logger.Subsystem(name),
+		winlog: l.winlog,
+	}
+}
+
+func (l *windowsEventLogger) SetSubsystemLevel(name string, level logrus.Level) {
+	l.logger.SetSubsystemLevel(name, level)
+}
+
+func (l *windowsEventLogger) Levels() (logrus.Level, map[string]logrus.Level) {
+	return l.logger.Levels()
+}
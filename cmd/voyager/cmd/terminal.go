@@ -38,6 +38,10 @@ func terminalPromptPassword(r passwordReader, title string) (password string, er
 func terminalPromptCreatePassword(r passwordReader) (password string, err error) {
 	// cmd.Println("Voyager node is booting up for the first time. Please provide a new password.")
 	fmt.Println("Voyager node is booting up for the first time. Please provide a new password.")
+	return terminalPromptNewPassword(r)
+}
+
+func terminalPromptNewPassword(r passwordReader) (password string, err error) {
 	p1, err := terminalPromptPassword(r, "Password")
 	if err != nil {
 		return "", err
@@ -0,0 +1,25 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"github.com/yanhuangpai/voyager"
+)
+
+func (c *command) initVersionCmd() {
+	c.root.AddCommand(&cobra.Command{
+		Use:   "version",
+		Short: "Print version and build information",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Printf("version: %s\n", voyager.Version)
+			cmd.Printf("go: %s\n", runtime.Version())
+			cmd.Printf("platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+		},
+	})
+}
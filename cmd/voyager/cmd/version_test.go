@@ -0,0 +1,29 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yanhuangpai/voyager"
+	"github.com/yanhuangpai/voyager/cmd/voyager/cmd"
+)
+
+func TestVersionCmd(t *testing.T) {
+	var out bytes.Buffer
+	c := newCommand(t,
+		cmd.WithArgs("version"),
+		cmd.WithOutput(&out),
+	)
+	if err := c.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "version: " + voyager.Version + "\n"; !strings.HasPrefix(out.String(), want) {
+		t.Errorf("got output %q, want prefix %q", out.String(), want)
+	}
+}
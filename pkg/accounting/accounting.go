@@ -53,13 +53,32 @@ type Interface interface {
 	CompensatedBalance(peer infinity.Address) (*big.Int, error)
 	// CompensatedBalances returns the compensated balances for all known peers.
 	CompensatedBalances() (map[string]*big.Int, error)
+	// Served records a chunk of the given size served to the peer.
+	Served(peer infinity.Address, size int)
+	// Requested records a chunk of the given size obtained from the peer.
+	Requested(peer infinity.Address, size int)
+	// PeerTraffic returns the traffic statistics for the given peer.
+	PeerTraffic(peer infinity.Address) (TrafficStats, error)
+	// Traffic returns the traffic statistics for all known peers.
+	Traffic() (map[string]TrafficStats, error)
+	// Reconciliation returns the attempted and settled amounts for all known peers.
+	Reconciliation() (map[string]ReconciliationStats, error)
+	// PeerOverride returns the settlement and thresholds override for the given peer.
+	PeerOverride(peer infinity.Address) (PeerOverride, error)
+	// SetPeerOverride sets the settlement and thresholds override for the given peer.
+	SetPeerOverride(peer infinity.Address, o PeerOverride) error
+	// RemovePeerOverride removes the override for the given peer.
+	RemovePeerOverride(peer infinity.Address) error
+	// PeerOverrides returns the overrides for all peers.
+	PeerOverrides() (map[string]PeerOverride, error)
 }
 
 // accountingPeer holds all in-memory accounting information for one peer.
 type accountingPeer struct {
-	lock             sync.Mutex // lock to be held during any accounting action for this peer
-	reservedBalance  *big.Int   // amount currently reserved for active peer interaction
-	paymentThreshold *big.Int   // the threshold at which the peer expects us to pay
+	lock             sync.Mutex    // lock to be held during any accounting action for this peer
+	reservedBalance  *big.Int      // amount currently reserved for active peer interaction
+	paymentThreshold *big.Int      // the threshold at which the peer expects us to pay
+	override         *PeerOverride // the override of the peer, nil until it is loaded from the store
 }
 
 // Accounting is the main implementation of the accounting interface.
@@ -69,6 +88,8 @@ type Accounting struct {
 	accountingPeers   map[string]*accountingPeer
 	logger            logging.Logger
 	store             storage.StateStorer
+	// Mutex for accessing the payment thresholds changed at runtime.
+	thresholdsMu sync.RWMutex
 	// The payment threshold in IFI we communicate to our peers.
 	paymentThreshold *big.Int
 	// The amount in IFI we let peers exceed the payment threshold before we
@@ -78,6 +99,8 @@ type Accounting struct {
 	settlement       settlement.Interface
 	pricing          pricing.Interface
 	metrics          metrics
+	traffic          *traffic
+	reconciliation   *reconciliation
 }
 
 var (
@@ -113,9 +136,33 @@ func NewAccounting(
 		settlement:       Settlement,
 		pricing:          Pricing,
 		metrics:          newMetrics(),
+		traffic:          newTraffic(),
+		reconciliation:   newReconciliation(),
 	}, nil
 }
 
+// SetPaymentThresholds changes the payment threshold communicated to the
+// peers, the tolerance of the debt of the peers over it and the early payment
+// at runtime. The new payment threshold applies to the peers accounted for
+// the first time, the payment threshold has to be announced to the others.
+func (a *Accounting) SetPaymentThresholds(paymentThreshold, paymentTolerance, earlyPayment *big.Int) {
+	a.thresholdsMu.Lock()
+	defer a.thresholdsMu.Unlock()
+
+	a.paymentThreshold = new(big.Int).Set(paymentThreshold)
+	a.paymentTolerance = new(big.Int).Set(paymentTolerance)
+	a.earlyPayment = new(big.Int).Set(earlyPayment)
+}
+
+// thresholds returns the payment threshold, the payment tolerance and the
+// early payment. They must not be modified.
+func (a *Accounting) thresholds() (paymentThreshold, paymentTolerance, earlyPayment *big.Int) {
+	a.thresholdsMu.RLock()
+	defer a.thresholdsMu.RUnlock()
+
+	return a.paymentThreshold, a.paymentTolerance, a.earlyPayment
+}
+
 // Reserve reserves a portion of the balance for peer and attempts settlements if necessary.
 func (a *Accounting) Reserve(ctx context.Context, peer infinity.Address, price uint64) error {
 	accountingPeer, err := a.getAccountingPeer(peer)
@@ -144,9 +191,24 @@ func (a *Accounting) Reserve(ctx context.Context, peer infinity.Address, price u
 		expectedDebt.SetInt64(0)
 	}
 
-	threshold := new(big.Int).Set(accountingPeer.paymentThreshold)
-	if threshold.Cmp(a.earlyPayment) > 0 {
-		threshold.Sub(threshold, a.earlyPayment)
+	override, err := a.peerOverride(peer, accountingPeer)
+	if err != nil {
+		return err
+	}
+	if override.SwapDisabled {
+		accountingPeer.reservedBalance = nextReserved
+		a.reconciliation.reserved(peer, price)
+		return nil
+	}
+	paymentThreshold := accountingPeer.paymentThreshold
+	if override.PaymentThreshold != nil {
+		paymentThreshold = override.PaymentThreshold
+	}
+
+	_, _, earlyPayment := a.thresholds()
+	threshold := new(big.Int).Set(paymentThreshold)
+	if threshold.Cmp(earlyPayment) > 0 {
+		threshold.Sub(threshold, earlyPayment)
 	} else {
 		threshold.SetInt64(0)
 	}
@@ -179,12 +241,13 @@ func (a *Accounting) Reserve(ctx context.Context, peer infinity.Address, price u
 
 	// if expectedDebt would still exceed the paymentThreshold at this point block this request
 	// this can happen if there is a large number of concurrent requests to the same peer
-	if increasedExpectedDebt.Cmp(accountingPeer.paymentThreshold) > 0 {
+	if increasedExpectedDebt.Cmp(paymentThreshold) > 0 {
 		a.metrics.AccountingBlocksCount.Inc()
 		return ErrOverdraft
 	}
 
 	accountingPeer.reservedBalance = nextReserved
+	a.reconciliation.reserved(peer, price)
 	return nil
 }
 
@@ -240,6 +303,7 @@ func (a *Accounting) Credit(peer infinity.Address, price uint64) error {
 
 	a.metrics.TotalCreditedAmount.Add(float64(price))
 	a.metrics.CreditEventsCount.Inc()
+	a.reconciliation.credited(peer, price)
 	return nil
 }
 
@@ -318,6 +382,7 @@ func (a *Accounting) Debit(peer infinity.Address, price uint64) error {
 			// count debit operations, terminate early
 			a.metrics.TotalDebitedAmount.Add(float64(price))
 			a.metrics.DebitEventsCount.Inc()
+			a.reconciliation.debited(peer, price)
 			return nil
 		}
 
@@ -360,8 +425,21 @@ func (a *Accounting) Debit(peer infinity.Address, price uint64) error {
 
 	a.metrics.TotalDebitedAmount.Add(float64(price))
 	a.metrics.DebitEventsCount.Inc()
+	a.reconciliation.debited(peer, price)
 
-	if nextBalance.Cmp(new(big.Int).Add(a.paymentThreshold, a.paymentTolerance)) >= 0 {
+	override, err := a.peerOverride(peer, accountingPeer)
+	if err != nil {
+		return err
+	}
+	if override.SwapDisabled {
+		return nil
+	}
+	paymentThreshold, paymentTolerance, _ := a.thresholds()
+	disconnectThreshold := new(big.Int).Add(paymentThreshold, paymentTolerance)
+	if override.DisconnectThreshold != nil {
+		disconnectThreshold = override.DisconnectThreshold
+	}
+	if nextBalance.Cmp(disconnectThreshold) >= 0 {
 		// peer too much in debt
 		a.metrics.AccountingDisconnectsCount.Inc()
 		return p2p.NewBlockPeerError(10000*time.Hour, ErrDisconnectThresholdExceeded)
@@ -445,10 +523,11 @@ func (a *Accounting) getAccountingPeer(peer infinity.Address) (*accountingPeer,
 
 	peerData, ok := a.accountingPeers[peer.String()]
 	if !ok {
+		paymentThreshold, _, _ := a.thresholds()
 		peerData = &accountingPeer{
 			reservedBalance: big.NewInt(0),
 			// initially assume the peer has the same threshold as us
-			paymentThreshold: new(big.Int).Set(a.paymentThreshold),
+			paymentThreshold: new(big.Int).Set(paymentThreshold),
 		}
 		a.accountingPeers[peer.String()] = peerData
 	}
@@ -535,6 +614,39 @@ func (a *Accounting) CompensatedBalances() (map[string]*big.Int, error) {
 	return s, nil
 }
 
+// Served records a chunk of the given size served to the peer.
+func (a *Accounting) Served(peer infinity.Address, size int) {
+	a.traffic.served(peer, size)
+}
+
+// Requested records a chunk of the given size obtained from the peer.
+func (a *Accounting) Requested(peer infinity.Address, size int) {
+	a.traffic.requested(peer, size)
+}
+
+// PeerTraffic returns the traffic statistics for the given peer. Traffic is
+// counted independently of the balance and it is not persisted.
+func (a *Accounting) PeerTraffic(peer infinity.Address) (TrafficStats, error) {
+	return a.traffic.peer(peer)
+}
+
+// Disconnected drops the traffic statistics of the disconnected peer.
+func (a *Accounting) Disconnected(peer infinity.Address) {
+	a.traffic.remove(peer)
+}
+
+// Traffic returns the traffic statistics for all known peers.
+func (a *Accounting) Traffic() (map[string]TrafficStats, error) {
+	return a.traffic.all(), nil
+}
+
+// Reconciliation returns the amounts reserved and credited for the operations
+// attempted with all known peers, and the amounts debited for the operations
+// served to them, since the node is started.
+func (a *Accounting) Reconciliation() (map[string]ReconciliationStats, error) {
+	return a.reconciliation.all(), nil
+}
+
 // balanceKeyPeer returns the embedded peer from the balance storage key.
 func balanceKeyPeer(key []byte) (infinity.Address, error) {
 	k := string(key)
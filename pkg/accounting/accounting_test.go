@@ -218,6 +218,43 @@ func TestAccountingDisconnect(t *testing.T) {
 	}
 }
 
+// TestAccountingSetPaymentThresholds tests that the disconnect threshold
+// changes with the payment thresholds set at runtime
+func TestAccountingSetPaymentThresholds(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+
+	store := mock.NewStateStore()
+	defer store.Close()
+
+	acc, err := accounting.NewAccounting(testPaymentThreshold, testPaymentTolerance, testPaymentEarly, logger, store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer1Addr, err := infinity.ParseHexAddress("00112233")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// put the peer 1 unit away from the disconnect with the doubled thresholds
+	err = acc.Debit(peer1Addr, testPaymentThreshold.Uint64()+testPaymentTolerance.Uint64()-1)
+	if err != nil {
+		t.Fatal("expected no error while still within tolerance")
+	}
+	acc.SetPaymentThresholds(new(big.Int).Mul(testPaymentThreshold, big.NewInt(2)), new(big.Int).Mul(testPaymentTolerance, big.NewInt(2)), testPaymentEarly)
+	err = acc.Debit(peer1Addr, testPaymentThreshold.Uint64()+testPaymentTolerance.Uint64())
+	if err != nil {
+		t.Fatal("expected no error while still within the new tolerance")
+	}
+
+	// put the peer over the new threshold
+	err = acc.Debit(peer1Addr, 1)
+	var e *p2p.BlockPeerError
+	if !errors.As(err, &e) {
+		t.Fatalf("expected BlockPeerError, got %v", err)
+	}
+}
+
 // TestAccountingCallSettlement tests that settlement is called correctly if the payment threshold is hit
 func TestAccountingCallSettlement(t *testing.T) {
 	logger := logging.New(ioutil.Discard, 0)
@@ -608,3 +645,357 @@ func TestAccountingNotifyPaymentThreshold(t *testing.T) {
 		t.Fatalf("paid wrong amount. got %d wanted %d", totalSent, debt)
 	}
 }
+
+// TestAccountingTraffic verifies that traffic is counted independently of
+// the balance.
+func TestAccountingTraffic(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+
+	store := mock.NewStateStore()
+	defer store.Close()
+
+	acc, err := accounting.NewAccounting(testPaymentThreshold, testPaymentTolerance, testPaymentEarly, logger, store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer1Addr, err := infinity.ParseHexAddress("00112233")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer2Addr, err := infinity.ParseHexAddress("00112244")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := acc.PeerTraffic(peer1Addr); !errors.Is(err, accounting.ErrPeerNoTraffic) {
+		t.Fatalf("got error %v, want %v", err, accounting.ErrPeerNoTraffic)
+	}
+
+	acc.Served(peer1Addr, 4096)
+	acc.Served(peer1Addr, 100)
+	acc.Requested(peer1Addr, 4096)
+	acc.Requested(peer2Addr, 10)
+
+	want1 := accounting.TrafficStats{
+		ChunksServed:    2,
+		BytesServed:     4196,
+		ChunksRequested: 1,
+		BytesRequested:  4096,
+	}
+	want2 := accounting.TrafficStats{
+		ChunksRequested: 1,
+		BytesRequested:  10,
+	}
+
+	got, err := acc.PeerTraffic(peer1Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want1 {
+		t.Fatalf("got traffic %+v, want %+v", got, want1)
+	}
+
+	traffic, err := acc.Traffic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traffic) != 2 {
+		t.Fatalf("got traffic for %v peers, want %v", len(traffic), 2)
+	}
+	if got := traffic[peer1Addr.String()]; got != want1 {
+		t.Fatalf("got traffic %+v, want %+v", got, want1)
+	}
+	if got := traffic[peer2Addr.String()]; got != want2 {
+		t.Fatalf("got traffic %+v, want %+v", got, want2)
+	}
+
+	if _, err := acc.Balance(peer1Addr); !errors.Is(err, accounting.ErrPeerNoBalance) {
+		t.Fatalf("got error %v, want %v", err, accounting.ErrPeerNoBalance)
+	}
+
+	acc.Disconnected(peer1Addr)
+
+	if _, err := acc.PeerTraffic(peer1Addr); !errors.Is(err, accounting.ErrPeerNoTraffic) {
+		t.Fatalf("got error %v, want %v", err, accounting.ErrPeerNoTraffic)
+	}
+	traffic, err = acc.Traffic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traffic) != 1 {
+		t.Fatalf("got traffic for %v peers, want %v", len(traffic), 1)
+	}
+}
+
+// TestAccountingReconciliation tests that the reserved, credited and debited
+// amounts are reported for every peer.
+func TestAccountingReconciliation(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+
+	store := mock.NewStateStore()
+	defer store.Close()
+
+	acc, err := accounting.NewAccounting(testPaymentThreshold, testPaymentTolerance, testPaymentEarly, logger, store, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer1Addr, err := infinity.ParseHexAddress("00112233")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer2Addr, err := infinity.ParseHexAddress("00112244")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two operations are attempted with the first peer, one of them fails
+	for i := 0; i < 2; i++ {
+		if err := acc.Reserve(context.Background(), peer1Addr, testPrice); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := acc.Credit(peer1Addr, testPrice); err != nil {
+		t.Fatal(err)
+	}
+	acc.Release(peer1Addr, testPrice)
+	acc.Release(peer1Addr, testPrice)
+
+	// two operations are served to the second peer
+	for i := 0; i < 2; i++ {
+		if err := acc.Debit(peer2Addr, testPrice); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reconciliation, err := acc.Reconciliation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reconciliation) != 2 {
+		t.Fatalf("got reconciliation for %v peers, want %v", len(reconciliation), 2)
+	}
+
+	want1 := accounting.ReconciliationStats{
+		Reserved: 2 * testPrice,
+		Credited: testPrice,
+	}
+	if got := reconciliation[peer1Addr.String()]; got != want1 {
+		t.Fatalf("got reconciliation %+v, want %+v", got, want1)
+	}
+	if got := want1.Unsettled(); got != testPrice {
+		t.Fatalf("got unsettled %v, want %v", got, testPrice)
+	}
+
+	want2 := accounting.ReconciliationStats{
+		Debited: 2 * testPrice,
+	}
+	if got := reconciliation[peer2Addr.String()]; got != want2 {
+		t.Fatalf("got reconciliation %+v, want %+v", got, want2)
+	}
+}
+
+// TestAccountingPeerOverride tests that the disabled swap and the overridden
+// thresholds of a peer apply to the reserve and the debit.
+func TestAccountingPeerOverride(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+
+	store := mock.NewStateStore()
+	defer store.Close()
+
+	settlement := mockSettlement.New()
+
+	acc, err := accounting.NewAccounting(testPaymentThreshold, testPaymentTolerance, testPaymentEarly, logger, store, settlement, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer1Addr, err := infinity.ParseHexAddress("00112233")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := acc.PeerOverride(peer1Addr); !errors.Is(err, accounting.ErrPeerNoOverride) {
+		t.Fatalf("got error %v, want %v", err, accounting.ErrPeerNoOverride)
+	}
+
+	if disabled, err := acc.SwapDisabled(peer1Addr); err != nil || disabled {
+		t.Fatalf("got swap disabled %v, error %v, want swap enabled", disabled, err)
+	}
+
+	err = acc.SetPeerOverride(peer1Addr, accounting.PeerOverride{SwapDisabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disabled, err := acc.SwapDisabled(peer1Addr); err != nil || !disabled {
+		t.Fatalf("got swap disabled %v, error %v, want swap disabled", disabled, err)
+	}
+
+	// the debt over the payment threshold is neither settled nor blocked
+	amount := 2 * testPaymentThreshold.Uint64()
+	for i := 0; i < 2; i++ {
+		err = acc.Reserve(context.Background(), peer1Addr, amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = acc.Credit(peer1Addr, amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		acc.Release(peer1Addr, amount)
+	}
+
+	totalSent, err := settlement.TotalSent(peer1Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totalSent.Sign() != 0 {
+		t.Fatalf("paid %d with swap disabled", totalSent)
+	}
+
+	// the peer in debt over the disconnect threshold is not disconnected
+	err = acc.Debit(peer1Addr, 2*amount+testPaymentThreshold.Uint64()+testPaymentTolerance.Uint64())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the overridden disconnect threshold applies with swap enabled
+	disconnectThreshold := new(big.Int).Add(testPaymentThreshold, testPaymentTolerance)
+	disconnectThreshold.Add(disconnectThreshold, big.NewInt(10))
+	err = acc.SetPeerOverride(peer1Addr, accounting.PeerOverride{DisconnectThreshold: disconnectThreshold})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = acc.Debit(peer1Addr, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = acc.Debit(peer1Addr, 1)
+	var e *p2p.BlockPeerError
+	if !errors.As(err, &e) {
+		t.Fatalf("expected BlockPeerError, got %v", err)
+	}
+
+	overrides, err := acc.PeerOverrides()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o, ok := overrides[peer1Addr.String()]; !ok || o.DisconnectThreshold.Cmp(disconnectThreshold) != 0 {
+		t.Fatalf("got overrides %v", overrides)
+	}
+
+	err = acc.RemovePeerOverride(peer1Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acc.RemovePeerOverride(peer1Addr); !errors.Is(err, accounting.ErrPeerNoOverride) {
+		t.Fatalf("got error %v, want %v", err, accounting.ErrPeerNoOverride)
+	}
+
+	if err := acc.SetPeerOverride(peer1Addr, accounting.PeerOverride{PaymentThreshold: big.NewInt(-1)}); !errors.Is(err, accounting.ErrInvalidValue) {
+		t.Fatalf("got error %v, want %v", err, accounting.ErrInvalidValue)
+	}
+}
+
+// TestAccountingPeerOverrideStored tests that the override persisted by a
+// previous run applies and that the removed override does not apply anymore.
+func TestAccountingPeerOverrideStored(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+
+	store := mock.NewStateStore()
+	defer store.Close()
+
+	peer1Addr, err := infinity.ParseHexAddress("00112233")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acc, err := accounting.NewAccounting(testPaymentThreshold, testPaymentTolerance, testPaymentEarly, logger, store, mockSettlement.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = acc.SetPeerOverride(peer1Addr, accounting.PeerOverride{SwapDisabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acc, err = accounting.NewAccounting(testPaymentThreshold, testPaymentTolerance, testPaymentEarly, logger, store, mockSettlement.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the peer in debt over the disconnect threshold is not disconnected
+	err = acc.Debit(peer1Addr, testPaymentThreshold.Uint64()+testPaymentTolerance.Uint64())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = acc.RemovePeerOverride(peer1Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = acc.Debit(peer1Addr, 1)
+	var e *p2p.BlockPeerError
+	if !errors.As(err, &e) {
+		t.Fatalf("expected BlockPeerError, got %v", err)
+	}
+}
+
+// TestAccountingPeerOverridePaymentThreshold tests that the settlement is
+// called at the overridden payment threshold.
+func TestAccountingPeerOverridePaymentThreshold(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+
+	store := mock.NewStateStore()
+	defer store.Close()
+
+	settlement := mockSettlement.New()
+
+	acc, err := accounting.NewAccounting(testPaymentThreshold, testPaymentTolerance, big.NewInt(0), logger, store, settlement, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer1Addr, err := infinity.ParseHexAddress("00112233")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paymentThreshold := big.NewInt(100)
+	err = acc.SetPeerOverride(peer1Addr, accounting.PeerOverride{PaymentThreshold: paymentThreshold})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = acc.Reserve(context.Background(), peer1Addr, paymentThreshold.Uint64()+1)
+	if !errors.Is(err, accounting.ErrOverdraft) {
+		t.Fatalf("got error %v, want %v", err, accounting.ErrOverdraft)
+	}
+
+	err = acc.Reserve(context.Background(), peer1Addr, paymentThreshold.Uint64())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = acc.Credit(peer1Addr, paymentThreshold.Uint64())
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc.Release(peer1Addr, paymentThreshold.Uint64())
+
+	err = acc.Reserve(context.Background(), peer1Addr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc.Release(peer1Addr, 1)
+
+	totalSent, err := settlement.TotalSent(peer1Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totalSent.Cmp(paymentThreshold) != 0 {
+		t.Fatalf("paid wrong amount. got %d wanted %d", totalSent, paymentThreshold)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package accounting
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Cost accumulates the number and the price of the chunk operations which
+// are paid for to the peers on behalf of a request. It is safe for
+// concurrent use.
+type Cost struct {
+	chunks uint64
+	amount uint64
+}
+
+// Add records a chunk operation with the price.
+func (c *Cost) Add(price uint64) {
+	atomic.AddUint64(&c.chunks, 1)
+	atomic.AddUint64(&c.amount, price)
+}
+
+// Chunks returns the number of the recorded chunk operations.
+func (c *Cost) Chunks() uint64 {
+	return atomic.LoadUint64(&c.chunks)
+}
+
+// Amount returns the sum of the prices of the recorded chunk operations.
+func (c *Cost) Amount() uint64 {
+	return atomic.LoadUint64(&c.amount)
+}
+
+type costKey struct{}
+
+// WithCost returns the context with the cost to which the chunk operations
+// paid for with the context are added.
+func WithCost(ctx context.Context, c *Cost) context.Context {
+	return context.WithValue(ctx, costKey{}, c)
+}
+
+// AddCost adds the chunk operation with the price to the cost in the
+// context, if there is one.
+func AddCost(ctx context.Context, price uint64) {
+	if c, ok := ctx.Value(costKey{}).(*Cost); ok {
+		c.Add(price)
+	}
+}
+
+// CostFrom returns the cost in the context, if there is one.
+func CostFrom(ctx context.Context) (*Cost, bool) {
+	c, ok := ctx.Value(costKey{}).(*Cost)
+	return c, ok
+}
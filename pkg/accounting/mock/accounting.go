@@ -29,6 +29,15 @@ type Service struct {
 	compensatedBalancesFunc func() (map[string]*big.Int, error)
 
 	balanceSurplusFunc func(infinity.Address) (*big.Int, error)
+
+	traffic         map[string]accounting.TrafficStats
+	peerTrafficFunc func(infinity.Address) (accounting.TrafficStats, error)
+	trafficFunc     func() (map[string]accounting.TrafficStats, error)
+
+	reconciliation     map[string]accounting.ReconciliationStats
+	reconciliationFunc func() (map[string]accounting.ReconciliationStats, error)
+
+	overrides map[string]accounting.PeerOverride
 }
 
 // WithReserveFunc sets the mock Reserve function
@@ -94,10 +103,34 @@ func WithBalanceSurplusFunc(f func(infinity.Address) (*big.Int, error)) Option {
 	})
 }
 
+// WithPeerTrafficFunc sets the mock PeerTraffic function
+func WithPeerTrafficFunc(f func(infinity.Address) (accounting.TrafficStats, error)) Option {
+	return optionFunc(func(s *Service) {
+		s.peerTrafficFunc = f
+	})
+}
+
+// WithTrafficFunc sets the mock Traffic function
+func WithTrafficFunc(f func() (map[string]accounting.TrafficStats, error)) Option {
+	return optionFunc(func(s *Service) {
+		s.trafficFunc = f
+	})
+}
+
+// WithReconciliationFunc sets the mock Reconciliation function
+func WithReconciliationFunc(f func() (map[string]accounting.ReconciliationStats, error)) Option {
+	return optionFunc(func(s *Service) {
+		s.reconciliationFunc = f
+	})
+}
+
 // NewAccounting creates the mock accounting implementation
 func NewAccounting(opts ...Option) accounting.Interface {
 	mock := new(Service)
 	mock.balances = make(map[string]*big.Int)
+	mock.traffic = make(map[string]accounting.TrafficStats)
+	mock.reconciliation = make(map[string]accounting.ReconciliationStats)
+	mock.overrides = make(map[string]accounting.PeerOverride)
 	for _, o := range opts {
 		o.apply(mock)
 	}
@@ -109,6 +142,12 @@ func (s *Service) Reserve(ctx context.Context, peer infinity.Address, price uint
 	if s.reserveFunc != nil {
 		return s.reserveFunc(ctx, peer, price)
 	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := s.reconciliation[peer.String()]
+	r.Reserved += price
+	s.reconciliation[peer.String()] = r
 	return nil
 }
 
@@ -132,6 +171,10 @@ func (s *Service) Credit(peer infinity.Address, price uint64) error {
 	} else {
 		s.balances[peer.String()] = big.NewInt(-int64(price))
 	}
+
+	r := s.reconciliation[peer.String()]
+	r.Credited += price
+	s.reconciliation[peer.String()] = r
 	return nil
 }
 
@@ -148,6 +191,10 @@ func (s *Service) Debit(peer infinity.Address, price uint64) error {
 	} else {
 		s.balances[peer.String()] = new(big.Int).SetUint64(price)
 	}
+
+	r := s.reconciliation[peer.String()]
+	r.Debited += price
+	s.reconciliation[peer.String()] = r
 	return nil
 }
 
@@ -200,6 +247,121 @@ func (s *Service) SurplusBalance(peer infinity.Address) (*big.Int, error) {
 	return big.NewInt(0), nil
 }
 
+// Served records the served chunk in the mock traffic statistics
+func (s *Service) Served(peer infinity.Address, size int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t := s.traffic[peer.String()]
+	t.ChunksServed++
+	t.BytesServed += uint64(size)
+	s.traffic[peer.String()] = t
+}
+
+// Requested records the requested chunk in the mock traffic statistics
+func (s *Service) Requested(peer infinity.Address, size int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t := s.traffic[peer.String()]
+	t.ChunksRequested++
+	t.BytesRequested += uint64(size)
+	s.traffic[peer.String()] = t
+}
+
+// PeerTraffic is the mock function wrapper that calls the set implementation
+func (s *Service) PeerTraffic(peer infinity.Address) (accounting.TrafficStats, error) {
+	if s.peerTrafficFunc != nil {
+		return s.peerTrafficFunc(peer)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t, ok := s.traffic[peer.String()]
+	if !ok {
+		return accounting.TrafficStats{}, accounting.ErrPeerNoTraffic
+	}
+	return t, nil
+}
+
+// Traffic is the mock function wrapper that calls the set implementation
+func (s *Service) Traffic() (map[string]accounting.TrafficStats, error) {
+	if s.trafficFunc != nil {
+		return s.trafficFunc()
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	traffic := make(map[string]accounting.TrafficStats, len(s.traffic))
+	for k, t := range s.traffic {
+		traffic[k] = t
+	}
+	return traffic, nil
+}
+
+// Reconciliation is the mock function wrapper that calls the set implementation
+func (s *Service) Reconciliation() (map[string]accounting.ReconciliationStats, error) {
+	if s.reconciliationFunc != nil {
+		return s.reconciliationFunc()
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reconciliation := make(map[string]accounting.ReconciliationStats, len(s.reconciliation))
+	for k, r := range s.reconciliation {
+		reconciliation[k] = r
+	}
+	return reconciliation, nil
+}
+
+// PeerOverride returns the override set for the peer in the mock
+func (s *Service) PeerOverride(peer infinity.Address) (accounting.PeerOverride, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	o, ok := s.overrides[peer.String()]
+	if !ok {
+		return accounting.PeerOverride{}, accounting.ErrPeerNoOverride
+	}
+	return o, nil
+}
+
+// SetPeerOverride sets the override for the peer in the mock
+func (s *Service) SetPeerOverride(peer infinity.Address, o accounting.PeerOverride) error {
+	if (o.PaymentThreshold != nil && o.PaymentThreshold.Sign() < 0) || (o.DisconnectThreshold != nil && o.DisconnectThreshold.Sign() < 0) {
+		return accounting.ErrInvalidValue
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.overrides[peer.String()] = o
+	return nil
+}
+
+// RemovePeerOverride removes the override for the peer in the mock
+func (s *Service) RemovePeerOverride(peer infinity.Address) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.overrides[peer.String()]; !ok {
+		return accounting.ErrPeerNoOverride
+	}
+	delete(s.overrides, peer.String())
+	return nil
+}
+
+// PeerOverrides returns the overrides set in the mock
+func (s *Service) PeerOverrides() (map[string]accounting.PeerOverride, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	overrides := make(map[string]accounting.PeerOverride, len(s.overrides))
+	for k, o := range s.overrides {
+		overrides[k] = o
+	}
+	return overrides, nil
+}
+
 // Option is the option passed to the mock accounting service
 type Option interface {
 	apply(*Service)
@@ -0,0 +1,165 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package accounting
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+var overridesPrefix = "accounting_peer_override_"
+
+// ErrPeerNoOverride is the error returned if no override is set for a peer.
+var ErrPeerNoOverride = errors.New("no override for peer")
+
+// PeerOverride changes the settlement and the thresholds for a single peer,
+// for example for the peers of the same operator. It is persisted in the
+// state store.
+type PeerOverride struct {
+	// SwapDisabled disables the settlement with the peer. The balance is
+	// still accounted, but the debt is neither paid nor does it block the
+	// requests, and the peer is never disconnected for its debt. The swap
+	// rejects the cheques received from the peer.
+	SwapDisabled bool `json:"swapDisabled"`
+	// PaymentThreshold replaces the payment threshold announced by the peer,
+	// the debt at which we pay the peer, if not nil.
+	PaymentThreshold *big.Int `json:"paymentThreshold,omitempty"`
+	// DisconnectThreshold replaces the payment threshold plus the payment
+	// tolerance, the debt of the peer at which it is disconnected, if not nil.
+	DisconnectThreshold *big.Int `json:"disconnectThreshold,omitempty"`
+}
+
+// PeerOverride returns the override of the given peer.
+func (a *Accounting) PeerOverride(peer infinity.Address) (o PeerOverride, err error) {
+	err = a.store.Get(peerOverrideKey(peer), &o)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return PeerOverride{}, ErrPeerNoOverride
+		}
+		return PeerOverride{}, err
+	}
+	return o, nil
+}
+
+// SwapDisabled returns true if the override of the given peer disables the
+// settlement with it.
+func (a *Accounting) SwapDisabled(peer infinity.Address) (bool, error) {
+	o, err := a.PeerOverride(peer)
+	if err != nil {
+		if errors.Is(err, ErrPeerNoOverride) {
+			return false, nil
+		}
+		return false, err
+	}
+	return o.SwapDisabled, nil
+}
+
+// SetPeerOverride sets the override of the given peer. It applies to the
+// accounting actions started after it returns.
+func (a *Accounting) SetPeerOverride(peer infinity.Address, o PeerOverride) error {
+	if o.PaymentThreshold != nil && o.PaymentThreshold.Sign() < 0 {
+		return ErrInvalidValue
+	}
+	if o.DisconnectThreshold != nil && o.DisconnectThreshold.Sign() < 0 {
+		return ErrInvalidValue
+	}
+
+	accountingPeer, err := a.getAccountingPeer(peer)
+	if err != nil {
+		return err
+	}
+
+	accountingPeer.lock.Lock()
+	defer accountingPeer.lock.Unlock()
+
+	if err := a.store.Put(peerOverrideKey(peer), o); err != nil {
+		return fmt.Errorf("failed to persist override: %w", err)
+	}
+	accountingPeer.override = &o
+	return nil
+}
+
+// RemovePeerOverride removes the override of the given peer, the node
+// thresholds and the settlement apply to it again.
+func (a *Accounting) RemovePeerOverride(peer infinity.Address) error {
+	if _, err := a.PeerOverride(peer); err != nil {
+		return err
+	}
+
+	accountingPeer, err := a.getAccountingPeer(peer)
+	if err != nil {
+		return err
+	}
+
+	accountingPeer.lock.Lock()
+	defer accountingPeer.lock.Unlock()
+
+	if err := a.store.Delete(peerOverrideKey(peer)); err != nil {
+		return err
+	}
+	accountingPeer.override = &PeerOverride{}
+	return nil
+}
+
+// PeerOverrides returns the overrides of all peers.
+func (a *Accounting) PeerOverrides() (map[string]PeerOverride, error) {
+	s := make(map[string]PeerOverride)
+
+	err := a.store.Iterate(overridesPrefix, func(key, val []byte) (stop bool, err error) {
+		addr, err := overrideKeyPeer(key)
+		if err != nil {
+			return false, fmt.Errorf("parse address from key: %s: %v", string(key), err)
+		}
+
+		o, err := a.PeerOverride(addr)
+		if err != nil {
+			return false, fmt.Errorf("get peer %s override: %v", addr.String(), err)
+		}
+		s[addr.String()] = o
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// peerOverride returns the override of the peer or the zero override if it
+// is not set. It is loaded from the store only once and kept with the
+// accounting peer, whose lock must be held.
+func (a *Accounting) peerOverride(peer infinity.Address, accountingPeer *accountingPeer) (PeerOverride, error) {
+	if accountingPeer.override != nil {
+		return *accountingPeer.override, nil
+	}
+	o, err := a.PeerOverride(peer)
+	if err != nil && !errors.Is(err, ErrPeerNoOverride) {
+		return PeerOverride{}, fmt.Errorf("failed to load override: %w", err)
+	}
+	accountingPeer.override = &o
+	return o, nil
+}
+
+// peerOverrideKey returns the override storage key for the given peer.
+func peerOverrideKey(peer infinity.Address) string {
+	return fmt.Sprintf("%s%s", overridesPrefix, peer.String())
+}
+
+func overrideKeyPeer(key []byte) (infinity.Address, error) {
+	k := string(key)
+
+	split := strings.SplitAfter(k, overridesPrefix)
+	if len(split) != 2 {
+		return infinity.ZeroAddress, errors.New("no peer in key")
+	}
+
+	return infinity.ParseHexAddress(split[1])
+}
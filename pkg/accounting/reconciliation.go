@@ -0,0 +1,89 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package accounting
+
+import (
+	"sync"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+// ReconciliationStats holds the amounts of the operations with a peer which
+// are attempted and the amounts which are settled for them, since the node
+// is started.
+type ReconciliationStats struct {
+	// Reserved is the amount reserved for the operations this node attempted
+	// with the peer.
+	Reserved uint64
+	// Credited is the amount credited to the peer for the completed ones.
+	Credited uint64
+	// Debited is the amount debited to the peer for the operations this node
+	// served.
+	Debited uint64
+}
+
+// Unsettled returns the amount reserved for the operations which are not
+// credited to the peer, either because they failed or they are in progress.
+func (s ReconciliationStats) Unsettled() uint64 {
+	if s.Credited > s.Reserved {
+		return 0
+	}
+	return s.Reserved - s.Credited
+}
+
+// reconciliation keeps in-memory reconciliation statistics for every peer.
+type reconciliation struct {
+	peers map[string]*ReconciliationStats
+	mu    sync.Mutex
+}
+
+func newReconciliation() *reconciliation {
+	return &reconciliation{
+		peers: make(map[string]*ReconciliationStats),
+	}
+}
+
+func (r *reconciliation) reserved(peer infinity.Address, price uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.peerStats(peer).Reserved += price
+}
+
+func (r *reconciliation) credited(peer infinity.Address, price uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.peerStats(peer).Credited += price
+}
+
+func (r *reconciliation) debited(peer infinity.Address, price uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.peerStats(peer).Debited += price
+}
+
+// peerStats returns the statistics of the peer, creating them if necessary.
+// It must be called with the lock held.
+func (r *reconciliation) peerStats(peer infinity.Address) *ReconciliationStats {
+	s, ok := r.peers[peer.String()]
+	if !ok {
+		s = new(ReconciliationStats)
+		r.peers[peer.String()] = s
+	}
+	return s
+}
+
+func (r *reconciliation) all() map[string]ReconciliationStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	all := make(map[string]ReconciliationStats, len(r.peers))
+	for k, s := range r.peers {
+		all[k] = *s
+	}
+	return all
+}
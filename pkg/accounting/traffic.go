@@ -0,0 +1,98 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package accounting
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+// ErrPeerNoTraffic is the error returned if no traffic is recorded for a peer.
+var ErrPeerNoTraffic = errors.New("no traffic for peer")
+
+// TrafficStats holds the number of chunks and bytes exchanged with a peer,
+// regardless of their price and the state of the monetary balance.
+type TrafficStats struct {
+	// ChunksServed is the number of chunks this node served to the peer.
+	ChunksServed uint64
+	// BytesServed is the size of the chunk data this node served to the peer.
+	BytesServed uint64
+	// ChunksRequested is the number of chunks this node obtained from the peer.
+	ChunksRequested uint64
+	// BytesRequested is the size of the chunk data this node obtained from the peer.
+	BytesRequested uint64
+}
+
+// traffic keeps in-memory traffic statistics for every connected peer.
+type traffic struct {
+	peers map[string]*TrafficStats
+	mu    sync.Mutex
+}
+
+func newTraffic() *traffic {
+	return &traffic{
+		peers: make(map[string]*TrafficStats),
+	}
+}
+
+func (t *traffic) served(peer infinity.Address, size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.peerStats(peer)
+	s.ChunksServed++
+	s.BytesServed += uint64(size)
+}
+
+func (t *traffic) requested(peer infinity.Address, size int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.peerStats(peer)
+	s.ChunksRequested++
+	s.BytesRequested += uint64(size)
+}
+
+// peerStats returns the statistics of the peer, creating them if necessary.
+// It must be called with the lock held.
+func (t *traffic) peerStats(peer infinity.Address) *TrafficStats {
+	s, ok := t.peers[peer.String()]
+	if !ok {
+		s = new(TrafficStats)
+		t.peers[peer.String()] = s
+	}
+	return s
+}
+
+func (t *traffic) peer(peer infinity.Address) (TrafficStats, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.peers[peer.String()]
+	if !ok {
+		return TrafficStats{}, ErrPeerNoTraffic
+	}
+	return *s, nil
+}
+
+func (t *traffic) remove(peer infinity.Address) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.peers, peer.String())
+}
+
+func (t *traffic) all() map[string]TrafficStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	all := make(map[string]TrafficStats, len(t.peers))
+	for k, s := range t.peers {
+		all[k] = *s
+	}
+	return all
+}
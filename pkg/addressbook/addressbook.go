@@ -8,6 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/yanhuangpai/voyager/pkg/ifi"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
@@ -20,6 +22,9 @@ var _ Interface = (*store)(nil)
 
 var ErrNotFound = errors.New("addressbook: not found")
 
+// timeNow is used to record the times of the entries, it is replaced in tests.
+var timeNow = time.Now
+
 // Interface is the AddressBook interface.
 type Interface interface {
 	GetPutter
@@ -28,6 +33,13 @@ type Interface interface {
 	Overlays() ([]infinity.Address, error)
 	// Addresses returns a list of all ifi.Address-es saved in addressbook.
 	Addresses() ([]ifi.Address, error)
+	// Entry returns the saved entry with the liveness metadata for requested
+	// overlay address.
+	Entry(overlay infinity.Address) (*Entry, error)
+	// Entries returns a list of all entries saved in addressbook.
+	Entries() ([]Entry, error)
+	// Failed records a failed connection attempt to the peer.
+	Failed(overlay infinity.Address) error
 }
 
 type GetPutter interface {
@@ -43,6 +55,13 @@ type Getter interface {
 type Putter interface {
 	// Put saves relation between peer overlay address and ifi.Address address.
 	Put(overlay infinity.Address, addr ifi.Address) (err error)
+	// PutSource saves the address as Put, refreshing the time it was last
+	// learned, and records the source of the address if the entry does not
+	// have a known source yet.
+	PutSource(overlay infinity.Address, addr ifi.Address, source Source) (err error)
+	// Connected saves the address of the successfully connected peer,
+	// recording the connection time and resetting its failures.
+	Connected(overlay infinity.Address, addr ifi.Address) (err error)
 }
 
 type Remover interface {
@@ -52,6 +71,7 @@ type Remover interface {
 
 type store struct {
 	store storage.StateStorer
+	mu    sync.Mutex // serializes the updates of the entries
 }
 
 // New creates new addressbook for state storer.
@@ -62,9 +82,16 @@ func New(storer storage.StateStorer) Interface {
 }
 
 func (s *store) Get(overlay infinity.Address) (*ifi.Address, error) {
-	key := keyPrefix + overlay.String()
-	v := &ifi.Address{}
-	err := s.store.Get(key, &v)
+	e, err := s.Entry(overlay)
+	if err != nil {
+		return nil, err
+	}
+	return &e.Address, nil
+}
+
+func (s *store) Entry(overlay infinity.Address) (*Entry, error) {
+	e := &Entry{}
+	err := s.store.Get(keyPrefix+overlay.String(), e)
 	if err != nil {
 		if err == storage.ErrNotFound {
 			return nil, ErrNotFound
@@ -72,12 +99,65 @@ func (s *store) Get(overlay infinity.Address) (*ifi.Address, error) {
 
 		return nil, err
 	}
-	return v, nil
+	return e, nil
 }
 
 func (s *store) Put(overlay infinity.Address, addr ifi.Address) (err error) {
-	key := keyPrefix + overlay.String()
-	return s.store.Put(key, &addr)
+	return s.PutSource(overlay, addr, SourceUnknown)
+}
+
+func (s *store) PutSource(overlay infinity.Address, addr ifi.Address, source Source) (err error) {
+	return s.update(overlay, func(e *Entry, exists bool) error {
+		e.Address = addr
+		e.LastLearned = timeNow()
+		if e.Source == SourceUnknown {
+			e.Source = source
+		}
+		return nil
+	})
+}
+
+func (s *store) Connected(overlay infinity.Address, addr ifi.Address) (err error) {
+	return s.update(overlay, func(e *Entry, exists bool) error {
+		e.Address = addr
+		e.LastConnected = timeNow()
+		e.Failures = 0
+		return nil
+	})
+}
+
+func (s *store) Failed(overlay infinity.Address) error {
+	return s.update(overlay, func(e *Entry, exists bool) error {
+		if !exists {
+			return ErrNotFound
+		}
+		e.Failures++
+		return nil
+	})
+}
+
+// update changes the entry for the overlay address with the function, the
+// entry is created if it does not exist.
+func (s *store) update(overlay infinity.Address, f func(e *Entry, exists bool) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exists := true
+	e, err := s.Entry(overlay)
+	if err != nil {
+		if err != ErrNotFound {
+			return err
+		}
+		exists = false
+		e = &Entry{}
+	}
+	if err := f(e, exists); err != nil {
+		return err
+	}
+	if e.Created.IsZero() {
+		e.Created = timeNow()
+	}
+	return s.store.Put(keyPrefix+overlay.String(), e)
 }
 
 func (s *store) Remove(overlay infinity.Address) error {
@@ -109,19 +189,31 @@ func (s *store) Overlays() (overlays []infinity.Address, err error) {
 }
 
 func (s *store) Addresses() (addresses []ifi.Address, err error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		addresses = append(addresses, e.Address)
+	}
+	return addresses, nil
+}
+
+func (s *store) Entries() (entries []Entry, err error) {
 	err = s.store.Iterate(keyPrefix, func(_, value []byte) (stop bool, err error) {
-		entry := &ifi.Address{}
-		err = entry.UnmarshalJSON(value)
+		var e Entry
+		err = e.UnmarshalJSON(value)
 		if err != nil {
 			return true, err
 		}
 
-		addresses = append(addresses, *entry)
+		entries = append(entries, e)
 		return false, nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	return addresses, nil
+	return entries, nil
 }
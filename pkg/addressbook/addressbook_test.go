@@ -5,12 +5,18 @@
 package addressbook_test
 
 import (
+	"errors"
+	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/yanhuangpai/voyager/pkg/addressbook"
 	"github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/ifi"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	p2pmock "github.com/yanhuangpai/voyager/pkg/p2p/mock"
 	"github.com/yanhuangpai/voyager/pkg/statestore/mock"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -40,7 +46,7 @@ func run(t *testing.T, f bookFunc) {
 		t.Fatal(err)
 	}
 
-	ifiAddr, err := ifi.NewAddress(crypto.NewDefaultSigner(pk), multiaddr, addr1, 1)
+	ifiAddr, err := ifi.NewAddress(crypto.NewDefaultSigner(pk), multiaddr, addr1, 1, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -86,3 +92,191 @@ func run(t *testing.T, f bookFunc) {
 		t.Fatalf("expected addresses len %v, got %v", 1, len(addresses))
 	}
 }
+
+func TestEntryMetadata(t *testing.T) {
+	now := setTimeNow(t, time.Unix(1000, 0))
+
+	book := addressbook.New(mock.NewStateStore())
+	addr := newIfiAddress(t, infinity.NewAddress([]byte{0, 1, 2, 3}))
+	overlay := addr.Overlay
+
+	if err := book.Failed(overlay); !errors.Is(err, addressbook.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, addressbook.ErrNotFound)
+	}
+
+	if err := book.PutSource(overlay, *addr, addressbook.SourceHive); err != nil {
+		t.Fatal(err)
+	}
+	// the source is recorded only once
+	if err := book.PutSource(overlay, *addr, addressbook.SourceManual); err != nil {
+		t.Fatal(err)
+	}
+	if err := book.Failed(overlay); err != nil {
+		t.Fatal(err)
+	}
+	if err := book.Failed(overlay); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := book.Entry(overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Source != addressbook.SourceHive {
+		t.Fatalf("got source %s, want %s", e.Source, addressbook.SourceHive)
+	}
+	if e.Failures != 2 {
+		t.Fatalf("got %d failures, want 2", e.Failures)
+	}
+	if !e.Created.Equal(*now) || !e.LastSeen().Equal(*now) {
+		t.Fatalf("got created %s and last seen %s, want %s", e.Created, e.LastSeen(), *now)
+	}
+
+	// every put refreshes the last seen time
+	*now = now.Add(time.Hour)
+	if err := book.PutSource(overlay, *addr, addressbook.SourceHive); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err = book.Entry(overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.LastLearned.Equal(*now) || !e.LastSeen().Equal(*now) {
+		t.Fatalf("got last learned %s and last seen %s, want %s", e.LastLearned, e.LastSeen(), *now)
+	}
+	if !e.Created.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("got created %s, want %s", e.Created, now.Add(-time.Hour))
+	}
+
+	*now = now.Add(time.Hour)
+	if err := book.Connected(overlay, *addr); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err = book.Entry(overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Failures != 0 {
+		t.Fatalf("got %d failures, want 0", e.Failures)
+	}
+	if !e.LastConnected.Equal(*now) || !e.LastSeen().Equal(*now) {
+		t.Fatalf("got last connected %s and last seen %s, want %s", e.LastConnected, e.LastSeen(), *now)
+	}
+	if !e.Address.Equal(addr) {
+		t.Fatalf("got address %s, want %s", e.Address, addr)
+	}
+}
+
+func TestMigrateEntries(t *testing.T) {
+	now := setTimeNow(t, time.Unix(1000, 0))
+
+	store := mock.NewStateStore()
+	addr := newIfiAddress(t, infinity.NewAddress([]byte{0, 1, 2, 3}))
+
+	// the old format contains only the address
+	if err := store.Put("addressbook_entry_"+addr.Overlay.String(), addr); err != nil {
+		t.Fatal(err)
+	}
+
+	book := addressbook.New(store)
+	v, err := book.Get(addr.Overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Equal(addr) {
+		t.Fatalf("got address %s, want %s", v, addr)
+	}
+
+	if err := addressbook.MigrateEntries(store); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := book.Entry(addr.Overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Address.Equal(addr) || e.Source != addressbook.SourceUnknown || !e.Created.Equal(*now) {
+		t.Fatalf("got entry %+v, want address %s created at %s", e, addr, *now)
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	now := setTimeNow(t, time.Unix(1000, 0))
+
+	book := addressbook.New(mock.NewStateStore())
+	var (
+		stale     = newIfiAddress(t, infinity.NewAddress([]byte{0, 1, 2, 3}))
+		connected = newIfiAddress(t, infinity.NewAddress([]byte{0, 1, 2, 4}))
+		recent    = newIfiAddress(t, infinity.NewAddress([]byte{0, 1, 2, 5}))
+		relearned = newIfiAddress(t, infinity.NewAddress([]byte{0, 1, 2, 6}))
+	)
+	for _, a := range []*ifi.Address{stale, connected, relearned} {
+		if err := book.Put(a.Overlay, *a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	*now = now.Add(2 * time.Hour)
+	for _, a := range []*ifi.Address{recent, relearned} {
+		if err := book.Put(a.Overlay, *a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	peerer := p2pmock.New(p2pmock.WithPeersFunc(func() []p2p.Peer {
+		return []p2p.Peer{{Address: connected.Overlay}}
+	}))
+	janitor := addressbook.NewJanitor(book, peerer, logging.New(ioutil.Discard, 0), addressbook.JanitorOptions{
+		Expiry: time.Hour,
+	})
+	defer janitor.Close()
+
+	removed, err := janitor.Clean()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Fatalf("got %d removed entries, want 1", removed)
+	}
+
+	if _, err := book.Get(stale.Overlay); !errors.Is(err, addressbook.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, addressbook.ErrNotFound)
+	}
+	for _, a := range []*ifi.Address{connected, recent, relearned} {
+		if _, err := book.Get(a.Overlay); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newIfiAddress(t *testing.T, overlay infinity.Address) *ifi.Address {
+	t.Helper()
+
+	underlay, err := ma.NewMultiaddr("/ip4/1.1.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := crypto.GenerateSecp256k1Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := ifi.NewAddress(crypto.NewDefaultSigner(pk), underlay, overlay, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+// setTimeNow replaces the time of the addressbook with the returned time
+// until the end of the test.
+func setTimeNow(t *testing.T, start time.Time) *time.Time {
+	t.Helper()
+
+	now := start
+	timeNow := *addressbook.TimeNow
+	t.Cleanup(func() { *addressbook.TimeNow = timeNow })
+	*addressbook.TimeNow = func() time.Time { return now }
+	return &now
+}
@@ -8,6 +8,9 @@ and ifi.Address address, which contains underlay (physical) address.
 The underlay address contains both physical and p2p addresses.
 
 It is single point of truth about known peers and relations of their
-overlay and underlay addresses.
+overlay and underlay addresses. Every entry also records where the
+address was learned from, when the peer was last connected and the number of
+the failed connection attempts since, which the Janitor uses to remove the
+entries of the peers not seen for a long time.
 */
 package addressbook
@@ -0,0 +1,133 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package addressbook
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/ifi"
+)
+
+// Source is where the address of the peer was learned from.
+type Source uint8
+
+const (
+	// SourceUnknown is the source of the peers connected on their own and of
+	// the entries migrated from the old format.
+	SourceUnknown Source = iota
+	// SourceHive is the source of the peers gossiped by the other peers.
+	SourceHive
+	// SourceBootnode is the source of the configured bootnodes.
+	SourceBootnode
+	// SourceManual is the source of the peers connected through the debug
+	// api.
+	SourceManual
+)
+
+var sourceNames = map[Source]string{
+	SourceUnknown:  "unknown",
+	SourceHive:     "hive",
+	SourceBootnode: "bootnode",
+	SourceManual:   "manual",
+}
+
+func (s Source) String() string {
+	if n, ok := sourceNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("source(%d)", uint8(s))
+}
+
+func (s Source) MarshalText() ([]byte, error) {
+	if _, ok := sourceNames[s]; !ok {
+		return nil, fmt.Errorf("addressbook: invalid source %d", uint8(s))
+	}
+	return []byte(s.String()), nil
+}
+
+func (s *Source) UnmarshalText(b []byte) error {
+	for source, name := range sourceNames {
+		if name == string(b) {
+			*s = source
+			return nil
+		}
+	}
+	return fmt.Errorf("addressbook: invalid source %q", b)
+}
+
+// Entry is the address of the peer with its liveness metadata.
+type Entry struct {
+	Address ifi.Address
+	Source  Source
+	// Created is the time the entry was first saved.
+	Created time.Time
+	// LastLearned is the time the address was last saved, either learned
+	// from its source or connected.
+	LastLearned time.Time
+	// LastConnected is the time of the last successful connection to the
+	// peer, zero if the peer was never connected.
+	LastConnected time.Time
+	// Failures is the number of the failed connection attempts since the
+	// last successful connection.
+	Failures int
+}
+
+// LastSeen returns the latest of the times the entry was created, the address
+// was learned and the peer was connected.
+func (e *Entry) LastSeen() time.Time {
+	t := e.Created
+	if e.LastLearned.After(t) {
+		t = e.LastLearned
+	}
+	if e.LastConnected.After(t) {
+		t = e.LastConnected
+	}
+	return t
+}
+
+type entryJSON struct {
+	Address       *ifi.Address `json:"address"`
+	Source        Source       `json:"source"`
+	Created       time.Time    `json:"created"`
+	LastLearned   time.Time    `json:"lastLearned"`
+	LastConnected time.Time    `json:"lastConnected"`
+	Failures      int          `json:"failures"`
+}
+
+func (e *Entry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&entryJSON{
+		Address:       &e.Address,
+		Source:        e.Source,
+		Created:       e.Created,
+		LastLearned:   e.LastLearned,
+		LastConnected: e.LastConnected,
+		Failures:      e.Failures,
+	})
+}
+
+// UnmarshalJSON decodes the entry, also from the old format which contained
+// only the ifi.Address, without the metadata.
+func (e *Entry) UnmarshalJSON(b []byte) error {
+	v := &entryJSON{}
+	if err := json.Unmarshal(b, v); err != nil {
+		return err
+	}
+	if v.Address == nil {
+		*e = Entry{}
+		return e.Address.UnmarshalJSON(b)
+	}
+
+	*e = Entry{
+		Address:       *v.Address,
+		Source:        v.Source,
+		Created:       v.Created,
+		LastLearned:   v.LastLearned,
+		LastConnected: v.LastConnected,
+		Failures:      v.Failures,
+	}
+	return nil
+}
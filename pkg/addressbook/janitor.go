@@ -0,0 +1,128 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package addressbook
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	m "github.com/yanhuangpai/voyager/pkg/metrics"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+)
+
+const defaultCleanupInterval = time.Hour
+
+// Peerer returns the connected peers.
+type Peerer interface {
+	Peers() []p2p.Peer
+}
+
+// JanitorOptions are the addressbook janitor options.
+type JanitorOptions struct {
+	// Expiry is the period after which the entries which were not seen are
+	// removed.
+	Expiry time.Duration
+	// Interval is the time between the cleanups, defaultCleanupInterval if
+	// not set.
+	Interval time.Duration
+}
+
+// Janitor periodically removes the addressbook entries of the peers which were
+// neither connected nor learned within the expiry period. Currently connected
+// peers are never removed.
+type Janitor struct {
+	book     Interface
+	peerer   Peerer
+	logger   logging.Logger
+	metrics  metrics
+	expiry   time.Duration
+	interval time.Duration
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewJanitor creates a new addressbook janitor.
+func NewJanitor(book Interface, peerer Peerer, logger logging.Logger, o JanitorOptions) *Janitor {
+	if o.Interval <= 0 {
+		o.Interval = defaultCleanupInterval
+	}
+	return &Janitor{
+		book:     book,
+		peerer:   peerer,
+		logger:   logger,
+		metrics:  newMetrics(),
+		expiry:   o.Expiry,
+		interval: o.Interval,
+		quit:     make(chan struct{}),
+	}
+}
+
+// Start starts the periodic cleanups.
+func (j *Janitor) Start() {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-j.quit:
+				return
+			case <-ticker.C:
+			}
+
+			if _, err := j.Clean(); err != nil {
+				j.logger.Debugf("addressbook janitor: clean: %v", err)
+				j.logger.Error("addressbook janitor: unable to remove expired entries")
+			}
+		}
+	}()
+}
+
+// Clean removes the expired entries and returns their number.
+func (j *Janitor) Clean() (removed int, err error) {
+	entries, err := j.book.Entries()
+	if err != nil {
+		return 0, err
+	}
+
+	connected := make(map[string]struct{})
+	for _, p := range j.peerer.Peers() {
+		connected[p.Address.ByteString()] = struct{}{}
+	}
+
+	cutoff := timeNow().Add(-j.expiry)
+	for _, e := range entries {
+		if !e.LastSeen().Before(cutoff) {
+			continue
+		}
+		if _, ok := connected[e.Address.Overlay.ByteString()]; ok {
+			continue
+		}
+		if err := j.book.Remove(e.Address.Overlay); err != nil {
+			return removed, err
+		}
+		j.logger.Tracef("addressbook janitor: removed expired peer %s", e.Address.Overlay)
+		removed++
+	}
+	j.metrics.ExpiredEntries.Add(float64(removed))
+	return removed, nil
+}
+
+func (j *Janitor) Metrics() []prometheus.Collector {
+	return m.PrometheusCollectorsFromFields(j.metrics)
+}
+
+// Close stops the cleanups.
+func (j *Janitor) Close() error {
+	close(j.quit)
+	j.wg.Wait()
+	return nil
+}
@@ -0,0 +1,27 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package addressbook
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	m "github.com/yanhuangpai/voyager/pkg/metrics"
+)
+
+type metrics struct {
+	ExpiredEntries prometheus.Counter
+}
+
+func newMetrics() metrics {
+	subsystem := "addressbook"
+
+	return metrics{
+		ExpiredEntries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "expired_entries_count",
+			Help:      "Number of the entries removed by the janitor as not seen within the expiry period.",
+		}),
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package addressbook
+
+import (
+	"fmt"
+
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+// MigrateEntries rewrites the entries saved in the old format, which contained
+// only the ifi.Address, to the format with the liveness metadata. Migrated
+// entries are created at the time of the migration, so that they are not
+// expired before the peers get a chance to be connected.
+func MigrateEntries(s storage.StateStorer) error {
+	migrated := make(map[string]*Entry)
+	err := s.Iterate(keyPrefix, func(key, value []byte) (stop bool, err error) {
+		e := &Entry{}
+		if err := e.UnmarshalJSON(value); err != nil {
+			return true, fmt.Errorf("entry %s: %w", key, err)
+		}
+		if e.Created.IsZero() {
+			migrated[string(key)] = e
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	now := timeNow()
+	for key, e := range migrated {
+		e.Created = now
+		if err := s.Put(key, e); err != nil {
+			return fmt.Errorf("entry %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -7,23 +7,38 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 	"unicode/utf8"
 
+	"github.com/gorilla/mux"
+
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/apikey"
 	"github.com/yanhuangpai/voyager/pkg/cpc"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/features"
 	"github.com/yanhuangpai/voyager/pkg/feeds"
+	"github.com/yanhuangpai/voyager/pkg/file/pipeline"
 	"github.com/yanhuangpai/voyager/pkg/file/pipeline/builder"
+	"github.com/yanhuangpai/voyager/pkg/file/redundancy"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/logging/httpaccess"
 	m "github.com/yanhuangpai/voyager/pkg/metrics"
 	"github.com/yanhuangpai/voyager/pkg/pss"
 	"github.com/yanhuangpai/voyager/pkg/resolver"
@@ -34,30 +49,32 @@ import (
 )
 
 const (
-	InfinityPinHeader           = "Infinity-Pin"
-	InfinityTagHeader           = "Infinity-Tag"
-	InfinityEncryptHeader       = "Infinity-Encrypt"
-	InfinityIndexDocumentHeader = "Infinity-Index-Document"
-	InfinityErrorDocumentHeader = "Infinity-Error-Document"
-	InfinityFeedIndexHeader     = "Infinity-Feed-Index"
-	InfinityFeedIndexNextHeader = "Infinity-Feed-Index-Next"
-)
-
-// The size of buffer used for prefetching content with Langos.
-// Warning: This value influences the number of chunk requests and chunker join goroutines
-// per file request.
-// Recommended value is 8 or 16 times the io.Copy default buffer value which is 32kB, depending
-// on the file size. Use lookaheadBufferSize() to get the correct buffer size for the request.
-const (
-	smallFileBufferSize = 8 * 32 * 1024
-	largeFileBufferSize = 16 * 32 * 1024
-
-	largeBufferFilesizeThreshold = 10 * 1000000 // ten megs
+	InfinityPinHeader             = "Infinity-Pin"
+	InfinityTagHeader             = "Infinity-Tag"
+	InfinityEncryptHeader         = "Infinity-Encrypt"
+	InfinityIndexDocumentHeader   = "Infinity-Index-Document"
+	InfinityErrorDocumentHeader   = "Infinity-Error-Document"
+	InfinityFeedIndexHeader       = "Infinity-Feed-Index"
+	InfinityFeedIndexNextHeader   = "Infinity-Feed-Index-Next"
+	InfinityContentSha256Header   = "Infinity-Content-Sha256"
+	InfinityResumeTokenHeader     = "Infinity-Resume-Token"
+	InfinityFeedKeyHeader         = "Infinity-Feed-Key"
+	InfinitySocSignatureHeader    = "Infinity-Soc-Signature"
+	InfinityManifestEntryHeader   = "Infinity-Manifest-Entry"
+	InfinityRedundancyLevelHeader = "Infinity-Redundancy-Level"
+	InfinityIfNoneExistsHeader    = "Infinity-If-None-Exists"
+	InfinityCostHeader            = "Infinity-Cost"
+	InfinityCostChunksHeader      = "Infinity-Cost-Chunks"
+	InfinityTimeoutHeader         = "Infinity-Timeout"
 )
 
 var (
 	errInvalidNameOrAddress = errors.New("invalid name or ifi address")
 	errNoResolver           = errors.New("no resolver connected")
+	errInvalidChecksum      = errors.New("invalid content checksum")
+	errInvalidRedundancy    = errors.New("invalid redundancy level")
+	errEncryptedRedundancy  = errors.New("redundancy is not supported for encrypted content")
+	errRedundancyDisabled   = errors.New("redundancy is disabled on this node")
 )
 
 // Service is the API service interface.
@@ -65,6 +82,14 @@ type Service interface {
 	http.Handler
 	m.Collector
 	io.Closer
+	Routes() ([]jsonhttp.Route, error)
+	// Drain rejects all new requests and waits for the requests in flight
+	// to finish or the context to be done.
+	Drain(ctx context.Context) error
+	// SetCORSAllowedOrigins changes the allowed CORS origins at runtime.
+	SetCORSAllowedOrigins(origins []string)
+	// SetGatewayMode enables or disables the gateway mode at runtime.
+	SetGatewayMode(enabled bool)
 }
 
 type server struct {
@@ -76,19 +101,54 @@ type server struct {
 	logger      logging.Logger
 	tracer      *tracing.Tracer
 	feedFactory feeds.Factory
+	signer      crypto.Signer
 	Options
 	http.Handler
-	metrics metrics
+	router   *mux.Router
+	metrics  metrics
+	resume   *resumeTokens
+	listings *manifestListings // entries of the listed manifests kept for the following pages
 
 	wsWg sync.WaitGroup // wait for all websockets to close on exit
 	quit chan struct{}
 	flg  *cpc.InterruptFlag
+
+	prefetchWg  sync.WaitGroup // wait for the index document prefetches on exit
+	prefetchSem chan struct{}  // limits the concurrent index document prefetches
+
+	drainMu  sync.Mutex
+	draining bool           // new requests are rejected
+	inflight sync.WaitGroup // requests being served
+
+	optionsMu sync.RWMutex // protects the options changed at runtime
+
+	gatewayLimiter *ipRateLimiter // nil if the gateway rate limit is disabled
 }
 
 type Options struct {
 	CORSAllowedOrigins []string
 	GatewayMode        bool
 	WsPingPeriod       time.Duration
+	Compression        bool
+	MaxUploadSize      int64                   // maximal size of the uploaded data in bytes, 0 disables the limit
+	MaxImportSize      int64                   // maximal size of the imported pin archives in bytes, 0 disables the limit
+	UploadReadTimeout  time.Duration           // maximal wait for the next part of an upload, 0 disables the timeout
+	WriteTimeout       time.Duration           // maximal wait for a client to receive a websocket message or a part of a download
+	BodyCapture        *httpaccess.BodyCapture // sampling of the bodies logged in the access log, nil disables it
+	PrefetchChunks     int                     // number of the index document chunks prefetched on a collection root request, 0 disables it
+	Features           features.Checker        // experimental features, all allowed if not set
+	Pricer             accounting.Pricer       // prices of the chunks paid to the peers, the prices are not available if not set
+
+	// policies applied only in the gateway mode
+	GatewayRateLimit        float64  // requests per second per client IP address, 0 disables the limit
+	GatewayRateBurst        int      // requests over the rate allowed at once per client IP address, the rate if not set
+	GatewayMaxUploadSize    int64    // maximal size of the request body in bytes, 0 disables the limit
+	GatewayAllowedEndpoints []string // paths of the allowed endpoints and the endpoints under them, all if empty
+
+	// GatewayAPIKeys are the keys required with the requests in the gateway
+	// mode, with the daily quotas of the requests. The keys are not required
+	// if it is not set.
+	GatewayAPIKeys *apikey.Service
 }
 
 const (
@@ -97,7 +157,7 @@ const (
 )
 
 // New will create a and initialize a new API service.
-func New(tags *tags.Tags, storer storage.Storer, resolver resolver.Interface, pss pss.Interface, traversalService traversal.Service, feedFactory feeds.Factory, logger logging.Logger, tracer *tracing.Tracer, o Options, flg *cpc.InterruptFlag) Service {
+func New(tags *tags.Tags, storer storage.Storer, resolver resolver.Interface, pss pss.Interface, traversalService traversal.Service, feedFactory feeds.Factory, signer crypto.Signer, logger logging.Logger, tracer *tracing.Tracer, o Options, flg *cpc.InterruptFlag) Service {
 	s := &server{
 		tags:        tags,
 		storer:      storer,
@@ -105,13 +165,23 @@ func New(tags *tags.Tags, storer storage.Storer, resolver resolver.Interface, ps
 		pss:         pss,
 		traversal:   traversalService,
 		feedFactory: feedFactory,
+		signer:      signer,
 		Options:     o,
 		logger:      logger,
 		tracer:      tracer,
 		metrics:     newMetrics(),
 		quit:        make(chan struct{}),
+		resume:      newResumeTokens(),
+		listings:    newManifestListings(),
+		prefetchSem: make(chan struct{}, maxIndexPrefetches),
 		flg:         flg,
 	}
+	if s.WriteTimeout <= 0 {
+		s.WriteTimeout = writeDeadline
+	}
+	if s.GatewayRateLimit > 0 {
+		s.gatewayLimiter = newIPRateLimiter(s.GatewayRateLimit, s.GatewayRateBurst)
+	}
 
 	s.setupRouting()
 
@@ -127,6 +197,7 @@ func (s *server) Close() error {
 	go func() {
 		defer close(done)
 		s.wsWg.Wait()
+		s.prefetchWg.Wait()
 	}()
 
 	select {
@@ -199,6 +270,68 @@ func requestEncrypt(r *http.Request) bool {
 	return strings.ToLower(r.Header.Get(InfinityEncryptHeader)) == "true"
 }
 
+// requestContentChecksum returns the expected SHA-256 digest of the uploaded
+// content if it is set in the request headers, or nil otherwise.
+func requestContentChecksum(r *http.Request) ([]byte, error) {
+	h := r.Header.Get(InfinityContentSha256Header)
+	if h == "" {
+		return nil, nil
+	}
+	checksum, err := hex.DecodeString(h)
+	if err != nil || len(checksum) != sha256.Size {
+		return nil, errInvalidChecksum
+	}
+	return checksum, nil
+}
+
+// verifiedContent copies the uploaded content to a temporary file and verifies
+// that its SHA-256 digest matches the checksum, so that no chunk of the content
+// is stored before it is verified. It returns the file positioned at the start
+// of the content and the size of the content, the caller closes and removes the
+// file.
+func verifiedContent(r io.Reader, checksum []byte) (*os.File, int64, error) {
+	tmp, err := ioutil.TempFile("", "voyager-checksum")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err == nil && !bytes.Equal(h.Sum(nil), checksum) {
+		err = builder.ErrChecksumMismatch
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return nil, 0, err
+	}
+	return tmp, size, nil
+}
+
+// requestRedundancyLevel returns the redundancy level of the uploaded content
+// set in the request headers. Redundancy is not supported together with
+// encryption or if the erasure coding feature is disabled.
+func (s *server) requestRedundancyLevel(r *http.Request) (redundancy.Level, error) {
+	h := r.Header.Get(InfinityRedundancyLevelHeader)
+	if h == "" {
+		return redundancy.None, nil
+	}
+	level, err := redundancy.ParseLevel(h)
+	if err != nil {
+		return redundancy.None, errInvalidRedundancy
+	}
+	if level != redundancy.None && s.Features != nil && !s.Features.Enabled(features.ErasureCoding) {
+		return redundancy.None, errRedundancyDisabled
+	}
+	if level != redundancy.None && requestEncrypt(r) {
+		return redundancy.None, errEncryptedRedundancy
+	}
+	return level, nil
+}
+
 func (s *server) newTracingHandler(spanName string) func(h http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -222,11 +355,30 @@ func (s *server) newTracingHandler(spanName string) func(h http.Handler) http.Ha
 	}
 }
 
-func lookaheadBufferSize(size int64) int {
-	if size <= largeBufferFilesizeThreshold {
-		return smallFileBufferSize
-	}
-	return largeFileBufferSize
+// SetCORSAllowedOrigins changes the allowed CORS origins at runtime.
+func (s *server) SetCORSAllowedOrigins(origins []string) {
+	s.optionsMu.Lock()
+	defer s.optionsMu.Unlock()
+	s.CORSAllowedOrigins = origins
+}
+
+// SetGatewayMode enables or disables the gateway mode at runtime.
+func (s *server) SetGatewayMode(enabled bool) {
+	s.optionsMu.Lock()
+	defer s.optionsMu.Unlock()
+	s.GatewayMode = enabled
+}
+
+func (s *server) corsAllowedOrigins() []string {
+	s.optionsMu.RLock()
+	defer s.optionsMu.RUnlock()
+	return s.CORSAllowedOrigins
+}
+
+func (s *server) gatewayMode() bool {
+	s.optionsMu.RLock()
+	defer s.optionsMu.RUnlock()
+	return s.GatewayMode
 }
 
 // checkOrigin returns true if the origin is not set or is equal to the request host.
@@ -239,7 +391,7 @@ func (s *server) checkOrigin(r *http.Request) bool {
 	if r.TLS != nil {
 		scheme = "https"
 	}
-	hosts := append(s.CORSAllowedOrigins, scheme+"://"+r.Host)
+	hosts := append(s.corsAllowedOrigins(), scheme+"://"+r.Host)
 	for _, v := range hosts {
 		if equalASCIIFold(origin[0], v) || v == "*" {
 			return true
@@ -275,14 +427,23 @@ func equalASCIIFold(s, t string) bool {
 
 type pipelineFunc func(context.Context, io.Reader, int64) (infinity.Address, error)
 
-func requestPipelineFn(s storage.Storer, r *http.Request) pipelineFunc {
+func requestPipelineFn(s storage.Storer, r *http.Request, level redundancy.Level) pipelineFunc {
 	mode, encrypt := requestModePut(r), requestEncrypt(r)
 	return func(ctx context.Context, r io.Reader, l int64) (infinity.Address, error) {
-		pipe := builder.NewPipelineBuilder(ctx, s, mode, encrypt)
+		pipe := newPipeline(ctx, s, mode, encrypt, level)
 		return builder.FeedPipeline(ctx, pipe, r, l)
 	}
 }
 
+// newPipeline returns the pipeline for the encryption or the redundancy
+// level requested by the upload.
+func newPipeline(ctx context.Context, s storage.Putter, mode storage.ModePut, encrypt bool, level redundancy.Level) pipeline.Interface {
+	if level != redundancy.None {
+		return builder.NewRedundantPipelineBuilder(ctx, s, mode, level)
+	}
+	return builder.NewPipelineBuilder(ctx, s, mode, encrypt)
+}
+
 // calculateNumberOfChunks calculates the number of chunks in an arbitrary
 // content length.
 func calculateNumberOfChunks(contentLength int64, isEncrypted bool) int64 {
@@ -312,3 +473,14 @@ func requestCalculateNumberOfChunks(r *http.Request) int64 {
 	}
 	return 0
 }
+
+// handleQuotaError responds with the insufficient storage status if the error
+// is caused by an exceeded storage quota of the pinned or the uploaded chunks
+// and reports if it did.
+func handleQuotaError(err error, w http.ResponseWriter) bool {
+	if !errors.Is(err, storage.ErrQuotaExceeded) {
+		return false
+	}
+	jsonhttp.InsufficientStorage(w, "storage quota exceeded")
+	return true
+}
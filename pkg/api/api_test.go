@@ -15,7 +15,10 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/yanhuangpai/voyager/pkg/accounting"
 	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/features"
 	"github.com/yanhuangpai/voyager/pkg/feeds"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/logging"
@@ -40,7 +43,15 @@ type testServerOptions struct {
 	Logger             logging.Logger
 	PreventRedirect    bool
 	Feeds              feeds.Factory
+	Signer             crypto.Signer
 	CORSAllowedOrigins []string
+	Compression        bool
+	MaxUploadSize      int64
+	MaxImportSize      int64
+	UploadReadTimeout  time.Duration
+	PrefetchChunks     int
+	Features           features.Checker
+	Pricer             accounting.Pricer
 }
 
 func newTestServer(t *testing.T, o testServerOptions) (*http.Client, *websocket.Conn, string) {
@@ -53,12 +64,21 @@ func newTestServer(t *testing.T, o testServerOptions) (*http.Client, *websocket.
 	if o.WsPingPeriod == 0 {
 		o.WsPingPeriod = 60 * time.Second
 	}
-	s := api.New(o.Tags, o.Storer, o.Resolver, o.Pss, o.Traversal, o.Feeds, o.Logger, nil, api.Options{
+	s := api.New(o.Tags, o.Storer, o.Resolver, o.Pss, o.Traversal, o.Feeds, o.Signer, o.Logger, nil, api.Options{
 		CORSAllowedOrigins: o.CORSAllowedOrigins,
 		GatewayMode:        o.GatewayMode,
 		WsPingPeriod:       o.WsPingPeriod,
+		Compression:        o.Compression,
+		MaxUploadSize:      o.MaxUploadSize,
+		MaxImportSize:      o.MaxImportSize,
+		UploadReadTimeout:  o.UploadReadTimeout,
+		PrefetchChunks:     o.PrefetchChunks,
+		Features:           o.Features,
+		Pricer:             o.Pricer,
 	})
-	ts := httptest.NewServer(s)
+	ts := httptest.NewUnstartedServer(s)
+	ts.Config.ConnContext = api.ConnContext
+	ts.Start()
 	t.Cleanup(ts.Close)
 
 	var (
@@ -171,7 +191,7 @@ func TestParseName(t *testing.T) {
 				}))
 		}
 
-		s := api.New(nil, nil, tC.res, nil, nil, nil, tC.log, nil, api.Options{}).(*api.Server)
+		s := api.New(nil, nil, tC.res, nil, nil, nil, nil, tC.log, nil, api.Options{}).(*api.Server)
 
 		t.Run(tC.desc, func(t *testing.T) {
 			got, err := s.ResolveNameOrAddress(tC.name)
@@ -0,0 +1,160 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/apikey"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+// apiKeyHandler requires the requests in the gateway mode to be authorized
+// with an API key in the Authorization header as a bearer token, if the API
+// keys are enabled. The requests over the daily quota of the key are rejected
+// with the too many requests status until the quota is reset, and the bytes
+// of the request and the response bodies and of the websocket connections are
+// accounted to the key as they are transferred. The CORS preflight requests
+// are not authorized, as the browsers send them without the credentials.
+func (s *server) apiKeyHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.GatewayAPIKeys == nil || !s.gatewayMode() || r.Method == http.MethodOptions {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		secret := bearerToken(r)
+		if secret == "" {
+			s.logger.Tracef("gateway mode: missing api key %s", r.URL.String())
+			s.metrics.GatewayRejectedCount.WithLabelValues(gatewayRejectAPIKey).Inc()
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			jsonhttp.Unauthorized(w, "missing api key")
+			return
+		}
+
+		id, err := s.GatewayAPIKeys.Authorize(secret, r.ContentLength)
+		if err != nil {
+			switch {
+			case errors.Is(err, apikey.ErrNotFound):
+				s.logger.Tracef("gateway mode: invalid api key %s", r.URL.String())
+				s.metrics.GatewayRejectedCount.WithLabelValues(gatewayRejectAPIKey).Inc()
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				jsonhttp.Unauthorized(w, "invalid api key")
+			case errors.Is(err, apikey.ErrQuotaExceeded):
+				s.logger.Tracef("gateway mode: api key quota exceeded %s", r.URL.String())
+				s.metrics.GatewayRejectedCount.WithLabelValues(gatewayRejectQuota).Inc()
+				retry := int(time.Until(apikey.Reset()).Seconds()) + 1
+				w.Header().Set("Retry-After", strconv.Itoa(retry))
+				jsonhttp.TooManyRequests(w, "api key quota exceeded")
+			default:
+				s.logger.Debugf("gateway mode: authorize api key: %v", err)
+				s.logger.Error("gateway mode: cannot authorize api key")
+				jsonhttp.InternalServerError(w, nil)
+			}
+			return
+		}
+
+		account := func(n int) error {
+			if n <= 0 {
+				return nil
+			}
+			return s.GatewayAPIKeys.Account(id, uint64(n))
+		}
+		if r.Body != nil {
+			r.Body = &apiKeyReadCloser{ReadCloser: r.Body, account: account}
+		}
+
+		h.ServeHTTP(&apiKeyResponseWriter{ResponseWriter: w, account: account}, r)
+	})
+}
+
+// bearerToken returns the bearer token of the Authorization header of the
+// request, empty if there is none.
+func bearerToken(r *http.Request) string {
+	const prefix = "bearer "
+	a := r.Header.Get("Authorization")
+	if len(a) < len(prefix) || !strings.EqualFold(a[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(a[len(prefix):])
+}
+
+// apiKeyReadCloser accounts the read request body bytes. The request is not
+// stopped once the quota is used up, as its size is checked when it is
+// authorized.
+type apiKeyReadCloser struct {
+	io.ReadCloser
+	account func(n int) error
+}
+
+func (r *apiKeyReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	_ = r.account(n)
+	return n, err
+}
+
+// apiKeyResponseWriter accounts the written response body bytes. It keeps the
+// response writer flushable and hijackable, as it wraps the responses of all
+// endpoints, including the websockets. The response is not stopped once the
+// quota is used up, but the next requests with the key are rejected.
+type apiKeyResponseWriter struct {
+	http.ResponseWriter
+	account func(n int) error
+}
+
+func (w *apiKeyResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	_ = w.account(n)
+	return n, err
+}
+
+func (w *apiKeyResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *apiKeyResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer is not a hijacker")
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &apiKeyConn{Conn: conn, account: w.account}, rw, nil
+}
+
+// apiKeyConn accounts the bytes read and written over the hijacked
+// connection, as of a websocket. As the connection is not bounded, its reads
+// and writes fail once the quota is used up.
+type apiKeyConn struct {
+	net.Conn
+	account func(n int) error
+}
+
+func (c *apiKeyConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if aerr := c.account(n); aerr != nil && err == nil {
+		err = aerr
+	}
+	return n, err
+}
+
+func (c *apiKeyConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if aerr := c.account(n); aerr != nil && err == nil {
+		err = aerr
+	}
+	return n, err
+}
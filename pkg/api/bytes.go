@@ -5,8 +5,11 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 
 	"github.com/gorilla/mux"
 	"github.com/yanhuangpai/voyager/pkg/file/pipeline/builder"
@@ -25,6 +28,67 @@ type bytesPostResponse struct {
 func (s *server) bytesUploadHandler(w http.ResponseWriter, r *http.Request) {
 	logger := tracing.NewLoggerWithTraceID(r.Context(), s.logger)
 
+	checksum, err := requestContentChecksum(r)
+	if err != nil {
+		logger.Debugf("bytes upload: parse checksum: %v", err)
+		logger.Error("bytes upload: parse checksum")
+		jsonhttp.BadRequest(w, "invalid checksum")
+		return
+	}
+
+	level, err := s.requestRedundancyLevel(r)
+	if err != nil {
+		logger.Debugf("bytes upload: parse redundancy level: %v", err)
+		logger.Error("bytes upload: parse redundancy level")
+		jsonhttp.BadRequest(w, err)
+		return
+	}
+
+	// the existence is checked before the data is read
+	reference, network, err := requestIfNoneExists(r)
+	if err != nil {
+		logger.Debugf("bytes upload: parse if none exists: %v", err)
+		logger.Error("bytes upload: parse if none exists")
+		jsonhttp.BadRequest(w, "invalid if none exists reference")
+		return
+	}
+	if !reference.IsZero() {
+		exists, err := s.referenceExists(r.Context(), reference, network)
+		if err != nil {
+			logger.Debugf("bytes upload: check existence %s: %v", reference, err)
+			logger.Error("bytes upload: check existence")
+			jsonhttp.InternalServerError(w, "check existence")
+			return
+		}
+		if exists {
+			jsonhttp.Conflict(w, bytesPostResponse{Reference: reference})
+			return
+		}
+	}
+
+	body, contentLength := io.Reader(r.Body), r.ContentLength
+	if checksum != nil {
+		f, size, err := verifiedContent(r.Body, checksum)
+		if err != nil {
+			if errors.Is(err, builder.ErrChecksumMismatch) {
+				logger.Debugf("bytes upload: verify checksum: %v", err)
+				logger.Error("bytes upload: checksum mismatch")
+				jsonhttp.BadRequest(w, "checksum mismatch")
+				return
+			}
+			if jsonhttp.HandleBodyReadError(err, w) {
+				return
+			}
+			logger.Debugf("bytes upload: verify checksum: %v", err)
+			logger.Error("bytes upload: verify checksum")
+			jsonhttp.InternalServerError(w, nil)
+			return
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		body, contentLength = f, size
+	}
+
 	tag, created, err := s.getOrCreateTag(r.Header.Get(InfinityTagHeader))
 	if err != nil {
 		logger.Debugf("bytes upload: get or create tag: %v", err)
@@ -49,11 +113,17 @@ func (s *server) bytesUploadHandler(w http.ResponseWriter, r *http.Request) {
 	// Add the tag to the context
 	ctx := sctx.SetTag(r.Context(), tag)
 
-	pipe := builder.NewPipelineBuilder(ctx, s.storer, requestModePut(r), requestEncrypt(r))
-	address, err := builder.FeedPipeline(ctx, pipe, r.Body, r.ContentLength)
+	pipe := newPipeline(ctx, s.storer, requestModePut(r), requestEncrypt(r), level)
+	address, err := builder.FeedPipeline(ctx, pipe, body, contentLength)
 	if err != nil {
+		if jsonhttp.HandleBodyReadError(err, w) {
+			return
+		}
 		logger.Debugf("bytes upload: split write all: %v", err)
 		logger.Error("bytes upload: split write all")
+		if handleQuotaError(err, w) {
+			return
+		}
 		jsonhttp.InternalServerError(w, nil)
 		return
 	}
@@ -90,5 +160,5 @@ func (s *server) bytesGetHandler(w http.ResponseWriter, r *http.Request) {
 		"Content-Type": {"application/octet-stream"},
 	}
 
-	s.downloadHandler(w, r, address, additionalHeaders, true)
+	s.downloadHandler(w, r, address, additionalHeaders, referenceETag(address))
 }
@@ -6,6 +6,9 @@ package api_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io/ioutil"
 	"net/http"
 	"testing"
@@ -13,10 +16,13 @@ import (
 	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
 
 	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/features"
+	"github.com/yanhuangpai/voyager/pkg/file/pipeline/builder"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
 	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/storage"
 	"github.com/yanhuangpai/voyager/pkg/storage/mock"
 	"github.com/yanhuangpai/voyager/pkg/tags"
 	mockbytes "gitlab.com/nolash/go-mockbytes"
@@ -53,6 +59,111 @@ func TestBytes(t *testing.T) {
 		)
 	})
 
+	t.Run("upload-with-checksum", func(t *testing.T) {
+		sum := sha256.Sum256(content)
+		jsonhttptest.Request(t, client, http.MethodPost, resource, http.StatusOK,
+			jsonhttptest.WithRequestBody(bytes.NewReader(content)),
+			jsonhttptest.WithRequestHeader(api.InfinityContentSha256Header, hex.EncodeToString(sum[:])),
+			jsonhttptest.WithExpectedJSONResponse(api.BytesPostResponse{
+				Reference: infinity.MustParseHexAddress(expHash),
+			}),
+		)
+	})
+
+	t.Run("upload-checksum-mismatch", func(t *testing.T) {
+		data := []byte("mismatched content")
+		sum := sha256.Sum256([]byte("other content"))
+		jsonhttptest.Request(t, client, http.MethodPost, resource, http.StatusBadRequest,
+			jsonhttptest.WithRequestBody(bytes.NewReader(data)),
+			jsonhttptest.WithRequestHeader(api.InfinityContentSha256Header, hex.EncodeToString(sum[:])),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "checksum mismatch",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+
+		// the content is not stored
+		ctx := context.Background()
+		pipe := builder.NewPipelineBuilder(ctx, mock.NewStorer(), storage.ModePutUpload, false)
+		address, err := builder.FeedPipeline(ctx, pipe, bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		has, err := mockStorer.Has(ctx, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has {
+			t.Fatal("chunk of the mismatched content stored")
+		}
+	})
+
+	t.Run("upload-invalid-checksum", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPost, resource, http.StatusBadRequest,
+			jsonhttptest.WithRequestBody(bytes.NewReader(content)),
+			jsonhttptest.WithRequestHeader(api.InfinityContentSha256Header, "abcd"),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "invalid checksum",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("upload-with-redundancy", func(t *testing.T) {
+		var resp api.BytesPostResponse
+		jsonhttptest.Request(t, client, http.MethodPost, resource, http.StatusOK,
+			jsonhttptest.WithRequestBody(bytes.NewReader(content)),
+			jsonhttptest.WithRequestHeader(api.InfinityRedundancyLevelHeader, "2"),
+			jsonhttptest.WithUnmarshalJSONResponse(&resp),
+		)
+		if resp.Reference.String() == expHash {
+			t.Fatal("got reference of the content without redundancy")
+		}
+
+		jsonhttptest.Request(t, client, http.MethodGet, resource+"/"+resp.Reference.String(), http.StatusOK,
+			jsonhttptest.WithExpectedResponse(content),
+		)
+	})
+
+	t.Run("upload-invalid-redundancy", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPost, resource, http.StatusBadRequest,
+			jsonhttptest.WithRequestBody(bytes.NewReader(content)),
+			jsonhttptest.WithRequestHeader(api.InfinityRedundancyLevelHeader, "5"),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "invalid redundancy level",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("upload-encrypted-redundancy", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPost, resource, http.StatusBadRequest,
+			jsonhttptest.WithRequestBody(bytes.NewReader(content)),
+			jsonhttptest.WithRequestHeader(api.InfinityRedundancyLevelHeader, "1"),
+			jsonhttptest.WithRequestHeader(api.InfinityEncryptHeader, "true"),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "redundancy is not supported for encrypted content",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("upload-redundancy-disabled", func(t *testing.T) {
+		client, _, _ := newTestServer(t, testServerOptions{
+			Storer:   mock.NewStorer(),
+			Tags:     tags.NewTags(statestore.NewStateStore(), logger),
+			Features: disabledFeatures{},
+		})
+		jsonhttptest.Request(t, client, http.MethodPost, resource, http.StatusBadRequest,
+			jsonhttptest.WithRequestBody(bytes.NewReader(content)),
+			jsonhttptest.WithRequestHeader(api.InfinityRedundancyLevelHeader, "1"),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "redundancy is disabled on this node",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
 	t.Run("download", func(t *testing.T) {
 		resp := request(t, client, http.MethodGet, resource+"/"+expHash, nil, http.StatusOK)
 		data, err := ioutil.ReadAll(resp.Body)
@@ -82,3 +193,10 @@ func TestBytes(t *testing.T) {
 		)
 	})
 }
+
+// disabledFeatures disables all experimental features.
+type disabledFeatures struct{}
+
+func (disabledFeatures) Enabled(features.Flag) bool {
+	return false
+}
@@ -34,6 +34,28 @@ func (s *server) chunkUploadHandler(w http.ResponseWriter, r *http.Request) {
 		err error
 	)
 
+	// the existence is checked before the chunk data is read
+	reference, network, err := requestIfNoneExists(r)
+	if err != nil {
+		s.logger.Debugf("chunk upload: parse if none exists: %v", err)
+		s.logger.Error("chunk upload: parse if none exists")
+		jsonhttp.BadRequest(w, "invalid if none exists reference")
+		return
+	}
+	if !reference.IsZero() {
+		exists, err := s.chunkExists(ctx, reference, network)
+		if err != nil {
+			s.logger.Debugf("chunk upload: check existence %s: %v", reference, err)
+			s.logger.Error("chunk upload: check existence")
+			jsonhttp.InternalServerError(w, "check existence")
+			return
+		}
+		if exists {
+			jsonhttp.Conflict(w, chunkAddressResponse{Reference: reference})
+			return
+		}
+	}
+
 	if h := r.Header.Get(InfinityTagHeader); h != "" {
 		tag, err = s.getTag(h)
 		if err != nil {
@@ -87,6 +109,9 @@ func (s *server) chunkUploadHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		s.logger.Debugf("chunk upload: chunk write error: %v, addr %s", err, chunk.Address())
 		s.logger.Error("chunk upload: chunk write error")
+		if handleQuotaError(err, w) {
+			return
+		}
 		jsonhttp.BadRequest(w, "chunk write error")
 		return
 	} else if len(seen) > 0 && seen[0] && tag != nil {
@@ -132,6 +157,11 @@ func (s *server) chunkGetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the content of the chunk address never changes
+	if checkPreconditions(w, r, referenceETag(address)) {
+		return
+	}
+
 	chunk, err := s.storer.Get(ctx, storage.ModeGetRequest, address)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
@@ -151,6 +181,7 @@ func (s *server) chunkGetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "binary/octet-stream")
+	w.Header().Set("ETag", referenceETag(address))
 	if targets != "" {
 		w.Header().Set(TargetsRecoveryHeader, targets)
 	}
@@ -0,0 +1,228 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/cac"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/sctx"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+const (
+	// chunkStreamLengthSize is the size of the length prefix of every chunk
+	// in a chunk stream message.
+	chunkStreamLengthSize = 4
+	// maxChunkStreamMessageSize is the maximal size of a chunk stream
+	// message, which fits 128 chunks with their length prefixes.
+	maxChunkStreamMessageSize = 128 * (chunkStreamLengthSize + infinity.ChunkWithSpanSize)
+)
+
+var errChunkStreamLength = errors.New("invalid chunk length")
+
+// chunkUploadStreamHandler upgrades the request to a websocket on which the
+// client sends the chunks in binary messages. Every message holds one or more
+// chunks, each prefixed with the length of its span and data as a 4 byte big
+// endian integer. For every stored chunk the address of the chunk is sent back
+// in a binary message, in the order the chunks were received. The connection
+// is closed with an error message on the first chunk which is not stored, or
+// which exceeds the maximal upload size with the chunks sent before it.
+func (s *server) chunkUploadStreamHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		tag *tags.Tag
+		err error
+	)
+
+	if h := r.Header.Get(InfinityTagHeader); h != "" {
+		tag, err = s.getTag(h)
+		if err != nil {
+			s.logger.Debugf("chunk stream: get tag: %v", err)
+			s.logger.Error("chunk stream: get tag")
+			jsonhttp.BadRequest(w, "cannot get tag")
+			return
+		}
+	}
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  infinity.ChunkSize,
+		WriteBufferSize: infinity.ChunkSize,
+		CheckOrigin:     s.checkOrigin,
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.Debugf("chunk stream: upgrade: %v", err)
+		s.logger.Error("chunk stream: cannot upgrade")
+		jsonhttp.InternalServerError(w, nil)
+		return
+	}
+
+	// the request context is canceled once the handler returns
+	ctx := context.Background()
+	if cost, ok := accounting.CostFrom(r.Context()); ok {
+		ctx = accounting.WithCost(ctx, cost)
+	}
+	if tag != nil {
+		ctx = sctx.SetTag(ctx, tag)
+	}
+
+	maxSize := s.MaxUploadSize
+	if limit := s.GatewayMaxUploadSize; s.gatewayMode() && limit > 0 && (maxSize <= 0 || limit < maxSize) {
+		maxSize = limit
+	}
+
+	s.wsWg.Add(1)
+	go s.handleUploadStream(ctx, conn, tag, requestModePut(r), maxSize)
+}
+
+// handleUploadStream stores the chunks sent on the connection until the
+// client closes it. The size of the spans and data of all chunks is limited
+// to maxSize bytes, 0 disables the limit.
+func (s *server) handleUploadStream(ctx context.Context, conn *websocket.Conn, tag *tags.Tag, mode storage.ModePut, maxSize int64) {
+	defer s.wsWg.Done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.quit:
+			// shutdown, the pending read is interrupted by closing the
+			// connection
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "node is shutting down"), time.Now().Add(s.WriteTimeout))
+			cancel()
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+	defer conn.Close()
+
+	closeWith := func(code int, text string) {
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(s.WriteTimeout))
+	}
+
+	conn.SetReadLimit(maxChunkStreamMessageSize)
+
+	var size int64
+	for {
+		if s.UploadReadTimeout > 0 {
+			if err := conn.SetReadDeadline(time.Now().Add(s.UploadReadTimeout)); err != nil {
+				s.logger.Debugf("chunk stream: set read deadline: %v", err)
+				return
+			}
+		}
+
+		mt, msg, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.logger.Debugf("chunk stream: read message: %v", err)
+			}
+			return
+		}
+		if mt != websocket.BinaryMessage {
+			s.logger.Debug("chunk stream: unexpected message type")
+			closeWith(websocket.CloseUnsupportedData, "binary messages expected")
+			return
+		}
+
+		for len(msg) > 0 {
+			data, rest, err := nextStreamChunk(msg)
+			if err != nil {
+				s.logger.Debugf("chunk stream: %v", err)
+				closeWith(websocket.CloseInvalidFramePayloadData, err.Error())
+				return
+			}
+			msg = rest
+
+			size += int64(len(data))
+			if maxSize > 0 && size > maxSize {
+				s.logger.Debugf("chunk stream: upload size exceeds %d bytes", maxSize)
+				closeWith(websocket.CloseMessageTooBig, "upload too large")
+				return
+			}
+
+			address, err := s.storeStreamChunk(ctx, tag, mode, data)
+			if err != nil {
+				s.logger.Debugf("chunk stream: %v", err)
+				s.logger.Error("chunk stream: cannot store chunk")
+				if errors.Is(err, storage.ErrQuotaExceeded) {
+					closeWith(websocket.CloseTryAgainLater, "storage quota exceeded")
+					return
+				}
+				closeWith(websocket.CloseInternalServerErr, "cannot store chunk")
+				return
+			}
+
+			if err := conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout)); err != nil {
+				s.logger.Debugf("chunk stream: set write deadline: %v", err)
+				return
+			}
+			if err := conn.WriteMessage(websocket.BinaryMessage, address.Bytes()); err != nil {
+				s.logger.Debugf("chunk stream: write ack: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// nextStreamChunk returns the span and data of the first length prefixed
+// chunk in the message and the rest of the message.
+func nextStreamChunk(msg []byte) (data, rest []byte, err error) {
+	if len(msg) < chunkStreamLengthSize {
+		return nil, nil, errChunkStreamLength
+	}
+	l := int(binary.BigEndian.Uint32(msg))
+	msg = msg[chunkStreamLengthSize:]
+	if l < infinity.SpanSize || l > infinity.ChunkWithSpanSize || l > len(msg) {
+		return nil, nil, errChunkStreamLength
+	}
+	return msg[:l], msg[l:], nil
+}
+
+// storeStreamChunk stores the chunk with the span and data, updating the tag
+// in the same way as the single chunk upload.
+func (s *server) storeStreamChunk(ctx context.Context, tag *tags.Tag, mode storage.ModePut, data []byte) (infinity.Address, error) {
+	if tag != nil {
+		if err := tag.Inc(tags.StateSplit); err != nil {
+			return infinity.ZeroAddress, fmt.Errorf("increment tag: %w", err)
+		}
+	}
+
+	chunk, err := cac.NewWithDataSpan(data)
+	if err != nil {
+		return infinity.ZeroAddress, fmt.Errorf("create chunk: %w", err)
+	}
+
+	seen, err := s.storer.Put(ctx, mode, chunk)
+	if err != nil {
+		return infinity.ZeroAddress, fmt.Errorf("chunk write: %w, addr %s", err, chunk.Address())
+	}
+
+	if tag != nil {
+		if len(seen) > 0 && seen[0] {
+			if err := tag.Inc(tags.StateSeen); err != nil {
+				return infinity.ZeroAddress, fmt.Errorf("increment tag: %w", err)
+			}
+		}
+		if err := tag.Inc(tags.StateStored); err != nil {
+			return infinity.ZeroAddress, fmt.Errorf("increment tag: %w", err)
+		}
+	}
+
+	return chunk.Address(), nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	testingc "github.com/yanhuangpai/voyager/pkg/storage/testing"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+func TestChunkUploadStream(t *testing.T) {
+	var (
+		mockStorer = mock.NewStorer()
+		tag        = tags.NewTags(statestore.NewStateStore(), logging.New(ioutil.Discard, 0))
+		chunks     = []infinity.Chunk{
+			testingc.GenerateTestRandomChunk(),
+			testingc.GenerateTestRandomChunk(),
+			testingc.GenerateTestRandomChunk(),
+		}
+	)
+
+	_, conn, _ := newTestServer(t, testServerOptions{
+		Storer: mockStorer,
+		Tags:   tag,
+		WsPath: "/chunks/stream",
+	})
+	defer conn.Close()
+
+	frame := func(chunks ...infinity.Chunk) []byte {
+		var b []byte
+		for _, ch := range chunks {
+			l := make([]byte, 4)
+			binary.BigEndian.PutUint32(l, uint32(len(ch.Data())))
+			b = append(b, l...)
+			b = append(b, ch.Data()...)
+		}
+		return b
+	}
+
+	readAck := func(t *testing.T, want infinity.Address) {
+		t.Helper()
+
+		if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		mt, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mt != websocket.BinaryMessage {
+			t.Fatalf("got message type %d, want %d", mt, websocket.BinaryMessage)
+		}
+		if !bytes.Equal(msg, want.Bytes()) {
+			t.Fatalf("got ack %x, want %s", msg, want)
+		}
+	}
+
+	// a single chunk in the first message and two in the second
+	if err := conn.WriteMessage(websocket.BinaryMessage, frame(chunks[0])); err != nil {
+		t.Fatal(err)
+	}
+	readAck(t, chunks[0].Address())
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, frame(chunks[1:]...)); err != nil {
+		t.Fatal(err)
+	}
+	readAck(t, chunks[1].Address())
+	readAck(t, chunks[2].Address())
+
+	for _, ch := range chunks {
+		got, err := mockStorer.Get(context.Background(), storage.ModeGetRequest, ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Data(), ch.Data()) {
+			t.Fatalf("chunk %s: data mismatch", ch.Address())
+		}
+	}
+
+	// a chunk with the length beyond the message closes the stream
+	invalid := frame(chunks[0])
+	binary.BigEndian.PutUint32(invalid, uint32(len(chunks[0].Data())+1))
+	if err := conn.WriteMessage(websocket.BinaryMessage, invalid); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseInvalidFramePayloadData) {
+		t.Fatalf("got error %v, want close error %d", err, websocket.CloseInvalidFramePayloadData)
+	}
+}
+
+func TestChunkUploadStreamMaxSize(t *testing.T) {
+	chunks := []infinity.Chunk{
+		testingc.GenerateTestRandomChunk(),
+		testingc.GenerateTestRandomChunk(),
+	}
+
+	_, conn, _ := newTestServer(t, testServerOptions{
+		Storer:        mock.NewStorer(),
+		Tags:          tags.NewTags(statestore.NewStateStore(), logging.New(ioutil.Discard, 0)),
+		WsPath:        "/chunks/stream",
+		MaxUploadSize: int64(len(chunks[0].Data())),
+	})
+	defer conn.Close()
+
+	for _, ch := range chunks {
+		msg := make([]byte, 4, 4+len(ch.Data()))
+		binary.BigEndian.PutUint32(msg, uint32(len(ch.Data())))
+		if err := conn.WriteMessage(websocket.BinaryMessage, append(msg, ch.Data()...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg, chunks[0].Address().Bytes()) {
+		t.Fatalf("got ack %x, want %s", msg, chunks[0].Address())
+	}
+
+	// the second chunk exceeds the upload size
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("got error %v, want close error %d", err, websocket.CloseMessageTooBig)
+	}
+}
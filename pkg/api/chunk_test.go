@@ -6,6 +6,8 @@ package api_test
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"testing"
@@ -116,3 +118,37 @@ func TestChunkUploadDownload(t *testing.T) {
 		}
 	})
 }
+
+// quotaStorer fails all puts with the exceeded upload quota.
+type quotaStorer struct {
+	storage.Storer
+}
+
+func (quotaStorer) Put(context.Context, storage.ModePut, ...infinity.Chunk) ([]bool, error) {
+	return nil, fmt.Errorf("upload %w", storage.ErrQuotaExceeded)
+}
+
+func TestChunkUploadQuotaExceeded(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	client, _, _ := newTestServer(t, testServerOptions{
+		Storer: quotaStorer{Storer: mock.NewStorer()},
+		Tags:   tags.NewTags(statestore.NewStateStore(), logger),
+		Logger: logger,
+	})
+	chunk := testingc.GenerateTestRandomChunk()
+
+	jsonhttptest.Request(t, client, http.MethodPost, "/chunks", http.StatusInsufficientStorage,
+		jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Message: "storage quota exceeded",
+			Code:    http.StatusInsufficientStorage,
+		}),
+	)
+	jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusInsufficientStorage,
+		jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Message: "storage quota exceeded",
+			Code:    http.StatusInsufficientStorage,
+		}),
+	)
+}
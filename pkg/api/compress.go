@@ -0,0 +1,221 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// compressMinSize is the smallest content length which is compressed,
+	// smaller responses gain too little to pay for the compression.
+	compressMinSize = 1024
+	// gzipETagSuffix distinguishes the entity tag of the compressed
+	// representation from the tag of the stored content.
+	gzipETagSuffix = "-gzip"
+)
+
+// compressibleTypes are the media types of the text content which benefits
+// from the compression, in addition to all text types.
+var compressibleTypes = map[string]struct{}{
+	"application/javascript": {},
+	"application/json":       {},
+	"application/xml":        {},
+	"application/xhtml+xml":  {},
+	"application/wasm":       {},
+	"image/svg+xml":          {},
+}
+
+// contentDownloadRoutes are the path templates of the routes which content is
+// compressed by the compressionHandler instead of the responseCompressionHandler.
+var contentDownloadRoutes = map[string]struct{}{
+	"/files/{addr}":            {},
+	"/bytes/{address}":         {},
+	"/ifi/{address}/{path:.*}": {},
+}
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// responseCompressionHandler compresses all responses for the clients which
+// accept it, except the downloaded content which is left to the
+// compressionHandler.
+func (s *server) responseCompressionHandler(h http.Handler) http.Handler {
+	compressed := handlers.CompressHandler(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.contentDownloadRoute(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		compressed.ServeHTTP(w, r)
+	})
+}
+
+// contentDownloadRoute reports whether the request is routed to one of the
+// contentDownloadRoutes.
+func (s *server) contentDownloadRoute(r *http.Request) bool {
+	var match mux.RouteMatch
+	if !s.router.Match(r, &match) || match.Route == nil {
+		return false
+	}
+	path, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return false
+	}
+	_, ok := contentDownloadRoutes[strings.TrimPrefix(path, "/"+apiVersion)]
+	return ok
+}
+
+// compressionHandler compresses the downloaded content with gzip if it is
+// enabled in the options, accepted by the client and the content type is
+// compressible. The stored data is not changed, the content is compressed
+// while it is sent. Range requests are served uncompressed as the ranges
+// refer to the stored content.
+func (s *server) compressionHandler(h http.Handler) http.Handler {
+	if !s.Compression {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Header.Get("Range") != "" || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w}
+		// the handlers validate the tags of the stored content
+		if v := r.Header.Get("If-None-Match"); strings.Contains(v, gzipETagSuffix+`"`) {
+			r.Header.Set("If-None-Match", strings.ReplaceAll(v, gzipETagSuffix+`"`, `"`))
+			cw.gzipTag = true
+		}
+		defer func() {
+			if err := cw.Close(); err != nil {
+				s.logger.Debugf("api compression: close: %v", err)
+				s.logger.Error("api compression: close")
+			}
+		}()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// compressResponseWriter decides whether to compress the response when its
+// header is written.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	gzipTag     bool // the client validates the tag of the compressed content
+	wroteHeader bool
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	header := w.Header()
+	switch {
+	case code == http.StatusOK && header.Get("Content-Encoding") == "" && compressible(header):
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		gzipETag(header)
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	case code == http.StatusNotModified && w.gzipTag:
+		gzipETag(header)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Close flushes the compressed data.
+func (w *compressResponseWriter) Close() error {
+	if w.gz == nil {
+		return nil
+	}
+	err := w.gz.Close()
+	gzipWriters.Put(w.gz)
+	w.gz = nil
+	return err
+}
+
+// gzipETag marks the entity tag in the header as the tag of the compressed
+// content.
+func gzipETag(header http.Header) {
+	if etag := header.Get("ETag"); strings.HasSuffix(etag, `"`) {
+		header.Set("ETag", strings.TrimSuffix(etag, `"`)+gzipETagSuffix+`"`)
+	}
+}
+
+// acceptsGzip reports whether the Accept-Encoding header value allows the
+// gzip encoding.
+func acceptsGzip(accept string) bool {
+	gzipQ, anyQ := -1.0, -1.0
+	for _, v := range strings.Split(accept, ",") {
+		parts := strings.Split(v, ";")
+		q := 1.0
+		for _, p := range parts[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if f, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		switch strings.TrimSpace(parts[0]) {
+		case "gzip":
+			gzipQ = q
+		case "*":
+			anyQ = q
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return anyQ > 0
+}
+
+// compressible reports whether the response with the header is worth
+// compressing.
+func compressible(header http.Header) bool {
+	if v := header.Get("Content-Length"); v != "" {
+		if l, err := strconv.ParseInt(v, 10, 64); err == nil && l < compressMinSize {
+			return false
+		}
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	_, ok := compressibleTypes[mediaType]
+	return ok
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+func TestCompression(t *testing.T) {
+	var (
+		textData   = []byte(strings.Repeat("this is a compressible text\n", 100))
+		binaryData = bytes.Repeat([]byte{0, 1, 2, 3}, 1000)
+		logger     = logging.New(ioutil.Discard, 0)
+	)
+
+	upload := func(t *testing.T, client *http.Client, data []byte, contentType string) string {
+		t.Helper()
+
+		var resp api.FileUploadResponse
+		jsonhttptest.Request(t, client, http.MethodPost, "/files", http.StatusOK,
+			jsonhttptest.WithRequestBody(bytes.NewReader(data)),
+			jsonhttptest.WithRequestHeader("Content-Type", contentType),
+			jsonhttptest.WithUnmarshalJSONResponse(&resp),
+		)
+		return resp.Reference.String()
+	}
+
+	newServer := func(t *testing.T, compression bool) *http.Client {
+		t.Helper()
+
+		client, _, _ := newTestServer(t, testServerOptions{
+			Storer:      mock.NewStorer(),
+			Tags:        tags.NewTags(statestore.NewStateStore(), logger),
+			Logger:      logger,
+			Compression: compression,
+		})
+		return client
+	}
+
+	t.Run("compressed", func(t *testing.T) {
+		client := newServer(t, true)
+		ref := upload(t, client, textData, "text/plain")
+
+		header := jsonhttptest.Request(t, client, http.MethodGet, "/files/"+ref, http.StatusOK,
+			jsonhttptest.WithRequestHeader("Accept-Encoding", "identity"),
+			jsonhttptest.WithExpectedResponse(textData),
+		)
+		etag := header.Get("ETag")
+		gzipETag := strings.TrimSuffix(etag, `"`) + `-gzip"`
+
+		var body []byte
+		header = jsonhttptest.Request(t, client, http.MethodGet, "/files/"+ref, http.StatusOK,
+			jsonhttptest.WithRequestHeader("Accept-Encoding", "gzip"),
+			jsonhttptest.WithPutResponseBody(&body),
+		)
+		if got := header.Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("got content encoding %q, want %q", got, "gzip")
+		}
+		if got, want := header.Get("Content-Length"), strconv.Itoa(len(body)); got != "" && got != want {
+			t.Errorf("got content length %q, want %q", got, want)
+		}
+		if got := header.Get("ETag"); got != gzipETag {
+			t.Errorf("got etag %q, want %q", got, gzipETag)
+		}
+		if got := header.Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("got vary %q, want %q", got, "Accept-Encoding")
+		}
+
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, textData) {
+			t.Fatal("decompressed data does not match the uploaded data")
+		}
+
+		header = jsonhttptest.Request(t, client, http.MethodGet, "/files/"+ref, http.StatusNotModified,
+			jsonhttptest.WithRequestHeader("Accept-Encoding", "gzip"),
+			jsonhttptest.WithRequestHeader("If-None-Match", gzipETag),
+		)
+		if got := header.Get("ETag"); got != gzipETag {
+			t.Errorf("not modified: got etag %q, want %q", got, gzipETag)
+		}
+	})
+
+	t.Run("not accepted", func(t *testing.T) {
+		client := newServer(t, true)
+		ref := upload(t, client, textData, "text/plain")
+
+		for _, accept := range []string{"identity", "gzip;q=0", "br"} {
+			header := jsonhttptest.Request(t, client, http.MethodGet, "/files/"+ref, http.StatusOK,
+				jsonhttptest.WithRequestHeader("Accept-Encoding", accept),
+				jsonhttptest.WithExpectedResponse(textData),
+			)
+			if got := header.Get("Content-Encoding"); got != "" {
+				t.Errorf("%q: got content encoding %q, want none", accept, got)
+			}
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		client := newServer(t, true)
+		ref := upload(t, client, textData, "text/plain")
+
+		header := jsonhttptest.Request(t, client, http.MethodGet, "/files/"+ref, http.StatusPartialContent,
+			jsonhttptest.WithRequestHeader("Accept-Encoding", "gzip"),
+			jsonhttptest.WithRequestHeader("Range", "bytes=0-9"),
+			jsonhttptest.WithExpectedResponse(textData[:10]),
+		)
+		if got := header.Get("Content-Encoding"); got != "" {
+			t.Errorf("got content encoding %q, want none", got)
+		}
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		client := newServer(t, true)
+		ref := upload(t, client, binaryData, "application/octet-stream")
+
+		header := jsonhttptest.Request(t, client, http.MethodGet, "/files/"+ref, http.StatusOK,
+			jsonhttptest.WithRequestHeader("Accept-Encoding", "gzip"),
+			jsonhttptest.WithExpectedResponse(binaryData),
+		)
+		if got := header.Get("Content-Encoding"); got != "" {
+			t.Errorf("got content encoding %q, want none", got)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		client := newServer(t, false)
+		ref := upload(t, client, textData, "text/plain")
+
+		header := jsonhttptest.Request(t, client, http.MethodGet, "/files/"+ref, http.StatusOK,
+			jsonhttptest.WithRequestHeader("Accept-Encoding", "gzip"),
+			jsonhttptest.WithExpectedResponse(textData),
+		)
+		if got := header.Get("Content-Encoding"); got != "" {
+			t.Errorf("got content encoding %q, want none", got)
+		}
+	})
+
+	t.Run("other responses", func(t *testing.T) {
+		for _, compression := range []bool{true, false} {
+			client := newServer(t, compression)
+
+			header := jsonhttptest.Request(t, client, http.MethodGet, "/", http.StatusOK,
+				jsonhttptest.WithRequestHeader("Accept-Encoding", "gzip"),
+			)
+			if got := header.Get("Content-Encoding"); got != "gzip" {
+				t.Errorf("compression %v: got content encoding %q, want %q", compression, got, "gzip")
+			}
+		}
+	})
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "gzip", want: true},
+		{accept: "deflate, gzip;q=1.0, *;q=0.5", want: true},
+		{accept: "gzip;q=0", want: false},
+		{accept: "*", want: true},
+		{accept: "*;q=1, gzip;q=0", want: false},
+		{accept: "br, identity", want: false},
+	} {
+		if got := api.AcceptsGzip(tc.accept); got != tc.want {
+			t.Errorf("%q: got %v, want %v", tc.accept, got, tc.want)
+		}
+	}
+}
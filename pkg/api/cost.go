@@ -0,0 +1,84 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+)
+
+// costHandler accumulates the price of the chunk operations paid for to the
+// peers while the request is served, the retrievals of the downloaded chunks
+// and the pushes of the chunks sent directly to the network. The cost is sent
+// in the response headers when the response is started. If it changes while
+// the body is written, the total cost is sent in the trailers, which are
+// received by the clients only with the chunked or HTTP/2 responses. The
+// chunks of the file, directory and bytes uploads are pushed to the network
+// after the response, so those routes do not report the cost, it can be
+// estimated with the prices endpoint.
+func (s *server) costHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cost := new(accounting.Cost)
+		cw := &costResponseWriter{ResponseWriter: w, cost: cost}
+
+		h.ServeHTTP(cw, r.WithContext(accounting.WithCost(r.Context(), cost)))
+
+		if cw.wroteHeader && cost.Chunks() != cw.chunks {
+			setCostHeaders(w.Header(), http.TrailerPrefix, cost)
+		}
+	})
+}
+
+// setCostHeaders sets the cost headers, or the trailers with the prefix.
+func setCostHeaders(h http.Header, prefix string, cost *accounting.Cost) {
+	h.Set(prefix+InfinityCostHeader, strconv.FormatUint(cost.Amount(), 10))
+	h.Set(prefix+InfinityCostChunksHeader, strconv.FormatUint(cost.Chunks(), 10))
+}
+
+// costResponseWriter sets the cost headers when the response is started. It
+// keeps the response writer flushable and hijackable, as it wraps the
+// streamed downloads.
+type costResponseWriter struct {
+	http.ResponseWriter
+	cost        *accounting.Cost
+	chunks      uint64 // chunk operations when the response is started
+	wroteHeader bool
+}
+
+func (w *costResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.chunks = w.cost.Chunks()
+		setCostHeaders(w.Header(), "", w.cost)
+		w.Header().Add("Access-Control-Expose-Headers", InfinityCostHeader+", "+InfinityCostChunksHeader)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *costResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *costResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *costResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer is not a hijacker")
+	}
+	return h.Hijack()
+}
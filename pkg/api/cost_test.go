@@ -0,0 +1,184 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	testingc "github.com/yanhuangpai/voyager/pkg/storage/testing"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+// costStorer pays for every chunk it gets, as if it retrieved it from the
+// network.
+type costStorer struct {
+	storage.Storer
+	price uint64
+	gets  uint64
+}
+
+func (s *costStorer) Get(ctx context.Context, mode storage.ModeGet, addr infinity.Address) (infinity.Chunk, error) {
+	ch, err := s.Storer.Get(ctx, mode, addr)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&s.gets, 1)
+	accounting.AddCost(ctx, s.price)
+	return ch, nil
+}
+
+func TestCostHeaders(t *testing.T) {
+	const price = 10
+	var (
+		logger       = logging.New(ioutil.Discard, 0)
+		storer       = &costStorer{Storer: mock.NewStorer(), price: price}
+		client, _, _ = newTestServer(t, testServerOptions{
+			Storer:      storer,
+			Tags:        tags.NewTags(statestore.NewStateStore(), logger),
+			Compression: true,
+		})
+		chunk = testingc.GenerateTestRandomChunk()
+	)
+
+	t.Run("upload", func(t *testing.T) {
+		header := jsonhttptest.Request(t, client, http.MethodPost, "/chunks", http.StatusOK,
+			jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+		)
+		checkCostHeaders(t, header, "0", "0")
+	})
+
+	t.Run("pushed upload", func(t *testing.T) {
+		header := jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusOK,
+			jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+		)
+		if _, ok := header[api.InfinityCostHeader]; ok {
+			t.Fatalf("got %s header on an upload pushed after the response", api.InfinityCostHeader)
+		}
+	})
+
+	t.Run("chunk download", func(t *testing.T) {
+		header := jsonhttptest.Request(t, client, http.MethodGet, "/chunks/"+chunk.Address().String(), http.StatusOK,
+			jsonhttptest.WithExpectedResponse(chunk.Data()),
+		)
+		checkCostHeaders(t, header, strconv.Itoa(price), "1")
+	})
+
+	t.Run("streamed download", func(t *testing.T) {
+		// the random text is large enough after the compression not to be
+		// buffered and sent with the content length
+		content := []byte(hex.EncodeToString(testingc.GenerateTestRandomChunk().Data()))
+		for i := 0; i < 2; i++ {
+			content = append(content, hex.EncodeToString(testingc.GenerateTestRandomChunk().Data())...)
+		}
+		var resp api.FileUploadResponse
+		jsonhttptest.Request(t, client, http.MethodPost, "/files", http.StatusOK,
+			jsonhttptest.WithRequestBody(bytes.NewReader(content)),
+			jsonhttptest.WithRequestHeader("Content-Type", "text/plain"),
+			jsonhttptest.WithUnmarshalJSONResponse(&resp),
+		)
+
+		gets := atomic.LoadUint64(&storer.gets)
+		req, err := http.NewRequest(http.MethodGet, "/files/"+resp.Reference.String(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the compressed response is chunked, so the trailers are sent
+		req.Header.Set("Accept-Encoding", "gzip")
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if _, err := ioutil.ReadAll(res.Body); err != nil {
+			t.Fatal(err)
+		}
+		gets = atomic.LoadUint64(&storer.gets) - gets
+
+		// the file metadata is retrieved before the response is started
+		if got := res.Header.Get(api.InfinityCostChunksHeader); got == "" || got == "0" {
+			t.Fatalf("got header cost chunks %q, want the root chunk", got)
+		}
+		checkCostHeaders(t, res.Trailer, strconv.FormatUint(gets*price, 10), strconv.FormatUint(gets, 10))
+	})
+}
+
+func checkCostHeaders(t *testing.T, h http.Header, cost, chunks string) {
+	t.Helper()
+
+	if got := h.Get(api.InfinityCostHeader); got != cost {
+		t.Errorf("got cost %q, want %q", got, cost)
+	}
+	if got := h.Get(api.InfinityCostChunksHeader); got != chunks {
+		t.Errorf("got cost chunks %q, want %q", got, chunks)
+	}
+}
+
+func TestPrices(t *testing.T) {
+	const poPrice = 10
+	client, _, _ := newTestServer(t, testServerOptions{
+		Pricer: accounting.NewFixedPricer(infinity.MustParseHexAddress("01"), poPrice),
+	})
+
+	maxChunkPrice := uint64((infinity.MaxPO + 1) * poPrice)
+
+	t.Run("prices", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, "/prices", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(api.PricesResponse{
+				MinChunkPrice: poPrice,
+				MaxChunkPrice: maxChunkPrice,
+			}),
+		)
+	})
+
+	t.Run("estimate", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, "/prices?size=10000", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(api.PricesResponse{
+				MinChunkPrice: poPrice,
+				MaxChunkPrice: maxChunkPrice,
+				Estimate: &api.CostEstimate{
+					Size:    10000,
+					Chunks:  4,
+					MinCost: 4 * poPrice,
+					MaxCost: 4 * maxChunkPrice,
+				},
+			}),
+		)
+	})
+
+	t.Run("invalid size", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, "/prices?size=-1", http.StatusBadRequest,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "invalid size",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("not available", func(t *testing.T) {
+		client, _, _ := newTestServer(t, testServerOptions{})
+		jsonhttptest.Request(t, client, http.MethodGet, "/prices", http.StatusNotImplemented,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "prices not available",
+				Code:    http.StatusNotImplemented,
+			}),
+		)
+	})
+}
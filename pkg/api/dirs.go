@@ -52,6 +52,14 @@ func (s *server) dirUploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	level, err := s.requestRedundancyLevel(r)
+	if err != nil {
+		logger.Debugf("dir upload: parse redundancy level: %v", err)
+		logger.Error("dir upload: parse redundancy level")
+		jsonhttp.BadRequest(w, err)
+		return
+	}
+
 	tag, created, err := s.getOrCreateTag(r.Header.Get(InfinityTagHeader))
 	if err != nil {
 		logger.Debugf("dir upload: get or create tag: %v", err)
@@ -62,13 +70,19 @@ func (s *server) dirUploadHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Add the tag to the context
 	ctx := sctx.SetTag(r.Context(), tag)
-	p := requestPipelineFn(s.storer, r)
+	p := requestPipelineFn(s.storer, r, level)
 	encrypt := requestEncrypt(r)
-	l := loadsave.New(s.storer, requestModePut(r), encrypt)
+	l := loadsave.NewDeferred(s.storer, requestModePut(r), encrypt)
 	reference, err := storeDir(ctx, encrypt, r.Body, s.logger, p, l, r.Header.Get(InfinityIndexDocumentHeader), r.Header.Get(InfinityErrorDocumentHeader), tag, created)
 	if err != nil {
+		if jsonhttp.HandleBodyReadError(err, w) {
+			return
+		}
 		logger.Debugf("dir upload: store dir err: %v", err)
 		logger.Errorf("dir upload: store dir")
+		if handleQuotaError(err, w) {
+			return
+		}
 		jsonhttp.InternalServerError(w, "could not store dir")
 		return
 	}
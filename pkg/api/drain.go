@@ -0,0 +1,59 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+// drainHandler rejects the new requests with the service unavailable status
+// once the draining is started and tracks the requests in flight. Websocket
+// connections are not tracked as they are hung up on Close.
+func (s *server) drainHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		s.drainMu.Lock()
+		if s.draining {
+			s.drainMu.Unlock()
+			w.Header().Set("Connection", "close")
+			jsonhttp.ServiceUnavailable(w, "node is shutting down")
+			return
+		}
+		s.inflight.Add(1)
+		s.drainMu.Unlock()
+		defer s.inflight.Done()
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Drain rejects all new requests and waits for the requests in flight to
+// finish or the context to be done.
+func (s *server) Drain(ctx context.Context) error {
+	s.drainMu.Lock()
+	s.draining = true
+	s.drainMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.inflight.Wait()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
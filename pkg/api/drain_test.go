@@ -0,0 +1,121 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+func TestDrain(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	storer := &blockingStorer{
+		Storer:  mock.NewStorer(),
+		put:     make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	s := api.New(tags.NewTags(statestore.NewStateStore(), logger), storer, nil, nil, nil, nil, nil, logger, nil, api.Options{})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	client := ts.Client()
+
+	// start an upload which is in flight until the storer is released
+	uploadErr := make(chan error, 1)
+	go func() {
+		resp, err := client.Post(ts.URL+"/bytes", "application/octet-stream", bytes.NewReader(make([]byte, 2*infinity.ChunkSize)))
+		if err != nil {
+			uploadErr <- err
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			uploadErr <- errors.New(resp.Status)
+			return
+		}
+		uploadErr <- nil
+	}()
+	select {
+	case <-storer.put:
+	case <-time.After(time.Second):
+		t.Fatal("upload not started")
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		if err := s.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("new requests rejected", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, ts.URL+"/bytes/"+"1234", http.StatusServiceUnavailable,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusServiceUnavailable,
+				Message: "node is shutting down",
+			}),
+		)
+	})
+
+	t.Run("requests in flight finished", func(t *testing.T) {
+		drained := make(chan error, 1)
+		go func() {
+			drained <- s.Drain(context.Background())
+		}()
+
+		select {
+		case err := <-drained:
+			t.Fatalf("drained with the request in flight: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		close(storer.release)
+		if err := <-uploadErr; err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+
+		select {
+		case err := <-drained:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("not drained")
+		}
+	})
+}
+
+// blockingStorer blocks the puts until it is released.
+type blockingStorer struct {
+	storage.Storer
+	put     chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStorer) Put(ctx context.Context, mode storage.ModePut, chs ...infinity.Chunk) ([]bool, error) {
+	select {
+	case s.put <- struct{}{}:
+	default:
+	}
+	<-s.release
+	return s.Storer.Put(ctx, mode, chs...)
+}
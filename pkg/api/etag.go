@@ -0,0 +1,68 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+// referenceETag returns the entity tag of the content with the reference.
+// The content of a reference never changes, so the reference itself is a
+// strong validator. The decryption key of an encrypted reference is left out,
+// as the address of the encrypted root chunk already identifies the content.
+func referenceETag(reference infinity.Address) string {
+	if b := reference.Bytes(); len(b) > infinity.HashSize {
+		reference = infinity.NewAddress(b[:infinity.HashSize])
+	}
+	return fmt.Sprintf("%q", reference)
+}
+
+// checkPreconditions evaluates the If-Match and If-None-Match request headers
+// against the entity tag of the content. If a precondition decides the
+// response, it is written and true is returned. The content does not have to
+// be retrieved to evaluate the preconditions.
+func checkPreconditions(w http.ResponseWriter, r *http.Request, etag string) (done bool) {
+	if v := r.Header.Get("If-Match"); v != "" && !etagMatch(v, etag, false) {
+		jsonhttp.PreconditionFailed(w, nil)
+		return true
+	}
+	if v := r.Header.Get("If-None-Match"); v != "" && etagMatch(v, etag, true) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+		jsonhttp.PreconditionFailed(w, nil)
+		return true
+	}
+	return false
+}
+
+// etagMatch reports whether the list of entity tags in a conditional request
+// header matches the entity tag. The weak comparison ignores the weakness
+// indicator, while weak tags never match in the strong comparison.
+func etagMatch(list, etag string, weak bool) bool {
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" {
+			return true
+		}
+		if strings.HasPrefix(v, "W/") {
+			if !weak {
+				continue
+			}
+			v = v[2:]
+		}
+		if v == etag {
+			return true
+		}
+	}
+	return false
+}
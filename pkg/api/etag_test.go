@@ -0,0 +1,107 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	testingc "github.com/yanhuangpai/voyager/pkg/storage/testing"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	var (
+		logger       = logging.New(ioutil.Discard, 0)
+		chunk        = testingc.GenerateTestRandomChunk()
+		data         = []byte("conditional request content")
+		client, _, _ = newTestServer(t, testServerOptions{
+			Storer: mock.NewStorer(),
+			Tags:   tags.NewTags(statestore.NewStateStore(), logger),
+			Logger: logger,
+		})
+	)
+
+	jsonhttptest.Request(t, client, http.MethodPost, "/chunks", http.StatusOK,
+		jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+	)
+
+	var bytesResp api.BytesPostResponse
+	jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusOK,
+		jsonhttptest.WithRequestBody(bytes.NewReader(data)),
+		jsonhttptest.WithUnmarshalJSONResponse(&bytesResp),
+	)
+
+	var fileResp api.FileUploadResponse
+	jsonhttptest.Request(t, client, http.MethodPost, "/files?name=file.txt", http.StatusOK,
+		jsonhttptest.WithRequestBody(bytes.NewReader(data)),
+		jsonhttptest.WithRequestHeader("Content-Type", "text/plain"),
+		jsonhttptest.WithUnmarshalJSONResponse(&fileResp),
+	)
+
+	var encryptedResp api.FileUploadResponse
+	jsonhttptest.Request(t, client, http.MethodPost, "/files?name=file.txt", http.StatusOK,
+		jsonhttptest.WithRequestBody(bytes.NewReader(data)),
+		jsonhttptest.WithRequestHeader("Content-Type", "text/plain"),
+		jsonhttptest.WithRequestHeader(api.InfinityEncryptHeader, "true"),
+		jsonhttptest.WithUnmarshalJSONResponse(&encryptedResp),
+	)
+
+	for _, tc := range []struct {
+		name string
+		path string
+		etag infinity.Address
+	}{
+		{name: "chunk", path: "/chunks/" + chunk.Address().String(), etag: chunk.Address()},
+		{name: "bytes", path: "/bytes/" + bytesResp.Reference.String(), etag: bytesResp.Reference},
+		{name: "file", path: "/files/" + fileResp.Reference.String(), etag: fileResp.Reference},
+		// the decryption key is not part of the tag
+		{
+			name: "encrypted file",
+			path: "/files/" + encryptedResp.Reference.String(),
+			etag: infinity.NewAddress(encryptedResp.Reference.Bytes()[:infinity.HashSize]),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			etag := fmt.Sprintf("%q", tc.etag)
+			other := fmt.Sprintf("%q", infinity.MustParseHexAddress("01"))
+
+			header := jsonhttptest.Request(t, client, http.MethodGet, tc.path, http.StatusOK)
+			if got := header.Get("ETag"); got != etag {
+				t.Fatalf("got etag %s, want %s", got, etag)
+			}
+
+			for _, v := range []string{etag, "W/" + etag, other + ", " + etag, "*"} {
+				header = jsonhttptest.Request(t, client, http.MethodGet, tc.path, http.StatusNotModified,
+					jsonhttptest.WithRequestHeader("If-None-Match", v),
+				)
+				if got := header.Get("ETag"); got != etag {
+					t.Errorf("if-none-match %s: got etag %s, want %s", v, got, etag)
+				}
+			}
+			jsonhttptest.Request(t, client, http.MethodGet, tc.path, http.StatusOK,
+				jsonhttptest.WithRequestHeader("If-None-Match", other),
+			)
+
+			jsonhttptest.Request(t, client, http.MethodGet, tc.path, http.StatusOK,
+				jsonhttptest.WithRequestHeader("If-Match", etag),
+			)
+			for _, v := range []string{other, "W/" + etag} {
+				jsonhttptest.Request(t, client, http.MethodGet, tc.path, http.StatusPreconditionFailed,
+					jsonhttptest.WithRequestHeader("If-Match", v),
+				)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/traversal"
+)
+
+// ifNoneExistsNetwork is the parameter of the If-None-Exists header which
+// extends the existence check to the network.
+const ifNoneExistsNetwork = "network"
+
+var errInvalidIfNoneExists = errors.New("invalid if-none-exists reference")
+
+// requestIfNoneExists returns the reference from the If-None-Exists header
+// and whether its existence should be checked in the network too. The zero
+// address is returned if the header is not set.
+func requestIfNoneExists(r *http.Request) (reference infinity.Address, network bool, err error) {
+	h := r.Header.Get(InfinityIfNoneExistsHeader)
+	if h == "" {
+		return infinity.ZeroAddress, false, nil
+	}
+	parts := strings.Split(h, ";")
+	for _, p := range parts[1:] {
+		if strings.ToLower(strings.TrimSpace(p)) != ifNoneExistsNetwork {
+			return infinity.ZeroAddress, false, errInvalidIfNoneExists
+		}
+		network = true
+	}
+	reference, err = infinity.ParseHexAddress(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return infinity.ZeroAddress, false, errInvalidIfNoneExists
+	}
+	return reference, network, nil
+}
+
+// referenceExists reports whether all chunks of the bytes reference are
+// stored locally or, if network is true, can be retrieved from the network.
+// The chunks which are not stored locally are not retrieved otherwise, so the
+// walk stops at the first missing one. The upload is not skipped on the
+// retrieval errors, so they are reported as the reference not existing.
+func (s *server) referenceExists(ctx context.Context, reference infinity.Address, network bool) (bool, error) {
+	var getter storage.Storer = &localStorer{Storer: s.storer}
+	if network {
+		getter = s.storer
+	}
+	err := traversal.NewService(getter).TraverseBytesAddresses(ctx, reference, func(addr infinity.Address) error {
+		exists, err := s.chunkExists(ctx, addr, network)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if network || errors.Is(err, storage.ErrNotFound) {
+			s.logger.Debugf("if none exists: walk %s: %v", reference, err)
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// chunkExists reports whether the chunk is stored locally or, if network is
+// true, can be retrieved from the network.
+func (s *server) chunkExists(ctx context.Context, addr infinity.Address, network bool) (bool, error) {
+	has, err := s.storer.Has(ctx, addr)
+	if err != nil || has || !network {
+		return has, err
+	}
+	if _, err := s.storer.Get(ctx, storage.ModeGetRequest, addr); err != nil {
+		s.logger.Debugf("if none exists: retrieve %s: %v", addr, err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// localStorer gets only the chunks which are stored locally, without
+// retrieving them from the network.
+type localStorer struct {
+	storage.Storer
+}
+
+func (s *localStorer) Get(ctx context.Context, mode storage.ModeGet, addr infinity.Address) (infinity.Chunk, error) {
+	has, err := s.Storer.Has(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, storage.ErrNotFound
+	}
+	return s.Storer.Get(ctx, mode, addr)
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/cac"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	testingc "github.com/yanhuangpai/voyager/pkg/storage/testing"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+func TestIfNoneExists(t *testing.T) {
+	var (
+		logger       = logging.New(ioutil.Discard, 0)
+		storer       = mock.NewStorer()
+		chunk        = testingc.GenerateTestRandomChunk()
+		missing      = testingc.GenerateTestRandomChunk()
+		data         = []byte("conditional upload content")
+		client, _, _ = newTestServer(t, testServerOptions{
+			Storer: storer,
+			Tags:   tags.NewTags(statestore.NewStateStore(), logger),
+			Logger: logger,
+		})
+	)
+
+	jsonhttptest.Request(t, client, http.MethodPost, "/chunks", http.StatusOK,
+		jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+	)
+	var bytesResp api.BytesPostResponse
+	jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusOK,
+		jsonhttptest.WithRequestBody(bytes.NewReader(data)),
+		jsonhttptest.WithUnmarshalJSONResponse(&bytesResp),
+	)
+
+	t.Run("chunk exists", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPost, "/chunks", http.StatusConflict,
+			jsonhttptest.WithRequestHeader(api.InfinityIfNoneExistsHeader, chunk.Address().String()),
+			jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+			jsonhttptest.WithExpectedJSONResponse(api.ChunkAddressResponse{Reference: chunk.Address()}),
+		)
+	})
+
+	t.Run("chunk does not exist", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPost, "/chunks", http.StatusOK,
+			jsonhttptest.WithRequestHeader(api.InfinityIfNoneExistsHeader, missing.Address().String()),
+			jsonhttptest.WithRequestBody(bytes.NewReader(missing.Data())),
+			jsonhttptest.WithExpectedJSONResponse(api.ChunkAddressResponse{Reference: missing.Address()}),
+		)
+		has, err := storer.Has(context.Background(), missing.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !has {
+			t.Fatal("chunk not stored")
+		}
+	})
+
+	t.Run("bytes exist", func(t *testing.T) {
+		// the body is not read, so its content does not matter
+		jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusConflict,
+			jsonhttptest.WithRequestHeader(api.InfinityIfNoneExistsHeader, bytesResp.Reference.String()),
+			jsonhttptest.WithRequestBody(bytes.NewReader([]byte("other content"))),
+			jsonhttptest.WithExpectedJSONResponse(api.BytesPostResponse{Reference: bytesResp.Reference}),
+		)
+	})
+
+	t.Run("bytes do not exist", func(t *testing.T) {
+		reference := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+		jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusOK,
+			jsonhttptest.WithRequestHeader(api.InfinityIfNoneExistsHeader, reference.String()),
+			jsonhttptest.WithRequestBody(bytes.NewReader(data)),
+			jsonhttptest.WithExpectedJSONResponse(api.BytesPostResponse{Reference: bytesResp.Reference}),
+		)
+	})
+
+	t.Run("bytes incomplete", func(t *testing.T) {
+		data := make([]byte, 2*infinity.ChunkSize)
+		for i := range data {
+			data[i] = byte(i / infinity.ChunkSize)
+		}
+		var resp api.BytesPostResponse
+		jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusOK,
+			jsonhttptest.WithRequestBody(bytes.NewReader(data)),
+			jsonhttptest.WithUnmarshalJSONResponse(&resp),
+		)
+		leaf, err := cac.New(data[infinity.ChunkSize:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := storer.Set(context.Background(), storage.ModeSetRemove, leaf.Address()); err != nil {
+			t.Fatal(err)
+		}
+
+		// the root chunk is stored, but not all of the data chunks
+		jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusOK,
+			jsonhttptest.WithRequestHeader(api.InfinityIfNoneExistsHeader, resp.Reference.String()),
+			jsonhttptest.WithRequestBody(bytes.NewReader(data)),
+			jsonhttptest.WithExpectedJSONResponse(api.BytesPostResponse{Reference: resp.Reference}),
+		)
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		for _, h := range []string{
+			"not a reference",
+			chunk.Address().String() + "; everywhere",
+		} {
+			jsonhttptest.Request(t, client, http.MethodPost, "/chunks", http.StatusBadRequest,
+				jsonhttptest.WithRequestHeader(api.InfinityIfNoneExistsHeader, h),
+				jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+			)
+			jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusBadRequest,
+				jsonhttptest.WithRequestHeader(api.InfinityIfNoneExistsHeader, h),
+				jsonhttptest.WithRequestBody(bytes.NewReader(data)),
+			)
+		}
+	})
+}
+
+func TestIfNoneExistsNetwork(t *testing.T) {
+	var (
+		logger = logging.New(ioutil.Discard, 0)
+		chunk  = testingc.GenerateTestRandomChunk()
+		storer = mock.NewStorer()
+		// the chunk is not stored locally, but can be retrieved
+		network      = &networkStorer{Storer: storer}
+		client, _, _ = newTestServer(t, testServerOptions{
+			Storer: network,
+			Tags:   tags.NewTags(statestore.NewStateStore(), logger),
+			Logger: logger,
+		})
+	)
+
+	if _, err := storer.Put(context.Background(), storage.ModePutUpload, chunk); err != nil {
+		t.Fatal(err)
+	}
+
+	jsonhttptest.Request(t, client, http.MethodPost, "/chunks", http.StatusOK,
+		jsonhttptest.WithRequestHeader(api.InfinityIfNoneExistsHeader, chunk.Address().String()),
+		jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+	)
+	jsonhttptest.Request(t, client, http.MethodPost, "/chunks", http.StatusConflict,
+		jsonhttptest.WithRequestHeader(api.InfinityIfNoneExistsHeader, chunk.Address().String()+"; network"),
+		jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+		jsonhttptest.WithExpectedJSONResponse(api.ChunkAddressResponse{Reference: chunk.Address()}),
+	)
+}
+
+// networkStorer reports the chunks as not stored locally, while they can be
+// retrieved.
+type networkStorer struct {
+	storage.Storer
+}
+
+func (s *networkStorer) Has(_ context.Context, _ infinity.Address) (bool, error) {
+	return false, nil
+}
@@ -4,7 +4,11 @@
 
 package api
 
-import "github.com/yanhuangpai/voyager/pkg/infinity"
+import (
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
 
 type Server = server
 
@@ -20,10 +24,18 @@ type (
 	PinnedChunk              = pinnedChunk
 	ListPinnedChunksResponse = listPinnedChunksResponse
 	UpdatePinCounter         = updatePinCounter
+	PinImportResponse        = pinImportResponse
+	ManifestEntryResponse    = manifestEntryResponse
+	ManifestPathsResponse    = manifestPathsResponse
+	ManifestDiffResponse     = manifestDiffResponse
+	ReferenceStatsResponse   = referenceStatsResponse
+	PricesResponse           = pricesResponse
+	CostEstimate             = costEstimate
 )
 
 var (
 	ContentTypeTar = contentTypeTar
+	AcceptsGzip    = acceptsGzip
 )
 
 var (
@@ -35,8 +47,11 @@ var (
 var (
 	ErrNoResolver           = errNoResolver
 	ErrInvalidNameOrAddress = errInvalidNameOrAddress
+	ErrPrefetchLimit        = errPrefetchLimit
 )
 
+var ReadAll = readAll
+
 var (
 	FeedMetadataEntryOwner = feedMetadataEntryOwner
 	FeedMetadataEntryTopic = feedMetadataEntryTopic
@@ -50,3 +65,11 @@ func (s *Server) ResolveNameOrAddress(str string) (infinity.Address, error) {
 func CalculateNumberOfChunks(contentLength int64, isEncrypted bool) int64 {
 	return calculateNumberOfChunks(contentLength, isEncrypted)
 }
+
+type IPRateLimiter = ipRateLimiter
+
+var NewIPRateLimiter = newIPRateLimiter
+
+func (l *IPRateLimiter) Allow(ip string, now time.Time) bool {
+	return l.allow(ip, now)
+}
@@ -5,17 +5,21 @@
 package api
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/gorilla/mux"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/feeds"
+	"github.com/yanhuangpai/voyager/pkg/feeds/sequence"
 	"github.com/yanhuangpai/voyager/pkg/file/loadsave"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
@@ -27,10 +31,16 @@ const (
 	feedMetadataEntryOwner = "infinity-feed-owner"
 	feedMetadataEntryTopic = "infinity-feed-topic"
 	feedMetadataEntryType  = "infinity-feed-type"
+
+	// feedUpdateMaxPayloadSize is the maximal size of the feed update
+	// payload as the update chunk also holds its timestamp.
+	feedUpdateMaxPayloadSize = infinity.ChunkSize - 8
 )
 
 var errInvalidFeedUpdate = errors.New("invalid feed update")
 
+const errFeedTerminated = "feed terminated"
+
 type feedReferenceResponse struct {
 	Reference infinity.Address `json:"reference"`
 }
@@ -91,7 +101,7 @@ func (s *server) feedGetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ref, _, err := parseFeedUpdate(ch)
+	terminated, err := feeds.Terminated(ch)
 	if err != nil {
 		s.logger.Debugf("feed get: parse update: %v", err)
 		s.logger.Error("feed get: parse update")
@@ -99,6 +109,17 @@ func (s *server) feedGetHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var ref infinity.Address
+	if !terminated {
+		ref, _, err = parseFeedUpdate(ch)
+		if err != nil {
+			s.logger.Debugf("feed get: parse update: %v", err)
+			s.logger.Error("feed get: parse update")
+			jsonhttp.InternalServerError(w, "parse update")
+			return
+		}
+	}
+
 	curBytes, err := cur.MarshalBinary()
 	if err != nil {
 		s.logger.Debugf("feed get: marshal current index: %v", err)
@@ -119,6 +140,10 @@ func (s *server) feedGetHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set(InfinityFeedIndexNextHeader, hex.EncodeToString(nextBytes))
 	w.Header().Set("Access-Control-Expose-Headers", fmt.Sprintf("%s, %s", InfinityFeedIndexHeader, InfinityFeedIndexNextHeader))
 
+	if terminated {
+		jsonhttp.Gone(w, errFeedTerminated)
+		return
+	}
 	jsonhttp.OK(w, feedReferenceResponse{Reference: ref})
 }
 
@@ -173,6 +198,210 @@ func (s *server) feedPostHandler(w http.ResponseWriter, r *http.Request) {
 	jsonhttp.Created(w, feedReferenceResponse{Reference: ref})
 }
 
+// feedUpdateHandler signs the feed update with the node's signer, or with the
+// private key provided in the request, and stores it. The update is stored at
+// the provided index or at the index following the latest update, unless the
+// feed is terminated. On the delete requests the update is the tombstone
+// which terminates the feed.
+func (s *server) feedUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	owner, err := hex.DecodeString(mux.Vars(r)["owner"])
+	if err != nil {
+		s.logger.Debugf("feed update: decode owner: %v", err)
+		s.logger.Error("feed update: bad owner")
+		jsonhttp.BadRequest(w, "bad owner")
+		return
+	}
+
+	topic, err := hex.DecodeString(mux.Vars(r)["topic"])
+	if err != nil {
+		s.logger.Debugf("feed update: decode topic: %v", err)
+		s.logger.Error("feed update: bad topic")
+		jsonhttp.BadRequest(w, "bad topic")
+		return
+	}
+
+	signer := s.signer
+	if keyHex := r.Header.Get(InfinityFeedKeyHeader); keyHex != "" {
+		b, err := hex.DecodeString(keyHex)
+		if err != nil {
+			s.logger.Debugf("feed update: decode key: %v", err)
+			s.logger.Error("feed update: bad key")
+			jsonhttp.BadRequest(w, "bad key")
+			return
+		}
+		key, err := crypto.DecodeSecp256k1PrivateKey(b)
+		if err != nil {
+			s.logger.Debugf("feed update: decode key: %v", err)
+			s.logger.Error("feed update: bad key")
+			jsonhttp.BadRequest(w, "bad key")
+			return
+		}
+		signer = crypto.NewDefaultSigner(key)
+	} else if s.gatewayMode() || signer == nil {
+		s.logger.Error("feed update: node signer not available")
+		jsonhttp.Forbidden(w, "signing key required")
+		return
+	}
+
+	signerAddress, err := signer.EthereumAddress()
+	if err != nil {
+		s.logger.Debugf("feed update: signer address: %v", err)
+		s.logger.Error("feed update: signer address")
+		jsonhttp.InternalServerError(w, nil)
+		return
+	}
+	if !bytes.Equal(signerAddress.Bytes(), owner) {
+		s.logger.Debugf("feed update: owner %x does not match signer %x", owner, signerAddress)
+		s.logger.Error("feed update: owner does not match signer")
+		jsonhttp.Forbidden(w, "owner does not match signer")
+		return
+	}
+
+	at := time.Now().Unix()
+	if atStr := r.URL.Query().Get("at"); atStr != "" {
+		at, err = strconv.ParseInt(atStr, 10, 64)
+		if err != nil || at < 0 {
+			s.logger.Debugf("feed update: decode at: %v", err)
+			s.logger.Error("feed update: bad at")
+			jsonhttp.BadRequest(w, "bad at")
+			return
+		}
+	}
+
+	terminate := r.Method == http.MethodDelete
+	payload := feeds.Tombstone()
+	if !terminate {
+		payload, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			if jsonhttp.HandleBodyReadError(err, w) {
+				return
+			}
+			s.logger.Debugf("feed update: read payload: %v", err)
+			s.logger.Error("feed update: read payload")
+			jsonhttp.InternalServerError(w, nil)
+			return
+		}
+		if len(payload) == 0 {
+			s.logger.Error("feed update: empty payload")
+			jsonhttp.BadRequest(w, "empty payload")
+			return
+		}
+	}
+
+	f := feeds.New(topic, signerAddress)
+
+	var idx feeds.Index
+	if indexStr := r.URL.Query().Get("index"); indexStr != "" {
+		b, err := hex.DecodeString(indexStr)
+		if err != nil || len(b) != 8 {
+			s.logger.Debugf("feed update: decode index %q: %v", indexStr, err)
+			s.logger.Error("feed update: bad index")
+			jsonhttp.BadRequest(w, "bad index")
+			return
+		}
+		idx = sequence.NewIndex(binary.BigEndian.Uint64(b))
+	} else {
+		lookup, err := s.feedFactory.NewLookup(feeds.Sequence, f)
+		if err != nil {
+			s.logger.Debugf("feed update: new lookup: %v", err)
+			s.logger.Error("feed update: new lookup")
+			jsonhttp.InternalServerError(w, "new lookup")
+			return
+		}
+		latest := time.Now().Unix()
+		if at > latest {
+			latest = at
+		}
+		var ch infinity.Chunk
+		ch, _, idx, err = lookup.At(r.Context(), latest, 0)
+		if err != nil {
+			s.logger.Debugf("feed update: lookup: %v", err)
+			s.logger.Error("feed update: lookup")
+			jsonhttp.InternalServerError(w, "lookup failed")
+			return
+		}
+		if ch != nil {
+			terminated, err := feeds.Terminated(ch)
+			if err != nil {
+				s.logger.Debugf("feed update: parse latest update: %v", err)
+				s.logger.Error("feed update: parse latest update")
+				jsonhttp.InternalServerError(w, "parse update")
+				return
+			}
+			if terminated {
+				s.logger.Debugf("feed update: feed %x terminated", f.Topic)
+				s.logger.Error("feed update: feed terminated")
+				jsonhttp.Conflict(w, errFeedTerminated)
+				return
+			}
+		}
+	}
+
+	addr, err := f.Update(idx).Address()
+	if err != nil {
+		s.logger.Debugf("feed update: update address: %v", err)
+		s.logger.Error("feed update: update address")
+		jsonhttp.InternalServerError(w, nil)
+		return
+	}
+	has, err := s.storer.Has(r.Context(), addr)
+	if err != nil {
+		s.logger.Debugf("feed update: has update %s: %v", addr, err)
+		s.logger.Error("feed update: has update")
+		jsonhttp.InternalServerError(w, nil)
+		return
+	}
+	if has {
+		s.logger.Debugf("feed update: update at index %s exists", idx)
+		s.logger.Error("feed update: update exists")
+		jsonhttp.Conflict(w, "update exists")
+		return
+	}
+
+	putter, err := feeds.NewPutter(s.storer, signer, topic)
+	if err != nil {
+		s.logger.Debugf("feed update: new putter: %v", err)
+		s.logger.Error("feed update: new putter")
+		jsonhttp.InternalServerError(w, nil)
+		return
+	}
+	if err := putter.Put(r.Context(), idx, at, payload); err != nil {
+		s.logger.Debugf("feed update: put update at index %s: %v", idx, err)
+		s.logger.Error("feed update: put update")
+		if handleQuotaError(err, w) {
+			return
+		}
+		jsonhttp.InternalServerError(w, "put update")
+		return
+	}
+
+	curBytes, err := idx.MarshalBinary()
+	if err != nil {
+		s.logger.Debugf("feed update: marshal current index: %v", err)
+		s.logger.Error("feed update: marshal index")
+		jsonhttp.InternalServerError(w, "marshal index")
+		return
+	}
+
+	nextBytes, err := idx.Next(at, 0).MarshalBinary()
+	if err != nil {
+		s.logger.Debugf("feed update: marshal next index: %v", err)
+		s.logger.Error("feed update: marshal index")
+		jsonhttp.InternalServerError(w, "marshal index")
+		return
+	}
+
+	w.Header().Set(InfinityFeedIndexHeader, hex.EncodeToString(curBytes))
+	w.Header().Set(InfinityFeedIndexNextHeader, hex.EncodeToString(nextBytes))
+	w.Header().Set("Access-Control-Expose-Headers", fmt.Sprintf("%s, %s", InfinityFeedIndexHeader, InfinityFeedIndexNextHeader))
+
+	if terminate {
+		jsonhttp.OK(w, feedReferenceResponse{Reference: addr})
+		return
+	}
+	jsonhttp.Created(w, feedReferenceResponse{Reference: addr})
+}
+
 func parseFeedUpdate(ch infinity.Chunk) (infinity.Address, int64, error) {
 	s, err := soc.FromChunk(ch)
 	if err != nil {
@@ -16,7 +16,9 @@ import (
 	"testing"
 
 	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/feeds"
+	"github.com/yanhuangpai/voyager/pkg/feeds/factory"
 	"github.com/yanhuangpai/voyager/pkg/file/loadsave"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
@@ -191,6 +193,150 @@ func TestFeed_Post(t *testing.T) {
 	})
 }
 
+func TestFeed_Put(t *testing.T) {
+	var (
+		mockStorer   = mock.NewStorer()
+		topic        = "aabbcc"
+		feedResource = func(owner, query string) string {
+			if query != "" {
+				return fmt.Sprintf("/feeds/%s/%s?%s", owner, topic, query)
+			}
+			return fmt.Sprintf("/feeds/%s/%s", owner, topic)
+		}
+		newSigner = func(t *testing.T) (crypto.Signer, string, string) {
+			t.Helper()
+			key, err := crypto.GenerateSecp256k1Key()
+			if err != nil {
+				t.Fatal(err)
+			}
+			signer := crypto.NewDefaultSigner(key)
+			owner, err := signer.EthereumAddress()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signer, hex.EncodeToString(owner.Bytes()), hex.EncodeToString(crypto.EncodeSecp256k1PrivateKey(key))
+		}
+		signer, owner, _ = newSigner(t)
+		client, _, _     = newTestServer(t, testServerOptions{
+			Storer: mockStorer,
+			Feeds:  factory.New(mockStorer),
+			Signer: signer,
+		})
+		indexHeaders = func(t *testing.T, h http.Header, cur, next string) {
+			t.Helper()
+			if got := h.Get(api.InfinityFeedIndexHeader); got != cur {
+				t.Errorf("got feed index %q, want %q", got, cur)
+			}
+			if got := h.Get(api.InfinityFeedIndexNextHeader); got != next {
+				t.Errorf("got next feed index %q, want %q", got, next)
+			}
+		}
+	)
+
+	t.Run("first update", func(t *testing.T) {
+		h := jsonhttptest.Request(t, client, http.MethodPut, feedResource(owner, "at=121212"), http.StatusCreated,
+			jsonhttptest.WithRequestBody(bytes.NewReader(expReference.Bytes())),
+		)
+		indexHeaders(t, h, "0000000000000000", "0000000000000001")
+
+		jsonhttptest.Request(t, client, http.MethodGet, feedResource(owner, ""), http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(api.FeedReferenceResponse{Reference: expReference}),
+		)
+	})
+
+	t.Run("next update", func(t *testing.T) {
+		h := jsonhttptest.Request(t, client, http.MethodPut, feedResource(owner, "at=121213"), http.StatusCreated,
+			jsonhttptest.WithRequestBody(bytes.NewReader(expReference.Bytes())),
+		)
+		indexHeaders(t, h, "0000000000000001", "0000000000000002")
+	})
+
+	t.Run("existing index", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPut, feedResource(owner, "index=0000000000000000"), http.StatusConflict,
+			jsonhttptest.WithRequestBody(bytes.NewReader(expReference.Bytes())),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "update exists",
+				Code:    http.StatusConflict,
+			}),
+		)
+	})
+
+	t.Run("bad index", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPut, feedResource(owner, "index=01"), http.StatusBadRequest,
+			jsonhttptest.WithRequestBody(bytes.NewReader(expReference.Bytes())),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "bad index",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("empty payload", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPut, feedResource(owner, ""), http.StatusBadRequest,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "empty payload",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("owner mismatch", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPut, feedResource(ownerString, ""), http.StatusForbidden,
+			jsonhttptest.WithRequestBody(bytes.NewReader(expReference.Bytes())),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "owner does not match signer",
+				Code:    http.StatusForbidden,
+			}),
+		)
+	})
+
+	t.Run("provided key", func(t *testing.T) {
+		_, keyOwner, key := newSigner(t)
+		h := jsonhttptest.Request(t, client, http.MethodPut, feedResource(keyOwner, ""), http.StatusCreated,
+			jsonhttptest.WithRequestBody(bytes.NewReader(expReference.Bytes())),
+			jsonhttptest.WithRequestHeader(api.InfinityFeedKeyHeader, key),
+		)
+		indexHeaders(t, h, "0000000000000000", "0000000000000001")
+	})
+
+	t.Run("terminate", func(t *testing.T) {
+		h := jsonhttptest.Request(t, client, http.MethodDelete, feedResource(owner, ""), http.StatusOK)
+		indexHeaders(t, h, "0000000000000002", "0000000000000003")
+
+		h = jsonhttptest.Request(t, client, http.MethodGet, feedResource(owner, ""), http.StatusGone,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "feed terminated",
+				Code:    http.StatusGone,
+			}),
+		)
+		indexHeaders(t, h, "0000000000000002", "0000000000000003")
+
+		jsonhttptest.Request(t, client, http.MethodPut, feedResource(owner, ""), http.StatusConflict,
+			jsonhttptest.WithRequestBody(bytes.NewReader(expReference.Bytes())),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "feed terminated",
+				Code:    http.StatusConflict,
+			}),
+		)
+	})
+
+	t.Run("gateway mode", func(t *testing.T) {
+		client, _, _ := newTestServer(t, testServerOptions{
+			Storer:      mockStorer,
+			Feeds:       factory.New(mockStorer),
+			Signer:      signer,
+			GatewayMode: true,
+		})
+		jsonhttptest.Request(t, client, http.MethodPut, feedResource(owner, ""), http.StatusForbidden,
+			jsonhttptest.WithRequestBody(bytes.NewReader(expReference.Bytes())),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "signing key required",
+				Code:    http.StatusForbidden,
+			}),
+		)
+	})
+}
+
 type factoryMock struct {
 	sequenceCalled bool
 	epochCalled    bool
@@ -7,6 +7,7 @@ package api
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,13 +18,14 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/ethersphere/langos"
 	"github.com/gorilla/mux"
 	"github.com/yanhuangpai/voyager/pkg/collection/entry"
 	"github.com/yanhuangpai/voyager/pkg/file"
 	"github.com/yanhuangpai/voyager/pkg/file/joiner"
+	"github.com/yanhuangpai/voyager/pkg/file/pipeline/builder"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 	"github.com/yanhuangpai/voyager/pkg/sctx"
@@ -61,6 +63,22 @@ func (s *server) fileUploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	checksum, err := requestContentChecksum(r)
+	if err != nil {
+		logger.Debugf("file upload: parse checksum: %v", err)
+		logger.Error("file upload: parse checksum")
+		jsonhttp.BadRequest(w, "invalid checksum")
+		return
+	}
+
+	level, err := s.requestRedundancyLevel(r)
+	if err != nil {
+		logger.Debugf("file upload: parse redundancy level: %v", err)
+		logger.Error("file upload: parse redundancy level")
+		jsonhttp.BadRequest(w, err)
+		return
+	}
+
 	tag, created, err := s.getOrCreateTag(r.Header.Get(InfinityTagHeader))
 	if err != nil {
 		logger.Debugf("file upload: get or create tag: %v", err)
@@ -128,6 +146,29 @@ func (s *server) fileUploadHandler(w http.ResponseWriter, r *http.Request) {
 		reader = r.Body
 	}
 
+	if checksum != nil {
+		f, size, err := verifiedContent(reader, checksum)
+		if err != nil {
+			if errors.Is(err, builder.ErrChecksumMismatch) {
+				logger.Debugf("file upload: verify checksum, file %q: %v", fileName, err)
+				logger.Errorf("file upload: checksum mismatch, file %q", fileName)
+				jsonhttp.BadRequest(w, "checksum mismatch")
+				return
+			}
+			if jsonhttp.HandleBodyReadError(err, w) {
+				return
+			}
+			logger.Debugf("file upload: verify checksum, file %q: %v", fileName, err)
+			logger.Errorf("file upload: verify checksum, file %q", fileName)
+			jsonhttp.InternalServerError(w, nil)
+			return
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		reader = f
+		contentLength = strconv.FormatInt(size, 10)
+	}
+
 	if contentLength != "" {
 		fileSize, err = strconv.ParseUint(contentLength, 10, 64)
 		if err != nil {
@@ -149,6 +190,9 @@ func (s *server) fileUploadHandler(w http.ResponseWriter, r *http.Request) {
 		defer tmp.Close()
 		n, err := io.Copy(tmp, reader)
 		if err != nil {
+			if jsonhttp.HandleBodyReadError(err, w) {
+				return
+			}
 			logger.Debugf("file upload: write temporary file: %v", err)
 			logger.Error("file upload: write temporary file")
 			jsonhttp.InternalServerError(w, nil)
@@ -164,13 +208,19 @@ func (s *server) fileUploadHandler(w http.ResponseWriter, r *http.Request) {
 		reader = tmp
 	}
 
-	p := requestPipelineFn(s.storer, r)
+	p := requestPipelineFn(s.storer, r, level)
 
 	// first store the file and get its reference
 	fr, err := p(ctx, reader, int64(fileSize))
 	if err != nil {
+		if jsonhttp.HandleBodyReadError(err, w) {
+			return
+		}
 		logger.Debugf("file upload: file store, file %q: %v", fileName, err)
 		logger.Errorf("file upload: file store, file %q", fileName)
+		if handleQuotaError(err, w) {
+			return
+		}
 		jsonhttp.InternalServerError(w, "could not store file data")
 		return
 	}
@@ -211,6 +261,9 @@ func (s *server) fileUploadHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		logger.Debugf("file upload: metadata store, file %q: %v", fileName, err)
 		logger.Errorf("file upload: metadata store, file %q", fileName)
+		if handleQuotaError(err, w) {
+			return
+		}
 		jsonhttp.InternalServerError(w, "could not store metadata")
 		return
 	}
@@ -228,6 +281,9 @@ func (s *server) fileUploadHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		logger.Debugf("file upload: entry store, file %q: %v", fileName, err)
 		logger.Errorf("file upload: entry store, file %q", fileName)
+		if handleQuotaError(err, w) {
+			return
+		}
 		jsonhttp.InternalServerError(w, "could not store entry")
 		return
 	}
@@ -240,7 +296,7 @@ func (s *server) fileUploadHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	w.Header().Set("ETag", fmt.Sprintf("%q", reference.String()))
+	w.Header().Set("ETag", referenceETag(reference))
 	w.Header().Set(InfinityTagHeader, fmt.Sprint(tag.Uid))
 	w.Header().Set("Access-Control-Expose-Headers", InfinityTagHeader)
 	jsonhttp.OK(w, fileUploadResponse{
@@ -300,16 +356,6 @@ func (s *server) fileDownloadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// If none match header is set always send the reply as not modified
-	// TODO: when SOC comes, we need to revisit this concept
-	noneMatchEtag := r.Header.Get("If-None-Match")
-	if noneMatchEtag != "" {
-		if e.Reference().Equal(address) {
-			w.WriteHeader(http.StatusNotModified)
-			return
-		}
-	}
-
 	// read metadata
 	j, _, err = joiner.New(r.Context(), s.storer, e.Metadata())
 	if err != nil {
@@ -341,18 +387,42 @@ func (s *server) fileDownloadHandler(w http.ResponseWriter, r *http.Request) {
 		"Content-Type":        {metaData.MimeType},
 	}
 
-	s.downloadHandler(w, r, e.Reference(), additionalHeaders, true)
+	// the entry determines both the content and the headers of the response
+	s.downloadHandler(w, r, e.Reference(), additionalHeaders, referenceETag(address))
 }
 
 // downloadHandler contains common logic for dowloading Smart Chain file from API
-func (s *server) downloadHandler(w http.ResponseWriter, r *http.Request, reference infinity.Address, additionalHeaders http.Header, etag bool) {
+// The response is tagged with the etag, unless it is empty.
+func (s *server) downloadHandler(w http.ResponseWriter, r *http.Request, reference infinity.Address, additionalHeaders http.Header, etag string) {
 	logger := tracing.NewLoggerWithTraceID(r.Context(), s.logger)
+	if etag != "" && checkPreconditions(w, r, etag) {
+		return
+	}
 	targets := r.URL.Query().Get("targets")
 	if targets != "" {
 		r = r.WithContext(sctx.SetTargets(r.Context(), targets))
 	}
 
-	reader, l, err := joiner.New(r.Context(), s.storer, reference)
+	var (
+		reader file.Joiner
+		l      int64
+		err    error
+	)
+	if token := r.Header.Get(InfinityResumeTokenHeader); token != "" {
+		state, ok := s.resume.take(token)
+		if !ok || !state.Root.Equal(reference) {
+			logger.Debugf("api download: invalid resume token %s for %s", token, reference)
+			logger.Error("api download: invalid resume token")
+			jsonhttp.BadRequest(w, errInvalidResumeToken)
+			return
+		}
+		if r.Header.Get("Range") == "" {
+			r.Header.Set("Range", fmt.Sprintf("bytes=%d-", state.Offset))
+		}
+		reader, l, err = joiner.NewFromState(r.Context(), s.storer, state)
+	} else {
+		reader, l, err = joiner.New(r.Context(), s.storer, reference)
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			logger.Debugf("api download: not found %s: %v", reference, err)
@@ -377,15 +447,48 @@ func (s *server) downloadHandler(w http.ResponseWriter, r *http.Request, referen
 		}
 		w.Header().Set(name, v)
 	}
-	if etag {
-		w.Header().Set("ETag", fmt.Sprintf("%q", reference))
+	if etag != "" {
+		w.Header().Set("ETag", etag)
 	}
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", l))
 	w.Header().Set("Decompressed-Content-Length", fmt.Sprintf("%d", l))
-	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, "+InfinityResumeTokenHeader)
 	if targets != "" {
 		w.Header().Set(TargetsRecoveryHeader, targets)
 	}
 
-	http.ServeContent(w, r, "", time.Now(), langos.NewBufferedLangos(reader, lookaheadBufferSize(l)))
+	token, err := newResumeToken()
+	if err != nil {
+		logger.Debugf("api download: resume token: %v", err)
+		logger.Error("api download: resume token")
+		jsonhttp.InternalServerError(w, nil)
+		return
+	}
+	w.Header().Set(InfinityResumeTokenHeader, token)
+
+	cw := &countingResponseWriter{ResponseWriter: w}
+	rs := &offsetReadSeeker{ReadSeeker: file.NewPrefetcher(reader, l)}
+	http.ServeContent(cw, r, "", time.Now(), rs)
+
+	// keep the state of the interrupted download for the client to resume it
+	if cw.err == nil && r.Context().Err() == nil {
+		return
+	}
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "multipart/") {
+		// offsets of multiple ranges can not be tracked
+		return
+	}
+	offset := rs.offset + cw.written
+	if offset >= l {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resumeCaptureTimeout)
+	defer cancel()
+	state, err := joiner.CaptureState(ctx, s.storer, reference, offset)
+	if err != nil {
+		logger.Debugf("api download: capture resume state %s at %d: %v", reference, offset, err)
+		logger.Error("api download: capture resume state")
+		return
+	}
+	s.resume.put(token, state)
 }
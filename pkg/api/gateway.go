@@ -0,0 +1,176 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+// Reasons of the requests rejected in the gateway mode, used as the metrics
+// labels.
+const (
+	gatewayRejectRateLimit  = "rate_limit"
+	gatewayRejectUploadSize = "upload_size"
+	gatewayRejectEndpoint   = "endpoint"
+	gatewayRejectHeader     = "header"
+	gatewayRejectAPIKey     = "api_key"
+	gatewayRejectQuota      = "quota"
+)
+
+// gatewayLimiterCleanupInterval is the minimal time between the removals of
+// the rate limits of the clients which have not sent requests for a while.
+const gatewayLimiterCleanupInterval = time.Minute
+
+// gatewayHandler applies the gateway policies to the requests in the gateway
+// mode: the requests over the rate limit of the client IP address are
+// rejected with the too many requests status, the uploads over the gateway
+// upload size with the request entity too large status and the requests to
+// the endpoints not in the allowlist with the forbidden status.
+func (s *server) gatewayHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.gatewayMode() {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if s.gatewayLimiter != nil && !s.gatewayLimiter.allow(clientIP(r), time.Now()) {
+			s.logger.Tracef("gateway mode: rate limit exceeded by %s", r.RemoteAddr)
+			s.metrics.GatewayRejectedCount.WithLabelValues(gatewayRejectRateLimit).Inc()
+			w.Header().Set("Retry-After", "1")
+			jsonhttp.TooManyRequests(w, "rate limit exceeded")
+			return
+		}
+
+		if !s.gatewayEndpointAllowed(r) {
+			s.logger.Tracef("gateway mode: endpoint not allowed %s", r.URL.String())
+			s.metrics.GatewayRejectedCount.WithLabelValues(gatewayRejectEndpoint).Inc()
+			jsonhttp.Forbidden(w, "endpoint is disabled")
+			return
+		}
+
+		if limit := s.GatewayMaxUploadSize; limit > 0 && r.Body != nil && r.Body != http.NoBody {
+			if r.ContentLength > limit {
+				s.logger.Tracef("gateway mode: upload too large %s", r.URL.String())
+				s.metrics.GatewayRejectedCount.WithLabelValues(gatewayRejectUploadSize).Inc()
+				jsonhttp.RequestEntityTooLarge(w, nil)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// gatewayEndpointAllowed returns true if the allowlist of the gateway
+// endpoints is empty or the route of the request is in it. An allowlist entry
+// allows the route with the same path template and all routes under it, with
+// or without the api version prefix. The requests which do not match any
+// route are allowed to be answered with the not found status.
+func (s *server) gatewayEndpointAllowed(r *http.Request) bool {
+	if len(s.GatewayAllowedEndpoints) == 0 {
+		return true
+	}
+
+	var match mux.RouteMatch
+	if !s.router.Match(r, &match) || match.Route == nil {
+		return true
+	}
+	path, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return false
+	}
+	path = strings.TrimPrefix(path, "/"+apiVersion)
+
+	for _, e := range s.GatewayAllowedEndpoints {
+		e = strings.TrimSuffix(e, "/")
+		if path == e || strings.HasPrefix(path, e+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP address of the client which sent the request.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// ipRateLimiter limits the rate of the requests per client IP address with a
+// token bucket for every address.
+type ipRateLimiter struct {
+	rate        float64 // tokens added per second
+	burst       float64 // maximal number of tokens
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+	mu          sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newIPRateLimiter returns the rate limiter allowing the rate of requests per
+// second with the burst of requests, at least one and the rate rounded up if
+// the burst is not set.
+func newIPRateLimiter(rate float64, burst int) *ipRateLimiter {
+	b := float64(burst)
+	if burst <= 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &ipRateLimiter{
+		rate:    rate,
+		burst:   b,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow returns true if the request from the IP address at the given time is
+// within the rate limit.
+func (l *ipRateLimiter) allow(ip string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastCleanup) > gatewayLimiterCleanupInterval {
+		l.cleanup(now)
+	}
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// cleanup removes the buckets which are full again, as they are the same as
+// the buckets of the new clients.
+func (l *ipRateLimiter) cleanup(now time.Time) {
+	for ip, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, ip)
+		}
+	}
+	l.lastCleanup = now
+}
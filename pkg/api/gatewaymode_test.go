@@ -8,9 +8,12 @@ import (
 	"bytes"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/apikey"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
 	"github.com/yanhuangpai/voyager/pkg/logging"
@@ -56,6 +59,14 @@ func TestGatewayMode(t *testing.T) {
 		jsonhttptest.Request(t, client, http.MethodGet, "/pss/subscribe/test-topic", http.StatusForbidden, forbiddenResponseOption)
 	})
 
+	t.Run("reference stats endpoint", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, "/references/0773a91efd6547c754fc1d95fb1c62c7d1b47f959c2caa685dfec8736da95c1c/stats", http.StatusForbidden, forbiddenResponseOption)
+	})
+
+	t.Run("manifest diff endpoint", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, "/manifests/0773a91efd6547c754fc1d95fb1c62c7d1b47f959c2caa685dfec8736da95c1c/diff/0773a91efd6547c754fc1d95fb1c62c7d1b47f959c2caa685dfec8736da95c1c", http.StatusForbidden, forbiddenResponseOption)
+	})
+
 	t.Run("pinning", func(t *testing.T) {
 		headerOption := jsonhttptest.WithRequestHeader(api.InfinityPinHeader, "true")
 
@@ -95,3 +106,222 @@ func TestGatewayMode(t *testing.T) {
 		jsonhttptest.Request(t, client, http.MethodPost, "/dirs", http.StatusForbidden, forbiddenResponseOption, headerOption)
 	})
 }
+
+func TestGatewayModeRoutes(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+
+	for _, gatewayMode := range []bool{false, true} {
+		s := api.New(nil, nil, nil, nil, nil, nil, nil, logger, nil, api.Options{
+			GatewayMode: gatewayMode,
+		})
+		routes, err := s.Routes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]bool{
+			"/bytes":                        true,
+			"/v1/bytes":                     true,
+			"/tags":                         !gatewayMode,
+			"/v1/tags":                      !gatewayMode,
+			"/pin/chunks":                   !gatewayMode,
+			"/pss/send/{topic}/{targets}":   !gatewayMode,
+			"/references/{reference}/stats": !gatewayMode,
+		}
+		for _, r := range routes {
+			enabled, ok := want[r.Path]
+			if !ok {
+				continue
+			}
+			if r.Enabled != enabled {
+				t.Errorf("gateway mode %v: route %s: got enabled %v, want %v", gatewayMode, r.Path, r.Enabled, enabled)
+			}
+			delete(want, r.Path)
+		}
+		for path := range want {
+			t.Errorf("gateway mode %v: route %s not listed", gatewayMode, path)
+		}
+	}
+}
+
+func TestSetGatewayMode(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	s := api.New(tags.NewTags(statestore.NewStateStore(), logger), mock.NewStorer(), nil, nil, nil, nil, nil, logger, nil, api.Options{})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	client := ts.Client()
+
+	jsonhttptest.Request(t, client, http.MethodGet, ts.URL+"/tags", http.StatusOK)
+
+	s.SetGatewayMode(true)
+	jsonhttptest.Request(t, client, http.MethodGet, ts.URL+"/tags", http.StatusForbidden)
+
+	s.SetGatewayMode(false)
+	jsonhttptest.Request(t, client, http.MethodGet, ts.URL+"/tags", http.StatusOK)
+}
+
+func TestGatewayPolicies(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	chunk := testingc.GenerateTestRandomChunk()
+
+	newServer := func(t *testing.T, o api.Options) (*http.Client, string) {
+		t.Helper()
+
+		o.GatewayMode = true
+		s := api.New(tags.NewTags(statestore.NewStateStore(), logger), mock.NewStorer(), nil, nil, nil, nil, nil, logger, nil, o)
+		ts := httptest.NewServer(s)
+		t.Cleanup(ts.Close)
+		return ts.Client(), ts.URL
+	}
+
+	t.Run("rate limit", func(t *testing.T) {
+		client, url := newServer(t, api.Options{
+			GatewayRateLimit: 0.001,
+			GatewayRateBurst: 2,
+		})
+		resource := url + "/chunks/" + chunk.Address().String()
+
+		jsonhttptest.Request(t, client, http.MethodGet, resource, http.StatusNotFound)
+		jsonhttptest.Request(t, client, http.MethodGet, resource, http.StatusNotFound)
+		h := jsonhttptest.Request(t, client, http.MethodGet, resource, http.StatusTooManyRequests,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "rate limit exceeded",
+				Code:    http.StatusTooManyRequests,
+			}),
+		)
+		if got := h.Get("Retry-After"); got != "1" {
+			t.Errorf("got retry after %q, want %q", got, "1")
+		}
+	})
+
+	t.Run("upload size", func(t *testing.T) {
+		client, url := newServer(t, api.Options{
+			GatewayMaxUploadSize: int64(len(chunk.Data()) - 1),
+		})
+
+		jsonhttptest.Request(t, client, http.MethodPost, url+"/bytes", http.StatusRequestEntityTooLarge,
+			jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+		)
+		jsonhttptest.Request(t, client, http.MethodPost, url+"/bytes", http.StatusOK,
+			jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data()[:len(chunk.Data())-1])),
+		)
+	})
+
+	t.Run("allowed endpoints", func(t *testing.T) {
+		client, url := newServer(t, api.Options{
+			GatewayAllowedEndpoints: []string{"/chunks"},
+		})
+
+		jsonhttptest.Request(t, client, http.MethodPost, url+"/chunks", http.StatusOK,
+			jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+		)
+		jsonhttptest.Request(t, client, http.MethodGet, url+"/v1/chunks/"+chunk.Address().String(), http.StatusOK)
+		jsonhttptest.Request(t, client, http.MethodGet, url+"/bytes/"+chunk.Address().String(), http.StatusForbidden,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "endpoint is disabled",
+				Code:    http.StatusForbidden,
+			}),
+		)
+	})
+
+	t.Run("api keys", func(t *testing.T) {
+		keys, err := apikey.New(statestore.NewStateStore(), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer keys.Close()
+		secret, _, err := keys.Create("client", apikey.Quota{Requests: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client, url := newServer(t, api.Options{
+			GatewayAPIKeys: keys,
+		})
+		resource := url + "/chunks/" + chunk.Address().String()
+
+		// the cors preflight requests are sent without the api key
+		jsonhttptest.Request(t, client, http.MethodOptions, resource, http.StatusOK)
+		jsonhttptest.Request(t, client, http.MethodGet, resource, http.StatusUnauthorized,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "missing api key",
+				Code:    http.StatusUnauthorized,
+			}),
+		)
+		jsonhttptest.Request(t, client, http.MethodGet, resource, http.StatusUnauthorized,
+			jsonhttptest.WithRequestHeader("Authorization", "Bearer invalid"),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "invalid api key",
+				Code:    http.StatusUnauthorized,
+			}),
+		)
+
+		jsonhttptest.Request(t, client, http.MethodPost, url+"/chunks", http.StatusOK,
+			jsonhttptest.WithRequestHeader("Authorization", "Bearer "+secret),
+			jsonhttptest.WithRequestBody(bytes.NewReader(chunk.Data())),
+		)
+		jsonhttptest.Request(t, client, http.MethodGet, resource, http.StatusOK,
+			jsonhttptest.WithRequestHeader("Authorization", "Bearer "+secret),
+		)
+		h := jsonhttptest.Request(t, client, http.MethodGet, resource, http.StatusTooManyRequests,
+			jsonhttptest.WithRequestHeader("Authorization", "Bearer "+secret),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "api key quota exceeded",
+				Code:    http.StatusTooManyRequests,
+			}),
+		)
+		if h.Get("Retry-After") == "" {
+			t.Error("no retry after")
+		}
+
+		usage := keys.Keys()[0].Usage
+		if usage.Requests != 2 {
+			t.Errorf("got %d requests, want 2", usage.Requests)
+		}
+		if want := uint64(2 * len(chunk.Data())); usage.Bytes < want {
+			t.Errorf("got %d bytes, want at least %d", usage.Bytes, want)
+		}
+	})
+
+	t.Run("not in gateway mode", func(t *testing.T) {
+		keys, err := apikey.New(statestore.NewStateStore(), logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer keys.Close()
+		s := api.New(tags.NewTags(statestore.NewStateStore(), logger), mock.NewStorer(), nil, nil, nil, nil, nil, logger, nil, api.Options{
+			GatewayRateLimit:        0.001,
+			GatewayRateBurst:        1,
+			GatewayAllowedEndpoints: []string{"/chunks"},
+			GatewayAPIKeys:          keys,
+		})
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		for i := 0; i < 2; i++ {
+			jsonhttptest.Request(t, ts.Client(), http.MethodGet, ts.URL+"/bytes/"+chunk.Address().String(), http.StatusNotFound)
+		}
+	})
+}
+
+func TestIPRateLimiter(t *testing.T) {
+	l := api.NewIPRateLimiter(2, 0)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if !l.Allow("10.0.0.1", now) {
+			t.Fatalf("request %d not allowed", i)
+		}
+	}
+	if l.Allow("10.0.0.1", now) {
+		t.Fatal("request over the burst allowed")
+	}
+	if !l.Allow("10.0.0.2", now) {
+		t.Fatal("request from other address not allowed")
+	}
+	if !l.Allow("10.0.0.1", now.Add(500*time.Millisecond)) {
+		t.Fatal("request after the refill not allowed")
+	}
+	if l.Allow("10.0.0.1", now.Add(500*time.Millisecond)) {
+		t.Fatal("request over the refill allowed")
+	}
+}
@@ -99,6 +99,12 @@ FETCH:
 				jsonhttp.NotFound(w, "no update found")
 				return
 			}
+			if terminated, err := feeds.Terminated(ch); err == nil && terminated {
+				logger.Debugf("ifi download: feed lookup: feed terminated")
+				logger.Error("ifi download: feed lookup")
+				jsonhttp.Gone(w, errFeedTerminated)
+				return
+			}
 			ref, _, err := parseFeedUpdate(ch)
 			if err != nil {
 				logger.Debugf("ifi download: parse feed update: %v", err)
@@ -320,7 +326,13 @@ func (s *server) serveManifestEntry(w http.ResponseWriter, r *http.Request, addr
 
 	fileEntryAddress := fe.Reference()
 
-	s.downloadHandler(w, r, fileEntryAddress, additionalHeaders, etag)
+	// the file entry of the path determines both the content and the headers
+	// of the response
+	var entryETag string
+	if etag {
+		entryETag = referenceETag(manifestEntryAddress)
+	}
+	s.downloadHandler(w, r, fileEntryAddress, additionalHeaders, entryETag)
 }
 
 // manifestMetadataLoad returns the value for a key stored in the metadata of
@@ -154,7 +154,7 @@ func TestIfi(t *testing.T) {
 		if params["filename"] != fileName {
 			t.Fatal("Invalid file name detected")
 		}
-		if rcvdHeader.Get("ETag") != fmt.Sprintf("%q", fileContentReference) {
+		if rcvdHeader.Get("ETag") != fmt.Sprintf("%q", fileReference) {
 			t.Fatal("Invalid ETags header received")
 		}
 		if rcvdHeader.Get("Content-Type") != "text/html; charset=utf-8" {
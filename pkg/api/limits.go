@@ -0,0 +1,133 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+type connContextKey struct{}
+
+// ConnContext stores the connection of the request in its context, which
+// allows the handlers to set the deadlines on the connections of slow
+// clients. It should be set as the ConnContext of the API http server.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// requestConn returns the connection of the request, or nil if it is not
+// known.
+func requestConn(r *http.Request) net.Conn {
+	c, _ := r.Context().Value(connContextKey{}).(net.Conn)
+	return c
+}
+
+// uploadLimitsHandler limits the size of the uploaded request body to the
+// configured maximal upload size and the time of waiting for the client to
+// send the next part of the body to the configured upload read timeout.
+func (s *server) uploadLimitsHandler(h http.Handler) http.Handler {
+	return s.bodyLimitsHandler(h, s.MaxUploadSize)
+}
+
+// importLimitsHandler limits the pin archive imports like the uploads, but to
+// the configured maximal import size, as an archive holds many uploads.
+func (s *server) importLimitsHandler(h http.Handler) http.Handler {
+	return s.bodyLimitsHandler(h, s.MaxImportSize)
+}
+
+// bodyLimitsHandler limits the size of the request body to maxSize, unless it
+// is 0, and the time of waiting for the client to send the next part of the
+// body to the configured upload read timeout.
+func (s *server) bodyLimitsHandler(h http.Handler, maxSize int64) http.Handler {
+	if maxSize > 0 {
+		h = jsonhttp.NewMaxBodyBytesHandler(maxSize)(h)
+	}
+	if s.UploadReadTimeout <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := requestConn(r)
+		if c == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		body := &deadlineReader{
+			ReadCloser: r.Body,
+			conn:       c,
+			timeout:    s.UploadReadTimeout,
+			eof:        r.Body == http.NoBody,
+		}
+		defer func() {
+			// the server discards the unread rest of the body before
+			// the response is sent, which must not wait for a slow
+			// client forever
+			if !body.eof {
+				_ = c.SetReadDeadline(time.Now().Add(s.UploadReadTimeout))
+			}
+		}()
+		r.Body = body
+		h.ServeHTTP(w, r)
+	})
+}
+
+// downloadTimeoutHandler limits the time of waiting for the client to
+// receive the next part of the response to the configured write timeout.
+func (s *server) downloadTimeoutHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := requestConn(r)
+		if c == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		defer func() {
+			_ = c.SetWriteDeadline(time.Time{})
+		}()
+		h.ServeHTTP(&deadlineResponseWriter{ResponseWriter: w, conn: c, timeout: s.WriteTimeout}, r)
+	})
+}
+
+// deadlineReader sets the read deadline of the connection before every read
+// of the request body.
+type deadlineReader struct {
+	io.ReadCloser
+	conn    net.Conn
+	timeout time.Duration
+	eof     bool
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	if err := r.conn.SetReadDeadline(time.Now().Add(r.timeout)); err != nil {
+		return 0, err
+	}
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF {
+		// the server keeps reading from the connection after the body
+		// to detect the closed connections
+		_ = r.conn.SetReadDeadline(time.Time{})
+		r.eof = true
+	}
+	return n, err
+}
+
+// deadlineResponseWriter sets the write deadline of the connection before
+// every write of the response body.
+type deadlineResponseWriter struct {
+	http.ResponseWriter
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (w *deadlineResponseWriter) Write(b []byte) (int, error) {
+	if err := w.conn.SetWriteDeadline(time.Now().Add(w.timeout)); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	testingc "github.com/yanhuangpai/voyager/pkg/storage/testing"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+func TestMaxUploadSize(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	client, _, _ := newTestServer(t, testServerOptions{
+		Storer:        mock.NewStorer(),
+		Tags:          tags.NewTags(statestore.NewStateStore(), logger),
+		Logger:        logger,
+		MaxUploadSize: 100,
+	})
+
+	t.Run("within limit", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusOK,
+			jsonhttptest.WithRequestBody(bytes.NewReader(make([]byte, 100))),
+		)
+	})
+
+	t.Run("over limit", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusRequestEntityTooLarge,
+			jsonhttptest.WithRequestBody(bytes.NewReader(make([]byte, 101))),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: http.StatusText(http.StatusRequestEntityTooLarge),
+			}),
+		)
+	})
+
+	t.Run("over limit without content length", func(t *testing.T) {
+		// the length of a multi reader is not known to the client
+		jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusRequestEntityTooLarge,
+			jsonhttptest.WithRequestBody(io.MultiReader(bytes.NewReader(make([]byte, 4096)))),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: http.StatusText(http.StatusRequestEntityTooLarge),
+			}),
+		)
+	})
+}
+
+func TestMaxImportSize(t *testing.T) {
+	missing := testingc.GenerateTestRandomChunk().Address()
+	manifest, err := json.Marshal(map[string][]api.PinnedChunk{
+		"pins": {{Address: missing, PinCounter: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := tarFiles(t, []f{{name: "pins.json", data: manifest}}).Bytes()
+
+	newClient := func(maxImportSize int64) *http.Client {
+		client, _, _ := newTestServer(t, testServerOptions{
+			Storer:        mock.NewStorer(),
+			MaxUploadSize: 100,
+			MaxImportSize: maxImportSize,
+		})
+		return client
+	}
+
+	t.Run("over upload limit", func(t *testing.T) {
+		jsonhttptest.Request(t, newClient(0), http.MethodPost, "/pin/import", http.StatusOK,
+			jsonhttptest.WithRequestHeader("Content-Type", api.ContentTypeTar),
+			jsonhttptest.WithRequestBody(bytes.NewReader(archive)),
+		)
+	})
+
+	t.Run("over import limit", func(t *testing.T) {
+		jsonhttptest.Request(t, newClient(int64(len(archive)-1)), http.MethodPost, "/pin/import", http.StatusRequestEntityTooLarge,
+			jsonhttptest.WithRequestHeader("Content-Type", api.ContentTypeTar),
+			jsonhttptest.WithRequestBody(bytes.NewReader(archive)),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: http.StatusText(http.StatusRequestEntityTooLarge),
+			}),
+		)
+	})
+}
+
+func TestUploadReadTimeout(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	_, _, addr := newTestServer(t, testServerOptions{
+		Storer:            mock.NewStorer(),
+		Tags:              tags.NewTags(statestore.NewStateStore(), logger),
+		Logger:            logger,
+		UploadReadTimeout: 100 * time.Millisecond,
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// send only a part of the announced body and stall
+	if _, err := fmt.Fprintf(conn, "POST /bytes HTTP/1.1\r\nHost: %s\r\nContent-Length: 1000\r\n\r\npartial data", addr); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Fatalf("got status %v, want %v", resp.StatusCode, http.StatusRequestTimeout)
+	}
+}
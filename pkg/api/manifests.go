@@ -0,0 +1,335 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yanhuangpai/voyager/pkg/collection/entry"
+	"github.com/yanhuangpai/voyager/pkg/file"
+	"github.com/yanhuangpai/voyager/pkg/file/joiner"
+	"github.com/yanhuangpai/voyager/pkg/file/loadsave"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/manifest"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/tracing"
+)
+
+const (
+	manifestPathsDefaultLimit = 100
+	manifestPathsMaxLimit     = 1000
+	// manifestListingTTL is the duration for which the entries of a listed
+	// manifest are kept for the following pages.
+	manifestListingTTL = time.Minute
+	// maxManifestListings is the maximal number of kept manifest listings.
+	maxManifestListings = 16
+	// maxManifestListingEntries is the maximal number of the entries of a
+	// kept manifest listing, larger listings are walked for every page.
+	maxManifestListingEntries = 100000
+)
+
+type manifestEntryResponse struct {
+	Path      string            `json:"path"`
+	Reference infinity.Address  `json:"reference"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+type manifestPathsResponse struct {
+	Entries []manifestEntryResponse `json:"entries"`
+}
+
+// manifestPathsHandler lists the entries of the manifest with paths starting
+// with the optional prefix.
+func (s *server) manifestPathsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := tracing.NewLoggerWithTraceID(r.Context(), s.logger)
+
+	var (
+		err           error
+		offset, limit = 0, manifestPathsDefaultLimit
+		query         = r.URL.Query()
+	)
+	if v := query.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			logger.Debugf("manifest paths: parse offset %s: %v", v, err)
+			logger.Error("manifest paths: bad offset")
+			jsonhttp.BadRequest(w, "bad offset")
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > manifestPathsMaxLimit {
+			logger.Debugf("manifest paths: parse limit %s: %v", v, err)
+			logger.Error("manifest paths: bad limit")
+			jsonhttp.BadRequest(w, "bad limit")
+			return
+		}
+	}
+
+	address, ok := s.manifestAddressFromVar(w, r, "address")
+	if !ok {
+		return
+	}
+	prefix := query.Get("prefix")
+	key := address.String() + "/" + prefix
+
+	// the following pages are served from the entries listed for the first
+	// one, instead of walking the whole manifest again
+	all, ok := s.listings.get(key)
+	if !ok {
+		m, ok := s.loadManifestAt(w, r, address)
+		if !ok {
+			return
+		}
+		all = make([]manifestEntryResponse, 0)
+		err = m.IterateEntries(r.Context(), prefix, func(path string, e manifest.Entry) error {
+			all = append(all, manifestEntryResponse{
+				Path:      path,
+				Reference: e.Reference(),
+				Metadata:  e.Metadata(),
+			})
+			return nil
+		})
+		if err != nil {
+			logger.Debugf("manifest paths: iterate entries: %v", err)
+			logger.Error("manifest paths: iterate entries")
+			jsonhttp.InternalServerError(w, "iterate manifest entries")
+			return
+		}
+		if len(all) <= maxManifestListingEntries {
+			s.listings.put(key, all)
+		}
+	}
+
+	entries := make([]manifestEntryResponse, 0)
+	if offset < len(all) {
+		end := offset + limit
+		if end > len(all) {
+			end = len(all)
+		}
+		entries = append(entries, all[offset:end]...)
+	}
+
+	jsonhttp.OK(w, manifestPathsResponse{
+		Entries: entries,
+	})
+}
+
+// manifestListings keeps the entries of the recently listed manifests by the
+// manifest address and the path prefix.
+type manifestListings struct {
+	listings map[string]manifestListing
+	mu       sync.Mutex
+}
+
+type manifestListing struct {
+	entries []manifestEntryResponse
+	expires time.Time
+}
+
+func newManifestListings() *manifestListings {
+	return &manifestListings{
+		listings: make(map[string]manifestListing),
+	}
+}
+
+// put keeps the entries under the key, dropping the expired listings and the
+// one closest to expiry if there are too many of them.
+func (l *manifestListings) put(key string, entries []manifestEntryResponse) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var oldest string
+	for k, v := range l.listings {
+		if now.After(v.expires) {
+			delete(l.listings, k)
+			continue
+		}
+		if oldest == "" || v.expires.Before(l.listings[oldest].expires) {
+			oldest = k
+		}
+	}
+	if len(l.listings) >= maxManifestListings {
+		delete(l.listings, oldest)
+	}
+
+	l.listings[key] = manifestListing{
+		entries: entries,
+		expires: now.Add(manifestListingTTL),
+	}
+}
+
+// get returns the entries kept under the key.
+func (l *manifestListings) get(key string) ([]manifestEntryResponse, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	v, ok := l.listings[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(v.expires) {
+		delete(l.listings, key)
+		return nil, false
+	}
+	return v.entries, true
+}
+
+// manifestPathHeadHandler reports whether an entry exists on the path of the
+// manifest and sets the header with the entry reference if it does.
+func (s *server) manifestPathHeadHandler(w http.ResponseWriter, r *http.Request) {
+	logger := tracing.NewLoggerWithTraceID(r.Context(), s.logger)
+
+	m, ok := s.manifestFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	e, err := m.Lookup(r.Context(), mux.Vars(r)["path"])
+	if err != nil {
+		if errors.Is(err, manifest.ErrNotFound) {
+			jsonhttp.NotFound(w, nil)
+			return
+		}
+		logger.Debugf("manifest path: lookup: %v", err)
+		logger.Error("manifest path: lookup")
+		jsonhttp.InternalServerError(w, nil)
+		return
+	}
+
+	w.Header().Set(InfinityManifestEntryHeader, e.Reference().String())
+	w.Header().Set("Access-Control-Expose-Headers", InfinityManifestEntryHeader)
+	w.WriteHeader(http.StatusOK)
+}
+
+type manifestDiffResponse struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// manifestDiffHandler lists the paths added, removed and changed in the other
+// manifest compared to the one at the address.
+func (s *server) manifestDiffHandler(w http.ResponseWriter, r *http.Request) {
+	logger := tracing.NewLoggerWithTraceID(r.Context(), s.logger)
+
+	a, ok := s.manifestFromRequest(w, r)
+	if !ok {
+		return
+	}
+	b, ok := s.manifestFromVar(w, r, "other")
+	if !ok {
+		return
+	}
+
+	d, err := manifest.Diff(r.Context(), a, b)
+	if err != nil {
+		logger.Debugf("manifest diff: %v", err)
+		logger.Error("manifest diff")
+		jsonhttp.InternalServerError(w, "diff manifests")
+		return
+	}
+
+	jsonhttp.OK(w, manifestDiffResponse{
+		Added:   d.Added,
+		Removed: d.Removed,
+		Changed: d.Changed,
+	})
+}
+
+// manifestFromRequest loads the manifest referenced in the request. If the
+// manifest can not be loaded, the error response is written and ok is false.
+func (s *server) manifestFromRequest(w http.ResponseWriter, r *http.Request) (m manifest.Interface, ok bool) {
+	return s.manifestFromVar(w, r, "address")
+}
+
+// manifestFromVar loads the manifest referenced in the named route variable.
+// If the manifest can not be loaded, the error response is written and ok is
+// false.
+func (s *server) manifestFromVar(w http.ResponseWriter, r *http.Request, name string) (m manifest.Interface, ok bool) {
+	address, ok := s.manifestAddressFromVar(w, r, name)
+	if !ok {
+		return nil, false
+	}
+	return s.loadManifestAt(w, r, address)
+}
+
+// manifestAddressFromVar resolves the manifest address in the named route
+// variable. If it can not be resolved, the error response is written and ok
+// is false.
+func (s *server) manifestAddressFromVar(w http.ResponseWriter, r *http.Request, name string) (address infinity.Address, ok bool) {
+	logger := tracing.NewLoggerWithTraceID(r.Context(), s.logger)
+
+	nameOrHex := mux.Vars(r)[name]
+	address, err := s.resolveNameOrAddress(nameOrHex)
+	if err != nil {
+		logger.Debugf("manifest: parse address %s: %v", nameOrHex, err)
+		logger.Error("manifest: parse address")
+		jsonhttp.NotFound(w, nil)
+		return infinity.ZeroAddress, false
+	}
+	return address, true
+}
+
+// loadManifestAt loads the manifest at the address. If the manifest can not
+// be loaded, the error response is written and ok is false.
+func (s *server) loadManifestAt(w http.ResponseWriter, r *http.Request, address infinity.Address) (m manifest.Interface, ok bool) {
+	logger := tracing.NewLoggerWithTraceID(r.Context(), s.logger)
+
+	m, err := s.loadManifest(r.Context(), address)
+	if err != nil {
+		logger.Debugf("manifest: load %s: %v", address, err)
+		logger.Errorf("manifest: load %s", address)
+		jsonhttp.NotFound(w, "manifest not found")
+		return nil, false
+	}
+	return m, true
+}
+
+// loadManifest loads the manifest from the collection entry at the address.
+func (s *server) loadManifest(ctx context.Context, address infinity.Address) (manifest.Interface, error) {
+	e := &entry.Entry{}
+	if err := s.readAll(ctx, address, e.UnmarshalBinary); err != nil {
+		return nil, fmt.Errorf("read entry: %w", err)
+	}
+
+	metadata := &entry.Metadata{}
+	err := s.readAll(ctx, e.Metadata(), func(b []byte) error {
+		return json.Unmarshal(b, metadata)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+
+	ls := loadsave.New(s.storer, storage.ModePutRequest, false)
+	return manifest.NewManifestReference(metadata.MimeType, e.Reference(), ls)
+}
+
+// readAll joins the data at the address and passes it to the unmarshal
+// function.
+func (s *server) readAll(ctx context.Context, address infinity.Address, unmarshal func([]byte) error) error {
+	j, _, err := joiner.New(ctx, s.storer, address)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := file.JoinReadAll(ctx, j, buf); err != nil {
+		return err
+	}
+	return unmarshal(buf.Bytes())
+}
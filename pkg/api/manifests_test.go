@@ -0,0 +1,235 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+// getCountingStorer counts the chunks read from the store.
+type getCountingStorer struct {
+	storage.Storer
+	gets int32
+}
+
+func (s *getCountingStorer) Get(ctx context.Context, mode storage.ModeGet, addr infinity.Address) (infinity.Chunk, error) {
+	atomic.AddInt32(&s.gets, 1)
+	return s.Storer.Get(ctx, mode, addr)
+}
+
+func TestManifestPaths(t *testing.T) {
+	var (
+		logger       = logging.New(ioutil.Discard, 0)
+		storer       = &getCountingStorer{Storer: mock.NewStorer()}
+		client, _, _ = newTestServer(t, testServerOptions{
+			Storer: storer,
+			Tags:   tags.NewTags(statestore.NewStateStore(), logger),
+			Logger: logger,
+		})
+		pathsResource = func(addr string) string { return "/manifests/" + addr + "/paths" }
+		pathResource  = func(addr, path string) string { return "/manifests/" + addr + "/paths/" + path }
+	)
+
+	var upload api.FileUploadResponse
+	jsonhttptest.Request(t, client, http.MethodPost, "/dirs", http.StatusOK,
+		jsonhttptest.WithRequestBody(tarFiles(t, []f{
+			{data: []byte("index"), name: "index.html"},
+			{data: []byte("logo"), name: "logo.png", dir: "img"},
+			{data: []byte("icon"), name: "icon.png", dir: "img"},
+			{data: []byte("robots"), name: "robots.txt"},
+		})),
+		jsonhttptest.WithRequestHeader("Content-Type", api.ContentTypeTar),
+		jsonhttptest.WithRequestHeader(api.InfinityIndexDocumentHeader, "index.html"),
+		jsonhttptest.WithUnmarshalJSONResponse(&upload),
+	)
+	ref := upload.Reference.String()
+
+	list := func(t *testing.T, query string) []api.ManifestEntryResponse {
+		t.Helper()
+
+		var resp api.ManifestPathsResponse
+		jsonhttptest.Request(t, client, http.MethodGet, pathsResource(ref)+query, http.StatusOK,
+			jsonhttptest.WithUnmarshalJSONResponse(&resp),
+		)
+		return resp.Entries
+	}
+	paths := func(entries []api.ManifestEntryResponse) (p []string) {
+		for _, e := range entries {
+			p = append(p, e.Path)
+		}
+		return p
+	}
+
+	all := list(t, "")
+
+	t.Run("all", func(t *testing.T) {
+		want := []string{"/", "img/icon.png", "img/logo.png", "index.html", "robots.txt"}
+		if got := paths(all); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got paths %v, want %v", got, want)
+		}
+		if got := all[0].Metadata[api.ManifestWebsiteIndexDocumentSuffixKey]; got != "index.html" {
+			t.Errorf("got index document metadata %q, want %q", got, "index.html")
+		}
+		for _, e := range all[1:] {
+			if e.Reference.Equal(infinity.ZeroAddress) {
+				t.Errorf("path %s: zero reference", e.Path)
+			}
+		}
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		want := []string{"img/icon.png", "img/logo.png"}
+		if got := paths(list(t, "?prefix=img/")); !reflect.DeepEqual(got, want) {
+			t.Errorf("got paths %v, want %v", got, want)
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		want := []string{"img/logo.png", "index.html"}
+		if got := paths(list(t, "?offset=2&limit=2")); !reflect.DeepEqual(got, want) {
+			t.Errorf("got paths %v, want %v", got, want)
+		}
+		if got := list(t, "?offset=10"); len(got) != 0 {
+			t.Errorf("got entries %v, want none", got)
+		}
+	})
+
+	t.Run("following pages", func(t *testing.T) {
+		// the first page walks the manifest and the following ones are
+		// served from its entries
+		_ = list(t, "?prefix=i&limit=1")
+		atomic.StoreInt32(&storer.gets, 0)
+
+		want := []string{"img/logo.png", "index.html"}
+		if got := paths(list(t, "?prefix=i&offset=1&limit=2")); !reflect.DeepEqual(got, want) {
+			t.Errorf("got paths %v, want %v", got, want)
+		}
+		if gets := atomic.LoadInt32(&storer.gets); gets != 0 {
+			t.Errorf("got %d chunks read for the following page, want none", gets)
+		}
+	})
+
+	t.Run("bad limit", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, pathsResource(ref)+"?limit=0", http.StatusBadRequest,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "bad limit",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("not a manifest", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, pathsResource(all[1].Reference.String()), http.StatusNotFound,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "manifest not found",
+				Code:    http.StatusNotFound,
+			}),
+		)
+	})
+
+	t.Run("head", func(t *testing.T) {
+		for _, e := range all[1:] {
+			header := jsonhttptest.Request(t, client, http.MethodHead, pathResource(ref, e.Path), http.StatusOK)
+			if got := header.Get(api.InfinityManifestEntryHeader); got != e.Reference.String() {
+				t.Errorf("path %s: got reference %s, want %s", e.Path, got, e.Reference)
+			}
+		}
+
+		jsonhttptest.Request(t, client, http.MethodHead, pathResource(ref, "img"), http.StatusNotFound)
+		jsonhttptest.Request(t, client, http.MethodHead, pathResource(ref, "missing.html"), http.StatusNotFound)
+	})
+}
+
+func TestManifestDiff(t *testing.T) {
+	var (
+		logger       = logging.New(ioutil.Discard, 0)
+		client, _, _ = newTestServer(t, testServerOptions{
+			Storer: mock.NewStorer(),
+			Tags:   tags.NewTags(statestore.NewStateStore(), logger),
+			Logger: logger,
+		})
+		diffResource = func(a, b string) string { return "/manifests/" + a + "/diff/" + b }
+	)
+
+	upload := func(t *testing.T, files []f) string {
+		t.Helper()
+
+		var resp api.FileUploadResponse
+		jsonhttptest.Request(t, client, http.MethodPost, "/dirs", http.StatusOK,
+			jsonhttptest.WithRequestBody(tarFiles(t, files)),
+			jsonhttptest.WithRequestHeader("Content-Type", api.ContentTypeTar),
+			jsonhttptest.WithRequestHeader(api.InfinityIndexDocumentHeader, "index.html"),
+			jsonhttptest.WithUnmarshalJSONResponse(&resp),
+		)
+		return resp.Reference.String()
+	}
+
+	ref1 := upload(t, []f{
+		{data: []byte("index"), name: "index.html"},
+		{data: []byte("logo"), name: "logo.png", dir: "img"},
+		{data: []byte("icon"), name: "icon.png", dir: "img"},
+		{data: []byte("robots"), name: "robots.txt"},
+	})
+	ref2 := upload(t, []f{
+		{data: []byte("index v2"), name: "index.html"},
+		{data: []byte("logo"), name: "logo.png", dir: "img"},
+		{data: []byte("about"), name: "about.html"},
+		{data: []byte("robots"), name: "robots.txt"},
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, diffResource(ref1, ref2), http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(api.ManifestDiffResponse{
+				Added:   []string{"about.html"},
+				Removed: []string{"img/icon.png"},
+				Changed: []string{"index.html"},
+			}),
+		)
+	})
+
+	t.Run("reverse", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, diffResource(ref2, ref1), http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(api.ManifestDiffResponse{
+				Added:   []string{"img/icon.png"},
+				Removed: []string{"about.html"},
+				Changed: []string{"index.html"},
+			}),
+		)
+	})
+
+	t.Run("same", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, diffResource(ref1, ref1), http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(api.ManifestDiffResponse{
+				Added:   []string{},
+				Removed: []string{},
+				Changed: []string{},
+			}),
+		)
+	})
+
+	t.Run("not a manifest", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, diffResource(ref1, infinity.MustParseHexAddress("abcd").String()), http.StatusNotFound,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "manifest not found",
+				Code:    http.StatusNotFound,
+			}),
+		)
+	})
+}
@@ -19,6 +19,13 @@ type metrics struct {
 	RequestCount     prometheus.Counter
 	ResponseDuration prometheus.Histogram
 	PingRequestCount prometheus.Counter
+
+	IndexPrefetchCount   prometheus.Counter
+	IndexPrefetchFailed  prometheus.Counter
+	IndexPrefetchSkipped prometheus.Counter
+	IndexPrefetchBytes   prometheus.Counter
+
+	GatewayRejectedCount *prometheus.CounterVec
 }
 
 func newMetrics() metrics {
@@ -38,6 +45,39 @@ func newMetrics() metrics {
 			Help:      "Histogram of API response durations.",
 			Buckets:   []float64{0.01, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
 		}),
+		IndexPrefetchCount: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "index_prefetch_count",
+			Help:      "Number of index documents prefetched on the collection root requests.",
+		}),
+		IndexPrefetchFailed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "index_prefetch_failed_count",
+			Help:      "Number of failed index document prefetches.",
+		}),
+		IndexPrefetchSkipped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "index_prefetch_skipped_count",
+			Help:      "Number of index document prefetches skipped over the concurrency limit.",
+		}),
+		IndexPrefetchBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "index_prefetch_bytes",
+			Help:      "Number of bytes of the index documents prefetched.",
+		}),
+		GatewayRejectedCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: m.Namespace,
+				Subsystem: subsystem,
+				Name:      "gateway_rejected_count",
+				Help:      "Number of requests rejected by the gateway mode policies.",
+			},
+			[]string{"reason"},
+		),
 	}
 }
 
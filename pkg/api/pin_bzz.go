@@ -59,6 +59,9 @@ func (s *server) pinIfi(w http.ResponseWriter, r *http.Request) {
 		}
 
 		s.logger.Error("pin ifi: cannot pin")
+		if handleQuotaError(err, w) {
+			return
+		}
 		jsonhttp.InternalServerError(w, "cannot pin")
 		return
 	}
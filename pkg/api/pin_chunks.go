@@ -49,6 +49,9 @@ func (s *server) pinChunk(w http.ResponseWriter, r *http.Request) {
 				s.logger.Debugf("pin chunk: storer put pin: %v", err)
 				s.logger.Error("pin chunk: storer put pin")
 
+				if handleQuotaError(err, w) {
+					return
+				}
 				jsonhttp.InternalServerError(w, err)
 				return
 			}
@@ -56,6 +59,9 @@ func (s *server) pinChunk(w http.ResponseWriter, r *http.Request) {
 			s.logger.Debugf("pin chunk: pinning error: %v, addr %s", err, addr)
 			s.logger.Error("pin chunk: cannot pin chunk")
 
+			if handleQuotaError(err, w) {
+				return
+			}
 			jsonhttp.InternalServerError(w, "cannot pin chunk")
 			return
 		}
@@ -269,6 +275,9 @@ func (s *server) updatePinnedChunkPinCounter(w http.ResponseWriter, r *http.Requ
 	if err != nil {
 		s.logger.Debugf("update pin counter: update error: %v, addr %s", err, addr)
 		s.logger.Error("update pin counter: update")
+		if handleQuotaError(err, w) {
+			return
+		}
 		jsonhttp.InternalServerError(w, err)
 		return
 	}
@@ -0,0 +1,309 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/cac"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/soc"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+const (
+	// pinArchiveManifest is the name of the first entry of the pin archive
+	// which lists the pinned chunks with their pin counters.
+	pinArchiveManifest = "pins.json"
+	// pinArchiveChunksDir is the directory of the pin archive entries with
+	// the data of the pinned chunks, named by the hex chunk addresses.
+	pinArchiveChunksDir = "chunks"
+	// pinExportPageSize is the number of the pinned chunks listed at once.
+	pinExportPageSize = 1000
+	// maxPinArchiveManifestSize limits the size of the manifest of the
+	// imported archive.
+	maxPinArchiveManifestSize = 64 * 1024 * 1024
+	// maxPinArchiveChunkSize is the size of the largest chunk data, which
+	// is the single owner chunk with the full payload.
+	maxPinArchiveChunkSize = soc.IdSize + soc.SignatureSize + infinity.ChunkWithSpanSize
+)
+
+var (
+	errPinArchiveManifest = errors.New("pins manifest must be the first archive entry")
+	errPinArchiveEntry    = errors.New("invalid archive entry")
+	errPinArchiveChunk    = errors.New("invalid chunk")
+	errPinArchiveUnpinned = errors.New("chunk not in pins manifest")
+)
+
+type pinArchiveManifestJSON struct {
+	Pins []pinnedChunk `json:"pins"`
+}
+
+type pinImportResponse struct {
+	Chunks  int                `json:"chunks"`
+	Pins    int                `json:"pins"`
+	Missing []infinity.Address `json:"missing,omitempty"`
+}
+
+// pinExportHandler streams the tar archive of all pinned chunks, which
+// starts with the manifest of the pinned chunks with their pin counters
+// followed by the data of the chunks. The archive can be imported with the
+// pin import handler on another node.
+func (s *server) pinExportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var pins []pinnedChunk
+	for offset := 0; ; {
+		page, err := s.storer.PinnedChunks(ctx, offset, pinExportPageSize)
+		if err != nil {
+			s.logger.Debugf("pin export: list pinned: %v", err)
+			s.logger.Error("pin export: list pinned")
+			jsonhttp.InternalServerError(w, "cannot list pinned chunks")
+			return
+		}
+		for _, p := range page {
+			pins = append(pins, pinnedChunk(*p))
+		}
+		if len(page) < pinExportPageSize {
+			break
+		}
+		offset += len(page)
+	}
+	if pins == nil {
+		pins = make([]pinnedChunk, 0)
+	}
+
+	// the chunks are checked before the response status is sent, so that
+	// the missing ones fail the request instead of the archive
+	for _, p := range pins {
+		has, err := s.storer.Has(ctx, p.Address)
+		if err != nil {
+			s.logger.Debugf("pin export: has chunk %s: %v", p.Address, err)
+			s.logger.Error("pin export: has chunk")
+			jsonhttp.InternalServerError(w, "cannot get pinned chunk")
+			return
+		}
+		if !has {
+			s.logger.Debugf("pin export: chunk %s not found", p.Address)
+			s.logger.Error("pin export: chunk not found")
+			jsonhttp.InternalServerError(w, fmt.Sprintf("pinned chunk %s not found", p.Address))
+			return
+		}
+	}
+
+	manifest, err := json.Marshal(pinArchiveManifestJSON{Pins: pins})
+	if err != nil {
+		s.logger.Debugf("pin export: marshal manifest: %v", err)
+		s.logger.Error("pin export: marshal manifest")
+		jsonhttp.InternalServerError(w, nil)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeTar)
+	w.Header().Set("Content-Disposition", `attachment; filename="pins.tar"`)
+	w.WriteHeader(http.StatusOK)
+
+	// the response status is sent, the connection is aborted on the errors,
+	// so that the client does not receive the truncated archive as complete
+	tw := tar.NewWriter(w)
+	modTime := time.Now()
+	if err := writePinArchiveEntry(tw, pinArchiveManifest, manifest, modTime); err != nil {
+		s.logger.Debugf("pin export: write manifest: %v", err)
+		s.logger.Error("pin export: write manifest")
+		panic(http.ErrAbortHandler)
+	}
+	for _, p := range pins {
+		ch, err := s.storer.Get(ctx, storage.ModeGetLookup, p.Address)
+		if err != nil {
+			s.logger.Debugf("pin export: get chunk %s: %v", p.Address, err)
+			s.logger.Error("pin export: get chunk")
+			panic(http.ErrAbortHandler)
+		}
+		if err := writePinArchiveEntry(tw, path.Join(pinArchiveChunksDir, p.Address.String()), ch.Data(), modTime); err != nil {
+			s.logger.Debugf("pin export: write chunk %s: %v", p.Address, err)
+			s.logger.Error("pin export: write chunk")
+			panic(http.ErrAbortHandler)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		s.logger.Debugf("pin export: close archive: %v", err)
+		s.logger.Error("pin export: close archive")
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func writePinArchiveEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// pinImportHandler stores the chunks from the tar archive created by the pin
+// export handler and pins them, so that their pin counters are at least the
+// ones in the archive manifest. The pinned chunks of the manifest which are
+// neither in the archive nor stored locally are reported as missing.
+func (s *server) pinImportHandler(w http.ResponseWriter, r *http.Request) {
+	if err := validateRequest(r); err != nil {
+		s.logger.Debugf("pin import: validate request: %v", err)
+		s.logger.Error("pin import: validate request")
+		jsonhttp.BadRequest(w, "could not validate request")
+		return
+	}
+
+	resp, err := s.importPins(r)
+	if err != nil {
+		if jsonhttp.HandleBodyReadError(err, w) {
+			return
+		}
+		s.logger.Debugf("pin import: %v", err)
+		s.logger.Error("pin import: import archive")
+		if handleQuotaError(err, w) {
+			return
+		}
+		switch {
+		case errors.Is(err, errPinArchiveManifest),
+			errors.Is(err, errPinArchiveEntry),
+			errors.Is(err, errPinArchiveChunk),
+			errors.Is(err, errPinArchiveUnpinned),
+			errors.Is(err, tar.ErrHeader),
+			errors.Is(err, io.ErrUnexpectedEOF):
+			jsonhttp.BadRequest(w, err)
+		default:
+			jsonhttp.InternalServerError(w, "cannot import pinned chunks")
+		}
+		return
+	}
+	jsonhttp.OK(w, resp)
+}
+
+func (s *server) importPins(r *http.Request) (*pinImportResponse, error) {
+	ctx := r.Context()
+	tr := tar.NewReader(r.Body)
+
+	h, err := tr.Next()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errPinArchiveManifest
+		}
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	if path.Clean(h.Name) != pinArchiveManifest {
+		return nil, errPinArchiveManifest
+	}
+	var manifest pinArchiveManifestJSON
+	if err := json.NewDecoder(io.LimitReader(tr, maxPinArchiveManifestSize)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%w: decode manifest: %v", errPinArchiveManifest, err)
+	}
+	pins := make(map[string]uint64, len(manifest.Pins))
+	for _, p := range manifest.Pins {
+		pins[p.Address.ByteString()] = p.PinCounter
+	}
+
+	imported := make(map[string]struct{})
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive: %w", err)
+		}
+		if !h.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		dir, name := path.Split(path.Clean(h.Name))
+		if strings.TrimSuffix(dir, "/") != pinArchiveChunksDir {
+			return nil, fmt.Errorf("%w: %s", errPinArchiveEntry, h.Name)
+		}
+		addr, err := infinity.ParseHexAddress(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errPinArchiveEntry, h.Name)
+		}
+		pinCounter, ok := pins[addr.ByteString()]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errPinArchiveUnpinned, addr)
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(tr, maxPinArchiveChunkSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("read chunk %s: %w", addr, err)
+		}
+		ch := infinity.NewChunk(addr, data)
+		if len(data) > maxPinArchiveChunkSize || (!cac.Valid(ch) && !soc.Valid(ch)) {
+			return nil, fmt.Errorf("%w: %s", errPinArchiveChunk, addr)
+		}
+
+		has, err := s.storer.Has(ctx, addr)
+		if err != nil {
+			return nil, fmt.Errorf("has chunk %s: %w", addr, err)
+		}
+		if !has {
+			if _, err := s.storer.Put(ctx, storage.ModePutRequest, ch); err != nil {
+				return nil, fmt.Errorf("put chunk %s: %w", addr, err)
+			}
+		}
+		// pin right after the put, before the chunk can be collected
+		if err := s.importPin(r, addr, pinCounter); err != nil {
+			return nil, err
+		}
+		imported[addr.ByteString()] = struct{}{}
+	}
+
+	resp := &pinImportResponse{
+		Chunks: len(imported),
+		Pins:   len(manifest.Pins),
+	}
+	for _, p := range manifest.Pins {
+		if _, ok := imported[p.Address.ByteString()]; ok {
+			continue
+		}
+		has, err := s.storer.Has(ctx, p.Address)
+		if err != nil {
+			return nil, fmt.Errorf("has chunk %s: %w", p.Address, err)
+		}
+		if !has {
+			resp.Missing = append(resp.Missing, p.Address)
+			continue
+		}
+		if err := s.importPin(r, p.Address, p.PinCounter); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// importPin raises the pin counter of the stored chunk to the imported one,
+// the higher local pin counter is kept.
+func (s *server) importPin(r *http.Request, addr infinity.Address, pinCounter uint64) error {
+	current, err := s.storer.PinCounter(addr)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("pin counter %s: %w", addr, err)
+	}
+	if pinCounter <= current {
+		return nil
+	}
+	if err := s.updatePinCount(r.Context(), addr, int(pinCounter-current)); err != nil {
+		return fmt.Errorf("pin chunk %s: %w", addr, err)
+	}
+	return nil
+}
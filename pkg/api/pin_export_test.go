@@ -0,0 +1,189 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	testingc "github.com/yanhuangpai/voyager/pkg/storage/testing"
+)
+
+func TestPinExportImport(t *testing.T) {
+	var (
+		sourceStorer = mock.NewStorer()
+		targetStorer = mock.NewStorer()
+		chunks       = []infinity.Chunk{
+			testingc.GenerateTestRandomChunk(),
+			testingc.GenerateTestRandomChunk(),
+		}
+		pinCounters = []uint64{1, 3}
+
+		sourceClient, _, _ = newTestServer(t, testServerOptions{
+			Storer: sourceStorer,
+		})
+		targetClient, _, _ = newTestServer(t, testServerOptions{
+			Storer: targetStorer,
+		})
+	)
+
+	for i, ch := range chunks {
+		if _, err := sourceStorer.Put(context.Background(), storage.ModePutUploadPin, ch); err != nil {
+			t.Fatal(err)
+		}
+		for j := uint64(1); j < pinCounters[i]; j++ {
+			if err := sourceStorer.Set(context.Background(), storage.ModeSetPin, ch.Address()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var archive []byte
+	header := jsonhttptest.Request(t, sourceClient, http.MethodGet, "/pin/export", http.StatusOK,
+		jsonhttptest.WithPutResponseBody(&archive),
+	)
+	if got := header.Get("Content-Type"); got != api.ContentTypeTar {
+		t.Fatalf("got content type %q, want %q", got, api.ContentTypeTar)
+	}
+
+	t.Run("import", func(t *testing.T) {
+		jsonhttptest.Request(t, targetClient, http.MethodPost, "/pin/import", http.StatusOK,
+			jsonhttptest.WithRequestHeader("Content-Type", api.ContentTypeTar),
+			jsonhttptest.WithRequestBody(bytes.NewReader(archive)),
+			jsonhttptest.WithExpectedJSONResponse(api.PinImportResponse{
+				Chunks: 2,
+				Pins:   2,
+			}),
+		)
+
+		for i, ch := range chunks {
+			got, err := targetStorer.Get(context.Background(), storage.ModeGetLookup, ch.Address())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Data(), ch.Data()) {
+				t.Errorf("chunk %s: got data %x, want %x", ch.Address(), got.Data(), ch.Data())
+			}
+			pinCounter, err := targetStorer.PinCounter(ch.Address())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pinCounter != pinCounters[i] {
+				t.Errorf("chunk %s: got pin counter %d, want %d", ch.Address(), pinCounter, pinCounters[i])
+			}
+		}
+	})
+
+	t.Run("import again", func(t *testing.T) {
+		jsonhttptest.Request(t, targetClient, http.MethodPost, "/pin/import", http.StatusOK,
+			jsonhttptest.WithRequestHeader("Content-Type", api.ContentTypeTar),
+			jsonhttptest.WithRequestBody(bytes.NewReader(archive)),
+		)
+
+		for i, ch := range chunks {
+			pinCounter, err := targetStorer.PinCounter(ch.Address())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pinCounter != pinCounters[i] {
+				t.Errorf("chunk %s: got pin counter %d, want %d", ch.Address(), pinCounter, pinCounters[i])
+			}
+		}
+	})
+
+	t.Run("missing chunks", func(t *testing.T) {
+		missing := testingc.GenerateTestRandomChunk().Address()
+		manifest, err := json.Marshal(map[string][]api.PinnedChunk{
+			"pins": {{Address: missing, PinCounter: 1}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jsonhttptest.Request(t, targetClient, http.MethodPost, "/pin/import", http.StatusOK,
+			jsonhttptest.WithRequestHeader("Content-Type", api.ContentTypeTar),
+			jsonhttptest.WithRequestBody(tarFiles(t, []f{{name: "pins.json", data: manifest}})),
+			jsonhttptest.WithExpectedJSONResponse(api.PinImportResponse{
+				Pins:    1,
+				Missing: []infinity.Address{missing},
+			}),
+		)
+	})
+
+	t.Run("no manifest", func(t *testing.T) {
+		ch := testingc.GenerateTestRandomChunk()
+		jsonhttptest.Request(t, targetClient, http.MethodPost, "/pin/import", http.StatusBadRequest,
+			jsonhttptest.WithRequestHeader("Content-Type", api.ContentTypeTar),
+			jsonhttptest.WithRequestBody(tarFiles(t, []f{{dir: "chunks", name: ch.Address().String(), data: ch.Data()}})),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "pins manifest must be the first archive entry",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("invalid chunk", func(t *testing.T) {
+		ch := testingc.GenerateTestRandomChunk()
+		manifest, err := json.Marshal(map[string][]api.PinnedChunk{
+			"pins": {{Address: ch.Address(), PinCounter: 1}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jsonhttptest.Request(t, targetClient, http.MethodPost, "/pin/import", http.StatusBadRequest,
+			jsonhttptest.WithRequestHeader("Content-Type", api.ContentTypeTar),
+			jsonhttptest.WithRequestBody(tarFiles(t, []f{
+				{name: "pins.json", data: manifest},
+				{dir: "chunks", name: ch.Address().String(), data: append(ch.Data(), 0)},
+			})),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "invalid chunk: " + ch.Address().String(),
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("export missing chunk", func(t *testing.T) {
+		storer := mock.NewStorer()
+		client, _, _ := newTestServer(t, testServerOptions{
+			Storer: storer,
+		})
+		ch := testingc.GenerateTestRandomChunk()
+		if _, err := storer.Put(context.Background(), storage.ModePutUploadPin, ch); err != nil {
+			t.Fatal(err)
+		}
+		if err := storer.Set(context.Background(), storage.ModeSetRemove, ch.Address()); err != nil {
+			t.Fatal(err)
+		}
+
+		jsonhttptest.Request(t, client, http.MethodGet, "/pin/export", http.StatusInternalServerError,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "pinned chunk " + ch.Address().String() + " not found",
+				Code:    http.StatusInternalServerError,
+			}),
+		)
+	})
+
+	t.Run("not tar", func(t *testing.T) {
+		jsonhttptest.Request(t, targetClient, http.MethodPost, "/pin/import", http.StatusBadRequest,
+			jsonhttptest.WithRequestHeader("Content-Type", "application/json"),
+			jsonhttptest.WithRequestBody(bytes.NewReader(archive)),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "could not validate request",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+}
@@ -59,6 +59,9 @@ func (s *server) pinFile(w http.ResponseWriter, r *http.Request) {
 		}
 
 		s.logger.Error("pin files: cannot pin")
+		if handleQuotaError(err, w) {
+			return
+		}
 		jsonhttp.InternalServerError(w, "cannot pin")
 		return
 	}
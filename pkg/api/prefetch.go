@@ -0,0 +1,183 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yanhuangpai/voyager/pkg/collection/entry"
+	"github.com/yanhuangpai/voyager/pkg/file"
+	"github.com/yanhuangpai/voyager/pkg/file/joiner"
+	"github.com/yanhuangpai/voyager/pkg/file/loadsave"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/manifest"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+const (
+	// indexPrefetchTimeout is the maximal duration of an index document
+	// prefetch.
+	indexPrefetchTimeout = 30 * time.Second
+	// maxIndexPrefetches is the maximal number of the concurrent index
+	// document prefetches, the further prefetches are skipped.
+	maxIndexPrefetches = 16
+)
+
+var (
+	errNoIndexDocument = errors.New("no index document")
+	errFeedManifest    = errors.New("feed manifest")
+	errPrefetchLimit   = errors.New("exceeds prefetch limit")
+)
+
+// ifiRootRedirectHandler redirects the collection root to the path with the
+// trailing slash. As the browsers follow the redirect immediately, the first
+// chunks of the index document are prefetched in the background to serve the
+// follow-up request warm. The gateways do not prefetch, as the redirects of
+// the anonymous clients would start retrievals not bound to any request.
+func (s *server) ifiRootRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	if s.PrefetchChunks > 0 && !s.gatewayMode() {
+		s.prefetchIndexDocument(mux.Vars(r)["address"])
+	}
+
+	u := r.URL
+	u.Path += "/"
+	http.Redirect(w, r, u.String(), http.StatusPermanentRedirect)
+}
+
+// prefetchIndexDocument starts fetching the first chunks of the index
+// document of the collection, unless too many prefetches are in progress.
+func (s *server) prefetchIndexDocument(nameOrHex string) {
+	select {
+	case s.prefetchSem <- struct{}{}:
+	default:
+		s.metrics.IndexPrefetchSkipped.Inc()
+		return
+	}
+
+	s.prefetchWg.Add(1)
+	go func() {
+		defer func() {
+			<-s.prefetchSem
+			s.prefetchWg.Done()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), indexPrefetchTimeout)
+		defer cancel()
+		go func() {
+			select {
+			case <-s.quit:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		n, err := s.fetchIndexDocument(ctx, nameOrHex, int64(s.PrefetchChunks)*infinity.ChunkSize)
+		if err != nil {
+			s.metrics.IndexPrefetchFailed.Inc()
+			s.logger.Debugf("ifi prefetch: index document of %s: %v", nameOrHex, err)
+			return
+		}
+		s.metrics.IndexPrefetchCount.Inc()
+		s.metrics.IndexPrefetchBytes.Add(float64(n))
+	}()
+}
+
+// fetchIndexDocument reads up to the limit of bytes of the index document of
+// the collection and returns the number of bytes read.
+func (s *server) fetchIndexDocument(ctx context.Context, nameOrHex string, limit int64) (int64, error) {
+	address, err := s.resolveNameOrAddress(nameOrHex)
+	if err != nil {
+		return 0, fmt.Errorf("resolve address: %w", err)
+	}
+
+	ls := loadsave.New(s.storer, storage.ModePutRequest, false)
+	data, err := readAll(ctx, s.storer, address, limit)
+	if err != nil {
+		return 0, fmt.Errorf("read entry: %w", err)
+	}
+	if _, err := s.manifestFeed(ctx, ls, data); err == nil {
+		// the feed is resolved by the follow-up request
+		return 0, errFeedManifest
+	}
+	e, metadata, err := s.readEntry(ctx, data, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	m, err := manifest.NewManifestReference(metadata.MimeType, e.Reference(), ls)
+	if err != nil {
+		return 0, fmt.Errorf("manifest: %w", err)
+	}
+	indexDocument, ok := manifestMetadataLoad(ctx, m, manifestRootPath, manifestWebsiteIndexDocumentSuffixKey)
+	if !ok {
+		return 0, errNoIndexDocument
+	}
+	me, err := m.Lookup(ctx, indexDocument)
+	if err != nil {
+		return 0, fmt.Errorf("lookup %s: %w", indexDocument, err)
+	}
+
+	data, err = readAll(ctx, s.storer, me.Reference(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("read file entry: %w", err)
+	}
+	fe := &entry.Entry{}
+	if err := fe.UnmarshalBinary(data); err != nil {
+		return 0, fmt.Errorf("unmarshal file entry: %w", err)
+	}
+
+	j, size, err := joiner.New(ctx, s.storer, fe.Reference())
+	if err != nil {
+		return 0, fmt.Errorf("joiner: %w", err)
+	}
+	if size < limit {
+		limit = size
+	}
+	return io.Copy(ioutil.Discard, io.NewSectionReader(j, 0, limit))
+}
+
+// readEntry unmarshals the collection entry and reads up to the limit of bytes
+// of its metadata.
+func (s *server) readEntry(ctx context.Context, data []byte, limit int64) (*entry.Entry, *entry.Metadata, error) {
+	e := &entry.Entry{}
+	if err := e.UnmarshalBinary(data); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal entry: %w", err)
+	}
+	data, err := readAll(ctx, s.storer, e.Metadata(), limit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read metadata: %w", err)
+	}
+	metadata := &entry.Metadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal metadata: %w", err)
+	}
+	return e, metadata, nil
+}
+
+// readAll reads the whole data with the address, unless it is larger than the
+// limit of bytes.
+func readAll(ctx context.Context, getter storage.Getter, address infinity.Address, limit int64) ([]byte, error) {
+	j, size, err := joiner.New(ctx, getter, address)
+	if err != nil {
+		return nil, err
+	}
+	if size > limit {
+		return nil, fmt.Errorf("size %d: %w", size, errPrefetchLimit)
+	}
+	buf := bytes.NewBuffer(nil)
+	if _, err := file.JoinReadAll(ctx, j, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/file/pipeline/builder"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	smock "github.com/yanhuangpai/voyager/pkg/storage/mock"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+func TestIndexDocumentPrefetch(t *testing.T) {
+	indexData := []byte("<h1>Smart Chain Prefetch</h1>")
+
+	for _, tc := range []struct {
+		name           string
+		prefetchChunks int
+		gatewayMode    bool
+		wantFetched    bool
+	}{
+		{
+			name:           "enabled",
+			prefetchChunks: 1,
+			wantFetched:    true,
+		},
+		{
+			name:           "disabled",
+			prefetchChunks: 0,
+		},
+		{
+			name:           "gateway mode",
+			prefetchChunks: 1,
+			gatewayMode:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				logger       = logging.New(ioutil.Discard, 0)
+				storer       = &recordingStorer{Storer: smock.NewStorer()}
+				client, _, _ = newTestServer(t, testServerOptions{
+					Storer:          storer,
+					Tags:            tags.NewTags(statestore.NewStateStore(), logger),
+					Logger:          logger,
+					PreventRedirect: true,
+					PrefetchChunks:  tc.prefetchChunks,
+					GatewayMode:     tc.gatewayMode,
+				})
+			)
+
+			var dirResp, fileResp api.FileUploadResponse
+			jsonhttptest.Request(t, client, http.MethodPost, "/dirs", http.StatusOK,
+				jsonhttptest.WithRequestBody(tarFiles(t, []f{{
+					data:     indexData,
+					name:     "index.html",
+					filePath: "./index.html",
+				}})),
+				jsonhttptest.WithRequestHeader("Content-Type", api.ContentTypeTar),
+				jsonhttptest.WithRequestHeader(api.InfinityIndexDocumentHeader, "index.html"),
+				jsonhttptest.WithUnmarshalJSONResponse(&dirResp),
+			)
+			// the index document data has the same reference as the bytes
+			jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusOK,
+				jsonhttptest.WithRequestBody(bytes.NewReader(indexData)),
+				jsonhttptest.WithUnmarshalJSONResponse(&fileResp),
+			)
+
+			storer.reset()
+			header := jsonhttptest.Request(t, client, http.MethodGet, "/ifi/"+dirResp.Reference.String(), http.StatusPermanentRedirect)
+			if got, want := header.Get("Location"), "/ifi/"+dirResp.Reference.String()+"/"; got != want {
+				t.Fatalf("got location %q, want %q", got, want)
+			}
+
+			fetched := func() bool { return storer.fetched(fileResp.Reference) }
+			if !tc.wantFetched {
+				time.Sleep(100 * time.Millisecond)
+				if fetched() {
+					t.Fatal("index document prefetched")
+				}
+				return
+			}
+			for i := 0; !fetched(); i++ {
+				if i == 100 {
+					t.Fatal("index document not prefetched")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestReadAllLimit(t *testing.T) {
+	ctx := context.Background()
+	storer := smock.NewStorer()
+	data := make([]byte, infinity.ChunkSize+1)
+
+	pipe := builder.NewPipelineBuilder(ctx, storer, storage.ModePutUpload, false)
+	address, err := builder.FeedPipeline(ctx, pipe, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := api.ReadAll(ctx, storer, address, infinity.ChunkSize); !errors.Is(err, api.ErrPrefetchLimit) {
+		t.Fatalf("got error %v, want %v", err, api.ErrPrefetchLimit)
+	}
+	got, err := api.ReadAll(ctx, storer, address, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("data mismatch")
+	}
+}
+
+// recordingStorer records the addresses of the retrieved chunks.
+type recordingStorer struct {
+	storage.Storer
+	mu   sync.Mutex
+	gets []infinity.Address
+}
+
+func (s *recordingStorer) Get(ctx context.Context, mode storage.ModeGet, addr infinity.Address) (infinity.Chunk, error) {
+	s.mu.Lock()
+	s.gets = append(s.gets, addr)
+	s.mu.Unlock()
+	return s.Storer.Get(ctx, mode, addr)
+}
+
+func (s *recordingStorer) fetched(addr infinity.Address) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.gets {
+		if a.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *recordingStorer) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gets = nil
+}
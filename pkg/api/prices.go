@@ -0,0 +1,70 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+// costEstimate is the estimated cost of pushing the data of the size to the
+// network, or of retrieving it from the network.
+type costEstimate struct {
+	Size    int64  `json:"size"`
+	Chunks  int64  `json:"chunks"`
+	MinCost uint64 `json:"minCost"`
+	MaxCost uint64 `json:"maxCost"`
+}
+
+// pricesResponse holds the range of the prices paid to the peers for a chunk,
+// which depend on the proximity of the peer to the chunk.
+type pricesResponse struct {
+	MinChunkPrice uint64        `json:"minChunkPrice"`
+	MaxChunkPrice uint64        `json:"maxChunkPrice"`
+	Estimate      *costEstimate `json:"estimate,omitempty"`
+}
+
+// pricesHandler responds with the range of the chunk prices and, if the size
+// query parameter is set, with the estimated cost of the data of the size,
+// including the intermediate chunks. The encrypted data is estimated if the
+// encrypt query parameter is true.
+func (s *server) pricesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Pricer == nil {
+		jsonhttp.NotImplemented(w, "prices not available")
+		return
+	}
+
+	// the closest peer charges the lowest price for the chunk and the
+	// farthest one the highest price
+	chunk := infinity.NewAddress(make([]byte, infinity.HashSize))
+	farthest := infinity.NewAddress(bytes.Repeat([]byte{0xff}, infinity.HashSize))
+	resp := pricesResponse{
+		MinChunkPrice: s.Pricer.PeerPrice(chunk, chunk),
+		MaxChunkPrice: s.Pricer.PeerPrice(farthest, chunk),
+	}
+
+	if v := r.URL.Query().Get("size"); v != "" {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || size < 0 {
+			s.logger.Debugf("prices: invalid size %q: %v", v, err)
+			s.logger.Error("prices: invalid size")
+			jsonhttp.BadRequest(w, "invalid size")
+			return
+		}
+		chunks := calculateNumberOfChunks(size, r.URL.Query().Get("encrypt") == "true")
+		resp.Estimate = &costEstimate{
+			Size:    size,
+			Chunks:  chunks,
+			MinCost: uint64(chunks) * resp.MinChunkPrice,
+			MaxCost: uint64(chunks) * resp.MaxChunkPrice,
+		}
+	}
+
+	jsonhttp.OK(w, resp)
+}
@@ -22,7 +22,7 @@ import (
 )
 
 var (
-	writeDeadline   = 4 * time.Second // write deadline. should be smaller than the shutdown timeout on api close
+	writeDeadline   = 4 * time.Second // default write deadline. should be smaller than the shutdown timeout on api close
 	targetMaxLength = 2               // max target length in bytes, in order to prevent grieving by excess computation
 )
 
@@ -131,7 +131,7 @@ func (s *server) pumpWs(conn *websocket.Conn, t string) {
 	for {
 		select {
 		case b := <-dataC:
-			err = conn.SetWriteDeadline(time.Now().Add(writeDeadline))
+			err = conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
 			if err != nil {
 				s.logger.Debugf("pss set write deadline: %v", err)
 				return
@@ -145,7 +145,7 @@ func (s *server) pumpWs(conn *websocket.Conn, t string) {
 
 		case <-s.quit:
 			// shutdown
-			err = conn.SetWriteDeadline(time.Now().Add(writeDeadline))
+			err = conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
 			if err != nil {
 				s.logger.Debugf("pss set write deadline: %v", err)
 				return
@@ -159,7 +159,7 @@ func (s *server) pumpWs(conn *websocket.Conn, t string) {
 			// client gone
 			return
 		case <-ticker.C:
-			err = conn.SetWriteDeadline(time.Now().Add(writeDeadline))
+			err = conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
 			if err != nil {
 				s.logger.Debugf("pss set write deadline: %v", err)
 				return
@@ -0,0 +1,134 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/yanhuangpai/voyager/pkg/file/joiner"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/tracing"
+	"github.com/yanhuangpai/voyager/pkg/traversal"
+)
+
+type referenceStatsResponse struct {
+	Chunks   int                `json:"chunks"`
+	Size     int64              `json:"size"`
+	Depth    int                `json:"depth"`
+	Complete bool               `json:"complete"`
+	Missing  []infinity.Address `json:"missing,omitempty"`
+}
+
+// referenceStatsHandler walks all chunks of the reference and reports their
+// number, whether they are all stored locally and the size and the depth of
+// the chunk tree of the reference. Only the locally stored chunks are walked,
+// so the reference is not found if any of its intermediate chunks is missing.
+func (s *server) referenceStatsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := tracing.NewLoggerWithTraceID(r.Context(), s.logger)
+	ctx := r.Context()
+
+	reference, err := infinity.ParseHexAddress(mux.Vars(r)["reference"])
+	if err != nil {
+		logger.Debugf("reference stats: parse reference: %v", err)
+		logger.Error("reference stats: parse reference")
+		jsonhttp.BadRequest(w, "bad reference")
+		return
+	}
+
+	listMissing := false
+	if v := r.URL.Query().Get("missing"); v != "" {
+		listMissing, err = strconv.ParseBool(v)
+		if err != nil {
+			logger.Debugf("reference stats: parse missing %s: %v", v, err)
+			logger.Error("reference stats: bad missing")
+			jsonhttp.BadRequest(w, "bad missing")
+			return
+		}
+	}
+
+	stats, err := s.referenceStats(ctx, reference)
+	if err != nil {
+		logger.Debugf("reference stats: %s: %v", reference, err)
+		if errors.Is(err, storage.ErrNotFound) {
+			// the root or an intermediate chunk is not stored locally
+			logger.Error("reference stats: not found")
+			jsonhttp.NotFound(w, "chunk not found")
+			return
+		}
+		logger.Error("reference stats: traverse")
+		jsonhttp.InternalServerError(w, "cannot traverse reference")
+		return
+	}
+
+	if !listMissing {
+		stats.Missing = nil
+	}
+	jsonhttp.OK(w, stats)
+}
+
+func (s *server) referenceStats(ctx context.Context, reference infinity.Address) (*referenceStatsResponse, error) {
+	getter := &localStorer{Storer: s.storer}
+
+	_, size, err := joiner.New(ctx, getter, reference)
+	if err != nil {
+		return nil, err
+	}
+	state, err := joiner.CaptureState(ctx, getter, reference, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu      sync.Mutex
+		chunks  = make(map[string]struct{})
+		missing = make(map[string]struct{})
+	)
+	err = traversal.NewService(getter).TraverseAddresses(ctx, reference, func(addr infinity.Address) error {
+		has, err := s.storer.Has(ctx, addr)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		chunks[addr.ByteString()] = struct{}{}
+		if !has {
+			missing[addr.ByteString()] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &referenceStatsResponse{
+		Chunks:   len(chunks),
+		Size:     size,
+		Depth:    state.Depth(),
+		Complete: len(missing) == 0,
+		Missing:  sortedAddresses(missing),
+	}, nil
+}
+
+// sortedAddresses returns the addresses in the ascending order.
+func sortedAddresses(set map[string]struct{}) []infinity.Address {
+	addrs := make([]infinity.Address, 0, len(set))
+	for a := range set {
+		addrs = append(addrs, infinity.NewAddress([]byte(a)))
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i].Bytes(), addrs[j].Bytes()) < 0
+	})
+	return addrs
+}
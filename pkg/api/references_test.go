@@ -0,0 +1,116 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/cac"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+func TestReferenceStats(t *testing.T) {
+	var (
+		logger       = logging.New(ioutil.Discard, 0)
+		storer       = mock.NewStorer()
+		data         = make([]byte, 2*infinity.ChunkSize+1)
+		client, _, _ = newTestServer(t, testServerOptions{
+			Storer: storer,
+			Tags:   tags.NewTags(statestore.NewStateStore(), logger),
+			Logger: logger,
+		})
+	)
+	for i := range data {
+		data[i] = byte(i / infinity.ChunkSize)
+	}
+
+	var resp api.BytesPostResponse
+	jsonhttptest.Request(t, client, http.MethodPost, "/bytes", http.StatusOK,
+		jsonhttptest.WithRequestBody(bytes.NewReader(data)),
+		jsonhttptest.WithUnmarshalJSONResponse(&resp),
+	)
+	statsPath := "/references/" + resp.Reference.String() + "/stats"
+
+	t.Run("complete", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, statsPath+"?missing=true", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(api.ReferenceStatsResponse{
+				Chunks:   4, // the root and three data chunks
+				Size:     int64(len(data)),
+				Depth:    2,
+				Complete: true,
+			}),
+		)
+	})
+
+	leaf, err := cac.New(data[:infinity.ChunkSize])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storer.Set(context.Background(), storage.ModeSetRemove, leaf.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("incomplete", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, statsPath, http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(api.ReferenceStatsResponse{
+				Chunks: 4,
+				Size:   int64(len(data)),
+				Depth:  2,
+			}),
+		)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, statsPath+"?missing=true", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(api.ReferenceStatsResponse{
+				Chunks:  4,
+				Size:    int64(len(data)),
+				Depth:   2,
+				Missing: []infinity.Address{leaf.Address()},
+			}),
+		)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, "/references/"+leaf.Address().String()+"/stats", http.StatusNotFound,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusNotFound,
+				Message: "chunk not found",
+			}),
+		)
+	})
+
+	t.Run("not retrieved", func(t *testing.T) {
+		// the chunks can be retrieved, but are not stored locally
+		client, _, _ := newTestServer(t, testServerOptions{
+			Storer: &networkStorer{Storer: storer},
+			Tags:   tags.NewTags(statestore.NewStateStore(), logger),
+			Logger: logger,
+		})
+		jsonhttptest.Request(t, client, http.MethodGet, statsPath, http.StatusNotFound,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusNotFound,
+				Message: "chunk not found",
+			}),
+		)
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, "/references/abcd1/stats", http.StatusBadRequest)
+		jsonhttptest.Request(t, client, http.MethodGet, statsPath+"?missing=maybe", http.StatusBadRequest)
+	})
+}
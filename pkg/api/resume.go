@@ -0,0 +1,132 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/file/joiner"
+)
+
+const (
+	// resumeTokenTTL is the duration for which the state of an interrupted
+	// download is kept.
+	resumeTokenTTL = 10 * time.Minute
+	// maxResumeTokens is the maximal number of kept states of interrupted
+	// downloads.
+	maxResumeTokens = 1000
+	// resumeCaptureTimeout limits the time to capture the state of an
+	// interrupted download.
+	resumeCaptureTimeout = 5 * time.Second
+)
+
+var errInvalidResumeToken = errors.New("invalid resume token")
+
+// resumeTokens keeps the joiner states of interrupted downloads by the
+// resume tokens issued with the download responses.
+type resumeTokens struct {
+	states map[string]resumeState
+	mu     sync.Mutex
+}
+
+type resumeState struct {
+	state   *joiner.State
+	expires time.Time
+}
+
+func newResumeTokens() *resumeTokens {
+	return &resumeTokens{
+		states: make(map[string]resumeState),
+	}
+}
+
+// newResumeToken returns a new random resume token.
+func newResumeToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// put keeps the state under the token, dropping the expired states and the
+// one closest to expiry if there are too many of them.
+func (t *resumeTokens) put(token string, state *joiner.State) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	var oldest string
+	for k, s := range t.states {
+		if now.After(s.expires) {
+			delete(t.states, k)
+			continue
+		}
+		if oldest == "" || s.expires.Before(t.states[oldest].expires) {
+			oldest = k
+		}
+	}
+	if len(t.states) >= maxResumeTokens {
+		delete(t.states, oldest)
+	}
+
+	t.states[token] = resumeState{
+		state:   state,
+		expires: now.Add(resumeTokenTTL),
+	}
+}
+
+// take returns and removes the state kept under the token.
+func (t *resumeTokens) take(token string) (*joiner.State, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.states[token]
+	if !ok {
+		return nil, false
+	}
+	delete(t.states, token)
+	if time.Now().After(s.expires) {
+		return nil, false
+	}
+	return s.state, true
+}
+
+// offsetReadSeeker records the offset of the last seek.
+type offsetReadSeeker struct {
+	io.ReadSeeker
+	offset int64
+}
+
+func (r *offsetReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	n, err := r.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		r.offset = n
+	}
+	return n, err
+}
+
+// countingResponseWriter counts the written response body bytes and records
+// the write error.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	written int64
+	err     error
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/file/pipeline/builder"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	mockbytes "gitlab.com/nolash/go-mockbytes"
+)
+
+// TestDownloadResumeToken tests that an interrupted download can be resumed
+// with the resume token issued in the response.
+func TestDownloadResumeToken(t *testing.T) {
+	ctx := context.Background()
+	storer := mock.NewStorer()
+	s := api.New(nil, storer, nil, nil, nil, nil, nil, logging.New(ioutil.Discard, 0), nil, api.Options{})
+
+	g := mockbytes.New(0, mockbytes.MockTypeStandard).WithModulus(255)
+	content, err := g.SequentialBytes(2*infinity.ChunkSize*infinity.ChunkSize/infinity.HashSize + 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipe := builder.NewPipelineBuilder(ctx, storer, storage.ModePutUpload, false)
+	address, err := builder.FeedPipeline(ctx, pipe, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resource := "/bytes/" + address.String()
+
+	// interrupt the download after some data is written
+	limit := len(content) / 3
+	w := &interruptedResponseWriter{ResponseRecorder: httptest.NewRecorder(), limit: limit}
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, resource, nil))
+
+	token := w.Header().Get(api.InfinityResumeTokenHeader)
+	if token == "" {
+		t.Fatal("no resume token")
+	}
+	if !bytes.Equal(w.Body.Bytes(), content[:limit]) {
+		t.Fatal("interrupted download data mismatch")
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, resource, nil)
+		r.Header.Set(api.InfinityResumeTokenHeader, token)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+
+		if rec.Code != http.StatusPartialContent {
+			t.Fatalf("got status %v, want %v", rec.Code, http.StatusPartialContent)
+		}
+		if !bytes.Equal(rec.Body.Bytes(), content[limit:]) {
+			t.Fatal("resumed download data mismatch")
+		}
+		if rec.Header().Get(api.InfinityResumeTokenHeader) == "" {
+			t.Fatal("no resume token")
+		}
+	})
+
+	t.Run("used token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, resource, nil)
+		r.Header.Set(api.InfinityResumeTokenHeader, token)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("got status %v, want %v", rec.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("completed download", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, resource, nil))
+
+		r := httptest.NewRequest(http.MethodGet, resource, nil)
+		r.Header.Set(api.InfinityResumeTokenHeader, rec.Header().Get(api.InfinityResumeTokenHeader))
+		rec = httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("got status %v, want %v", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+// interruptedResponseWriter fails to write the response body after the limit
+// of bytes is written.
+type interruptedResponseWriter struct {
+	*httptest.ResponseRecorder
+	limit int
+}
+
+func (w *interruptedResponseWriter) Write(b []byte) (int, error) {
+	if left := w.limit - w.Body.Len(); len(b) > left {
+		n, _ := w.ResponseRecorder.Write(b[:left])
+		return n, errors.New("connection interrupted")
+	}
+	return w.ResponseRecorder.Write(b)
+}
@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	"resenje.org/web"
@@ -17,11 +16,13 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 	"github.com/yanhuangpai/voyager/pkg/logging/httpaccess"
+	"github.com/yanhuangpai/voyager/pkg/tags"
 )
 
-func (s *server) setupRouting() {
-	apiVersion := "v1" // only one api version exists, this should be configurable with more
+// apiVersion is the version prefix of the api paths.
+const apiVersion = "v1" // only one api version exists, this should be configurable with more
 
+func (s *server) setupRouting() {
 	handle := func(router *mux.Router, path string, handler http.Handler) {
 		router.Handle(path, handler)
 		router.Handle("/"+apiVersion+path, handler)
@@ -41,12 +42,16 @@ func (s *server) setupRouting() {
 	handle(router, "/files", jsonhttp.MethodHandler{
 		"POST": web.ChainHandlers(
 			s.newTracingHandler("files-upload"),
+			s.uploadLimitsHandler,
 			web.FinalHandlerFunc(s.fileUploadHandler),
 		),
 	})
 	handle(router, "/files/{addr}", jsonhttp.MethodHandler{
 		"GET": web.ChainHandlers(
 			s.newTracingHandler("files-download"),
+			s.costHandler,
+			s.downloadTimeoutHandler,
+			s.compressionHandler,
 			web.FinalHandlerFunc(s.fileDownloadHandler),
 		),
 	})
@@ -54,6 +59,7 @@ func (s *server) setupRouting() {
 	handle(router, "/dirs", jsonhttp.MethodHandler{
 		"POST": web.ChainHandlers(
 			s.newTracingHandler("dirs-upload"),
+			s.uploadLimitsHandler,
 			web.FinalHandlerFunc(s.dirUploadHandler),
 		),
 	})
@@ -61,58 +67,119 @@ func (s *server) setupRouting() {
 	handle(router, "/bytes", jsonhttp.MethodHandler{
 		"POST": web.ChainHandlers(
 			s.newTracingHandler("bytes-upload"),
+			s.uploadLimitsHandler,
 			web.FinalHandlerFunc(s.bytesUploadHandler),
 		),
 	})
 	handle(router, "/bytes/{address}", jsonhttp.MethodHandler{
 		"GET": web.ChainHandlers(
 			s.newTracingHandler("bytes-download"),
+			s.costHandler,
+			s.downloadTimeoutHandler,
+			s.compressionHandler,
 			web.FinalHandlerFunc(s.bytesGetHandler),
 		),
 	})
 
 	handle(router, "/chunks", jsonhttp.MethodHandler{
 		"POST": web.ChainHandlers(
+			s.costHandler,
+			s.uploadLimitsHandler,
 			jsonhttp.NewMaxBodyBytesHandler(infinity.ChunkWithSpanSize),
 			web.FinalHandlerFunc(s.chunkUploadHandler),
 		),
 	})
 
+	handle(router, "/chunks/stream", web.ChainHandlers(
+		s.costHandler,
+		s.uploadLimitsHandler,
+		web.FinalHandlerFunc(s.chunkUploadStreamHandler),
+	))
+
 	handle(router, "/chunks/{addr}", jsonhttp.MethodHandler{
-		"GET": http.HandlerFunc(s.chunkGetHandler),
+		"GET": web.ChainHandlers(
+			s.costHandler,
+			s.downloadTimeoutHandler,
+			web.FinalHandlerFunc(s.chunkGetHandler),
+		),
 	})
 
 	handle(router, "/soc/{owner}/{id}", jsonhttp.MethodHandler{
+		"GET": web.ChainHandlers(
+			s.costHandler,
+			web.FinalHandlerFunc(s.socGetHandler),
+		),
 		"POST": web.ChainHandlers(
+			s.costHandler,
 			jsonhttp.NewMaxBodyBytesHandler(infinity.ChunkWithSpanSize),
 			web.FinalHandlerFunc(s.socUploadHandler),
 		),
 	})
 
 	handle(router, "/feeds/{owner}/{topic}", jsonhttp.MethodHandler{
-		"GET": http.HandlerFunc(s.feedGetHandler),
+		"GET": web.ChainHandlers(
+			s.costHandler,
+			web.FinalHandlerFunc(s.feedGetHandler),
+		),
 		"POST": web.ChainHandlers(
 			jsonhttp.NewMaxBodyBytesHandler(infinity.ChunkWithSpanSize),
 			web.FinalHandlerFunc(s.feedPostHandler),
 		),
+		"PUT": web.ChainHandlers(
+			jsonhttp.NewMaxBodyBytesHandler(feedUpdateMaxPayloadSize),
+			web.FinalHandlerFunc(s.feedUpdateHandler),
+		),
+		"DELETE": http.HandlerFunc(s.feedUpdateHandler),
 	})
 
-	handle(router, "/ifi/{address}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		u := r.URL
-		u.Path += "/"
-		http.Redirect(w, r, u.String(), http.StatusPermanentRedirect)
-	}))
+	handle(router, "/ifi/{address}", http.HandlerFunc(s.ifiRootRedirectHandler))
 	handle(router, "/ifi/{address}/{path:.*}", jsonhttp.MethodHandler{
 		"GET": web.ChainHandlers(
 			s.newTracingHandler("ifi-download"),
+			s.costHandler,
+			s.downloadTimeoutHandler,
+			s.compressionHandler,
 			web.FinalHandlerFunc(s.ifiDownloadHandler),
 		),
 	})
 
+	handle(router, "/manifests/{address}/paths", jsonhttp.MethodHandler{
+		"GET": web.ChainHandlers(
+			s.newTracingHandler("manifest-paths"),
+			web.FinalHandlerFunc(s.manifestPathsHandler),
+		),
+	})
+	handle(router, "/manifests/{address}/paths/{path:.*}", jsonhttp.MethodHandler{
+		"HEAD": web.ChainHandlers(
+			s.newTracingHandler("manifest-path"),
+			web.FinalHandlerFunc(s.manifestPathHeadHandler),
+		),
+	})
+	handle(router, "/manifests/{address}/diff/{other}", web.ChainHandlers(
+		s.gatewayModeForbidEndpointHandler,
+		web.FinalHandler(jsonhttp.MethodHandler{
+			"GET": web.ChainHandlers(
+				s.newTracingHandler("manifest-diff"),
+				web.FinalHandlerFunc(s.manifestDiffHandler),
+			),
+		})),
+	)
+
+	handle(router, "/references/{reference}/stats", web.ChainHandlers(
+		s.gatewayModeForbidEndpointHandler,
+		web.FinalHandler(jsonhttp.MethodHandler{
+			"GET": web.ChainHandlers(
+				s.newTracingHandler("reference-stats"),
+				web.FinalHandlerFunc(s.referenceStatsHandler),
+			),
+		})),
+	)
+
 	handle(router, "/pss/send/{topic}/{targets}", web.ChainHandlers(
 		s.gatewayModeForbidEndpointHandler,
 		web.FinalHandler(jsonhttp.MethodHandler{
 			"POST": web.ChainHandlers(
+				s.costHandler,
 				jsonhttp.NewMaxBodyBytesHandler(infinity.ChunkSize),
 				web.FinalHandlerFunc(s.pssPostHandler),
 			),
@@ -124,13 +191,16 @@ func (s *server) setupRouting() {
 		web.FinalHandlerFunc(s.pssWsHandler),
 	))
 
+	tagHandlers := NewTagHandlers(s.tags, s.logger, func(t *tags.Tag) interface{} {
+		return newTagResponse(t)
+	})
 	handle(router, "/tags", web.ChainHandlers(
 		s.gatewayModeForbidEndpointHandler,
 		web.FinalHandler(jsonhttp.MethodHandler{
 			"GET": http.HandlerFunc(s.listTagsHandler),
 			"POST": web.ChainHandlers(
 				jsonhttp.NewMaxBodyBytesHandler(1024),
-				web.FinalHandlerFunc(s.createTagHandler),
+				web.FinalHandlerFunc(tagHandlers.Create),
 			),
 		})),
 	)
@@ -141,7 +211,7 @@ func (s *server) setupRouting() {
 			"DELETE": http.HandlerFunc(s.deleteTagHandler),
 			"PATCH": web.ChainHandlers(
 				jsonhttp.NewMaxBodyBytesHandler(1024),
-				web.FinalHandlerFunc(s.doneSplitHandler),
+				web.FinalHandlerFunc(tagHandlers.DoneSplit),
 			),
 		})),
 	)
@@ -164,6 +234,24 @@ func (s *server) setupRouting() {
 			"GET": http.HandlerFunc(s.listPinnedChunks),
 		})),
 	)
+	handle(router, "/pin/export", web.ChainHandlers(
+		s.gatewayModeForbidEndpointHandler,
+		web.FinalHandler(jsonhttp.MethodHandler{
+			"GET": web.ChainHandlers(
+				s.downloadTimeoutHandler,
+				web.FinalHandlerFunc(s.pinExportHandler),
+			),
+		})),
+	)
+	handle(router, "/pin/import", web.ChainHandlers(
+		s.gatewayModeForbidEndpointHandler,
+		web.FinalHandler(jsonhttp.MethodHandler{
+			"POST": web.ChainHandlers(
+				s.importLimitsHandler,
+				web.FinalHandlerFunc(s.pinImportHandler),
+			),
+		})),
+	)
 
 	handle(router, "/pin/bytes/{address}", web.ChainHandlers(
 		s.gatewayModeForbidEndpointHandler,
@@ -181,13 +269,19 @@ func (s *server) setupRouting() {
 		})),
 	)
 
+	handle(router, "/prices", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.pricesHandler),
+	})
+
 	handle(router, "/isLatest", jsonhttp.MethodHandler{
 		"GET": http.HandlerFunc(s.isLatestClientVersion),
 	})
 
+	s.router = router
 	s.Handler = web.ChainHandlers(
-		httpaccess.NewHTTPAccessLogHandler(s.logger, logrus.InfoLevel, s.tracer, "api access"),
-		handlers.CompressHandler,
+		httpaccess.NewHTTPAccessLogHandler(s.logger, logrus.InfoLevel, s.tracer, "api access", httpaccess.WithBodyCapture(s.BodyCapture)),
+		s.responseCompressionHandler,
+		s.drainHandler,
 		// todo: add recovery handler
 		s.pageviewMetricsHandler,
 		func(h http.Handler) http.Handler {
@@ -202,32 +296,46 @@ func (s *server) setupRouting() {
 				h.ServeHTTP(w, r)
 			})
 		},
+		s.gatewayHandler,
+		s.apiKeyHandler,
 		s.gatewayModeForbidHeadersHandler,
+		s.requestTimeoutHandler,
 		web.FinalHandler(router),
 	)
 }
 
+// Routes returns the routes of the API and whether they are enabled in the
+// current mode.
+func (s *server) Routes() ([]jsonhttp.Route, error) {
+	return jsonhttp.Routes(s.router)
+}
+
 func (s *server) gatewayModeForbidEndpointHandler(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if s.GatewayMode {
+	return &jsonhttp.ToggleHandler{
+		Handler: h,
+		Disabled: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			s.logger.Tracef("gateway mode: forbidden %s", r.URL.String())
+			s.metrics.GatewayRejectedCount.WithLabelValues(gatewayRejectEndpoint).Inc()
 			jsonhttp.Forbidden(w, nil)
-			return
-		}
-		h.ServeHTTP(w, r)
-	})
+		}),
+		Enabled: func() bool {
+			return !s.gatewayMode()
+		},
+	}
 }
 
 func (s *server) gatewayModeForbidHeadersHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if s.GatewayMode {
+		if s.gatewayMode() {
 			if strings.ToLower(r.Header.Get(InfinityPinHeader)) == "true" {
 				s.logger.Tracef("gateway mode: forbidden pinning %s", r.URL.String())
+				s.metrics.GatewayRejectedCount.WithLabelValues(gatewayRejectHeader).Inc()
 				jsonhttp.Forbidden(w, "pinning is disabled")
 				return
 			}
 			if strings.ToLower(r.Header.Get(InfinityEncryptHeader)) == "true" {
 				s.logger.Tracef("gateway mode: forbidden encryption %s", r.URL.String())
+				s.metrics.GatewayRejectedCount.WithLabelValues(gatewayRejectHeader).Inc()
 				jsonhttp.Forbidden(w, "encryption is disabled")
 				return
 			}
@@ -5,8 +5,11 @@
 package api
 
 import (
+	"bytes"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 
@@ -14,7 +17,11 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/cac"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/netstore"
+	"github.com/yanhuangpai/voyager/pkg/sctx"
 	"github.com/yanhuangpai/voyager/pkg/soc"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/tags"
 )
 
 var errBadRequestParams = errors.New("owner, id or span is not well formed")
@@ -55,6 +62,32 @@ func (s *server) socUploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var (
+		tag *tags.Tag
+		ctx = r.Context()
+	)
+	if h := r.Header.Get(InfinityTagHeader); h != "" {
+		tag, err = s.getTag(h)
+		if err != nil {
+			s.logger.Debugf("soc upload: get tag: %v", err)
+			s.logger.Error("soc upload: get tag")
+			jsonhttp.BadRequest(w, "cannot get tag")
+			return
+		}
+
+		// add the tag to the context if it exists
+		ctx = sctx.SetTag(r.Context(), tag)
+
+		// increment the StateSplit here since we dont have a splitter for the chunk upload
+		err = tag.Inc(tags.StateSplit)
+		if err != nil {
+			s.logger.Debugf("soc upload: increment tag: %v", err)
+			s.logger.Error("soc upload: increment tag")
+			jsonhttp.InternalServerError(w, "increment tag")
+			return
+		}
+	}
+
 	data, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		if jsonhttp.HandleBodyReadError(err, w) {
@@ -104,16 +137,13 @@ func (s *server) socUploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !soc.Valid(sch) {
+	if _, err := soc.VerifyChunk(sch); err != nil {
 		s.logger.Debugf("soc upload: invalid chunk: %v", err)
 		s.logger.Error("soc upload: invalid chunk")
 		jsonhttp.Unauthorized(w, "invalid chunk")
 		return
-
 	}
 
-	ctx := r.Context()
-
 	has, err := s.storer.Has(ctx, sch.Address())
 	if err != nil {
 		s.logger.Debugf("soc upload: store has: %v", err)
@@ -127,13 +157,109 @@ func (s *server) socUploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = s.storer.Put(ctx, requestModePut(r), sch)
+	seen, err := s.storer.Put(ctx, requestModePut(r), sch)
 	if err != nil {
 		s.logger.Debugf("soc upload: chunk write error: %v", err)
 		s.logger.Error("soc upload: chunk write error")
+		if handleQuotaError(err, w) {
+			return
+		}
 		jsonhttp.BadRequest(w, "chunk write error")
 		return
+	} else if len(seen) > 0 && seen[0] && tag != nil {
+		err := tag.Inc(tags.StateSeen)
+		if err != nil {
+			s.logger.Debugf("soc upload: increment tag: %v", err)
+			s.logger.Error("soc upload: increment tag")
+			jsonhttp.BadRequest(w, "increment tag")
+			return
+		}
 	}
 
+	if tag != nil {
+		// indicate that the chunk is stored
+		err = tag.Inc(tags.StateStored)
+		if err != nil {
+			s.logger.Debugf("soc upload: increment tag: %v", err)
+			s.logger.Error("soc upload: increment tag")
+			jsonhttp.InternalServerError(w, "increment tag")
+			return
+		}
+		w.Header().Set(InfinityTagHeader, fmt.Sprint(tag.Uid))
+	}
+
+	w.Header().Set("Access-Control-Expose-Headers", InfinityTagHeader)
 	jsonhttp.Created(w, chunkAddressResponse{Reference: sch.Address()})
 }
+
+// socGetHandler returns the data of the chunk wrapped by the single owner
+// chunk together with its signature.
+func (s *server) socGetHandler(w http.ResponseWriter, r *http.Request) {
+	owner, err := hex.DecodeString(mux.Vars(r)["owner"])
+	if err != nil {
+		s.logger.Debugf("soc get: bad owner: %v", err)
+		s.logger.Error("soc get: bad owner")
+		jsonhttp.BadRequest(w, "bad owner")
+		return
+	}
+	id, err := hex.DecodeString(mux.Vars(r)["id"])
+	if err != nil {
+		s.logger.Debugf("soc get: bad id: %v", err)
+		s.logger.Error("soc get: bad id")
+		jsonhttp.BadRequest(w, "bad id")
+		return
+	}
+
+	address, err := soc.CreateAddress(id, owner)
+	if err != nil {
+		s.logger.Debugf("soc get: create address: %v", err)
+		s.logger.Error("soc get: create address")
+		jsonhttp.InternalServerError(w, nil)
+		return
+	}
+
+	targets := r.URL.Query().Get("targets")
+	if targets != "" {
+		r = r.WithContext(sctx.SetTargets(r.Context(), targets))
+	}
+
+	ch, err := s.storer.Get(r.Context(), storage.ModeGetRequest, address)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.logger.Tracef("soc get: chunk not found. addr %s", address)
+			jsonhttp.NotFound(w, "chunk not found")
+			return
+		}
+		if errors.Is(err, netstore.ErrRecoveryAttempt) {
+			s.logger.Tracef("soc get: chunk recovery initiated. addr %s", address)
+			jsonhttp.Accepted(w, "chunk recovery initiated. retry after sometime.")
+			return
+		}
+		s.logger.Debugf("soc get: chunk read error: %v, addr %s", err, address)
+		s.logger.Error("soc get: chunk read error")
+		jsonhttp.InternalServerError(w, "chunk read error")
+		return
+	}
+
+	if !soc.Valid(ch) {
+		s.logger.Debugf("soc get: invalid chunk: addr %s", address)
+		s.logger.Error("soc get: invalid chunk")
+		jsonhttp.InternalServerError(w, "invalid chunk")
+		return
+	}
+	sch, err := soc.FromChunk(ch)
+	if err != nil {
+		s.logger.Debugf("soc get: parse chunk: %v, addr %s", err, address)
+		s.logger.Error("soc get: parse chunk")
+		jsonhttp.InternalServerError(w, "invalid chunk")
+		return
+	}
+
+	w.Header().Set("Content-Type", "binary/octet-stream")
+	w.Header().Set(InfinitySocSignatureHeader, hex.EncodeToString(sch.Signature()))
+	w.Header().Set("Access-Control-Expose-Headers", InfinitySocSignatureHeader)
+	if targets != "" {
+		w.Header().Set(TargetsRecoveryHeader, targets)
+	}
+	_, _ = io.Copy(w, bytes.NewReader(sch.WrappedChunk().Data()))
+}
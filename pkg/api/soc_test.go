@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"testing"
 
 	"github.com/yanhuangpai/voyager/pkg/api"
@@ -27,6 +28,7 @@ func TestSOC(t *testing.T) {
 	var (
 		testData       = []byte("foo")
 		socResource    = func(owner, id, sig string) string { return fmt.Sprintf("/soc/%s/%s?sig=%s", owner, id, sig) }
+		socGetResource = func(owner, id string) string { return fmt.Sprintf("/soc/%s/%s", owner, id) }
 		mockStatestore = statestore.NewStateStore()
 		logger         = logging.New(ioutil.Discard, 0)
 		tag            = tags.NewTags(mockStatestore, logger)
@@ -111,6 +113,56 @@ func TestSOC(t *testing.T) {
 		if !bytes.Equal(s.Chunk().Data(), data) {
 			t.Fatal("data retrieved doesn't match uploaded content")
 		}
+
+		// fetch the single owner chunk
+		h := jsonhttptest.Request(t, client, http.MethodGet, socGetResource(hex.EncodeToString(s.Owner), hex.EncodeToString(s.ID)), http.StatusOK,
+			jsonhttptest.WithExpectedResponse(s.WrappedChunk.Data()),
+		)
+		if got := h.Get(api.InfinitySocSignatureHeader); got != hex.EncodeToString(s.Signature) {
+			t.Fatalf("got signature %s, want %x", got, s.Signature)
+		}
+	})
+
+	t.Run("get not found", func(t *testing.T) {
+		s := testingsoc.GenerateMockSOC(t, []byte("bar"))
+
+		jsonhttptest.Request(t, client, http.MethodGet, socGetResource(hex.EncodeToString(s.Owner), hex.EncodeToString(s.ID)), http.StatusNotFound,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "chunk not found",
+				Code:    http.StatusNotFound,
+			}),
+		)
+	})
+
+	t.Run("get malformed id", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, socGetResource("8d3766440f0d7b949a5e32995d09619a7f86e632", "bifi"), http.StatusBadRequest,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "bad id",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("with tag", func(t *testing.T) {
+		s := testingsoc.GenerateMockSOC(t, []byte("baz"))
+		tg, err := tag.Create(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rcvdHeaders := jsonhttptest.Request(t, client, http.MethodPost, socResource(hex.EncodeToString(s.Owner), hex.EncodeToString(s.ID), hex.EncodeToString(s.Signature)), http.StatusCreated,
+			jsonhttptest.WithRequestBody(bytes.NewReader(s.WrappedChunk.Data())),
+			jsonhttptest.WithRequestHeader(api.InfinityTagHeader, strconv.FormatUint(uint64(tg.Uid), 10)),
+			jsonhttptest.WithExpectedJSONResponse(api.SocPostResponse{
+				Reference: s.Address(),
+			}),
+		)
+		if got := rcvdHeaders.Get(api.InfinityTagHeader); got != strconv.FormatUint(uint64(tg.Uid), 10) {
+			t.Fatalf("got tag header %q, want %d", got, tg.Uid)
+		}
+		if split, stored := tg.Get(tags.StateSplit), tg.Get(tags.StateStored); split != 1 || stored != 1 {
+			t.Fatalf("got tag split %d stored %d, want 1 and 1", split, stored)
+		}
 	})
 
 	t.Run("already exists", func(t *testing.T) {
@@ -15,6 +15,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/tags"
 )
 
@@ -23,11 +24,12 @@ type tagRequest struct {
 }
 
 type tagResponse struct {
-	Uid       uint32    `json:"uid"`
-	StartedAt time.Time `json:"startedAt"`
-	Total     int64     `json:"total"`
-	Processed int64     `json:"processed"`
-	Synced    int64     `json:"synced"`
+	Uid       uint32     `json:"uid"`
+	StartedAt time.Time  `json:"startedAt"`
+	Total     int64      `json:"total"`
+	Processed int64      `json:"processed"`
+	Synced    int64      `json:"synced"`
+	ETA       *time.Time `json:"eta,omitempty"` // estimated time of the sync completion
 }
 
 type listTagsResponse struct {
@@ -35,47 +37,135 @@ type listTagsResponse struct {
 }
 
 func newTagResponse(tag *tags.Tag) tagResponse {
+	var eta *time.Time
+	if t, err := tag.SyncETA(); err == nil {
+		eta = &t
+	}
 	return tagResponse{
 		Uid:       tag.Uid,
 		StartedAt: tag.StartedAt,
 		Total:     tag.Total,
 		Processed: tag.Stored,
 		Synced:    tag.Seen + tag.Synced,
+		ETA:       eta,
 	}
 }
 
-func (s *server) createTagHandler(w http.ResponseWriter, r *http.Request) {
-	body, err := ioutil.ReadAll(r.Body)
+// TagHandlers create the tags and mark their split done. They are shared by
+// the API and the debug API, which respond with their own representations of
+// the created tags.
+type TagHandlers struct {
+	tags     *tags.Tags
+	logger   logging.Logger
+	response func(*tags.Tag) interface{}
+}
+
+// NewTagHandlers returns the tag handlers responding to the tag creation with
+// the representation of the tag returned by the response function.
+func NewTagHandlers(t *tags.Tags, logger logging.Logger, response func(*tags.Tag) interface{}) *TagHandlers {
+	return &TagHandlers{
+		tags:     t,
+		logger:   logger,
+		response: response,
+	}
+}
+
+// Create creates a tag for the uploads made chunk by chunk, which are tracked
+// with the tag sent in the chunk upload requests. The address of the upload
+// can be associated with the tag already when it is created.
+func (h *TagHandlers) Create(w http.ResponseWriter, r *http.Request) {
+	tagr, ok := h.readTagRequest(w, r, "create tag")
+	if !ok {
+		return
+	}
+
+	tag, err := h.tags.Create(0)
 	if err != nil {
-		if jsonhttp.HandleBodyReadError(err, w) {
+		h.logger.Debugf("create tag: tag create error: %v", err)
+		h.logger.Error("create tag: tag create error")
+		jsonhttp.InternalServerError(w, "cannot create tag")
+		return
+	}
+
+	if !tagr.Address.IsZero() {
+		if err := tag.SetAddress(tagr.Address); err != nil {
+			h.logger.Debugf("create tag: set address %s: %v", tagr.Address, err)
+			h.logger.Errorf("create tag: set address %s", tagr.Address)
+			jsonhttp.InternalServerError(w, "cannot create tag")
 			return
 		}
-		s.logger.Debugf("create tag: read request body error: %v", err)
-		s.logger.Error("create tag: read request body error")
-		jsonhttp.InternalServerError(w, "cannot read request")
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, private, max-age=0")
+	jsonhttp.Created(w, h.response(tag))
+}
+
+// DoneSplit marks the split of the upload tracked with the tag done, setting
+// its total to the number of the chunks uploaded with the tag, and associates
+// the address of the upload with the tag.
+func (h *TagHandlers) DoneSplit(w http.ResponseWriter, r *http.Request) {
+	idStr := mux.Vars(r)["id"]
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		h.logger.Debugf("done split: parse id  %s: %v", idStr, err)
+		h.logger.Error("done split: parse id")
+		jsonhttp.BadRequest(w, "invalid id")
 		return
 	}
 
-	tagr := tagRequest{}
-	if len(body) > 0 {
-		err = json.Unmarshal(body, &tagr)
-		if err != nil {
-			s.logger.Debugf("create tag: unmarshal tag name error: %v", err)
-			s.logger.Errorf("create tag: unmarshal tag name error")
-			jsonhttp.InternalServerError(w, "error unmarshaling metadata")
+	tagr, ok := h.readTagRequest(w, r, "done split")
+	if !ok {
+		return
+	}
+
+	tag, err := h.tags.Get(uint32(id))
+	if err != nil {
+		if errors.Is(err, tags.ErrNotFound) {
+			h.logger.Debugf("done split: tag not present: %v, id %s", err, idStr)
+			h.logger.Error("done split: tag not present")
+			jsonhttp.NotFound(w, "tag not present")
 			return
 		}
+		h.logger.Debugf("done split: tag %v: %v", idStr, err)
+		h.logger.Errorf("done split: %v", idStr)
+		jsonhttp.InternalServerError(w, "cannot get tag")
+		return
 	}
 
-	tag, err := s.tags.Create(0)
-	if err != nil {
-		s.logger.Debugf("create tag: tag create error: %v", err)
-		s.logger.Error("create tag: tag create error")
-		jsonhttp.InternalServerError(w, "cannot create tag")
+	if _, err := tag.DoneSplit(tagr.Address); err != nil {
+		h.logger.Debugf("done split: tag %v address %s: %v", idStr, tagr.Address, err)
+		h.logger.Errorf("done split: tag %v", idStr)
+		jsonhttp.InternalServerError(w, "cannot update tag")
 		return
 	}
-	w.Header().Set("Cache-Control", "no-cache, private, max-age=0")
-	jsonhttp.Created(w, newTagResponse(tag))
+
+	jsonhttp.OK(w, "ok")
+}
+
+// readTagRequest reads the optional tag request body, responding with the
+// bad request status if it is malformed.
+func (h *TagHandlers) readTagRequest(w http.ResponseWriter, r *http.Request, op string) (tagr tagRequest, ok bool) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		if jsonhttp.HandleBodyReadError(err, w) {
+			return tagr, false
+		}
+		h.logger.Debugf("%s: read request body: %v", op, err)
+		h.logger.Errorf("%s: read request body", op)
+		jsonhttp.InternalServerError(w, "cannot read request")
+		return tagr, false
+	}
+
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &tagr); err != nil {
+			h.logger.Debugf("%s: unmarshal request: %v", op, err)
+			h.logger.Errorf("%s: unmarshal request", op)
+			jsonhttp.BadRequest(w, "invalid request")
+			return tagr, false
+		}
+	}
+	return tagr, true
 }
 
 func (s *server) getTagHandler(w http.ResponseWriter, r *http.Request) {
@@ -136,63 +226,6 @@ func (s *server) deleteTagHandler(w http.ResponseWriter, r *http.Request) {
 	jsonhttp.NoContent(w)
 }
 
-func (s *server) doneSplitHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := mux.Vars(r)["id"]
-
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		s.logger.Debugf("done split tag: parse id  %s: %v", idStr, err)
-		s.logger.Error("done split tag: parse id")
-		jsonhttp.BadRequest(w, "invalid id")
-		return
-	}
-
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		if jsonhttp.HandleBodyReadError(err, w) {
-			return
-		}
-		s.logger.Debugf("done split tag: read request body error: %v", err)
-		s.logger.Error("done split tag: read request body error")
-		jsonhttp.InternalServerError(w, "cannot read request")
-		return
-	}
-
-	tagr := tagRequest{}
-	if len(body) > 0 {
-		err = json.Unmarshal(body, &tagr)
-		if err != nil {
-			s.logger.Debugf("done split tag: unmarshal tag name error: %v", err)
-			s.logger.Errorf("done split tag: unmarshal tag name error")
-			jsonhttp.InternalServerError(w, "error unmarshaling metadata")
-			return
-		}
-	}
-
-	tag, err := s.tags.Get(uint32(id))
-	if err != nil {
-		if errors.Is(err, tags.ErrNotFound) {
-			s.logger.Debugf("done split: tag not present: %v, id %s", err, idStr)
-			s.logger.Error("done split: tag not present")
-			jsonhttp.NotFound(w, "tag not present")
-			return
-		}
-		s.logger.Debugf("done split: tag %v: %v", idStr, err)
-		s.logger.Errorf("done split: %v", idStr)
-		jsonhttp.InternalServerError(w, "cannot get tag")
-		return
-	}
-
-	_, err = tag.DoneSplit(tagr.Address)
-	if err != nil {
-		s.logger.Debugf("done split: failed for address %v", tagr.Address)
-		s.logger.Errorf("done split: failed for address %v", tagr.Address)
-		jsonhttp.InternalServerError(w, nil)
-		return
-	}
-	jsonhttp.OK(w, "ok")
-}
-
 func (s *server) listTagsHandler(w http.ResponseWriter, r *http.Request) {
 	var (
 		err           error
@@ -347,6 +347,60 @@ func TestTags(t *testing.T) {
 
 // isTagFoundInResponse verifies that the tag id is found in the supplied HTTP headers
 // if an API tag response is supplied, it also verifies that it contains an id which matches the headers
+// TestTagsRequest tests that the tag is associated with the address sent when
+// it is created, and that the malformed tag requests are rejected with the
+// bad request status, as in the debug API.
+func TestTagsRequest(t *testing.T) {
+	var (
+		tagsStore    = tags.NewTags(statestore.NewStateStore(), logging.New(ioutil.Discard, 0))
+		address      = infinity.MustParseHexAddress("aabbcc")
+		client, _, _ = newTestServer(t, testServerOptions{
+			Storer: mock.NewStorer(),
+			Tags:   tagsStore,
+		})
+	)
+
+	t.Run("create with address", func(t *testing.T) {
+		var tr api.TagResponse
+		jsonhttptest.Request(t, client, http.MethodPost, "/tags", http.StatusCreated,
+			jsonhttptest.WithJSONRequestBody(api.TagRequest{Address: address}),
+			jsonhttptest.WithUnmarshalJSONResponse(&tr),
+		)
+		tag, err := tagsStore.GetByAddress(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Uid != tr.Uid {
+			t.Fatalf("got tag %d by address, want %d", tag.Uid, tr.Uid)
+		}
+	})
+
+	t.Run("create invalid request", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodPost, "/tags", http.StatusBadRequest,
+			jsonhttptest.WithRequestBody(bytes.NewReader([]byte("{"))),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "invalid request",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("done split invalid request", func(t *testing.T) {
+		var tr api.TagResponse
+		jsonhttptest.Request(t, client, http.MethodPost, "/tags", http.StatusCreated,
+			jsonhttptest.WithJSONRequestBody(api.TagRequest{}),
+			jsonhttptest.WithUnmarshalJSONResponse(&tr),
+		)
+		jsonhttptest.Request(t, client, http.MethodPatch, tagsWithIdResource(tr.Uid), http.StatusBadRequest,
+			jsonhttptest.WithRequestBody(bytes.NewReader([]byte("{"))),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "invalid request",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+}
+
 func isTagFoundInResponse(t *testing.T, headers http.Header, tr *api.TagResponse) uint32 {
 	t.Helper()
 
@@ -0,0 +1,112 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+// requestTimeoutHandler sets the deadline of the request context to the
+// timeout given by the client in the Infinity-Timeout header, which bounds
+// the whole handling of the request, including the chunk retrieval from the
+// network. The request which fails after its deadline is exceeded is
+// responded with the gateway timeout status.
+func (s *server) requestTimeoutHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.Header.Get(InfinityTimeoutHeader)
+		if v == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		timeout, err := parseTimeout(v)
+		if err != nil {
+			s.logger.Debugf("request timeout: parse %s header %q: %v", InfinityTimeoutHeader, v, err)
+			s.logger.Error("request timeout: parse header")
+			jsonhttp.BadRequest(w, "invalid timeout")
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		h.ServeHTTP(&timeoutResponseWriter{ResponseWriter: w, ctx: ctx}, r.WithContext(ctx))
+	})
+}
+
+// parseTimeout parses the timeout given as a duration, for example 1m30s, or
+// as a number of seconds.
+func parseTimeout(v string) (time.Duration, error) {
+	var timeout time.Duration
+	if seconds, err := strconv.ParseUint(v, 10, 32); err == nil {
+		timeout = time.Duration(seconds) * time.Second
+	} else {
+		timeout, err = time.ParseDuration(v)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if timeout <= 0 {
+		return 0, errors.New("timeout not positive")
+	}
+	return timeout, nil
+}
+
+// timeoutResponseWriter replaces the error response written after the
+// deadline of the request is exceeded with the gateway timeout response, as
+// the error is caused by the deadline. It keeps the response writer flushable
+// and hijackable, as the timeout header may be sent to any endpoint,
+// including the websockets.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (w *timeoutResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if code >= http.StatusBadRequest && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+		w.Header().Del("Content-Length")
+		jsonhttp.GatewayTimeout(w.ResponseWriter, nil)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.timedOut {
+		// the body of the replaced response is discarded
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *timeoutResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *timeoutResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer is not a hijacker")
+	}
+	return h.Hijack()
+}
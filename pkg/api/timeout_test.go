@@ -0,0 +1,118 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/pss"
+	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+	testingc "github.com/yanhuangpai/voyager/pkg/storage/testing"
+	"github.com/yanhuangpai/voyager/pkg/tags"
+)
+
+// deadlineStorer waits for the context of the request to be done on the gets
+// of the chunks which are not stored, as the retrieval from the network does.
+type deadlineStorer struct {
+	storage.Storer
+}
+
+func (s deadlineStorer) Get(ctx context.Context, mode storage.ModeGet, addr infinity.Address) (infinity.Chunk, error) {
+	ch, err := s.Storer.Get(ctx, mode, addr)
+	if err == nil {
+		return ch, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRequestTimeout(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	storer := mock.NewStorer()
+	client, _, _ := newTestServer(t, testServerOptions{
+		Storer: deadlineStorer{Storer: storer},
+		Tags:   tags.NewTags(statestore.NewStateStore(), logger),
+		Logger: logger,
+	})
+
+	chunk := testingc.GenerateTestRandomChunk()
+	if _, err := storer.Put(context.Background(), storage.ModePutUpload, chunk); err != nil {
+		t.Fatal(err)
+	}
+	missing := testingc.GenerateTestRandomChunk().Address()
+
+	t.Run("stored chunk", func(t *testing.T) {
+		jsonhttptest.Request(t, client, http.MethodGet, "/chunks/"+chunk.Address().String(), http.StatusOK,
+			jsonhttptest.WithRequestHeader(api.InfinityTimeoutHeader, "10s"),
+			jsonhttptest.WithExpectedResponse(chunk.Data()),
+		)
+	})
+
+	for _, timeout := range []string{"100ms", "1"} {
+		t.Run("deadline exceeded "+timeout, func(t *testing.T) {
+			start := time.Now()
+			jsonhttptest.Request(t, client, http.MethodGet, "/chunks/"+missing.String(), http.StatusGatewayTimeout,
+				jsonhttptest.WithRequestHeader(api.InfinityTimeoutHeader, timeout),
+				jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+					Code:    http.StatusGatewayTimeout,
+					Message: http.StatusText(http.StatusGatewayTimeout),
+				}),
+			)
+			if d := time.Since(start); d > 5*time.Second {
+				t.Fatalf("request took %s", d)
+			}
+		})
+	}
+
+	for _, timeout := range []string{"soon", "0", "-1s"} {
+		t.Run("invalid "+timeout, func(t *testing.T) {
+			jsonhttptest.Request(t, client, http.MethodGet, "/chunks/"+missing.String(), http.StatusBadRequest,
+				jsonhttptest.WithRequestHeader(api.InfinityTimeoutHeader, timeout),
+				jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+					Code:    http.StatusBadRequest,
+					Message: "invalid timeout",
+				}),
+			)
+		})
+	}
+}
+
+func TestRequestTimeoutWebsocket(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	privkey, err := crypto.GenerateSecp256k1Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, listener := newTestServer(t, testServerOptions{
+		Pss:    pss.New(privkey, logger),
+		Storer: mock.NewStorer(),
+		Logger: logger,
+	})
+
+	// the websocket upgrade hijacks the response writer of the timeout
+	u := url.URL{Scheme: "ws", Host: listener, Path: "/pss/subscribe/testtopic"}
+	header := http.Header{api.InfinityTimeoutHeader: []string{"10s"}}
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
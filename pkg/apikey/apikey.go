@@ -0,0 +1,300 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package apikey provides the keys of the API clients with the daily quotas
+// of their requests and of the bytes they upload and download. The keys and
+// their usage are persisted in the state store, the usage periodically.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+const keyPrefix = "apikey_"
+
+var (
+	// ErrNotFound is returned for a key which does not exist or is revoked.
+	ErrNotFound = errors.New("api key not found")
+	// ErrQuotaExceeded is returned if the daily quota of the key is used up.
+	ErrQuotaExceeded = errors.New("api key quota exceeded")
+)
+
+// timeNow is used to deterministically mock time.Now() in tests.
+var timeNow = time.Now
+
+// persistInterval is the interval of persisting the usage of the keys.
+var persistInterval = time.Minute
+
+// Quota is the number of requests and bytes allowed per day, the zero values
+// do not limit them.
+type Quota struct {
+	Requests uint64 `json:"requests"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+// Usage is the number of requests and bytes used on the day, which is the
+// UTC date in the YYYY-MM-DD format.
+type Usage struct {
+	Day      string `json:"day"`
+	Requests uint64 `json:"requests"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+// Key is an API key. The key itself is not stored, only its hash which is the
+// id of the key.
+type Key struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Quota   Quota     `json:"quota"`
+	Usage   Usage     `json:"usage"`
+}
+
+// Service holds the API keys and accounts their usage.
+type Service struct {
+	store  storage.StateStorer
+	logger logging.Logger
+	mu     sync.Mutex
+	keys   map[string]*Key     // keyed by the id
+	dirty  map[string]struct{} // ids of the keys with the usage not persisted
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New returns the API keys persisted in the state store. The usage of the
+// keys is persisted until the service is closed.
+func New(store storage.StateStorer, logger logging.Logger) (*Service, error) {
+	s := &Service{
+		store:  store,
+		logger: logger,
+		keys:   make(map[string]*Key),
+		dirty:  make(map[string]struct{}),
+		quit:   make(chan struct{}),
+	}
+
+	if err := store.Iterate(keyPrefix, func(k, v []byte) (bool, error) {
+		var key Key
+		if err := json.Unmarshal(v, &key); err != nil {
+			return true, fmt.Errorf("unmarshal key %s: %w", k, err)
+		}
+		s.keys[key.ID] = &key
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.persistWorker()
+
+	return s, nil
+}
+
+// Create creates a new key with the name and the quota. The returned secret is
+// sent by the clients and is not retrievable later.
+func (s *Service) Create(name string, quota Quota) (secret string, key Key, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", Key{}, fmt.Errorf("generate key: %w", err)
+	}
+	secret = hex.EncodeToString(b)
+
+	key = Key{
+		ID:      id(secret),
+		Name:    name,
+		Created: timeNow().UTC(),
+		Quota:   quota,
+		Usage:   Usage{Day: day(timeNow())},
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.store.Put(storeKey(key.ID), key); err != nil {
+		return "", Key{}, fmt.Errorf("put key: %w", err)
+	}
+	s.keys[key.ID] = &key
+	s.logger.Infof("api keys: created key %s %q", key.ID, name)
+
+	return secret, key, nil
+}
+
+// Revoke removes the key with the id.
+func (s *Service) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[id]; !ok {
+		return ErrNotFound
+	}
+	if err := s.store.Delete(storeKey(id)); err != nil {
+		return fmt.Errorf("delete key: %w", err)
+	}
+	delete(s.keys, id)
+	delete(s.dirty, id)
+	s.logger.Infof("api keys: revoked key %s", id)
+
+	return nil
+}
+
+// Keys returns all keys sorted by the time of their creation, with the usage
+// of the current day.
+func (s *Service) Keys() []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	today := day(timeNow())
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		key := *k
+		if key.Usage.Day != today {
+			key.Usage = Usage{Day: today}
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Created.Equal(keys[j].Created) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].Created.Before(keys[j].Created)
+	})
+	return keys
+}
+
+// Authorize returns the id of the key with the secret and counts a request
+// if the quota of the key allows the request with the size in bytes, which is
+// not checked if it is not known in advance.
+func (s *Service) Authorize(secret string, size int64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id(secret)]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	s.resetUsage(key)
+	if key.Quota.Requests > 0 && key.Usage.Requests >= key.Quota.Requests {
+		return "", ErrQuotaExceeded
+	}
+	if key.Quota.Bytes > 0 {
+		if size < 0 {
+			size = 0
+		}
+		if key.Usage.Bytes >= key.Quota.Bytes || uint64(size) > key.Quota.Bytes-key.Usage.Bytes {
+			return "", ErrQuotaExceeded
+		}
+	}
+	key.Usage.Requests++
+	s.dirty[key.ID] = struct{}{}
+
+	return key.ID, nil
+}
+
+// Account adds the bytes uploaded or downloaded with an authorized request to
+// the usage of the key. It is called as the bytes are transferred, and returns
+// ErrQuotaExceeded once the bytes quota of the key is used up, so that the
+// transfers which are not bounded, as over the websockets, can be stopped.
+func (s *Service) Account(id string, bytes uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		// revoked while the request was served
+		return nil
+	}
+
+	s.resetUsage(key)
+	key.Usage.Bytes += bytes
+	s.dirty[id] = struct{}{}
+	if key.Quota.Bytes > 0 && key.Usage.Bytes > key.Quota.Bytes {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Close stops persisting the usage of the keys and persists the usage not
+// persisted yet.
+func (s *Service) Close() error {
+	close(s.quit)
+	s.wg.Wait()
+
+	return s.persist()
+}
+
+// persistWorker persists the usage of the keys in the persist intervals.
+func (s *Service) persistWorker() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(persistInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.persist(); err != nil {
+				s.logger.Debugf("api keys: persist usage: %v", err)
+				s.logger.Error("api keys: cannot persist usage")
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// persist stores the keys with the usage changed since they were last
+// persisted.
+func (s *Service) persist() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.dirty {
+		if err := s.store.Put(storeKey(id), s.keys[id]); err != nil {
+			return fmt.Errorf("put key %s: %w", id, err)
+		}
+		delete(s.dirty, id)
+	}
+	return nil
+}
+
+// Reset returns the time when the daily usage of the keys is reset.
+func Reset() time.Time {
+	y, m, d := timeNow().UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+// resetUsage resets the usage of the key on the new day. It must be called
+// with the lock held.
+func (s *Service) resetUsage(key *Key) {
+	if today := day(timeNow()); key.Usage.Day != today {
+		key.Usage = Usage{Day: today}
+	}
+}
+
+// id returns the id of the key with the secret.
+func id(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// day returns the UTC date of the time.
+func day(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// storeKey returns the state store key of the key with the id.
+func storeKey(id string) string {
+	return keyPrefix + id
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package apikey_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/apikey"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/statestore/mock"
+)
+
+func TestAPIKeys(t *testing.T) {
+	now := time.Date(2021, 3, 1, 23, 0, 0, 0, time.UTC)
+	apikey.SetTimeNow(func() time.Time { return now })
+	defer apikey.SetTimeNow(time.Now)
+
+	logger := logging.New(ioutil.Discard, 0)
+	store := mock.NewStateStore()
+
+	s, err := apikey.New(store, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, key, err := s.Create("client", apikey.Quota{Requests: 2, Bytes: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Authorize("unknown", 0); !errors.Is(err, apikey.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, apikey.ErrNotFound)
+	}
+	if _, err := s.Authorize(secret, 101); !errors.Is(err, apikey.ErrQuotaExceeded) {
+		t.Fatalf("got error %v, want %v", err, apikey.ErrQuotaExceeded)
+	}
+
+	id, err := s.Authorize(secret, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != key.ID {
+		t.Fatalf("got id %s, want %s", id, key.ID)
+	}
+	if err := s.Account(id, 60); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authorize(secret, 50); !errors.Is(err, apikey.ErrQuotaExceeded) {
+		t.Fatalf("got error %v, want %v", err, apikey.ErrQuotaExceeded)
+	}
+	if _, err := s.Authorize(secret, -1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authorize(secret, -1); !errors.Is(err, apikey.ErrQuotaExceeded) {
+		t.Fatalf("got error %v, want %v", err, apikey.ErrQuotaExceeded)
+	}
+	if err := s.Account(id, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	// the keys and their usage are persisted
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = apikey.New(store, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := s.Keys()
+	if len(keys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(keys))
+	}
+	if keys[0].ID != key.ID || keys[0].Name != "client" {
+		t.Fatalf("got key %+v, want %+v", keys[0], key)
+	}
+	if u := keys[0].Usage; u.Requests != 2 || u.Bytes != 70 {
+		t.Fatalf("got usage %+v, want 2 requests and 70 bytes", u)
+	}
+
+	// the usage is reset on the next day
+	if want := time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC); !apikey.Reset().Equal(want) {
+		t.Fatalf("got reset %v, want %v", apikey.Reset(), want)
+	}
+	now = now.Add(time.Hour)
+	if _, err := s.Authorize(secret, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(key.ID); !errors.Is(err, apikey.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, apikey.ErrNotFound)
+	}
+	if _, err := s.Authorize(secret, 0); !errors.Is(err, apikey.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, apikey.ErrNotFound)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = apikey.New(store, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if keys := s.Keys(); len(keys) != 0 {
+		t.Fatalf("got %d keys after revoke, want 0", len(keys))
+	}
+}
+
+func TestAPIKeysAccount(t *testing.T) {
+	apikey.SetPersistInterval(10 * time.Millisecond)
+	defer apikey.SetPersistInterval(time.Minute)
+
+	store := mock.NewStateStore()
+	s, err := apikey.New(store, logging.New(ioutil.Discard, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	secret, key, err := s.Create("client", apikey.Quota{Bytes: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.Authorize(secret, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Account(id, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Account(id, 1); !errors.Is(err, apikey.ErrQuotaExceeded) {
+		t.Fatalf("got error %v, want %v", err, apikey.ErrQuotaExceeded)
+	}
+
+	// the usage is persisted periodically
+	for i := 0; ; i++ {
+		var k apikey.Key
+		if err := store.Get("apikey_"+key.ID, &k); err != nil {
+			t.Fatal(err)
+		}
+		if k.Usage.Requests == 1 && k.Usage.Bytes == 101 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("got persisted usage %+v, want 1 request and 101 bytes", k.Usage)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package apikey
+
+import "time"
+
+func SetTimeNow(f func() time.Time) {
+	timeNow = f
+}
+
+func SetPersistInterval(d time.Duration) {
+	persistInterval = d
+}
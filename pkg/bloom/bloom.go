@@ -0,0 +1,124 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package bloom provides the Bloom filter of the chunk addresses, which
+// answers whether an address was added to it with no false negatives and
+// with a bounded rate of false positives.
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+const (
+	// headerSize is the size of the encoded number of the hash functions
+	// and of the tweak.
+	headerSize = 1 + 4
+	// minSize is the minimal size of the bit array in bytes, which keeps
+	// the false positive rate negligible for the small filters.
+	minSize = 128
+	// maxHashes is the maximal number of the hash functions.
+	maxHashes = 32
+)
+
+// ErrInvalidFilter is returned when the encoded filter is malformed.
+var ErrInvalidFilter = errors.New("invalid bloom filter")
+
+// Filter is the Bloom filter of the chunk addresses.
+type Filter struct {
+	hashes uint8
+	tweak  uint32
+	bits   []byte
+}
+
+// New returns the filter sized for n addresses with the false positive rate
+// p. The tweak changes the bits set for the addresses, so that the filters
+// with different tweaks have independent false positives.
+func New(n int, p float64, tweak uint32) *Filter {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	size := int(math.Ceil(m / 8))
+	if size < minSize {
+		size = minSize
+	}
+	hashes := int(math.Round(float64(size*8) / float64(n) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	if hashes > maxHashes {
+		hashes = maxHashes
+	}
+	return &Filter{
+		hashes: uint8(hashes),
+		tweak:  tweak,
+		bits:   make([]byte, size),
+	}
+}
+
+// Add adds the address to the filter.
+func (f *Filter) Add(addr infinity.Address) {
+	h1, h2 := f.hash(addr)
+	m := uint64(len(f.bits)) * 8
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		b := (h1 + i*h2) % m
+		f.bits[b/8] |= 1 << (b % 8)
+	}
+}
+
+// Has returns true if the address was added to the filter or if it is a
+// false positive.
+func (f *Filter) Has(addr infinity.Address) bool {
+	h1, h2 := f.hash(addr)
+	m := uint64(len(f.bits)) * 8
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		b := (h1 + i*h2) % m
+		if f.bits[b/8]&(1<<(b%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hash returns the two hashes of the address combined into the hashes of
+// the filter.
+func (f *Filter) hash(addr infinity.Address) (h1, h2 uint64) {
+	h := fnv.New64a()
+	var tweak [4]byte
+	binary.BigEndian.PutUint32(tweak[:], f.tweak)
+	_, _ = h.Write(tweak[:])
+	_, _ = h.Write(addr.Bytes())
+	s := h.Sum64()
+	return s & math.MaxUint32, s>>32 | 1
+}
+
+// MarshalBinary encodes the filter as the number of the hash functions, the
+// tweak and the bit array.
+func (f *Filter) MarshalBinary() ([]byte, error) {
+	b := make([]byte, headerSize+len(f.bits))
+	b[0] = f.hashes
+	binary.BigEndian.PutUint32(b[1:headerSize], f.tweak)
+	copy(b[headerSize:], f.bits)
+	return b, nil
+}
+
+// UnmarshalBinary decodes the filter encoded by MarshalBinary.
+func (f *Filter) UnmarshalBinary(b []byte) error {
+	if len(b) <= headerSize || b[0] == 0 || b[0] > maxHashes {
+		return ErrInvalidFilter
+	}
+	f.hashes = b[0]
+	f.tweak = binary.BigEndian.Uint32(b[1:headerSize])
+	f.bits = append([]byte(nil), b[headerSize:]...)
+	return nil
+}
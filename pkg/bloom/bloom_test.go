@@ -0,0 +1,89 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bloom_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/bloom"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/infinity/test"
+)
+
+func TestFilter(t *testing.T) {
+	const (
+		n = 10000
+		p = 0.01
+	)
+
+	f := bloom.New(n, p, 42)
+	added := make([]infinity.Address, n)
+	for i := range added {
+		added[i] = test.RandomAddress()
+		f.Add(added[i])
+	}
+
+	b, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded bloom.Filter
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filter := range []*bloom.Filter{f, &decoded} {
+		for _, a := range added {
+			if !filter.Has(a) {
+				t.Fatalf("added address %s not in the filter", a)
+			}
+		}
+
+		var positives int
+		for i := 0; i < n; i++ {
+			if filter.Has(test.RandomAddress()) {
+				positives++
+			}
+		}
+		if rate := float64(positives) / n; rate > 2*p {
+			t.Errorf("got false positive rate %v, want at most %v", rate, 2*p)
+		}
+	}
+}
+
+func TestFilterTweak(t *testing.T) {
+	addr := test.RandomAddress()
+	f1 := bloom.New(1, 0.01, 1)
+	f1.Add(addr)
+	f2 := bloom.New(1, 0.01, 2)
+	f2.Add(addr)
+
+	b1, err := f1.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, err := f2.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b1[5:]) == string(b2[5:]) {
+		t.Fatal("filters with different tweaks set the same bits")
+	}
+}
+
+func TestFilterUnmarshalInvalid(t *testing.T) {
+	for _, b := range [][]byte{
+		nil,
+		{1, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0xff},
+		{33, 0, 0, 0, 0, 0xff},
+	} {
+		var f bloom.Filter
+		if err := f.UnmarshalBinary(b); !errors.Is(err, bloom.ErrInvalidFilter) {
+			t.Errorf("unmarshal %x: got error %v, want %v", b, err, bloom.ErrInvalidFilter)
+		}
+	}
+}
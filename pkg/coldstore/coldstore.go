@@ -0,0 +1,60 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package coldstore provides the cold storage tier which the chunks collected
+// by the garbage collection of the local store are offloaded to, so that they
+// can be restored on access instead of being retrieved from the network.
+package coldstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+// Store stores the chunks in the cold tier.
+type Store interface {
+	// Put stores the chunk, overwriting the chunk with the same address.
+	Put(ctx context.Context, ch infinity.Chunk) error
+	// Get returns the chunk with the address, storage.ErrNotFound if it is
+	// not stored.
+	Get(ctx context.Context, addr infinity.Address) (infinity.Chunk, error)
+	// Delete removes the chunk with the address, it is not an error if the
+	// chunk is not stored.
+	Delete(ctx context.Context, addr infinity.Address) error
+}
+
+// Policy selects the chunks collected by the garbage collection which are
+// offloaded to the cold tier, the other ones are removed.
+type Policy struct {
+	// MinProximity is the minimal proximity order of the chunk address to
+	// the node address, so that only the chunks closer to the node are
+	// offloaded, all chunks if zero.
+	MinProximity uint8
+	// MaxIdle is the maximal time since the chunk was last accessed, so that
+	// only the recently used chunks are offloaded, not limited if zero.
+	MaxIdle time.Duration
+	// MaxAge is the maximal time the chunk is kept in the cold tier since it
+	// was offloaded, after which it is evicted, not limited if zero.
+	MaxAge time.Duration
+}
+
+// Offload reports whether the chunk with the proximity order to the node
+// address, which was not accessed for the idle time, is offloaded.
+func (p Policy) Offload(proximity uint8, idle time.Duration) bool {
+	if proximity < p.MinProximity {
+		return false
+	}
+	if p.MaxIdle > 0 && idle > p.MaxIdle {
+		return false
+	}
+	return true
+}
+
+// Expired reports whether the chunk offloaded for the age is evicted from the
+// cold tier.
+func (p Policy) Expired(age time.Duration) bool {
+	return p.MaxAge > 0 && age > p.MaxAge
+}
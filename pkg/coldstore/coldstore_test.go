@@ -0,0 +1,87 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coldstore_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/coldstore"
+)
+
+func TestPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		policy    coldstore.Policy
+		proximity uint8
+		idle      time.Duration
+		want      bool
+	}{
+		{
+			name: "all",
+			idle: time.Hour,
+			want: true,
+		},
+		{
+			name:      "close",
+			policy:    coldstore.Policy{MinProximity: 4},
+			proximity: 4,
+			want:      true,
+		},
+		{
+			name:      "far",
+			policy:    coldstore.Policy{MinProximity: 4},
+			proximity: 3,
+		},
+		{
+			name:   "recent",
+			policy: coldstore.Policy{MaxIdle: time.Hour},
+			idle:   time.Minute,
+			want:   true,
+		},
+		{
+			name:   "idle",
+			policy: coldstore.Policy{MaxIdle: time.Hour},
+			idle:   2 * time.Hour,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.Offload(tc.proximity, tc.idle); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPolicyExpired(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy coldstore.Policy
+		age    time.Duration
+		want   bool
+	}{
+		{
+			name: "not limited",
+			age:  time.Hour,
+		},
+		{
+			name:   "fresh",
+			policy: coldstore.Policy{MaxAge: time.Hour},
+			age:    time.Minute,
+		},
+		{
+			name:   "expired",
+			policy: coldstore.Policy{MaxAge: time.Hour},
+			age:    2 * time.Hour,
+			want:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.Expired(tc.age); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mock provides the cold store keeping the chunks in memory.
+package mock
+
+import (
+	"context"
+	"sync"
+
+	"github.com/yanhuangpai/voyager/pkg/coldstore"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+var _ coldstore.Store = (*Store)(nil)
+
+// Store keeps the chunks in memory.
+type Store struct {
+	mu     sync.Mutex
+	chunks map[string][]byte
+	putErr error
+}
+
+// Option is the option of the mock store.
+type Option interface {
+	apply(*Store)
+}
+
+type optionFunc func(*Store)
+
+func (f optionFunc) apply(s *Store) { f(s) }
+
+// WithPutError makes all uploads of the chunks fail with the error.
+func WithPutError(err error) Option {
+	return optionFunc(func(s *Store) {
+		s.putErr = err
+	})
+}
+
+// New returns the empty store.
+func New(opts ...Option) *Store {
+	s := &Store{
+		chunks: make(map[string][]byte),
+	}
+	for _, o := range opts {
+		o.apply(s)
+	}
+	return s
+}
+
+func (s *Store) Put(_ context.Context, ch infinity.Chunk) error {
+	if s.putErr != nil {
+		return s.putErr
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks[ch.Address().ByteString()] = append([]byte(nil), ch.Data()...)
+	return nil
+}
+
+func (s *Store) Get(_ context.Context, addr infinity.Address) (infinity.Chunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.chunks[addr.ByteString()]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return infinity.NewChunk(addr, data), nil
+}
+
+func (s *Store) Delete(_ context.Context, addr infinity.Address) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.chunks, addr.ByteString())
+	return nil
+}
+
+// Has reports whether the chunk with the address is stored.
+func (s *Store) Has(addr infinity.Address) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.chunks[addr.ByteString()]
+	return ok
+}
+
+// Len returns the number of the stored chunks.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.chunks)
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coldstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/soc"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+
+	// maxChunkDataSize is the size of the largest chunk data, which is the
+	// single owner chunk with the full payload.
+	maxChunkDataSize = soc.IdSize + soc.SignatureSize + infinity.ChunkWithSpanSize
+)
+
+// ErrInvalidS3Options is returned if the S3 storage options are invalid.
+var ErrInvalidS3Options = errors.New("coldstore: invalid s3 options")
+
+// S3Options are the options of the S3 compatible object storage.
+type S3Options struct {
+	// Endpoint is the URL of the storage, for example https://s3.amazonaws.com,
+	// the bucket is addressed in the path of the URL.
+	Endpoint string
+	// Region is the region of the bucket, us-east-1 if not set.
+	Region string
+	// Bucket is the name of the bucket the chunks are stored in.
+	Bucket string
+	// Prefix is prepended to the hex chunk addresses in the object keys.
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// Client is the HTTP client of the storage, http.DefaultClient if not
+	// set.
+	Client *http.Client
+}
+
+var _ Store = (*S3)(nil)
+
+// S3 stores the chunks as the objects of an S3 compatible object storage.
+type S3 struct {
+	endpoint *url.URL
+	o        S3Options
+}
+
+// NewS3 returns the store of the chunks in the bucket of the S3 compatible
+// object storage.
+func NewS3(o S3Options) (*S3, error) {
+	endpoint, err := url.Parse(o.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("%w: endpoint %q", ErrInvalidS3Options, o.Endpoint)
+	}
+	if o.Bucket == "" {
+		return nil, fmt.Errorf("%w: no bucket", ErrInvalidS3Options)
+	}
+	if o.Region == "" {
+		o.Region = defaultS3Region
+	}
+	if o.Client == nil {
+		o.Client = http.DefaultClient
+	}
+	return &S3{
+		endpoint: endpoint,
+		o:        o,
+	}, nil
+}
+
+// Put uploads the chunk data as the object.
+func (s *S3) Put(ctx context.Context, ch infinity.Chunk) error {
+	resp, err := s.do(ctx, http.MethodPut, ch.Address(), ch.Data())
+	if err != nil {
+		return fmt.Errorf("put chunk %s: %w", ch.Address(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("put chunk %s: %w", ch.Address(), responseError(resp))
+	}
+	return nil
+}
+
+// Get downloads the chunk data from the object.
+func (s *S3) Get(ctx context.Context, addr infinity.Address) (infinity.Chunk, error) {
+	resp, err := s.do(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get chunk %s: %w", addr, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, storage.ErrNotFound
+	default:
+		return nil, fmt.Errorf("get chunk %s: %w", addr, responseError(resp))
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxChunkDataSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("get chunk %s: %w", addr, err)
+	}
+	if len(data) > maxChunkDataSize {
+		return nil, fmt.Errorf("get chunk %s: object too large", addr)
+	}
+	return infinity.NewChunk(addr, data), nil
+}
+
+// Delete removes the object of the chunk.
+func (s *S3) Delete(ctx context.Context, addr infinity.Address) error {
+	resp, err := s.do(ctx, http.MethodDelete, addr, nil)
+	if err != nil {
+		return fmt.Errorf("delete chunk %s: %w", addr, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("delete chunk %s: %w", addr, responseError(resp))
+	}
+}
+
+// do sends the signed request for the object of the chunk.
+func (s *S3) do(ctx context.Context, method string, addr infinity.Address, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.o.Bucket + "/" + s.o.Prefix + addr.String()
+
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		payloadHash = hashHex(body)
+		r.ContentLength = int64(len(body))
+	}
+	signV4(r, payloadHash, s.o.Region, s.o.AccessKey, s.o.SecretKey, time.Now())
+
+	return s.o.Client.Do(r)
+}
+
+// responseError returns the error with the status and the beginning of the
+// body of the unexpected response.
+func responseError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	if len(body) == 0 {
+		return fmt.Errorf("response status %s", resp.Status)
+	}
+	return fmt.Errorf("response status %s: %s", resp.Status, bytes.TrimSpace(body))
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coldstore_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/coldstore"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	testingc "github.com/yanhuangpai/voyager/pkg/storage/testing"
+)
+
+func TestS3(t *testing.T) {
+	server := newS3Server(t, "bucket")
+	defer server.Close()
+
+	s, err := coldstore.NewS3(coldstore.S3Options{
+		Endpoint:  server.URL,
+		Bucket:    "bucket",
+		Prefix:    "chunks/",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	ch := testingc.GenerateTestRandomChunk()
+
+	if _, err := s.Get(ctx, ch.Address()); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, storage.ErrNotFound)
+	}
+
+	if err := s.Put(ctx, ch); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.object("chunks/" + ch.Address().String()); !ok {
+		t.Fatal("object not stored under the prefixed key")
+	}
+
+	got, err := s.Get(ctx, ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(ch) {
+		t.Fatalf("got chunk %s, want %s", got, ch)
+	}
+
+	if err := s.Delete(ctx, ch.Address()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, ch.Address()); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, storage.ErrNotFound)
+	}
+	if err := s.Delete(ctx, ch.Address()); err != nil {
+		t.Fatalf("delete missing chunk: %v", err)
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		s, err := coldstore.NewS3(coldstore.S3Options{
+			Endpoint: server.URL,
+			Bucket:   "other",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.Put(ctx, ch)
+		if err == nil || !strings.Contains(err.Error(), "403") {
+			t.Fatalf("got error %v, want forbidden", err)
+		}
+	})
+}
+
+func TestNewS3(t *testing.T) {
+	for _, o := range []coldstore.S3Options{
+		{Endpoint: "", Bucket: "bucket"},
+		{Endpoint: "://invalid", Bucket: "bucket"},
+		{Endpoint: "http://localhost:9000"},
+	} {
+		if _, err := coldstore.NewS3(o); !errors.Is(err, coldstore.ErrInvalidS3Options) {
+			t.Errorf("options %+v: got error %v, want %v", o, err, coldstore.ErrInvalidS3Options)
+		}
+	}
+}
+
+// s3Server is the S3 object storage with a single bucket which accepts only
+// the signed requests.
+type s3Server struct {
+	*httptest.Server
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newS3Server(t *testing.T, bucket string) *s3Server {
+	t.Helper()
+
+	s := &s3Server{
+		bucket:  bucket,
+		objects: make(map[string][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *s3Server) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=") || r.Header.Get("X-Amz-Date") == "" {
+		http.Error(w, "missing signature", http.StatusForbidden)
+		return
+	}
+	prefix := "/" + s.bucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.objects[key] = data
+	case http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *s3Server) object(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	return data, ok
+}
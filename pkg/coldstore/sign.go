@@ -0,0 +1,112 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coldstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signAlgorithm  = "AWS4-HMAC-SHA256"
+	signService    = "s3"
+	signTimeFormat = "20060102T150405Z"
+	signDateFormat = "20060102"
+)
+
+// emptyPayloadHash is the SHA-256 hash of the empty request body.
+var emptyPayloadHash = hashHex(nil)
+
+// signV4 signs the request to the S3 service with the AWS signature version
+// 4, the request body is given by its SHA-256 hash. All headers set on the
+// request are signed, together with the host.
+func signV4(r *http.Request, payloadHash, region, accessKey, secretKey string, t time.Time) {
+	t = t.UTC()
+	r.Header.Set("X-Amz-Date", t.Format(signTimeFormat))
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range r.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		path,
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format(signDateFormat), region, signService, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		t.Format(signTimeFormat),
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), t.Format(signDateFormat))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, signService)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	r.Header.Set("Authorization", signAlgorithm+" Credential="+accessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery returns the query parameters sorted by the names and encoded
+// as required by the signature.
+func canonicalQuery(q url.Values) string {
+	params := make([]string, 0, len(q))
+	for name, values := range q {
+		for _, v := range values {
+			params = append(params, uriEncode(name)+"="+uriEncode(v))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+func uriEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
@@ -24,21 +24,27 @@ const (
 	AddressSize = 20
 )
 
-// NewOverlayAddress constructs a Smart Chain Address from ECDSA public key.
-func NewOverlayAddress(p ecdsa.PublicKey, networkID uint64) (infinity.Address, error) {
+// NewOverlayAddress constructs a Smart Chain Address from ECDSA public key
+// and the overlay nonce, which is empty unless the overlay address is mined.
+func NewOverlayAddress(p ecdsa.PublicKey, networkID uint64, nonce []byte) (infinity.Address, error) {
 	ethAddr, err := NewEthereumAddress(p)
 	if err != nil {
 		return infinity.ZeroAddress, err
 	}
-	return NewOverlayFromEthereumAddress(ethAddr, networkID), nil
+	return NewOverlayFromEthereumAddress(ethAddr, networkID, nonce), nil
 }
 
-// NewOverlayFromEthereumAddress constructs a Smart Chain Address for an Ethereum address.
-func NewOverlayFromEthereumAddress(ethAddr []byte, networkID uint64) infinity.Address {
+// NewOverlayFromEthereumAddress constructs a Smart Chain Address for an
+// Ethereum address and the overlay nonce. The address derived with the empty
+// nonce is the same as the one of the nodes not using the nonce.
+func NewOverlayFromEthereumAddress(ethAddr []byte, networkID uint64, nonce []byte) infinity.Address {
 	netIDBytes := make([]byte, 8)
 	binary.LittleEndian.PutUint64(netIDBytes, networkID)
-	h := sha3.Sum256(append(ethAddr, netIDBytes...))
-	return infinity.NewAddress(h[:])
+	h := sha3.New256()
+	_, _ = h.Write(ethAddr)
+	_, _ = h.Write(netIDBytes)
+	_, _ = h.Write(nonce)
+	return infinity.NewAddress(h.Sum(nil))
 }
 
 // GenerateSecp256k1Key generates an ECDSA private key using
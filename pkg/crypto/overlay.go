@@ -0,0 +1,65 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+// MaxOverlayNonceSize is the maximal size of the overlay nonce accepted from
+// the peers.
+const MaxOverlayNonceSize = 32
+
+// ErrInvalidNeighborhood is returned if the neighborhood prefix is longer than
+// the overlay address.
+var ErrInvalidNeighborhood = errors.New("invalid neighborhood")
+
+// MineOverlayNonce searches for the overlay nonce with which the overlay
+// address derived from the Ethereum address is in the neighborhood, the
+// overlay addresses starting with the first bits of the prefix. About 2^bits
+// overlay addresses are derived until one in the neighborhood is found, it
+// returns the context error if it is done before.
+func MineOverlayNonce(ctx context.Context, ethAddr []byte, networkID uint64, prefix []byte, bits int) ([]byte, infinity.Address, error) {
+	if bits < 0 || bits > infinity.HashSize*8 || bits > len(prefix)*8 {
+		return nil, infinity.ZeroAddress, ErrInvalidNeighborhood
+	}
+
+	nonce := make([]byte, 8)
+	for i := uint64(0); ; i++ {
+		// the context is checked only from time to time, as it is much
+		// slower than the derivation of the address
+		if i%4096 == 0 {
+			select {
+			case <-ctx.Done():
+				return nil, infinity.ZeroAddress, ctx.Err()
+			default:
+			}
+		}
+		binary.BigEndian.PutUint64(nonce, i)
+		overlay := NewOverlayFromEthereumAddress(ethAddr, networkID, nonce)
+		if hasPrefix(overlay.Bytes(), prefix, bits) {
+			return nonce, overlay, nil
+		}
+	}
+}
+
+// hasPrefix reports whether the first bits of b are the same as the ones of
+// the prefix.
+func hasPrefix(b, prefix []byte, bits int) bool {
+	n := bits / 8
+	if !bytes.Equal(b[:n], prefix[:n]) {
+		return false
+	}
+	if r := bits % 8; r > 0 {
+		mask := byte(0xff) << (8 - r)
+		return b[n]&mask == prefix[n]&mask
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package crypto_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+)
+
+func TestNewOverlayFromEthereumAddress(t *testing.T) {
+	ethAddr, err := hex.DecodeString("8d3766440f0d7b949a5e32995d09619a7f86e632")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the overlay address of the nodes without the nonce is not changed
+	a := crypto.NewOverlayFromEthereumAddress(ethAddr, 1, nil)
+	if want := "94b374273c9bcd662fbc9c8cb1c44b2d99962967cb5c037a948a4fc6098e73a0"; a.String() != want {
+		t.Fatalf("got overlay %s, want %s", a, want)
+	}
+	if b := crypto.NewOverlayFromEthereumAddress(ethAddr, 1, []byte{}); !a.Equal(b) {
+		t.Fatalf("got overlay %s with the empty nonce, want %s", b, a)
+	}
+	if b := crypto.NewOverlayFromEthereumAddress(ethAddr, 1, []byte{0}); a.Equal(b) {
+		t.Fatal("got the same overlay with the nonce")
+	}
+	if b := crypto.NewOverlayFromEthereumAddress(ethAddr, 2, nil); a.Equal(b) {
+		t.Fatal("got the same overlay with another network id")
+	}
+}
+
+func TestMineOverlayNonce(t *testing.T) {
+	ethAddr, err := hex.DecodeString("8d3766440f0d7b949a5e32995d09619a7f86e632")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		prefix []byte
+		bits   int
+	}{
+		{name: "none", prefix: nil, bits: 0},
+		{name: "bin", prefix: []byte{0xa0}, bits: 3},
+		{name: "byte", prefix: []byte{0x5c}, bits: 8},
+		{name: "bytes", prefix: []byte{0xff, 0x80}, bits: 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nonce, overlay, err := crypto.MineOverlayNonce(context.Background(), ethAddr, 1, tc.prefix, tc.bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := crypto.NewOverlayFromEthereumAddress(ethAddr, 1, nonce); !got.Equal(overlay) {
+				t.Fatalf("got overlay %s, want %s", got, overlay)
+			}
+			for i := 0; i < tc.bits; i++ {
+				got := overlay.Bytes()[i/8] >> (7 - i%8) & 1
+				want := tc.prefix[i/8] >> (7 - i%8) & 1
+				if got != want {
+					t.Fatalf("overlay %s: got bit %d %d, want %d", overlay, i, got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("invalid neighborhood", func(t *testing.T) {
+		if _, _, err := crypto.MineOverlayNonce(context.Background(), ethAddr, 1, []byte{0}, 9); !errors.Is(err, crypto.ErrInvalidNeighborhood) {
+			t.Fatalf("got error %v, want %v", err, crypto.ErrInvalidNeighborhood)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		prefix := make([]byte, 32)
+		if _, _, err := crypto.MineOverlayNonce(ctx, ethAddr, 1, prefix, 256); !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error %v, want %v", err, context.Canceled)
+		}
+	})
+}
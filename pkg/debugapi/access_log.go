@@ -0,0 +1,72 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+type bodyCaptureRequest struct {
+	SampleRate float64 `json:"sampleRate"`
+	MaxSize    int     `json:"maxSize"`
+}
+
+type bodyCaptureResponse struct {
+	SampleRate float64 `json:"sampleRate"`
+	MaxSize    int     `json:"maxSize"`
+}
+
+// bodyCaptureHandler reports the sampling of the bodies logged in the API
+// access log.
+func (s *Service) bodyCaptureHandler(w http.ResponseWriter, r *http.Request) {
+	s.handlerMu.RLock()
+	c := s.bodyCapture
+	s.handlerMu.RUnlock()
+
+	if c == nil {
+		jsonhttp.NotImplemented(w, "body capture not supported")
+		return
+	}
+
+	sampleRate, maxSize := c.Get()
+	jsonhttp.OK(w, bodyCaptureResponse{
+		SampleRate: sampleRate,
+		MaxSize:    maxSize,
+	})
+}
+
+// setBodyCaptureHandler changes the sampling of the bodies logged in the API
+// access log.
+func (s *Service) setBodyCaptureHandler(w http.ResponseWriter, r *http.Request) {
+	s.handlerMu.RLock()
+	c := s.bodyCapture
+	s.handlerMu.RUnlock()
+
+	if c == nil {
+		jsonhttp.NotImplemented(w, "body capture not supported")
+		return
+	}
+
+	var req bodyCaptureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Debugf("debug api: body capture: decode request: %v", err)
+		s.logger.Error("debug api: body capture: bad request")
+		jsonhttp.BadRequest(w, "bad request")
+		return
+	}
+
+	if err := c.Set(req.SampleRate, req.MaxSize); err != nil {
+		s.logger.Debugf("debug api: body capture: set: %v", err)
+		s.logger.Error("debug api: body capture: bad request")
+		jsonhttp.BadRequest(w, err)
+		return
+	}
+	s.logger.Infof("debug api: api access log body capture set to sample rate %v and max size %d", req.SampleRate, req.MaxSize)
+
+	jsonhttp.OK(w, nil)
+}
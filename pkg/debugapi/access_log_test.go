@@ -0,0 +1,77 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging/httpaccess"
+)
+
+func TestBodyCapture(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		c, err := httpaccess.NewBodyCapture(0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testServer := newTestServer(t, testServerOptions{
+			BodyCapture: c,
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/access-log/body-capture", http.StatusOK,
+			jsonhttptest.WithJSONRequestBody(debugapi.BodyCaptureRequest{
+				SampleRate: 0.1,
+				MaxSize:    1024,
+			}),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusOK,
+				Message: http.StatusText(http.StatusOK),
+			}),
+		)
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/access-log/body-capture", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.BodyCaptureResponse{
+				SampleRate: 0.1,
+				MaxSize:    1024,
+			}),
+		)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		c, err := httpaccess.NewBodyCapture(0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testServer := newTestServer(t, testServerOptions{
+			BodyCapture: c,
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/access-log/body-capture", http.StatusBadRequest,
+			jsonhttptest.WithJSONRequestBody(debugapi.BodyCaptureRequest{
+				SampleRate: 2,
+				MaxSize:    1024,
+			}),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusBadRequest,
+				Message: httpaccess.ErrInvalidSampleRate.Error(),
+			}),
+		)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/access-log/body-capture", http.StatusNotImplemented,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusNotImplemented,
+				Message: "body capture not supported",
+			}),
+		)
+	})
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yanhuangpai/voyager/pkg/apikey"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+type apiKeyQuota struct {
+	DailyRequests uint64 `json:"dailyRequests"`
+	DailyBytes    uint64 `json:"dailyBytes"`
+}
+
+type apiKeyUsage struct {
+	Requests uint64 `json:"requests"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+type apiKeyResponse struct {
+	ID      string      `json:"id"`
+	Name    string      `json:"name"`
+	Created time.Time   `json:"created"`
+	Quota   apiKeyQuota `json:"quota"`
+	Usage   apiKeyUsage `json:"usage"`
+}
+
+type apiKeysResponse struct {
+	Keys []apiKeyResponse `json:"keys"`
+}
+
+// apiKeyCreateRequest is the name and the daily quota of the API key to
+// create, the zero quotas do not limit the key.
+type apiKeyCreateRequest struct {
+	Name string `json:"name"`
+	apiKeyQuota
+}
+
+// apiKeyCreateResponse holds the secret of the created key, which is sent by
+// the clients in the Authorization header.
+type apiKeyCreateResponse struct {
+	apiKeyResponse
+	Key string `json:"key"`
+}
+
+// SetAPIKeys sets the keys of the API clients which are managed with the API
+// keys requests.
+func (s *Service) SetAPIKeys(k *apikey.Service) {
+	s.handlerMu.Lock()
+	defer s.handlerMu.Unlock()
+
+	s.apiKeys = k
+}
+
+// getAPIKeys returns the API keys, responding with the not implemented
+// status if they are not set.
+func (s *Service) getAPIKeys(w http.ResponseWriter) (*apikey.Service, bool) {
+	s.handlerMu.RLock()
+	k := s.apiKeys
+	s.handlerMu.RUnlock()
+
+	if k == nil {
+		jsonhttp.NotImplemented(w, "api keys not supported")
+		return nil, false
+	}
+	return k, true
+}
+
+// apiKeysHandler lists the API keys with their usage on the current day.
+func (s *Service) apiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	k, ok := s.getAPIKeys(w)
+	if !ok {
+		return
+	}
+
+	keys := k.Keys()
+	resp := apiKeysResponse{Keys: make([]apiKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, newAPIKeyResponse(key))
+	}
+
+	jsonhttp.OK(w, resp)
+}
+
+// createAPIKeyHandler creates an API key and responds with its secret, which
+// is not retrievable later.
+func (s *Service) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	k, ok := s.getAPIKeys(w)
+	if !ok {
+		return
+	}
+
+	var req apiKeyCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Debugf("debug api: create api key: decode request: %v", err)
+		s.logger.Error("debug api: create api key: bad request")
+		jsonhttp.BadRequest(w, "bad request")
+		return
+	}
+
+	secret, key, err := k.Create(req.Name, apikey.Quota{
+		Requests: req.DailyRequests,
+		Bytes:    req.DailyBytes,
+	})
+	if err != nil {
+		s.logger.Debugf("debug api: create api key: %v", err)
+		s.logger.Error("debug api: create api key: cannot create key")
+		jsonhttp.InternalServerError(w, "cannot create api key")
+		return
+	}
+
+	jsonhttp.Created(w, apiKeyCreateResponse{
+		apiKeyResponse: newAPIKeyResponse(key),
+		Key:            secret,
+	})
+}
+
+// revokeAPIKeyHandler revokes the API key with the id.
+func (s *Service) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	k, ok := s.getAPIKeys(w)
+	if !ok {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if err := k.Revoke(id); err != nil {
+		s.logger.Debugf("debug api: revoke api key %s: %v", id, err)
+		if errors.Is(err, apikey.ErrNotFound) {
+			s.logger.Errorf("debug api: revoke api key: key %s not found", id)
+			jsonhttp.NotFound(w, "api key not found")
+			return
+		}
+		s.logger.Errorf("debug api: revoke api key: cannot revoke key %s", id)
+		jsonhttp.InternalServerError(w, "cannot revoke api key")
+		return
+	}
+
+	jsonhttp.OK(w, nil)
+}
+
+func newAPIKeyResponse(key apikey.Key) apiKeyResponse {
+	return apiKeyResponse{
+		ID:      key.ID,
+		Name:    key.Name,
+		Created: key.Created,
+		Quota: apiKeyQuota{
+			DailyRequests: key.Quota.Requests,
+			DailyBytes:    key.Quota.Bytes,
+		},
+		Usage: apiKeyUsage{
+			Requests: key.Usage.Requests,
+			Bytes:    key.Usage.Bytes,
+		},
+	}
+}
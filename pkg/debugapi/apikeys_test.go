@@ -0,0 +1,81 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/apikey"
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/statestore/mock"
+)
+
+func TestAPIKeys(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		k, err := apikey.New(mock.NewStateStore(), logging.New(ioutil.Discard, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer k.Close()
+		testServer := newTestServer(t, testServerOptions{
+			APIKeys: k,
+		})
+
+		var created debugapi.APIKeyCreateResponse
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/apikeys", http.StatusCreated,
+			jsonhttptest.WithJSONRequestBody(map[string]interface{}{
+				"name":          "gateway client",
+				"dailyRequests": 100,
+				"dailyBytes":    1 << 20,
+			}),
+			jsonhttptest.WithUnmarshalJSONResponse(&created),
+		)
+		if created.Key == "" {
+			t.Fatal("no key created")
+		}
+		if created.Name != "gateway client" || created.Quota.DailyRequests != 100 || created.Quota.DailyBytes != 1<<20 {
+			t.Fatalf("got key %+v", created)
+		}
+		if _, err := k.Authorize(created.Key, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		var resp debugapi.APIKeysResponse
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/apikeys", http.StatusOK,
+			jsonhttptest.WithUnmarshalJSONResponse(&resp),
+		)
+		if len(resp.Keys) != 1 || resp.Keys[0].ID != created.ID || resp.Keys[0].Usage.Requests != 1 {
+			t.Fatalf("got keys %+v", resp.Keys)
+		}
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodDelete, "/apikeys/"+created.ID, http.StatusOK)
+		if _, err := k.Authorize(created.Key, 0); err == nil {
+			t.Fatal("revoked key authorized")
+		}
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodDelete, "/apikeys/"+created.ID, http.StatusNotFound,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusNotFound,
+				Message: "api key not found",
+			}),
+		)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/apikeys", http.StatusNotImplemented,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusNotImplemented,
+				Message: "api keys not supported",
+			}),
+		)
+	})
+}
@@ -310,23 +310,50 @@ func (s *Service) swapCashoutStatusHandler(w http.ResponseWriter, r *http.Reques
 
 type chequebookTxResponse struct {
 	TransactionHash common.Hash `json:"transactionHash"`
+	StatusURL       string      `json:"statusUrl"`
 }
 
-func (s *Service) chequebookWithdrawHandler(w http.ResponseWriter, r *http.Request) {
+// chequebookAmount returns the positive amount of the deposit or withdraw
+// request.
+func chequebookAmount(r *http.Request) (*big.Int, bool) {
 	amountStr := r.URL.Query().Get("amount")
 	if amountStr == "" {
-		jsonhttp.BadRequest(w, errChequebookNoAmount)
-		s.logger.Error("debug api: no withdraw amount")
-		return
+		return nil, false
 	}
-
 	amount, ok := big.NewInt(0).SetString(amountStr, 10)
+	if !ok || amount.Sign() <= 0 {
+		return nil, false
+	}
+	return amount, true
+}
+
+func (s *Service) chequebookWithdrawHandler(w http.ResponseWriter, r *http.Request) {
+	amount, ok := chequebookAmount(r)
 	if !ok {
 		jsonhttp.BadRequest(w, errChequebookNoAmount)
 		s.logger.Error("debug api: invalid withdraw amount")
 		return
 	}
 
+	// the funds of the pending withdrawals are not available for the next
+	// ones, so that the outstanding cheques stay covered
+	s.withdrawMu.Lock()
+	defer s.withdrawMu.Unlock()
+
+	available, err := s.chequebook.AvailableBalance(r.Context())
+	if err != nil {
+		jsonhttp.InternalServerError(w, errChequebookBalance)
+		s.logger.Debugf("debug api: chequebook withdraw: get available balance: %v", err)
+		s.logger.Error("debug api: cannot withdraw from chequebook")
+		return
+	}
+	if available.Sub(available, s.chequebookTxs.pending(chequebookTxWithdraw)).Cmp(amount) < 0 {
+		jsonhttp.BadRequest(w, errChequebookInsufficientFunds)
+		s.logger.Debugf("debug api: chequebook withdraw: available balance %s lower than %s", available, amount)
+		s.logger.Error("debug api: cannot withdraw from chequebook")
+		return
+	}
+
 	txHash, err := s.chequebook.Withdraw(r.Context(), amount)
 	if errors.Is(err, chequebook.ErrInsufficientFunds) {
 		jsonhttp.BadRequest(w, errChequebookInsufficientFunds)
@@ -341,18 +368,14 @@ func (s *Service) chequebookWithdrawHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	jsonhttp.OK(w, chequebookTxResponse{TransactionHash: txHash})
+	s.chequebookTxs.add(txHash, chequebookTxWithdraw, amount)
+	go s.waitForChequebookTx(txHash, s.chequebook.WaitForWithdraw)
+
+	jsonhttp.OK(w, chequebookTxResponse{TransactionHash: txHash, StatusURL: chequebookTxStatusURL(txHash)})
 }
 
 func (s *Service) chequebookDepositHandler(w http.ResponseWriter, r *http.Request) {
-	amountStr := r.URL.Query().Get("amount")
-	if amountStr == "" {
-		jsonhttp.BadRequest(w, errChequebookNoAmount)
-		s.logger.Error("debug api: no deposit amount")
-		return
-	}
-
-	amount, ok := big.NewInt(0).SetString(amountStr, 10)
+	amount, ok := chequebookAmount(r)
 	if !ok {
 		jsonhttp.BadRequest(w, errChequebookNoAmount)
 		s.logger.Error("debug api: invalid deposit amount")
@@ -373,5 +396,8 @@ func (s *Service) chequebookDepositHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	jsonhttp.OK(w, chequebookTxResponse{TransactionHash: txHash})
+	s.chequebookTxs.add(txHash, chequebookTxDeposit, amount)
+	go s.waitForChequebookTx(txHash, s.chequebook.WaitForDeposit)
+
+	jsonhttp.OK(w, chequebookTxResponse{TransactionHash: txHash, StatusURL: chequebookTxStatusURL(txHash)})
 }
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/yanhuangpai/voyager/pkg/debugapi"
@@ -137,11 +138,18 @@ func TestChequebookWithdraw(t *testing.T) {
 		return common.Hash{}, nil
 	}
 
+	chequebookAvailableBalanceFunc := func(context.Context) (*big.Int, error) {
+		return big.NewInt(1000), nil
+	}
+
 	testServer := newTestServer(t, testServerOptions{
-		ChequebookOpts: []mock.Option{mock.WithChequebookWithdrawFunc(chequebookWithdrawFunc)},
+		ChequebookOpts: []mock.Option{
+			mock.WithChequebookWithdrawFunc(chequebookWithdrawFunc),
+			mock.WithChequebookAvailableBalanceFunc(chequebookAvailableBalanceFunc),
+		},
 	})
 
-	expected := &debugapi.ChequebookTxResponse{TransactionHash: txHash}
+	expected := &debugapi.ChequebookTxResponse{TransactionHash: txHash, StatusURL: "/chequebook/transaction/" + txHash.String()}
 
 	var got *debugapi.ChequebookTxResponse
 	jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/chequebook/withdraw?amount=500", http.StatusOK,
@@ -153,6 +161,67 @@ func TestChequebookWithdraw(t *testing.T) {
 	}
 }
 
+func TestChequebookWithdrawPending(t *testing.T) {
+	txHash := common.HexToHash("0xfffff")
+	confirm := make(chan struct{})
+
+	testServer := newTestServer(t, testServerOptions{
+		ChequebookOpts: []mock.Option{
+			mock.WithChequebookWithdrawFunc(func(ctx context.Context, amount *big.Int) (common.Hash, error) {
+				return txHash, nil
+			}),
+			mock.WithChequebookAvailableBalanceFunc(func(context.Context) (*big.Int, error) {
+				return big.NewInt(1000), nil
+			}),
+			mock.WithChequebookWaitForWithdrawFunc(func(ctx context.Context, tx common.Hash) error {
+				select {
+				case <-confirm:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}),
+		},
+	})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/chequebook/withdraw?amount=600", http.StatusOK)
+
+	// the funds of the pending withdrawal are not available
+	jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/chequebook/withdraw?amount=600", http.StatusBadRequest,
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Code:    http.StatusBadRequest,
+			Message: "insufficient funds",
+		}),
+	)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/chequebook/transaction/"+txHash.String(), http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.ChequebookTxStatusResponse{
+			TransactionHash: txHash,
+			Type:            "withdraw",
+			Amount:          big.NewInt(600),
+			Status:          "pending",
+		}),
+	)
+
+	close(confirm)
+	waitChequebookTxStatus(t, testServer, txHash, "confirmed")
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/chequebook/withdraw?amount=600", http.StatusOK)
+}
+
+func TestChequebookWithdrawInvalidAmount(t *testing.T) {
+	testServer := newTestServer(t, testServerOptions{})
+
+	for _, amount := range []string{"", "abc", "0", "-100"} {
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/chequebook/withdraw?amount="+amount, http.StatusBadRequest,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusBadRequest,
+				Message: "did not specify amount",
+			}),
+		)
+	}
+}
+
 func TestChequebookDeposit(t *testing.T) {
 
 	txHash := common.HexToHash("0xfffff")
@@ -164,11 +233,18 @@ func TestChequebookDeposit(t *testing.T) {
 		return common.Hash{}, nil
 	}
 
+	chequebookWaitForDepositFunc := func(ctx context.Context, tx common.Hash) error {
+		return errors.New("reverted")
+	}
+
 	testServer := newTestServer(t, testServerOptions{
-		ChequebookOpts: []mock.Option{mock.WithChequebookDepositFunc(chequebookDepositFunc)},
+		ChequebookOpts: []mock.Option{
+			mock.WithChequebookDepositFunc(chequebookDepositFunc),
+			mock.WithChequebookWaitForDepositFunc(chequebookWaitForDepositFunc),
+		},
 	})
 
-	expected := &debugapi.ChequebookTxResponse{TransactionHash: txHash}
+	expected := &debugapi.ChequebookTxResponse{TransactionHash: txHash, StatusURL: "/chequebook/transaction/" + txHash.String()}
 
 	var got *debugapi.ChequebookTxResponse
 	jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/chequebook/deposit?amount=700", http.StatusOK,
@@ -178,6 +254,48 @@ func TestChequebookDeposit(t *testing.T) {
 	if !reflect.DeepEqual(got, expected) {
 		t.Errorf("got address: %+v, expected: %+v", got, expected)
 	}
+
+	status := waitChequebookTxStatus(t, testServer, txHash, "failed")
+	if status.Error != "reverted" {
+		t.Errorf("got error %q, want %q", status.Error, "reverted")
+	}
+}
+
+func TestChequebookTransactionStatus(t *testing.T) {
+	testServer := newTestServer(t, testServerOptions{})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/chequebook/transaction/0x1234", http.StatusBadRequest,
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Code:    http.StatusBadRequest,
+			Message: "invalid transaction hash",
+		}),
+	)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/chequebook/transaction/"+common.HexToHash("0xfffff").String(), http.StatusNotFound,
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Code:    http.StatusNotFound,
+			Message: "transaction not found",
+		}),
+	)
+}
+
+// waitChequebookTxStatus polls the status of the chequebook transaction until
+// it has the wanted status.
+func waitChequebookTxStatus(t *testing.T, testServer *testServer, txHash common.Hash, want string) debugapi.ChequebookTxStatusResponse {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		var got debugapi.ChequebookTxStatusResponse
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/chequebook/transaction/"+txHash.String(), http.StatusOK,
+			jsonhttptest.WithUnmarshalJSONResponse(&got),
+		)
+		if got.Status == want {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("transaction %s did not get status %s", txHash, want)
+	return debugapi.ChequebookTxStatusResponse{}
 }
 
 func TestChequebookLastCheques(t *testing.T) {
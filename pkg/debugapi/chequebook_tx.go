@@ -0,0 +1,178 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+const (
+	chequebookTxDeposit  = "deposit"
+	chequebookTxWithdraw = "withdraw"
+
+	chequebookTxPending   = "pending"
+	chequebookTxConfirmed = "confirmed"
+	chequebookTxFailed    = "failed"
+
+	// chequebookTxWaitTimeout is the maximal time to wait for the
+	// confirmation of a deposit or withdraw transaction.
+	chequebookTxWaitTimeout = 10 * time.Minute
+	// maxChequebookTxs is the number of the transactions kept for the status
+	// requests, the oldest finished ones are forgotten first.
+	maxChequebookTxs = 100
+)
+
+var (
+	errChequebookBadTxHash = "invalid transaction hash"
+	errChequebookNoTx      = "transaction not found"
+)
+
+type chequebookTx struct {
+	kind   string
+	amount *big.Int
+	status string
+	err    string
+}
+
+// chequebookTxs keeps the status of the deposit and withdraw transactions
+// sent by the debug api while they are waited for.
+type chequebookTxs struct {
+	mu    sync.Mutex
+	txs   map[common.Hash]*chequebookTx
+	order []common.Hash // oldest first
+}
+
+func newChequebookTxs() *chequebookTxs {
+	return &chequebookTxs{
+		txs: make(map[common.Hash]*chequebookTx),
+	}
+}
+
+func (c *chequebookTxs) add(txHash common.Hash, kind string, amount *big.Int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.txs[txHash]; !ok {
+		c.order = append(c.order, txHash)
+	}
+	c.txs[txHash] = &chequebookTx{
+		kind:   kind,
+		amount: new(big.Int).Set(amount),
+		status: chequebookTxPending,
+	}
+
+	for i := 0; len(c.order) > maxChequebookTxs && i < len(c.order); {
+		h := c.order[i]
+		if c.txs[h].status == chequebookTxPending {
+			i++
+			continue
+		}
+		delete(c.txs, h)
+		c.order = append(c.order[:i], c.order[i+1:]...)
+	}
+}
+
+func (c *chequebookTxs) finish(txHash common.Hash, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, ok := c.txs[txHash]
+	if !ok {
+		return
+	}
+	if err != nil {
+		tx.status = chequebookTxFailed
+		tx.err = err.Error()
+		return
+	}
+	tx.status = chequebookTxConfirmed
+}
+
+func (c *chequebookTxs) get(txHash common.Hash) (chequebookTx, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, ok := c.txs[txHash]
+	if !ok {
+		return chequebookTx{}, false
+	}
+	return *tx, true
+}
+
+// pending returns the total amount of the pending transactions of the kind.
+func (c *chequebookTxs) pending(kind string) *big.Int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := big.NewInt(0)
+	for _, tx := range c.txs {
+		if tx.kind == kind && tx.status == chequebookTxPending {
+			total.Add(total, tx.amount)
+		}
+	}
+	return total
+}
+
+// waitForChequebookTx waits in the background for the confirmation of the
+// transaction sent by the deposit or withdraw request and records its result.
+func (s *Service) waitForChequebookTx(txHash common.Hash, wait func(ctx context.Context, txHash common.Hash) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), chequebookTxWaitTimeout)
+	defer cancel()
+
+	err := wait(ctx, txHash)
+	if err != nil {
+		s.logger.Debugf("debug api: chequebook transaction %s: %v", txHash, err)
+		s.logger.Errorf("debug api: chequebook transaction %s failed", txHash)
+	}
+	s.chequebookTxs.finish(txHash, err)
+}
+
+func chequebookTxStatusURL(txHash common.Hash) string {
+	return "/chequebook/transaction/" + txHash.String()
+}
+
+type chequebookTxStatusResponse struct {
+	TransactionHash common.Hash `json:"transactionHash"`
+	Type            string      `json:"type"`
+	Amount          *big.Int    `json:"amount"`
+	Status          string      `json:"status"`
+	Error           string      `json:"error,omitempty"`
+}
+
+func (s *Service) chequebookTxStatusHandler(w http.ResponseWriter, r *http.Request) {
+	v := mux.Vars(r)["hash"]
+	b, err := hex.DecodeString(strings.TrimPrefix(v, "0x"))
+	if err != nil || len(b) != common.HashLength {
+		s.logger.Debugf("debug api: chequebook transaction: invalid hash %s: %v", v, err)
+		s.logger.Error("debug api: chequebook transaction: invalid hash")
+		jsonhttp.BadRequest(w, errChequebookBadTxHash)
+		return
+	}
+	txHash := common.BytesToHash(b)
+
+	tx, ok := s.chequebookTxs.get(txHash)
+	if !ok {
+		jsonhttp.NotFound(w, errChequebookNoTx)
+		return
+	}
+
+	jsonhttp.OK(w, chequebookTxStatusResponse{
+		TransactionHash: txHash,
+		Type:            tx.kind,
+		Amount:          tx.amount,
+		Status:          tx.status,
+		Error:           tx.err,
+	})
+}
@@ -0,0 +1,33 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"net/http"
+
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+// configReloadHandler reloads the configuration parameters of the node which
+// can be changed without restarting it.
+func (s *Service) configReloadHandler(w http.ResponseWriter, r *http.Request) {
+	s.handlerMu.RLock()
+	reload := s.configReloader
+	s.handlerMu.RUnlock()
+
+	if reload == nil {
+		jsonhttp.NotImplemented(w, "configuration reloading not supported")
+		return
+	}
+
+	if err := reload(r.Context()); err != nil {
+		s.logger.Debugf("debug api: config reload: %v", err)
+		s.logger.Error("debug api: can not reload configuration")
+		jsonhttp.InternalServerError(w, err)
+		return
+	}
+
+	jsonhttp.OK(w, nil)
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+)
+
+func TestConfigReload(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		var reloaded int
+		testServer := newTestServer(t, testServerOptions{
+			ConfigReloader: func(ctx context.Context) error {
+				reloaded++
+				return nil
+			},
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/config/reload", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusOK,
+				Message: http.StatusText(http.StatusOK),
+			}),
+		)
+		if reloaded != 1 {
+			t.Fatalf("got %d reloads, want 1", reloaded)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		testErr := errors.New("test error")
+		testServer := newTestServer(t, testServerOptions{
+			ConfigReloader: func(ctx context.Context) error {
+				return testErr
+			},
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/config/reload", http.StatusInternalServerError,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusInternalServerError,
+				Message: testErr.Error(),
+			}),
+		)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/config/reload", http.StatusNotImplemented,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusNotImplemented,
+				Message: "configuration reloading not supported",
+			}),
+		)
+	})
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+)
+
+type connectionEventResponse struct {
+	Time      time.Time               `json:"time"`
+	Type      p2p.ConnectionEventType `json:"type"`
+	Direction string                  `json:"direction,omitempty"`
+	Overlay   *infinity.Address       `json:"overlay,omitempty"`
+	Underlay  string                  `json:"underlay,omitempty"`
+	ErrorType string                  `json:"errorType,omitempty"`
+	Reason    string                  `json:"reason,omitempty"`
+}
+
+type connectionEventsResponse struct {
+	Events []connectionEventResponse `json:"events"`
+}
+
+// connectionEventsHandler responds with the recent connection events of the
+// node, from the oldest to the newest.
+func (s *Service) connectionEventsHandler(w http.ResponseWriter, r *http.Request) {
+	events := s.p2p.ConnectionEvents()
+	resp := connectionEventsResponse{Events: make([]connectionEventResponse, 0, len(events))}
+	for _, e := range events {
+		event := connectionEventResponse{
+			Time:      e.Time,
+			Type:      e.Type,
+			ErrorType: e.ErrorType,
+			Reason:    e.Reason,
+		}
+		// the direction is known only for the established or failed
+		// connections
+		switch e.Type {
+		case p2p.ConnectionEventConnected, p2p.ConnectionEventFailed:
+			event.Direction = "outbound"
+			if e.Inbound {
+				event.Direction = "inbound"
+			}
+		}
+		if !e.Overlay.IsZero() {
+			overlay := e.Overlay
+			event.Overlay = &overlay
+		}
+		if e.Underlay != nil {
+			event.Underlay = e.Underlay.String()
+		}
+		resp.Events = append(resp.Events, event)
+	}
+
+	jsonhttp.OK(w, resp)
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/p2p/mock"
+)
+
+func TestConnectionEvents(t *testing.T) {
+	underlay, err := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/11634/p2p/16Uiu2HAkx8ULY8cTXhdVAcMmLcH9AsTKz6uBQ7DPLKRjMLgBVYkS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+	now := time.Unix(1600000000, 0).UTC()
+
+	testServer := newTestServer(t, testServerOptions{
+		P2P: mock.New(mock.WithConnectionEventsFunc(func() []p2p.ConnectionEvent {
+			return []p2p.ConnectionEvent{
+				{Time: now, Type: p2p.ConnectionEventDial, Underlay: underlay},
+				{Time: now, Type: p2p.ConnectionEventFailed, Underlay: underlay, ErrorType: "refused", Reason: "connection refused"},
+				{Time: now, Type: p2p.ConnectionEventConnected, Inbound: true, Overlay: overlay, Underlay: underlay},
+				{Time: now, Type: p2p.ConnectionEventDisconnected, Overlay: overlay, Reason: "remote"},
+			}
+		})),
+	})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/debug/connection-events", http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.ConnectionEventsResponse{
+			Events: []debugapi.ConnectionEventResponse{
+				{Time: now, Type: p2p.ConnectionEventDial, Underlay: underlay.String()},
+				{Time: now, Type: p2p.ConnectionEventFailed, Direction: "outbound", Underlay: underlay.String(), ErrorType: "refused", Reason: "connection refused"},
+				{Time: now, Type: p2p.ConnectionEventConnected, Direction: "inbound", Overlay: &overlay, Underlay: underlay.String()},
+				{Time: now, Type: p2p.ConnectionEventDisconnected, Overlay: &overlay, Reason: "remote"},
+			},
+		}),
+	)
+}
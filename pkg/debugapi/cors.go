@@ -12,7 +12,10 @@ import (
 // corsHandler sets CORS headers to HTTP response if allowed origins are configured.
 func (s *Service) corsHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if o := r.Header.Get("Origin"); o != "" && checkOrigin(r, s.corsAllowedOrigins) {
+		s.corsMu.RLock()
+		allowed := s.corsAllowedOrigins
+		s.corsMu.RUnlock()
+		if o := r.Header.Get("Origin"); o != "" && checkOrigin(r, allowed) {
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			w.Header().Set("Access-Control-Allow-Origin", o)
 			w.Header().Set("Access-Control-Allow-Headers", "Origin, Accept, Authorization, Content-Type, X-Requested-With, Access-Control-Request-Headers, Access-Control-Request-Method")
@@ -23,6 +26,13 @@ func (s *Service) corsHandler(h http.Handler) http.Handler {
 	})
 }
 
+// SetCORSAllowedOrigins changes the allowed CORS origins at runtime.
+func (s *Service) SetCORSAllowedOrigins(origins []string) {
+	s.corsMu.Lock()
+	defer s.corsMu.Unlock()
+	s.corsAllowedOrigins = origins
+}
+
 // checkOrigin returns true if the origin header is not set or is equal to the request host.
 func checkOrigin(r *http.Request, allowed []string) bool {
 	origin := r.Header["Origin"]
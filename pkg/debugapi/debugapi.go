@@ -8,22 +8,31 @@
 package debugapi
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"net/http"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/addressbook"
+	"github.com/yanhuangpai/voyager/pkg/apikey"
+	"github.com/yanhuangpai/voyager/pkg/features"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/logging/httpaccess"
+	"github.com/yanhuangpai/voyager/pkg/metrics"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
 	"github.com/yanhuangpai/voyager/pkg/pingpong"
+	"github.com/yanhuangpai/voyager/pkg/pullsync"
 	"github.com/yanhuangpai/voyager/pkg/settlement"
 	"github.com/yanhuangpai/voyager/pkg/settlement/swap"
 	"github.com/yanhuangpai/voyager/pkg/settlement/swap/chequebook"
 	"github.com/yanhuangpai/voyager/pkg/storage"
 	"github.com/yanhuangpai/voyager/pkg/tags"
+	"github.com/yanhuangpai/voyager/pkg/telemetry"
 	"github.com/yanhuangpai/voyager/pkg/topology"
 	"github.com/yanhuangpai/voyager/pkg/tracing"
 )
@@ -45,11 +54,26 @@ type Service struct {
 	settlement         settlement.Interface
 	chequebookEnabled  bool
 	chequebook         chequebook.Service
+	chequebookTxs      *chequebookTxs // deposit and withdraw transactions waited for
+	withdrawMu         sync.Mutex     // serializes the chequebook withdrawals
 	swap               swap.ApiInterface
+	pullSync           pullsync.DebugService
+	telemetry          *telemetry.Service
 	corsAllowedOrigins []string
+	corsMu             sync.RWMutex // protects corsAllowedOrigins changed at runtime
 	metricsRegistry    *prometheus.Registry
-	// handler is changed in the Configure method
+	metrics            debugMetrics
+	apiRoutes          RouteLister
+	configReloader     func(ctx context.Context) error
+	bodyCapture        *httpaccess.BodyCapture
+	healthChecks       []namedHealthCheck
+	healthChecksMu     sync.RWMutex
+	addressBook        addressbook.Putter
+	features           *features.Service
+	apiKeys            *apikey.Service
+	// handler and router are changed in the Configure method
 	handler   http.Handler
+	router    *mux.Router
 	handlerMu sync.RWMutex
 }
 
@@ -67,6 +91,9 @@ func New(overlay infinity.Address, publicKey, pssPublicKey ecdsa.PublicKey, ethe
 	s.tracer = tracer
 	s.corsAllowedOrigins = corsAllowedOrigins
 	s.metricsRegistry = newMetricsRegistry()
+	s.metrics = newDebugMetrics()
+	s.chequebookTxs = newChequebookTxs()
+	s.MustRegisterMetrics(metrics.PrometheusCollectorsFromFields(s.metrics)...)
 
 	s.setRouter(s.newBasicRouter())
 
@@ -76,7 +103,7 @@ func New(overlay infinity.Address, publicKey, pssPublicKey ecdsa.PublicKey, ethe
 // Configure injects required dependencies and configuration parameters and
 // constructs HTTP routes that depend on them. It is intended and safe to call
 // this method only once.
-func (s *Service) Configure(p2p p2p.DebugService, pingpong pingpong.Interface, topologyDriver topology.Driver, storer storage.Storer, tags *tags.Tags, accounting accounting.Interface, settlement settlement.Interface, chequebookEnabled bool, swap swap.ApiInterface, chequebook chequebook.Service) {
+func (s *Service) Configure(p2p p2p.DebugService, pingpong pingpong.Interface, topologyDriver topology.Driver, storer storage.Storer, tags *tags.Tags, accounting accounting.Interface, settlement settlement.Interface, chequebookEnabled bool, swap swap.ApiInterface, chequebook chequebook.Service, pullSync pullsync.DebugService, telemetry *telemetry.Service) {
 	s.p2p = p2p
 	s.pingpong = pingpong
 	s.topologyDriver = topologyDriver
@@ -87,10 +114,48 @@ func (s *Service) Configure(p2p p2p.DebugService, pingpong pingpong.Interface, t
 	s.chequebookEnabled = chequebookEnabled
 	s.chequebook = chequebook
 	s.swap = swap
+	s.pullSync = pullSync
+	s.telemetry = telemetry
 
 	s.setRouter(s.newRouter())
 }
 
+// SetAPIRoutes sets the lister of the API routes reported together with the
+// Debug API routes.
+func (s *Service) SetAPIRoutes(l RouteLister) {
+	s.handlerMu.Lock()
+	defer s.handlerMu.Unlock()
+
+	s.apiRoutes = l
+}
+
+// SetConfigReloader sets the function reloading the configuration of the node
+// on the config reload requests.
+func (s *Service) SetConfigReloader(reload func(ctx context.Context) error) {
+	s.handlerMu.Lock()
+	defer s.handlerMu.Unlock()
+
+	s.configReloader = reload
+}
+
+// SetBodyCapture sets the sampling of the bodies logged in the API access log
+// which can be changed with the access log requests.
+func (s *Service) SetBodyCapture(c *httpaccess.BodyCapture) {
+	s.handlerMu.Lock()
+	defer s.handlerMu.Unlock()
+
+	s.bodyCapture = c
+}
+
+// SetAddressBook sets the addressbook in which the peers connected through the
+// debug api are recorded as manually added.
+func (s *Service) SetAddressBook(b addressbook.Putter) {
+	s.handlerMu.Lock()
+	defer s.handlerMu.Unlock()
+
+	s.addressBook = b
+}
+
 // ServeHTTP implements http.Handler interface.
 func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// protect handler as it is changed by the Configure method
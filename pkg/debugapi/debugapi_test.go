@@ -5,6 +5,7 @@
 package debugapi_test
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
 	"io/ioutil"
@@ -17,20 +18,25 @@ import (
 	"github.com/multiformats/go-multiaddr"
 	"github.com/yanhuangpai/voyager"
 	accountingmock "github.com/yanhuangpai/voyager/pkg/accounting/mock"
+	"github.com/yanhuangpai/voyager/pkg/apikey"
 	"github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/features"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
 	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/logging/httpaccess"
 	"github.com/yanhuangpai/voyager/pkg/p2p/mock"
 	p2pmock "github.com/yanhuangpai/voyager/pkg/p2p/mock"
 	"github.com/yanhuangpai/voyager/pkg/pingpong"
+	pullsyncmock "github.com/yanhuangpai/voyager/pkg/pullsync/mock"
 	"github.com/yanhuangpai/voyager/pkg/resolver"
 	chequebookmock "github.com/yanhuangpai/voyager/pkg/settlement/swap/chequebook/mock"
 	swapmock "github.com/yanhuangpai/voyager/pkg/settlement/swap/mock"
 	"github.com/yanhuangpai/voyager/pkg/storage"
 	"github.com/yanhuangpai/voyager/pkg/tags"
+	"github.com/yanhuangpai/voyager/pkg/telemetry"
 	topologymock "github.com/yanhuangpai/voyager/pkg/topology/mock"
 	"resenje.org/web"
 )
@@ -51,6 +57,14 @@ type testServerOptions struct {
 	SettlementOpts     []swapmock.Option
 	ChequebookOpts     []chequebookmock.Option
 	SwapOpts           []swapmock.Option
+	PullSyncOpts       []pullsyncmock.Option
+	Telemetry          *telemetry.Service
+	APIRoutes          debugapi.RouteLister
+	ConfigReloader     func(ctx context.Context) error
+	BodyCapture        *httpaccess.BodyCapture
+	HealthChecks       map[string]debugapi.HealthCheck
+	Features           *features.Service
+	APIKeys            *apikey.Service
 }
 
 type testServer struct {
@@ -64,8 +78,27 @@ func newTestServer(t *testing.T, o testServerOptions) *testServer {
 	settlement := swapmock.New(o.SettlementOpts...)
 	chequebook := chequebookmock.NewChequebook(o.ChequebookOpts...)
 	swapserv := swapmock.NewApiInterface(o.SwapOpts...)
+	pullSync := pullsyncmock.NewPullSync(o.PullSyncOpts...)
 	s := debugapi.New(o.Overlay, o.PublicKey, o.PSSPublicKey, o.EthereumAddress, logging.New(ioutil.Discard, 0), nil, o.CORSAllowedOrigins)
-	s.Configure(o.P2P, o.Pingpong, topologyDriver, o.Storer, o.Tags, acc, settlement, true, swapserv, chequebook)
+	s.Configure(o.P2P, o.Pingpong, topologyDriver, o.Storer, o.Tags, acc, settlement, true, swapserv, chequebook, pullSync, o.Telemetry)
+	if o.APIRoutes != nil {
+		s.SetAPIRoutes(o.APIRoutes)
+	}
+	if o.ConfigReloader != nil {
+		s.SetConfigReloader(o.ConfigReloader)
+	}
+	if o.BodyCapture != nil {
+		s.SetBodyCapture(o.BodyCapture)
+	}
+	if o.Features != nil {
+		s.SetFeatures(o.Features)
+	}
+	if o.APIKeys != nil {
+		s.SetAPIKeys(o.APIKeys)
+	}
+	for name, check := range o.HealthChecks {
+		s.AddHealthCheck(name, check)
+	}
 	ts := httptest.NewServer(s)
 	t.Cleanup(ts.Close)
 
@@ -130,6 +163,7 @@ func TestServer_Configure(t *testing.T) {
 	settlement := swapmock.New(o.SettlementOpts...)
 	chequebook := chequebookmock.NewChequebook(o.ChequebookOpts...)
 	swapserv := swapmock.NewApiInterface(o.SwapOpts...)
+	pullSync := pullsyncmock.NewPullSync(o.PullSyncOpts...)
 	s := debugapi.New(o.Overlay, o.PublicKey, o.PSSPublicKey, o.EthereumAddress, logging.New(ioutil.Discard, 0), nil, nil)
 	ts := httptest.NewServer(s)
 	t.Cleanup(ts.Close)
@@ -162,7 +196,7 @@ func TestServer_Configure(t *testing.T) {
 		}),
 	)
 
-	s.Configure(o.P2P, o.Pingpong, topologyDriver, o.Storer, o.Tags, acc, settlement, true, swapserv, chequebook)
+	s.Configure(o.P2P, o.Pingpong, topologyDriver, o.Storer, o.Tags, acc, settlement, true, swapserv, chequebook, pullSync, o.Telemetry)
 
 	testBasicRouter(t, client)
 	jsonhttptest.Request(t, client, http.MethodGet, "/readiness", http.StatusOK,
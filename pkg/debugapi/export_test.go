@@ -6,6 +6,7 @@ package debugapi
 
 type (
 	StatusResponse                    = statusResponse
+	HealthCheckResponse               = healthCheckResponse
 	PingpongResponse                  = pingpongResponse
 	PeerConnectResponse               = peerConnectResponse
 	PeersResponse                     = peersResponse
@@ -22,10 +23,44 @@ type (
 	ChequebookLastChequesResponse     = chequebookLastChequesResponse
 	ChequebookLastChequesPeerResponse = chequebookLastChequesPeerResponse
 	ChequebookTxResponse              = chequebookTxResponse
+	ChequebookTxStatusResponse        = chequebookTxStatusResponse
 	SwapCashoutResponse               = swapCashoutResponse
 	SwapCashoutStatusResponse         = swapCashoutStatusResponse
 	SwapCashoutStatusResult           = swapCashoutStatusResult
+	SwapPauseResponse                 = swapPauseResponse
+	SwapPausedPeersResponse           = swapPausedPeersResponse
 	TagResponse                       = tagResponse
+	PullsyncCursorsResponse           = pullsyncCursorsResponse
+	PullsyncBinResponse               = pullsyncBinResponse
+	RoutesResponse                    = routesResponse
+	PullsyncPeerResponse              = pullsyncPeerResponse
+	PullsyncIncidentsResponse         = pullsyncIncidentsResponse
+	PullsyncIncidentsPeerResponse     = pullsyncIncidentsPeerResponse
+	TrafficResponse                   = trafficResponse
+	TrafficsResponse                  = trafficsResponse
+	ReconciliationResponse            = reconciliationResponse
+	ReconciliationsResponse           = reconciliationsResponse
+	TelemetryResponse                 = telemetryResponse
+	ListenAddressesResponse           = listenAddressesResponse
+	LoggersResponse                   = loggersResponse
+	BodyCaptureRequest                = bodyCaptureRequest
+	BodyCaptureResponse               = bodyCaptureResponse
+	OverrideRequest                   = overrideRequest
+	OverrideResponse                  = overrideResponse
+	OverridesResponse                 = overridesResponse
+	StorageStatsResponse              = storageStatsResponse
+	NamespaceStatsResponse            = namespaceStatsResponse
+	PopularChunksResponse             = popularChunksResponse
+	PopularChunkResponse              = popularChunkResponse
+	PeersWaitResponse                 = peersWaitResponse
+	FeatureResponse                   = featureResponse
+	FeaturesResponse                  = featuresResponse
+	FeaturesRequest                   = featuresRequest
+	APIKeysResponse                   = apiKeysResponse
+	APIKeyCreateRequest               = apiKeyCreateRequest
+	APIKeyCreateResponse              = apiKeyCreateResponse
+	ConnectionEventResponse           = connectionEventResponse
+	ConnectionEventsResponse          = connectionEventsResponse
 )
 
 var (
@@ -36,4 +71,13 @@ var (
 	ErrCantSettlements     = errCantSettlements
 	ErrChequebookBalance   = errChequebookBalance
 	ErrInvalidAddress      = errInvalidAddress
+	ErrCantTraffic         = errCantTraffic
+	ErrNoTraffic           = errNoTraffic
+	ErrCantReconciliation  = errCantReconciliation
+	ErrNoOverride          = errNoOverride
+	ErrCantTelemetry       = errCantTelemetry
+	ErrCantStorageStats    = errCantStorageStats
+	ErrCantPopularChunks   = errCantPopularChunks
 )
+
+var PeersWaitPollInterval = &peersWaitPollInterval
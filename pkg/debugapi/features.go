@@ -0,0 +1,103 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/yanhuangpai/voyager/pkg/features"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+type featureResponse struct {
+	Name        string `json:"name"`
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description"`
+}
+
+type featuresResponse struct {
+	Features []featureResponse `json:"features"`
+}
+
+// featuresRequest maps the names of the features to enable or disable to
+// their new state.
+type featuresRequest map[string]bool
+
+// SetFeatures sets the experimental feature flags which can be inspected and
+// changed with the features requests.
+func (s *Service) SetFeatures(f *features.Service) {
+	s.handlerMu.Lock()
+	defer s.handlerMu.Unlock()
+
+	s.features = f
+}
+
+// featuresHandler reports the state of the experimental feature flags.
+func (s *Service) featuresHandler(w http.ResponseWriter, r *http.Request) {
+	s.handlerMu.RLock()
+	f := s.features
+	s.handlerMu.RUnlock()
+
+	if f == nil {
+		jsonhttp.NotImplemented(w, "features not supported")
+		return
+	}
+
+	jsonhttp.OK(w, newFeaturesResponse(f))
+}
+
+// setFeaturesHandler enables or disables the experimental features. The
+// changes are persisted and kept on restart.
+func (s *Service) setFeaturesHandler(w http.ResponseWriter, r *http.Request) {
+	s.handlerMu.RLock()
+	f := s.features
+	s.handlerMu.RUnlock()
+
+	if f == nil {
+		jsonhttp.NotImplemented(w, "features not supported")
+		return
+	}
+
+	var req featuresRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Debugf("debug api: features: decode request: %v", err)
+		s.logger.Error("debug api: features: bad request")
+		jsonhttp.BadRequest(w, "bad request")
+		return
+	}
+
+	flags := make(map[features.Flag]bool, len(req))
+	for name, enabled := range req {
+		flags[features.Flag(name)] = enabled
+	}
+	if err := f.Set(flags); err != nil {
+		s.logger.Debugf("debug api: features: set: %v", err)
+		if errors.Is(err, features.ErrUnknownFlag) {
+			s.logger.Error("debug api: features: unknown feature")
+			jsonhttp.BadRequest(w, err)
+			return
+		}
+		s.logger.Error("debug api: features: cannot set features")
+		jsonhttp.InternalServerError(w, "cannot set features")
+		return
+	}
+
+	jsonhttp.OK(w, newFeaturesResponse(f))
+}
+
+func newFeaturesResponse(f *features.Service) featuresResponse {
+	flags := f.Flags()
+	resp := featuresResponse{Features: make([]featureResponse, 0, len(flags))}
+	for _, flag := range flags {
+		resp.Features = append(resp.Features, featureResponse{
+			Name:        string(flag.Flag),
+			Enabled:     flag.Enabled,
+			Description: flag.Description,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/features"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/statestore/mock"
+)
+
+func TestFeatures(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		store := mock.NewStateStore()
+		f, err := features.New(store, nil, logging.New(ioutil.Discard, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testServer := newTestServer(t, testServerOptions{
+			Features: f,
+		})
+
+		var resp debugapi.FeaturesResponse
+		jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/features", http.StatusOK,
+			jsonhttptest.WithJSONRequestBody(debugapi.FeaturesRequest{
+				string(features.RetrievalRacing):  true,
+				string(features.RetrievalHedging): true,
+			}),
+			jsonhttptest.WithUnmarshalJSONResponse(&resp),
+		)
+		if !f.Enabled(features.RetrievalRacing) {
+			t.Fatal("retrieval racing not enabled")
+		}
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/features", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(resp),
+		)
+		for _, feature := range resp.Features {
+			if !feature.Enabled {
+				t.Errorf("feature %s disabled", feature.Name)
+			}
+		}
+
+		// the change is persisted
+		f, err = features.New(store, nil, logging.New(ioutil.Discard, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !f.Enabled(features.RetrievalRacing) {
+			t.Fatal("retrieval racing not enabled after restart")
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		f, err := features.New(mock.NewStateStore(), nil, logging.New(ioutil.Discard, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testServer := newTestServer(t, testServerOptions{
+			Features: f,
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/features", http.StatusBadRequest,
+			jsonhttptest.WithJSONRequestBody(debugapi.FeaturesRequest{
+				"unknown": true,
+			}),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusBadRequest,
+				Message: "unknown feature flag: unknown",
+			}),
+		)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/features", http.StatusNotImplemented,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusNotImplemented,
+				Message: "features not supported",
+			}),
+		)
+	})
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+)
+
+type listenAddressesResponse struct {
+	Addresses []multiaddr.Multiaddr `json:"addresses"`
+}
+
+func (s *Service) listenAddressesHandler(w http.ResponseWriter, r *http.Request) {
+	addresses := s.p2p.ListenAddresses()
+	if addresses == nil {
+		addresses = make([]multiaddr.Multiaddr, 0)
+	}
+	jsonhttp.OK(w, listenAddressesResponse{
+		Addresses: addresses,
+	})
+}
+
+func (s *Service) listenHandler(w http.ResponseWriter, r *http.Request) {
+	addr, err := multiaddr.NewMultiaddr("/" + mux.Vars(r)["multi-address"])
+	if err != nil {
+		s.logger.Debugf("debug api: listen: parse multiaddress: %v", err)
+		jsonhttp.BadRequest(w, err)
+		return
+	}
+
+	if err := s.p2p.Listen(addr); err != nil {
+		s.logger.Debugf("debug api: listen %s: %v", addr, err)
+		s.logger.Errorf("unable to listen on %s", addr)
+		jsonhttp.InternalServerError(w, err)
+		return
+	}
+
+	jsonhttp.OK(w, nil)
+}
+
+func (s *Service) closeListenerHandler(w http.ResponseWriter, r *http.Request) {
+	addr, err := multiaddr.NewMultiaddr("/" + mux.Vars(r)["multi-address"])
+	if err != nil {
+		s.logger.Debugf("debug api: close listener: parse multiaddress: %v", err)
+		jsonhttp.BadRequest(w, err)
+		return
+	}
+
+	if err := s.p2p.CloseListener(addr); err != nil {
+		s.logger.Debugf("debug api: close listener %s: %v", addr, err)
+		if errors.Is(err, p2p.ErrListenerNotFound) {
+			jsonhttp.NotFound(w, "listener not found")
+			return
+		}
+		s.logger.Errorf("unable to close listener %s", addr)
+		jsonhttp.InternalServerError(w, err)
+		return
+	}
+
+	jsonhttp.OK(w, nil)
+}
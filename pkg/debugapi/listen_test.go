@@ -0,0 +1,112 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/p2p/mock"
+)
+
+func TestListen(t *testing.T) {
+	address := "/ip4/0.0.0.0/tcp/1634"
+	wsAddress := "/ip4/0.0.0.0/tcp/1635/ws"
+	testErr := errors.New("test error")
+
+	var listened []ma.Multiaddr
+	testServer := newTestServer(t, testServerOptions{
+		P2P: mock.New(
+			mock.WithListenAddressesFunc(func() []ma.Multiaddr {
+				return listened
+			}),
+			mock.WithListenFunc(func(addr ma.Multiaddr) error {
+				if addr.String() != wsAddress {
+					return testErr
+				}
+				listened = append(listened, addr)
+				return nil
+			}),
+			mock.WithCloseListenerFunc(func(addr ma.Multiaddr) error {
+				for i, a := range listened {
+					if a.Equal(addr) {
+						listened = append(listened[:i], listened[i+1:]...)
+						return nil
+					}
+				}
+				return p2p.ErrListenerNotFound
+			}),
+		),
+	})
+
+	wsAddr, err := ma.NewMultiaddr(wsAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("no addresses", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/listen", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.ListenAddressesResponse{
+				Addresses: []ma.Multiaddr{},
+			}),
+		)
+	})
+
+	t.Run("listen", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/listen"+wsAddress, http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusOK,
+				Message: http.StatusText(http.StatusOK),
+			}),
+		)
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/listen", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.ListenAddressesResponse{
+				Addresses: []ma.Multiaddr{wsAddr},
+			}),
+		)
+	})
+
+	t.Run("listen error", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/listen"+address, http.StatusInternalServerError,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusInternalServerError,
+				Message: testErr.Error(),
+			}),
+		)
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/listen/invalid", http.StatusBadRequest)
+	})
+
+	t.Run("close listener", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodDelete, "/listen"+wsAddress, http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusOK,
+				Message: http.StatusText(http.StatusOK),
+			}),
+		)
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/listen", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.ListenAddressesResponse{
+				Addresses: []ma.Multiaddr{},
+			}),
+		)
+	})
+
+	t.Run("close listener not found", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodDelete, "/listen"+wsAddress, http.StatusNotFound,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusNotFound,
+				Message: "listener not found",
+			}),
+		)
+	})
+}
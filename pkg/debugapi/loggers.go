@@ -0,0 +1,48 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+)
+
+type loggersResponse struct {
+	Level      string            `json:"level"`
+	Subsystems map[string]string `json:"subsystems"`
+}
+
+func (s *Service) loggersHandler(w http.ResponseWriter, r *http.Request) {
+	level, subsystems := s.logger.Levels()
+	resp := loggersResponse{
+		Level:      level.String(),
+		Subsystems: make(map[string]string, len(subsystems)),
+	}
+	for name, l := range subsystems {
+		resp.Subsystems[name] = l.String()
+	}
+	jsonhttp.OK(w, resp)
+}
+
+func (s *Service) setLoggerLevelHandler(w http.ResponseWriter, r *http.Request) {
+	subsystem := mux.Vars(r)["subsystem"]
+	verbosity := mux.Vars(r)["verbosity"]
+
+	level, err := logging.ParseVerbosity(verbosity)
+	if err != nil {
+		s.logger.Debugf("debug api: set logger level: parse verbosity %s: %v", verbosity, err)
+		s.logger.Error("debug api: set logger level: bad verbosity")
+		jsonhttp.BadRequest(w, "bad verbosity")
+		return
+	}
+
+	s.logger.SetSubsystemLevel(subsystem, level)
+	s.logger.Infof("debug api: log level of subsystem %s set to %s", subsystem, level)
+
+	jsonhttp.OK(w, nil)
+}
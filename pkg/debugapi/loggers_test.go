@@ -0,0 +1,50 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+)
+
+func TestLoggers(t *testing.T) {
+	testServer := newTestServer(t, testServerOptions{})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/loggers", http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.LoggersResponse{
+			Level:      "panic",
+			Subsystems: map[string]string{},
+		}),
+	)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/loggers/kademlia/debug", http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Code:    http.StatusOK,
+			Message: http.StatusText(http.StatusOK),
+		}),
+	)
+	jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/loggers/api/2", http.StatusOK)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/loggers", http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.LoggersResponse{
+			Level: "panic",
+			Subsystems: map[string]string{
+				"kademlia": "debug",
+				"api":      "warning",
+			},
+		}),
+	)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/loggers/kademlia/loud", http.StatusBadRequest,
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Code:    http.StatusBadRequest,
+			Message: "bad verbosity",
+		}),
+	)
+}
@@ -10,6 +10,26 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/metrics"
 )
 
+type debugMetrics struct {
+	// all metrics fields must be exported
+	// to be able to return them by Metrics()
+	// using reflection
+	PullsyncCursorLag *prometheus.GaugeVec
+}
+
+func newDebugMetrics() debugMetrics {
+	subsystem := "debugapi"
+
+	return debugMetrics{
+		PullsyncCursorLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: subsystem,
+			Name:      "pullsync_cursor_lag",
+			Help:      "Difference between the highest neighbor pull sync cursor and the local one, per bin.",
+		}, []string{"bin"}),
+	}
+}
+
 func newMetricsRegistry() (r *prometheus.Registry) {
 	r = prometheus.NewRegistry()
 
@@ -0,0 +1,158 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+var (
+	errCantOverrides = "Cannot get overrides"
+	errCantOverride  = "Cannot set override"
+	errNoOverride    = "No override for peer"
+)
+
+type overrideRequest struct {
+	SwapDisabled        bool     `json:"swapDisabled"`
+	PaymentThreshold    *big.Int `json:"paymentThreshold,omitempty"`
+	DisconnectThreshold *big.Int `json:"disconnectThreshold,omitempty"`
+}
+
+type overrideResponse struct {
+	Peer                string   `json:"peer"`
+	SwapDisabled        bool     `json:"swapDisabled"`
+	PaymentThreshold    *big.Int `json:"paymentThreshold,omitempty"`
+	DisconnectThreshold *big.Int `json:"disconnectThreshold,omitempty"`
+}
+
+type overridesResponse struct {
+	Overrides []overrideResponse `json:"overrides"`
+}
+
+func newOverrideResponse(peer string, o accounting.PeerOverride) overrideResponse {
+	return overrideResponse{
+		Peer:                peer,
+		SwapDisabled:        o.SwapDisabled,
+		PaymentThreshold:    o.PaymentThreshold,
+		DisconnectThreshold: o.DisconnectThreshold,
+	}
+}
+
+func (s *Service) overridesHandler(w http.ResponseWriter, r *http.Request) {
+	overrides, err := s.accounting.PeerOverrides()
+	if err != nil {
+		jsonhttp.InternalServerError(w, errCantOverrides)
+		s.logger.Debugf("debug api: overrides: %v", err)
+		s.logger.Error("debug api: can not get overrides")
+		return
+	}
+
+	responses := make([]overrideResponse, 0, len(overrides))
+	for peer, o := range overrides {
+		responses = append(responses, newOverrideResponse(peer, o))
+	}
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Peer < responses[j].Peer
+	})
+
+	jsonhttp.OK(w, overridesResponse{Overrides: responses})
+}
+
+func (s *Service) peerOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["peer"]
+	peer, err := infinity.ParseHexAddress(addr)
+	if err != nil {
+		s.logger.Debugf("debug api: override peer: invalid peer address %s: %v", addr, err)
+		s.logger.Errorf("debug api: override peer: invalid peer address %s", addr)
+		jsonhttp.NotFound(w, errInvalidAddress)
+		return
+	}
+
+	o, err := s.accounting.PeerOverride(peer)
+	if err != nil {
+		if errors.Is(err, accounting.ErrPeerNoOverride) {
+			jsonhttp.NotFound(w, errNoOverride)
+			return
+		}
+		s.logger.Debugf("debug api: override peer: get peer %s override: %v", peer.String(), err)
+		s.logger.Errorf("debug api: override peer: can't get peer %s override", peer.String())
+		jsonhttp.InternalServerError(w, errCantOverrides)
+		return
+	}
+
+	jsonhttp.OK(w, newOverrideResponse(peer.String(), o))
+}
+
+func (s *Service) setPeerOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["peer"]
+	peer, err := infinity.ParseHexAddress(addr)
+	if err != nil {
+		s.logger.Debugf("debug api: set override peer: invalid peer address %s: %v", addr, err)
+		s.logger.Errorf("debug api: set override peer: invalid peer address %s", addr)
+		jsonhttp.NotFound(w, errInvalidAddress)
+		return
+	}
+
+	var req overrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.logger.Debugf("debug api: set override peer: decode request: %v", err)
+		s.logger.Error("debug api: set override peer: bad request")
+		jsonhttp.BadRequest(w, "bad request")
+		return
+	}
+
+	o := accounting.PeerOverride{
+		SwapDisabled:        req.SwapDisabled,
+		PaymentThreshold:    req.PaymentThreshold,
+		DisconnectThreshold: req.DisconnectThreshold,
+	}
+	if err := s.accounting.SetPeerOverride(peer, o); err != nil {
+		if errors.Is(err, accounting.ErrInvalidValue) {
+			jsonhttp.BadRequest(w, "invalid threshold")
+			return
+		}
+		s.logger.Debugf("debug api: set override peer: set peer %s override: %v", peer.String(), err)
+		s.logger.Errorf("debug api: set override peer: can't set peer %s override", peer.String())
+		jsonhttp.InternalServerError(w, errCantOverride)
+		return
+	}
+	s.logger.Infof("debug api: accounting override set for peer %s", peer.String())
+
+	jsonhttp.OK(w, newOverrideResponse(peer.String(), o))
+}
+
+func (s *Service) removePeerOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["peer"]
+	peer, err := infinity.ParseHexAddress(addr)
+	if err != nil {
+		s.logger.Debugf("debug api: remove override peer: invalid peer address %s: %v", addr, err)
+		s.logger.Errorf("debug api: remove override peer: invalid peer address %s", addr)
+		jsonhttp.NotFound(w, errInvalidAddress)
+		return
+	}
+
+	if err := s.accounting.RemovePeerOverride(peer); err != nil {
+		if errors.Is(err, accounting.ErrPeerNoOverride) {
+			jsonhttp.NotFound(w, errNoOverride)
+			return
+		}
+		s.logger.Debugf("debug api: remove override peer: remove peer %s override: %v", peer.String(), err)
+		s.logger.Errorf("debug api: remove override peer: can't remove peer %s override", peer.String())
+		jsonhttp.InternalServerError(w, errCantOverride)
+		return
+	}
+	s.logger.Infof("debug api: accounting override removed for peer %s", peer.String())
+
+	jsonhttp.OK(w, nil)
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+)
+
+func TestPeerOverride(t *testing.T) {
+	peer := "bff2c89e85e78c38bd89fca1acc996afb876c21bf5a8482ad798ce15f1c223fa"
+	testServer := newTestServer(t, testServerOptions{})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/accounting/overrides/"+peer, http.StatusNotFound,
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Message: debugapi.ErrNoOverride,
+			Code:    http.StatusNotFound,
+		}),
+	)
+
+	override := debugapi.OverrideResponse{
+		Peer:                peer,
+		SwapDisabled:        true,
+		DisconnectThreshold: big.NewInt(100000),
+	}
+	jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/accounting/overrides/"+peer, http.StatusOK,
+		jsonhttptest.WithJSONRequestBody(debugapi.OverrideRequest{
+			SwapDisabled:        true,
+			DisconnectThreshold: big.NewInt(100000),
+		}),
+		jsonhttptest.WithExpectedJSONResponse(override),
+	)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/accounting/overrides/"+peer, http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(override),
+	)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/accounting/overrides", http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.OverridesResponse{
+			Overrides: []debugapi.OverrideResponse{override},
+		}),
+	)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodDelete, "/accounting/overrides/"+peer, http.StatusOK)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodDelete, "/accounting/overrides/"+peer, http.StatusNotFound,
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Message: debugapi.ErrNoOverride,
+			Code:    http.StatusNotFound,
+		}),
+	)
+}
+
+func TestPeerOverrideInvalid(t *testing.T) {
+	peer := "bff2c89e85e78c38bd89fca1acc996afb876c21bf5a8482ad798ce15f1c223fa"
+	testServer := newTestServer(t, testServerOptions{})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/accounting/overrides/"+peer, http.StatusBadRequest,
+		jsonhttptest.WithJSONRequestBody(debugapi.OverrideRequest{
+			PaymentThreshold: big.NewInt(-1),
+		}),
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Message: "invalid threshold",
+			Code:    http.StatusBadRequest,
+		}),
+	)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/accounting/overrides/invalid", http.StatusNotFound,
+		jsonhttptest.WithJSONRequestBody(debugapi.OverrideRequest{}),
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Message: debugapi.ErrInvalidAddress,
+			Code:    http.StatusNotFound,
+		}),
+	)
+}
@@ -7,9 +7,12 @@ package debugapi
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/multiformats/go-multiaddr"
+	"github.com/yanhuangpai/voyager/pkg/addressbook"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
@@ -35,6 +38,16 @@ func (s *Service) peerConnectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.handlerMu.RLock()
+	addressBook := s.addressBook
+	s.handlerMu.RUnlock()
+	if addressBook != nil {
+		if err := addressBook.PutSource(ifiAddr.Overlay, *ifiAddr, addressbook.SourceManual); err != nil {
+			s.logger.Debugf("debug api: peer connect: addressbook put %s: %v", ifiAddr.Overlay, err)
+			s.logger.Errorf("unable to record manually connected peer %s", ifiAddr.Overlay)
+		}
+	}
+
 	jsonhttp.OK(w, peerConnectResponse{
 		Address: ifiAddr.Overlay.String(),
 	})
@@ -68,11 +81,80 @@ type peersResponse struct {
 }
 
 func (s *Service) peersHandler(w http.ResponseWriter, r *http.Request) {
+	peers := s.p2p.Peers()
+	for i := range peers {
+		peers[i].UserAgent = s.p2p.UserAgent(peers[i].Address)
+	}
 	jsonhttp.OK(w, peersResponse{
-		Peers: s.p2p.Peers(),
+		Peers: peers,
 	})
 }
 
+var (
+	defaultPeersWaitTimeout = 30 * time.Second
+	maxPeersWaitTimeout     = 5 * time.Minute
+	peersWaitPollInterval   = time.Second
+)
+
+type peersWaitResponse struct {
+	Connected int `json:"connected"`
+	Min       int `json:"min"`
+}
+
+// peersWaitHandler waits until the number of the connected peers reaches the
+// min query parameter and responds with 408 if the timeout elapses first. The
+// peers are counted on every change of the topology and periodically, as not
+// all connections are tracked by the topology driver.
+func (s *Service) peersWaitHandler(w http.ResponseWriter, r *http.Request) {
+	min, err := strconv.Atoi(r.URL.Query().Get("min"))
+	if err != nil || min < 0 {
+		s.logger.Debugf("debug api: peers wait: parse min %q: %v", r.URL.Query().Get("min"), err)
+		jsonhttp.BadRequest(w, "invalid min")
+		return
+	}
+	timeout := defaultPeersWaitTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		timeout, err = time.ParseDuration(v)
+		if err != nil || timeout <= 0 || timeout > maxPeersWaitTimeout {
+			s.logger.Debugf("debug api: peers wait: parse timeout %q: %v", v, err)
+			jsonhttp.BadRequest(w, "invalid timeout")
+			return
+		}
+	}
+
+	changes, unsubscribe := s.topologyDriver.SubscribePeersChange()
+	defer unsubscribe()
+
+	ticker := time.NewTicker(peersWaitPollInterval)
+	defer ticker.Stop()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		connected := len(s.p2p.Peers())
+		if connected >= min {
+			jsonhttp.OK(w, peersWaitResponse{
+				Connected: connected,
+				Min:       min,
+			})
+			return
+		}
+
+		select {
+		case <-changes:
+		case <-ticker.C:
+		case <-timer.C:
+			jsonhttp.RequestTimeout(w, peersWaitResponse{
+				Connected: len(s.p2p.Peers()),
+				Min:       min,
+			})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
 func (s *Service) blocklistedPeersHandler(w http.ResponseWriter, r *http.Request) {
 	peers, err := s.p2p.BlocklistedPeers()
 	if err != nil {
@@ -85,3 +167,7 @@ func (s *Service) blocklistedPeersHandler(w http.ResponseWriter, r *http.Request
 		Peers: peers,
 	})
 }
+
+func (s *Service) bandwidthHandler(w http.ResponseWriter, r *http.Request) {
+	jsonhttp.OK(w, s.p2p.Bandwidth())
+}
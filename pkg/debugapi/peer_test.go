@@ -8,7 +8,9 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"sync"
 	"testing"
+	"time"
 
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/yanhuangpai/voyager/pkg/crypto"
@@ -31,7 +33,7 @@ func TestConnect(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	overlay, err := crypto.NewOverlayAddress(privateKey.PublicKey, 0)
+	overlay, err := crypto.NewOverlayAddress(privateKey.PublicKey, 0, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -40,7 +42,7 @@ func TestConnect(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	ifiAddress, err := ifi.NewAddress(crypto.NewDefaultSigner(privateKey), underlama, overlay, 0)
+	ifiAddress, err := ifi.NewAddress(crypto.NewDefaultSigner(privateKey), underlama, overlay, 0, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -158,16 +160,23 @@ func TestDisconnect(t *testing.T) {
 
 func TestPeer(t *testing.T) {
 	overlay := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+	identified := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59d")
+	const userAgent = "voyager/1.0.0 network/mainnet"
 	testServer := newTestServer(t, testServerOptions{
 		P2P: mock.New(mock.WithPeersFunc(func() []p2p.Peer {
-			return []p2p.Peer{{Address: overlay}}
+			return []p2p.Peer{{Address: overlay}, {Address: identified}}
+		}), mock.WithUserAgentFunc(func(addr infinity.Address) string {
+			if addr.Equal(identified) {
+				return userAgent
+			}
+			return ""
 		})),
 	})
 
 	t.Run("ok", func(t *testing.T) {
 		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/peers", http.StatusOK,
 			jsonhttptest.WithExpectedJSONResponse(debugapi.PeersResponse{
-				Peers: []p2p.Peer{{Address: overlay}},
+				Peers: []p2p.Peer{{Address: overlay}, {Address: identified, UserAgent: userAgent}},
 			}),
 		)
 	})
@@ -213,3 +222,86 @@ func TestBlocklistedPeersErr(t *testing.T) {
 			}),
 	)
 }
+
+func TestBandwidth(t *testing.T) {
+	bandwidth := p2p.Bandwidth{
+		Peers: map[string]p2p.BandwidthStats{
+			"ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c": {In: 10, Out: 20},
+		},
+		Protocols: map[string]p2p.BandwidthStats{
+			"retrieval": {In: 10, Out: 20},
+		},
+	}
+	testServer := newTestServer(t, testServerOptions{
+		P2P: mock.New(mock.WithBandwidthFunc(func() p2p.Bandwidth {
+			return bandwidth
+		})),
+	})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/bandwidth", http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(bandwidth),
+	)
+}
+
+func TestPeersWait(t *testing.T) {
+	defer func(d time.Duration) {
+		*debugapi.PeersWaitPollInterval = d
+	}(*debugapi.PeersWaitPollInterval)
+	*debugapi.PeersWaitPollInterval = 10 * time.Millisecond
+
+	overlay := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+	var (
+		mtx   sync.Mutex
+		peers []p2p.Peer
+	)
+	testServer := newTestServer(t, testServerOptions{
+		P2P: mock.New(mock.WithPeersFunc(func() []p2p.Peer {
+			mtx.Lock()
+			defer mtx.Unlock()
+			return peers
+		})),
+	})
+
+	t.Run("reached", func(t *testing.T) {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			mtx.Lock()
+			peers = append(peers, p2p.Peer{Address: overlay})
+			mtx.Unlock()
+		}()
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/peers/wait?min=1&timeout=5s", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.PeersWaitResponse{
+				Connected: 1,
+				Min:       1,
+			}),
+		)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/peers/wait?min=2&timeout=50ms", http.StatusRequestTimeout,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.PeersWaitResponse{
+				Connected: 1,
+				Min:       2,
+			}),
+		)
+	})
+
+	t.Run("invalid min", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/peers/wait?min=many", http.StatusBadRequest,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusBadRequest,
+				Message: "invalid min",
+			}),
+		)
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/peers/wait?min=1&timeout=1h", http.StatusBadRequest,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusBadRequest,
+				Message: "invalid timeout",
+			}),
+		)
+	})
+}
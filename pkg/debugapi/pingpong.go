@@ -5,17 +5,22 @@
 package debugapi
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/pingpong"
 )
 
 type pingpongResponse struct {
-	RTT string `json:"rtt"`
+	RTT       string  `json:"rtt"`
+	Bandwidth float64 `json:"bandwidth,omitempty"`
 }
 
 func (s *Service) pingpongHandler(w http.ResponseWriter, r *http.Request) {
@@ -32,6 +37,11 @@ func (s *Service) pingpongHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Query().Get("size") != "" {
+		s.pingpongProbe(ctx, logger, w, r, address)
+		return
+	}
+
 	rtt, err := s.pingpong.Ping(ctx, address, "hey", "there", ",", "how are", "you", "?")
 	if err != nil {
 		logger.Debugf("pingpong: ping %s: %v", peerID, err)
@@ -50,3 +60,45 @@ func (s *Service) pingpongHandler(w http.ResponseWriter, r *http.Request) {
 		RTT: rtt.String(),
 	})
 }
+
+// pingpongProbe measures the bandwidth with the payload size and the message
+// count from the query.
+func (s *Service) pingpongProbe(ctx context.Context, logger *logrus.Entry, w http.ResponseWriter, r *http.Request, address infinity.Address) {
+	size, err := strconv.Atoi(r.URL.Query().Get("size"))
+	if err != nil {
+		logger.Debugf("pingpong: parse size: %v", err)
+		jsonhttp.BadRequest(w, "invalid size")
+		return
+	}
+	count := 1
+	if v := r.URL.Query().Get("count"); v != "" {
+		count, err = strconv.Atoi(v)
+		if err != nil {
+			logger.Debugf("pingpong: parse count: %v", err)
+			jsonhttp.BadRequest(w, "invalid count")
+			return
+		}
+	}
+
+	m, err := s.pingpong.Probe(ctx, address, size, count)
+	if err != nil {
+		logger.Debugf("pingpong: probe %s: %v", address, err)
+		switch {
+		case errors.Is(err, pingpong.ErrInvalidProbe):
+			jsonhttp.BadRequest(w, "invalid size or count")
+		case errors.Is(err, p2p.ErrPeerNotFound):
+			jsonhttp.NotFound(w, "peer not found")
+		case errors.Is(err, pingpong.ErrPayloadNotEchoed):
+			jsonhttp.BadGateway(w, "peer does not support probes")
+		default:
+			logger.Errorf("pingpong probe failed to peer %s", address)
+			jsonhttp.InternalServerError(w, nil)
+		}
+		return
+	}
+
+	jsonhttp.OK(w, pingpongResponse{
+		RTT:       m.RTT.String(),
+		Bandwidth: m.Bandwidth,
+	})
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/pingpong"
 	pingpongmock "github.com/yanhuangpai/voyager/pkg/pingpong/mock"
 )
 
@@ -34,6 +35,19 @@ func TestPingpong(t *testing.T) {
 			return 0, p2p.ErrPeerNotFound
 		}
 		return rtt, nil
+	}).WithProbeFunc(func(ctx context.Context, address infinity.Address, size, count int) (pingpong.Measurement, error) {
+		switch {
+		case size <= 0 || size > pingpong.MaxPayloadSize || count <= 0 || count > pingpong.MaxProbeCount:
+			return pingpong.Measurement{}, pingpong.ErrInvalidProbe
+		case address.Equal(errorPeerID):
+			return pingpong.Measurement{}, pingpong.ErrPayloadNotEchoed
+		case !address.Equal(peerID):
+			return pingpong.Measurement{}, p2p.ErrPeerNotFound
+		}
+		return pingpong.Measurement{
+			RTT:       rtt,
+			Bandwidth: float64(size * count),
+		}, nil
 	})
 
 	ts := newTestServer(t, testServerOptions{
@@ -48,6 +62,39 @@ func TestPingpong(t *testing.T) {
 		)
 	})
 
+	t.Run("probe", func(t *testing.T) {
+		jsonhttptest.Request(t, ts.Client, http.MethodPost, "/pingpong/"+peerID.String()+"?size=4096&count=3", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.PingpongResponse{
+				RTT:       rtt.String(),
+				Bandwidth: 3 * 4096,
+			}),
+		)
+	})
+
+	t.Run("probe default count", func(t *testing.T) {
+		jsonhttptest.Request(t, ts.Client, http.MethodPost, "/pingpong/"+peerID.String()+"?size=4096", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.PingpongResponse{
+				RTT:       rtt.String(),
+				Bandwidth: 4096,
+			}),
+		)
+	})
+
+	t.Run("probe invalid size", func(t *testing.T) {
+		for _, q := range []string{"size=a", "size=0", "size=1&count=0", "size=1&count=101"} {
+			jsonhttptest.Request(t, ts.Client, http.MethodPost, "/pingpong/"+peerID.String()+"?"+q, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("probe not supported", func(t *testing.T) {
+		jsonhttptest.Request(t, ts.Client, http.MethodPost, "/pingpong/"+errorPeerID.String()+"?size=4096", http.StatusBadGateway,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusBadGateway,
+				Message: "peer does not support probes",
+			}),
+		)
+	})
+
 	t.Run("peer not found", func(t *testing.T) {
 		jsonhttptest.Request(t, ts.Client, http.MethodPost, "/pingpong/"+unknownPeerID.String(), http.StatusNotFound,
 			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
@@ -0,0 +1,148 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/topology"
+)
+
+var getCursorsTimeout = 10 * time.Second
+
+type pullsyncBinResponse struct {
+	Bin        uint8  `json:"bin"`
+	Cursor     uint64 `json:"cursor"`
+	PeerCursor uint64 `json:"peerCursor"`
+	Lag        uint64 `json:"lag"`
+}
+
+type pullsyncPeerResponse struct {
+	Address infinity.Address `json:"address"`
+	Cursors []uint64         `json:"cursors"`
+	Lag     []uint64         `json:"lag"`
+	Error   string           `json:"error,omitempty"`
+}
+
+type pullsyncCursorsResponse struct {
+	Depth uint8                  `json:"depth"`
+	Bins  []pullsyncBinResponse  `json:"bins"`
+	Peers []pullsyncPeerResponse `json:"peers"`
+}
+
+type pullsyncIncidentsPeerResponse struct {
+	Address     infinity.Address `json:"address"`
+	Unsolicited uint64           `json:"unsolicited"`
+	Invalid     uint64           `json:"invalid"`
+	Sanctions   uint64           `json:"sanctions"`
+	Last        time.Time        `json:"last"`
+}
+
+type pullsyncIncidentsResponse struct {
+	Peers []pullsyncIncidentsPeerResponse `json:"peers"`
+}
+
+// pullsyncCursorsHandler fetches the cursors of all neighbors and compares
+// them with the local ones. The lag of a bin is the difference between the
+// highest cursor of the neighbors and the local cursor.
+func (s *Service) pullsyncCursorsHandler(w http.ResponseWriter, r *http.Request) {
+	cursors, err := s.pullSync.Cursors(r.Context())
+	if err != nil {
+		s.logger.Debugf("debug api: pullsync cursors: local cursors: %v", err)
+		s.logger.Error("debug api: pullsync cursors: can not get local cursors")
+		jsonhttp.InternalServerError(w, "cannot get local cursors")
+		return
+	}
+
+	depth := s.topologyDriver.NeighborhoodDepth()
+	var neighbors []infinity.Address
+	if err := s.topologyDriver.EachPeerWith(func(addr infinity.Address, _ uint8) (bool, bool, error) {
+		neighbors = append(neighbors, addr)
+		return false, false, nil
+	}, topology.WithBins(depth, infinity.MaxPO)); err != nil {
+		s.logger.Debugf("debug api: pullsync cursors: iterate neighbors: %v", err)
+		s.logger.Error("debug api: pullsync cursors: can not iterate neighbors")
+		jsonhttp.InternalServerError(w, "cannot iterate neighbors")
+		return
+	}
+
+	peers := make([]pullsyncPeerResponse, len(neighbors))
+	var wg sync.WaitGroup
+	for i, addr := range neighbors {
+		wg.Add(1)
+		go func(i int, addr infinity.Address) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), getCursorsTimeout)
+			defer cancel()
+
+			peers[i].Address = addr
+			c, err := s.pullSync.GetCursors(ctx, addr)
+			if err != nil {
+				s.logger.Debugf("debug api: pullsync cursors: get cursors from peer %s: %v", addr, err)
+				peers[i].Error = err.Error()
+				return
+			}
+			peers[i].Cursors = c
+			peers[i].Lag = make([]uint64, len(c))
+			for bin := range c {
+				if bin < len(cursors) && c[bin] > cursors[bin] {
+					peers[i].Lag[bin] = c[bin] - cursors[bin]
+				}
+			}
+		}(i, addr)
+	}
+	wg.Wait()
+
+	bins := make([]pullsyncBinResponse, len(cursors))
+	for bin, cursor := range cursors {
+		bins[bin] = pullsyncBinResponse{
+			Bin:    uint8(bin),
+			Cursor: cursor,
+		}
+		for _, p := range peers {
+			if bin < len(p.Cursors) && p.Cursors[bin] > bins[bin].PeerCursor {
+				bins[bin].PeerCursor = p.Cursors[bin]
+			}
+		}
+		if bins[bin].PeerCursor > cursor {
+			bins[bin].Lag = bins[bin].PeerCursor - cursor
+		}
+		s.metrics.PullsyncCursorLag.WithLabelValues(strconv.Itoa(bin)).Set(float64(bins[bin].Lag))
+	}
+
+	jsonhttp.OK(w, pullsyncCursorsResponse{
+		Depth: depth,
+		Bins:  bins,
+		Peers: peers,
+	})
+}
+
+// pullsyncIncidentsHandler lists the peers which delivered unsolicited or
+// invalid chunks while syncing, with the number of the times they were
+// blocklisted for them.
+func (s *Service) pullsyncIncidentsHandler(w http.ResponseWriter, r *http.Request) {
+	incidents := s.pullSync.Incidents()
+	peers := make([]pullsyncIncidentsPeerResponse, 0, len(incidents))
+	for _, i := range incidents {
+		peers = append(peers, pullsyncIncidentsPeerResponse{
+			Address:     i.Peer,
+			Unsolicited: i.Unsolicited,
+			Invalid:     i.Invalid,
+			Sanctions:   i.Sanctions,
+			Last:        i.Last,
+		})
+	}
+
+	jsonhttp.OK(w, pullsyncIncidentsResponse{
+		Peers: peers,
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/pullsync"
+	pullsyncmock "github.com/yanhuangpai/voyager/pkg/pullsync/mock"
+	topologymock "github.com/yanhuangpai/voyager/pkg/topology/mock"
+)
+
+func TestPullsyncCursors(t *testing.T) {
+	peer1 := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+	peer2 := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59d")
+
+	testServer := newTestServer(t, testServerOptions{
+		TopologyOpts: []topologymock.Option{topologymock.WithPeers(peer1, peer2)},
+		PullSyncOpts: []pullsyncmock.Option{
+			pullsyncmock.WithLocalCursors([]uint64{10, 20, 30}),
+			pullsyncmock.WithPeerCursors(peer1, []uint64{15, 20, 25}),
+			pullsyncmock.WithPeerCursors(peer2, []uint64{12, 24}),
+		},
+	})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/pullsync/cursors", http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.PullsyncCursorsResponse{
+			Depth: 0,
+			Bins: []debugapi.PullsyncBinResponse{
+				{Bin: 0, Cursor: 10, PeerCursor: 15, Lag: 5},
+				{Bin: 1, Cursor: 20, PeerCursor: 24, Lag: 4},
+				{Bin: 2, Cursor: 30, PeerCursor: 25, Lag: 0},
+			},
+			Peers: []debugapi.PullsyncPeerResponse{
+				{Address: peer1, Cursors: []uint64{15, 20, 25}, Lag: []uint64{5, 0, 0}},
+				{Address: peer2, Cursors: []uint64{12, 24}, Lag: []uint64{2, 4}},
+			},
+		}),
+	)
+}
+
+func TestPullsyncIncidents(t *testing.T) {
+	peer := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+	last := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("ok", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{
+			PullSyncOpts: []pullsyncmock.Option{
+				pullsyncmock.WithIncidents(pullsync.Incidents{
+					Peer:        peer,
+					Unsolicited: 2,
+					Invalid:     1,
+					Sanctions:   1,
+					Last:        last,
+				}),
+			},
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/pullsync/incidents", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.PullsyncIncidentsResponse{
+				Peers: []debugapi.PullsyncIncidentsPeerResponse{
+					{Address: peer, Unsolicited: 2, Invalid: 1, Sanctions: 1, Last: last},
+				},
+			}),
+		)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/pullsync/incidents", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.PullsyncIncidentsResponse{
+				Peers: []debugapi.PullsyncIncidentsPeerResponse{},
+			}),
+		)
+	})
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+var errCantReconciliation = "Cannot get reconciliation"
+
+// reconciliationResponse holds the amounts of the operations with the peer,
+// the unsettled amount is reserved for the attempted operations which are
+// not credited to the peer.
+type reconciliationResponse struct {
+	Peer      string `json:"peer"`
+	Reserved  uint64 `json:"reserved"`
+	Credited  uint64 `json:"credited"`
+	Unsettled uint64 `json:"unsettled"`
+	Debited   uint64 `json:"debited"`
+}
+
+type reconciliationsResponse struct {
+	Reconciliation []reconciliationResponse `json:"reconciliation"`
+}
+
+// reconciliationHandler lists the attempted and the settled amounts of the
+// operations with all known peers, sorted by the peer address.
+func (s *Service) reconciliationHandler(w http.ResponseWriter, r *http.Request) {
+	reconciliation, err := s.accounting.Reconciliation()
+	if err != nil {
+		jsonhttp.InternalServerError(w, errCantReconciliation)
+		s.logger.Debugf("debug api: reconciliation: %v", err)
+		s.logger.Error("debug api: can not get reconciliation")
+		return
+	}
+
+	responses := make([]reconciliationResponse, 0, len(reconciliation))
+	for peer, r := range reconciliation {
+		responses = append(responses, reconciliationResponse{
+			Peer:      peer,
+			Reserved:  r.Reserved,
+			Credited:  r.Credited,
+			Unsettled: r.Unsettled(),
+			Debited:   r.Debited,
+		})
+	}
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Peer < responses[j].Peer
+	})
+
+	jsonhttp.OK(w, reconciliationsResponse{Reconciliation: responses})
+}
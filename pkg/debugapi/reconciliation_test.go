@@ -0,0 +1,69 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/accounting/mock"
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+)
+
+func TestReconciliation(t *testing.T) {
+	reconciliationFunc := func() (map[string]accounting.ReconciliationStats, error) {
+		return map[string]accounting.ReconciliationStats{
+			"DEAD": {
+				Reserved: 100,
+				Credited: 60,
+				Debited:  30,
+			},
+			"BEEF": {
+				Debited: 20,
+			},
+		}, nil
+	}
+	testServer := newTestServer(t, testServerOptions{
+		AccountingOpts: []mock.Option{mock.WithReconciliationFunc(reconciliationFunc)},
+	})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/accounting/reconciliation", http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.ReconciliationsResponse{
+			Reconciliation: []debugapi.ReconciliationResponse{
+				{
+					Peer:    "BEEF",
+					Debited: 20,
+				},
+				{
+					Peer:      "DEAD",
+					Reserved:  100,
+					Credited:  60,
+					Unsettled: 40,
+					Debited:   30,
+				},
+			},
+		}),
+	)
+}
+
+func TestReconciliationError(t *testing.T) {
+	reconciliationFunc := func() (map[string]accounting.ReconciliationStats, error) {
+		return nil, errors.New("reconciliation error")
+	}
+	testServer := newTestServer(t, testServerOptions{
+		AccountingOpts: []mock.Option{mock.WithReconciliationFunc(reconciliationFunc)},
+	})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/accounting/reconciliation", http.StatusInternalServerError,
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Message: debugapi.ErrCantReconciliation,
+			Code:    http.StatusInternalServerError,
+		}),
+	)
+}
@@ -15,8 +15,10 @@ import (
 	"github.com/sirupsen/logrus"
 	"resenje.org/web"
 
+	"github.com/yanhuangpai/voyager/pkg/api"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 	"github.com/yanhuangpai/voyager/pkg/logging/httpaccess"
+	"github.com/yanhuangpai/voyager/pkg/tags"
 )
 
 // newBasicRouter constructs only the routes that do not depend on the injected dependencies:
@@ -52,13 +54,21 @@ func (s *Service) newBasicRouter() *mux.Router {
 
 	router.Handle("/health", web.ChainHandlers(
 		httpaccess.SetAccessLogLevelHandler(0), // suppress access log messages
-		web.FinalHandlerFunc(statusHandler),
+		web.FinalHandlerFunc(s.healthHandler),
 	))
 
 	router.Handle("/addresses", jsonhttp.MethodHandler{
 		"GET": http.HandlerFunc(s.addressesHandler),
 	})
 
+	router.Handle("/loggers", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.loggersHandler),
+	})
+
+	router.Handle("/loggers/{subsystem}/{verbosity}", jsonhttp.MethodHandler{
+		"PUT": http.HandlerFunc(s.setLoggerLevelHandler),
+	})
+
 	return router
 }
 
@@ -80,13 +90,30 @@ func (s *Service) newRouter() *mux.Router {
 	router.Handle("/connect/{multi-address:.+}", jsonhttp.MethodHandler{
 		"POST": http.HandlerFunc(s.peerConnectHandler),
 	})
+	router.Handle("/listen", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.listenAddressesHandler),
+	})
+	router.Handle("/listen/{multi-address:.+}", jsonhttp.MethodHandler{
+		"POST":   http.HandlerFunc(s.listenHandler),
+		"DELETE": http.HandlerFunc(s.closeListenerHandler),
+	})
 	router.Handle("/peers", jsonhttp.MethodHandler{
 		"GET": http.HandlerFunc(s.peersHandler),
 	})
 	router.Handle("/blocklist", jsonhttp.MethodHandler{
 		"GET": http.HandlerFunc(s.blocklistedPeersHandler),
 	})
+	router.Handle("/debug/connection-events", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.connectionEventsHandler),
+	})
 
+	router.Handle("/bandwidth", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.bandwidthHandler),
+	})
+
+	router.Handle("/peers/wait", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.peersWaitHandler),
+	})
 	router.Handle("/peers/{address}", jsonhttp.MethodHandler{
 		"DELETE": http.HandlerFunc(s.peerDisconnectHandler),
 	})
@@ -94,9 +121,21 @@ func (s *Service) newRouter() *mux.Router {
 		"GET":    http.HandlerFunc(s.hasChunkHandler),
 		"DELETE": http.HandlerFunc(s.removeChunk),
 	})
+	router.Handle("/storage/stats", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.storageStatsHandler),
+	})
+	router.Handle("/storage/popular", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.popularChunksHandler),
+	})
 	router.Handle("/topology", jsonhttp.MethodHandler{
 		"GET": http.HandlerFunc(s.topologyHandler),
 	})
+	router.Handle("/pullsync/cursors", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.pullsyncCursorsHandler),
+	})
+	router.Handle("/pullsync/incidents", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.pullsyncIncidentsHandler),
+	})
 	router.Handle("/welcome-message", jsonhttp.MethodHandler{
 		"GET": http.HandlerFunc(s.getWelcomeMessageHandler),
 		"POST": web.ChainHandlers(
@@ -121,6 +160,32 @@ func (s *Service) newRouter() *mux.Router {
 		"GET": http.HandlerFunc(s.peerBalanceHandler),
 	})
 
+	router.Handle("/traffic", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.trafficHandler),
+	})
+
+	router.Handle("/traffic/{peer}", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.peerTrafficHandler),
+	})
+
+	router.Handle("/accounting/reconciliation", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.reconciliationHandler),
+	})
+
+	router.Handle("/accounting/overrides", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.overridesHandler),
+	})
+
+	router.Handle("/accounting/overrides/{peer}", jsonhttp.MethodHandler{
+		"GET":    http.HandlerFunc(s.peerOverrideHandler),
+		"PUT":    http.HandlerFunc(s.setPeerOverrideHandler),
+		"DELETE": http.HandlerFunc(s.removePeerOverrideHandler),
+	})
+
+	router.Handle("/telemetry", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.telemetryHandler),
+	})
+
 	router.Handle("/settlements", jsonhttp.MethodHandler{
 		"GET": http.HandlerFunc(s.settlementsHandler),
 	})
@@ -129,46 +194,110 @@ func (s *Service) newRouter() *mux.Router {
 		"GET": http.HandlerFunc(s.peerSettlementsHandler),
 	})
 
-	if s.chequebookEnabled {
-		router.Handle("/chequebook/balance", jsonhttp.MethodHandler{
-			"GET": http.HandlerFunc(s.chequebookBalanceHandler),
-		})
+	router.Handle("/chequebook/balance", s.chequebookHandler(jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.chequebookBalanceHandler),
+	}))
 
-		router.Handle("/chequebook/address", jsonhttp.MethodHandler{
-			"GET": http.HandlerFunc(s.chequebookAddressHandler),
-		})
+	router.Handle("/chequebook/address", s.chequebookHandler(jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.chequebookAddressHandler),
+	}))
 
-		router.Handle("/chequebook/deposit", jsonhttp.MethodHandler{
-			"POST": http.HandlerFunc(s.chequebookDepositHandler),
-		})
+	router.Handle("/chequebook/deposit", s.chequebookHandler(jsonhttp.MethodHandler{
+		"POST": http.HandlerFunc(s.chequebookDepositHandler),
+	}))
 
-		router.Handle("/chequebook/withdraw", jsonhttp.MethodHandler{
-			"POST": http.HandlerFunc(s.chequebookWithdrawHandler),
-		})
+	router.Handle("/chequebook/withdraw", s.chequebookHandler(jsonhttp.MethodHandler{
+		"POST": http.HandlerFunc(s.chequebookWithdrawHandler),
+	}))
 
-		router.Handle("/chequebook/cheque/{peer}", jsonhttp.MethodHandler{
-			"GET": http.HandlerFunc(s.chequebookLastPeerHandler),
-		})
+	router.Handle("/chequebook/transaction/{hash}", s.chequebookHandler(jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.chequebookTxStatusHandler),
+	}))
 
-		router.Handle("/chequebook/cheque", jsonhttp.MethodHandler{
-			"GET": http.HandlerFunc(s.chequebookAllLastHandler),
-		})
+	router.Handle("/chequebook/cheque/{peer}", s.chequebookHandler(jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.chequebookLastPeerHandler),
+	}))
 
-		router.Handle("/chequebook/cashout/{peer}", jsonhttp.MethodHandler{
-			"GET":  http.HandlerFunc(s.swapCashoutStatusHandler),
-			"POST": http.HandlerFunc(s.swapCashoutHandler),
-		})
-	}
+	router.Handle("/chequebook/cheque", s.chequebookHandler(jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.chequebookAllLastHandler),
+	}))
+
+	router.Handle("/chequebook/cashout/{peer}", s.chequebookHandler(jsonhttp.MethodHandler{
+		"GET":  http.HandlerFunc(s.swapCashoutStatusHandler),
+		"POST": http.HandlerFunc(s.swapCashoutHandler),
+	}))
+
+	router.Handle("/chequebook/paused", s.chequebookHandler(jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.swapPausedPeersHandler),
+	}))
+
+	router.Handle("/chequebook/paused/{peer}", s.chequebookHandler(jsonhttp.MethodHandler{
+		"GET":    http.HandlerFunc(s.swapPauseStatusHandler),
+		"PUT":    http.HandlerFunc(s.swapPauseHandler),
+		"DELETE": http.HandlerFunc(s.swapResumeHandler),
+	}))
+
+	tagHandlers := api.NewTagHandlers(s.tags, s.logger, func(t *tags.Tag) interface{} {
+		return newTagResponse(t)
+	})
+	router.Handle("/tags", jsonhttp.MethodHandler{
+		"POST": web.ChainHandlers(
+			jsonhttp.NewMaxBodyBytesHandler(1024),
+			web.FinalHandlerFunc(tagHandlers.Create),
+		),
+	})
 
 	router.Handle("/tags/{id}", jsonhttp.MethodHandler{
 		"GET": http.HandlerFunc(s.getTagHandler),
+		"PATCH": web.ChainHandlers(
+			jsonhttp.NewMaxBodyBytesHandler(1024),
+			web.FinalHandlerFunc(tagHandlers.DoneSplit),
+		),
+	})
+
+	router.Handle("/routes", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.routesHandler),
+	})
+
+	router.Handle("/config/reload", jsonhttp.MethodHandler{
+		"POST": http.HandlerFunc(s.configReloadHandler),
+	})
+
+	router.Handle("/access-log/body-capture", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.bodyCaptureHandler),
+		"PUT": http.HandlerFunc(s.setBodyCaptureHandler),
+	})
+
+	router.Handle("/features", jsonhttp.MethodHandler{
+		"GET": http.HandlerFunc(s.featuresHandler),
+		"PUT": http.HandlerFunc(s.setFeaturesHandler),
+	})
+
+	router.Handle("/apikeys", jsonhttp.MethodHandler{
+		"GET":  http.HandlerFunc(s.apiKeysHandler),
+		"POST": http.HandlerFunc(s.createAPIKeyHandler),
+	})
+	router.Handle("/apikeys/{id}", jsonhttp.MethodHandler{
+		"DELETE": http.HandlerFunc(s.revokeAPIKeyHandler),
 	})
 
 	return router
 }
 
+// chequebookHandler serves the chequebook routes only if the chequebook is
+// enabled.
+func (s *Service) chequebookHandler(h http.Handler) http.Handler {
+	return &jsonhttp.ToggleHandler{
+		Handler:  h,
+		Disabled: http.HandlerFunc(jsonhttp.NotFoundHandler),
+		Enabled: func() bool {
+			return s.chequebookEnabled
+		},
+	}
+}
+
 // setRouter sets the base Debug API handler with common middlewares.
-func (s *Service) setRouter(router http.Handler) {
+func (s *Service) setRouter(router *mux.Router) {
 	h := http.NewServeMux()
 	h.Handle("/", web.ChainHandlers(
 		httpaccess.NewHTTPAccessLogHandler(s.logger, logrus.InfoLevel, s.tracer, "debug api access"),
@@ -182,4 +311,5 @@ func (s *Service) setRouter(router http.Handler) {
 	defer s.handlerMu.Unlock()
 
 	s.handler = h
+	s.router = router
 }
@@ -0,0 +1,52 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"net/http"
+
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+// RouteLister lists the routes of an HTTP API.
+type RouteLister interface {
+	Routes() ([]jsonhttp.Route, error)
+}
+
+type routesResponse struct {
+	API      []jsonhttp.Route `json:"api"`
+	DebugAPI []jsonhttp.Route `json:"debugApi"`
+}
+
+// routesHandler lists the routes of the API and the Debug API with their
+// methods and whether they are enabled in the current mode.
+func (s *Service) routesHandler(w http.ResponseWriter, r *http.Request) {
+	s.handlerMu.RLock()
+	router, apiRoutes := s.router, s.apiRoutes
+	s.handlerMu.RUnlock()
+
+	var (
+		resp routesResponse
+		err  error
+	)
+	resp.DebugAPI, err = jsonhttp.Routes(router)
+	if err != nil {
+		s.logger.Debugf("debug api: routes: debug api: %v", err)
+		s.logger.Error("debug api: can not list routes")
+		jsonhttp.InternalServerError(w, nil)
+		return
+	}
+	if apiRoutes != nil {
+		resp.API, err = apiRoutes.Routes()
+		if err != nil {
+			s.logger.Debugf("debug api: routes: api: %v", err)
+			s.logger.Error("debug api: can not list routes")
+			jsonhttp.InternalServerError(w, nil)
+			return
+		}
+	}
+
+	jsonhttp.OK(w, resp)
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+)
+
+type routeLister []jsonhttp.Route
+
+func (l routeLister) Routes() ([]jsonhttp.Route, error) {
+	return l, nil
+}
+
+func TestRoutes(t *testing.T) {
+	apiRoutes := routeLister{
+		{Path: "/bytes", Methods: []string{"POST"}, Enabled: true},
+		{Path: "/tags", Methods: []string{"GET", "POST"}, Enabled: false},
+	}
+	testServer := newTestServer(t, testServerOptions{
+		APIRoutes: apiRoutes,
+	})
+
+	var resp debugapi.RoutesResponse
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/routes", http.StatusOK,
+		jsonhttptest.WithUnmarshalJSONResponse(&resp),
+	)
+
+	if !reflect.DeepEqual(resp.API, []jsonhttp.Route(apiRoutes)) {
+		t.Errorf("got api routes %+v, want %+v", resp.API, apiRoutes)
+	}
+
+	want := map[string]jsonhttp.Route{
+		"/health":                    {Path: "/health", Enabled: true},
+		"/routes":                    {Path: "/routes", Methods: []string{"GET"}, Enabled: true},
+		"/chunks/{address}":          {Path: "/chunks/{address}", Methods: []string{"DELETE", "GET"}, Enabled: true},
+		"/chequebook/cashout/{peer}": {Path: "/chequebook/cashout/{peer}", Methods: []string{"GET", "POST"}, Enabled: true},
+	}
+	for _, r := range resp.DebugAPI {
+		w, ok := want[r.Path]
+		if !ok {
+			continue
+		}
+		if !reflect.DeepEqual(r, w) {
+			t.Errorf("got route %+v, want %+v", r, w)
+		}
+		delete(want, r.Path)
+	}
+	for path := range want {
+		t.Errorf("route %s not listed", path)
+	}
+}
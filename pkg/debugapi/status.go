@@ -5,15 +5,39 @@
 package debugapi
 
 import (
+	"context"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/yanhuangpai/voyager"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 )
 
+// healthCheckTimeout is the maximal duration of a single health check.
+var healthCheckTimeout = 5 * time.Second
+
+// HealthCheck checks a service the node depends on and returns an error if it
+// is not available.
+type HealthCheck func(ctx context.Context) error
+
+type namedHealthCheck struct {
+	name  string
+	check HealthCheck
+}
+
 type statusResponse struct {
+	Status  string                `json:"status"`
+	Version string                `json:"version"`
+	Checks  []healthCheckResponse `json:"checks,omitempty"`
+}
+
+type healthCheckResponse struct {
+	Name    string `json:"name"`
 	Status  string `json:"status"`
-	Version string `json:"version"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
 }
 
 func statusHandler(w http.ResponseWriter, r *http.Request) {
@@ -22,3 +46,79 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 		Version: voyager.Version,
 	})
 }
+
+// AddHealthCheck adds the check of a service the node depends on, reported
+// under the name by the verbose health endpoint.
+func (s *Service) AddHealthCheck(name string, check HealthCheck) {
+	s.healthChecksMu.Lock()
+	defer s.healthChecksMu.Unlock()
+
+	s.healthChecks = append(s.healthChecks, namedHealthCheck{name: name, check: check})
+}
+
+// healthHandler reports the health of the node. With the verbose query
+// parameter, it runs the health checks of the dependent services and responds
+// with the service unavailable status if any of them fails.
+func (s *Service) healthHandler(w http.ResponseWriter, r *http.Request) {
+	verbose := false
+	if v := r.URL.Query().Get("verbose"); v != "" {
+		var err error
+		verbose, err = strconv.ParseBool(v)
+		if err != nil {
+			s.logger.Debugf("debug api: health: parse verbose %s: %v", v, err)
+			s.logger.Error("debug api: health: bad verbose")
+			jsonhttp.BadRequest(w, "bad verbose")
+			return
+		}
+	}
+	if !verbose {
+		statusHandler(w, r)
+		return
+	}
+
+	s.healthChecksMu.RLock()
+	checks := s.healthChecks
+	s.healthChecksMu.RUnlock()
+
+	resp := statusResponse{
+		Status:  "ok",
+		Version: voyager.Version,
+		Checks:  make([]healthCheckResponse, len(checks)),
+	}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedHealthCheck) {
+			defer wg.Done()
+			resp.Checks[i] = runHealthCheck(r.Context(), c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, c := range resp.Checks {
+		if c.Error != "" {
+			resp.Status = "error"
+			jsonhttp.ServiceUnavailable(w, resp)
+			return
+		}
+	}
+	jsonhttp.OK(w, resp)
+}
+
+func runHealthCheck(ctx context.Context, c namedHealthCheck) healthCheckResponse {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.check(ctx)
+	resp := healthCheckResponse{
+		Name:    c.name,
+		Status:  "ok",
+		Latency: time.Since(start).String(),
+	}
+	if err != nil {
+		resp.Status = "error"
+		resp.Error = err.Error()
+	}
+	return resp
+}
@@ -5,6 +5,8 @@
 package debugapi_test
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"testing"
 
@@ -24,6 +26,72 @@ func TestHealth(t *testing.T) {
 	)
 }
 
+func TestHealthVerbose(t *testing.T) {
+	testErr := errors.New("test error")
+
+	t.Run("ok", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{
+			HealthChecks: map[string]debugapi.HealthCheck{
+				"statestore": func(ctx context.Context) error {
+					return nil
+				},
+			},
+		})
+
+		var resp debugapi.StatusResponse
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/health?verbose=true", http.StatusOK,
+			jsonhttptest.WithUnmarshalJSONResponse(&resp),
+		)
+		if resp.Status != "ok" {
+			t.Errorf("got status %q, want %q", resp.Status, "ok")
+		}
+		if len(resp.Checks) != 1 {
+			t.Fatalf("got %d checks, want 1", len(resp.Checks))
+		}
+		if c := resp.Checks[0]; c.Name != "statestore" || c.Status != "ok" || c.Error != "" || c.Latency == "" {
+			t.Errorf("got check %+v", c)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{
+			HealthChecks: map[string]debugapi.HealthCheck{
+				"chain": func(ctx context.Context) error {
+					return testErr
+				},
+			},
+		})
+
+		var resp debugapi.StatusResponse
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/health?verbose=true", http.StatusServiceUnavailable,
+			jsonhttptest.WithUnmarshalJSONResponse(&resp),
+		)
+		if resp.Status != "error" {
+			t.Errorf("got status %q, want %q", resp.Status, "error")
+		}
+		if len(resp.Checks) != 1 {
+			t.Fatalf("got %d checks, want 1", len(resp.Checks))
+		}
+		if c := resp.Checks[0]; c.Name != "chain" || c.Status != "error" || c.Error != testErr.Error() {
+			t.Errorf("got check %+v", c)
+		}
+
+		// the checks are run only on verbose requests
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/health", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.StatusResponse{
+				Status:  "ok",
+				Version: voyager.Version,
+			}),
+		)
+	})
+
+	t.Run("bad verbose", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/health?verbose=maybe", http.StatusBadRequest)
+	})
+}
+
 func TestReadiness(t *testing.T) {
 	testServer := newTestServer(t, testServerOptions{})
 
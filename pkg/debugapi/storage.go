@@ -0,0 +1,112 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+const (
+	defaultPopularChunksLimit = 10
+	maxPopularChunksLimit     = 1000
+)
+
+var (
+	errCantStorageStats  = "cannot get storage stats"
+	errCantPopularChunks = "cannot get popular chunks"
+)
+
+type namespaceStatsResponse struct {
+	Size     uint64 `json:"size"`
+	Capacity uint64 `json:"capacity"`
+}
+
+type storageStatsResponse struct {
+	Pinned namespaceStatsResponse `json:"pinned"`
+	Cache  namespaceStatsResponse `json:"cache"`
+	Upload namespaceStatsResponse `json:"upload"`
+}
+
+// storageStatsHandler reports the number of the chunks in the pinned, cache
+// and upload namespaces of the local store and their capacities, zero
+// capacity is not limited.
+func (s *Service) storageStatsHandler(w http.ResponseWriter, r *http.Request) {
+	reporter, ok := s.storer.(storage.UsageReporter)
+	if !ok {
+		jsonhttp.NotImplemented(w, "storage stats not supported")
+		return
+	}
+
+	usage, err := reporter.Usage()
+	if err != nil {
+		s.logger.Debugf("debug api: storage stats: %v", err)
+		s.logger.Error("debug api: can not get storage stats")
+		jsonhttp.InternalServerError(w, errCantStorageStats)
+		return
+	}
+
+	jsonhttp.OK(w, storageStatsResponse{
+		Pinned: namespaceStatsResponse(usage.Pinned),
+		Cache:  namespaceStatsResponse(usage.Cache),
+		Upload: namespaceStatsResponse(usage.Upload),
+	})
+}
+
+type popularChunkResponse struct {
+	Address    infinity.Address `json:"address"`
+	Count      uint64           `json:"count"`
+	LastAccess time.Time        `json:"lastAccess"`
+}
+
+type popularChunksResponse struct {
+	Chunks []popularChunkResponse `json:"chunks"`
+}
+
+// popularChunksHandler reports the locally stored chunks with the most
+// requests, at most the number given in the limit query parameter.
+func (s *Service) popularChunksHandler(w http.ResponseWriter, r *http.Request) {
+	reporter, ok := s.storer.(storage.PopularityReporter)
+	if !ok {
+		jsonhttp.NotImplemented(w, "popular chunks not supported")
+		return
+	}
+
+	limit := defaultPopularChunksLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxPopularChunksLimit {
+			s.logger.Debugf("debug api: popular chunks: parse limit %q: %v", v, err)
+			jsonhttp.BadRequest(w, "invalid limit")
+			return
+		}
+	}
+
+	chunks, err := reporter.MostAccessed(limit)
+	if err != nil {
+		s.logger.Debugf("debug api: popular chunks: %v", err)
+		s.logger.Error("debug api: can not get popular chunks")
+		jsonhttp.InternalServerError(w, errCantPopularChunks)
+		return
+	}
+
+	resp := popularChunksResponse{
+		Chunks: make([]popularChunkResponse, 0, len(chunks)),
+	}
+	for _, c := range chunks {
+		resp.Chunks = append(resp.Chunks, popularChunkResponse{
+			Address:    c.Address,
+			Count:      c.Count,
+			LastAccess: c.LastAccess,
+		})
+	}
+	jsonhttp.OK(w, resp)
+}
@@ -0,0 +1,176 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+)
+
+// usageStorer is a mock storer which reports the namespace usage.
+type usageStorer struct {
+	storage.Storer
+	usage storage.Usage
+	err   error
+}
+
+func (s usageStorer) Usage() (storage.Usage, error) {
+	return s.usage, s.err
+}
+
+func TestStorageStats(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{
+			Storer: usageStorer{
+				Storer: mock.NewStorer(),
+				usage: storage.Usage{
+					Pinned: storage.NamespaceUsage{Size: 10, Capacity: 100},
+					Cache:  storage.NamespaceUsage{Size: 20, Capacity: 200},
+					Upload: storage.NamespaceUsage{Size: 30},
+				},
+			},
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/storage/stats", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.StorageStatsResponse{
+				Pinned: debugapi.NamespaceStatsResponse{Size: 10, Capacity: 100},
+				Cache:  debugapi.NamespaceStatsResponse{Size: 20, Capacity: 200},
+				Upload: debugapi.NamespaceStatsResponse{Size: 30},
+			}),
+		)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{
+			Storer: usageStorer{
+				Storer: mock.NewStorer(),
+				err:    errors.New("test error"),
+			},
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/storage/stats", http.StatusInternalServerError,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusInternalServerError,
+				Message: debugapi.ErrCantStorageStats,
+			}),
+		)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{
+			Storer: mock.NewStorer(),
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/storage/stats", http.StatusNotImplemented,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusNotImplemented,
+				Message: "storage stats not supported",
+			}),
+		)
+	})
+}
+
+// popularityStorer is a mock storer which reports the most requested chunks.
+type popularityStorer struct {
+	storage.Storer
+	chunks []storage.ChunkAccess
+	limit  int
+	err    error
+}
+
+func (s *popularityStorer) MostAccessed(n int) ([]storage.ChunkAccess, error) {
+	s.limit = n
+	if n < len(s.chunks) {
+		return s.chunks[:n], s.err
+	}
+	return s.chunks, s.err
+}
+
+func TestPopularChunks(t *testing.T) {
+	lastAccess := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	storer := &popularityStorer{
+		Storer: mock.NewStorer(),
+		chunks: []storage.ChunkAccess{
+			{Address: infinity.MustParseHexAddress("aa"), Count: 10, LastAccess: lastAccess},
+			{Address: infinity.MustParseHexAddress("bb"), Count: 5, LastAccess: lastAccess},
+		},
+	}
+	testServer := newTestServer(t, testServerOptions{
+		Storer: storer,
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/storage/popular", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.PopularChunksResponse{
+				Chunks: []debugapi.PopularChunkResponse{
+					{Address: infinity.MustParseHexAddress("aa"), Count: 10, LastAccess: lastAccess},
+					{Address: infinity.MustParseHexAddress("bb"), Count: 5, LastAccess: lastAccess},
+				},
+			}),
+		)
+		if storer.limit != 10 {
+			t.Fatalf("got limit %d, want %d", storer.limit, 10)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/storage/popular?limit=1", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.PopularChunksResponse{
+				Chunks: []debugapi.PopularChunkResponse{
+					{Address: infinity.MustParseHexAddress("aa"), Count: 10, LastAccess: lastAccess},
+				},
+			}),
+		)
+	})
+
+	for _, limit := range []string{"none", "0", "1001"} {
+		t.Run("invalid limit "+limit, func(t *testing.T) {
+			jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/storage/popular?limit="+limit, http.StatusBadRequest,
+				jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+					Code:    http.StatusBadRequest,
+					Message: "invalid limit",
+				}),
+			)
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{
+			Storer: &popularityStorer{
+				Storer: mock.NewStorer(),
+				err:    errors.New("test error"),
+			},
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/storage/popular", http.StatusInternalServerError,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusInternalServerError,
+				Message: debugapi.ErrCantPopularChunks,
+			}),
+		)
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{
+			Storer: mock.NewStorer(),
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/storage/popular", http.StatusNotImplemented,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Code:    http.StatusNotImplemented,
+				Message: "popular chunks not supported",
+			}),
+		)
+	})
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+var (
+	errCantPausedPeers = "cannot get paused peers"
+	errCantPause       = "cannot pause settlements"
+	errCantResume      = "cannot resume settlements"
+)
+
+type swapPauseResponse struct {
+	Peer   string `json:"peer"`
+	Paused bool   `json:"paused"`
+}
+
+type swapPausedPeersResponse struct {
+	Peers []string `json:"peers"`
+}
+
+func (s *Service) swapPausedPeersHandler(w http.ResponseWriter, r *http.Request) {
+	overrides, err := s.accounting.PeerOverrides()
+	if err != nil {
+		s.logger.Debugf("debug api: paused peers: %v", err)
+		s.logger.Error("debug api: paused peers: cannot get paused peers")
+		jsonhttp.InternalServerError(w, errCantPausedPeers)
+		return
+	}
+
+	resp := swapPausedPeersResponse{Peers: make([]string, 0, len(overrides))}
+	for peer, o := range overrides {
+		if o.SwapDisabled {
+			resp.Peers = append(resp.Peers, peer)
+		}
+	}
+	sort.Strings(resp.Peers)
+
+	jsonhttp.OK(w, resp)
+}
+
+func (s *Service) swapPauseStatusHandler(w http.ResponseWriter, r *http.Request) {
+	peer, ok := s.swapPausePeer(w, r, "pause status")
+	if !ok {
+		return
+	}
+
+	o, err := s.accounting.PeerOverride(peer)
+	if err != nil && !errors.Is(err, accounting.ErrPeerNoOverride) {
+		s.logger.Debugf("debug api: pause status: peer %s: %v", peer, err)
+		s.logger.Errorf("debug api: pause status: cannot get pause status for peer %s", peer)
+		jsonhttp.InternalServerError(w, errCantPausedPeers)
+		return
+	}
+
+	jsonhttp.OK(w, swapPauseResponse{Peer: peer.String(), Paused: o.SwapDisabled})
+}
+
+func (s *Service) swapPauseHandler(w http.ResponseWriter, r *http.Request) {
+	peer, ok := s.swapPausePeer(w, r, "pause settlements")
+	if !ok {
+		return
+	}
+
+	if err := s.setSwapDisabled(peer, true); err != nil {
+		s.logger.Debugf("debug api: pause settlements: peer %s: %v", peer, err)
+		s.logger.Errorf("debug api: pause settlements: cannot pause settlements for peer %s", peer)
+		jsonhttp.InternalServerError(w, errCantPause)
+		return
+	}
+
+	jsonhttp.OK(w, swapPauseResponse{Peer: peer.String(), Paused: true})
+}
+
+func (s *Service) swapResumeHandler(w http.ResponseWriter, r *http.Request) {
+	peer, ok := s.swapPausePeer(w, r, "resume settlements")
+	if !ok {
+		return
+	}
+
+	if err := s.setSwapDisabled(peer, false); err != nil {
+		s.logger.Debugf("debug api: resume settlements: peer %s: %v", peer, err)
+		s.logger.Errorf("debug api: resume settlements: cannot resume settlements for peer %s", peer)
+		jsonhttp.InternalServerError(w, errCantResume)
+		return
+	}
+
+	jsonhttp.OK(w, swapPauseResponse{Peer: peer.String(), Paused: false})
+}
+
+// setSwapDisabled pauses or resumes the settlements with the peer by setting
+// the swap disabled switch of its accounting override, keeping the thresholds
+// of the override. The override is removed when it is left without any effect.
+func (s *Service) setSwapDisabled(peer infinity.Address, disabled bool) error {
+	o, err := s.accounting.PeerOverride(peer)
+	if err != nil {
+		if !errors.Is(err, accounting.ErrPeerNoOverride) {
+			return err
+		}
+		if !disabled {
+			return nil
+		}
+	}
+	o.SwapDisabled = disabled
+	if o == (accounting.PeerOverride{}) {
+		return s.accounting.RemovePeerOverride(peer)
+	}
+	return s.accounting.SetPeerOverride(peer, o)
+}
+
+// swapPausePeer parses the peer of the request, responding with an error if
+// the address is invalid.
+func (s *Service) swapPausePeer(w http.ResponseWriter, r *http.Request, op string) (infinity.Address, bool) {
+	addr := mux.Vars(r)["peer"]
+	peer, err := infinity.ParseHexAddress(addr)
+	if err != nil {
+		s.logger.Debugf("debug api: %s: invalid peer address %s: %v", op, addr, err)
+		s.logger.Errorf("debug api: %s: invalid peer address %s", op, addr)
+		jsonhttp.NotFound(w, errInvalidAddress)
+		return infinity.ZeroAddress, false
+	}
+	return peer, true
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+)
+
+func TestSwapPause(t *testing.T) {
+	addr := infinity.MustParseHexAddress("1000000000000000000000000000000000000000000000000000000000000000")
+
+	testServer := newTestServer(t, testServerOptions{})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/chequebook/paused/"+addr.String(), http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.SwapPauseResponse{Peer: addr.String(), Paused: true}),
+	)
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/chequebook/paused/"+addr.String(), http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.SwapPauseResponse{Peer: addr.String(), Paused: true}),
+	)
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/chequebook/paused", http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.SwapPausedPeersResponse{Peers: []string{addr.String()}}),
+	)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodDelete, "/chequebook/paused/"+addr.String(), http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.SwapPauseResponse{Peer: addr.String(), Paused: false}),
+	)
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/chequebook/paused/"+addr.String(), http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.SwapPauseResponse{Peer: addr.String(), Paused: false}),
+	)
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/chequebook/paused", http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.SwapPausedPeersResponse{Peers: []string{}}),
+	)
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/accounting/overrides/"+addr.String(), http.StatusNotFound)
+
+	t.Run("override thresholds kept", func(t *testing.T) {
+		threshold := big.NewInt(100)
+		jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/accounting/overrides/"+addr.String(), http.StatusOK,
+			jsonhttptest.WithJSONRequestBody(debugapi.OverrideRequest{PaymentThreshold: threshold}),
+		)
+		jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/chequebook/paused/"+addr.String(), http.StatusOK)
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/accounting/overrides/"+addr.String(), http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.OverrideResponse{
+				Peer:             addr.String(),
+				SwapDisabled:     true,
+				PaymentThreshold: threshold,
+			}),
+		)
+		jsonhttptest.Request(t, testServer.Client, http.MethodDelete, "/chequebook/paused/"+addr.String(), http.StatusOK)
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/accounting/overrides/"+addr.String(), http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.OverrideResponse{
+				Peer:             addr.String(),
+				PaymentThreshold: threshold,
+			}),
+		)
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodPut, "/chequebook/paused/abcx", http.StatusNotFound,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: debugapi.ErrInvalidAddress,
+				Code:    http.StatusNotFound,
+			}),
+		)
+	})
+}
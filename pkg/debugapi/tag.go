@@ -26,9 +26,14 @@ type tagResponse struct {
 	Uid       uint32           `json:"uid"`
 	Address   infinity.Address `json:"address"`
 	StartedAt time.Time        `json:"startedAt"`
+	ETA       *time.Time       `json:"eta,omitempty"` // estimated time of the sync completion
 }
 
 func newTagResponse(tag *tags.Tag) tagResponse {
+	var eta *time.Time
+	if t, err := tag.SyncETA(); err == nil {
+		eta = &t
+	}
 	return tagResponse{
 		Total:     tag.Total,
 		Split:     tag.Split,
@@ -39,6 +44,7 @@ func newTagResponse(tag *tags.Tag) tagResponse {
 		Uid:       tag.Uid,
 		Address:   tag.Address,
 		StartedAt: tag.StartedAt,
+		ETA:       eta,
 	}
 }
 
@@ -5,6 +5,7 @@
 package debugapi_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/ioutil"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/yanhuangpai/voyager/pkg/debugapi"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
 	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
@@ -24,6 +26,11 @@ import (
 
 func tagsWithIdResource(id uint32) string { return fmt.Sprintf("/tags/%d", id) }
 
+// tagRequest is the request body of the tag creation and the done split.
+type tagRequest struct {
+	Address infinity.Address `json:"address,omitempty"`
+}
+
 func TestTags(t *testing.T) {
 	var (
 		logger         = logging.New(ioutil.Discard, 0)
@@ -63,6 +70,104 @@ func TestTags(t *testing.T) {
 	})
 }
 
+func TestTagsCreateDoneSplit(t *testing.T) {
+	var (
+		logger     = logging.New(ioutil.Discard, 0)
+		address    = infinity.MustParseHexAddress("aabbcc")
+		tagsStore  = tags.NewTags(statestore.NewStateStore(), logger)
+		testServer = newTestServer(t, testServerOptions{
+			Storer: mock.NewStorer(),
+			Tags:   tagsStore,
+		})
+	)
+
+	t.Run("create", func(t *testing.T) {
+		var tr debugapi.TagResponse
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/tags", http.StatusCreated,
+			jsonhttptest.WithUnmarshalJSONResponse(&tr),
+		)
+		if tr.Uid == 0 {
+			t.Fatal("got zero tag uid")
+		}
+		if _, err := tagsStore.Get(tr.Uid); err != nil {
+			t.Fatal(err)
+		}
+		tagValueTest(t, tr.Uid, 0, 0, 0, 0, 0, 0, infinity.ZeroAddress, testServer.Client)
+	})
+
+	t.Run("create with address", func(t *testing.T) {
+		var tr debugapi.TagResponse
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/tags", http.StatusCreated,
+			jsonhttptest.WithJSONRequestBody(tagRequest{Address: address}),
+			jsonhttptest.WithUnmarshalJSONResponse(&tr),
+		)
+		if !tr.Address.Equal(address) {
+			t.Fatalf("got address %s, want %s", tr.Address, address)
+		}
+		tag, err := tagsStore.GetByAddress(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Uid != tr.Uid {
+			t.Fatalf("got tag %d by address, want %d", tag.Uid, tr.Uid)
+		}
+	})
+
+	t.Run("create invalid request", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/tags", http.StatusBadRequest,
+			jsonhttptest.WithRequestBody(bytes.NewReader([]byte("{"))),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "invalid request",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+
+	t.Run("done split", func(t *testing.T) {
+		var tr debugapi.TagResponse
+		jsonhttptest.Request(t, testServer.Client, http.MethodPost, "/tags", http.StatusCreated,
+			jsonhttptest.WithUnmarshalJSONResponse(&tr),
+		)
+		tag, err := tagsStore.Get(tr.Uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the chunks uploaded one by one with the tag
+		for i := 0; i < 3; i++ {
+			_ = tag.Inc(tags.StateSplit)
+			_ = tag.Inc(tags.StateStored)
+		}
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodPatch, tagsWithIdResource(tr.Uid), http.StatusOK,
+			jsonhttptest.WithJSONRequestBody(tagRequest{Address: address}),
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "ok",
+				Code:    http.StatusOK,
+			}),
+		)
+		tagValueTest(t, tr.Uid, 3, 3, 0, 0, 0, 3, address, testServer.Client)
+	})
+
+	t.Run("done split not found", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodPatch, tagsWithIdResource(1), http.StatusNotFound,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "tag not present",
+				Code:    http.StatusNotFound,
+			}),
+		)
+	})
+
+	t.Run("done split invalid id", func(t *testing.T) {
+		jsonhttptest.Request(t, testServer.Client, http.MethodPatch, "/tags/invalid", http.StatusBadRequest,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: "invalid id",
+				Code:    http.StatusBadRequest,
+			}),
+		)
+	})
+}
+
 func tagValueTest(t *testing.T, id uint32, split, stored, seen, sent, synced, total int64, address infinity.Address, client *http.Client) {
 	t.Helper()
 
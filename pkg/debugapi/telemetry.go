@@ -0,0 +1,38 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"net/http"
+
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/telemetry"
+)
+
+var errCantTelemetry = "Cannot get telemetry report"
+
+type telemetryResponse struct {
+	Enabled  bool             `json:"enabled"`
+	Endpoint string           `json:"endpoint"`
+	Report   telemetry.Report `json:"report"`
+}
+
+// telemetryHandler shows the telemetry report exactly as it is or would be
+// sent, regardless whether reporting is enabled.
+func (s *Service) telemetryHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := s.telemetry.Report()
+	if err != nil {
+		jsonhttp.InternalServerError(w, errCantTelemetry)
+		s.logger.Debugf("debug api: telemetry: %v", err)
+		s.logger.Error("debug api: can not get telemetry report")
+		return
+	}
+
+	jsonhttp.OK(w, telemetryResponse{
+		Enabled:  s.telemetry.Enabled(),
+		Endpoint: s.telemetry.Endpoint(),
+		Report:   report,
+	})
+}
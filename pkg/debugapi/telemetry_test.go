@@ -0,0 +1,86 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager"
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/p2p/mock"
+	"github.com/yanhuangpai/voyager/pkg/telemetry"
+)
+
+func TestTelemetry(t *testing.T) {
+	peers := mock.New(mock.WithPeersFunc(func() []p2p.Peer {
+		return make([]p2p.Peer, 12)
+	}))
+
+	t.Run("disabled", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{
+			Telemetry: telemetry.New(peers, func() (int64, error) {
+				return 2 << 30, nil
+			}, logging.New(ioutil.Discard, 0), telemetry.Options{}),
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/telemetry", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.TelemetryResponse{
+				Enabled: false,
+				Report: telemetry.Report{
+					Schema:  telemetry.SchemaVersion,
+					Version: voyager.Version,
+					Peers:   "11-50",
+					Storage: "1-10GB",
+				},
+			}),
+		)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{
+			Telemetry: telemetry.New(peers, func() (int64, error) {
+				return 0, nil
+			}, logging.New(ioutil.Discard, 0), telemetry.Options{
+				Enabled:  true,
+				Endpoint: "https://telemetry.example.com",
+			}),
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/telemetry", http.StatusOK,
+			jsonhttptest.WithExpectedJSONResponse(debugapi.TelemetryResponse{
+				Enabled:  true,
+				Endpoint: "https://telemetry.example.com",
+				Report: telemetry.Report{
+					Schema:  telemetry.SchemaVersion,
+					Version: voyager.Version,
+					Peers:   "11-50",
+					Storage: "0-1GB",
+				},
+			}),
+		)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		testServer := newTestServer(t, testServerOptions{
+			Telemetry: telemetry.New(peers, func() (int64, error) {
+				return 0, errors.New("storage size error")
+			}, logging.New(ioutil.Discard, 0), telemetry.Options{}),
+		})
+
+		jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/telemetry", http.StatusInternalServerError,
+			jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+				Message: debugapi.ErrCantTelemetry,
+				Code:    http.StatusInternalServerError,
+			}),
+		)
+	})
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+var (
+	errCantTraffic = "Cannot get traffic"
+	errNoTraffic   = "No traffic for peer"
+)
+
+type trafficResponse struct {
+	Peer            string `json:"peer"`
+	ChunksServed    uint64 `json:"chunksServed"`
+	BytesServed     uint64 `json:"bytesServed"`
+	ChunksRequested uint64 `json:"chunksRequested"`
+	BytesRequested  uint64 `json:"bytesRequested"`
+}
+
+type trafficsResponse struct {
+	Traffic []trafficResponse `json:"traffic"`
+}
+
+func newTrafficResponse(peer string, t accounting.TrafficStats) trafficResponse {
+	return trafficResponse{
+		Peer:            peer,
+		ChunksServed:    t.ChunksServed,
+		BytesServed:     t.BytesServed,
+		ChunksRequested: t.ChunksRequested,
+		BytesRequested:  t.BytesRequested,
+	}
+}
+
+func (s *Service) trafficHandler(w http.ResponseWriter, r *http.Request) {
+	traffic, err := s.accounting.Traffic()
+	if err != nil {
+		jsonhttp.InternalServerError(w, errCantTraffic)
+		s.logger.Debugf("debug api: traffic: %v", err)
+		s.logger.Error("debug api: can not get traffic")
+		return
+	}
+
+	responses := make([]trafficResponse, 0, len(traffic))
+	for peer, t := range traffic {
+		responses = append(responses, newTrafficResponse(peer, t))
+	}
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Peer < responses[j].Peer
+	})
+
+	jsonhttp.OK(w, trafficsResponse{Traffic: responses})
+}
+
+func (s *Service) peerTrafficHandler(w http.ResponseWriter, r *http.Request) {
+	addr := mux.Vars(r)["peer"]
+	peer, err := infinity.ParseHexAddress(addr)
+	if err != nil {
+		s.logger.Debugf("debug api: traffic peer: invalid peer address %s: %v", addr, err)
+		s.logger.Errorf("debug api: traffic peer: invalid peer address %s", addr)
+		jsonhttp.NotFound(w, errInvalidAddress)
+		return
+	}
+
+	traffic, err := s.accounting.PeerTraffic(peer)
+	if err != nil {
+		if errors.Is(err, accounting.ErrPeerNoTraffic) {
+			jsonhttp.NotFound(w, errNoTraffic)
+			return
+		}
+		s.logger.Debugf("debug api: traffic peer: get peer %s traffic: %v", peer.String(), err)
+		s.logger.Errorf("debug api: traffic peer: can't get peer %s traffic", peer.String())
+		jsonhttp.InternalServerError(w, errCantTraffic)
+		return
+	}
+
+	jsonhttp.OK(w, newTrafficResponse(peer.String(), traffic))
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debugapi_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/accounting/mock"
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp/jsonhttptest"
+)
+
+func TestTraffic(t *testing.T) {
+	trafficFunc := func() (map[string]accounting.TrafficStats, error) {
+		return map[string]accounting.TrafficStats{
+			"DEAD": {
+				ChunksServed:    10,
+				BytesServed:     40960,
+				ChunksRequested: 1,
+				BytesRequested:  4096,
+			},
+			"BEEF": {
+				ChunksRequested: 2,
+				BytesRequested:  8192,
+			},
+		}, nil
+	}
+	testServer := newTestServer(t, testServerOptions{
+		AccountingOpts: []mock.Option{mock.WithTrafficFunc(trafficFunc)},
+	})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/traffic", http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.TrafficsResponse{
+			Traffic: []debugapi.TrafficResponse{
+				{
+					Peer:            "BEEF",
+					ChunksRequested: 2,
+					BytesRequested:  8192,
+				},
+				{
+					Peer:            "DEAD",
+					ChunksServed:    10,
+					BytesServed:     40960,
+					ChunksRequested: 1,
+					BytesRequested:  4096,
+				},
+			},
+		}),
+	)
+}
+
+func TestTrafficError(t *testing.T) {
+	trafficFunc := func() (map[string]accounting.TrafficStats, error) {
+		return nil, errors.New("traffic error")
+	}
+	testServer := newTestServer(t, testServerOptions{
+		AccountingOpts: []mock.Option{mock.WithTrafficFunc(trafficFunc)},
+	})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/traffic", http.StatusInternalServerError,
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Message: debugapi.ErrCantTraffic,
+			Code:    http.StatusInternalServerError,
+		}),
+	)
+}
+
+func TestPeerTraffic(t *testing.T) {
+	peer := "bff2c89e85e78c38bd89fca1acc996afb876c21bf5a8482ad798ce15f1c223fa"
+	testServer := newTestServer(t, testServerOptions{
+		AccountingOpts: []mock.Option{mock.WithPeerTrafficFunc(func(a infinity.Address) (accounting.TrafficStats, error) {
+			if a.String() != peer {
+				return accounting.TrafficStats{}, accounting.ErrPeerNoTraffic
+			}
+			return accounting.TrafficStats{
+				ChunksServed: 3,
+				BytesServed:  12288,
+			}, nil
+		})},
+	})
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/traffic/"+peer, http.StatusOK,
+		jsonhttptest.WithExpectedJSONResponse(debugapi.TrafficResponse{
+			Peer:         peer,
+			ChunksServed: 3,
+			BytesServed:  12288,
+		}),
+	)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/traffic/ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c", http.StatusNotFound,
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Message: debugapi.ErrNoTraffic,
+			Code:    http.StatusNotFound,
+		}),
+	)
+
+	jsonhttptest.Request(t, testServer.Client, http.MethodGet, "/traffic/invalid-address", http.StatusNotFound,
+		jsonhttptest.WithExpectedJSONResponse(jsonhttp.StatusResponse{
+			Message: debugapi.ErrInvalidAddress,
+			Code:    http.StatusNotFound,
+		}),
+	)
+}
@@ -0,0 +1,187 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package events provides a lightweight publish-subscribe bus for the internal
+// node events, so that the debug api, the metrics and the plugins can consume
+// a single stream of events from the node components.
+package events
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	m "github.com/yanhuangpai/voyager/pkg/metrics"
+)
+
+// Topic is the kind of the event.
+type Topic string
+
+const (
+	// TopicPeerAdded is published by kademlia when a peer is connected, the
+	// data is PeerData.
+	TopicPeerAdded Topic = "peer-added"
+	// TopicPeerRemoved is published by kademlia when a peer is disconnected,
+	// the data is PeerData.
+	TopicPeerRemoved Topic = "peer-removed"
+	// TopicDepthChanged is published by kademlia when the neighborhood depth
+	// changes, the data is DepthData.
+	TopicDepthChanged Topic = "depth-changed"
+	// TopicReceiptReceived is published by pushsync when a valid receipt for
+	// a pushed chunk is received, the data is ReceiptData.
+	TopicReceiptReceived Topic = "receipt-received"
+	// TopicIntervalComplete is published by pullsync when an interval is
+	// synced from a peer, the data is IntervalData.
+	TopicIntervalComplete Topic = "interval-complete"
+	// TopicChequeReceived is published by the settlement when a cheque is
+	// received from a peer, the data is ChequeData.
+	TopicChequeReceived Topic = "cheque-received"
+	// TopicLatencyMeasured is published by the latency prober when the
+	// round-trip-time to a peer is measured, the data is LatencyData.
+	TopicLatencyMeasured Topic = "latency-measured"
+)
+
+// PeerData is the data of the peer added and removed events.
+type PeerData struct {
+	Peer infinity.Address
+	Bin  uint8
+}
+
+// DepthData is the data of the depth changed event.
+type DepthData struct {
+	Depth uint8
+}
+
+// ReceiptData is the data of the receipt received event.
+type ReceiptData struct {
+	Chunk infinity.Address
+	Peer  infinity.Address
+}
+
+// IntervalData is the data of the interval complete event.
+type IntervalData struct {
+	Peer infinity.Address
+	Bin  uint8
+	From uint64
+	To   uint64
+}
+
+// ChequeData is the data of the cheque received event.
+type ChequeData struct {
+	Peer   infinity.Address
+	Amount *big.Int
+}
+
+// LatencyData is the data of the latency measured event.
+type LatencyData struct {
+	Peer    infinity.Address
+	RTT     time.Duration
+	Average time.Duration
+}
+
+// Event is a single event published on the bus.
+type Event struct {
+	Topic Topic
+	Time  time.Time
+	Data  interface{}
+}
+
+type subscriber struct {
+	c      chan Event
+	topics map[Topic]struct{} // all topics if empty
+}
+
+// Bus delivers the published events to the subscribers. A nil Bus is valid
+// and it discards all events, so that the components can publish and
+// subscribe without checking if the bus is configured.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*subscriber]struct{}
+	metrics     metrics
+}
+
+// New returns a new event bus.
+func New() *Bus {
+	return &Bus{
+		subscribers: make(map[*subscriber]struct{}),
+		metrics:     newMetrics(),
+	}
+}
+
+// Publish delivers the event with the topic and the data to the subscribers
+// of the topic. It never blocks, the event is dropped for the subscribers
+// which do not keep up with the events.
+func (b *Bus) Publish(topic Topic, data interface{}) {
+	if b == nil {
+		return
+	}
+
+	e := Event{
+		Topic: topic,
+		Time:  time.Now(),
+		Data:  data,
+	}
+	b.metrics.PublishedEvents.WithLabelValues(string(topic)).Inc()
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for s := range b.subscribers {
+		if len(s.topics) > 0 {
+			if _, ok := s.topics[topic]; !ok {
+				continue
+			}
+		}
+		select {
+		case s.c <- e:
+		default:
+			b.metrics.DroppedEvents.Inc()
+		}
+	}
+}
+
+// Subscribe returns the channel with the events of the topics, or of all
+// topics if none is given, buffered with the size. Returned function
+// unsubscribes and closes the channel, it is safe to be called multiple
+// times. The channel of a nil Bus is closed.
+func (b *Bus) Subscribe(size int, topics ...Topic) (c <-chan Event, unsubscribe func()) {
+	if b == nil {
+		closed := make(chan Event)
+		close(closed)
+		return closed, func() {}
+	}
+
+	s := &subscriber{
+		c:      make(chan Event, size),
+		topics: make(map[Topic]struct{}, len(topics)),
+	}
+	for _, t := range topics {
+		s.topics[t] = struct{}{}
+	}
+
+	b.mu.Lock()
+	b.subscribers[s] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe = func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.subscribers, s)
+			close(s.c)
+		})
+	}
+	return s.c, unsubscribe
+}
+
+func (b *Bus) Metrics() []prometheus.Collector {
+	if b == nil {
+		return nil
+	}
+	return m.PrometheusCollectorsFromFields(b.metrics)
+}
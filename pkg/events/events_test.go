@@ -0,0 +1,101 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/events"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+func TestBus(t *testing.T) {
+	b := events.New()
+
+	all, unsubscribeAll := b.Subscribe(10)
+	defer unsubscribeAll()
+	depth, unsubscribeDepth := b.Subscribe(10, events.TopicDepthChanged)
+	defer unsubscribeDepth()
+
+	peer := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+	b.Publish(events.TopicPeerAdded, events.PeerData{Peer: peer, Bin: 3})
+	b.Publish(events.TopicDepthChanged, events.DepthData{Depth: 2})
+
+	for _, want := range []events.Topic{events.TopicPeerAdded, events.TopicDepthChanged} {
+		e := receiveEvent(t, all)
+		if e.Topic != want {
+			t.Fatalf("got topic %s, want %s", e.Topic, want)
+		}
+	}
+
+	e := receiveEvent(t, depth)
+	if d, ok := e.Data.(events.DepthData); !ok || d.Depth != 2 {
+		t.Fatalf("got event data %v, want depth 2", e.Data)
+	}
+	select {
+	case e := <-depth:
+		t.Fatalf("got unexpected event %v", e)
+	default:
+	}
+}
+
+func TestBusUnsubscribe(t *testing.T) {
+	b := events.New()
+
+	c, unsubscribe := b.Subscribe(1)
+	unsubscribe()
+	unsubscribe()
+
+	b.Publish(events.TopicDepthChanged, events.DepthData{Depth: 1})
+
+	if _, ok := <-c; ok {
+		t.Fatal("channel not closed")
+	}
+}
+
+func TestBusSlowSubscriber(t *testing.T) {
+	b := events.New()
+
+	c, unsubscribe := b.Subscribe(1)
+	defer unsubscribe()
+
+	// the publishing does not block on the full channel
+	b.Publish(events.TopicDepthChanged, events.DepthData{Depth: 1})
+	b.Publish(events.TopicDepthChanged, events.DepthData{Depth: 2})
+
+	e := receiveEvent(t, c)
+	if d := e.Data.(events.DepthData); d.Depth != 1 {
+		t.Fatalf("got depth %d, want 1", d.Depth)
+	}
+}
+
+func TestBusNil(t *testing.T) {
+	var b *events.Bus
+	b.Publish(events.TopicDepthChanged, events.DepthData{Depth: 1})
+
+	c, unsubscribe := b.Subscribe(1)
+	unsubscribe()
+	unsubscribe()
+
+	if _, ok := <-c; ok {
+		t.Fatal("channel not closed")
+	}
+	if m := b.Metrics(); m != nil {
+		t.Fatalf("got metrics %v, want none", m)
+	}
+}
+
+func receiveEvent(t *testing.T, c <-chan events.Event) events.Event {
+	t.Helper()
+
+	select {
+	case e := <-c:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+	}
+	return events.Event{}
+}
@@ -0,0 +1,34 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package events
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	m "github.com/yanhuangpai/voyager/pkg/metrics"
+)
+
+type metrics struct {
+	PublishedEvents *prometheus.CounterVec
+	DroppedEvents   prometheus.Counter
+}
+
+func newMetrics() metrics {
+	subsystem := "events"
+
+	return metrics{
+		PublishedEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "published_count",
+			Help:      "Number of published events by topic.",
+		}, []string{"topic"}),
+		DroppedEvents: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "dropped_count",
+			Help:      "Number of events dropped for the slow subscribers.",
+		}),
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package features provides the flags of the experimental features which can
+// be enabled selectively on a node. The flags are set in the configuration
+// and can be changed at runtime, in which case the change is persisted in the
+// state store and takes precedence over the configuration on the next start.
+package features
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+// Flag is the name of an experimental feature.
+type Flag string
+
+const (
+	// ErasureCoding allows the uploads with a redundancy level.
+	ErasureCoding Flag = "erasure-coding"
+	// RetrievalRacing requests a chunk from two peers at once.
+	RetrievalRacing Flag = "retrieval-racing"
+	// RetrievalHedging requests a chunk from the next peer when the
+	// requested one is slower than the most of the recent retrievals.
+	RetrievalHedging Flag = "retrieval-hedging"
+)
+
+// definitions are the known flags with their descriptions and defaults.
+var definitions = map[Flag]struct {
+	description string
+	enabled     bool
+}{
+	ErasureCoding:    {description: "erasure coding of the uploads with a redundancy level", enabled: true},
+	RetrievalRacing:  {description: "request a chunk from two peers at once and use the first response", enabled: false},
+	RetrievalHedging: {description: "request a chunk from the next peer when the requested one is slower than the 95th percentile of the recent retrievals", enabled: false},
+}
+
+const keyPrefix = "feature_"
+
+// ErrUnknownFlag is returned for a flag which is not known.
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// Checker reports whether the features are enabled.
+type Checker interface {
+	Enabled(f Flag) bool
+}
+
+// Status is the state of a feature flag.
+type Status struct {
+	Flag        Flag
+	Enabled     bool
+	Description string
+}
+
+var _ Checker = (*Service)(nil)
+
+// Service holds the state of the feature flags.
+type Service struct {
+	store   storage.StateStorer
+	logger  logging.Logger
+	mu      sync.RWMutex
+	enabled map[Flag]bool
+}
+
+// New returns the feature flags with the defaults changed by the config and
+// then by the persisted changes. Every config entry is the name of the flag
+// to enable, optionally followed by =true or =false.
+func New(store storage.StateStorer, config []string, logger logging.Logger) (*Service, error) {
+	s := &Service{
+		store:   store,
+		logger:  logger,
+		enabled: make(map[Flag]bool, len(definitions)),
+	}
+	for f, d := range definitions {
+		s.enabled[f] = d.enabled
+	}
+
+	for _, c := range config {
+		f, enabled, err := parseConfig(c)
+		if err != nil {
+			return nil, err
+		}
+		s.enabled[f] = enabled
+	}
+
+	for f := range definitions {
+		var enabled bool
+		if err := store.Get(key(f), &enabled); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("get flag %s: %w", f, err)
+		}
+		s.enabled[f] = enabled
+	}
+
+	enabled := []string{}
+	for _, f := range s.Flags() {
+		if f.Enabled {
+			enabled = append(enabled, string(f.Flag))
+		}
+	}
+	logger.Infof("features: enabled %v", enabled)
+
+	return s, nil
+}
+
+// Enabled returns true if the feature is enabled.
+func (s *Service) Enabled(f Flag) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.enabled[f]
+}
+
+// Set enables or disables the features and persists the changes. All flags
+// are validated before any of them is changed.
+func (s *Service) Set(flags map[Flag]bool) error {
+	for f := range flags {
+		if _, ok := definitions[f]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownFlag, f)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for f, enabled := range flags {
+		if err := s.store.Put(key(f), enabled); err != nil {
+			return fmt.Errorf("put flag %s: %w", f, err)
+		}
+		s.enabled[f] = enabled
+		s.logger.Infof("features: %s enabled %t", f, enabled)
+	}
+	return nil
+}
+
+// Flags returns the state of all flags sorted by their names.
+func (s *Service) Flags() []Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	flags := make([]Status, 0, len(definitions))
+	for f, d := range definitions {
+		flags = append(flags, Status{
+			Flag:        f,
+			Enabled:     s.enabled[f],
+			Description: d.description,
+		})
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Flag < flags[j].Flag
+	})
+	return flags
+}
+
+// parseConfig parses the config entry in the name or name=bool format.
+func parseConfig(c string) (Flag, bool, error) {
+	name, value := c, "true"
+	if i := strings.IndexByte(c, '='); i >= 0 {
+		name, value = c[:i], c[i+1:]
+	}
+	f := Flag(strings.TrimSpace(name))
+	if _, ok := definitions[f]; !ok {
+		return "", false, fmt.Errorf("%w: %s", ErrUnknownFlag, f)
+	}
+	enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return "", false, fmt.Errorf("flag %s: %w", f, err)
+	}
+	return f, enabled, nil
+}
+
+// key returns the storage key of the flag.
+func key(f Flag) string {
+	return keyPrefix + string(f)
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package features_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/features"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/statestore/mock"
+)
+
+func TestFeatures(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	store := mock.NewStateStore()
+
+	s, err := features.New(store, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Enabled(features.ErasureCoding) {
+		t.Error("erasure coding disabled by default")
+	}
+	if s.Enabled(features.RetrievalRacing) {
+		t.Error("retrieval racing enabled by default")
+	}
+
+	s, err = features.New(store, []string{"retrieval-racing", "erasure-coding=false"}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Enabled(features.ErasureCoding) {
+		t.Error("erasure coding enabled, disabled in config")
+	}
+	if !s.Enabled(features.RetrievalRacing) {
+		t.Error("retrieval racing disabled, enabled in config")
+	}
+
+	if err := s.Set(map[features.Flag]bool{features.RetrievalRacing: false, "unknown": true}); !errors.Is(err, features.ErrUnknownFlag) {
+		t.Fatalf("got error %v, want %v", err, features.ErrUnknownFlag)
+	}
+	if !s.Enabled(features.RetrievalRacing) {
+		t.Error("flag changed on failed set")
+	}
+
+	if err := s.Set(map[features.Flag]bool{features.RetrievalRacing: false}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Enabled(features.RetrievalRacing) {
+		t.Error("retrieval racing enabled after set")
+	}
+
+	// the persisted change takes precedence over the config
+	s, err = features.New(store, []string{"retrieval-racing"}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Enabled(features.RetrievalRacing) {
+		t.Error("retrieval racing enabled, disabled persistently")
+	}
+
+	want := []features.Status{
+		{Flag: features.ErasureCoding, Enabled: true},
+		{Flag: features.RetrievalHedging, Enabled: false},
+		{Flag: features.RetrievalRacing, Enabled: false},
+	}
+	got := s.Flags()
+	if len(got) != len(want) {
+		t.Fatalf("got %d flags, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Flag != want[i].Flag || got[i].Enabled != want[i].Enabled {
+			t.Errorf("flag %d: got %s %t, want %s %t", i, got[i].Flag, got[i].Enabled, want[i].Flag, want[i].Enabled)
+		}
+	}
+
+	for _, c := range []string{"unknown", "erasure-coding=maybe"} {
+		if _, err := features.New(store, []string{c}, logger); err == nil {
+			t.Errorf("config %q: expected error", c)
+		}
+	}
+}
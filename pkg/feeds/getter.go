@@ -35,9 +35,20 @@ func NewGetter(getter storage.Getter, feed *Feed) *Getter {
 
 // Latest looks up the latest update of the feed
 // after is a unix time hint of the latest known update
+// if the latest update is a tombstone, it is returned with ErrTerminated
 func Latest(ctx context.Context, l Lookup, after int64) (infinity.Chunk, error) {
 	c, _, _, err := l.At(ctx, time.Now().Unix(), after)
-	return c, err
+	if err != nil || c == nil {
+		return c, err
+	}
+	terminated, err := Terminated(c)
+	if err != nil {
+		return nil, err
+	}
+	if terminated {
+		return c, ErrTerminated
+	}
+	return c, nil
 }
 
 // Get creates an update of the underlying feed at the given epoch
@@ -46,6 +46,11 @@ func (i *index) MarshalBinary() ([]byte, error) {
 	return indexBytes, nil
 }
 
+// NewIndex constructs the sequence index of the i-th update.
+func NewIndex(i uint64) feeds.Index {
+	return &index{i}
+}
+
 // Next requires
 func (i *index) Next(last int64, at uint64) feeds.Index {
 	return &index{i.index + 1}
@@ -93,6 +93,22 @@ func TestFinderBasic(t *testing.T, finderf func(storage.Getter, *feeds.Feed) fee
 			t.Fatalf("timestamp mismatch: expected %v, got %v", at, ts)
 		}
 	})
+	t.Run("terminated", func(t *testing.T) {
+		// the tombstone is stored after the first update also for the time
+		// based feeds
+		time.Sleep(time.Second)
+		err = feeds.Terminate(ctx, updater, time.Now().Unix())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ch, err := feeds.Latest(ctx, finder, 0)
+		if !errors.Is(err, feeds.ErrTerminated) {
+			t.Fatalf("got error %v, want %v", err, feeds.ErrTerminated)
+		}
+		if terminated, err := feeds.Terminated(ch); err != nil || !terminated {
+			t.Fatalf("expected tombstone update, got terminated %v, error %v", terminated, err)
+		}
+	})
 }
 
 func TestFinderFixIntervals(t *testing.T, nextf func() (bool, int64), finderf func(storage.Getter, *feeds.Feed) feeds.Lookup, updaterf func(putter storage.Putter, signer crypto.Signer, topic []byte) (feeds.Updater, error)) {
@@ -0,0 +1,41 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package feeds
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+// ErrTerminated is returned by Latest when the latest update of the feed is a
+// tombstone.
+var ErrTerminated = errors.New("feed terminated")
+
+// tombstone is the payload of the update which marks the feed as terminated.
+// It can not be mistaken for a reference, which is 32 or 64 bytes long.
+var tombstone = []byte("infinity-feed-tombstone")
+
+// Tombstone returns the payload of the update which marks the feed as
+// terminated, no further updates are expected after it.
+func Tombstone() []byte {
+	return append([]byte(nil), tombstone...)
+}
+
+// Terminated reports if the feed update is a tombstone.
+func Terminated(ch infinity.Chunk) (bool, error) {
+	_, payload, err := FromChunk(ch)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(payload, tombstone), nil
+}
+
+// Terminate marks the feed as terminated with a tombstone update.
+func Terminate(ctx context.Context, u Updater, at int64) error {
+	return u.Update(ctx, at, Tombstone())
+}
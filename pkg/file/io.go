@@ -122,3 +122,14 @@ type LoadSaver interface {
 	Loader
 	Saver
 }
+
+// DeferredLoadSaver is a LoadSaver which keeps the data of the last Save call
+// in memory instead of storing it. The deferred data is stored when the next
+// Save is called or on Flush, and it can be dropped with Discard.
+type DeferredLoadSaver interface {
+	LoadSaver
+	// Flush stores the data of the last Save call, if any.
+	Flush(context.Context) error
+	// Discard drops the data of the last Save call without storing it.
+	Discard()
+}
@@ -6,54 +6,82 @@
 package joiner
 
 import (
+	"bytes"
 	"context"
-	"encoding/binary"
 	"errors"
 	"io"
 	"sync"
 	"sync/atomic"
 
+	"github.com/yanhuangpai/voyager/pkg/cac"
 	"github.com/yanhuangpai/voyager/pkg/encryption/store"
 	"github.com/yanhuangpai/voyager/pkg/file"
+	"github.com/yanhuangpai/voyager/pkg/file/redundancy"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/storage"
 	"golang.org/x/sync/errgroup"
 )
 
+var errRecoveredChunkInvalid = errors.New("joiner: recovered chunk invalid")
+
 type joiner struct {
 	addr      infinity.Address
 	rootData  []byte
 	span      int64
 	off       int64
 	refLength int
+	parities  int   // number of parity references in the intermediate chunks
+	branching int64 // number of data references in the intermediate chunks
 
 	ctx    context.Context
 	getter storage.Getter
+
+	recoverMu sync.Mutex
+	recovered map[string]infinity.Chunk // chunks reconstructed from parities by their references
 }
 
 // New creates a new Joiner. A Joiner provides Read, Seek and Size functionalities.
 func New(ctx context.Context, getter storage.Getter, address infinity.Address) (file.Joiner, int64, error) {
-	getter = store.New(getter)
+	j, err := newJoiner(ctx, store.New(getter), address)
+	if err != nil {
+		return nil, 0, err
+	}
+	return j, j.span, nil
+}
+
+// NewFromState creates a new Joiner for the root of the captured state. The
+// intermediate chunks captured in the state are not retrieved again.
+func NewFromState(ctx context.Context, getter storage.Getter, s *State) (file.Joiner, int64, error) {
+	j, err := newJoiner(ctx, &stateGetter{Getter: store.New(getter), chunks: s.chunks}, s.Root)
+	if err != nil {
+		return nil, 0, err
+	}
+	return j, j.span, nil
+}
+
+func newJoiner(ctx context.Context, getter storage.Getter, address infinity.Address) (*joiner, error) {
 	// retrieve the root chunk to read the total data length the be retrieved
 	rootChunk, err := getter.Get(ctx, storage.ModeGetRequest, address)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
 	var chunkData = rootChunk.Data()
 
-	span := int64(binary.LittleEndian.Uint64(chunkData[:infinity.SpanSize]))
+	span, parities := chunkToSpan(chunkData)
+	refLength := len(address.Bytes())
 
-	j := &joiner{
+	return &joiner{
 		addr:      rootChunk.Address(),
-		refLength: len(address.Bytes()),
+		refLength: refLength,
+		parities:  parities,
+		branching: int64(infinity.ChunkSize/refLength - parities),
 		ctx:       ctx,
 		getter:    getter,
-		span:      span,
+		span:      int64(span),
 		rootData:  chunkData[infinity.SpanSize:],
-	}
-
-	return j, span, nil
+		recovered: make(map[string]infinity.Chunk),
+	}, nil
 }
 
 // Read is called by the consumer to retrieve the joined data.
@@ -80,7 +108,7 @@ func (j *joiner) ReadAt(b []byte, off int64) (read int, err error) {
 	}
 	var bytesRead int64
 	var eg errgroup.Group
-	j.readAtOffset(b, j.rootData, 0, j.span, off, 0, readLen, &bytesRead, &eg)
+	j.readAtOffset(b, j.rootData, j.parities, 0, j.span, off, 0, readLen, &bytesRead, &eg)
 
 	err = eg.Wait()
 	if err != nil {
@@ -90,7 +118,7 @@ func (j *joiner) ReadAt(b []byte, off int64) (read int, err error) {
 	return int(atomic.LoadInt64(&bytesRead)), nil
 }
 
-func (j *joiner) readAtOffset(b, data []byte, cur, subTrieSize, off, bufferOffset, bytesToRead int64, bytesRead *int64, eg *errgroup.Group) {
+func (j *joiner) readAtOffset(b, data []byte, parities int, cur, subTrieSize, off, bufferOffset, bytesToRead int64, bytesRead *int64, eg *errgroup.Group) {
 	// we are at a leaf data chunk
 	if subTrieSize <= int64(len(data)) {
 		dataOffsetStart := off - cur
@@ -106,20 +134,21 @@ func (j *joiner) readAtOffset(b, data []byte, cur, subTrieSize, off, bufferOffse
 		return
 	}
 
-	for cursor := 0; cursor < len(data); cursor += j.refLength {
+	refs := j.dataRefs(data, parities)
+	for cursor := 0; cursor < len(refs); cursor += j.refLength {
 		if bytesToRead == 0 {
 			break
 		}
 
 		// fast forward the cursor
-		sec := subtrieSection(data, cursor, j.refLength, subTrieSize)
+		sec := subtrieSection(refs, cursor, j.refLength, j.branching, subTrieSize)
 		if cur+sec < off {
 			cur += sec
 			continue
 		}
 
 		// if we are here it means that we are within the bounds of the data we need to read
-		address := infinity.NewAddress(data[cursor : cursor+j.refLength])
+		index := cursor / j.refLength
 		subtrieSpan := sec
 		currentReadSize := subtrieSpan - (off - cur) // the size of the subtrie, minus the offset from the start of the trie
 
@@ -131,19 +160,19 @@ func (j *joiner) readAtOffset(b, data []byte, cur, subTrieSize, off, bufferOffse
 			currentReadSize = subtrieSpan
 		}
 
-		func(address infinity.Address, b []byte, cur, subTrieSize, off, bufferOffset, bytesToRead int64) {
+		func(index int, b []byte, cur, subTrieSize, off, bufferOffset, bytesToRead int64) {
 			eg.Go(func() error {
-				ch, err := j.getter.Get(j.ctx, storage.ModeGetRequest, address)
+				ch, err := j.getChunk(j.ctx, data, parities, index)
 				if err != nil {
 					return err
 				}
 
 				chunkData := ch.Data()[8:]
-				subtrieSpan := int64(chunkToSpan(ch.Data()))
-				j.readAtOffset(b, chunkData, cur, subtrieSpan, off, bufferOffset, currentReadSize, bytesRead, eg)
+				subtrieSpan, chunkParities := chunkToSpan(ch.Data())
+				j.readAtOffset(b, chunkData, chunkParities, cur, int64(subtrieSpan), off, bufferOffset, currentReadSize, bytesRead, eg)
 				return nil
 			})
-		}(address, b, cur, subtrieSpan, off, bufferOffset, currentReadSize)
+		}(index, b, cur, subtrieSpan, off, bufferOffset, currentReadSize)
 
 		bufferOffset += currentReadSize
 		bytesToRead -= currentReadSize
@@ -153,7 +182,7 @@ func (j *joiner) readAtOffset(b, data []byte, cur, subTrieSize, off, bufferOffse
 }
 
 // brute-forces the subtrie size for each of the sections in this intermediate chunk
-func subtrieSection(data []byte, startIdx, refLen int, subtrieSize int64) int64 {
+func subtrieSection(data []byte, startIdx, refLen int, branching, subtrieSize int64) int64 {
 	// assume we have a trie of size `y` then we can assume that all of
 	// the forks except for the last one on the right are of equal size
 	// this is due to how the splitter wraps levels.
@@ -163,7 +192,6 @@ func subtrieSection(data []byte, startIdx, refLen int, subtrieSize int64) int64
 	// x is constant (the brute forced value) and l is the size of the last subtrie
 	var (
 		refs       = int64(len(data) / refLen) // how many references in the intermediate chunk
-		branching  = int64(4096 / refLen)      // branching factor is chunkSize divided by reference length
 		branchSize = int64(4096)
 	)
 	for {
@@ -218,10 +246,10 @@ func (j *joiner) IterateChunkAddresses(fn infinity.AddressIterFunc) error {
 		return err
 	}
 
-	return j.processChunkAddresses(j.ctx, fn, j.rootData, j.span)
+	return j.processChunkAddresses(j.ctx, fn, j.rootData, j.parities, j.span)
 }
 
-func (j *joiner) processChunkAddresses(ctx context.Context, fn infinity.AddressIterFunc, data []byte, subTrieSize int64) error {
+func (j *joiner) processChunkAddresses(ctx context.Context, fn infinity.AddressIterFunc, data []byte, parities int, subTrieSize int64) error {
 	// we are at a leaf data chunk
 	if subTrieSize <= int64(len(data)) {
 		return nil
@@ -237,47 +265,256 @@ func (j *joiner) processChunkAddresses(ctx context.Context, fn infinity.AddressI
 
 	var wg sync.WaitGroup
 
-	for cursor := 0; cursor < len(data); cursor += j.refLength {
+	refs := j.dataRefs(data, parities)
+	for cursor := 0; cursor < len(refs); cursor += j.refLength {
 
-		address := infinity.NewAddress(data[cursor : cursor+j.refLength])
+		address := infinity.NewAddress(refs[cursor : cursor+j.refLength])
 
 		if err := fn(address); err != nil {
 			return err
 		}
 
-		sec := subtrieSection(data, cursor, j.refLength, subTrieSize)
+		sec := subtrieSection(refs, cursor, j.refLength, j.branching, subTrieSize)
 		if sec <= 4096 {
 			continue
 		}
 
-		func(address infinity.Address, eg *errgroup.Group) {
+		func(index int, eg *errgroup.Group) {
 			wg.Add(1)
 
 			eg.Go(func() error {
 				defer wg.Done()
 
-				ch, err := j.getter.Get(ectx, storage.ModeGetRequest, address)
+				ch, err := j.getChunk(ectx, data, parities, index)
 				if err != nil {
 					return err
 				}
 
 				chunkData := ch.Data()[8:]
-				subtrieSpan := int64(chunkToSpan(ch.Data()))
+				subtrieSpan, chunkParities := chunkToSpan(ch.Data())
 
-				return j.processChunkAddresses(ectx, fn, chunkData, subtrieSpan)
+				return j.processChunkAddresses(ectx, fn, chunkData, chunkParities, int64(subtrieSpan))
 			})
-		}(address, eg)
+		}(cursor/j.refLength, eg)
 
 		wg.Wait()
 	}
 
+	// report the parity chunks of the intermediate chunk
+	for cursor := len(refs); cursor < len(data); cursor += j.refLength {
+		if err := fn(infinity.NewAddress(data[cursor : cursor+j.refLength])); err != nil {
+			return err
+		}
+	}
+
 	return eg.Wait()
 }
 
+// State captures the offset of the data in the file with the given root
+// reference together with the root and the intermediate chunks on the path to
+// the data chunk at the offset.
+type State struct {
+	Root   infinity.Address
+	Offset int64
+	chunks map[string]infinity.Chunk // intermediate chunks by their references
+}
+
+// CaptureState returns the state of reading the file with the given root
+// reference at the offset.
+func CaptureState(ctx context.Context, getter storage.Getter, address infinity.Address, off int64) (*State, error) {
+	j, err := newJoiner(ctx, store.New(getter), address)
+	if err != nil {
+		return nil, err
+	}
+	if off < 0 || off > j.span {
+		return nil, errOffset
+	}
+
+	chunks, err := j.intermediateChunks(address, off)
+	if err != nil {
+		return nil, err
+	}
+
+	return &State{
+		Root:   address,
+		Offset: off,
+		chunks: chunks,
+	}, nil
+}
+
+// Depth returns the number of the levels of the chunk tree of the file, from
+// the root chunk to the data chunks. The tree is filled from the left, so the
+// path captured at the offset zero is the longest one.
+func (s *State) Depth() int {
+	root := s.chunks[s.Root.ByteString()]
+	if span, _ := chunkToSpan(root.Data()); span <= uint64(len(root.Data())-infinity.SpanSize) {
+		// the root chunk is the data chunk
+		return 1
+	}
+	return len(s.chunks) + 1
+}
+
+// intermediateChunks returns the root chunk and the intermediate chunks on
+// the path from the root to the data chunk which contains the offset.
+func (j *joiner) intermediateChunks(address infinity.Address, off int64) (map[string]infinity.Chunk, error) {
+	root, err := j.getter.Get(j.ctx, storage.ModeGetRequest, address)
+	if err != nil {
+		return nil, err
+	}
+	chunks := map[string]infinity.Chunk{address.ByteString(): root}
+
+	data, parities, subTrieSize, cur := j.rootData, j.parities, j.span, int64(0)
+	for subTrieSize > int64(len(data)) {
+		var (
+			index int
+			sec   int64
+		)
+		refs := j.dataRefs(data, parities)
+		for cursor := 0; cursor < len(refs); cursor += j.refLength {
+			sec = subtrieSection(refs, cursor, j.refLength, j.branching, subTrieSize)
+			if cur+sec <= off && cursor+j.refLength < len(refs) {
+				cur += sec
+				continue
+			}
+			index = cursor / j.refLength
+			break
+		}
+		if sec <= infinity.ChunkSize {
+			// the next chunk on the path is the data chunk
+			break
+		}
+
+		ch, err := j.getChunk(j.ctx, data, parities, index)
+		if err != nil {
+			return nil, err
+		}
+		chunks[ch.Address().ByteString()] = ch
+
+		span, chunkParities := chunkToSpan(ch.Data())
+		data, parities, subTrieSize = ch.Data()[infinity.SpanSize:], chunkParities, int64(span)
+	}
+
+	return chunks, nil
+}
+
+// stateGetter returns the chunks captured in a state without retrieving them.
+type stateGetter struct {
+	storage.Getter
+	chunks map[string]infinity.Chunk
+}
+
+func (g *stateGetter) Get(ctx context.Context, mode storage.ModeGet, addr infinity.Address) (infinity.Chunk, error) {
+	if ch, ok := g.chunks[addr.ByteString()]; ok {
+		return ch, nil
+	}
+	return g.Getter.Get(ctx, mode, addr)
+}
+
 func (j *joiner) Size() int64 {
 	return j.span
 }
 
-func chunkToSpan(data []byte) uint64 {
-	return binary.LittleEndian.Uint64(data[:8])
+// dataRefs returns the data references of an intermediate chunk without the
+// trailing parity references.
+func (j *joiner) dataRefs(data []byte, parities int) []byte {
+	return data[:len(data)-parities*j.refLength]
+}
+
+// getChunk retrieves the chunk at the index of the references of an
+// intermediate chunk. If the chunk can not be retrieved and the intermediate
+// chunk has parity references, the chunk is reconstructed from its siblings.
+func (j *joiner) getChunk(ctx context.Context, data []byte, parities, index int) (infinity.Chunk, error) {
+	address := infinity.NewAddress(data[index*j.refLength : (index+1)*j.refLength])
+	ch, err := j.getter.Get(ctx, storage.ModeGetRequest, address)
+	if err == nil || parities == 0 || ctx.Err() != nil {
+		return ch, err
+	}
+
+	rch, rerr := j.recoverChunk(ctx, data, parities, index)
+	if rerr != nil {
+		return nil, err
+	}
+	return rch, nil
+}
+
+// recoverChunk reconstructs the chunk at the index of the references of an
+// intermediate chunk from the available data and parity chunks. All missing
+// data chunks of the intermediate chunk are reconstructed at once and kept
+// for the subsequent reads.
+func (j *joiner) recoverChunk(ctx context.Context, data []byte, parities, index int) (infinity.Chunk, error) {
+	j.recoverMu.Lock()
+	defer j.recoverMu.Unlock()
+
+	ref := func(i int) infinity.Address {
+		return infinity.NewAddress(data[i*j.refLength : (i+1)*j.refLength])
+	}
+	if ch, ok := j.recovered[ref(index).ByteString()]; ok {
+		return ch, nil
+	}
+
+	shards := make([][]byte, len(data)/j.refLength)
+	var eg errgroup.Group
+	for i := range shards {
+		if i == index {
+			continue
+		}
+		i := i
+		eg.Go(func() error {
+			ch, err := j.getter.Get(ctx, storage.ModeGetRequest, ref(i))
+			if err != nil {
+				// missing chunks are reconstructed or counted as lost
+				return nil
+			}
+			shard := make([]byte, infinity.ChunkWithSpanSize)
+			copy(shard, ch.Data())
+			shards[i] = shard
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	var missing []int
+	dataShards := len(shards) - parities
+	for i := 0; i < dataShards; i++ {
+		if shards[i] == nil {
+			missing = append(missing, i)
+		}
+	}
+	if err := redundancy.Reconstruct(shards, dataShards); err != nil {
+		return nil, err
+	}
+
+	for _, i := range missing {
+		ch := infinity.NewChunk(ref(i), j.trimShard(shards[i]))
+		if !cac.Valid(ch) {
+			if i == index {
+				return nil, errRecoveredChunkInvalid
+			}
+			continue
+		}
+		j.recovered[ref(i).ByteString()] = ch
+	}
+	return j.recovered[ref(index).ByteString()], nil
+}
+
+// trimShard removes the padding of a reconstructed chunk. Data chunks are
+// trimmed to their span, intermediate chunks to their last non-zero
+// reference.
+func (j *joiner) trimShard(shard []byte) []byte {
+	span, _ := chunkToSpan(shard)
+	if span <= infinity.ChunkSize {
+		return shard[:infinity.SpanSize+span]
+	}
+	end := len(shard)
+	zero := make([]byte, j.refLength)
+	for end > infinity.SpanSize && bytes.Equal(shard[end-j.refLength:end], zero) {
+		end -= j.refLength
+	}
+	return shard[:end]
+}
+
+// chunkToSpan returns the span of the chunk and the number of the parity
+// references if it is an intermediate chunk.
+func chunkToSpan(data []byte) (uint64, int) {
+	return redundancy.DecodeSpan(data)
 }
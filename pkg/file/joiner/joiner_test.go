@@ -19,6 +19,7 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/encryption/store"
 	"github.com/yanhuangpai/voyager/pkg/file/joiner"
 	"github.com/yanhuangpai/voyager/pkg/file/pipeline/builder"
+	"github.com/yanhuangpai/voyager/pkg/file/redundancy"
 	"github.com/yanhuangpai/voyager/pkg/file/splitter"
 	filetest "github.com/yanhuangpai/voyager/pkg/file/testing"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
@@ -830,3 +831,260 @@ func TestJoinerIterateChunkAddresses(t *testing.T) {
 		checkAddressFound(t, foundAddresses, createdAddress)
 	}
 }
+
+// TestJoinerFromState verifies that the joiner created from a captured state
+// reads the data without retrieving the captured intermediate chunks again.
+func TestJoinerFromState(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		t.Run(fmt.Sprintf("encrypt %v", encrypt), func(t *testing.T) {
+			ctx := context.Background()
+			st := mock.NewStorer()
+
+			size := 2*infinity.ChunkSize*infinity.ChunkSize/infinity.HashSize + 1000
+			g := mockbytes.New(0, mockbytes.MockTypeStandard).WithModulus(255)
+			data, err := g.SequentialBytes(size)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pipe := builder.NewPipelineBuilder(ctx, st, storage.ModePutUpload, encrypt)
+			addr, err := builder.FeedPipeline(ctx, pipe, bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			off := int64(size / 2)
+			state, err := joiner.CaptureState(ctx, st, addr, off)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !state.Root.Equal(addr) || state.Offset != off {
+				t.Fatalf("got state root %s offset %v, want %s %v", state.Root, state.Offset, addr, off)
+			}
+
+			getter := &recordingGetter{Getter: st}
+			j, l, err := joiner.NewFromState(ctx, getter, state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if l != int64(size) {
+				t.Fatalf("got size %v, want %v", l, size)
+			}
+			if _, err := j.Seek(off, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadAll(j)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data[off:]) {
+				t.Fatal("data mismatch")
+			}
+
+			if getter.requested(infinity.NewAddress(addr.Bytes()[:infinity.HashSize])) {
+				t.Fatal("root chunk retrieved")
+			}
+		})
+	}
+
+	t.Run("invalid offset", func(t *testing.T) {
+		ctx := context.Background()
+		st := mock.NewStorer()
+
+		pipe := builder.NewPipelineBuilder(ctx, st, storage.ModePutUpload, false)
+		addr, err := builder.FeedPipeline(ctx, pipe, bytes.NewReader([]byte("data")), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := joiner.CaptureState(ctx, st, addr, 5); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+// TestStateDepth verifies the depth of the chunk trees of the captured
+// states.
+func TestStateDepth(t *testing.T) {
+	branches := infinity.ChunkSize / infinity.HashSize
+	for _, tc := range []struct {
+		size  int
+		depth int
+	}{
+		{size: 1, depth: 1},
+		{size: infinity.ChunkSize, depth: 1},
+		{size: infinity.ChunkSize + 1, depth: 2},
+		{size: branches * infinity.ChunkSize, depth: 2},
+		{size: branches*infinity.ChunkSize + 1, depth: 3},
+	} {
+		t.Run(fmt.Sprintf("%d bytes", tc.size), func(t *testing.T) {
+			ctx := context.Background()
+			st := mock.NewStorer()
+
+			data, err := mockbytes.New(0, mockbytes.MockTypeStandard).WithModulus(255).SequentialBytes(tc.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pipe := builder.NewPipelineBuilder(ctx, st, storage.ModePutUpload, false)
+			addr, err := builder.FeedPipeline(ctx, pipe, bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state, err := joiner.CaptureState(ctx, st, addr, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := state.Depth(); d != tc.depth {
+				t.Fatalf("got depth %v, want %v", d, tc.depth)
+			}
+		})
+	}
+}
+
+// TestJoinerRedundancy verifies that the joiner reconstructs the missing
+// chunks of the content uploaded with redundancy from the parity chunks.
+func TestJoinerRedundancy(t *testing.T) {
+	level := redundancy.Medium
+	parities := level.Parities()
+	branching := infinity.Branches - parities
+	size := 2*branching*infinity.ChunkSize + 1000
+
+	g := mockbytes.New(0, mockbytes.MockTypeStandard).WithModulus(255)
+	data, err := g.SequentialBytes(size)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(t *testing.T) (*mock.MockStorer, infinity.Address) {
+		t.Helper()
+
+		ctx := context.Background()
+		st := mock.NewStorer()
+		pipe := builder.NewRedundantPipelineBuilder(ctx, st, storage.ModePutUpload, level)
+		addr, err := builder.FeedPipeline(ctx, pipe, bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st, addr
+	}
+
+	// refs returns the data references of the intermediate chunk
+	refs := func(t *testing.T, st storage.Getter, addr infinity.Address) (refs []infinity.Address) {
+		t.Helper()
+
+		ch, err := st.Get(context.Background(), storage.ModeGetRequest, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, p := redundancy.DecodeSpan(ch.Data())
+		if p != parities {
+			t.Fatalf("got %v parities, want %v", p, parities)
+		}
+		d := ch.Data()[infinity.SpanSize:]
+		for i := 0; i < len(d)-p*infinity.HashSize; i += infinity.HashSize {
+			refs = append(refs, infinity.NewAddress(d[i:i+infinity.HashSize]))
+		}
+		return refs
+	}
+
+	remove := func(t *testing.T, st *mock.MockStorer, addrs ...infinity.Address) {
+		t.Helper()
+
+		if err := st.Set(context.Background(), storage.ModeSetRemove, addrs...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read := func(st storage.Getter, addr infinity.Address) ([]byte, error) {
+		j, _, err := joiner.New(context.Background(), st, addr)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(j)
+	}
+
+	t.Run("addresses", func(t *testing.T) {
+		st, addr := upload(t)
+
+		j, _, err := joiner.New(context.Background(), st, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var count int
+		err = j.IterateChunkAddresses(func(infinity.Address) error {
+			count++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// root, two intermediate chunks and the data chunks with the
+		// parities of the three intermediate chunks, the last data chunk
+		// is carried over to the root
+		want := 1 + 2 + 2*branching + 1 + 3*parities
+		if count != want {
+			t.Fatalf("got %v addresses, want %v", count, want)
+		}
+	})
+
+	t.Run("recover", func(t *testing.T) {
+		st, addr := upload(t)
+
+		root := refs(t, st, addr)
+		first := refs(t, st, root[0])
+		second := refs(t, st, root[1])
+
+		// missing intermediate chunk, together with missing data chunks
+		// in all groups up to the number of the parities
+		remove(t, st, root[1])
+		remove(t, st, first[:parities]...)
+		remove(t, st, second[len(second)-parities:]...)
+
+		got, err := read(st, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatal("data mismatch")
+		}
+	})
+
+	t.Run("too many missing", func(t *testing.T) {
+		st, addr := upload(t)
+
+		first := refs(t, st, refs(t, st, addr)[0])
+		remove(t, st, first[:parities+1]...)
+
+		if _, err := read(st, addr); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("got error %v, want %v", err, storage.ErrNotFound)
+		}
+	})
+}
+
+// recordingGetter records the addresses of all retrieved chunks.
+type recordingGetter struct {
+	storage.Getter
+	addrs []infinity.Address
+	mu    sync.Mutex
+}
+
+func (g *recordingGetter) Get(ctx context.Context, mode storage.ModeGet, addr infinity.Address) (infinity.Chunk, error) {
+	g.mu.Lock()
+	g.addrs = append(g.addrs, addr)
+	g.mu.Unlock()
+	return g.Getter.Get(ctx, mode, addr)
+}
+
+func (g *recordingGetter) requested(addr infinity.Address) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, a := range g.addrs {
+		if a.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
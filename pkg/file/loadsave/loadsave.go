@@ -3,6 +3,7 @@ package loadsave
 import (
 	"bytes"
 	"context"
+	"sync"
 
 	"github.com/yanhuangpai/voyager/pkg/file"
 	"github.com/yanhuangpai/voyager/pkg/file/joiner"
@@ -54,3 +55,107 @@ func (ls *loadSave) Save(ctx context.Context, data []byte) ([]byte, error) {
 	return address.Bytes(), nil
 
 }
+
+// deferredLoadSave stores the data of every Save call as soon as the next
+// one is made, keeping only the chunks of the last saved data in memory.
+// When used for saving a manifest trie, the last saved node is always the
+// root, so it is flushed only once the whole trie is stored, while all the
+// other nodes are written incrementally.
+type deferredLoadSave struct {
+	*loadSave
+	pending   *pendingSave
+	pendingMu sync.Mutex
+}
+
+type pendingSave struct {
+	reference []byte
+	data      []byte
+	chunks    []infinity.Chunk
+}
+
+// NewDeferred returns a file.DeferredLoadSaver which defers storing of the
+// data of the last Save call until it is flushed.
+func NewDeferred(storer storage.Storer, mode storage.ModePut, enc bool) file.DeferredLoadSaver {
+	return &deferredLoadSave{
+		loadSave: &loadSave{
+			storer:    storer,
+			mode:      mode,
+			encrypted: enc,
+		},
+	}
+}
+
+func (ls *deferredLoadSave) Load(ctx context.Context, ref []byte) ([]byte, error) {
+	ls.pendingMu.Lock()
+	if ls.pending != nil && bytes.Equal(ls.pending.reference, ref) {
+		data := ls.pending.data
+		ls.pendingMu.Unlock()
+		return data, nil
+	}
+	ls.pendingMu.Unlock()
+
+	return ls.loadSave.Load(ctx, ref)
+}
+
+func (ls *deferredLoadSave) Save(ctx context.Context, data []byte) ([]byte, error) {
+	ls.pendingMu.Lock()
+	defer ls.pendingMu.Unlock()
+
+	if err := ls.flush(ctx); err != nil {
+		return infinity.ZeroAddress.Bytes(), err
+	}
+
+	// only compute the reference, chunks are kept in memory
+	buf := new(chunkBuffer)
+	pipe := builder.NewPipelineBuilder(ctx, buf, ls.mode, ls.encrypted)
+	address, err := builder.FeedPipeline(ctx, pipe, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return infinity.ZeroAddress.Bytes(), err
+	}
+
+	ls.pending = &pendingSave{
+		reference: address.Bytes(),
+		data:      data,
+		chunks:    buf.chunks,
+	}
+
+	return address.Bytes(), nil
+}
+
+func (ls *deferredLoadSave) Flush(ctx context.Context) error {
+	ls.pendingMu.Lock()
+	defer ls.pendingMu.Unlock()
+
+	return ls.flush(ctx)
+}
+
+func (ls *deferredLoadSave) flush(ctx context.Context) error {
+	if ls.pending == nil {
+		return nil
+	}
+	if _, err := ls.storer.Put(ctx, ls.mode, ls.pending.chunks...); err != nil {
+		return err
+	}
+	ls.pending = nil
+	return nil
+}
+
+func (ls *deferredLoadSave) Discard() {
+	ls.pendingMu.Lock()
+	ls.pending = nil
+	ls.pendingMu.Unlock()
+}
+
+// chunkBuffer is a storage.Putter which keeps chunks in memory.
+type chunkBuffer struct {
+	chunks []infinity.Chunk
+	mu     sync.Mutex
+}
+
+func (b *chunkBuffer) Put(_ context.Context, _ storage.ModePut, chs ...infinity.Chunk) ([]bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.chunks = append(b.chunks, chs...)
+	return make([]bool, len(chs)), nil
+}
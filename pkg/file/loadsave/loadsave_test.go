@@ -0,0 +1,108 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package loadsave_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/file/loadsave"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/storage/mock"
+)
+
+var (
+	data1 = []byte("some data")
+	data2 = []byte("some other data")
+)
+
+func TestDeferredLoadSave(t *testing.T) {
+	ctx := context.Background()
+	storer := mock.NewStorer()
+	ls := loadsave.NewDeferred(storer, storage.ModePutUpload, false)
+
+	ref1, err := ls.Save(ctx, data1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// data is not stored until the next save, but it can be loaded
+	expectStored(t, storer, ref1, false)
+	expectLoad(t, ls.Load, ref1, data1)
+
+	ref2, err := ls.Save(ctx, data2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStored(t, storer, ref1, true)
+	expectStored(t, storer, ref2, false)
+	expectLoad(t, ls.Load, ref1, data1)
+	expectLoad(t, ls.Load, ref2, data2)
+
+	if err := ls.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expectStored(t, storer, ref2, true)
+	expectLoad(t, loadsave.New(storer, storage.ModePutUpload, false).Load, ref2, data2)
+
+	// saving the same data must yield the same reference as the plain loadsaver
+	ref, err := loadsave.New(mock.NewStorer(), storage.ModePutUpload, false).Save(ctx, data2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ref, ref2) {
+		t.Fatalf("got reference %x, want %x", ref2, ref)
+	}
+}
+
+func TestDeferredLoadSave_discard(t *testing.T) {
+	ctx := context.Background()
+	storer := mock.NewStorer()
+	ls := loadsave.NewDeferred(storer, storage.ModePutUpload, false)
+
+	ref, err := ls.Save(ctx, data1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ls.Discard()
+
+	if err := ls.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expectStored(t, storer, ref, false)
+}
+
+func expectStored(t *testing.T, storer storage.Storer, ref []byte, want bool) {
+	t.Helper()
+
+	_, err := storer.Get(context.Background(), storage.ModeGetRequest, infinity.NewAddress(ref))
+	switch {
+	case err == nil && !want:
+		t.Fatalf("chunk %x stored", ref)
+	case errors.Is(err, storage.ErrNotFound) && want:
+		t.Fatalf("chunk %x not stored", ref)
+	case err != nil && !errors.Is(err, storage.ErrNotFound):
+		t.Fatal(err)
+	}
+}
+
+func expectLoad(t *testing.T, load func(context.Context, []byte) ([]byte, error), ref, want []byte) {
+	t.Helper()
+
+	got, err := load(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got data %q, want %q", got, want)
+	}
+}
@@ -5,7 +5,10 @@
 package builder
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 
@@ -16,10 +19,15 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/file/pipeline/feeder"
 	"github.com/yanhuangpai/voyager/pkg/file/pipeline/hashtrie"
 	"github.com/yanhuangpai/voyager/pkg/file/pipeline/store"
+	"github.com/yanhuangpai/voyager/pkg/file/redundancy"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/storage"
 )
 
+// ErrChecksumMismatch is returned when the SHA-256 digest of the data fed to
+// the pipeline does not match the expected checksum.
+var ErrChecksumMismatch = errors.New("pipeline: checksum mismatch")
+
 // NewPipelineBuilder returns the appropriate pipeline according to the specified parameters
 func NewPipelineBuilder(ctx context.Context, s storage.Putter, mode storage.ModePut, encrypt bool) pipeline.Interface {
 	if encrypt {
@@ -38,6 +46,19 @@ func newPipeline(ctx context.Context, s storage.Putter, mode storage.ModePut) pi
 	return feeder.NewChunkFeederWriter(infinity.ChunkSize, b)
 }
 
+// NewRedundantPipelineBuilder returns a standard pipeline which adds the
+// Reed-Solomon parity chunks of the redundancy level to every intermediate
+// chunk of the trie. Redundancy is not supported for encrypted content.
+func NewRedundantPipelineBuilder(ctx context.Context, s storage.Putter, mode storage.ModePut, level redundancy.Level) pipeline.Interface {
+	if level.Parities() == 0 {
+		return newPipeline(ctx, s, mode)
+	}
+	tw := hashtrie.NewRedundantHashTrieWriter(infinity.ChunkSize, infinity.Branches, infinity.HashSize, level.Parities(), newShortPipelineFunc(ctx, s, mode))
+	lsw := store.NewStoreWriter(ctx, s, mode, tw)
+	b := bmt.NewBmtWriter(lsw)
+	return feeder.NewChunkFeederWriter(infinity.ChunkSize, b)
+}
+
 // newShortPipelineFunc returns a constructor function for an ephemeral hashing pipeline
 // needed by the hashTrieWriter.
 func newShortPipelineFunc(ctx context.Context, s storage.Putter, mode storage.ModePut) func() pipeline.ChainWriter {
@@ -124,3 +145,23 @@ func FeedPipeline(ctx context.Context, pipeline pipeline.Interface, r io.Reader,
 	newAddress := infinity.NewAddress(sum)
 	return newAddress, nil
 }
+
+// FeedPipelineWithChecksum feeds the pipeline with the given reader until EOF
+// is reached, computing the SHA-256 digest of the plaintext data as it is split.
+// If the digest does not match the provided checksum, ErrChecksumMismatch is
+// returned instead of the root hash. A nil checksum disables the verification.
+func FeedPipelineWithChecksum(ctx context.Context, pipeline pipeline.Interface, r io.Reader, dataLength int64, checksum []byte) (infinity.Address, error) {
+	if checksum == nil {
+		return FeedPipeline(ctx, pipeline, r, dataLength)
+	}
+
+	h := sha256.New()
+	addr, err := FeedPipeline(ctx, pipeline, io.TeeReader(r, h), dataLength)
+	if err != nil {
+		return infinity.ZeroAddress, err
+	}
+	if !bytes.Equal(h.Sum(nil), checksum) {
+		return infinity.ZeroAddress, ErrChecksumMismatch
+	}
+	return addr, nil
+}
@@ -8,7 +8,9 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strconv"
 	"testing"
@@ -100,6 +102,49 @@ func TestAllVectors(t *testing.T) {
 	}
 }
 
+func TestFeedPipelineWithChecksum(t *testing.T) {
+	data := []byte("hello world")
+	checksum := sha256.Sum256(data)
+	exp := infinity.MustParseHexAddress("92672a471f4419b255d7cb0cf313474a6f5856fb347c5ece85fb706d644b630f")
+
+	t.Run("match", func(t *testing.T) {
+		m := mock.NewStorer()
+		p := builder.NewPipelineBuilder(context.Background(), m, storage.ModePutUpload, false)
+
+		addr, err := builder.FeedPipelineWithChecksum(context.Background(), p, bytes.NewReader(data), int64(len(data)), checksum[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !addr.Equal(exp) {
+			t.Fatalf("expected %s got %s", exp, addr)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		m := mock.NewStorer()
+		p := builder.NewPipelineBuilder(context.Background(), m, storage.ModePutUpload, false)
+		wrong := sha256.Sum256([]byte("hello wrld"))
+
+		_, err := builder.FeedPipelineWithChecksum(context.Background(), p, bytes.NewReader(data), int64(len(data)), wrong[:])
+		if !errors.Is(err, builder.ErrChecksumMismatch) {
+			t.Fatalf("got error %v, want %v", err, builder.ErrChecksumMismatch)
+		}
+	})
+
+	t.Run("no checksum", func(t *testing.T) {
+		m := mock.NewStorer()
+		p := builder.NewPipelineBuilder(context.Background(), m, storage.ModePutUpload, false)
+
+		addr, err := builder.FeedPipelineWithChecksum(context.Background(), p, bytes.NewReader(data), int64(len(data)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !addr.Equal(exp) {
+			t.Fatalf("expected %s got %s", exp, addr)
+		}
+	})
+}
+
 /*
 go test -v -bench=. -run Bench -benchmem
 goos: linux
@@ -9,6 +9,7 @@ import (
 	"errors"
 
 	"github.com/yanhuangpai/voyager/pkg/file/pipeline"
+	"github.com/yanhuangpai/voyager/pkg/file/redundancy"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 )
 
@@ -28,6 +29,8 @@ type hashTrieWriter struct {
 	buffer     []byte // keeps all level data
 	full       bool   // indicates whether the trie is full. currently we support (128^7)*4096 = 2305843009213693952 bytes
 	pipelineFn pipeline.PipelineFunc
+	parities   int        // number of parity references in every intermediate chunk
+	shards     [][][]byte // level children chunk data needed to encode the parities, key is level
 }
 
 func NewHashTrieWriter(chunkSize, branching, refLen int, pipelineFn pipeline.PipelineFunc) pipeline.ChainWriter {
@@ -42,6 +45,18 @@ func NewHashTrieWriter(chunkSize, branching, refLen int, pipelineFn pipeline.Pip
 	}
 }
 
+// NewRedundantHashTrieWriter returns a hash trie writer which appends the
+// references of the given number of Reed-Solomon parity chunks to every
+// intermediate chunk. The parity chunks are encoded from the data of the
+// children of the intermediate chunk, so the branching of the data references
+// is reduced by the number of the parities.
+func NewRedundantHashTrieWriter(chunkSize, branching, refLen, parities int, pipelineFn pipeline.PipelineFunc) pipeline.ChainWriter {
+	h := NewHashTrieWriter(chunkSize, branching-parities, refLen, pipelineFn).(*hashTrieWriter)
+	h.parities = parities
+	h.shards = make([][][]byte, 9)
+	return h
+}
+
 // accepts writes of hashes from the previous writer in the chain, by definition these writes
 // are on level 1
 func (h *hashTrieWriter) ChainWrite(p *pipeline.PipeWriteArgs) error {
@@ -53,6 +68,9 @@ func (h *hashTrieWriter) ChainWrite(p *pipeline.PipeWriteArgs) error {
 	if h.full {
 		return errTrieFull
 	}
+	if h.parities > 0 {
+		h.shards[1] = append(h.shards[1], append([]byte(nil), p.Data...))
+	}
 	return h.writeToLevel(1, p.Span, p.Ref, p.Key)
 }
 
@@ -95,7 +113,19 @@ func (h *hashTrieWriter) wrapFullLevel(level int) error {
 	}
 	spb := make([]byte, 8)
 	binary.LittleEndian.PutUint64(spb, sp)
-	hashes = append(spb, hashes...)
+	chunkSpan := spb
+	if h.parities > 0 {
+		// the parity references follow the data references and their
+		// number is encoded in the span of the chunk, the span written
+		// to the level above is kept clean to sum up correctly
+		refs, err := h.encodeParities(level)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, refs...)
+		chunkSpan = redundancy.EncodeSpan(sp, h.parities)
+	}
+	hashes = append(chunkSpan, hashes...)
 	writer := h.pipelineFn()
 	args := pipeline.PipeWriteArgs{
 		Data: hashes,
@@ -105,6 +135,9 @@ func (h *hashTrieWriter) wrapFullLevel(level int) error {
 	if err != nil {
 		return err
 	}
+	if h.parities > 0 {
+		h.shards[level+1] = append(h.shards[level+1], args.Data)
+	}
 	err = h.writeToLevel(level+1, args.Span, args.Ref, args.Key)
 	if err != nil {
 		return err
@@ -119,6 +152,35 @@ func (h *hashTrieWriter) wrapFullLevel(level int) error {
 	return nil
 }
 
+// encodeParities encodes the parity chunks from the data of the children of
+// the level, stores them and returns their references.
+func (h *hashTrieWriter) encodeParities(level int) ([]byte, error) {
+	shards := make([][]byte, len(h.shards[level]))
+	for i, s := range h.shards[level] {
+		shards[i] = make([]byte, h.chunkSize+infinity.SpanSize)
+		copy(shards[i], s)
+	}
+	h.shards[level] = nil
+
+	parities, err := redundancy.Encode(shards, h.parities)
+	if err != nil {
+		return nil, err
+	}
+	var refs []byte
+	for _, p := range parities {
+		writer := h.pipelineFn()
+		args := pipeline.PipeWriteArgs{
+			Data: p,
+			Span: p[:infinity.SpanSize],
+		}
+		if err := writer.ChainWrite(&args); err != nil {
+			return nil, err
+		}
+		refs = append(refs, args.Ref...)
+	}
+	return refs, nil
+}
+
 func (h *hashTrieWriter) levelSize(level int) int {
 	if level == 8 {
 		return h.cursors[level]
@@ -175,6 +237,10 @@ func (h *hashTrieWriter) Sum() ([]byte, error) {
 			// that might or might not have data. the eventual result is that the last
 			// hash generated will always be carried over to the last level (8), then returned.
 			h.cursors[i+1] = h.cursors[i]
+			if h.parities > 0 {
+				h.shards[i+1] = append(h.shards[i+1], h.shards[i]...)
+				h.shards[i] = nil
+			}
 		default:
 			// more than 0 but smaller than chunk size - wrap the level to the one above it
 			err := h.wrapFullLevel(i)
@@ -0,0 +1,209 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package file
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+var (
+	// PrefetchMinWindow is the smallest prefetch window used for slow
+	// consumers.
+	PrefetchMinWindow = 8 * infinity.ChunkSize
+	// PrefetchMaxWindow is the largest prefetch window used for fast
+	// consumers on slow retrieval.
+	PrefetchMaxWindow = 1024 * infinity.ChunkSize
+	// PrefetchInitialWindow is the prefetch window used before the
+	// throughput is measured.
+	PrefetchInitialWindow = 64 * infinity.ChunkSize
+)
+
+const (
+	// prefetchLatencies is the number of the retrieval latencies the
+	// prefetch window should cover at the consumer read rate.
+	prefetchLatencies = 2
+	// prefetchSmoothing is the weight of the new observation in the moving
+	// averages of the latency and the read rate.
+	prefetchSmoothing = 0.3
+)
+
+var (
+	errPrefetchWhence = errors.New("prefetch: invalid whence")
+	errPrefetchOffset = errors.New("prefetch: negative offset")
+)
+
+// Prefetcher reads the data ahead of the consumer. The size of the prefetch
+// window is adapted to the measured retrieval latency and the consumer read
+// rate, so that slow consumers do not hold large buffers and fast consumers
+// are not stalled by the retrieval.
+//
+// Read and Seek must not be called concurrently.
+type Prefetcher struct {
+	r      Reader
+	size   int64
+	cursor int64
+
+	buf    []byte    // fetched data at bufOff
+	bufOff int64     // offset of the fetched data
+	peek   *peek     // lookahead read in progress
+	window int       // current prefetch window in bytes
+	rate   float64   // moving average of the consumer read rate in bytes per second
+	delay  float64   // moving average of the retrieval latency in seconds
+	last   time.Time // time of the last read
+}
+
+type peek struct {
+	off     int64
+	data    []byte
+	err     error
+	latency time.Duration
+	done    chan struct{}
+}
+
+// NewPrefetcher returns a Prefetcher for the reader of the data of the given
+// size.
+func NewPrefetcher(r Reader, size int64) *Prefetcher {
+	return &Prefetcher{
+		r:      r,
+		size:   size,
+		window: PrefetchInitialWindow,
+	}
+}
+
+// Read reads the data at the current position from the prefetched data and
+// starts prefetching the data which follows.
+func (f *Prefetcher) Read(b []byte) (int, error) {
+	if f.cursor >= f.size {
+		return 0, io.EOF
+	}
+
+	if !f.buffered() {
+		if f.peek == nil || f.peek.off != f.cursor {
+			// the position is not on the prefetched path
+			f.peek = f.startPeek(f.cursor)
+		}
+		pe := f.peek
+		f.peek = nil
+		<-pe.done
+
+		f.observeLatency(pe.latency)
+		if len(pe.data) == 0 {
+			if pe.err == nil {
+				pe.err = io.ErrUnexpectedEOF
+			}
+			return 0, pe.err
+		}
+		f.buf, f.bufOff = pe.data, pe.off
+	}
+
+	n := copy(b, f.buf[f.cursor-f.bufOff:])
+	f.cursor += int64(n)
+	f.observeRead(n)
+
+	if end := f.bufOff + int64(len(f.buf)); f.peek == nil && end < f.size {
+		f.peek = f.startPeek(end)
+	}
+	return n, nil
+}
+
+// ReadAt reads directly from the underlying reader.
+func (f *Prefetcher) ReadAt(b []byte, off int64) (int, error) {
+	return f.r.ReadAt(b, off)
+}
+
+// Seek sets the position of the next Read. The prefetched data is kept if it
+// contains the new position.
+func (f *Prefetcher) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.cursor
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errPrefetchWhence
+	}
+	if offset < 0 {
+		return 0, errPrefetchOffset
+	}
+	f.cursor = offset
+	return offset, nil
+}
+
+// buffered reports whether the data at the current position is prefetched.
+func (f *Prefetcher) buffered() bool {
+	return f.cursor >= f.bufOff && f.cursor < f.bufOff+int64(len(f.buf))
+}
+
+// startPeek starts reading the prefetch window of data at the offset.
+func (f *Prefetcher) startPeek(off int64) *peek {
+	size := int64(f.window)
+	if rest := f.size - off; rest < size {
+		size = rest
+	}
+
+	pe := &peek{
+		off:  off,
+		data: make([]byte, size),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(pe.done)
+
+		start := time.Now()
+		n, err := f.r.ReadAt(pe.data, off)
+		pe.latency = time.Since(start)
+		if n > len(pe.data) {
+			n = len(pe.data)
+		}
+		pe.data = pe.data[:n]
+		if err != io.EOF {
+			pe.err = err
+		}
+	}()
+	return pe
+}
+
+// observeRead updates the consumer read rate with the read of n bytes.
+func (f *Prefetcher) observeRead(n int) {
+	now := time.Now()
+	if !f.last.IsZero() {
+		if d := now.Sub(f.last).Seconds(); d > 0 {
+			f.rate = average(f.rate, float64(n)/d)
+		}
+	}
+	f.last = now
+}
+
+// observeLatency updates the retrieval latency and adapts the prefetch
+// window to cover the data the consumer reads during the latency.
+func (f *Prefetcher) observeLatency(latency time.Duration) {
+	f.delay = average(f.delay, latency.Seconds())
+	if f.rate == 0 {
+		return
+	}
+
+	window := int(f.rate * f.delay * prefetchLatencies)
+	window = (window + infinity.ChunkSize - 1) / infinity.ChunkSize * infinity.ChunkSize
+	switch {
+	case window < PrefetchMinWindow:
+		window = PrefetchMinWindow
+	case window > PrefetchMaxWindow:
+		window = PrefetchMaxWindow
+	}
+	f.window = window
+}
+
+// average returns the exponential moving average with the new value.
+func average(avg, v float64) float64 {
+	if avg == 0 {
+		return v
+	}
+	return avg + prefetchSmoothing*(v-avg)
+}
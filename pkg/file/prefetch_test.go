@@ -0,0 +1,120 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package file_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/file"
+)
+
+// slowReader is a reader with a fixed latency of every ReadAt call.
+type slowReader struct {
+	*bytes.Reader
+	latency time.Duration
+}
+
+func (r *slowReader) ReadAt(b []byte, off int64) (int, error) {
+	time.Sleep(r.latency)
+	return r.Reader.ReadAt(b, off)
+}
+
+func newSlowReader(t *testing.T, size int, latency time.Duration) ([]byte, *slowReader) {
+	t.Helper()
+
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data, &slowReader{Reader: bytes.NewReader(data), latency: latency}
+}
+
+func TestPrefetcherRead(t *testing.T) {
+	data, r := newSlowReader(t, 3*file.PrefetchInitialWindow+1000, 0)
+	p := file.NewPrefetcher(r, int64(len(data)))
+
+	got, err := ioutil.ReadAll(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("data mismatch")
+	}
+}
+
+func TestPrefetcherSeek(t *testing.T) {
+	data, r := newSlowReader(t, 3*file.PrefetchInitialWindow+1000, 0)
+	p := file.NewPrefetcher(r, int64(len(data)))
+
+	for _, tc := range []struct {
+		offset int64
+		whence int
+		want   int64
+	}{
+		{offset: 10, whence: io.SeekStart, want: 10},
+		{offset: 100, whence: io.SeekCurrent, want: 110 + 100},
+		{offset: int64(file.PrefetchInitialWindow) * 2, whence: io.SeekStart, want: int64(file.PrefetchInitialWindow) * 2},
+		{offset: -100, whence: io.SeekEnd, want: int64(len(data)) - 100},
+		{offset: 0, whence: io.SeekStart, want: 0},
+	} {
+		off, err := p.Seek(tc.offset, tc.whence)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if off != tc.want {
+			t.Fatalf("got offset %v, want %v", off, tc.want)
+		}
+
+		b := make([]byte, 100)
+		n, err := io.ReadFull(p, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b[:n], data[off:off+int64(n)]) {
+			t.Fatalf("offset %v: data mismatch", off)
+		}
+	}
+
+	if _, err := p.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// TestPrefetcherWindow tests that the prefetch window grows for a fast
+// consumer on slow retrieval and shrinks for a slow consumer.
+func TestPrefetcherWindow(t *testing.T) {
+	t.Run("fast consumer", func(t *testing.T) {
+		data, r := newSlowReader(t, 8*file.PrefetchInitialWindow, 20*time.Millisecond)
+		p := file.NewPrefetcher(r, int64(len(data)))
+
+		if _, err := io.Copy(ioutil.Discard, p); err != nil {
+			t.Fatal(err)
+		}
+		if w := p.Window(); w <= file.PrefetchInitialWindow {
+			t.Fatalf("got window %v, want more than %v", w, file.PrefetchInitialWindow)
+		}
+	})
+
+	t.Run("slow consumer", func(t *testing.T) {
+		data, r := newSlowReader(t, 8*file.PrefetchInitialWindow, 5*time.Millisecond)
+		p := file.NewPrefetcher(r, int64(len(data)))
+
+		b := make([]byte, 1024)
+		for i := 0; i < 3*file.PrefetchInitialWindow/len(b); i++ {
+			if _, err := io.ReadFull(p, b); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if w := p.Window(); w != file.PrefetchMinWindow {
+			t.Fatalf("got window %v, want %v", w, file.PrefetchMinWindow)
+		}
+	})
+}
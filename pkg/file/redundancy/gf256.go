@@ -0,0 +1,64 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redundancy
+
+// fieldSize is the number of the elements of GF(2^8), which is also the
+// maximal number of the data and parity shards.
+const fieldSize = 256
+
+// polynomial is the irreducible polynomial generating GF(2^8).
+const polynomial = 0x11d
+
+var (
+	expTable [2 * (fieldSize - 1)]byte
+	logTable [fieldSize]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < fieldSize-1; i++ {
+		expTable[i] = byte(x)
+		expTable[i+fieldSize-1] = byte(x)
+		logTable[x] = byte(i)
+		x <<= 1
+		if x >= fieldSize {
+			x ^= polynomial
+		}
+	}
+}
+
+// mul multiplies the field elements.
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+// inverse returns the multiplicative inverse of the non-zero field element.
+func inverse(a byte) byte {
+	return expTable[fieldSize-1-int(logTable[a])]
+}
+
+// mulAdd adds the product of the coefficient and the src to the dst.
+func mulAdd(dst, src []byte, c byte) {
+	switch c {
+	case 0:
+		return
+	case 1:
+		for i, v := range src {
+			dst[i] ^= v
+		}
+		return
+	}
+
+	var t [fieldSize]byte
+	for i := range t {
+		t[i] = mul(byte(i), c)
+	}
+	for i, v := range src {
+		dst[i] ^= t[v]
+	}
+}
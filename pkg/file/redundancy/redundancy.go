@@ -0,0 +1,235 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package redundancy provides the Reed-Solomon erasure coding of the chunk
+// trees. The children of every intermediate chunk are the data shards of a
+// systematic Reed-Solomon code and the references of the parity shards are
+// appended to the references of the children. Any missing children can be
+// reconstructed as long as the number of the missing chunks in the group does
+// not exceed the number of the parities.
+//
+// The number of the parity references is stored in the most significant byte
+// of the span of the intermediate chunk, which is never used by the span of
+// the content.
+package redundancy
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+var (
+	// ErrInvalidLevel is returned when the redundancy level is not known.
+	ErrInvalidLevel = errors.New("redundancy: invalid level")
+	// ErrTooFewShards is returned when there are not enough shards to
+	// reconstruct the data.
+	ErrTooFewShards = errors.New("redundancy: too few shards")
+	// ErrTooManyShards is returned when the number of the data and parity
+	// shards exceeds the size of the field.
+	ErrTooManyShards = errors.New("redundancy: too many shards")
+	// ErrShardSize is returned when the shards are not of equal size.
+	ErrShardSize = errors.New("redundancy: shard size mismatch")
+)
+
+// Level is the level of redundancy of the uploaded content.
+type Level uint8
+
+const (
+	// None adds no parity chunks.
+	None Level = iota
+	// Medium tolerates the loss of 9 chunks in every group.
+	Medium
+	// Strong tolerates the loss of 21 chunks in every group.
+	Strong
+	// Insane tolerates the loss of 31 chunks in every group.
+	Insane
+	// Paranoid tolerates the loss of 90 chunks in every group.
+	Paranoid
+)
+
+// parities are the numbers of the parity references in every intermediate
+// chunk by the redundancy level.
+var parities = []int{
+	None:     0,
+	Medium:   9,
+	Strong:   21,
+	Insane:   31,
+	Paranoid: 90,
+}
+
+// ParseLevel parses the decimal redundancy level.
+func ParseLevel(s string) (Level, error) {
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || int(v) >= len(parities) {
+		return None, ErrInvalidLevel
+	}
+	return Level(v), nil
+}
+
+// Parities returns the number of the parity references in every intermediate
+// chunk.
+func (l Level) Parities() int {
+	if int(l) >= len(parities) {
+		return 0
+	}
+	return parities[l]
+}
+
+// EncodeSpan returns the span bytes of an intermediate chunk with the given
+// number of the parity references.
+func EncodeSpan(span uint64, parities int) []byte {
+	b := make([]byte, infinity.SpanSize)
+	binary.LittleEndian.PutUint64(b, span)
+	b[infinity.SpanSize-1] = byte(parities)
+	return b
+}
+
+// DecodeSpan returns the span and the number of the parity references encoded
+// in the span bytes of a chunk.
+func DecodeSpan(b []byte) (span uint64, parities int) {
+	s := make([]byte, infinity.SpanSize)
+	copy(s, b[:infinity.SpanSize])
+	parities = int(s[infinity.SpanSize-1])
+	s[infinity.SpanSize-1] = 0
+	return binary.LittleEndian.Uint64(s), parities
+}
+
+// Encode returns the parity shards for the data shards of equal size.
+func Encode(data [][]byte, parities int) ([][]byte, error) {
+	if len(data)+parities > fieldSize {
+		return nil, ErrTooManyShards
+	}
+	size := len(data[0])
+	for _, d := range data {
+		if len(d) != size {
+			return nil, ErrShardSize
+		}
+	}
+
+	out := make([][]byte, parities)
+	for i := range out {
+		out[i] = make([]byte, size)
+		for j, d := range data {
+			mulAdd(out[i], d, cauchy(len(data), i, j))
+		}
+	}
+	return out, nil
+}
+
+// Reconstruct fills in the missing data shards. The shards are the data
+// shards followed by the parity shards, missing shards are nil.
+func Reconstruct(shards [][]byte, dataShards int) error {
+	if len(shards) > fieldSize {
+		return ErrTooManyShards
+	}
+
+	var (
+		rows    []int // indices of the shards used for the reconstruction
+		missing []int // indices of the missing data shards
+		size    = -1
+	)
+	for i, s := range shards {
+		if s == nil {
+			if i < dataShards {
+				missing = append(missing, i)
+			}
+			continue
+		}
+		if size == -1 {
+			size = len(s)
+		} else if len(s) != size {
+			return ErrShardSize
+		}
+		if len(rows) < dataShards {
+			rows = append(rows, i)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if len(rows) < dataShards {
+		return ErrTooFewShards
+	}
+
+	// the available shards are the product of the encoding matrix rows and
+	// the data shards, so the data shards are the product of the inverted
+	// matrix and the available shards
+	m := make([][]byte, dataShards)
+	for i, r := range rows {
+		m[i] = make([]byte, dataShards)
+		if r < dataShards {
+			m[i][r] = 1
+			continue
+		}
+		for j := range m[i] {
+			m[i][j] = cauchy(dataShards, r-dataShards, j)
+		}
+	}
+	inv, err := invert(m)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range missing {
+		s := make([]byte, size)
+		for k, r := range rows {
+			mulAdd(s, shards[r], inv[d][k])
+		}
+		shards[d] = s
+	}
+	return nil
+}
+
+// cauchy returns the element of the Cauchy matrix used for the parity shard
+// of the code with the number of the data shards. Every square submatrix of
+// the Cauchy matrix is invertible, so any data shards can be reconstructed from
+// any parity shards.
+func cauchy(dataShards, parity, data int) byte {
+	return inverse(byte(dataShards+parity) ^ byte(data))
+}
+
+// invert returns the inverse of the square matrix using the Gauss-Jordan
+// elimination.
+func invert(m [][]byte) ([][]byte, error) {
+	n := len(m)
+	a := make([][]byte, n)
+	for i := range a {
+		a[i] = make([]byte, 2*n)
+		copy(a[i], m[i])
+		a[i][n+i] = 1
+	}
+
+	for c := 0; c < n; c++ {
+		p := c
+		for p < n && a[p][c] == 0 {
+			p++
+		}
+		if p == n {
+			return nil, errors.New("redundancy: singular matrix")
+		}
+		a[c], a[p] = a[p], a[c]
+
+		if v := a[c][c]; v != 1 {
+			iv := inverse(v)
+			for j := range a[c] {
+				a[c][j] = mul(a[c][j], iv)
+			}
+		}
+		for r := 0; r < n; r++ {
+			if r == c || a[r][c] == 0 {
+				continue
+			}
+			mulAdd(a[r], a[c], a[r][c])
+		}
+	}
+
+	inv := make([][]byte, n)
+	for i := range inv {
+		inv[i] = a[i][n:]
+	}
+	return inv, nil
+}
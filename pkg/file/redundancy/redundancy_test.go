@@ -0,0 +1,109 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redundancy_test
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/file/redundancy"
+)
+
+func TestReconstruct(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     int
+		parities int
+		missing  []int
+		err      error
+	}{
+		{name: "nothing missing", data: 10, parities: 4},
+		{name: "data missing", data: 10, parities: 4, missing: []int{0, 3, 9}},
+		{name: "data and parities missing", data: 10, parities: 4, missing: []int{1, 2, 10, 13}},
+		{name: "all parities used", data: 119, parities: 9, missing: []int{0, 10, 20, 30, 40, 50, 60, 70, 118}},
+		{name: "paranoid", data: 38, parities: 90, missing: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34, 35, 36, 37}},
+		{name: "too many missing", data: 10, parities: 2, missing: []int{0, 1, 2}, err: redundancy.ErrTooFewShards},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := make([][]byte, tc.data)
+			for i := range data {
+				data[i] = make([]byte, 64)
+				rand.Read(data[i])
+			}
+			parities, err := redundancy.Encode(data, tc.parities)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(parities) != tc.parities {
+				t.Fatalf("got %v parities, want %v", len(parities), tc.parities)
+			}
+
+			shards := make([][]byte, 0, tc.data+tc.parities)
+			for _, d := range data {
+				shards = append(shards, append([]byte(nil), d...))
+			}
+			shards = append(shards, parities...)
+			for _, i := range tc.missing {
+				shards[i] = nil
+			}
+
+			err = redundancy.Reconstruct(shards, tc.data)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+			if tc.err != nil {
+				return
+			}
+			for i, d := range data {
+				if !bytes.Equal(shards[i], d) {
+					t.Fatalf("shard %v: got %x, want %x", i, shards[i], d)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeShardSize(t *testing.T) {
+	_, err := redundancy.Encode([][]byte{make([]byte, 2), make([]byte, 3)}, 1)
+	if !errors.Is(err, redundancy.ErrShardSize) {
+		t.Fatalf("got error %v, want %v", err, redundancy.ErrShardSize)
+	}
+}
+
+func TestSpan(t *testing.T) {
+	b := redundancy.EncodeSpan(123456, 21)
+	span, parities := redundancy.DecodeSpan(b)
+	if span != 123456 {
+		t.Errorf("got span %v, want %v", span, 123456)
+	}
+	if parities != 21 {
+		t.Errorf("got parities %v, want %v", parities, 21)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, tc := range []struct {
+		s     string
+		level redundancy.Level
+		err   error
+	}{
+		{s: "0", level: redundancy.None},
+		{s: "1", level: redundancy.Medium},
+		{s: "4", level: redundancy.Paranoid},
+		{s: "5", err: redundancy.ErrInvalidLevel},
+		{s: "-1", err: redundancy.ErrInvalidLevel},
+		{s: "medium", err: redundancy.ErrInvalidLevel},
+	} {
+		level, err := redundancy.ParseLevel(tc.s)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%q: got error %v, want %v", tc.s, err, tc.err)
+		}
+		if level != tc.level {
+			t.Errorf("%q: got level %v, want %v", tc.s, level, tc.level)
+		}
+	}
+}
@@ -26,10 +26,11 @@ import (
 
 const (
 	protocolName    = "hive"
-	protocolVersion = "1.0.0"
+	protocolVersion = "1.1.0" // the peers are sent with their overlay nonces since 1.1.0
 	peersStreamName = "peers"
 	messageTimeout  = 1 * time.Minute // maximum allowed time for a message to be read or written.
 	maxBatchSize    = 30
+	peersField      = 1 // number of the peers field of the pb.Peers message
 )
 
 type Service struct {
@@ -116,6 +117,7 @@ func (s *Service) sendPeers(ctx context.Context, peer infinity.Address, peers []
 			Overlay:   addr.Overlay.Bytes(),
 			Underlay:  addr.Underlay.Bytes(),
 			Signature: addr.Signature,
+			Nonce:     addr.Nonce,
 		})
 	}
 
@@ -128,39 +130,55 @@ func (s *Service) sendPeers(ctx context.Context, peer infinity.Address, peers []
 
 func (s *Service) peersHandler(ctx context.Context, peer p2p.Peer, stream p2p.Stream) error {
 	s.metrics.PeersHandler.Inc()
+	logger := logging.PeerEntry(s.logger, peer.Address, logging.NewRequestID())
 	_, r := protobuf.NewWriterAndReader(stream)
 	ctx, cancel := context.WithTimeout(ctx, messageTimeout)
 	defer cancel()
-	var peersReq pb.Peers
-	if err := r.ReadMsgWithContext(ctx, &peersReq); err != nil {
-		_ = stream.Reset()
-		return fmt.Errorf("read requestPeers message: %w", err)
-	}
-
-	s.metrics.PeersHandlerPeers.Add(float64(len(peersReq.Peers)))
+	// the peers are added to the address book as they are read, so that the
+	// large messages are not held in memory as a whole
+	var (
+		received int
+		peers    []infinity.Address
+	)
+	if err := r.ReadFieldsWithContext(ctx, func(f protobuf.Field) error {
+		if f.Number != peersField {
+			return nil
+		}
+		received++
 
-	// close the stream before processing in order to unblock the sending side
-	// fullclose is called async because there is no need to wait for confirmation,
-	// but we still want to handle not closed stream from the other side to avoid zombie stream
-	go stream.FullClose()
+		var newPeer pb.IfiAddress
+		if err := f.Unmarshal(&newPeer); err != nil {
+			return fmt.Errorf("peer: %w", err)
+		}
 
-	var peers []infinity.Address
-	for _, newPeer := range peersReq.Peers {
-		ifiAddress, err := ifi.ParseAddress(newPeer.Underlay, newPeer.Overlay, newPeer.Signature, s.networkID)
+		ifiAddress, err := ifi.ParseAddress(newPeer.Underlay, newPeer.Overlay, newPeer.Signature, newPeer.Nonce, s.networkID)
 		if err != nil {
-			s.logger.Warningf("skipping peer in response %s: %v", newPeer.String(), err)
-			continue
+			logger.Warningf("skipping peer in response %s: %v", newPeer.String(), err)
+			return nil
 		}
 
-		err = s.addressBook.Put(ifiAddress.Overlay, *ifiAddress)
+		err = s.addressBook.PutSource(ifiAddress.Overlay, *ifiAddress, addressbook.SourceHive)
 		if err != nil {
-			s.logger.Warningf("skipping peer in response %s: %v", newPeer.String(), err)
-			continue
+			logger.Warningf("skipping peer in response %s: %v", newPeer.String(), err)
+			return nil
 		}
 
 		peers = append(peers, ifiAddress.Overlay)
+		return nil
+	}); err != nil {
+		_ = stream.Reset()
+		return fmt.Errorf("read requestPeers message: %w", err)
 	}
 
+	s.metrics.PeersHandlerPeers.Add(float64(received))
+
+	// close the stream before the peers are handled in order to unblock the sending side
+	// fullclose is called async because there is no need to wait for confirmation,
+	// but we still want to handle not closed stream from the other side to avoid zombie stream
+	go stream.FullClose()
+
+	logger.Tracef("hive: received %d peers", len(peers))
+
 	if s.addPeersHandler != nil {
 		if err := s.addPeersHandler(ctx, peers...); err != nil {
 			return err
@@ -56,11 +56,16 @@ func TestBroadcastPeers(t *testing.T) {
 			t.Fatal(err)
 		}
 		signer := crypto.NewDefaultSigner(pk)
-		overlay, err := crypto.NewOverlayAddress(pk.PublicKey, networkID)
+		// every other peer has the overlay derived with a nonce
+		var nonce []byte
+		if i%2 == 1 {
+			nonce = []byte{byte(i)}
+		}
+		overlay, err := crypto.NewOverlayAddress(pk.PublicKey, networkID, nonce)
 		if err != nil {
 			t.Fatal(err)
 		}
-		ifiAddr, err := ifi.NewAddress(signer, underlay, overlay, networkID)
+		ifiAddr, err := ifi.NewAddress(signer, underlay, overlay, networkID, nonce)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -76,6 +81,7 @@ func TestBroadcastPeers(t *testing.T) {
 			Overlay:   ifiAddresses[i].Overlay.Bytes(),
 			Underlay:  ifiAddresses[i].Underlay.Bytes(),
 			Signature: ifiAddresses[i].Signature,
+			Nonce:     ifiAddresses[i].Nonce,
 		})
 	}
 
@@ -142,7 +148,7 @@ func TestBroadcastPeers(t *testing.T) {
 			}
 
 			// get a record for this stream
-			records, err := recorder.Records(tc.addresee, "hive", "1.0.0", "peers")
+			records, err := recorder.Records(tc.addresee, "hive", "1.1.0", "peers")
 			if err != nil {
 				t.Fatal(err)
 			}
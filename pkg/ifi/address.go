@@ -11,6 +11,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -26,19 +27,23 @@ var ErrInvalidAddress = errors.New("invalid address")
 // Address represents the ifi address in infinity.
 // It consists of a peers underlay (physical) address, overlay (topology) address and signature.
 // Signature is used to verify the `Overlay/Underlay` pair, as it is based on `underlay|networkID`, signed with the public key of Overlay address
+// The overlay nonce, empty unless the overlay address is mined, is needed to
+// derive the overlay address from the public key.
 type Address struct {
 	Underlay  ma.Multiaddr
 	Overlay   infinity.Address
 	Signature []byte
+	Nonce     []byte
 }
 
 type addressJSON struct {
 	Overlay   string `json:"overlay"`
 	Underlay  string `json:"underlay"`
 	Signature string `json:"signature"`
+	Nonce     string `json:"nonce,omitempty"`
 }
 
-func NewAddress(signer crypto.Signer, underlay ma.Multiaddr, overlay infinity.Address, networkID uint64) (*Address, error) {
+func NewAddress(signer crypto.Signer, underlay ma.Multiaddr, overlay infinity.Address, networkID uint64, nonce []byte) (*Address, error) {
 	underlayBinary, err := underlay.MarshalBinary()
 	if err != nil {
 		return nil, err
@@ -53,16 +58,21 @@ func NewAddress(signer crypto.Signer, underlay ma.Multiaddr, overlay infinity.Ad
 		Underlay:  underlay,
 		Overlay:   overlay,
 		Signature: signature,
+		Nonce:     nonce,
 	}, nil
 }
 
-func ParseAddress(underlay, overlay, signature []byte, networkID uint64) (*Address, error) {
+func ParseAddress(underlay, overlay, signature, nonce []byte, networkID uint64) (*Address, error) {
+	if len(nonce) > crypto.MaxOverlayNonceSize {
+		return nil, ErrInvalidAddress
+	}
+
 	recoveredPK, err := crypto.Recover(signature, generateSignData(underlay, overlay, networkID))
 	if err != nil {
 		return nil, ErrInvalidAddress
 	}
 
-	recoveredOverlay, err := crypto.NewOverlayAddress(*recoveredPK, networkID)
+	recoveredOverlay, err := crypto.NewOverlayAddress(*recoveredPK, networkID, nonce)
 	if err != nil {
 		return nil, ErrInvalidAddress
 	}
@@ -79,6 +89,7 @@ func ParseAddress(underlay, overlay, signature []byte, networkID uint64) (*Addre
 		Underlay:  multiUnderlay,
 		Overlay:   infinity.NewAddress(overlay),
 		Signature: signature,
+		Nonce:     nonce,
 	}, nil
 }
 
@@ -91,7 +102,7 @@ func generateSignData(underlay, overlay []byte, networkID uint64) []byte {
 }
 
 func (a *Address) Equal(b *Address) bool {
-	return a.Overlay.Equal(b.Overlay) && a.Underlay.Equal(b.Underlay) && bytes.Equal(a.Signature, b.Signature)
+	return a.Overlay.Equal(b.Overlay) && a.Underlay.Equal(b.Underlay) && bytes.Equal(a.Signature, b.Signature) && bytes.Equal(a.Nonce, b.Nonce)
 }
 
 func (a *Address) MarshalJSON() ([]byte, error) {
@@ -99,6 +110,7 @@ func (a *Address) MarshalJSON() ([]byte, error) {
 		Overlay:   a.Overlay.String(),
 		Underlay:  a.Underlay.String(),
 		Signature: base64.StdEncoding.EncodeToString(a.Signature),
+		Nonce:     hex.EncodeToString(a.Nonce),
 	})
 }
 
@@ -123,6 +135,13 @@ func (a *Address) UnmarshalJSON(b []byte) error {
 
 	a.Underlay = m
 	a.Signature, err = base64.StdEncoding.DecodeString(v.Signature)
+	if err != nil {
+		return err
+	}
+
+	if v.Nonce != "" {
+		a.Nonce, err = hex.DecodeString(v.Nonce)
+	}
 	return err
 }
 
@@ -24,18 +24,18 @@ func TestIfiAddress(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	overlay, err := crypto.NewOverlayAddress(privateKey1.PublicKey, 3)
+	overlay, err := crypto.NewOverlayAddress(privateKey1.PublicKey, 3, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 	signer1 := crypto.NewDefaultSigner(privateKey1)
 
-	ifiAddress, err := ifi.NewAddress(signer1, node1ma, overlay, 3)
+	ifiAddress, err := ifi.NewAddress(signer1, node1ma, overlay, 3, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	ifiAddress2, err := ifi.ParseAddress(node1ma.Bytes(), overlay.Bytes(), ifiAddress.Signature, 3)
+	ifiAddress2, err := ifi.ParseAddress(node1ma.Bytes(), overlay.Bytes(), ifiAddress.Signature, nil, 3)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -58,3 +58,54 @@ func TestIfiAddress(t *testing.T) {
 		t.Fatalf("got %s expected %s", newifi, ifiAddress)
 	}
 }
+
+func TestIfiAddressNonce(t *testing.T) {
+	underlay, err := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/11634/p2p/16Uiu2HAkx8ULY8cTXhdVAcMmLcH9AsTKz6uBQ7DPLKRjMLgBVYkA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey, err := crypto.GenerateSecp256k1Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := crypto.NewDefaultSigner(privateKey)
+
+	nonce := []byte{1, 2, 3}
+	overlay, err := crypto.NewOverlayAddress(privateKey.PublicKey, 3, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ifiAddress, err := ifi.NewAddress(signer, underlay, overlay, 3, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ifi.ParseAddress(underlay.Bytes(), overlay.Bytes(), ifiAddress.Signature, nonce, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Equal(ifiAddress) {
+		t.Fatalf("got %s expected %s", parsed, ifiAddress)
+	}
+
+	// the overlay address is not derived without the nonce
+	if _, err := ifi.ParseAddress(underlay.Bytes(), overlay.Bytes(), ifiAddress.Signature, nil, 3); err != ifi.ErrInvalidAddress {
+		t.Fatalf("got error %v, want %v", err, ifi.ErrInvalidAddress)
+	}
+	if _, err := ifi.ParseAddress(underlay.Bytes(), overlay.Bytes(), ifiAddress.Signature, make([]byte, crypto.MaxOverlayNonceSize+1), 3); err != ifi.ErrInvalidAddress {
+		t.Fatalf("got error %v, want %v", err, ifi.ErrInvalidAddress)
+	}
+
+	b, err := ifiAddress.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unmarshaled ifi.Address
+	if err := unmarshaled.UnmarshalJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	if !unmarshaled.Equal(ifiAddress) {
+		t.Fatalf("got %s expected %s", &unmarshaled, ifiAddress)
+	}
+}
@@ -6,7 +6,9 @@ package test
 
 import (
 	"fmt"
+	"hash/fnv"
 	"math/rand"
+	"testing"
 
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 )
@@ -14,6 +16,16 @@ import (
 // RandomAddressAt generates a random address
 // at proximity order prox relative to address.
 func RandomAddressAt(self infinity.Address, prox int) infinity.Address {
+	return randomAddressAt(rand.Intn, self, prox)
+}
+
+// RandomAddressAtSeed generates an address at proximity order prox relative
+// to address, which is the same for the same seed.
+func RandomAddressAtSeed(seed int64, self infinity.Address, prox int) infinity.Address {
+	return randomAddressAt(rand.New(rand.NewSource(seed)).Intn, self, prox)
+}
+
+func randomAddressAt(intn func(n int) int, self infinity.Address, prox int) infinity.Address {
 	addr := make([]byte, len(self.Bytes()))
 	copy(addr, self.Bytes())
 	pos := -1
@@ -26,12 +38,12 @@ func RandomAddressAt(self infinity.Address, prox int) infinity.Address {
 		}
 		flipbyte := byte(1 << uint8(7-trans))
 		transbyteb := transbytea ^ byte(255)
-		randbyte := byte(rand.Intn(255))
+		randbyte := byte(intn(255))
 		addr[pos] = ((addr[pos] & transbytea) ^ flipbyte) | randbyte&transbyteb
 	}
 
 	for i := pos + 1; i < len(addr); i++ {
-		addr[i] = byte(rand.Intn(255))
+		addr[i] = byte(intn(255))
 	}
 
 	a := infinity.NewAddress(addr)
@@ -46,3 +58,38 @@ func RandomAddress() infinity.Address {
 	b := make([]byte, 32)
 	return RandomAddressAt(infinity.NewAddress(b), -1)
 }
+
+// RandomAddressSeed generates an address which is the same for the same seed.
+func RandomAddressSeed(seed int64) infinity.Address {
+	b := make([]byte, 32)
+	return RandomAddressAtSeed(seed, infinity.NewAddress(b), -1)
+}
+
+// Seed returns a seed derived from the name of the test, so that the
+// addresses generated with it are the same in every run of the test. The
+// seed is logged to make the failures easier to reproduce.
+func Seed(t testing.TB) int64 {
+	t.Helper()
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(t.Name()))
+	seed := int64(h.Sum64())
+	t.Logf("address seed %d", seed)
+	return seed
+}
+
+// ClusterAddresses generates n addresses spread evenly across the bins from
+// 0 to bins-1 relative to address, the i-th address is in the bin i%bins.
+// The addresses are the same for the same seed.
+func ClusterAddresses(seed int64, self infinity.Address, n, bins int) []infinity.Address {
+	if bins <= 0 || bins > len(self.Bytes())*8 {
+		panic(fmt.Sprintf("invalid number of bins %d", bins))
+	}
+
+	intn := rand.New(rand.NewSource(seed)).Intn
+	addrs := make([]infinity.Address, n)
+	for i := range addrs {
+		addrs[i] = randomAddressAt(intn, self, i%bins)
+	}
+	return addrs
+}
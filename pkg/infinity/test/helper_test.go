@@ -43,3 +43,47 @@ func TestRandomAddressAt(t *testing.T) {
 		}
 	}
 }
+
+// TestRandomAddressAtSeed checks that the same seed generates the same address
+// and that the different seeds generate different addresses.
+func TestRandomAddressAtSeed(t *testing.T) {
+	base := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+	seed := test.Seed(t)
+
+	addr := test.RandomAddressAtSeed(seed, base, 5)
+	if po := infinity.Proximity(base.Bytes(), addr.Bytes()); po != 5 {
+		t.Fatalf("got proximity order %d, want 5", po)
+	}
+	if a := test.RandomAddressAtSeed(seed, base, 5); !a.Equal(addr) {
+		t.Fatalf("got address %s, want %s", a, addr)
+	}
+	if a := test.RandomAddressAtSeed(seed+1, base, 5); a.Equal(addr) {
+		t.Fatalf("got the same address %s for a different seed", a)
+	}
+	if !test.RandomAddressSeed(seed).Equal(test.RandomAddressSeed(seed)) {
+		t.Fatal("got different addresses for the same seed")
+	}
+}
+
+// TestClusterAddresses checks that the cluster addresses are spread across the
+// bins and that they are the same for the same seed.
+func TestClusterAddresses(t *testing.T) {
+	base := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+	seed := test.Seed(t)
+
+	addrs := test.ClusterAddresses(seed, base, 20, 4)
+	if len(addrs) != 20 {
+		t.Fatalf("got %d addresses, want 20", len(addrs))
+	}
+	for i, addr := range addrs {
+		if po := infinity.Proximity(base.Bytes(), addr.Bytes()); po != uint8(i%4) {
+			t.Fatalf("address %d: got proximity order %d, want %d", i, po, i%4)
+		}
+	}
+
+	for i, addr := range test.ClusterAddresses(seed, base, 20, 4) {
+		if !addr.Equal(addrs[i]) {
+			t.Fatalf("address %d: got %s, want %s", i, addr, addrs[i])
+		}
+	}
+}
@@ -5,6 +5,8 @@
 package jsonhttp
 
 import (
+	"errors"
+	"net"
 	"net/http"
 
 	"resenje.org/web"
@@ -39,16 +41,22 @@ func NewMaxBodyBytesHandler(limit int64) func(http.Handler) http.Handler {
 }
 
 // HandleBodyReadError checks for particular errors and writes appropriate
-// response accordingly. If no known error is found, no response is written and
-// the function returns false.
+// response accordingly. A body which is too large is answered with Request
+// Entity Too Large response and a timed out read of the body with Request
+// Timeout response. The errors may be wrapped. If no known error is found, no
+// response is written and the function returns false.
 func HandleBodyReadError(err error, w http.ResponseWriter) (responded bool) {
-	if err == nil {
-		return false
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		// http.MaxBytesReader returns an unexported error,
+		// this is the only way to detect it
+		if e.Error() == "http: request body too large" {
+			RequestEntityTooLarge(w, nil)
+			return true
+		}
 	}
-	// http.MaxBytesReader returns an unexported error,
-	// this is the only way to detect it
-	if err.Error() == "http: request body too large" {
-		RequestEntityTooLarge(w, nil)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		RequestTimeout(w, nil)
 		return true
 	}
 	return false
@@ -6,8 +6,10 @@ package jsonhttp_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -194,3 +196,47 @@ func TestNewMaxBodyBytesHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleBodyReadError(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{
+			name: "no error",
+		},
+		{
+			name: "unknown error",
+			err:  errors.New("test error"),
+		},
+		{
+			name:     "wrapped body too large",
+			err:      fmt.Errorf("split: %w", errors.New("http: request body too large")),
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "wrapped timeout",
+			err:      fmt.Errorf("read: %w", &net.OpError{Op: "read", Err: timeoutError{}}),
+			wantCode: http.StatusRequestTimeout,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			responded := jsonhttp.HandleBodyReadError(tc.err, w)
+			if want := tc.wantCode != 0; responded != want {
+				t.Fatalf("got responded %v, want %v", responded, want)
+			}
+			if responded && w.Code != tc.wantCode {
+				t.Errorf("got http response code %d, want %d", w.Code, tc.wantCode)
+			}
+		})
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
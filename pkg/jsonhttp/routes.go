@@ -0,0 +1,80 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhttp
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// Route describes a route registered in a router. Routes without methods
+// accept requests with any method.
+type Route struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods,omitempty"`
+	Enabled bool     `json:"enabled"`
+}
+
+// ToggleHandler serves the requests of a route which can be disabled by the
+// node configuration. The requests are served by the Handler if the route is
+// enabled and by the Disabled handler otherwise.
+type ToggleHandler struct {
+	Handler  http.Handler
+	Disabled http.Handler
+	Enabled  func() bool
+}
+
+func (h *ToggleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.Enabled() {
+		h.Disabled.ServeHTTP(w, r)
+		return
+	}
+	h.Handler.ServeHTTP(w, r)
+}
+
+// Routes returns the routes registered in the router sorted by path. The
+// methods are known for the routes served by the MethodHandler and whether
+// the route is enabled for the routes served by the ToggleHandler.
+func Routes(router *mux.Router) ([]Route, error) {
+	var routes []Route
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		h := route.GetHandler()
+		if h == nil {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			// routes not matched by path are not listed
+			return nil
+		}
+
+		r := Route{
+			Path:    path,
+			Enabled: true,
+		}
+		if t, ok := h.(*ToggleHandler); ok {
+			r.Enabled = t.Enabled()
+			h = t.Handler
+		}
+		if m, ok := h.(MethodHandler); ok {
+			for method := range m {
+				r.Methods = append(r.Methods, method)
+			}
+			sort.Strings(r.Methods)
+		}
+		routes = append(routes, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+	return routes, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonhttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/yanhuangpai/voyager/pkg/jsonhttp"
+)
+
+func TestRoutes(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonhttp.OK(w, nil)
+	})
+	enabled := false
+
+	router := mux.NewRouter()
+	router.Handle("/b", jsonhttp.MethodHandler{
+		"POST": ok,
+		"GET":  ok,
+	})
+	router.Handle("/a", ok)
+	router.Handle("/c/{id}", &jsonhttp.ToggleHandler{
+		Handler: jsonhttp.MethodHandler{
+			"DELETE": ok,
+		},
+		Disabled: http.HandlerFunc(jsonhttp.NotFoundHandler),
+		Enabled:  func() bool { return enabled },
+	})
+
+	got, err := jsonhttp.Routes(router)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []jsonhttp.Route{
+		{Path: "/a", Enabled: true},
+		{Path: "/b", Methods: []string{"GET", "POST"}, Enabled: true},
+		{Path: "/c/{id}", Methods: []string{"DELETE"}, Enabled: false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got routes %+v, want %+v", got, want)
+	}
+
+	r := httptest.NewRequest(http.MethodDelete, "/c/1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("got status %v for disabled route, want %v", w.Code, http.StatusNotFound)
+	}
+
+	enabled = true
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %v for enabled route, want %v", w.Code, http.StatusOK)
+	}
+}
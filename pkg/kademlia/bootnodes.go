@@ -0,0 +1,178 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kademlia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+)
+
+const (
+	defaultBootnodeResolveInterval = 10 * time.Minute
+	bootnodeResolveTimeout         = 30 * time.Second
+	maxDNSAddrDepth                = 8 // how many nested dnsaddr records are followed
+)
+
+var errNoBootnodeAddresses = errors.New("no addresses resolved")
+
+// Resolver resolves the dnsaddr bootnode addresses from the DNS TXT records.
+type Resolver interface {
+	Resolve(ctx context.Context, addr ma.Multiaddr) ([]ma.Multiaddr, error)
+}
+
+var _ Resolver = (*madns.Resolver)(nil)
+
+// isDNSAddr returns true if the address is resolved from the dnsaddr TXT
+// records.
+func isDNSAddr(addr ma.Multiaddr) bool {
+	comp, _ := ma.SplitFirst(addr)
+	return comp != nil && comp.Protocol().Code == ma.P_DNSADDR
+}
+
+// resolveDNSAddr resolves the dnsaddr address, following the nested dnsaddr
+// records, into the addresses to connect to.
+func resolveDNSAddr(ctx context.Context, r Resolver, addr ma.Multiaddr, depth int) ([]ma.Multiaddr, error) {
+	if !isDNSAddr(addr) {
+		return []ma.Multiaddr{addr}, nil
+	}
+	if depth >= maxDNSAddrDepth {
+		return nil, fmt.Errorf("dnsaddr %s: too many nested records", addr)
+	}
+
+	addrs, err := r.Resolve(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("dns resolve address %s: %w", addr, err)
+	}
+
+	var resolved []ma.Multiaddr
+	for _, a := range addrs {
+		as, err := resolveDNSAddr(ctx, r, a, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, as...)
+	}
+	return resolved, nil
+}
+
+// resolveBootnodes resolves the dnsaddr bootnodes and caches their addresses,
+// so that the bootnode addresses rotated by the operators are picked up. The
+// previously resolved addresses are kept if the resolution fails.
+func (k *Kad) resolveBootnodes(ctx context.Context) {
+	k.bootnodesMu.Lock()
+	bootnodes := k.bootnodes
+	k.bootnodesMu.Unlock()
+
+	for _, addr := range bootnodes {
+		if !isDNSAddr(addr) {
+			continue
+		}
+		if _, err := k.resolveBootnode(ctx, addr); err != nil {
+			k.logger.Debugf("resolve bootnode %s: %v", addr, err)
+			k.logger.Warningf("cannot resolve bootnode %s, using the cached addresses", addr)
+		}
+	}
+}
+
+// resolveBootnode resolves the dnsaddr bootnode and replaces its cached
+// addresses with the resolved ones.
+func (k *Kad) resolveBootnode(ctx context.Context, addr ma.Multiaddr) ([]ma.Multiaddr, error) {
+	ctx, cancel := context.WithTimeout(ctx, bootnodeResolveTimeout)
+	defer cancel()
+
+	addrs, err := resolveDNSAddr(ctx, k.resolver, addr, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, errNoBootnodeAddresses
+	}
+
+	k.bootnodesMu.Lock()
+	k.resolvedBootnodes[addr.String()] = addrs
+	k.bootnodesMu.Unlock()
+
+	k.logger.Tracef("resolved bootnode %s to %v", addr, addrs)
+	return addrs, nil
+}
+
+// bootnodeAddresses returns the addresses of the bootnode to connect to in a
+// random order. The addresses of a dnsaddr bootnode are taken from the cache,
+// resolving them only if they were not resolved yet.
+func (k *Kad) bootnodeAddresses(ctx context.Context, addr ma.Multiaddr) ([]ma.Multiaddr, error) {
+	if !isDNSAddr(addr) {
+		return []ma.Multiaddr{addr}, nil
+	}
+
+	k.bootnodesMu.Lock()
+	cached := k.resolvedBootnodes[addr.String()]
+	k.bootnodesMu.Unlock()
+
+	addrs := make([]ma.Multiaddr, len(cached))
+	copy(addrs, cached)
+	if len(addrs) == 0 {
+		var err error
+		if addrs, err = k.resolveBootnode(ctx, addr); err != nil {
+			return nil, err
+		}
+	}
+
+	rand.Shuffle(len(addrs), func(i, j int) {
+		addrs[i], addrs[j] = addrs[j], addrs[i]
+	})
+	return addrs, nil
+}
+
+// discoverBootnode calls f with every address of the bootnode until f
+// returns true or an error.
+func (k *Kad) discoverBootnode(ctx context.Context, addr ma.Multiaddr, f func(ma.Multiaddr) (bool, error)) (bool, error) {
+	addrs, err := k.bootnodeAddresses(ctx, addr)
+	if err != nil {
+		return false, err
+	}
+
+	for _, a := range addrs {
+		stopped, err := f(a)
+		if err != nil {
+			return false, fmt.Errorf("discover %s: %w", a, err)
+		}
+		if stopped {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// resolveBootnodesLoop resolves the dnsaddr bootnodes on start and then
+// periodically, until the kademlia is closed.
+func (k *Kad) resolveBootnodesLoop() {
+	defer k.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-k.quit
+		cancel()
+	}()
+	defer cancel()
+
+	ticker := time.NewTicker(k.resolveInterval)
+	defer ticker.Stop()
+
+	for {
+		k.resolveBootnodes(ctx)
+
+		select {
+		case <-k.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
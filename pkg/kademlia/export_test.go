@@ -4,8 +4,49 @@
 
 package kademlia
 
+import (
+	"context"
+	"strconv"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
 var (
 	TimeToRetry         = &timeToRetry
 	SaturationPeers     = &saturationPeers
 	OverSaturationPeers = &overSaturationPeers
 )
+
+func (k *Kad) ResolveBootnodes(ctx context.Context) {
+	k.resolveBootnodes(ctx)
+}
+
+func (k *Kad) BootnodeAddresses(ctx context.Context, addr ma.Multiaddr) ([]ma.Multiaddr, error) {
+	return k.bootnodeAddresses(ctx, addr)
+}
+
+// DialCandidates returns the known peers in the order they are dialed.
+func (k *Kad) DialCandidates() []infinity.Address {
+	var peers []infinity.Address
+	for _, c := range k.dialCandidates() {
+		peers = append(peers, c.peer)
+	}
+	return peers
+}
+
+// PeersMetrics returns the numbers of the known and the connected peers in
+// the bin reported by the metrics.
+func (k *Kad) PeersMetrics(bin uint8) (known, connected int) {
+	label := strconv.Itoa(int(bin))
+	known = int(testutil.ToFloat64(k.metrics.KnownPeers.WithLabelValues(label)))
+	connected = int(testutil.ToFloat64(k.metrics.ConnectedPeers.WithLabelValues(label)))
+	return known, connected
+}
+
+// DepthMetrics returns the depth and the number of the depth changes reported
+// by the metrics.
+func (k *Kad) DepthMetrics() (depth uint8, changes int) {
+	return uint8(testutil.ToFloat64(k.metrics.Depth)), int(testutil.ToFloat64(k.metrics.DepthChanges))
+}
@@ -11,14 +11,18 @@ import (
 	"fmt"
 	"math"
 	"math/bits"
+	"sort"
 	"sync"
 	"time"
 
 	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
 	"github.com/yanhuangpai/voyager/pkg/addressbook"
 	"github.com/yanhuangpai/voyager/pkg/discovery"
+	"github.com/yanhuangpai/voyager/pkg/events"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/kademlia/pslice"
+	"github.com/yanhuangpai/voyager/pkg/latency"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
 	"github.com/yanhuangpai/voyager/pkg/topology"
@@ -29,15 +33,23 @@ const (
 	maxConnAttempts        = 3 // when there is maxConnAttempts failed connect calls for a given peer it is considered non-connectable
 	maxBootnodeAttempts    = 3 // how many attempts to dial to bootnodes before giving up
 	defaultBitSuffixLength = 2 // the number of bits used to create pseudo addresses for balancing
+
+	protocolName      = "kademlia"
+	protocolVersion   = "1.0.0"
+	goodbyeStreamName = "goodbye"
 )
 
 var (
 	errMissingAddressBookEntry = errors.New("addressbook underlay entry not found")
 	errOverlayMismatch         = errors.New("overlay mismatch")
+	errPeerBackedOff           = errors.New("peer connection backed off")
 	timeToRetry                = 60 * time.Second
 	shortRetry                 = 30 * time.Second
 	saturationPeers            = 4
 	overSaturationPeers        = 16
+	goodbyeTimeout             = 5 * time.Second
+	bootnodePeersPerBin        = 4
+	bootnodePeerMaxAge         = 24 * time.Hour
 )
 
 type binSaturationFunc func(bin uint8, peers, connected *pslice.PSlice) (saturated bool, oversaturated bool)
@@ -52,6 +64,14 @@ type Options struct {
 	StandaloneMode  bool
 	BootnodeMode    bool
 	BitSuffixLength int
+	Streamer        p2p.Streamer // announces the departure to the neighbors on close
+	EventBus        *events.Bus  // publishes the peer and depth changes
+
+	// Resolver resolves the dnsaddr bootnodes, the DNS resolver if not set.
+	Resolver Resolver
+	// BootnodeResolveInterval is the period of resolving the dnsaddr
+	// bootnodes again, so that the rotated bootnode addresses are used.
+	BootnodeResolveInterval time.Duration
 }
 
 // Kad is the Smart Chain forwarding kademlia implementation.
@@ -60,25 +80,38 @@ type Kad struct {
 	discovery         discovery.Driver      // the discovery driver
 	addressBook       addressbook.Interface // address book to get underlays
 	p2p               p2p.Service           // p2p service to connect to nodes with
+	streamer          p2p.Streamer          // streamer to send the departure notices with
+	eventBus          *events.Bus           // bus to publish the peer and depth changes on
 	saturationFunc    binSaturationFunc     // pluggable saturation function
 	bitSuffixLength   int                   // additional depth of common prefix for bin
 	commonBinPrefixes [][]infinity.Address  // list of address prefixes for each bin
 	connectedPeers    *pslice.PSlice        // a slice of peers sorted and indexed by po, indexes kept in `bins`
 	knownPeers        *pslice.PSlice        // both are po aware slice of addresses
 	bootnodes         []ma.Multiaddr
-	depth             uint8                // current neighborhood depth
-	depthMu           sync.RWMutex         // protect depth changes
-	manageC           chan struct{}        // trigger the manage forever loop to connect to new peers
-	waitNext          map[string]retryInfo // sanction connections to a peer, key is overlay string and value is a retry information
-	waitNextMu        sync.Mutex           // synchronize map
+	bootnodesMu       sync.Mutex               // protect bootnodes changes
+	depth             uint8                    // current neighborhood depth
+	depthMu           sync.RWMutex             // protect depth changes
+	manageC           chan struct{}            // trigger the manage forever loop to connect to new peers
+	waitNext          map[string]retryInfo     // sanction connections to a peer, key is overlay string and value is a retry information
+	waitNextMu        sync.Mutex               // synchronize map
+	seen              map[string]time.Time     // time the peers were last seen connected, kept in bootnode mode only
+	seenMu            sync.Mutex               // protect seen changes
+	metadata          map[string]*peerMetadata // connection history of the peers, key is overlay bytes string
+	latency           latency.Estimator        // estimates the round-trip-time to the peers, may be nil
+	metadataMu        sync.Mutex               // protect metadata and latency
 	peerSig           []chan struct{}
 	peerSigMtx        sync.Mutex
 	logger            logging.Logger // logger
 	standalone        bool           // indicates whether the node is working in standalone mode
 	bootnode          bool           // indicates whether the node is working in bootnode mode
+	optionsMu         sync.RWMutex   // protect standalone, bootnode, bitSuffixLength and commonBinPrefixes changes
 	quit              chan struct{}  // quit channel
 	done              chan struct{}  // signal that `manage` has quit
 	wg                sync.WaitGroup
+	resolvedBootnodes map[string][]ma.Multiaddr // cached addresses of the dnsaddr bootnodes, protected by bootnodesMu
+	resolver          Resolver                  // resolver of the dnsaddr bootnodes
+	resolveInterval   time.Duration             // period of resolving the dnsaddr bootnodes
+	metrics           metrics
 }
 
 type retryInfo struct {
@@ -94,49 +127,64 @@ func New(base infinity.Address, addressbook addressbook.Interface, discovery dis
 	if o.BitSuffixLength == 0 {
 		o.BitSuffixLength = defaultBitSuffixLength
 	}
+	if o.Resolver == nil {
+		o.Resolver = madns.DefaultResolver
+	}
+	if o.BootnodeResolveInterval <= 0 {
+		o.BootnodeResolveInterval = defaultBootnodeResolveInterval
+	}
 
 	k := &Kad{
 		base:              base,
 		discovery:         discovery,
 		addressBook:       addressbook,
 		p2p:               p2p,
+		streamer:          o.Streamer,
+		eventBus:          o.EventBus,
 		saturationFunc:    o.SaturationFunc,
 		bitSuffixLength:   o.BitSuffixLength,
-		commonBinPrefixes: make([][]infinity.Address, int(infinity.MaxBins)),
+		commonBinPrefixes: generateCommonBinPrefixes(base, o.BitSuffixLength),
 		connectedPeers:    pslice.New(int(infinity.MaxBins)),
 		knownPeers:        pslice.New(int(infinity.MaxBins)),
 		bootnodes:         o.Bootnodes,
+		resolvedBootnodes: make(map[string][]ma.Multiaddr),
 		manageC:           make(chan struct{}, 1),
 		waitNext:          make(map[string]retryInfo),
+		seen:              make(map[string]time.Time),
+		metadata:          make(map[string]*peerMetadata),
 		logger:            logger,
 		standalone:        o.StandaloneMode,
 		bootnode:          o.BootnodeMode,
 		quit:              make(chan struct{}),
 		done:              make(chan struct{}),
 		wg:                sync.WaitGroup{},
+		resolver:          o.Resolver,
+		resolveInterval:   o.BootnodeResolveInterval,
+		metrics:           newMetrics(),
 	}
 
-	if k.bitSuffixLength > 0 {
-		k.generateCommonBinPrefixes()
+	for i := uint8(0); i < infinity.MaxBins; i++ {
+		k.updatePeersMetrics(i)
 	}
 
 	return k
 }
 
-func (k *Kad) generateCommonBinPrefixes() {
-	bitCombinationsCount := int(math.Pow(2, float64(k.bitSuffixLength)))
+// generateCommonBinPrefixes returns the pseudo addresses of each bin of the
+// base address for the bit suffix length.
+func generateCommonBinPrefixes(base infinity.Address, bitSuffixLength int) [][]infinity.Address {
+	binPrefixes := make([][]infinity.Address, int(infinity.MaxBins))
+	if bitSuffixLength <= 0 {
+		return binPrefixes
+	}
+
+	bitCombinationsCount := int(math.Pow(2, float64(bitSuffixLength)))
 	bitSufixes := make([]uint8, bitCombinationsCount)
 
 	for i := 0; i < bitCombinationsCount; i++ {
 		bitSufixes[i] = uint8(i)
 	}
 
-	addr := infinity.MustParseHexAddress(k.base.String())
-	addrBytes := addr.Bytes()
-	_ = addrBytes
-
-	binPrefixes := k.commonBinPrefixes
-
 	// copy base address
 	for i := range binPrefixes {
 		binPrefixes[i] = make([]infinity.Address, bitCombinationsCount)
@@ -144,8 +192,8 @@ func (k *Kad) generateCommonBinPrefixes() {
 
 	for i := range binPrefixes {
 		for j := range binPrefixes[i] {
-			pseudoAddrBytes := make([]byte, len(k.base.Bytes()))
-			copy(pseudoAddrBytes, k.base.Bytes())
+			pseudoAddrBytes := make([]byte, len(base.Bytes()))
+			copy(pseudoAddrBytes, base.Bytes())
 			binPrefixes[i][j] = infinity.NewAddress(pseudoAddrBytes)
 		}
 	}
@@ -163,8 +211,8 @@ func (k *Kad) generateCommonBinPrefixes() {
 			}
 
 			// set pseudo suffix
-			bitSuffixPos := k.bitSuffixLength - 1
-			for l := i + 1; l < i+k.bitSuffixLength+1; l++ {
+			bitSuffixPos := bitSuffixLength - 1
+			for l := i + 1; l < i+bitSuffixLength+1; l++ {
 				index, pos := l/8, l%8
 
 				if hasBit(bitSufixes[j], uint8(bitSuffixPos)) {
@@ -177,13 +225,36 @@ func (k *Kad) generateCommonBinPrefixes() {
 			}
 
 			// clear rest of the bits
-			for l := i + k.bitSuffixLength + 1; l < len(pseudoAddrBytes)*8; l++ {
+			for l := i + bitSuffixLength + 1; l < len(pseudoAddrBytes)*8; l++ {
 				index, pos := l/8, l%8
 				pseudoAddrBytes[index] = bits.Reverse8(clearBit(bits.Reverse8(pseudoAddrBytes[index]), uint8(pos)))
 			}
 		}
 	}
 
+	return binPrefixes
+}
+
+// isStandalone reports whether the node is working in standalone mode.
+func (k *Kad) isStandalone() bool {
+	k.optionsMu.RLock()
+	defer k.optionsMu.RUnlock()
+	return k.standalone
+}
+
+// isBootnode reports whether the node is working in bootnode mode.
+func (k *Kad) isBootnode() bool {
+	k.optionsMu.RLock()
+	defer k.optionsMu.RUnlock()
+	return k.bootnode
+}
+
+// binPrefixes returns the bit suffix length and the pseudo addresses of each
+// bin generated for it.
+func (k *Kad) binPrefixes() (int, [][]infinity.Address) {
+	k.optionsMu.RLock()
+	defer k.optionsMu.RUnlock()
+	return k.bitSuffixLength, k.commonBinPrefixes
 }
 
 // Clears the bit at pos in n.
@@ -245,18 +316,19 @@ func (k *Kad) manage() {
 				return
 			default:
 			}
-			if k.standalone {
+			if k.isStandalone() {
 				continue
 			}
 
 			// attempt balanced connection first
 			err := func() error {
+				bitSuffixLength, commonBinPrefixes := k.binPrefixes()
 				// for each bin
-				for i := range k.commonBinPrefixes {
+				for i := range commonBinPrefixes {
 					// and each pseudo address
 
-					for j := range k.commonBinPrefixes[i] {
-						pseudoAddr := k.commonBinPrefixes[i][j]
+					for j := range commonBinPrefixes[i] {
+						pseudoAddr := commonBinPrefixes[i][j]
 
 						closestConnectedPeer, err := closestPeer(k.connectedPeers, pseudoAddr, noopSanctionedPeerFn, infinity.ZeroAddress)
 						if err != nil {
@@ -271,7 +343,7 @@ func (k *Kad) manage() {
 						// check proximity
 						closestConnectedPO := infinity.ExtendedProximity(closestConnectedPeer.Bytes(), pseudoAddr.Bytes())
 
-						if int(closestConnectedPO) < i+k.bitSuffixLength+1 {
+						if int(closestConnectedPO) < i+bitSuffixLength+1 {
 							// connect to closest known peer which we haven't tried connecting
 							// to recently
 
@@ -291,7 +363,7 @@ func (k *Kad) manage() {
 
 							closestKnownPeerPO := infinity.ExtendedProximity(closestKnownPeer.Bytes(), pseudoAddr.Bytes())
 
-							if int(closestKnownPeerPO) < i+k.bitSuffixLength+1 {
+							if int(closestKnownPeerPO) < i+bitSuffixLength+1 {
 								continue
 							}
 
@@ -314,7 +386,7 @@ func (k *Kad) manage() {
 							err = k.connect(ctx, peer, ifiAddr.Underlay, po)
 							if err != nil {
 								if errors.Is(err, errOverlayMismatch) {
-									k.knownPeers.Remove(peer, po)
+									k.removeKnownPeer(peer, po)
 									if err := k.addressBook.Remove(peer); err != nil {
 										k.logger.Debugf("could not remove peer from addressbook: %s", peer.String())
 									}
@@ -328,6 +400,7 @@ func (k *Kad) manage() {
 									k.waitNext[peer.String()] = retryInfo{tryAfter: time.Now().Add(timeToRetry)}
 								}
 								k.waitNextMu.Unlock()
+								k.markUnreachable(peer)
 
 								// continue to next
 								continue
@@ -338,10 +411,12 @@ func (k *Kad) manage() {
 							k.waitNextMu.Unlock()
 
 							k.connectedPeers.Add(peer, po)
+							k.updatePeersMetrics(po)
+							k.markSeen(peer)
+							k.recordConnection(peer, directionOutbound)
+							k.eventBus.Publish(events.TopicPeerAdded, events.PeerData{Peer: peer, Bin: po})
 
-							k.depthMu.Lock()
-							k.depth = recalcDepth(k.connectedPeers)
-							k.depthMu.Unlock()
+							k.updateDepth()
 
 							k.logger.Debugf("connected to peer: %s for bin: %d", peer, i)
 
@@ -356,94 +431,100 @@ func (k *Kad) manage() {
 			if err != nil {
 				if errors.Is(err, errMissingAddressBookEntry) {
 					po := infinity.Proximity(k.base.Bytes(), peerToRemove.Bytes())
-					k.knownPeers.Remove(peerToRemove, po)
+					k.removeKnownPeer(peerToRemove, po)
 				} else {
 					k.logger.Errorf("kademlia manage loop iterator: %v", err)
 				}
 			}
 
-			err = k.knownPeers.EachBinRev(func(peer infinity.Address, po uint8) (bool, bool, error) {
+			// dial the peers deepening the depth or balancing the bins first
+			err = func() error {
+				saturatedBins := make(map[uint8]bool)
+				for _, c := range k.dialCandidates() {
+					peer, po := c.peer, c.po
 
-				if k.connectedPeers.Exists(peer) {
-					return false, false, nil
-				}
+					if saturatedBins[po] || k.connectedPeers.Exists(peer) {
+						continue
+					}
 
-				k.waitNextMu.Lock()
-				if next, ok := k.waitNext[peer.String()]; ok && time.Now().Before(next.tryAfter) {
+					k.waitNextMu.Lock()
+					if next, ok := k.waitNext[peer.String()]; ok && time.Now().Before(next.tryAfter) {
+						k.waitNextMu.Unlock()
+						continue
+					}
 					k.waitNextMu.Unlock()
-					return false, false, nil
-				}
-				k.waitNextMu.Unlock()
 
-				currentDepth := k.NeighborhoodDepth()
-				if saturated, _ := k.saturationFunc(po, k.knownPeers, k.connectedPeers); saturated {
-					return false, true, nil // bin is saturated, skip to next bin
-				}
+					currentDepth := k.NeighborhoodDepth()
+					if saturated, _ := k.saturationFunc(po, k.knownPeers, k.connectedPeers); saturated {
+						saturatedBins[po] = true // bin is saturated, skip its other peers
+						continue
+					}
 
-				ifiAddr, err := k.addressBook.Get(peer)
-				if err != nil {
-					if err == addressbook.ErrNotFound {
-						k.logger.Debugf("failed to get address book entry for peer: %s", peer.String())
-						peerToRemove = peer
-						return false, false, errMissingAddressBookEntry
+					ifiAddr, err := k.addressBook.Get(peer)
+					if err != nil {
+						if err == addressbook.ErrNotFound {
+							k.logger.Debugf("failed to get address book entry for peer: %s", peer.String())
+							peerToRemove = peer
+							return errMissingAddressBookEntry
+						}
+						// either a peer is not known in the address book, in which case it
+						// should be removed, or that some severe I/O problem is at hand
+						return err
 					}
-					// either a peer is not known in the address book, in which case it
-					// should be removed, or that some severe I/O problem is at hand
-					return false, false, err
-				}
 
-				err = k.connect(ctx, peer, ifiAddr.Underlay, po)
-				if err != nil {
-					if errors.Is(err, errOverlayMismatch) {
-						k.knownPeers.Remove(peer, po)
-						if err := k.addressBook.Remove(peer); err != nil {
-							k.logger.Debugf("could not remove peer from addressbook: %s", peer.String())
+					err = k.connect(ctx, peer, ifiAddr.Underlay, po)
+					if err != nil {
+						if errors.Is(err, errOverlayMismatch) {
+							k.removeKnownPeer(peer, po)
+							if err := k.addressBook.Remove(peer); err != nil {
+								k.logger.Debugf("could not remove peer from addressbook: %s", peer.String())
+							}
+						}
+						k.logger.Debugf("peer not reachable from kademlia %s: %v", ifiAddr.String(), err)
+						k.logger.Warningf("peer not reachable when attempting to connect")
+
+						k.waitNextMu.Lock()
+						if _, ok := k.waitNext[peer.String()]; !ok {
+							// don't override existing data in the map
+							k.waitNext[peer.String()] = retryInfo{tryAfter: time.Now().Add(timeToRetry)}
 						}
+						k.waitNextMu.Unlock()
+						k.markUnreachable(peer)
+
+						// continue to next
+						continue
 					}
-					k.logger.Debugf("peer not reachable from kademlia %s: %v", ifiAddr.String(), err)
-					k.logger.Warningf("peer not reachable when attempting to connect")
 
 					k.waitNextMu.Lock()
-					if _, ok := k.waitNext[peer.String()]; !ok {
-						// don't override existing data in the map
-						k.waitNext[peer.String()] = retryInfo{tryAfter: time.Now().Add(timeToRetry)}
-					}
+					k.waitNext[peer.String()] = retryInfo{tryAfter: time.Now().Add(shortRetry)}
 					k.waitNextMu.Unlock()
 
-					// continue to next
-					return false, false, nil
-				}
-
-				k.waitNextMu.Lock()
-				k.waitNext[peer.String()] = retryInfo{tryAfter: time.Now().Add(shortRetry)}
-				k.waitNextMu.Unlock()
+					k.connectedPeers.Add(peer, po)
+					k.updatePeersMetrics(po)
+					k.markSeen(peer)
+					k.recordConnection(peer, directionOutbound)
+					k.eventBus.Publish(events.TopicPeerAdded, events.PeerData{Peer: peer, Bin: po})
 
-				k.connectedPeers.Add(peer, po)
+					k.updateDepth()
 
-				k.depthMu.Lock()
-				k.depth = recalcDepth(k.connectedPeers)
-				k.depthMu.Unlock()
+					k.logger.Debugf("connected to peer: %s old depth: %d new depth: %d", peer, currentDepth, k.NeighborhoodDepth())
 
-				k.logger.Debugf("connected to peer: %s old depth: %d new depth: %d", peer, currentDepth, k.NeighborhoodDepth())
+					k.notifyPeerSig()
 
-				k.notifyPeerSig()
-
-				select {
-				case <-k.quit:
-					return true, false, nil
-				default:
+					select {
+					case <-k.quit:
+						return nil
+					default:
+					}
 				}
-
-				// the bin could be saturated or not, so a decision cannot
-				// be made before checking the next peer, so we iterate to next
-				return false, false, nil
-			})
+				return nil
+			}()
 			k.logger.Tracef("kademlia iterator took %s to finish", time.Since(start))
 
 			if err != nil {
 				if errors.Is(err, errMissingAddressBookEntry) {
 					po := infinity.Proximity(k.base.Bytes(), peerToRemove.Bytes())
-					k.knownPeers.Remove(peerToRemove, po)
+					k.removeKnownPeer(peerToRemove, po)
 				} else {
 					k.logger.Errorf("kademlia manage loop iterator: %v", err)
 				}
@@ -462,6 +543,11 @@ func (k *Kad) Start(ctx context.Context) error {
 	k.wg.Add(1)
 	go k.manage()
 
+	if !k.isStandalone() {
+		k.wg.Add(1)
+		go k.resolveBootnodesLoop()
+	}
+
 	addresses, err := k.addressBook.Overlays()
 	if err != nil {
 		return fmt.Errorf("addressbook overlays: %w", err)
@@ -470,19 +556,42 @@ func (k *Kad) Start(ctx context.Context) error {
 	return k.AddPeers(ctx, addresses...)
 }
 
+// SetBootnodes changes the bootnodes connected to when there are no
+// connected peers. The cached addresses of the removed dnsaddr bootnodes are
+// dropped.
+func (k *Kad) SetBootnodes(bootnodes []ma.Multiaddr) {
+	k.bootnodesMu.Lock()
+	defer k.bootnodesMu.Unlock()
+	k.bootnodes = bootnodes
+
+	keep := make(map[string]struct{}, len(bootnodes))
+	for _, b := range bootnodes {
+		keep[b.String()] = struct{}{}
+	}
+	for b := range k.resolvedBootnodes {
+		if _, ok := keep[b]; !ok {
+			delete(k.resolvedBootnodes, b)
+		}
+	}
+}
+
 func (k *Kad) connectBootnodes(ctx context.Context) {
+	k.bootnodesMu.Lock()
+	bootnodes := k.bootnodes
+	k.bootnodesMu.Unlock()
+
 	var attempts, connected int
-	var totalAttempts = maxBootnodeAttempts * len(k.bootnodes)
+	var totalAttempts = maxBootnodeAttempts * len(bootnodes)
 
 	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
-	for _, addr := range k.bootnodes {
+	for _, addr := range bootnodes {
 		if attempts >= totalAttempts || connected >= 3 {
 			return
 		}
 
-		if _, err := p2p.Discover(ctx, addr, func(addr ma.Multiaddr) (stop bool, err error) {
+		if _, err := k.discoverBootnode(ctx, addr, func(addr ma.Multiaddr) (stop bool, err error) {
 			k.logger.Tracef("connecting to bootnode %s", addr)
 			if attempts >= maxBootnodeAttempts {
 				return true, nil
@@ -499,9 +608,14 @@ func (k *Kad) connectBootnodes(ctx context.Context) {
 				return false, nil
 			}
 
+			if err := k.addressBook.PutSource(ifiAddress.Overlay, *ifiAddress, addressbook.SourceBootnode); err != nil {
+				k.logger.Debugf("could not record bootnode in addressbook %s: %v", ifiAddress.Overlay, err)
+			}
+
 			if err := k.connected(ctx, ifiAddress.Overlay); err != nil {
 				return false, err
 			}
+			k.recordConnection(ifiAddress.Overlay, directionOutbound)
 			k.logger.Tracef("connected to bootnode %s", addr)
 			connected++
 			// connect to max 3 bootnodes
@@ -545,30 +659,133 @@ func binSaturated(bin uint8, peers, connected *pslice.PSlice) (bool, bool) {
 
 // recalcDepth calculates and returns the kademlia depth.
 func recalcDepth(peers *pslice.PSlice) uint8 {
+	return depthOf(binSizes(peers))
+}
+
+// binSizes returns the number of the peers in each bin.
+func binSizes(peers *pslice.PSlice) []int {
+	sizes := make([]int, int(infinity.MaxBins))
+	_ = peers.EachBin(func(_ infinity.Address, po uint8) (bool, bool, error) {
+		sizes[po]++
+		return false, false, nil
+	})
+	return sizes
+}
+
+// depthOf calculates the kademlia depth from the number of the peers in each
+// bin. It is the bin of the nnLowWatermark-th closest peer, or the shallowest
+// empty bin if it is shallower.
+func depthOf(sizes []int) uint8 {
+	total := 0
+	for _, size := range sizes {
+		total += size
+	}
 	// handle edge case separately
-	if peers.Length() <= nnLowWatermark {
+	if total <= nnLowWatermark {
 		return 0
 	}
+
 	var (
-		peersCtr                     = uint(0)
-		candidate                    = uint8(0)
-		shallowestEmpty, noEmptyBins = peers.ShallowestEmpty()
+		peersCtr  = 0
+		candidate = 0
 	)
-
-	_ = peers.EachBin(func(_ infinity.Address, po uint8) (bool, bool, error) {
-		peersCtr++
+	for po := len(sizes) - 1; po >= 0; po-- {
+		peersCtr += sizes[po]
 		if peersCtr >= nnLowWatermark {
 			candidate = po
-			return true, false, nil
+			break
+		}
+	}
+
+	for po := 0; po < candidate; po++ {
+		if sizes[po] == 0 {
+			return uint8(po)
 		}
+	}
+	return uint8(candidate)
+}
+
+// Priorities of the known peers to dial, the lower are dialed first.
+const (
+	dialDeepensDepth = iota // the connection would deepen the depth
+	dialBalancesBin         // the connection would balance the bin of the peer
+	dialOther
+)
+
+type dialCandidate struct {
+	peer     infinity.Address
+	po       uint8
+	priority int
+}
+
+// dialCandidates returns the known peers which are not connected, the ones
+// which connection would deepen the neighborhood depth first, then the ones
+// closer than the connected peers to the pseudo addresses of their bins from
+// the commonBinPrefixes, and the others last. Within the same priority, the
+// peers in the shallower bins are first.
+func (k *Kad) dialCandidates() []dialCandidate {
+	var (
+		sizes      = binSizes(k.connectedPeers)
+		depth      = depthOf(sizes)
+		candidates []dialCandidate
+	)
+	bitSuffixLength, commonBinPrefixes := k.binPrefixes()
+	unbalanced := unbalancedPrefixes(k.connectedPeers, bitSuffixLength, commonBinPrefixes)
+
+	_ = k.knownPeers.EachBinRev(func(peer infinity.Address, po uint8) (bool, bool, error) {
+		if k.connectedPeers.Exists(peer) {
+			return false, false, nil
+		}
+
+		c := dialCandidate{peer: peer, po: po, priority: dialOther}
+		sizes[po]++
+		switch {
+		case depthOf(sizes) > depth:
+			c.priority = dialDeepensDepth
+		case balancesBin(peer, po, bitSuffixLength, unbalanced):
+			c.priority = dialBalancesBin
+		}
+		sizes[po]--
+
+		candidates = append(candidates, c)
 		return false, false, nil
 	})
 
-	if noEmptyBins || shallowestEmpty > candidate {
-		return candidate
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].priority < candidates[j].priority
+	})
+	return candidates
+}
+
+// unbalancedPrefixes returns the pseudo addresses of each bin from the
+// commonBinPrefixes without a connected peer close enough to them for the bin
+// to be balanced.
+func unbalancedPrefixes(connectedPeers *pslice.PSlice, bitSuffixLength int, commonBinPrefixes [][]infinity.Address) [][]infinity.Address {
+	unbalanced := make([][]infinity.Address, len(commonBinPrefixes))
+	for i := range commonBinPrefixes {
+		for _, pseudoAddr := range commonBinPrefixes[i] {
+			closestConnectedPeer, err := closestPeer(connectedPeers, pseudoAddr, noopSanctionedPeerFn, infinity.ZeroAddress)
+			if err == nil && int(infinity.ExtendedProximity(closestConnectedPeer.Bytes(), pseudoAddr.Bytes())) >= i+bitSuffixLength+1 {
+				continue
+			}
+			unbalanced[i] = append(unbalanced[i], pseudoAddr)
+		}
 	}
+	return unbalanced
+}
 
-	return shallowestEmpty
+// balancesBin reports whether the peer is close enough to one of the
+// unbalanced pseudo addresses of its bin to balance it.
+func balancesBin(peer infinity.Address, po uint8, bitSuffixLength int, unbalanced [][]infinity.Address) bool {
+	if int(po) >= len(unbalanced) {
+		return false
+	}
+	for _, pseudoAddr := range unbalanced[po] {
+		if int(infinity.ExtendedProximity(peer.Bytes(), pseudoAddr.Bytes())) >= int(po)+bitSuffixLength+1 {
+			return true
+		}
+	}
+	return false
 }
 
 // connect connects to a peer and gossips its address to our connected peers,
@@ -588,6 +805,7 @@ func (k *Kad) connect(ctx context.Context, peer infinity.Address, ma ma.Multiadd
 		}
 
 		k.logger.Debugf("could not connect to peer %s: %v", peer, err)
+		k.recordFailure(peer)
 		retryTime := time.Now().Add(timeToRetry)
 		var e *p2p.ConnectionBackoffError
 		k.waitNextMu.Lock()
@@ -601,6 +819,9 @@ func (k *Kad) connect(ctx context.Context, peer infinity.Address, ma ma.Multiadd
 			}
 
 			failedAttempts++
+			if err := k.addressBook.Failed(peer); err != nil {
+				k.logger.Debugf("could not record failed connection in addressbook %s: %v", peer, err)
+			}
 		}
 
 		if failedAttempts > maxConnAttempts {
@@ -654,6 +875,10 @@ func (k *Kad) announce(ctx context.Context, peer infinity.Address) error {
 		return false, false, nil
 	})
 
+	if k.isBootnode() {
+		addrs = k.bootnodeSample(peer)
+	}
+
 	if len(addrs) == 0 {
 		return nil
 	}
@@ -666,6 +891,86 @@ func (k *Kad) announce(ctx context.Context, peer infinity.Address) error {
 	return err
 }
 
+// bootnodeSample returns the peers which the bootnode announces to a newly
+// connected peer. Instead of all connected peers, at most bootnodePeersPerBin
+// healthy peers are selected for every bin of the new peer, so that it can
+// populate its routing table evenly. The connected peers are preferred,
+// followed by the known peers last seen most recently. The peers not seen
+// for bootnodePeerMaxAge or unreachable on the last dial are left out.
+func (k *Kad) bootnodeSample(peer infinity.Address) []infinity.Address {
+	type candidate struct {
+		addr infinity.Address
+		seen time.Time
+	}
+	bins := make([][]candidate, infinity.MaxBins)
+	now := time.Now()
+
+	k.seenMu.Lock()
+	_ = k.knownPeers.EachBinRev(func(addr infinity.Address, _ uint8) (bool, bool, error) {
+		if addr.Equal(peer) {
+			return false, false, nil
+		}
+		seen, ok := k.seen[addr.ByteString()]
+		if k.connectedPeers.Exists(addr) {
+			seen, ok = now, true
+		}
+		if !ok || now.Sub(seen) > bootnodePeerMaxAge {
+			return false, false, nil
+		}
+		po := infinity.Proximity(peer.Bytes(), addr.Bytes())
+		bins[po] = append(bins[po], candidate{addr: addr, seen: seen})
+		return false, false, nil
+	})
+	k.seenMu.Unlock()
+
+	var sample []infinity.Address
+	for _, b := range bins {
+		sort.SliceStable(b, func(i, j int) bool {
+			return b[i].seen.After(b[j].seen)
+		})
+		for i := 0; i < len(b) && i < bootnodePeersPerBin; i++ {
+			sample = append(sample, b[i].addr)
+		}
+	}
+	return sample
+}
+
+// markSeen records the time the peer was seen connected in bootnode mode.
+func (k *Kad) markSeen(peer infinity.Address) {
+	if !k.isBootnode() {
+		return
+	}
+	k.seenMu.Lock()
+	k.seen[peer.ByteString()] = time.Now()
+	k.seenMu.Unlock()
+}
+
+// markUnreachable forgets when the peer was seen in bootnode mode, so that it
+// is not announced until it is connected again.
+func (k *Kad) markUnreachable(peer infinity.Address) {
+	if !k.isBootnode() {
+		return
+	}
+	k.seenMu.Lock()
+	delete(k.seen, peer.ByteString())
+	k.seenMu.Unlock()
+}
+
+// removeKnownPeer removes the peer from the known peers and forgets its
+// connection history, which is kept only for the known peers.
+func (k *Kad) removeKnownPeer(peer infinity.Address, po uint8) {
+	k.knownPeers.Remove(peer, po)
+	k.updatePeersMetrics(po)
+
+	k.metadataMu.Lock()
+	delete(k.metadata, peer.ByteString())
+	k.metadataMu.Unlock()
+
+	k.seenMu.Lock()
+	delete(k.seen, peer.ByteString())
+	k.seenMu.Unlock()
+}
+
 // AddPeers adds peers to the knownPeers list.
 // This does not guarantee that a connection will immediately
 // be made to the peer.
@@ -677,6 +982,7 @@ func (k *Kad) AddPeers(ctx context.Context, addrs ...infinity.Address) error {
 
 		po := infinity.Proximity(k.base.Bytes(), addr.Bytes())
 		k.knownPeers.Add(addr, po)
+		k.updatePeersMetrics(po)
 	}
 
 	select {
@@ -688,7 +994,7 @@ func (k *Kad) AddPeers(ctx context.Context, addrs ...infinity.Address) error {
 }
 
 func (k *Kad) Pick(peer p2p.Peer) bool {
-	if k.bootnode {
+	if k.isBootnode() {
 		// shortcircuit for bootnode mode - always accept connections,
 		// at least until we find a better solution.
 		return true
@@ -699,9 +1005,28 @@ func (k *Kad) Pick(peer p2p.Peer) bool {
 	return !oversaturated
 }
 
+// Reconnect connects again to the peer which connection was lost, unless the
+// connections to the peer are backed off after the failed attempts. The failed
+// reconnection is backed off in the same way as the connections of the manage
+// loop.
+func (k *Kad) Reconnect(ctx context.Context, peer infinity.Address) error {
+	k.waitNextMu.Lock()
+	next, ok := k.waitNext[peer.String()]
+	k.waitNextMu.Unlock()
+	if ok && time.Now().Before(next.tryAfter) {
+		return p2p.NewConnectionBackoffError(errPeerBackedOff, next.tryAfter)
+	}
+
+	ifiAddr, err := k.addressBook.Get(peer)
+	if err != nil {
+		return fmt.Errorf("addressbook: %w", err)
+	}
+	return k.connect(ctx, peer, ifiAddr.Underlay, infinity.Proximity(k.base.Bytes(), peer.Bytes()))
+}
+
 // Connected is called when a peer has dialed in.
 func (k *Kad) Connected(ctx context.Context, peer p2p.Peer) error {
-	if !k.bootnode {
+	if !k.isBootnode() {
 		// don't run this check if we're a bootnode
 		po := infinity.Proximity(k.base.Bytes(), peer.Address.Bytes())
 		if _, overSaturated := k.saturationFunc(po, k.knownPeers, k.connectedPeers); overSaturated {
@@ -712,6 +1037,7 @@ func (k *Kad) Connected(ctx context.Context, peer p2p.Peer) error {
 	if err := k.connected(ctx, peer.Address); err != nil {
 		return err
 	}
+	k.recordConnection(peer.Address, directionInbound)
 
 	select {
 	case k.manageC <- struct{}{}:
@@ -730,14 +1056,15 @@ func (k *Kad) connected(ctx context.Context, addr infinity.Address) error {
 
 	k.knownPeers.Add(addr, po)
 	k.connectedPeers.Add(addr, po)
+	k.updatePeersMetrics(po)
+	k.markSeen(addr)
+	k.eventBus.Publish(events.TopicPeerAdded, events.PeerData{Peer: addr, Bin: po})
 
 	k.waitNextMu.Lock()
 	delete(k.waitNext, addr.String())
 	k.waitNextMu.Unlock()
 
-	k.depthMu.Lock()
-	k.depth = recalcDepth(k.connectedPeers)
-	k.depthMu.Unlock()
+	k.updateDepth()
 
 	k.notifyPeerSig()
 	return nil
@@ -748,14 +1075,16 @@ func (k *Kad) connected(ctx context.Context, addr infinity.Address) error {
 func (k *Kad) Disconnected(peer p2p.Peer) {
 	po := infinity.Proximity(k.base.Bytes(), peer.Address.Bytes())
 	k.connectedPeers.Remove(peer.Address, po)
+	k.updatePeersMetrics(po)
+	k.markSeen(peer.Address)
+	k.recordDisconnection(peer.Address)
+	k.eventBus.Publish(events.TopicPeerRemoved, events.PeerData{Peer: peer.Address, Bin: po})
 
 	k.waitNextMu.Lock()
 	k.waitNext[peer.Address.String()] = retryInfo{tryAfter: time.Now().Add(timeToRetry), failedAttempts: 0}
 	k.waitNextMu.Unlock()
 
-	k.depthMu.Lock()
-	k.depth = recalcDepth(k.connectedPeers)
-	k.depthMu.Unlock()
+	k.updateDepth()
 
 	select {
 	case k.manageC <- struct{}{}:
@@ -764,6 +1093,22 @@ func (k *Kad) Disconnected(peer p2p.Peer) {
 	k.notifyPeerSig()
 }
 
+// updateDepth recalculates the neighborhood depth from the connected peers
+// and publishes its change.
+func (k *Kad) updateDepth() {
+	k.depthMu.Lock()
+	old := k.depth
+	k.depth = recalcDepth(k.connectedPeers)
+	depth := k.depth
+	k.depthMu.Unlock()
+
+	k.metrics.Depth.Set(float64(depth))
+	if depth != old {
+		k.metrics.DepthChanges.Inc()
+		k.eventBus.Publish(events.TopicDepthChanged, events.DepthData{Depth: depth})
+	}
+}
+
 func (k *Kad) notifyPeerSig() {
 	k.peerSigMtx.Lock()
 	defer k.peerSigMtx.Unlock()
@@ -842,13 +1187,7 @@ func (k *Kad) ClosestPeer(addr infinity.Address, skipPeers ...infinity.Address)
 	var peersToDisconnect []infinity.Address
 	closest := k.base
 
-	err := k.connectedPeers.EachBinRev(func(peer infinity.Address, po uint8) (bool, bool, error) {
-		for _, a := range skipPeers {
-			if a.Equal(peer) {
-				return false, false, nil
-			}
-		}
-
+	err := k.EachPeerWith(func(peer infinity.Address, po uint8) (bool, bool, error) {
 		// kludge: hotfix for topology peer inconsistencies bug
 		if !isIn(peer, peers) {
 			a := infinity.NewAddress(peer.Bytes())
@@ -871,7 +1210,7 @@ func (k *Kad) ClosestPeer(addr infinity.Address, skipPeers ...infinity.Address)
 			// do nothing
 		}
 		return false, false, nil
-	})
+	}, topology.WithReverse(), topology.WithSkipPeers(skipPeers...))
 	if err != nil {
 		return infinity.Address{}, err
 	}
@@ -888,6 +1227,74 @@ func (k *Kad) ClosestPeer(addr infinity.Address, skipPeers ...infinity.Address)
 	return closest, nil
 }
 
+// ClosestPeers returns at most n closest connected peers to a given address,
+// sorted by distance from the closest one.
+func (k *Kad) ClosestPeers(addr infinity.Address, n int, skipPeers ...infinity.Address) ([]infinity.Address, error) {
+	if k.connectedPeers.Length() == 0 {
+		return nil, topology.ErrNotFound
+	}
+
+	peers := k.p2p.Peers()
+	var peersToDisconnect []infinity.Address
+	var closest []infinity.Address
+
+	err := k.EachPeerWith(func(peer infinity.Address, po uint8) (bool, bool, error) {
+		// kludge: hotfix for topology peer inconsistencies bug
+		if !isIn(peer, peers) {
+			a := infinity.NewAddress(peer.Bytes())
+			peersToDisconnect = append(peersToDisconnect, a)
+			return false, false, nil
+		}
+
+		var err error
+		closest, err = insertClosest(closest, n, addr, peer)
+		if err != nil {
+			return false, false, err
+		}
+		return false, false, nil
+	}, topology.WithReverse(), topology.WithSkipPeers(skipPeers...))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range peersToDisconnect {
+		k.Disconnected(p2p.Peer{Address: v})
+	}
+
+	if len(closest) == 0 {
+		return nil, topology.ErrNotFound
+	}
+
+	return closest, nil
+}
+
+// insertClosest inserts the peer into the peers sorted by distance to addr,
+// keeping at most n closest peers.
+func insertClosest(peers []infinity.Address, n int, addr, peer infinity.Address) ([]infinity.Address, error) {
+	i := len(peers)
+	for ; i > 0; i-- {
+		dcmp, err := infinity.DistanceCmp(addr.Bytes(), peers[i-1].Bytes(), peer.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		if dcmp >= 0 {
+			// previous peer is not farther from addr
+			break
+		}
+	}
+	if i >= n {
+		return peers, nil
+	}
+
+	peers = append(peers, infinity.Address{})
+	copy(peers[i+1:], peers[i:])
+	peers[i] = peer
+	if len(peers) > n {
+		peers = peers[:n]
+	}
+	return peers, nil
+}
+
 // EachPeer iterates from closest bin to farthest
 func (k *Kad) EachPeer(f topology.EachPeerFunc) error {
 	return k.connectedPeers.EachBin(f)
@@ -898,6 +1305,33 @@ func (k *Kad) EachPeerRev(f topology.EachPeerFunc) error {
 	return k.connectedPeers.EachBinRev(f)
 }
 
+// EachPeerWith iterates over the peers selected by the options, from the
+// closest bin to the farthest unless the order is reversed.
+func (k *Kad) EachPeerWith(f topology.EachPeerFunc, opts ...topology.EachPeerOption) error {
+	o := topology.NewEachPeerOptions(opts...)
+	peers := k.connectedPeers
+	if o.Known {
+		peers = k.knownPeers
+	}
+	f = o.Select(f, k.reachable)
+	if o.Reverse {
+		return peers.EachBinRev(f)
+	}
+	return peers.EachBin(f)
+}
+
+// reachable reports whether the peer is connected or it was not dialed
+// unsuccessfully on the last attempt.
+func (k *Kad) reachable(peer infinity.Address) bool {
+	if k.connectedPeers.Exists(peer) {
+		return true
+	}
+	k.waitNextMu.Lock()
+	defer k.waitNextMu.Unlock()
+	info, ok := k.waitNext[peer.String()]
+	return !ok || info.failedAttempts == 0
+}
+
 // SubscribePeersChange returns the channel that signals when the connected peers
 // set changes. Returned function is safe to be called multiple times.
 func (k *Kad) SubscribePeersChange() (c <-chan struct{}, unsubscribe func()) {
@@ -943,20 +1377,21 @@ func (k *Kad) IsBalanced(bin uint8) bool {
 	k.depthMu.RLock()
 	defer k.depthMu.RUnlock()
 
-	if int(bin) > len(k.commonBinPrefixes) {
+	bitSuffixLength, commonBinPrefixes := k.binPrefixes()
+	if int(bin) >= len(commonBinPrefixes) {
 		return false
 	}
 
 	// for each pseudo address
-	for i := range k.commonBinPrefixes[bin] {
-		pseudoAddr := k.commonBinPrefixes[bin][i]
+	for i := range commonBinPrefixes[bin] {
+		pseudoAddr := commonBinPrefixes[bin][i]
 		closestConnectedPeer, err := closestPeer(k.connectedPeers, pseudoAddr, noopSanctionedPeerFn, infinity.ZeroAddress)
 		if err != nil {
 			return false
 		}
 
 		closestConnectedPO := infinity.ExtendedProximity(closestConnectedPeer.Bytes(), pseudoAddr.Bytes())
-		if int(closestConnectedPO) < int(bin)+k.bitSuffixLength+1 {
+		if int(closestConnectedPO) < int(bin)+bitSuffixLength+1 {
 			return false
 		}
 	}
@@ -971,10 +1406,12 @@ func (k *Kad) MarshalJSON() ([]byte, error) {
 
 func (k *Kad) marshal(indent bool) ([]byte, error) {
 	type binInfo struct {
-		BinPopulation     uint     `json:"population"`
-		BinConnected      uint     `json:"connected"`
-		DisconnectedPeers []string `json:"disconnectedPeers"`
-		ConnectedPeers    []string `json:"connectedPeers"`
+		BinPopulation         uint       `json:"population"`
+		BinConnected          uint       `json:"connected"`
+		DisconnectedPeers     []string   `json:"disconnectedPeers"`
+		ConnectedPeers        []string   `json:"connectedPeers"`
+		DisconnectedPeersInfo []peerInfo `json:"disconnectedPeersInfo"`
+		ConnectedPeersInfo    []peerInfo `json:"connectedPeersInfo"`
 	}
 
 	type kadBins struct {
@@ -1014,6 +1451,7 @@ func (k *Kad) marshal(indent bool) ([]byte, error) {
 	_ = k.connectedPeers.EachBin(func(addr infinity.Address, po uint8) (bool, bool, error) {
 		infos[po].BinConnected++
 		infos[po].ConnectedPeers = append(infos[po].ConnectedPeers, addr.String())
+		infos[po].ConnectedPeersInfo = append(infos[po].ConnectedPeersInfo, k.peerInfo(addr))
 		return false, false, nil
 	})
 
@@ -1029,6 +1467,7 @@ func (k *Kad) marshal(indent bool) ([]byte, error) {
 		}
 
 		infos[po].DisconnectedPeers = append(infos[po].DisconnectedPeers, addr.String())
+		infos[po].DisconnectedPeersInfo = append(infos[po].DisconnectedPeersInfo, k.peerInfo(addr))
 		return false, false, nil
 	})
 
@@ -1074,10 +1513,72 @@ func (k *Kad) String() string {
 	return string(b)
 }
 
+// Protocol returns the protocol specification of the kademlia control
+// streams.
+func (k *Kad) Protocol() p2p.ProtocolSpec {
+	return p2p.ProtocolSpec{
+		Name:    protocolName,
+		Version: protocolVersion,
+		StreamSpecs: []p2p.StreamSpec{
+			{
+				Name:    goodbyeStreamName,
+				Handler: k.goodbyeHandler,
+			},
+		},
+	}
+}
+
+// goodbyeHandler handles the departure notice of a peer by disconnecting it,
+// so the depth is adjusted and the manage loop rebalances immediately.
+func (k *Kad) goodbyeHandler(_ context.Context, peer p2p.Peer, stream p2p.Stream) error {
+	_ = stream.Close()
+
+	k.logger.Debugf("kademlia: peer %s departing", peer.Address)
+	if err := k.p2p.Disconnect(peer.Address); err != nil && !errors.Is(err, p2p.ErrPeerNotFound) {
+		return fmt.Errorf("disconnect departing peer %s: %w", peer.Address, err)
+	}
+	return nil
+}
+
+// announceDeparture sends the departure notices to the connected peers in
+// the neighborhood, so they do not have to discover the absence of this node
+// through timeouts.
+func (k *Kad) announceDeparture() {
+	if k.streamer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), goodbyeTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	depth := k.NeighborhoodDepth()
+	_ = k.connectedPeers.EachBin(func(peer infinity.Address, po uint8) (bool, bool, error) {
+		if po < depth {
+			return true, false, nil
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			stream, err := k.streamer.NewStream(ctx, peer, nil, protocolName, protocolVersion, goodbyeStreamName)
+			if err != nil {
+				k.logger.Debugf("kademlia: announce departure to peer %s: %v", peer, err)
+				return
+			}
+			_ = stream.FullClose()
+		}()
+		return false, false, nil
+	})
+	wg.Wait()
+}
+
 // Close shuts down kademlia.
 func (k *Kad) Close() error {
 	k.logger.Info("kademlia shutting down")
 	close(k.quit)
+	k.announceDeparture()
 	cc := make(chan struct{})
 
 	go func() {
@@ -5,10 +5,14 @@
 package kademlia_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"math/rand"
+	"sort"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -19,6 +23,7 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/crypto"
 	voyagerCrypto "github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/discovery/mock"
+	"github.com/yanhuangpai/voyager/pkg/events"
 	"github.com/yanhuangpai/voyager/pkg/ifi"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/infinity/test"
@@ -27,6 +32,7 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
 	p2pmock "github.com/yanhuangpai/voyager/pkg/p2p/mock"
+	"github.com/yanhuangpai/voyager/pkg/p2p/streamtest"
 	mockstate "github.com/yanhuangpai/voyager/pkg/statestore/mock"
 	"github.com/yanhuangpai/voyager/pkg/topology"
 )
@@ -261,6 +267,62 @@ func TestManageWithBalancing(t *testing.T) {
 
 }
 
+// TestDialPriority tests that the known peers which connection would deepen
+// the depth or balance their bins are dialed before the other peers.
+func TestDialPriority(t *testing.T) {
+	t.Run("depth", func(t *testing.T) {
+		base, kad, ab, _, signer := newTestKademlia(nil, nil, kademlia.Options{BitSuffixLength: -1})
+
+		// the depth is 0 with two peers in bin 0 and one in bin 1
+		connectOne(t, signer, kad, ab, test.RandomAddressAt(base, 0), nil)
+		connectOne(t, signer, kad, ab, test.RandomAddressAt(base, 0), nil)
+		connectOne(t, signer, kad, ab, test.RandomAddressAt(base, 1), nil)
+
+		shallow := test.RandomAddressAt(base, 0)
+		deep := test.RandomAddressAt(base, 3) // deepens the depth to 1
+		addOne(t, signer, kad, ab, shallow)
+		addOne(t, signer, kad, ab, deep)
+
+		expectDialCandidates(t, kad, deep, shallow)
+	})
+
+	t.Run("balance", func(t *testing.T) {
+		base, kad, ab, _, signer := newTestKademlia(nil, nil, kademlia.Options{BitSuffixLength: 2})
+
+		// the peers of the bin 0 with the bits following the first one
+		// set to the pseudo address suffix and the other bits
+		binZero := func(b byte) infinity.Address {
+			a := make([]byte, len(base.Bytes()))
+			a[0] = (base.Bytes()[0]&0x80 ^ 0x80) | b
+			return infinity.NewAddress(a)
+		}
+
+		// balances the pseudo address with the 00 suffix
+		connectOne(t, signer, kad, ab, binZero(0x00), nil)
+
+		sameSuffix := binZero(0x10)  // 00 suffix
+		otherSuffix := binZero(0x20) // 01 suffix
+		addOne(t, signer, kad, ab, otherSuffix)
+		addOne(t, signer, kad, ab, sameSuffix)
+
+		expectDialCandidates(t, kad, otherSuffix, sameSuffix)
+	})
+}
+
+func expectDialCandidates(t *testing.T, kad *kademlia.Kad, want ...infinity.Address) {
+	t.Helper()
+
+	got := kad.DialCandidates()
+	if len(got) != len(want) {
+		t.Fatalf("got %d dial candidates, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Fatalf("got dial candidate %s at %d, want %s", got[i], i, want[i])
+		}
+	}
+}
+
 // TestBinSaturation tests the builtin binSaturated function.
 // the test must have two phases of adding peers so that the section
 // beyond the first flow control statement gets hit (if po >= depth),
@@ -432,6 +494,60 @@ func TestOversaturationBootnode(t *testing.T) {
 	}
 }
 
+// TestBootnodeAnnounce tests that a bootnode announces to a newly connected
+// peer at most the limited number of peers per bin of the new peer, and only
+// the peers which have been seen connected.
+func TestBootnodeAnnounce(t *testing.T) {
+	var (
+		_, kad, ab, disc, signer = newTestKademlia(nil, nil, kademlia.Options{BootnodeMode: true})
+		newcomer                 = test.RandomAddress()
+		farPeers                 []infinity.Address
+		closePeers               []infinity.Address
+	)
+
+	for i := 0; i < 6; i++ {
+		addr := test.RandomAddressAt(newcomer, 2)
+		connectOne(t, signer, kad, ab, addr, nil)
+		farPeers = append(farPeers, addr)
+	}
+	for i := 0; i < 2; i++ {
+		addr := test.RandomAddressAt(newcomer, 5)
+		connectOne(t, signer, kad, ab, addr, nil)
+		closePeers = append(closePeers, addr)
+	}
+	// the disconnected peer has been seen recently and is announced
+	kad.Disconnected(p2p.Peer{Address: closePeers[1]})
+	// the peer which has never been connected is not announced
+	if err := kad.AddPeers(context.Background(), test.RandomAddressAt(newcomer, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	disc.Reset()
+	connectOne(t, signer, kad, ab, newcomer, nil)
+
+	recs, ok := disc.AddresseeRecords(newcomer)
+	if !ok {
+		t.Fatal("got no records for the new peer")
+	}
+	if len(recs) != 6 {
+		t.Fatalf("got %d announced peers, want 6", len(recs))
+	}
+	var far int
+	for _, a := range farPeers {
+		if isIn(a, recs) {
+			far++
+		}
+	}
+	if far != 4 {
+		t.Fatalf("got %d announced peers in bin 2, want 4", far)
+	}
+	for _, a := range closePeers {
+		if !isIn(a, recs) {
+			t.Fatalf("peer %s not announced", a)
+		}
+	}
+}
+
 // TestNotifierHooks tests that the Connected/Disconnected hooks
 // result in the correct behavior once called.
 func TestNotifierHooks(t *testing.T) {
@@ -562,7 +678,7 @@ func TestAddressBookPrune(t *testing.T) {
 	}
 	defer kad.Close()
 
-	nonConnPeer, err := ifi.NewAddress(signer, nonConnectableAddress, test.RandomAddressAt(base, 1), 0)
+	nonConnPeer, err := ifi.NewAddress(signer, nonConnectableAddress, test.RandomAddressAt(base, 1), 0, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -714,6 +830,205 @@ func TestClosestPeer(t *testing.T) {
 	}
 }
 
+// TestClosestPeers tests that ClosestPeers method returns the closest
+// connected peers to a given address sorted by distance.
+func TestClosestPeers(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	base := infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000000") // base is 0000
+	connectedPeers := []p2p.Peer{
+		{
+			Address: infinity.MustParseHexAddress("8000000000000000000000000000000000000000000000000000000000000000"), // binary 1000 -> po 0 to base
+		},
+		{
+			Address: infinity.MustParseHexAddress("4000000000000000000000000000000000000000000000000000000000000000"), // binary 0100 -> po 1 to base
+		},
+		{
+			Address: infinity.MustParseHexAddress("6000000000000000000000000000000000000000000000000000000000000000"), // binary 0110 -> po 1 to base
+		},
+	}
+
+	disc := mock.NewDiscovery()
+	ab := addressbook.New(mockstate.NewStateStore())
+	p2ps := p2pmock.New(p2pmock.WithPeersFunc(func() []p2p.Peer {
+		return connectedPeers
+	}))
+
+	kad := kademlia.New(base, ab, disc, p2ps, logger, kademlia.Options{})
+	defer kad.Close()
+
+	for _, p := range connectedPeers {
+		if err := kad.Connected(context.Background(), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name          string
+		chunkAddress  infinity.Address // chunk address to test
+		n             int
+		skipPeers     []int // indexes of the connectedPeers slice
+		expectedPeers []int // indexes of the connectedPeers slice
+	}{
+		{
+			name:          "all",
+			chunkAddress:  infinity.MustParseHexAddress("7000000000000000000000000000000000000000000000000000000000000000"), // 0111
+			n:             3,
+			expectedPeers: []int{2, 1, 0},
+		},
+		{
+			name:          "more than connected",
+			chunkAddress:  infinity.MustParseHexAddress("c000000000000000000000000000000000000000000000000000000000000000"), // 1100
+			n:             10,
+			expectedPeers: []int{0, 1, 2},
+		},
+		{
+			name:          "limited",
+			chunkAddress:  infinity.MustParseHexAddress("5000000000000000000000000000000000000000000000000000000000000000"), // 0101
+			n:             2,
+			expectedPeers: []int{1, 2},
+		},
+		{
+			name:          "skip",
+			chunkAddress:  infinity.MustParseHexAddress("5000000000000000000000000000000000000000000000000000000000000000"), // 0101
+			n:             2,
+			skipPeers:     []int{1},
+			expectedPeers: []int{2, 0},
+		},
+		{
+			name:         "skip all",
+			chunkAddress: infinity.MustParseHexAddress("5000000000000000000000000000000000000000000000000000000000000000"), // 0101
+			n:            2,
+			skipPeers:    []int{0, 1, 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var skipPeers []infinity.Address
+			for _, i := range tc.skipPeers {
+				skipPeers = append(skipPeers, connectedPeers[i].Address)
+			}
+
+			peers, err := kad.ClosestPeers(tc.chunkAddress, tc.n, skipPeers...)
+			if len(tc.expectedPeers) == 0 {
+				if !errors.Is(err, topology.ErrNotFound) {
+					t.Fatalf("got error %v, want %v", err, topology.ErrNotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(peers) != len(tc.expectedPeers) {
+				t.Fatalf("got %v peers, want %v", len(peers), len(tc.expectedPeers))
+			}
+			for i, p := range peers {
+				if want := connectedPeers[tc.expectedPeers[i]].Address; !p.Equal(want) {
+					t.Errorf("got peer %v %s, want %s", i, p, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEachPeerWith(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	base := infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000000") // base is 0000
+	peers := []infinity.Address{
+		infinity.MustParseHexAddress("8000000000000000000000000000000000000000000000000000000000000000"), // binary 1000 -> po 0 to base
+		infinity.MustParseHexAddress("4000000000000000000000000000000000000000000000000000000000000000"), // binary 0100 -> po 1 to base
+		infinity.MustParseHexAddress("2000000000000000000000000000000000000000000000000000000000000000"), // binary 0010 -> po 2 to base
+		infinity.MustParseHexAddress("1000000000000000000000000000000000000000000000000000000000000000"), // binary 0001 -> po 3 to base
+		infinity.MustParseHexAddress("0800000000000000000000000000000000000000000000000000000000000000"), // binary 0000 1 -> po 4 to base, not connected
+	}
+
+	disc := mock.NewDiscovery()
+	ab := addressbook.New(mockstate.NewStateStore())
+	kad := kademlia.New(base, ab, disc, p2pmock.New(), logger, kademlia.Options{})
+	defer kad.Close()
+
+	for _, p := range peers[:4] {
+		if err := kad.Connected(context.Background(), p2p.Peer{Address: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := kad.AddPeers(context.Background(), peers[4]); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name          string
+		opts          []topology.EachPeerOption
+		expectedPeers []int // indexes of the peers slice
+	}{
+		{
+			name:          "connected",
+			expectedPeers: []int{3, 2, 1, 0},
+		},
+		{
+			name:          "reverse",
+			opts:          []topology.EachPeerOption{topology.WithReverse()},
+			expectedPeers: []int{0, 1, 2, 3},
+		},
+		{
+			name:          "known",
+			opts:          []topology.EachPeerOption{topology.WithKnownPeers()},
+			expectedPeers: []int{4, 3, 2, 1, 0},
+		},
+		{
+			name:          "known reachable",
+			opts:          []topology.EachPeerOption{topology.WithKnownPeers(), topology.WithReachableOnly()},
+			expectedPeers: []int{4, 3, 2, 1, 0},
+		},
+		{
+			name:          "bins",
+			opts:          []topology.EachPeerOption{topology.WithBins(1, 2)},
+			expectedPeers: []int{2, 1},
+		},
+		{
+			name:          "bins reverse",
+			opts:          []topology.EachPeerOption{topology.WithBins(1, 2), topology.WithReverse()},
+			expectedPeers: []int{1, 2},
+		},
+		{
+			name:          "max peers",
+			opts:          []topology.EachPeerOption{topology.WithMaxPeers(2)},
+			expectedPeers: []int{3, 2},
+		},
+		{
+			name:          "skip",
+			opts:          []topology.EachPeerOption{topology.WithSkipPeers(peers[2], peers[0]), topology.WithMaxPeers(2)},
+			expectedPeers: []int{3, 1},
+		},
+		{
+			name: "filter",
+			opts: []topology.EachPeerOption{topology.WithFilter(func(_ infinity.Address, po uint8) bool {
+				return po%2 == 0
+			}), topology.WithReverse()},
+			expectedPeers: []int{0, 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []infinity.Address
+			err := kad.EachPeerWith(func(addr infinity.Address, _ uint8) (bool, bool, error) {
+				got = append(got, addr)
+				return false, false, nil
+			}, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) != len(tc.expectedPeers) {
+				t.Fatalf("got %v peers, want %v", len(got), len(tc.expectedPeers))
+			}
+			for i, p := range got {
+				if want := peers[tc.expectedPeers[i]]; !p.Equal(want) {
+					t.Errorf("got peer %v %s, want %s", i, p, want)
+				}
+			}
+		})
+	}
+}
+
 func TestKademlia_SubscribePeersChange(t *testing.T) {
 	testSignal := func(t *testing.T, k *kademlia.Kad, c <-chan struct{}) {
 		t.Helper()
@@ -831,6 +1146,83 @@ func TestKademlia_SubscribePeersChange(t *testing.T) {
 	})
 }
 
+// TestEvents checks that the connected and disconnected peers are published
+// on the event bus.
+func TestEvents(t *testing.T) {
+	bus := events.New()
+	c, unsubscribe := bus.Subscribe(10, events.TopicPeerAdded, events.TopicPeerRemoved)
+	defer unsubscribe()
+
+	base, kad, ab, _, signer := newTestKademlia(nil, nil, kademlia.Options{EventBus: bus})
+	peer := test.RandomAddressAt(base, 3)
+
+	connectOne(t, signer, kad, ab, peer, nil)
+	removeOne(kad, peer)
+
+	for _, want := range []events.Topic{events.TopicPeerAdded, events.TopicPeerRemoved} {
+		select {
+		case e := <-c:
+			if e.Topic != want {
+				t.Fatalf("got topic %s, want %s", e.Topic, want)
+			}
+			d := e.Data.(events.PeerData)
+			if !d.Peer.Equal(peer) || d.Bin != 3 {
+				t.Fatalf("got peer %s in bin %d, want %s in bin 3", d.Peer, d.Bin, peer)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %s event", want)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	base, kad, ab, _, signer := newTestKademlia(nil, nil, kademlia.Options{})
+
+	checkPeers := func(t *testing.T, bin uint8, wantKnown, wantConnected int) {
+		t.Helper()
+		known, connected := kad.PeersMetrics(bin)
+		if known != wantKnown || connected != wantConnected {
+			t.Fatalf("bin %d: got %d known and %d connected peers, want %d and %d", bin, known, connected, wantKnown, wantConnected)
+		}
+	}
+
+	checkPeers(t, 3, 0, 0)
+
+	if err := kad.AddPeers(context.Background(), test.RandomAddressAt(base, 3)); err != nil {
+		t.Fatal(err)
+	}
+	checkPeers(t, 3, 1, 0)
+
+	peer := test.RandomAddressAt(base, 3)
+	connectOne(t, signer, kad, ab, peer, nil)
+	checkPeers(t, 3, 2, 1)
+
+	removeOne(kad, peer)
+	checkPeers(t, 3, 2, 0)
+
+	// every connection to the shallowest empty bin deepens the depth
+	var wantChanges int
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 2; j++ {
+			before := kad.NeighborhoodDepth()
+			connectOne(t, signer, kad, ab, test.RandomAddressAt(base, i), nil)
+			if kad.NeighborhoodDepth() != before {
+				wantChanges++
+			}
+		}
+	}
+	if wantChanges == 0 {
+		t.Fatal("depth not changed")
+	}
+	depth, changes := kad.DepthMetrics()
+	if depth != kad.NeighborhoodDepth() {
+		t.Fatalf("got depth %d, want %d", depth, kad.NeighborhoodDepth())
+	}
+	if changes != wantChanges {
+		t.Fatalf("got %d depth changes, want %d", changes, wantChanges)
+	}
+}
+
 func TestMarshal(t *testing.T) {
 	_, kad, ab, _, signer := newTestKademlia(nil, nil, kademlia.Options{})
 	if err := kad.Start(context.Background()); err != nil {
@@ -846,6 +1238,277 @@ func TestMarshal(t *testing.T) {
 	}
 }
 
+func TestMarshalPeerInfo(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	base := infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000000")         // base is 0000
+	connected := infinity.MustParseHexAddress("8000000000000000000000000000000000000000000000000000000000000000")    // binary 1000 -> po 0 to base
+	disconnected := infinity.MustParseHexAddress("4000000000000000000000000000000000000000000000000000000000000000") // binary 0100 -> po 1 to base
+
+	disc := mock.NewDiscovery()
+	ab := addressbook.New(mockstate.NewStateStore())
+	kad := kademlia.New(base, ab, disc, p2pmock.New(), logger, kademlia.Options{})
+	defer kad.Close()
+	kad.SetLatencyEstimator(latencyEstimator{connected.ByteString(): 20 * time.Millisecond})
+
+	for _, p := range []infinity.Address{connected, disconnected} {
+		if err := kad.Connected(context.Background(), p2p.Peer{Address: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kad.Disconnected(p2p.Peer{Address: disconnected})
+
+	b, err := kad.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type peerInfo struct {
+		Address    string     `json:"address"`
+		LastSeen   *time.Time `json:"lastSeen"`
+		Direction  string     `json:"direction"`
+		Reachable  bool       `json:"reachable"`
+		Latency    string     `json:"latency"`
+		Reputation *float64   `json:"reputation"`
+	}
+	var got struct {
+		Bins map[string]struct {
+			ConnectedPeersInfo    []peerInfo `json:"connectedPeersInfo"`
+			DisconnectedPeersInfo []peerInfo `json:"disconnectedPeersInfo"`
+		} `json:"bins"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, infos []peerInfo, addr infinity.Address, latency string) {
+		t.Helper()
+		if len(infos) != 1 {
+			t.Fatalf("got %v peers, want 1", len(infos))
+		}
+		i := infos[0]
+		if i.Address != addr.String() {
+			t.Errorf("got address %s, want %s", i.Address, addr)
+		}
+		if i.LastSeen == nil || time.Since(*i.LastSeen) > time.Minute {
+			t.Errorf("got last seen %v, want recent", i.LastSeen)
+		}
+		if i.Direction != "inbound" {
+			t.Errorf("got direction %q, want %q", i.Direction, "inbound")
+		}
+		if !i.Reachable {
+			t.Error("got unreachable peer")
+		}
+		if i.Latency != latency {
+			t.Errorf("got latency %q, want %q", i.Latency, latency)
+		}
+		if i.Reputation == nil || *i.Reputation != 1 {
+			t.Errorf("got reputation %v, want 1", i.Reputation)
+		}
+	}
+
+	t.Run("connected", func(t *testing.T) {
+		bin := got.Bins["bin_0"]
+		check(t, bin.ConnectedPeersInfo, connected, "20ms")
+		if len(bin.DisconnectedPeersInfo) != 0 {
+			t.Errorf("got %v disconnected peers, want none", len(bin.DisconnectedPeersInfo))
+		}
+	})
+
+	t.Run("disconnected", func(t *testing.T) {
+		bin := got.Bins["bin_1"]
+		check(t, bin.DisconnectedPeersInfo, disconnected, "")
+		if len(bin.ConnectedPeersInfo) != 0 {
+			t.Errorf("got %v connected peers, want none", len(bin.ConnectedPeersInfo))
+		}
+	})
+}
+
+type latencyEstimator map[string]time.Duration
+
+func (e latencyEstimator) RTT(peer infinity.Address) (time.Duration, bool) {
+	rtt, ok := e[peer.ByteString()]
+	return rtt, ok
+}
+
+func TestExportImportState(t *testing.T) {
+	base, kad, ab, _, signer := newTestKademlia(nil, nil, kademlia.Options{BootnodeMode: true, BitSuffixLength: 3})
+
+	var peers []infinity.Address
+	for po := 0; po < 8; po++ {
+		for i := 0; i < 10; i++ {
+			peer := test.RandomAddressAt(base, po)
+			peers = append(peers, peer)
+			// only some of the peers have an address book entry
+			if i%2 == 0 {
+				addOne(t, signer, kad, ab, peer)
+			}
+		}
+	}
+	if err := kad.AddPeers(context.Background(), peers...); err != nil {
+		t.Fatal(err)
+	}
+
+	exportState := func(t *testing.T, k *kademlia.Kad) kademlia.State {
+		t.Helper()
+		s, err := k.ExportState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Slice(s.Peers, func(i, j int) bool {
+			return bytes.Compare(s.Peers[i].Address.Bytes(), s.Peers[j].Address.Bytes()) < 0
+		})
+		return s
+	}
+
+	// the state is serializable
+	want := exportState(t, kad)
+	b, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state kademlia.State
+	if err := json.Unmarshal(b, &state); err != nil {
+		t.Fatal(err)
+	}
+
+	ab2 := addressbook.New(mockstate.NewStateStore())
+	kad2 := kademlia.New(base, ab2, mock.NewDiscovery(), p2pmock.New(), logging.New(ioutil.Discard, 0), kademlia.Options{})
+	if err := kad2.ImportState(state); err != nil {
+		t.Fatal(err)
+	}
+	got := exportState(t, kad2)
+	if got.Base.String() != want.Base.String() || got.BitSuffixLength != want.BitSuffixLength || got.StandaloneMode != want.StandaloneMode || got.BootnodeMode != want.BootnodeMode {
+		t.Fatalf("got state %+v, want %+v", got, want)
+	}
+	if len(got.Peers) != len(want.Peers) {
+		t.Fatalf("got %d peers, want %d", len(got.Peers), len(want.Peers))
+	}
+	for i, p := range got.Peers {
+		w := want.Peers[i]
+		if !p.Address.Equal(w.Address) || p.PO != w.PO {
+			t.Fatalf("got peer %s po %d, want %s po %d", p.Address, p.PO, w.Address, w.PO)
+		}
+		if (p.Ifi == nil) != (w.Ifi == nil) || p.Ifi != nil && !p.Ifi.Equal(w.Ifi) {
+			t.Fatalf("peer %s: got address %v, want %v", p.Address, p.Ifi, w.Ifi)
+		}
+		if w.Ifi != nil {
+			if _, err := ab2.Get(p.Address); err != nil {
+				t.Fatalf("peer %s: address book: %v", p.Address, err)
+			}
+		}
+	}
+
+	t.Run("base mismatch", func(t *testing.T) {
+		_, kad3, _, _, _ := newTestKademlia(nil, nil, kademlia.Options{})
+		if err := kad3.ImportState(state); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	peer := test.RandomAddressAt(base, 3)
+	ifiAddr, err := ab.Get(peers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name            string
+		peers           []kademlia.PeerState
+		bitSuffixLength int
+	}{
+		{
+			name:            "negative bit suffix length",
+			peers:           []kademlia.PeerState{{Address: peer, PO: 3}},
+			bitSuffixLength: -1,
+		},
+		{
+			name:            "bit suffix length out of range",
+			peers:           []kademlia.PeerState{{Address: peer, PO: 3}},
+			bitSuffixLength: 9,
+		},
+		{
+			name:  "invalid po",
+			peers: []kademlia.PeerState{{Address: peer, PO: 4}},
+		},
+		{
+			name:  "po out of range",
+			peers: []kademlia.PeerState{{Address: peer, PO: 255}},
+		},
+		{
+			name:  "base address",
+			peers: []kademlia.PeerState{{Address: base, PO: infinity.MaxPO}},
+		},
+		{
+			name:  "duplicate peer",
+			peers: []kademlia.PeerState{{Address: peer, PO: 3}, {Address: peer, PO: 3}},
+		},
+		{
+			name:  "address overlay mismatch",
+			peers: []kademlia.PeerState{{Address: peer, PO: 3, Ifi: ifiAddr}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			kad3 := kademlia.New(base, addressbook.New(mockstate.NewStateStore()), mock.NewDiscovery(), p2pmock.New(), logging.New(ioutil.Discard, 0), kademlia.Options{})
+			if err := kad3.ImportState(kademlia.State{Base: base, BitSuffixLength: tc.bitSuffixLength, Peers: tc.peers}); err == nil {
+				t.Fatal("expected error")
+			}
+			s, err := kad3.ExportState()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(s.Peers) != 0 {
+				t.Fatalf("got %d peers imported, want none", len(s.Peers))
+			}
+		})
+	}
+}
+
+// TestReconnect checks that the peers with the lost connection are connected
+// to again with the backoff of the failed connections.
+func TestReconnect(t *testing.T) {
+	var (
+		conns, failedConns       int32 // how many connect calls were made to the p2p mock
+		base, kad, ab, _, signer = newTestKademlia(&conns, &failedConns, kademlia.Options{})
+	)
+	defer kad.Close()
+
+	nonConnPeer, err := ifi.NewAddress(signer, nonConnectableAddress, test.RandomAddressAt(base, 1), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ab.Put(nonConnPeer.Overlay, *nonConnPeer); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := kad.Reconnect(context.Background(), nonConnPeer.Overlay); err == nil {
+		t.Fatal("expected error")
+	}
+	waitCounter(t, &failedConns, 1)
+
+	// the failed peer is not dialed again before the backoff expires
+	var backoffErr *p2p.ConnectionBackoffError
+	if err := kad.Reconnect(context.Background(), nonConnPeer.Overlay); !errors.As(err, &backoffErr) {
+		t.Fatalf("got error %v, want connection backoff error", err)
+	}
+	waitCounter(t, &failedConns, 0)
+
+	peer := test.RandomAddressAt(base, 2)
+	multiaddr, err := ma.NewMultiaddr(underlayBase + peer.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ifiAddr, err := ifi.NewAddress(signer, multiaddr, peer, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ab.Put(peer, *ifiAddr); err != nil {
+		t.Fatal(err)
+	}
+	if err := kad.Reconnect(context.Background(), peer); err != nil {
+		t.Fatal(err)
+	}
+	waitCounter(t, &conns, 1)
+}
+
 func TestStart(t *testing.T) {
 	var bootnodes []ma.Multiaddr
 	for i := 0; i < 10; i++ {
@@ -868,7 +1531,7 @@ func TestStart(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
-			ifiAddr, err := ifi.NewAddress(signer, multiaddr, peer, 0)
+			ifiAddr, err := ifi.NewAddress(signer, multiaddr, peer, 0, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -899,6 +1562,236 @@ func TestStart(t *testing.T) {
 	})
 }
 
+// TestBootnodesDNSAddr tests that the dnsaddr bootnodes are resolved, that
+// their addresses are updated when they are resolved again and that the
+// cached addresses are used when the resolution fails.
+func TestBootnodesDNSAddr(t *testing.T) {
+	bootnode, err := ma.NewMultiaddr("/dnsaddr/bootnode.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := ma.NewMultiaddr("/dnsaddr/nested.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	underlays := func(n int) []ma.Multiaddr {
+		var addrs []ma.Multiaddr
+		for i := 0; i < n; i++ {
+			addr, err := ma.NewMultiaddr(underlayBase + test.RandomAddress().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			addrs = append(addrs, addr)
+		}
+		return addrs
+	}
+
+	t.Run("connect", func(t *testing.T) {
+		resolver := newMockResolver()
+		resolver.set(bootnode, underlays(5), nil)
+
+		var conns, failedConns int32 // how many connect calls were made to the p2p mock
+		_, kad, _, _, _ := newTestKademlia(&conns, &failedConns, kademlia.Options{Bootnodes: []ma.Multiaddr{bootnode}, Resolver: resolver})
+		defer kad.Close()
+
+		if err := kad.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		waitCounter(t, &conns, 3)
+		waitCounter(t, &failedConns, 0)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		resolver := newMockResolver()
+		addrs := underlays(2)
+		resolver.set(bootnode, append([]ma.Multiaddr{nested}, addrs[0]), nil)
+		resolver.set(nested, addrs[1:], nil)
+
+		_, kad, _, _, _ := newTestKademlia(nil, nil, kademlia.Options{Bootnodes: []ma.Multiaddr{bootnode}, Resolver: resolver})
+
+		got, err := kad.BootnodeAddresses(context.Background(), bootnode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkAddresses(t, got, addrs)
+	})
+
+	t.Run("rotation and fallback", func(t *testing.T) {
+		resolver := newMockResolver()
+		_, kad, _, _, _ := newTestKademlia(nil, nil, kademlia.Options{Bootnodes: []ma.Multiaddr{bootnode}, Resolver: resolver})
+		ctx := context.Background()
+
+		first := underlays(2)
+		resolver.set(bootnode, first, nil)
+		kad.ResolveBootnodes(ctx)
+		got, err := kad.BootnodeAddresses(ctx, bootnode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkAddresses(t, got, first)
+
+		rotated := underlays(2)
+		resolver.set(bootnode, rotated, nil)
+		kad.ResolveBootnodes(ctx)
+		got, err = kad.BootnodeAddresses(ctx, bootnode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkAddresses(t, got, rotated)
+
+		resolver.set(bootnode, nil, errors.New("dns failure"))
+		kad.ResolveBootnodes(ctx)
+		got, err = kad.BootnodeAddresses(ctx, bootnode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkAddresses(t, got, rotated)
+
+		// the cache is dropped with the bootnode
+		kad.SetBootnodes(nil)
+		kad.SetBootnodes([]ma.Multiaddr{bootnode})
+		if _, err := kad.BootnodeAddresses(ctx, bootnode); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+type mockResolver struct {
+	mu      sync.Mutex
+	records map[string][]ma.Multiaddr
+	errs    map[string]error
+}
+
+func newMockResolver() *mockResolver {
+	return &mockResolver{
+		records: make(map[string][]ma.Multiaddr),
+		errs:    make(map[string]error),
+	}
+}
+
+func (r *mockResolver) set(addr ma.Multiaddr, addrs []ma.Multiaddr, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[addr.String()] = addrs
+	r.errs[addr.String()] = err
+}
+
+func (r *mockResolver) Resolve(_ context.Context, addr ma.Multiaddr) ([]ma.Multiaddr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.errs[addr.String()]; err != nil {
+		return nil, err
+	}
+	return r.records[addr.String()], nil
+}
+
+// checkAddresses checks that the addresses are the expected ones in any order.
+func checkAddresses(t *testing.T, got, want []ma.Multiaddr) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d addresses, want %d", len(got), len(want))
+	}
+	for _, w := range want {
+		var found bool
+		for _, g := range got {
+			if g.Equal(w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("address %s not found in %v", w, got)
+		}
+	}
+}
+
+// TestAnnounceDeparture tests that the departure of the node is announced
+// to the connected neighbors on close, and only to them.
+func TestAnnounceDeparture(t *testing.T) {
+	recorder := streamtest.New(streamtest.WithProtocols(p2p.ProtocolSpec{
+		Name:    "kademlia",
+		Version: "1.0.0",
+		StreamSpecs: []p2p.StreamSpec{
+			{
+				Name: "goodbye",
+				Handler: func(_ context.Context, _ p2p.Peer, stream p2p.Stream) error {
+					return stream.Close()
+				},
+			},
+		},
+	}))
+
+	var (
+		base, kad, ab, _, signer = newTestKademlia(nil, nil, kademlia.Options{Streamer: recorder})
+		outside                  = []infinity.Address{test.RandomAddressAt(base, 0), test.RandomAddressAt(base, 1)}
+		neighbors                = []infinity.Address{test.RandomAddressAt(base, 8), test.RandomAddressAt(base, 8)}
+	)
+
+	if err := kad.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, peer := range append(outside, neighbors...) {
+		connectOne(t, signer, kad, ab, peer, nil)
+	}
+	kDepth(t, kad, 2)
+
+	if err := kad.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, peer := range neighbors {
+		records, err := recorder.Records(peer, "kademlia", "1.0.0", "goodbye")
+		if err != nil {
+			t.Fatalf("neighbor %s: %v", peer, err)
+		}
+		if l := len(records); l != 1 {
+			t.Fatalf("neighbor %s: got %v records, want %v", peer, l, 1)
+		}
+	}
+	for _, peer := range outside {
+		if _, err := recorder.Records(peer, "kademlia", "1.0.0", "goodbye"); !errors.Is(err, streamtest.ErrRecordsNotFound) {
+			t.Fatalf("peer %s: got error %v, want %v", peer, err, streamtest.ErrRecordsNotFound)
+		}
+	}
+}
+
+// TestGoodbyeHandler tests that a departing peer is disconnected as soon as
+// its departure notice is received.
+func TestGoodbyeHandler(t *testing.T) {
+	var (
+		disconnected = make(chan infinity.Address, 1)
+		base         = test.RandomAddress()
+		peer         = test.RandomAddress()
+		ab           = addressbook.New(mockstate.NewStateStore())
+		p2ps         = p2pmock.New(p2pmock.WithDisconnectFunc(func(overlay infinity.Address) error {
+			disconnected <- overlay
+			return nil
+		}))
+		kad = kademlia.New(base, ab, mock.NewDiscovery(), p2ps, logging.New(ioutil.Discard, 0), kademlia.Options{})
+	)
+
+	recorder := streamtest.New(streamtest.WithProtocols(kad.Protocol()), streamtest.WithBaseAddr(peer))
+	stream, err := recorder.NewStream(context.Background(), base, nil, "kademlia", "1.0.0", "goodbye")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.FullClose(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-disconnected:
+		if !got.Equal(peer) {
+			t.Fatalf("got disconnected peer %s, want %s", got, peer)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("peer not disconnected")
+	}
+}
+
 func newTestKademlia(connCounter, failedConnCounter *int32, kadOpts kademlia.Options) (infinity.Address, *kademlia.Kad, addressbook.Interface, *mock.Discovery, voyagerCrypto.Signer) {
 	var (
 		pk, _  = crypto.GenerateSecp256k1Key()                       // random private key
@@ -936,7 +1829,7 @@ func p2pMock(ab addressbook.Interface, signer voyagerCrypto.Signer, counter, fai
 		}
 
 		address := test.RandomAddress()
-		ifiAddr, err := ifi.NewAddress(signer, addr, address, 0)
+		ifiAddr, err := ifi.NewAddress(signer, addr, address, 0, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -964,7 +1857,7 @@ func connectOne(t *testing.T, signer voyagerCrypto.Signer, k *kademlia.Kad, ab a
 		t.Fatal(err)
 	}
 
-	ifiAddr, err := ifi.NewAddress(signer, multiaddr, peer, 0)
+	ifiAddr, err := ifi.NewAddress(signer, multiaddr, peer, 0, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -985,7 +1878,7 @@ func addOne(t *testing.T, signer voyagerCrypto.Signer, k *kademlia.Kad, ab addre
 	if err != nil {
 		t.Fatal(err)
 	}
-	ifiAddr, err := ifi.NewAddress(signer, multiaddr, peer, 0)
+	ifiAddr, err := ifi.NewAddress(signer, multiaddr, peer, 0, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
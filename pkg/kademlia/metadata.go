@@ -0,0 +1,133 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kademlia
+
+import (
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/latency"
+)
+
+// Directions of the peer connections.
+const (
+	directionInbound  = "inbound"
+	directionOutbound = "outbound"
+)
+
+// peerMetadata is the connection history of a peer.
+type peerMetadata struct {
+	direction   string    // direction of the last connection
+	lastSeen    time.Time // time the peer was last connected or disconnected
+	connections uint64    // number of the connections established
+	failures    uint64    // number of the failed dials
+	lastSeenSet bool      // lastSeen is known, from a connection or the address book
+}
+
+// peerInfo is the metadata of a peer in the JSON representation of
+// Kademlia.
+type peerInfo struct {
+	Address    string     `json:"address"`
+	LastSeen   *time.Time `json:"lastSeen,omitempty"`   // time the peer was last connected or disconnected
+	Direction  string     `json:"direction,omitempty"`  // direction of the last connection
+	Reachable  bool       `json:"reachable"`            // false if the last dial failed
+	Latency    string     `json:"latency,omitempty"`    // moving average of the round-trip-time
+	Reputation *float64   `json:"reputation,omitempty"` // fraction of the successful connection attempts
+}
+
+// SetLatencyEstimator sets the estimator of the round-trip-time to the peers
+// reported in the JSON representation.
+func (k *Kad) SetLatencyEstimator(e latency.Estimator) {
+	k.metadataMu.Lock()
+	defer k.metadataMu.Unlock()
+	k.latency = e
+}
+
+// recordConnection records the established connection to the peer.
+func (k *Kad) recordConnection(peer infinity.Address, direction string) {
+	k.metadataMu.Lock()
+	defer k.metadataMu.Unlock()
+	m := k.peerMetadata(peer)
+	m.direction = direction
+	m.lastSeen = time.Now()
+	m.lastSeenSet = true
+	m.connections++
+}
+
+// recordDisconnection records the time the peer disconnected.
+func (k *Kad) recordDisconnection(peer infinity.Address) {
+	k.metadataMu.Lock()
+	defer k.metadataMu.Unlock()
+	m := k.peerMetadata(peer)
+	m.lastSeen = time.Now()
+	m.lastSeenSet = true
+}
+
+// recordFailure records the failed dial to the peer.
+func (k *Kad) recordFailure(peer infinity.Address) {
+	k.metadataMu.Lock()
+	defer k.metadataMu.Unlock()
+	k.peerMetadata(peer).failures++
+}
+
+// peerMetadata returns the metadata of the peer, created if it does not
+// exist. It must be called with the metadata lock held.
+func (k *Kad) peerMetadata(peer infinity.Address) *peerMetadata {
+	m, ok := k.metadata[peer.ByteString()]
+	if !ok {
+		m = new(peerMetadata)
+		k.metadata[peer.ByteString()] = m
+	}
+	return m
+}
+
+// peerInfo returns the metadata of the peer for the JSON representation. The
+// time the peer was last connected is taken from the address book once if the
+// peer was not seen since the start.
+func (k *Kad) peerInfo(peer infinity.Address) peerInfo {
+	i := peerInfo{
+		Address:   peer.String(),
+		Reachable: k.reachable(peer),
+	}
+
+	k.metadataMu.Lock()
+	m, ok := k.metadata[peer.ByteString()]
+	lastSeenSet := ok && m.lastSeenSet
+	k.metadataMu.Unlock()
+
+	if !lastSeenSet {
+		var lastConnected time.Time
+		if e, err := k.addressBook.Entry(peer); err == nil {
+			lastConnected = e.LastConnected
+		}
+		k.metadataMu.Lock()
+		if m = k.peerMetadata(peer); !m.lastSeenSet {
+			m.lastSeen = lastConnected
+			m.lastSeenSet = true
+		}
+		k.metadataMu.Unlock()
+	}
+
+	k.metadataMu.Lock()
+	m = k.peerMetadata(peer)
+	i.Direction = m.direction
+	if !m.lastSeen.IsZero() {
+		lastSeen := m.lastSeen
+		i.LastSeen = &lastSeen
+	}
+	if attempts := m.connections + m.failures; attempts > 0 {
+		reputation := float64(m.connections) / float64(attempts)
+		i.Reputation = &reputation
+	}
+	estimator := k.latency
+	k.metadataMu.Unlock()
+
+	if estimator != nil {
+		if rtt, ok := estimator.RTT(peer); ok {
+			i.Latency = rtt.String()
+		}
+	}
+	return i
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kademlia
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	m "github.com/yanhuangpai/voyager/pkg/metrics"
+)
+
+type metrics struct {
+	// all metrics fields must be exported
+	// to be able to return them by Metrics()
+	// using reflection
+
+	Depth          prometheus.Gauge
+	DepthChanges   prometheus.Counter
+	KnownPeers     *prometheus.GaugeVec
+	ConnectedPeers *prometheus.GaugeVec
+}
+
+func newMetrics() metrics {
+	subsystem := "kademlia"
+
+	return metrics{
+		Depth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "depth",
+			Help:      "Neighborhood depth.",
+		}),
+		DepthChanges: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "depth_changes_count",
+			Help:      "Number of the neighborhood depth changes.",
+		}),
+		KnownPeers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "known_peers",
+			Help:      "Number of the known peers in the bin.",
+		}, []string{"bin"}),
+		ConnectedPeers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "connected_peers",
+			Help:      "Number of the connected peers in the bin.",
+		}, []string{"bin"}),
+	}
+}
+
+// Metrics returns the metrics of the Kademlia topology.
+func (k *Kad) Metrics() []prometheus.Collector {
+	return m.PrometheusCollectorsFromFields(k.metrics)
+}
+
+// updatePeersMetrics sets the numbers of the known and the connected peers
+// in the bin, it is called on every change of the peers in the bin.
+func (k *Kad) updatePeersMetrics(bin uint8) {
+	label := strconv.Itoa(int(bin))
+	k.metrics.KnownPeers.WithLabelValues(label).Set(float64(k.knownPeers.BinSize(bin)))
+	k.metrics.ConnectedPeers.WithLabelValues(label).Set(float64(k.connectedPeers.BinSize(bin)))
+}
@@ -68,6 +68,10 @@ func (m *Mock) ClosestPeer(addr infinity.Address, skipPeers ...infinity.Address)
 	panic("not implemented") // TODO: Implement
 }
 
+func (m *Mock) ClosestPeers(addr infinity.Address, n int, skipPeers ...infinity.Address) (peerAddrs []infinity.Address, err error) {
+	panic("not implemented") // TODO: Implement
+}
+
 // EachPeer iterates from closest bin to farthest
 func (m *Mock) EachPeer(f topology.EachPeerFunc) error {
 	m.mtx.Lock()
@@ -101,6 +105,15 @@ func (m *Mock) EachPeerRev(f topology.EachPeerFunc) error {
 	return nil
 }
 
+// EachPeerWith iterates over the peers selected by the options
+func (m *Mock) EachPeerWith(f topology.EachPeerFunc, opts ...topology.EachPeerOption) error {
+	o := topology.NewEachPeerOptions(opts...)
+	if o.Reverse {
+		return m.EachPeerRev(o.Select(f, nil))
+	}
+	return m.EachPeer(o.Select(f, nil))
+}
+
 func (m *Mock) NeighborhoodDepth() uint8 {
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
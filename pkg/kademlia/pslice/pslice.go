@@ -102,6 +102,21 @@ func (s *PSlice) Length() int {
 	return len(s.peers)
 }
 
+// BinSize returns the number of peers in the bin.
+func (s *PSlice) BinSize(bin uint8) int {
+	s.RLock()
+	defer s.RUnlock()
+
+	if int(bin) >= len(s.bins) {
+		return 0
+	}
+	end := uint(len(s.peers))
+	if int(bin)+1 < len(s.bins) {
+		end = s.bins[bin+1]
+	}
+	return int(end - s.bins[bin])
+}
+
 // ShallowestEmpty returns the shallowest empty bin if one exists.
 // If such bin does not exists, returns true as bool value.
 func (s *PSlice) ShallowestEmpty() (bin uint8, none bool) {
@@ -163,6 +178,41 @@ func (s *PSlice) Add(addr infinity.Address, po uint8) {
 	s.bins = bins
 }
 
+// AddBatch adds the peers at their proximity orders at once, without copying
+// the whole slice for every peer as Add does. The proximity order of every
+// address is at the same index in pos.
+func (s *PSlice) AddBatch(addrs []infinity.Address, pos []uint8) {
+	s.Lock()
+	defer s.Unlock()
+
+	known := make(map[string]struct{}, len(s.peers)+len(addrs))
+	for _, a := range s.peers {
+		known[a.ByteString()] = struct{}{}
+	}
+	added := make([][]infinity.Address, len(s.bins))
+	for i, a := range addrs {
+		if _, ok := known[a.ByteString()]; ok {
+			continue
+		}
+		known[a.ByteString()] = struct{}{}
+		added[pos[i]] = append(added[pos[i]], a)
+	}
+
+	peers := make([]infinity.Address, 0, len(known))
+	bins := make([]uint, len(s.bins))
+	for po := range s.bins {
+		bins[po] = uint(len(peers))
+		end := uint(len(s.peers))
+		if po+1 < len(s.bins) {
+			end = s.bins[po+1]
+		}
+		peers = append(peers, added[po]...)
+		peers = append(peers, s.peers[s.bins[po]:end]...)
+	}
+	s.peers = peers
+	s.bins = bins
+}
+
 // Remove a peer at a certain PO.
 func (s *PSlice) Remove(addr infinity.Address, po uint8) {
 	s.Lock()
@@ -89,6 +89,42 @@ func TestShallowestEmpty(t *testing.T) {
 }
 
 // TestAddRemove checks that the Add, Remove and Exists methods work as expected.
+// TestBinSize tests that the number of peers in the bins is reported
+// correctly.
+func TestBinSize(t *testing.T) {
+	var (
+		ps    = pslice.New(4)
+		base  = test.RandomAddress()
+		sizes = []int{2, 0, 3, 1}
+	)
+
+	check := func(t *testing.T, want []int) {
+		t.Helper()
+		for bin, size := range want {
+			if got := ps.BinSize(uint8(bin)); got != size {
+				t.Errorf("bin %d: got size %d, want %d", bin, got, size)
+			}
+		}
+		if got := ps.BinSize(uint8(len(want))); got != 0 {
+			t.Errorf("bin out of range: got size %d, want 0", got)
+		}
+	}
+
+	check(t, []int{0, 0, 0, 0})
+
+	var last infinity.Address
+	for bin, size := range sizes {
+		for i := 0; i < size; i++ {
+			last = test.RandomAddressAt(base, bin)
+			ps.Add(last, uint8(bin))
+		}
+	}
+	check(t, sizes)
+
+	ps.Remove(last, 3)
+	check(t, []int{2, 0, 3, 0})
+}
+
 func TestAddRemove(t *testing.T) {
 	var (
 		ps    = pslice.New(4)
@@ -178,6 +214,42 @@ func TestAddRemove(t *testing.T) {
 	chkNotExists(t, ps, peers...)
 }
 
+// TestAddBatch checks that the peers added at once are indexed as the peers
+// added one by one.
+func TestAddBatch(t *testing.T) {
+	var (
+		ps    = pslice.New(4)
+		base  = test.RandomAddress()
+		peers = make([]infinity.Address, 8)
+		pos   = make([]uint8, 8)
+	)
+
+	// 2 peers per bin
+	for i := 0; i < 8; i++ {
+		pos[i] = uint8(i / 2)
+		peers[i] = test.RandomAddressAt(base, int(pos[i]))
+	}
+
+	ps.Add(peers[2], 1)
+	ps.Add(peers[7], 3)
+
+	// the existing and duplicate peers are not added again
+	ps.AddBatch(append(peers[:6:6], peers[0], peers[7]), append(pos[:6:6], pos[0], pos[7]))
+	chkLen(t, ps, 7)
+	chkBins(t, ps, []uint{0, 2, 4, 6})
+	chkExists(t, ps, peers[:6]...)
+	chkExists(t, ps, peers[7])
+	chkNotExists(t, ps, peers[6])
+
+	// the peers are removed from their bins
+	for i := 0; i < 6; i++ {
+		ps.Remove(peers[i], pos[i])
+	}
+	chkLen(t, ps, 1)
+	chkBins(t, ps, []uint{0, 0, 0, 0})
+	chkExists(t, ps, peers[7])
+}
+
 // TestIteratorError checks that error propagation works correctly in the iterators.
 func TestIteratorError(t *testing.T) {
 	var (
@@ -0,0 +1,148 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kademlia
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/yanhuangpai/voyager/pkg/addressbook"
+	"github.com/yanhuangpai/voyager/pkg/ifi"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+// maxBitSuffixLength is the maximal bit suffix length of the imported state,
+// the number of the pseudo addresses for balancing grows exponentially with
+// it.
+const maxBitSuffixLength = 8
+
+var (
+	errInvalidPO              = errors.New("invalid proximity order")
+	errInvalidPeer            = errors.New("invalid peer")
+	errDuplicatePeer          = errors.New("duplicate peer")
+	errInvalidBitSuffixLength = errors.New("invalid bit suffix length")
+)
+
+// State is the topology state of Kademlia, the known peers and the
+// configuration, which can be exported from one instance and imported into
+// another one to construct a realistic topology without adding every peer.
+type State struct {
+	Base            infinity.Address `json:"base"`
+	BitSuffixLength int              `json:"bitSuffixLength"`
+	StandaloneMode  bool             `json:"standaloneMode"`
+	BootnodeMode    bool             `json:"bootnodeMode"`
+	Peers           []PeerState      `json:"peers"`
+}
+
+// PeerState is the known peer in the exported State, with its address book
+// entry if there is one.
+type PeerState struct {
+	Address infinity.Address `json:"address"`
+	PO      uint8            `json:"po"`
+	Ifi     *ifi.Address     `json:"ifi,omitempty"`
+}
+
+// ExportState returns the known peers with their address book entries and
+// the configuration of Kademlia. The connections to the peers are not a part
+// of the state.
+func (k *Kad) ExportState() (State, error) {
+	k.optionsMu.RLock()
+	s := State{
+		Base:            k.base,
+		BitSuffixLength: k.bitSuffixLength,
+		StandaloneMode:  k.standalone,
+		BootnodeMode:    k.bootnode,
+		Peers:           make([]PeerState, 0, k.knownPeers.Length()),
+	}
+	k.optionsMu.RUnlock()
+
+	err := k.knownPeers.EachBin(func(addr infinity.Address, po uint8) (bool, bool, error) {
+		ifiAddr, err := k.addressBook.Get(addr)
+		if err != nil && !errors.Is(err, addressbook.ErrNotFound) {
+			return true, false, fmt.Errorf("peer %s: %w", addr, err)
+		}
+		s.Peers = append(s.Peers, PeerState{Address: addr, PO: po, Ifi: ifiAddr})
+		return false, false, nil
+	})
+	if err != nil {
+		return State{}, err
+	}
+	return s, nil
+}
+
+// ImportState adds the known peers with their address book entries and
+// applies the configuration of the state exported from Kademlia with the same
+// base address. The whole state is validated before anything is changed. As
+// with AddPeers, the peers are connected to later by the manage loop.
+func (k *Kad) ImportState(s State) error {
+	if !s.Base.Equal(k.base) {
+		return errOverlayMismatch
+	}
+	if s.BitSuffixLength < 0 || s.BitSuffixLength > maxBitSuffixLength {
+		return fmt.Errorf("%w %d", errInvalidBitSuffixLength, s.BitSuffixLength)
+	}
+
+	peers := make([]PeerState, len(s.Peers))
+	copy(peers, s.Peers)
+	sort.SliceStable(peers, func(i, j int) bool {
+		if peers[i].PO != peers[j].PO {
+			return peers[i].PO < peers[j].PO
+		}
+		return bytes.Compare(peers[i].Address.Bytes(), peers[j].Address.Bytes()) < 0
+	})
+
+	addrs := make([]infinity.Address, len(peers))
+	pos := make([]uint8, len(peers))
+	for i, p := range peers {
+		if len(p.Address.Bytes()) != len(k.base.Bytes()) || p.Address.Equal(k.base) {
+			return fmt.Errorf("peer %s: %w", p.Address, errInvalidPeer)
+		}
+		if i > 0 && p.Address.Equal(peers[i-1].Address) {
+			return fmt.Errorf("peer %s: %w", p.Address, errDuplicatePeer)
+		}
+		if po := infinity.Proximity(k.base.Bytes(), p.Address.Bytes()); p.PO >= infinity.MaxBins || po != p.PO {
+			return fmt.Errorf("peer %s: %w %d, want %d", p.Address, errInvalidPO, p.PO, po)
+		}
+		if p.Ifi != nil && !p.Ifi.Overlay.Equal(p.Address) {
+			return fmt.Errorf("peer %s: %w", p.Address, errOverlayMismatch)
+		}
+		addrs[i], pos[i] = p.Address, p.PO
+	}
+
+	for _, p := range peers {
+		if p.Ifi == nil {
+			continue
+		}
+		if err := k.addressBook.Put(p.Address, *p.Ifi); err != nil {
+			return fmt.Errorf("peer %s: %w", p.Address, err)
+		}
+	}
+
+	k.optionsMu.Lock()
+	k.standalone = s.StandaloneMode
+	k.bootnode = s.BootnodeMode
+	if s.BitSuffixLength != k.bitSuffixLength {
+		k.bitSuffixLength = s.BitSuffixLength
+		k.commonBinPrefixes = generateCommonBinPrefixes(k.base, s.BitSuffixLength)
+	}
+	k.optionsMu.Unlock()
+
+	k.knownPeers.AddBatch(addrs, pos)
+	updated := make(map[uint8]struct{})
+	for _, po := range pos {
+		if _, ok := updated[po]; !ok {
+			updated[po] = struct{}{}
+			k.updatePeersMetrics(po)
+		}
+	}
+
+	select {
+	case k.manageC <- struct{}{}:
+	default:
+	}
+	return nil
+}
@@ -63,7 +63,10 @@ type kdfParams struct {
 
 func encryptKey(k *ecdsa.PrivateKey, password string) ([]byte, error) {
 	data := crypto.EncodeSecp256k1PrivateKey(k)
-	kc, err := encryptData(data, []byte(password))
+	p := []byte(password)
+	kc, err := encryptData(data, p)
+	wipe(data)
+	wipe(p)
 	if err != nil {
 		return nil, err
 	}
@@ -90,6 +93,7 @@ func decryptKey(data []byte, password string) (*ecdsa.PrivateKey, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer wipe(d)
 	return crypto.DecodeSecp256k1PrivateKey(d)
 }
 
@@ -102,6 +106,7 @@ func encryptData(data, password []byte) (*keyCripto, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer wipe(derivedKey)
 	encryptKey := derivedKey[:16]
 
 	iv := make([]byte, aes.BlockSize)
@@ -145,10 +150,13 @@ func decryptData(v keyCripto, password string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("hex decode cipher text: %s", err)
 	}
-	derivedKey, err := getKDFKey(v, []byte(password))
+	p := []byte(password)
+	derivedKey, err := getKDFKey(v, p)
+	wipe(p)
 	if err != nil {
 		return nil, err
 	}
+	defer wipe(derivedKey)
 	calculatedMAC := sha3.Sum256(append(derivedKey[16:32], cipherText...))
 	if !bytes.Equal(calculatedMAC[:], mac) {
 		return nil, keystore.ErrInvalidPassword
@@ -193,3 +201,20 @@ func getKDFKey(v keyCripto, password []byte) ([]byte, error) {
 		v.KDFParams.DKLen,
 	)
 }
+
+// wipe overwrites the plain text key material, so that it is not kept in
+// memory after it is no longer needed.
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// wipeKey overwrites the secret of the private key which is no longer needed.
+func wipeKey(k *ecdsa.PrivateKey) {
+	b := k.D.Bits()
+	for i := range b {
+		b[i] = 0
+	}
+	k.D.SetInt64(0)
+}
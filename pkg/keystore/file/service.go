@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 
 	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/keystore"
 )
 
 // Service is the file-based keystore.Service implementation.
@@ -63,7 +64,12 @@ func (s *Service) Key(name, password string) (pk *ecdsa.PrivateKey, created bool
 		if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
 			return nil, false, err
 		}
-		if err := ioutil.WriteFile(filename, d, 0600); err != nil {
+		tmp, err := writeTempFile(filename, d)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := os.Rename(tmp, filename); err != nil {
+			_ = os.Remove(tmp)
 			return nil, false, err
 		}
 		return pk, true, nil
@@ -76,6 +82,105 @@ func (s *Service) Key(name, password string) (pk *ecdsa.PrivateKey, created bool
 	return pk, false, nil
 }
 
+// SetPassword re-encrypts the key file with the new password. The new file is
+// written next to the old one and renamed over it, so the key is never lost if
+// the process is interrupted.
+func (s *Service) SetPassword(name, password, newPassword string) error {
+	return s.SetPasswords(password, newPassword, name)
+}
+
+// SetPasswords re-encrypts the key files with the names with the new
+// password. All keys are decrypted and their new files written next to the
+// old ones before any of them is renamed over the old one, so a wrong
+// password or a failed write leaves all keys unchanged.
+func (s *Service) SetPasswords(password, newPassword string, names ...string) (err error) {
+	tmps := make([]string, 0, len(names))
+	defer func() {
+		if err != nil {
+			for _, tmp := range tmps {
+				_ = os.Remove(tmp)
+			}
+		}
+	}()
+
+	for _, name := range names {
+		filename := s.keyFilename(name)
+
+		data, err := ioutil.ReadFile(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("read private key %s: %w", name, err)
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("%s: %w", name, keystore.ErrNotFound)
+		}
+
+		pk, err := decryptKey(data, password)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		d, err := encryptKey(pk, newPassword)
+		wipeKey(pk)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		tmp, err := writeTempFile(filename, d)
+		if err != nil {
+			return fmt.Errorf("write private key %s: %w", name, err)
+		}
+		tmps = append(tmps, tmp)
+	}
+
+	for i, name := range names {
+		if err := os.Rename(tmps[i], s.keyFilename(name)); err != nil {
+			return fmt.Errorf("rename private key %s: %w", name, err)
+		}
+	}
+	return syncDir(s.dir)
+}
+
+// writeTempFile writes the data to a new temporary file next to the file and
+// syncs it to the disk, so that it is not renamed over the file before it is
+// written. It returns the name of the temporary file.
+func writeTempFile(filename string, data []byte) (tmp string, err error) {
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// syncDir syncs the directory, so that the files renamed in it are kept after
+// a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
+}
+
 func (s *Service) keyFilename(name string) string {
 	return filepath.Join(s.dir, fmt.Sprintf("%s.key", name))
 }
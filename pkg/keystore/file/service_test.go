@@ -5,10 +5,13 @@
 package file_test
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/yanhuangpai/voyager/pkg/keystore"
 	"github.com/yanhuangpai/voyager/pkg/keystore/file"
 	"github.com/yanhuangpai/voyager/pkg/keystore/test"
 )
@@ -22,3 +25,45 @@ func TestService(t *testing.T) {
 
 	test.Service(t, file.New(dir))
 }
+
+func TestSetPasswords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ifi-keystore-file-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := file.New(dir)
+	for name, password := range map[string]string{"a": "pass", "b": "other pass"} {
+		if _, _, err := s.Key(name, password); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the key b is not encrypted with the password, so neither key changes
+	if err := s.SetPasswords("pass", "new pass", "a", "b"); !errors.Is(err, keystore.ErrInvalidPassword) {
+		t.Fatalf("got error %v, want %v", err, keystore.ErrInvalidPassword)
+	}
+	if _, _, err := s.Key("a", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	tmps, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmps) != 0 {
+		t.Fatalf("got temporary files %v", tmps)
+	}
+
+	if err := s.SetPassword("b", "other pass", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPasswords("pass", "new pass", "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, _, err := s.Key(name, "new pass"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
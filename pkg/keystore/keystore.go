@@ -13,6 +13,10 @@ import (
 // private key is stored is not valid.
 var ErrInvalidPassword = errors.New("invalid password")
 
+// ErrNotFound is returned when the private key with the specified name does
+// not exist.
+var ErrNotFound = errors.New("key not found")
+
 // Service for managing keystore private keys.
 type Service interface {
 	// Key returns the private key for a specified name that was encrypted with
@@ -22,4 +26,33 @@ type Service interface {
 	Key(name, password string) (k *ecdsa.PrivateKey, created bool, err error)
 	// Exists returns true if the key with specified name exists.
 	Exists(name string) (bool, error)
+	// SetPassword encrypts the existing private key with a specified name
+	// with the new password. It returns ErrInvalidPassword if the private
+	// key can not be decrypted with the current password and ErrNotFound if
+	// the private key does not exist.
+	SetPassword(name, password, newPassword string) error
+}
+
+// Keys provides the private keys of a keystore unlocked in the process. The
+// keys are decrypted on each request, so that they are held only by the
+// components which use them and are not passed around with the password.
+type Keys interface {
+	// Key returns the private key for a specified name, creating it if it
+	// does not exist. The second returned value indicates if the key is
+	// newly created.
+	Key(name string) (k *ecdsa.PrivateKey, created bool, err error)
+}
+
+// Unlock returns the keys of the keystore decrypted with the password.
+func Unlock(s Service, password string) Keys {
+	return &unlockedKeys{service: s, password: password}
+}
+
+type unlockedKeys struct {
+	service  Service
+	password string
+}
+
+func (k *unlockedKeys) Key(name string) (*ecdsa.PrivateKey, bool, error) {
+	return k.service.Key(name, k.password)
 }
@@ -64,6 +64,22 @@ func (s *Service) Key(name, password string) (pk *ecdsa.PrivateKey, created bool
 	return k.pk, created, nil
 }
 
+func (s *Service) SetPassword(name, password, newPassword string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.m[name]
+	if !ok {
+		return keystore.ErrNotFound
+	}
+	if k.password != password {
+		return keystore.ErrInvalidPassword
+	}
+	k.password = newPassword
+	s.m[name] = k
+	return nil
+}
+
 type key struct {
 	pk       *ecdsa.PrivateKey
 	password string
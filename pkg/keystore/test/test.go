@@ -82,4 +82,53 @@ func Service(t *testing.T, s keystore.Service) {
 	if !bytes.Equal(k3.D.Bytes(), k4.D.Bytes()) {
 		t.Fatal("two keys are not equal")
 	}
+
+	// change the password of the libp2p key
+	if err := s.SetPassword("libp2p", "invalid password", "new p2p pass"); !errors.Is(err, keystore.ErrInvalidPassword) {
+		t.Fatalf("got error %v, want %v", err, keystore.ErrInvalidPassword)
+	}
+	if err := s.SetPassword("libp2p", "p2p pass", "new p2p pass"); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = s.Key("libp2p", "p2p pass")
+	if !errors.Is(err, keystore.ErrInvalidPassword) {
+		t.Fatal(err)
+	}
+	k5, created, err := s.Key("libp2p", "new p2p pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Fatal("key is created, but should not be")
+	}
+	if !bytes.Equal(k3.D.Bytes(), k5.D.Bytes()) {
+		t.Fatal("two keys are not equal")
+	}
+
+	// get the libp2p key from the unlocked keystore
+	k6, created, err := keystore.Unlock(s, "new p2p pass").Key("libp2p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created {
+		t.Fatal("key is created, but should not be")
+	}
+	if !bytes.Equal(k3.D.Bytes(), k6.D.Bytes()) {
+		t.Fatal("two keys are not equal")
+	}
+	if _, _, err := keystore.Unlock(s, "p2p pass").Key("libp2p"); !errors.Is(err, keystore.ErrInvalidPassword) {
+		t.Fatalf("got error %v, want %v", err, keystore.ErrInvalidPassword)
+	}
+
+	// change the password of a missing key
+	if err := s.SetPassword("missing", "pass", "new pass"); !errors.Is(err, keystore.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, keystore.ErrNotFound)
+	}
+	exists, err = s.Exists("missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("should not exist")
+	}
 }
@@ -0,0 +1,222 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package latency periodically measures the round-trip-time to the connected
+// peers with the pingpong protocol and keeps its exponentially weighted moving
+// average per peer, so that the latency can be taken into account when peers
+// are selected.
+package latency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/yanhuangpai/voyager/pkg/events"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	m "github.com/yanhuangpai/voyager/pkg/metrics"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/pingpong"
+)
+
+const (
+	defaultInterval    = time.Minute
+	defaultTimeout     = 5 * time.Second
+	defaultAlpha       = 0.3
+	maxConcurrentPings = 8
+)
+
+// Estimator returns the estimated round-trip-time to a peer.
+type Estimator interface {
+	// RTT returns the moving average of the round-trip-time to the peer and
+	// false if the peer has not been measured yet.
+	RTT(peer infinity.Address) (time.Duration, bool)
+}
+
+// Peerer returns the connected peers.
+type Peerer interface {
+	Peers() []p2p.Peer
+}
+
+// Options are the latency prober options.
+type Options struct {
+	// Interval is the time between the measurements of all connected
+	// peers, defaultInterval if not set.
+	Interval time.Duration
+	// Timeout is the maximal time to wait for a single ping,
+	// defaultTimeout if not set.
+	Timeout time.Duration
+	// Alpha is the weight of the new measurement in the moving average,
+	// in the range (0, 1], defaultAlpha if not set.
+	Alpha float64
+}
+
+var _ Estimator = (*Service)(nil)
+
+// Service probes the connected peers periodically.
+type Service struct {
+	pinger   pingpong.Interface
+	peerer   Peerer
+	logger   logging.Logger
+	eventBus *events.Bus
+	metrics  metrics
+	interval time.Duration
+	timeout  time.Duration
+	alpha    float64
+
+	mu   sync.RWMutex
+	rtts map[string]time.Duration
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a new latency prober.
+func New(pinger pingpong.Interface, peerer Peerer, logger logging.Logger, o Options) *Service {
+	if o.Interval <= 0 {
+		o.Interval = defaultInterval
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.Alpha <= 0 || o.Alpha > 1 {
+		o.Alpha = defaultAlpha
+	}
+	return &Service{
+		pinger:   pinger,
+		peerer:   peerer,
+		logger:   logger,
+		metrics:  newMetrics(),
+		interval: o.Interval,
+		timeout:  o.Timeout,
+		alpha:    o.Alpha,
+		rtts:     make(map[string]time.Duration),
+		quit:     make(chan struct{}),
+	}
+}
+
+// SetEventBus sets the bus on which the measurements are published.
+func (s *Service) SetEventBus(b *events.Bus) {
+	s.eventBus = b
+}
+
+// Start starts measuring the connected peers periodically.
+func (s *Service) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.quit:
+				return
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				select {
+				case <-s.quit:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			s.Probe(ctx)
+			cancel()
+		}
+	}()
+}
+
+// Probe measures the round-trip-time to all connected peers and forgets the
+// peers which are not connected anymore.
+func (s *Service) Probe(ctx context.Context) {
+	peers := s.peerer.Peers()
+
+	connected := make(map[string]struct{}, len(peers))
+	for _, p := range peers {
+		connected[p.Address.ByteString()] = struct{}{}
+	}
+	s.mu.Lock()
+	for k := range s.rtts {
+		if _, ok := connected[k]; !ok {
+			delete(s.rtts, k)
+		}
+	}
+	s.mu.Unlock()
+
+	sem := make(chan struct{}, maxConcurrentPings)
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(peer infinity.Address) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.probe(ctx, peer)
+		}(p.Address)
+	}
+	wg.Wait()
+}
+
+func (s *Service) probe(ctx context.Context, peer infinity.Address) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	rtt, err := s.pinger.Ping(ctx, peer, "ping")
+	if err != nil {
+		s.metrics.ProbeFailedCount.Inc()
+		s.logger.Debugf("latency: ping peer %s: %v", peer, err)
+		return
+	}
+	s.metrics.ProbeCount.Inc()
+	s.metrics.RTT.Observe(rtt.Seconds())
+
+	s.mu.Lock()
+	avg, ok := s.rtts[peer.ByteString()]
+	if ok {
+		avg = time.Duration(s.alpha*float64(rtt) + (1-s.alpha)*float64(avg))
+	} else {
+		avg = rtt
+	}
+	s.rtts[peer.ByteString()] = avg
+	s.mu.Unlock()
+
+	s.eventBus.Publish(events.TopicLatencyMeasured, events.LatencyData{
+		Peer:    peer,
+		RTT:     rtt,
+		Average: avg,
+	})
+}
+
+// RTT returns the moving average of the round-trip-time to the peer.
+func (s *Service) RTT(peer infinity.Address) (time.Duration, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rtt, ok := s.rtts[peer.ByteString()]
+	return rtt, ok
+}
+
+func (s *Service) Metrics() []prometheus.Collector {
+	return m.PrometheusCollectorsFromFields(s.metrics)
+}
+
+// Close stops the measurements.
+func (s *Service) Close() error {
+	close(s.quit)
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latency_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/events"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/latency"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/pingpong/mock"
+)
+
+type peerer struct {
+	mu    sync.Mutex
+	peers []p2p.Peer
+}
+
+func (p *peerer) Peers() []p2p.Peer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.peers
+}
+
+func (p *peerer) set(addrs ...infinity.Address) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.peers = nil
+	for _, a := range addrs {
+		p.peers = append(p.peers, p2p.Peer{Address: a})
+	}
+}
+
+func TestProbe(t *testing.T) {
+	fast := infinity.MustParseHexAddress("01")
+	slow := infinity.MustParseHexAddress("02")
+	failing := infinity.MustParseHexAddress("03")
+
+	var (
+		mu   sync.Mutex
+		rtts = map[string]time.Duration{
+			fast.String(): 10 * time.Millisecond,
+			slow.String(): 100 * time.Millisecond,
+		}
+	)
+	pinger := mock.New(func(_ context.Context, address infinity.Address, _ ...string) (time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		rtt, ok := rtts[address.String()]
+		if !ok {
+			return 0, errors.New("test error")
+		}
+		return rtt, nil
+	})
+	p := new(peerer)
+	p.set(fast, slow, failing)
+
+	s := latency.New(pinger, p, logging.New(ioutil.Discard, 0), latency.Options{Alpha: 0.5})
+	bus := events.New()
+	s.SetEventBus(bus)
+	c, unsubscribe := bus.Subscribe(10, events.TopicLatencyMeasured)
+	defer unsubscribe()
+
+	s.Probe(context.Background())
+
+	if rtt, ok := s.RTT(fast); !ok || rtt != 10*time.Millisecond {
+		t.Fatalf("got rtt %v %v, want %v", rtt, ok, 10*time.Millisecond)
+	}
+	if _, ok := s.RTT(failing); ok {
+		t.Fatal("got rtt of the failing peer")
+	}
+	if got := len(c); got != 2 {
+		t.Fatalf("got %d events, want 2", got)
+	}
+
+	// the average moves towards the new measurement
+	mu.Lock()
+	rtts[slow.String()] = 200 * time.Millisecond
+	mu.Unlock()
+	s.Probe(context.Background())
+
+	if rtt, _ := s.RTT(slow); rtt != 150*time.Millisecond {
+		t.Fatalf("got rtt %v, want %v", rtt, 150*time.Millisecond)
+	}
+
+	// the disconnected peers are forgotten
+	p.set(slow)
+	s.Probe(context.Background())
+
+	if _, ok := s.RTT(fast); ok {
+		t.Fatal("got rtt of the disconnected peer")
+	}
+}
+
+func TestStartClose(t *testing.T) {
+	peer := infinity.MustParseHexAddress("01")
+	pinged := make(chan struct{}, 1)
+	pinger := mock.New(func(context.Context, infinity.Address, ...string) (time.Duration, error) {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return time.Millisecond, nil
+	})
+	p := new(peerer)
+	p.set(peer)
+
+	s := latency.New(pinger, p, logging.New(ioutil.Discard, 0), latency.Options{Interval: 10 * time.Millisecond})
+	s.Start()
+
+	select {
+	case <-pinged:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for ping")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package latency
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	m "github.com/yanhuangpai/voyager/pkg/metrics"
+)
+
+type metrics struct {
+	ProbeCount       prometheus.Counter
+	ProbeFailedCount prometheus.Counter
+	RTT              prometheus.Histogram
+}
+
+func newMetrics() metrics {
+	subsystem := "latency"
+
+	return metrics{
+		ProbeCount: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "probe_count",
+			Help:      "Number of successful peer latency probes.",
+		}),
+		ProbeFailedCount: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "probe_failed_count",
+			Help:      "Number of failed peer latency probes.",
+		}),
+		RTT: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "rtt_seconds",
+			Help:      "Histogram of the measured round-trip-time to the peers.",
+			Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+		}),
+	}
+}
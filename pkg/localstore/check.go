@@ -0,0 +1,162 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package localstore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/yanhuangpai/voyager/pkg/shed"
+)
+
+// ErrCorrupted is returned by New if the quick check of the database finds
+// the indexes inconsistent with each other.
+var ErrCorrupted = errors.New("localstore corrupted")
+
+// errWarmingUp is returned by the readiness check until the warm-up of the
+// indexes is done.
+var errWarmingUp = errors.New("warming up")
+
+// openMarker values stored while the database is open and after it is closed
+// cleanly.
+const (
+	markerOpen   uint64 = 1
+	markerClosed uint64 = 2
+)
+
+// checkShutdown reports whether the database was closed cleanly the last time
+// it was used and marks it as open. A database without the marker, new or
+// created before the marker was introduced, is considered closed cleanly.
+func (db *DB) checkShutdown() (clean bool, err error) {
+	marker, err := db.openMarker.Get()
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		return false, fmt.Errorf("get open marker: %w", err)
+	}
+	if err := db.openMarker.Put(markerOpen); err != nil {
+		return false, fmt.Errorf("put open marker: %w", err)
+	}
+	return marker != markerOpen, nil
+}
+
+// quickCheck checks the schema of the database and, if it was not shut down
+// cleanly, that the numbers of the items in the indexes are consistent with
+// each other. The indexes are counted only after an unclean shutdown, as it
+// takes long on a large database, which is otherwise not expected to be
+// inconsistent. The gc size is corrected if it does not match the gc index.
+func (db *DB) quickCheck(path string, cleanShutdown bool) error {
+	start := time.Now()
+
+	corrupted := func(format string, a ...interface{}) error {
+		hint := "verify the stored chunks or remove the localstore directory"
+		if path != "" {
+			hint += " " + path
+		}
+		if !cleanShutdown {
+			hint = "the node was not shut down cleanly, " + hint
+		}
+		return fmt.Errorf("%w: %s: %s", ErrCorrupted, fmt.Sprintf(format, a...), hint)
+	}
+
+	schemaName, err := db.schemaName.Get()
+	if err != nil {
+		return fmt.Errorf("get schema name: %w", err)
+	}
+	if schemaName != DbSchemaCurrent {
+		return corrupted("schema %q, expected %q", schemaName, DbSchemaCurrent)
+	}
+	if cleanShutdown {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for name, index := range map[string]shed.Index{
+		"retrievalDataIndex": db.retrievalDataIndex,
+		"pullIndex":          db.pullIndex,
+		"gcIndex":            db.gcIndex,
+	} {
+		c, err := index.Count()
+		if err != nil {
+			return fmt.Errorf("count %s: %w", name, err)
+		}
+		counts[name] = c
+	}
+
+	// every chunk in the pull and gc indexes is also in the retrieval data
+	// index, the push index is left out as the removed chunks are kept in it
+	// until they are synced
+	for _, name := range []string{"pullIndex", "gcIndex"} {
+		if counts[name] > counts["retrievalDataIndex"] {
+			return corrupted("%s has %d items, more than %d stored chunks", name, counts[name], counts["retrievalDataIndex"])
+		}
+	}
+
+	gcSize, err := db.gcSize.Get()
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		return fmt.Errorf("get gc size: %w", err)
+	}
+	if gcSize != uint64(counts["gcIndex"]) {
+		db.logger.Warningf("localstore: gc size %d does not match %d items in the gc index, corrected", gcSize, counts["gcIndex"])
+		if err := db.gcSize.Put(uint64(counts["gcIndex"])); err != nil {
+			return fmt.Errorf("put gc size: %w", err)
+		}
+	}
+
+	db.logger.Debugf("localstore: quick check of %d chunks done in %s", counts["retrievalDataIndex"], time.Since(start))
+	return nil
+}
+
+// warmUp reads the keys of the frequently used indexes, so that their blocks
+// are loaded into the block cache, and closes the ready channel when done.
+// The retrieval data index is left out as it holds the chunk data.
+func (db *DB) warmUp() {
+	defer db.warmUpWG.Done()
+	defer close(db.ready)
+
+	start := time.Now()
+	for name, index := range map[string]shed.Index{
+		"pushIndex":            db.pushIndex,
+		"pullIndex":            db.pullIndex,
+		"gcIndex":              db.gcIndex,
+		"retrievalAccessIndex": db.retrievalAccessIndex,
+		"pinIndex":             db.pinIndex,
+	} {
+		err := index.Iterate(func(shed.Item) (stop bool, err error) {
+			select {
+			case <-db.close:
+				return true, nil
+			default:
+				return false, nil
+			}
+		}, nil)
+		if err != nil {
+			db.logger.Debugf("localstore: warm up %s: %v", name, err)
+		}
+
+		select {
+		case <-db.close:
+			return
+		default:
+		}
+	}
+	db.logger.Debugf("localstore: indexes warmed up in %s", time.Since(start))
+}
+
+// Ready returns the channel which is closed once the indexes are warmed up
+// after the database is opened.
+func (db *DB) Ready() <-chan struct{} {
+	return db.ready
+}
+
+// ReadyCheck returns an error until the indexes are warmed up.
+func (db *DB) ReadyCheck() error {
+	select {
+	case <-db.ready:
+		return nil
+	default:
+		return errWarmingUp
+	}
+}
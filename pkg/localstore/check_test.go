@@ -0,0 +1,178 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package localstore
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/shed"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+// TestReady checks that the database reports readiness once the indexes are
+// warmed up.
+func TestReady(t *testing.T) {
+	db := newTestDB(t, nil)
+
+	_, err := db.Put(context.Background(), storage.ModePutUpload, generateTestRandomChunks(10)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-db.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the warm-up")
+	}
+	if err := db.ReadyCheck(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestQuickCheck checks that the database with the inconsistent indexes is
+// not opened and that the gc size is corrected on open after an unclean
+// shutdown.
+func TestQuickCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localstore-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	baseKey := make([]byte, 32)
+	logger := logging.New(ioutil.Discard, 0)
+
+	db, err := New(dir, baseKey, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := generateTestRandomChunks(3)
+	_, err = db.Put(context.Background(), storage.ModePutUpload, chunks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Set(context.Background(), storage.ModeSetSync, chunkAddresses(chunks)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.gcSize.Put(100); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the indexes are not counted after the clean shutdown
+	db, err = New(dir, baseKey, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcSize, err := db.gcSize.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gcSize != 100 {
+		t.Fatalf("got gc size %d, want 100", gcSize)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	markUncleanShutdown(t, dir)
+
+	db, err = New(dir, baseKey, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcSize, err = db.gcSize.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gcSize != 3 {
+		t.Fatalf("got gc size %d, want 3", gcSize)
+	}
+
+	// remove a chunk from the retrieval data index only
+	if err := db.retrievalDataIndex.Delete(addressToItem(chunks[0].Address())); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	markUncleanShutdown(t, dir)
+
+	_, err = New(dir, baseKey, nil, logger)
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("got error %v, want %v", err, ErrCorrupted)
+	}
+}
+
+// markUncleanShutdown marks the closed database at the path as not closed
+// cleanly, as after a crash.
+func markUncleanShutdown(t *testing.T, path string) {
+	t.Helper()
+
+	s, err := shed.NewDB(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	marker, err := s.NewUint64Field("open-marker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := marker.Put(markerOpen); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCheckShutdown checks that the database which is not closed is reported
+// as not closed cleanly.
+func TestCheckShutdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localstore-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	baseKey := make([]byte, 32)
+	logger := logging.New(ioutil.Discard, 0)
+
+	db, err := New(dir, baseKey, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = New(dir, baseKey, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	marker, err := db.openMarker.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if marker != markerOpen {
+		t.Fatalf("got open marker %d, want %d", marker, markerOpen)
+	}
+
+	// the database is still open, as after a crash
+	clean, err := db.checkShutdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clean {
+		t.Fatal("got clean shutdown")
+	}
+}
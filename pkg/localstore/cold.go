@@ -0,0 +1,267 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package localstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/yanhuangpai/voyager/pkg/cac"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/shed"
+	"github.com/yanhuangpai/voyager/pkg/soc"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+const (
+	// coldStoreTimeout is the maximal time of a single upload or download
+	// of a chunk to or from the cold tier.
+	coldStoreTimeout = 30 * time.Second
+	// coldOffloadConcurrency is the number of the chunks collected by the
+	// garbage collection uploaded to the cold tier at once.
+	coldOffloadConcurrency = 16
+	// coldBackoffMax is the maximal time the offloads are skipped for after
+	// the failed ones.
+	coldBackoffMax = time.Hour
+	// coldEvictInterval is the minimal time between the evictions of the
+	// expired chunks from the cold tier.
+	coldEvictInterval = time.Hour
+	// coldEvictBatchSize limits the number of the chunks evicted from the
+	// cold tier at once.
+	coldEvictBatchSize = 1000
+)
+
+var (
+	// coldOffloadDeadline is the maximal time the garbage collection waits
+	// for the uploads of the collected chunks, the chunks not uploaded by
+	// then are removed.
+	coldOffloadDeadline = 2 * time.Minute
+	// coldBackoffBase is the time the offloads are skipped for after the
+	// first garbage collection run with the failed ones, doubled after every
+	// following one.
+	coldBackoffBase = time.Minute
+)
+
+var errInvalidColdChunk = errors.New("invalid chunk")
+
+// offloadCold uploads the chunks collected by the garbage collection which
+// are selected by the cold policy to the cold tier and returns the ones stored
+// in the cold tier. The chunks which fail to be uploaded, or are not uploaded
+// before the offload deadline, are removed as any other collected chunk, so
+// that the garbage collection is not blocked by an unavailable cold tier.
+// After the failed uploads, the offloads are skipped for an exponentially
+// growing time. It is called only by the garbage collection worker.
+func (db *DB) offloadCold(items []shed.Item) (offloaded map[string]struct{}) {
+	offloaded = make(map[string]struct{})
+	if db.coldStore == nil {
+		return offloaded
+	}
+	backoff := time.Unix(0, now()).Before(db.coldBackoffUntil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), coldOffloadDeadline)
+	defer cancel()
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		sem       = make(chan struct{}, coldOffloadConcurrency)
+		attempted int
+		failed    int
+	)
+	for _, item := range items {
+		idle := time.Duration(now() - item.AccessTimestamp)
+		if !db.coldPolicy.Offload(db.po(infinity.NewAddress(item.Address)), idle) {
+			continue
+		}
+		if has, err := db.coldIndex.Has(item); err == nil && has {
+			// the chunk is in the cold tier since it was offloaded before
+			offloaded[string(item.Address)] = struct{}{}
+			continue
+		}
+		if backoff {
+			continue
+		}
+
+		// the rest of the chunks is removed without the offload after the
+		// deadline
+		select {
+		case sem <- struct{}{}:
+			if ctx.Err() != nil {
+				<-sem
+				continue
+			}
+		case <-ctx.Done():
+			continue
+		}
+		attempted++
+		wg.Add(1)
+		go func(item shed.Item) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := db.offloadColdChunk(ctx, item)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				db.metrics.ColdOffloadFailure.Inc()
+				db.logger.Debugf("localstore: offload chunk %x to cold store: %v", item.Address, err)
+				failed++
+				return
+			}
+			db.metrics.ColdOffload.Inc()
+			offloaded[string(item.Address)] = struct{}{}
+		}(item)
+	}
+	wg.Wait()
+
+	if failed > 0 {
+		db.coldFailures++
+		d := coldBackoffBase
+		for i := 1; i < db.coldFailures && d < coldBackoffMax; i++ {
+			d *= 2
+		}
+		if d > coldBackoffMax {
+			d = coldBackoffMax
+		}
+		db.coldBackoffUntil = time.Unix(0, now()).Add(d)
+		db.logger.Warningf("localstore: %d collected chunks not offloaded to the cold store, offloads skipped for %v", failed, d)
+	} else if attempted > 0 {
+		db.coldFailures = 0
+	}
+	return offloaded
+}
+
+// offloadColdChunk uploads the data of the chunk to the cold tier.
+func (db *DB) offloadColdChunk(ctx context.Context, item shed.Item) error {
+	i, err := db.retrievalDataIndex.Get(item)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, coldStoreTimeout)
+	defer cancel()
+	return db.coldStore.Put(ctx, infinity.NewChunk(infinity.NewAddress(i.Address), i.Data))
+}
+
+// evictCold removes the chunks offloaded to the cold tier before the maximal
+// age of the cold policy from the cold tier. It runs at most once in the
+// eviction interval and removes a limited number of the chunks at once. It is
+// called only by the garbage collection worker.
+func (db *DB) evictCold() {
+	if db.coldStore == nil || db.coldPolicy.MaxAge <= 0 {
+		return
+	}
+	t := time.Unix(0, now())
+	if t.Sub(db.coldEvicted) < coldEvictInterval {
+		return
+	}
+	db.coldEvicted = t
+
+	var expired []shed.Item
+	err := db.coldIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		if db.coldPolicy.Expired(t.Sub(time.Unix(0, item.StoreTimestamp))) {
+			expired = append(expired, item)
+		}
+		return len(expired) >= coldEvictBatchSize, nil
+	}, nil)
+	if err != nil {
+		db.logger.Errorf("localstore: iterate cold chunks: %v", err)
+		return
+	}
+	if len(expired) == 0 {
+		return
+	}
+	if len(expired) >= coldEvictBatchSize {
+		// evict the rest of the expired chunks on the next run
+		db.coldEvicted = time.Time{}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), coldOffloadDeadline)
+	defer cancel()
+
+	batch := new(leveldb.Batch)
+	for _, item := range expired {
+		err := func() error {
+			ctx, cancel := context.WithTimeout(ctx, coldStoreTimeout)
+			defer cancel()
+			return db.coldStore.Delete(ctx, infinity.NewAddress(item.Address))
+		}()
+		if err != nil {
+			db.logger.Debugf("localstore: evict chunk %x from cold store: %v", item.Address, err)
+			continue
+		}
+		if err := db.coldIndex.DeleteInBatch(batch, item); err != nil {
+			db.logger.Errorf("localstore: evict cold chunk %x from index: %v", item.Address, err)
+			return
+		}
+		db.metrics.ColdEvict.Inc()
+	}
+	if err := db.shed.WriteBatch(batch); err != nil {
+		db.logger.Errorf("localstore: evict cold chunks: %v", err)
+	}
+}
+
+// RestoreCold restores the chunk which was offloaded to the cold tier by the
+// garbage collection to the local store. The chunk is removed from the cold
+// tier as it is stored locally again. The storage.ErrNotFound is returned if
+// the chunk was not offloaded.
+func (db *DB) RestoreCold(ctx context.Context, addr infinity.Address) (infinity.Chunk, error) {
+	if db.coldStore == nil {
+		return nil, storage.ErrNotFound
+	}
+	item := addressToItem(addr)
+	has, err := db.coldIndex.Has(item)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, storage.ErrNotFound
+	}
+
+	ch, err := db.restoreCold(ctx, addr)
+	if err != nil {
+		db.metrics.ColdRestoreFailure.Inc()
+		if errors.Is(err, storage.ErrNotFound) {
+			// the chunk is gone from the cold tier, do not look for it again
+			if err := db.coldIndex.Delete(item); err != nil {
+				db.logger.Debugf("localstore: remove cold chunk %s from index: %v", addr, err)
+			}
+		}
+		return nil, err
+	}
+	db.metrics.ColdRestore.Inc()
+
+	if err := db.coldIndex.Delete(item); err != nil {
+		db.logger.Debugf("localstore: remove cold chunk %s from index: %v", addr, err)
+		return ch, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), coldStoreTimeout)
+	defer cancel()
+	if err := db.coldStore.Delete(ctx, addr); err != nil {
+		db.logger.Debugf("localstore: remove chunk %s from cold store: %v", addr, err)
+	}
+	return ch, nil
+}
+
+// restoreCold downloads the chunk from the cold tier and stores it locally.
+func (db *DB) restoreCold(ctx context.Context, addr infinity.Address) (infinity.Chunk, error) {
+	ctx, cancel := context.WithTimeout(ctx, coldStoreTimeout)
+	defer cancel()
+
+	ch, err := db.coldStore.Get(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	if !cac.Valid(ch) && !soc.Valid(ch) {
+		return nil, fmt.Errorf("restore cold chunk %s: %w", addr, errInvalidColdChunk)
+	}
+	if _, err := db.Put(ctx, storage.ModePutRequest, ch); err != nil {
+		return nil, fmt.Errorf("restore cold chunk %s: %w", addr, err)
+	}
+	return ch, nil
+}
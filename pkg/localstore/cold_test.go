@@ -0,0 +1,285 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package localstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/coldstore"
+	coldmock "github.com/yanhuangpai/voyager/pkg/coldstore/mock"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/shed"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+// TestColdOffload validates that the chunks collected by the garbage
+// collection are offloaded to the cold tier and restored from it.
+func TestColdOffload(t *testing.T) {
+	cold := coldmock.New()
+	db, chunks := newColdTestDB(t, cold)
+
+	collected := collectedChunks(t, db, chunks)
+	if len(collected) == 0 {
+		t.Fatal("no chunks collected")
+	}
+	if got := cold.Len(); got != len(collected) {
+		t.Fatalf("got %d offloaded chunks, want %d", got, len(collected))
+	}
+	t.Run("cold index count", newItemsCountTest(db.coldIndex, len(collected)))
+
+	ch := collected[0]
+	if !cold.Has(ch.Address()) {
+		t.Fatalf("chunk %s not offloaded", ch.Address())
+	}
+
+	got, err := db.RestoreCold(context.Background(), ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data(), ch.Data()) {
+		t.Fatal("restored chunk data not equal")
+	}
+
+	got, err = db.Get(context.Background(), storage.ModeGetRequest, ch.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data(), ch.Data()) {
+		t.Fatal("stored chunk data not equal")
+	}
+	if cold.Has(ch.Address()) {
+		t.Fatalf("restored chunk %s not removed from the cold store", ch.Address())
+	}
+	t.Run("cold index count after restore", newItemsCountTest(db.coldIndex, len(collected)-1))
+
+	_, err = db.RestoreCold(context.Background(), ch.Address())
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, storage.ErrNotFound)
+	}
+}
+
+// TestColdOffloadFailure validates that the chunks which fail to be offloaded
+// to the cold tier are removed as without the cold tier.
+func TestColdOffloadFailure(t *testing.T) {
+	cold := coldmock.New(coldmock.WithPutError(errors.New("unavailable")))
+	db, chunks := newColdTestDB(t, cold)
+
+	collected := collectedChunks(t, db, chunks)
+	if len(collected) == 0 {
+		t.Fatal("no chunks collected")
+	}
+	t.Run("cold index count", newItemsCountTest(db.coldIndex, 0))
+
+	_, err := db.RestoreCold(context.Background(), collected[0].Address())
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, storage.ErrNotFound)
+	}
+}
+
+// TestColdPolicy validates that only the chunks selected by the cold policy
+// are offloaded to the cold tier.
+func TestColdPolicy(t *testing.T) {
+	cold := coldmock.New()
+	db, chunks := newColdTestDB(t, cold, coldstore.Policy{MaxIdle: time.Nanosecond})
+
+	collected := collectedChunks(t, db, chunks)
+	if len(collected) == 0 {
+		t.Fatal("no chunks collected")
+	}
+	if got := cold.Len(); got != 0 {
+		t.Fatalf("got %d offloaded chunks, want none", got)
+	}
+}
+
+// TestColdOffloadBackoff validates that the offloads are skipped for an
+// exponentially growing time after the failed ones.
+func TestColdOffloadBackoff(t *testing.T) {
+	defer func(d time.Duration) { coldBackoffBase = d }(coldBackoffBase)
+	coldBackoffBase = time.Minute
+
+	cold := &countingColdStore{Store: coldmock.New(coldmock.WithPutError(errors.New("unavailable")))}
+	db := newTestDB(t, &Options{Capacity: 100, ColdStore: cold})
+	items := gcItems(t, db, addRandomChunks(t, 5, db, false))
+
+	var timestamp int64 = 1
+	defer setNow(func() int64 { return timestamp })()
+
+	for _, tc := range []struct {
+		name         string
+		advance      time.Duration
+		wantAttempts int
+		wantBackoff  time.Duration
+	}{
+		{name: "first failure", wantAttempts: len(items), wantBackoff: time.Minute},
+		{name: "backoff", advance: 30 * time.Second, wantBackoff: 30 * time.Second},
+		{name: "second failure", advance: 30 * time.Second, wantAttempts: len(items), wantBackoff: 2 * time.Minute},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			timestamp += int64(tc.advance)
+			cold.puts = 0
+
+			if offloaded := db.offloadCold(items); len(offloaded) != 0 {
+				t.Fatalf("got %d offloaded chunks, want none", len(offloaded))
+			}
+			if cold.puts != tc.wantAttempts {
+				t.Fatalf("got %d uploads, want %d", cold.puts, tc.wantAttempts)
+			}
+			if got := db.coldBackoffUntil.Sub(time.Unix(0, timestamp)); got != tc.wantBackoff {
+				t.Fatalf("got backoff %v, want %v", got, tc.wantBackoff)
+			}
+		})
+	}
+}
+
+// TestColdOffloadDeadline validates that the garbage collection does not wait
+// for the uploads after the offload deadline.
+func TestColdOffloadDeadline(t *testing.T) {
+	defer func(d time.Duration) { coldOffloadDeadline = d }(coldOffloadDeadline)
+	coldOffloadDeadline = 100 * time.Millisecond
+
+	cold := &countingColdStore{Store: coldmock.New(), block: true}
+	db := newTestDB(t, &Options{Capacity: 100, ColdStore: cold})
+	items := gcItems(t, db, addRandomChunks(t, 2*coldOffloadConcurrency, db, false))
+
+	done := make(chan map[string]struct{})
+	go func() {
+		done <- db.offloadCold(items)
+	}()
+	select {
+	case offloaded := <-done:
+		if len(offloaded) != 0 {
+			t.Fatalf("got %d offloaded chunks, want none", len(offloaded))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("offload not done after the deadline")
+	}
+	if cold.puts != coldOffloadConcurrency {
+		t.Fatalf("got %d uploads, want %d", cold.puts, coldOffloadConcurrency)
+	}
+}
+
+// TestColdEvict validates that the chunks kept in the cold tier longer than
+// the maximal age of the cold policy are evicted from it.
+func TestColdEvict(t *testing.T) {
+	cold := coldmock.New()
+	db, chunks := newColdTestDB(t, cold, coldstore.Policy{MaxAge: time.Hour})
+
+	collected := collectedChunks(t, db, chunks)
+	if len(collected) == 0 {
+		t.Fatal("no chunks collected")
+	}
+
+	defer setNow(func() int64 { return time.Now().Add(2 * time.Hour).UnixNano() })()
+	db.evictCold()
+
+	if got := cold.Len(); got != 0 {
+		t.Fatalf("got %d chunks in the cold store, want none", got)
+	}
+	t.Run("cold index count", newItemsCountTest(db.coldIndex, 0))
+}
+
+// newColdTestDB returns the store with the cold tier and the chunks added to
+// it until the garbage collection is done.
+func newColdTestDB(t *testing.T, cold coldstore.Store, policy ...coldstore.Policy) (*DB, []infinity.Chunk) {
+	t.Helper()
+
+	var closed chan struct{}
+	testHookCollectGarbageChan := make(chan uint64)
+	t.Cleanup(setTestHookCollectGarbage(func(collectedCount uint64) {
+		select {
+		case testHookCollectGarbageChan <- collectedCount:
+		case <-closed:
+		}
+	}))
+	o := &Options{
+		Capacity:  10,
+		ColdStore: cold,
+	}
+	if len(policy) > 0 {
+		o.ColdPolicy = policy[0]
+	}
+	db := newTestDB(t, o)
+	closed = db.close
+
+	chunks := addRandomChunks(t, 15, db, false)
+
+	gcTarget := db.gcTarget()
+	for {
+		select {
+		case <-testHookCollectGarbageChan:
+		case <-time.After(10 * time.Second):
+			t.Fatal("collect garbage timeout")
+		}
+		gcSize, err := db.gcSize.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gcSize == gcTarget {
+			break
+		}
+	}
+	return db, chunks
+}
+
+// collectedChunks returns the chunks not found in the store.
+func collectedChunks(t *testing.T, db *DB, chunks []infinity.Chunk) (collected []infinity.Chunk) {
+	t.Helper()
+
+	for _, ch := range chunks {
+		has, err := db.Has(context.Background(), ch.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !has {
+			collected = append(collected, ch)
+		}
+	}
+	return collected
+}
+
+// gcItems returns the gc index items of the chunks.
+func gcItems(t *testing.T, db *DB, chunks []infinity.Chunk) (items []shed.Item) {
+	t.Helper()
+
+	for _, ch := range chunks {
+		i, err := db.retrievalDataIndex.Get(addressToItem(ch.Address()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := db.retrievalAccessIndex.Get(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i.AccessTimestamp = a.AccessTimestamp
+		items = append(items, i)
+	}
+	return items
+}
+
+// countingColdStore counts the uploads of the chunks, which are blocked until
+// the context is done if block is true.
+type countingColdStore struct {
+	coldstore.Store
+	block bool
+
+	mu   sync.Mutex
+	puts int
+}
+
+func (s *countingColdStore) Put(ctx context.Context, ch infinity.Chunk) error {
+	s.mu.Lock()
+	s.puts++
+	s.mu.Unlock()
+	if s.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return s.Store.Put(ctx, ch)
+}
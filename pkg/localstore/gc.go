@@ -58,6 +58,7 @@ func (db *DB) collectGarbageWorker() {
 			if err != nil {
 				db.logger.Errorf("localstore: collect garbage: %v", err)
 			}
+			db.evictCold()
 			// check if another gc run is needed
 			if !done {
 				db.triggerGarbageCollection()
@@ -146,6 +147,10 @@ func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
 		testHookGCIteratorDone()
 	}
 
+	// offload the valuable chunks to the cold tier before they are removed,
+	// outside of the lock as the uploads are slow
+	offloaded := db.offloadCold(candidates)
+
 	// protect database from changing idexes and gcSize
 	db.batchMu.Lock()
 	defer totalTimeMetric(db.metrics.TotalTimeGCLock, time.Now())
@@ -159,7 +164,17 @@ func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
 	}
 
 	// get rid of dirty entries
+	offloadTimestamp := now()
 	for _, item := range candidates {
+		if _, ok := offloaded[string(item.Address)]; ok {
+			err = db.coldIndex.PutInBatch(batch, shed.Item{
+				Address:        item.Address,
+				StoreTimestamp: offloadTimestamp,
+			})
+			if err != nil {
+				return 0, false, err
+			}
+		}
 		if infinity.NewAddress(item.Address).MemberOf(db.dirtyAddresses) {
 			collectedCount--
 			continue
@@ -177,6 +192,10 @@ func (db *DB) collectGarbage() (collectedCount uint64, done bool, err error) {
 		if err != nil {
 			return 0, false, err
 		}
+		err = db.accessCountIndex.DeleteInBatch(batch, item)
+		if err != nil {
+			return 0, false, err
+		}
 		err = db.pullIndex.DeleteInBatch(batch, item)
 		if err != nil {
 			return 0, false, err
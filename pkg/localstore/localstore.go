@@ -27,6 +27,7 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/shed"
 	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/coldstore"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/tags"
 	"github.com/prometheus/client_golang/prometheus"
@@ -44,9 +45,10 @@ var (
 var (
 	// Default value for Capacity DB option.
 	defaultCapacity uint64 = 5000000
-	// Limit the number of goroutines created by Getters
-	// that call updateGC function. Value 0 sets no limit.
-	maxParallelUpdateGC = 1000
+	// Limit the number of chunks waiting for the gc index
+	// update. Accesses to chunks beyond the limit are not
+	// recorded until the queue is written.
+	maxUpdateGCQueue = 10000
 
 	// values needed to adjust subscription trigger
 	// buffer time.
@@ -62,6 +64,8 @@ type DB struct {
 
 	// schema name of loaded data
 	schemaName shed.StringField
+	// marks the database as open until it is closed cleanly
+	openMarker shed.Uint64Field
 
 	// retrieval indexes
 	retrievalDataIndex   shed.Index
@@ -91,6 +95,23 @@ type DB struct {
 	// pin files Index
 	pinIndex shed.Index
 
+	// number of requests and last access time of chunks
+	accessCountIndex shed.Index
+
+	// chunks offloaded to the cold tier by the garbage collection
+	coldIndex shed.Index
+	// cold tier and the policy selecting the offloaded chunks,
+	// the chunks are removed if the cold tier is not set
+	coldStore  coldstore.Store
+	coldPolicy coldstore.Policy
+	// number of the consecutive garbage collection runs with the failed
+	// offloads and the time the offloads are skipped until, and the last
+	// time the expired chunks were evicted from the cold tier, used only by
+	// the garbage collection worker
+	coldFailures     int
+	coldBackoffUntil time.Time
+	coldEvicted      time.Time
+
 	// field that stores number of intems in gc index
 	gcSize shed.Uint64Field
 
@@ -98,15 +119,25 @@ type DB struct {
 	// the capacity value
 	capacity uint64
 
+	// numbers of pinned and not yet synced uploaded chunks
+	sizes namespaceSizes
+	// limits of the pinned and the uploaded chunks, not
+	// limited if zero
+	pinnedCapacity uint64
+	uploadCapacity uint64
+
 	// triggers garbage collection event loop
 	collectGarbageTrigger chan struct{}
 
-	// a buffered channel acting as a semaphore
-	// to limit the maximal number of goroutines
-	// created by Getters to call updateGC function
-	updateGCSem chan struct{}
-	// a wait group to ensure all updateGC goroutines
-	// are done before closing the database
+	// chunks accessed by Getters waiting for the gc index
+	// update, coalesced by address
+	updateGCQueue   []shed.Item
+	updateGCQueued  map[string]uint64 // number of coalesced accesses
+	updateGCQueueMu sync.Mutex
+	// triggers the worker writing the queued gc updates
+	updateGCTrigger chan struct{}
+	// a wait group to ensure the updateGC worker
+	// is done before closing the database
 	updateGCWG sync.WaitGroup
 
 	// baseKey is the overlay address
@@ -137,6 +168,13 @@ type DB struct {
 	// iterators
 	subscritionsWG sync.WaitGroup
 
+	// closed when the indexes are warmed up after the
+	// database is opened
+	ready chan struct{}
+	// a wait group to ensure the warm-up is done
+	// before closing the database
+	warmUpWG sync.WaitGroup
+
 	metrics metrics
 
 	logger logging.Logger
@@ -147,6 +185,13 @@ type Options struct {
 	// Capacity is a limit that triggers garbage collection when
 	// number of items in gcIndex equals or exceeds it.
 	Capacity uint64
+	// PinnedCapacity is the limit of the number of pinned chunks,
+	// pinning more chunks fails. Zero means no limit.
+	PinnedCapacity uint64
+	// UploadCapacity is the limit of the number of uploaded chunks
+	// which are not synced yet, uploading more chunks fails until
+	// they are synced. Zero means no limit.
+	UploadCapacity uint64
 	// OpenFilesLimit defines the upper bound of open files that the
 	// the localstore should maintain at any point of time. It is
 	// passed on to the shed constructor.
@@ -160,6 +205,13 @@ type Options struct {
 	// and is passed on to shed.
 	DisableSeeksCompaction bool
 
+	// ColdStore is the cold tier the chunks collected by the garbage
+	// collection are offloaded to and restored from on access, the
+	// collected chunks are removed if it is not set.
+	ColdStore coldstore.Store
+	// ColdPolicy selects the collected chunks offloaded to the cold tier.
+	ColdPolicy coldstore.Policy
+
 	// MetricsPrefix defines a prefix for metrics names.
 	MetricsPrefix string
 	Tags          *tags.Tags
@@ -177,16 +229,23 @@ func New(path string, baseKey []byte, o *Options, logger logging.Logger) (db *DB
 	}
 
 	db = &DB{
-		capacity: o.Capacity,
-		baseKey:  baseKey,
-		tags:     o.Tags,
+		capacity:       o.Capacity,
+		pinnedCapacity: o.PinnedCapacity,
+		uploadCapacity: o.UploadCapacity,
+		coldStore:      o.ColdStore,
+		coldPolicy:     o.ColdPolicy,
+		baseKey:        baseKey,
+		tags:           o.Tags,
 		// channel collectGarbageTrigger
 		// needs to be buffered with the size of 1
 		// to signal another event if it
 		// is triggered during already running function
 		collectGarbageTrigger:    make(chan struct{}, 1),
+		updateGCTrigger:          make(chan struct{}, 1),
+		updateGCQueued:           make(map[string]uint64),
 		close:                    make(chan struct{}),
 		collectGarbageWorkerDone: make(chan struct{}),
+		ready:                    make(chan struct{}),
 		metrics:                  newMetrics(),
 		logger:                   logger,
 	}
@@ -202,10 +261,6 @@ func New(path string, baseKey []byte, o *Options, logger logging.Logger) (db *DB
 		db.logger.Infof("database capacity: %d chunks (approximately %0.1fGB)", db.capacity, capacityMB/1000)
 	}
 
-	if maxParallelUpdateGC > 0 {
-		db.updateGCSem = make(chan struct{}, maxParallelUpdateGC)
-	}
-
 	shedOpts := &shed.Options{
 		OpenFilesLimit:         o.OpenFilesLimit,
 		BlockCacheCapacity:     o.BlockCacheCapacity,
@@ -241,6 +296,18 @@ func New(path string, baseKey []byte, o *Options, logger logging.Logger) (db *DB
 		}
 	}
 
+	db.openMarker, err = db.shed.NewUint64Field("open-marker")
+	if err != nil {
+		return nil, err
+	}
+	cleanShutdown, err := db.checkShutdown()
+	if err != nil {
+		return nil, err
+	}
+	if !cleanShutdown {
+		db.logger.Warning("localstore was not closed cleanly, checking the indexes")
+	}
+
 	// Persist gc size.
 	db.gcSize, err = db.shed.NewUint64Field("gc-size")
 	if err != nil {
@@ -435,8 +502,72 @@ func New(path string, baseKey []byte, o *Options, logger logging.Logger) (db *DB
 		return nil, err
 	}
 
+	// Create a index structure for counting the chunk requests
+	db.accessCountIndex, err = db.shed.NewIndex("Hash->AccessCount|AccessTimestamp", shed.IndexFuncs{
+		EncodeKey: func(fields shed.Item) (key []byte, err error) {
+			return fields.Address, nil
+		},
+		DecodeKey: func(key []byte) (e shed.Item, err error) {
+			e.Address = key
+			return e, nil
+		},
+		EncodeValue: func(fields shed.Item) (value []byte, err error) {
+			b := make([]byte, 16)
+			binary.BigEndian.PutUint64(b[:8], fields.AccessCount)
+			binary.BigEndian.PutUint64(b[8:16], uint64(fields.AccessTimestamp))
+			return b, nil
+		},
+		DecodeValue: func(keyItem shed.Item, value []byte) (e shed.Item, err error) {
+			e.AccessCount = binary.BigEndian.Uint64(value[:8])
+			e.AccessTimestamp = int64(binary.BigEndian.Uint64(value[8:16]))
+			return e, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a index structure for the chunks offloaded to the cold tier
+	db.coldIndex, err = db.shed.NewIndex("Cold|Hash->StoreTimestamp", shed.IndexFuncs{
+		EncodeKey: func(fields shed.Item) (key []byte, err error) {
+			return fields.Address, nil
+		},
+		DecodeKey: func(key []byte) (e shed.Item, err error) {
+			e.Address = key
+			return e, nil
+		},
+		EncodeValue: func(fields shed.Item) (value []byte, err error) {
+			b := make([]byte, 8)
+			binary.BigEndian.PutUint64(b, uint64(fields.StoreTimestamp))
+			return b, nil
+		},
+		DecodeValue: func(keyItem shed.Item, value []byte) (e shed.Item, err error) {
+			e.StoreTimestamp = int64(binary.BigEndian.Uint64(value[:8]))
+			return e, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.quickCheck(path, cleanShutdown); err != nil {
+		_ = db.shed.Close()
+		return nil, err
+	}
+
+	if err := db.initNamespaceSizes(); err != nil {
+		return nil, err
+	}
+
+	// warm up the indexes in the background
+	db.warmUpWG.Add(1)
+	go db.warmUp()
+
 	// start garbage collection worker
 	go db.collectGarbageWorker()
+	// start gc index update worker
+	db.updateGCWG.Add(1)
+	go db.updateGCWorker()
 	return db, nil
 }
 
@@ -449,6 +580,7 @@ func (db *DB) Close() (err error) {
 	go func() {
 		db.updateGCWG.Wait()
 		db.subscritionsWG.Wait()
+		db.warmUpWG.Wait()
 		// wait for gc worker to
 		// return before closing the shed
 		<-db.collectGarbageWorkerDone
@@ -456,6 +588,11 @@ func (db *DB) Close() (err error) {
 	}()
 	select {
 	case <-done:
+		// the marker is left open if the goroutines are still active,
+		// so that the indexes are checked on the next start
+		if err := db.openMarker.Put(markerClosed); err != nil {
+			db.logger.Debugf("localstore: put open marker: %v", err)
+		}
 	case <-time.After(5 * time.Second):
 		db.logger.Errorf("localstore closed with still active goroutines")
 		// Print a full goroutine dump to debug blocking.
@@ -487,6 +624,8 @@ func (db *DB) DebugIndices() (indexInfo map[string]int, err error) {
 		"gcIndex":              db.gcIndex,
 		"gcExcludeIndex":       db.gcExcludeIndex,
 		"pinIndex":             db.pinIndex,
+		"accessCountIndex":     db.accessCountIndex,
+		"coldIndex":            db.coldIndex,
 	} {
 		indexSize, err := v.Count()
 		if err != nil {
@@ -92,57 +92,88 @@ func TestDB(t *testing.T) {
 	}
 }
 
-// TestDB_updateGCSem tests maxParallelUpdateGC limit.
-// This test temporary sets the limit to a low number,
-// makes updateGC function execution time longer by
-// setting a custom testHookUpdateGC function with a sleep
-// and a count current and maximal number of goroutines.
-func TestDB_updateGCSem(t *testing.T) {
-	updateGCSleep := time.Second
-	var count int
-	var max int
-	var mu sync.Mutex
+// TestDB_updateGCQueue tests that the gc updates are coalesced
+// while the updateGC worker is busy and that the queue is limited
+// by maxUpdateGCQueue.
+func TestDB_updateGCQueue(t *testing.T) {
+	var (
+		flushes int
+		mu      sync.Mutex
+		block   = make(chan struct{})
+		flushed = make(chan struct{}, 10)
+	)
 	t.Cleanup(setTestHookUpdateGC(func() {
 		mu.Lock()
-		// add to the count of current goroutines
-		count++
-		if count > max {
-			// set maximal detected numbers of goroutines
-			max = count
-		}
+		flushes++
+		first := flushes == 1
 		mu.Unlock()
 
-		// wait for some time to ensure multiple parallel goroutines
-		time.Sleep(updateGCSleep)
-
-		mu.Lock()
-		count--
-		mu.Unlock()
+		if first {
+			// keep the worker busy with the first write
+			<-block
+		}
+		flushed <- struct{}{}
 	}))
 
-	defer func(m int) { maxParallelUpdateGC = m }(maxParallelUpdateGC)
-	maxParallelUpdateGC = 3
+	defer func(m int) { maxUpdateGCQueue = m }(maxUpdateGCQueue)
+	maxUpdateGCQueue = 3
 
 	db := newTestDB(t, nil)
 
-	ch := generateTestRandomChunk()
+	chunks := make([]infinity.Chunk, 5)
+	for i := range chunks {
+		chunks[i] = generateTestRandomChunk()
+		_, err := db.Put(context.Background(), storage.ModePutUpload, chunks[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
 
-	_, err := db.Put(context.Background(), storage.ModePutUpload, ch)
+	_, err := db.Get(context.Background(), storage.ModeGetRequest, chunks[0].Address())
 	if err != nil {
 		t.Fatal(err)
 	}
+	// wait for the worker to take the first chunk
+	for {
+		db.updateGCQueueMu.Lock()
+		n := len(db.updateGCQueue)
+		db.updateGCQueueMu.Unlock()
+		if n == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
 
-	// get more chunks then maxParallelUpdateGC
-	// in time shorter then updateGCSleep
-	for i := 0; i < 5; i++ {
-		_, err = db.Get(context.Background(), storage.ModeGetRequest, ch.Address())
-		if err != nil {
-			t.Fatal(err)
+	// repeated accesses are coalesced and accesses
+	// beyond the limit are dropped
+	for i := 0; i < 3; i++ {
+		for _, ch := range chunks[1:] {
+			_, err := db.Get(context.Background(), storage.ModeGetRequest, ch.Address())
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	db.updateGCQueueMu.Lock()
+	n := len(db.updateGCQueue)
+	db.updateGCQueueMu.Unlock()
+	if n != maxUpdateGCQueue {
+		t.Errorf("got queue length %v, want %v", n, maxUpdateGCQueue)
+	}
+
+	close(block)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-flushed:
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout waiting for gc update")
 		}
 	}
 
-	if max != maxParallelUpdateGC {
-		t.Errorf("got max %v, want %v", max, maxParallelUpdateGC)
+	mu.Lock()
+	defer mu.Unlock()
+	if flushes != 2 {
+		t.Errorf("got %v gc updates, want %v", flushes, 2)
 	}
 }
 
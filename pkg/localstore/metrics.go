@@ -33,6 +33,13 @@ type metrics struct {
 	GCExcludeWriteBatchError prometheus.Counter
 	GCUpdate                 prometheus.Counter
 	GCUpdateError            prometheus.Counter
+	GCUpdateDropped          prometheus.Counter
+
+	ColdOffload        prometheus.Counter
+	ColdOffloadFailure prometheus.Counter
+	ColdRestore        prometheus.Counter
+	ColdRestoreFailure prometheus.Counter
+	ColdEvict          prometheus.Counter
 
 	ModeGet                       prometheus.Counter
 	ModeGetFailure                prometheus.Counter
@@ -57,6 +64,10 @@ type metrics struct {
 	SubscribePushIterationFailure prometheus.Counter
 
 	GCSize                  prometheus.Gauge
+	PinnedSize              prometheus.Gauge
+	UploadSize              prometheus.Gauge
+	PinnedQuotaExceeded     prometheus.Counter
+	UploadQuotaExceeded     prometheus.Counter
 	GCStoreTimeStamps       prometheus.Gauge
 	GCStoreAccessTimeStamps prometheus.Gauge
 }
@@ -197,6 +208,12 @@ func newMetrics() metrics {
 			Name:      "gc_update_error_count",
 			Help:      "Number of times the gc update had error.",
 		}),
+		GCUpdateDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "gc_update_dropped_count",
+			Help:      "Number of chunk accesses not recorded in the gc index because the update queue was full.",
+		}),
 
 		ModeGet: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: m.Namespace,
@@ -331,6 +348,60 @@ func newMetrics() metrics {
 			Name:      "gc_size",
 			Help:      "Number of elements in Garbage collection index.",
 		}),
+		PinnedSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "pinned_size",
+			Help:      "Number of pinned chunks.",
+		}),
+		UploadSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "upload_size",
+			Help:      "Number of uploaded chunks which are not synced yet.",
+		}),
+		PinnedQuotaExceeded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "pinned_quota_exceeded_count",
+			Help:      "Number of times pinning was refused for exceeding the pinned capacity.",
+		}),
+		UploadQuotaExceeded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "upload_quota_exceeded_count",
+			Help:      "Number of times uploading was refused for exceeding the upload capacity.",
+		}),
+		ColdOffload: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "cold_offload_count",
+			Help:      "Number of chunks collected by the garbage collection offloaded to the cold store.",
+		}),
+		ColdOffloadFailure: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "cold_offload_failure_count",
+			Help:      "Number of chunks collected by the garbage collection which failed to be offloaded to the cold store.",
+		}),
+		ColdRestore: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "cold_restore_count",
+			Help:      "Number of chunks restored from the cold store.",
+		}),
+		ColdRestoreFailure: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "cold_restore_failure_count",
+			Help:      "Number of chunks which failed to be restored from the cold store.",
+		}),
+		ColdEvict: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "cold_evict_count",
+			Help:      "Number of expired chunks evicted from the cold store.",
+		}),
 		GCStoreTimeStamps: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: m.Namespace,
 			Subsystem: subsystem,
@@ -85,52 +85,109 @@ func (db *DB) get(mode storage.ModeGet, addr infinity.Address) (out shed.Item, e
 
 // updateGCItems is called when ModeGetRequest is used
 // for Get or GetMulti to update access time and gc indexes
-// for all returned chunks.
+// for all returned chunks. The updates are queued and
+// written by the updateGCWorker, so that Getters are not
+// slowed down by writes. Multiple accesses to the same
+// chunk before the queue is written are coalesced and
+// counted.
 func (db *DB) updateGCItems(items ...shed.Item) {
-	if db.updateGCSem != nil {
-		// wait before creating new goroutines
-		// if updateGCSem buffer id full
-		db.updateGCSem <- struct{}{}
-	}
-	db.updateGCWG.Add(1)
-	go func() {
-		defer db.updateGCWG.Done()
-		if db.updateGCSem != nil {
-			// free a spot in updateGCSem buffer
-			// for a new goroutine
-			defer func() { <-db.updateGCSem }()
+	db.updateGCQueueMu.Lock()
+	for _, item := range items {
+		key := string(item.Address)
+		if _, ok := db.updateGCQueued[key]; ok {
+			db.updateGCQueued[key]++
+			continue
 		}
+		if len(db.updateGCQueue) >= maxUpdateGCQueue {
+			db.metrics.GCUpdateDropped.Inc()
+			continue
+		}
+		db.updateGCQueued[key] = 1
+		db.updateGCQueue = append(db.updateGCQueue, item)
+	}
+	db.updateGCQueueMu.Unlock()
 
-		db.metrics.GCUpdate.Inc()
-		defer totalTimeMetric(db.metrics.TotalTimeUpdateGC, time.Now())
+	select {
+	case db.updateGCTrigger <- struct{}{}:
+	default:
+	}
+}
 
-		for _, item := range items {
-			err := db.updateGC(item)
-			if err != nil {
-				db.metrics.GCUpdateError.Inc()
-				db.logger.Errorf("localstore update gc: %v", err)
-			}
-		}
-		// if gc update hook is defined, call it
-		if testHookUpdateGC != nil {
-			testHookUpdateGC()
+// updateGCWorker writes the queued gc index updates in
+// a single batch whenever it is triggered. The staleness
+// of the gc index is bounded by the duration of one batch
+// write. The remaining updates are written before the
+// worker returns on close.
+func (db *DB) updateGCWorker() {
+	defer db.updateGCWG.Done()
+
+	for {
+		select {
+		case <-db.updateGCTrigger:
+			db.writeUpdateGCQueue()
+		case <-db.close:
+			db.writeUpdateGCQueue()
+			return
 		}
-	}()
+	}
+}
+
+// writeUpdateGCQueue takes all queued items and updates
+// their access time, access count and gc indexes.
+func (db *DB) writeUpdateGCQueue() {
+	db.updateGCQueueMu.Lock()
+	items := db.updateGCQueue
+	for i := range items {
+		items[i].AccessCount = db.updateGCQueued[string(items[i].Address)]
+	}
+	db.updateGCQueue = nil
+	db.updateGCQueued = make(map[string]uint64)
+	db.updateGCQueueMu.Unlock()
+
+	if len(items) == 0 {
+		return
+	}
+
+	db.metrics.GCUpdate.Inc()
+	defer totalTimeMetric(db.metrics.TotalTimeUpdateGC, time.Now())
+
+	if err := db.updateGC(items...); err != nil {
+		db.metrics.GCUpdateError.Inc()
+		db.logger.Errorf("localstore update gc: %v", err)
+	}
+	// if gc update hook is defined, call it
+	if testHookUpdateGC != nil {
+		testHookUpdateGC()
+	}
 }
 
-// updateGC updates garbage collection index for
-// a single item. Provided item is expected to have
-// only Address and Data fields with non zero values,
-// which is ensured by the get function.
-func (db *DB) updateGC(item shed.Item) (err error) {
+// updateGC updates garbage collection and access count
+// indexes for the items in a single batch. Provided items
+// are expected to have only Address, Data and AccessCount
+// fields with non zero values, which is ensured by the get
+// function.
+func (db *DB) updateGC(items ...shed.Item) (err error) {
 	db.batchMu.Lock()
 	defer db.batchMu.Unlock()
-	if db.gcRunning {
-		db.dirtyAddresses = append(db.dirtyAddresses, infinity.NewAddress(item.Address))
-	}
 
 	batch := new(leveldb.Batch)
+	for _, item := range items {
+		if db.gcRunning {
+			db.dirtyAddresses = append(db.dirtyAddresses, infinity.NewAddress(item.Address))
+		}
+		if err := db.updateAccessCountInBatch(batch, item); err != nil {
+			return err
+		}
+		if err := db.updateGCInBatch(batch, item); err != nil {
+			return err
+		}
+	}
+	return db.shed.WriteBatch(batch)
+}
 
+// updateGCInBatch adds the access time and gc index
+// updates of a single item to the batch.
+func (db *DB) updateGCInBatch(batch *leveldb.Batch, item shed.Item) (err error) {
 	// update accessTimeStamp in retrieve, gc
 
 	i, err := db.retrievalAccessIndex.Get(item)
@@ -171,8 +228,7 @@ func (db *DB) updateGC(item shed.Item) (err error) {
 			return err
 		}
 	}
-
-	return db.shed.WriteBatch(batch)
+	return nil
 }
 
 // testHookUpdateGC is a hook that can provide
@@ -66,6 +66,7 @@ func (db *DB) put(mode storage.ModePut, chs ...infinity.Chunk) (exist []bool, er
 	// variables that provide information for operations
 	// to be done after write batch function successfully executes
 	var gcSizeChange int64                      // number to add or subtract from gcSize
+	var pinnedChange, uploadChange int64        // numbers to add to the pinned and uploaded chunks
 	var triggerPushFeed bool                    // signal push feed subscriptions to iterate
 	triggerPullFeed := make(map[uint8]struct{}) // signal pull feed subscriptions to iterate
 
@@ -92,10 +93,11 @@ func (db *DB) put(mode storage.ModePut, chs ...infinity.Chunk) (exist []bool, er
 			gcSizeChange += c
 
 			if mode == storage.ModePutRequestPin {
-				err = db.setPin(batch, ch.Address())
+				p, err := db.setPin(batch, ch.Address())
 				if err != nil {
 					return nil, err
 				}
+				pinnedChange += p
 			}
 		}
 
@@ -115,13 +117,15 @@ func (db *DB) put(mode storage.ModePut, chs ...infinity.Chunk) (exist []bool, er
 				// after the batch is successfully written
 				triggerPullFeed[db.po(ch.Address())] = struct{}{}
 				triggerPushFeed = true
+				uploadChange++
 			}
 			gcSizeChange += c
 			if mode == storage.ModePutUploadPin {
-				err = db.setPin(batch, ch.Address())
+				p, err := db.setPin(batch, ch.Address())
 				if err != nil {
 					return nil, err
 				}
+				pinnedChange += p
 			}
 		}
 
@@ -148,6 +152,10 @@ func (db *DB) put(mode storage.ModePut, chs ...infinity.Chunk) (exist []bool, er
 		return nil, ErrInvalidMode
 	}
 
+	if err := db.checkQuotas(pinnedChange, uploadChange); err != nil {
+		return nil, err
+	}
+
 	for po, id := range binIDs {
 		db.binIDs.PutInBatch(batch, uint64(po), id)
 	}
@@ -161,6 +169,7 @@ func (db *DB) put(mode storage.ModePut, chs ...infinity.Chunk) (exist []bool, er
 	if err != nil {
 		return nil, err
 	}
+	db.updateNamespaceSizes(pinnedChange, uploadChange)
 
 	for po := range triggerPullFeed {
 		db.triggerPullSubscriptions(po)
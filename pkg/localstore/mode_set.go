@@ -59,16 +59,18 @@ func (db *DB) set(mode storage.ModeSet, addrs ...infinity.Address) (err error) {
 	// variables that provide information for operations
 	// to be done after write batch function successfully executes
 	var gcSizeChange int64                      // number to add or subtract from gcSize
+	var pinnedChange, uploadChange int64        // numbers to add to the pinned and uploaded chunks
 	triggerPullFeed := make(map[uint8]struct{}) // signal pull feed subscriptions to iterate
 
 	switch mode {
 	case storage.ModeSetSync:
 		for _, addr := range addrs {
-			c, err := db.setSync(batch, addr, mode)
+			c, u, err := db.setSync(batch, addr, mode)
 			if err != nil {
 				return err
 			}
 			gcSizeChange += c
+			uploadChange += u
 		}
 
 	case storage.ModeSetRemove:
@@ -91,22 +93,28 @@ func (db *DB) set(mode storage.ModeSet, addrs ...infinity.Address) (err error) {
 				return storage.ErrNotFound
 			}
 
-			err = db.setPin(batch, addr)
+			c, err := db.setPin(batch, addr)
 			if err != nil {
 				return err
 			}
+			pinnedChange += c
 		}
 	case storage.ModeSetUnpin:
 		for _, addr := range addrs {
-			err := db.setUnpin(batch, addr)
+			c, err := db.setUnpin(batch, addr)
 			if err != nil {
 				return err
 			}
+			pinnedChange += c
 		}
 	default:
 		return ErrInvalidMode
 	}
 
+	if err := db.checkQuotas(pinnedChange, uploadChange); err != nil {
+		return err
+	}
+
 	err = db.incGCSizeInBatch(batch, gcSizeChange)
 	if err != nil {
 		return err
@@ -116,6 +124,7 @@ func (db *DB) set(mode storage.ModeSet, addrs ...infinity.Address) (err error) {
 	if err != nil {
 		return err
 	}
+	db.updateNamespaceSizes(pinnedChange, uploadChange)
 	for po := range triggerPullFeed {
 		db.triggerPullSubscriptions(po)
 	}
@@ -126,8 +135,9 @@ func (db *DB) set(mode storage.ModeSet, addrs ...infinity.Address) (err error) {
 // - ModeSetSync - the corresponding tag is incremented, then item is removed
 //   from push sync index
 // - update to gc index happens given item does not exist in pin index
-// Provided batch is updated.
-func (db *DB) setSync(batch *leveldb.Batch, addr infinity.Address, mode storage.ModeSet) (gcSizeChange int64, err error) {
+// Provided batch is updated. The returned uploadChange is the change of the
+// number of the chunks in the push sync index.
+func (db *DB) setSync(batch *leveldb.Batch, addr infinity.Address, mode storage.ModeSet) (gcSizeChange, uploadChange int64, err error) {
 	item := addressToItem(addr)
 
 	// need to get access timestamp here as it is not
@@ -143,11 +153,11 @@ func (db *DB) setSync(batch *leveldb.Batch, addr infinity.Address, mode storage.
 			// if it is there
 			err = db.pushIndex.DeleteInBatch(batch, item)
 			if err != nil {
-				return 0, err
+				return 0, 0, err
 			}
-			return 0, nil
+			return 0, 0, nil
 		}
-		return 0, err
+		return 0, 0, err
 	}
 	item.StoreTimestamp = i.StoreTimestamp
 	item.BinID = i.BinID
@@ -160,8 +170,10 @@ func (db *DB) setSync(batch *leveldb.Batch, addr infinity.Address, mode storage.
 			// but this function is called with ModeSetSync
 			db.logger.Debugf("localstore: chunk with address %s not found in push index", addr)
 		} else {
-			return 0, err
+			return 0, 0, err
 		}
+	} else {
+		uploadChange--
 	}
 	if err == nil && db.tags != nil && i.Tag != 0 {
 		t, err := db.tags.Get(i.Tag)
@@ -172,14 +184,14 @@ func (db *DB) setSync(batch *leveldb.Batch, addr infinity.Address, mode storage.
 		} else {
 			err = t.Inc(tags.StateSynced)
 			if err != nil {
-				return 0, err
+				return 0, 0, err
 			}
 		}
 	}
 
 	err = db.pushIndex.DeleteInBatch(batch, item)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	i, err = db.retrievalAccessIndex.Get(item)
@@ -188,34 +200,34 @@ func (db *DB) setSync(batch *leveldb.Batch, addr infinity.Address, mode storage.
 		item.AccessTimestamp = i.AccessTimestamp
 		err = db.gcIndex.DeleteInBatch(batch, item)
 		if err != nil {
-			return 0, err
+			return 0, 0, err
 		}
 		gcSizeChange--
 	case errors.Is(err, leveldb.ErrNotFound):
 		// the chunk is not accessed before
 	default:
-		return 0, err
+		return 0, 0, err
 	}
 	item.AccessTimestamp = now()
 	err = db.retrievalAccessIndex.PutInBatch(batch, item)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	// Add in gcIndex only if this chunk is not pinned
 	ok, err := db.pinIndex.Has(item)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	if !ok {
 		err = db.gcIndex.PutInBatch(batch, item)
 		if err != nil {
-			return 0, err
+			return 0, 0, err
 		}
 		gcSizeChange++
 	}
 
-	return gcSizeChange, nil
+	return gcSizeChange, uploadChange, nil
 }
 
 // setRemove removes the chunk by updating indexes:
@@ -250,6 +262,10 @@ func (db *DB) setRemove(batch *leveldb.Batch, addr infinity.Address) (gcSizeChan
 	if err != nil {
 		return 0, err
 	}
+	err = db.accessCountIndex.DeleteInBatch(batch, item)
+	if err != nil {
+		return 0, err
+	}
 	err = db.pullIndex.DeleteInBatch(batch, item)
 	if err != nil {
 		return 0, err
@@ -270,8 +286,9 @@ func (db *DB) setRemove(batch *leveldb.Batch, addr infinity.Address) (gcSizeChan
 
 // setPin increments pin counter for the chunk by updating
 // pin index and sets the chunk to be excluded from garbage collection.
-// Provided batch is updated.
-func (db *DB) setPin(batch *leveldb.Batch, addr infinity.Address) (err error) {
+// Provided batch is updated. The returned pinnedChange is the change of the
+// number of the pinned chunks.
+func (db *DB) setPin(batch *leveldb.Batch, addr infinity.Address) (pinnedChange int64, err error) {
 	item := addressToItem(addr)
 
 	// Get the existing pin counter of the chunk
@@ -281,14 +298,15 @@ func (db *DB) setPin(batch *leveldb.Batch, addr infinity.Address) (err error) {
 		if errors.Is(err, leveldb.ErrNotFound) {
 			// If this Address is not present in DB, then its a new entry
 			existingPinCounter = 0
+			pinnedChange = 1
 
 			// Add in gcExcludeIndex of the chunk is not pinned already
 			err = db.gcExcludeIndex.PutInBatch(batch, item)
 			if err != nil {
-				return err
+				return 0, err
 			}
 		} else {
-			return err
+			return 0, err
 		}
 	} else {
 		existingPinCounter = pinnedChunk.PinCounter
@@ -298,21 +316,22 @@ func (db *DB) setPin(batch *leveldb.Batch, addr infinity.Address) (err error) {
 	item.PinCounter = existingPinCounter + 1
 	err = db.pinIndex.PutInBatch(batch, item)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	return nil
+	return pinnedChange, nil
 }
 
 // setUnpin decrements pin counter for the chunk by updating pin index.
-// Provided batch is updated.
-func (db *DB) setUnpin(batch *leveldb.Batch, addr infinity.Address) (err error) {
+// Provided batch is updated. The returned pinnedChange is the change of the
+// number of the pinned chunks.
+func (db *DB) setUnpin(batch *leveldb.Batch, addr infinity.Address) (pinnedChange int64, err error) {
 	item := addressToItem(addr)
 
 	// Get the existing pin counter of the chunk
 	pinnedChunk, err := db.pinIndex.Get(item)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Decrement the pin counter or
@@ -321,14 +340,15 @@ func (db *DB) setUnpin(batch *leveldb.Batch, addr infinity.Address) (err error)
 		item.PinCounter = pinnedChunk.PinCounter - 1
 		err = db.pinIndex.PutInBatch(batch, item)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	} else {
 		err = db.pinIndex.DeleteInBatch(batch, item)
 		if err != nil {
-			return err
+			return 0, err
 		}
+		pinnedChange = -1
 	}
 
-	return nil
+	return pinnedChange, nil
 }
@@ -0,0 +1,102 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package localstore
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/shed"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+var _ storage.PopularityReporter = (*DB)(nil)
+
+// updateAccessCountInBatch adds the number of the requests of the item since
+// the last update, at least one, to its access count and sets its last access
+// time.
+func (db *DB) updateAccessCountInBatch(batch *leveldb.Batch, item shed.Item) error {
+	count := item.AccessCount
+	if count == 0 {
+		count = 1
+	}
+	i, err := db.accessCountIndex.Get(item)
+	switch {
+	case err == nil:
+		count += i.AccessCount
+	case errors.Is(err, leveldb.ErrNotFound):
+		// first request of the chunk
+	default:
+		return err
+	}
+	item.AccessCount = count
+	item.AccessTimestamp = now()
+	return db.accessCountIndex.PutInBatch(batch, item)
+}
+
+// MostAccessed returns at most n locally stored chunks with the most
+// requests, ordered from the most requested. The chunks with the same
+// number of requests are ordered from the last accessed.
+func (db *DB) MostAccessed(n int) ([]storage.ChunkAccess, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	top := make(accessHeap, 0, n)
+	err := db.accessCountIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		if len(top) < n {
+			heap.Push(&top, item)
+		} else if !top.less(item, top[0]) {
+			top[0] = item
+			heap.Fix(&top, 0)
+		}
+		return false, nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		return top.less(top[j], top[i])
+	})
+	chunks := make([]storage.ChunkAccess, 0, len(top))
+	for _, item := range top {
+		chunks = append(chunks, storage.ChunkAccess{
+			Address:    infinity.NewAddress(item.Address),
+			Count:      item.AccessCount,
+			LastAccess: time.Unix(0, item.AccessTimestamp),
+		})
+	}
+	return chunks, nil
+}
+
+// accessHeap is the min-heap of the access count index items with the least
+// requested item on the top.
+type accessHeap []shed.Item
+
+// less reports whether the item a has fewer requests than the item b, or the
+// same number of requests and an earlier last access.
+func (accessHeap) less(a, b shed.Item) bool {
+	if a.AccessCount != b.AccessCount {
+		return a.AccessCount < b.AccessCount
+	}
+	return a.AccessTimestamp < b.AccessTimestamp
+}
+
+func (h accessHeap) Len() int            { return len(h) }
+func (h accessHeap) Less(i, j int) bool  { return h.less(h[i], h[j]) }
+func (h accessHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *accessHeap) Push(x interface{}) { *h = append(*h, x.(shed.Item)) }
+
+func (h *accessHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
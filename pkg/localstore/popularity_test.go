@@ -0,0 +1,105 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package localstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+// TestMostAccessed validates that the requests of the chunks are counted and
+// the most requested chunks are reported.
+func TestMostAccessed(t *testing.T) {
+	db := newTestDB(t, nil)
+
+	accessTimestamp := time.Now().UTC().UnixNano()
+	defer setNow(func() (t int64) {
+		return accessTimestamp
+	})()
+
+	testHookUpdateGCChan := make(chan struct{})
+	defer setTestHookUpdateGC(func() {
+		testHookUpdateGCChan <- struct{}{}
+	})()
+
+	chunks := make([]infinity.Chunk, 3)
+	for i := range chunks {
+		chunks[i] = generateTestRandomChunk()
+		if _, err := db.Put(context.Background(), storage.ModePutUpload, chunks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, requests := range []int{3, 1, 2} {
+		for j := 0; j < requests; j++ {
+			if _, err := db.Get(context.Background(), storage.ModeGetRequest, chunks[i].Address()); err != nil {
+				t.Fatal(err)
+			}
+			<-testHookUpdateGCChan
+		}
+	}
+
+	checkMostAccessed := func(t *testing.T, n int, want []storage.ChunkAccess) {
+		t.Helper()
+
+		got, err := db.MostAccessed(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %d chunks, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if !got[i].Address.Equal(want[i].Address) {
+				t.Errorf("chunk %d: got address %s, want %s", i, got[i].Address, want[i].Address)
+			}
+			if got[i].Count != want[i].Count {
+				t.Errorf("chunk %d: got count %d, want %d", i, got[i].Count, want[i].Count)
+			}
+			if !got[i].LastAccess.Equal(want[i].LastAccess) {
+				t.Errorf("chunk %d: got last access %s, want %s", i, got[i].LastAccess, want[i].LastAccess)
+			}
+		}
+	}
+	access := func(i int, count uint64) storage.ChunkAccess {
+		return storage.ChunkAccess{
+			Address:    chunks[i].Address(),
+			Count:      count,
+			LastAccess: time.Unix(0, accessTimestamp),
+		}
+	}
+
+	t.Run("all", func(t *testing.T) {
+		checkMostAccessed(t, 10, []storage.ChunkAccess{access(0, 3), access(2, 2), access(1, 1)})
+	})
+
+	t.Run("top", func(t *testing.T) {
+		checkMostAccessed(t, 2, []storage.ChunkAccess{access(0, 3), access(2, 2)})
+	})
+
+	t.Run("none", func(t *testing.T) {
+		checkMostAccessed(t, 0, nil)
+	})
+
+	t.Run("coalesced requests", func(t *testing.T) {
+		item := addressToItem(chunks[1].Address())
+		db.updateGCItems(item, item, item)
+		<-testHookUpdateGCChan
+
+		checkMostAccessed(t, 10, []storage.ChunkAccess{access(1, 4), access(0, 3), access(2, 2)})
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		if err := db.Set(context.Background(), storage.ModeSetRemove, chunks[1].Address()); err != nil {
+			t.Fatal(err)
+		}
+
+		checkMostAccessed(t, 10, []storage.ChunkAccess{access(0, 3), access(2, 2)})
+	})
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package localstore
+
+import (
+	"fmt"
+
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+var (
+	// ErrPinnedQuotaExceeded is returned when pinning a chunk would exceed
+	// the capacity of the pinned chunks. Pinned chunks are never garbage
+	// collected, so the chunks have to be unpinned to make space.
+	ErrPinnedQuotaExceeded = fmt.Errorf("pinned %w", storage.ErrQuotaExceeded)
+	// ErrUploadQuotaExceeded is returned when uploading a chunk would exceed
+	// the capacity of the chunks which are not synced yet. The uploaded
+	// chunks are not garbage collected before they are synced, so the upload
+	// can be retried when the syncing catches up.
+	ErrUploadQuotaExceeded = fmt.Errorf("upload %w", storage.ErrQuotaExceeded)
+)
+
+// namespaceSizes counts the pinned chunks and the uploaded chunks which are
+// not synced yet. The request cache is counted by the gcSize field. It must be
+// accessed under the db.batchMu lock.
+type namespaceSizes struct {
+	pinned uint64
+	upload uint64
+}
+
+// initNamespaceSizes counts the chunks in the pin and the push indexes.
+func (db *DB) initNamespaceSizes() error {
+	pinned, err := db.pinIndex.Count()
+	if err != nil {
+		return fmt.Errorf("count pinned chunks: %w", err)
+	}
+	upload, err := db.pushIndex.Count()
+	if err != nil {
+		return fmt.Errorf("count uploaded chunks: %w", err)
+	}
+
+	db.sizes.pinned = uint64(pinned)
+	db.sizes.upload = uint64(upload)
+	db.metrics.PinnedSize.Set(float64(pinned))
+	db.metrics.UploadSize.Set(float64(upload))
+	return nil
+}
+
+// checkQuotas returns an error if the changes of the number of the pinned and
+// the uploaded chunks would exceed their capacities. Only the increases are
+// limited. It must be called under the db.batchMu lock.
+func (db *DB) checkQuotas(pinnedChange, uploadChange int64) error {
+	if exceedsQuota(db.sizes.pinned, pinnedChange, db.pinnedCapacity) {
+		db.metrics.PinnedQuotaExceeded.Inc()
+		return ErrPinnedQuotaExceeded
+	}
+	if exceedsQuota(db.sizes.upload, uploadChange, db.uploadCapacity) {
+		db.metrics.UploadQuotaExceeded.Inc()
+		return ErrUploadQuotaExceeded
+	}
+	return nil
+}
+
+// updateNamespaceSizes applies the changes of the number of the pinned and
+// the uploaded chunks after the batch is written. It must be called under the
+// db.batchMu lock.
+func (db *DB) updateNamespaceSizes(pinnedChange, uploadChange int64) {
+	db.sizes.pinned = addChange(db.sizes.pinned, pinnedChange)
+	db.sizes.upload = addChange(db.sizes.upload, uploadChange)
+	db.metrics.PinnedSize.Set(float64(db.sizes.pinned))
+	db.metrics.UploadSize.Set(float64(db.sizes.upload))
+}
+
+// Usage returns the number of the chunks in the pinned, cache and upload
+// namespaces and their capacities.
+func (db *DB) Usage() (storage.Usage, error) {
+	db.batchMu.Lock()
+	defer db.batchMu.Unlock()
+
+	gcSize, err := db.gcSize.Get()
+	if err != nil {
+		return storage.Usage{}, err
+	}
+
+	return storage.Usage{
+		Pinned: storage.NamespaceUsage{Size: db.sizes.pinned, Capacity: db.pinnedCapacity},
+		Cache:  storage.NamespaceUsage{Size: gcSize, Capacity: db.capacity},
+		Upload: storage.NamespaceUsage{Size: db.sizes.upload, Capacity: db.uploadCapacity},
+	}, nil
+}
+
+// exceedsQuota reports if the size increased by the change exceeds the
+// capacity, zero capacity is not limited.
+func exceedsQuota(size uint64, change int64, capacity uint64) bool {
+	return capacity > 0 && change > 0 && size+uint64(change) > capacity
+}
+
+// addChange returns the size changed by the change, which can be negative,
+// protecting it from the underflow.
+func addChange(size uint64, change int64) uint64 {
+	if change >= 0 {
+		return size + uint64(change)
+	}
+	if c := uint64(-change); c < size {
+		return size - c
+	}
+	return 0
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package localstore
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+// TestUploadQuota checks that the uploads are refused over the upload
+// capacity until the uploaded chunks are synced.
+func TestUploadQuota(t *testing.T) {
+	db := newTestDB(t, &Options{UploadCapacity: 3})
+	chunks := generateTestRandomChunks(4)
+
+	_, err := db.Put(context.Background(), storage.ModePutUpload, chunks[:3]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Put(context.Background(), storage.ModePutUpload, chunks[3])
+	if !errors.Is(err, ErrUploadQuotaExceeded) || !errors.Is(err, storage.ErrQuotaExceeded) {
+		t.Fatalf("got error %v, want %v", err, ErrUploadQuotaExceeded)
+	}
+
+	// the chunks which are not uploaded are not limited
+	_, err = db.Put(context.Background(), storage.ModePutRequest, generateTestRandomChunk())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Set(context.Background(), storage.ModeSetSync, chunks[0].Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Put(context.Background(), storage.ModePutUpload, chunks[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := db.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := storage.Usage{
+		Pinned: storage.NamespaceUsage{Size: 0, Capacity: 0},
+		Cache:  storage.NamespaceUsage{Size: 2, Capacity: defaultCapacity},
+		Upload: storage.NamespaceUsage{Size: 3, Capacity: 3},
+	}
+	if usage != want {
+		t.Fatalf("got usage %+v, want %+v", usage, want)
+	}
+}
+
+// TestPinnedQuota checks that the pinning is refused over the pinned capacity
+// until the chunks are unpinned, and that pinning the pinned chunks again is
+// not limited.
+func TestPinnedQuota(t *testing.T) {
+	db := newTestDB(t, &Options{PinnedCapacity: 2})
+	chunks := generateTestRandomChunks(3)
+
+	_, err := db.Put(context.Background(), storage.ModePutUpload, chunks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Set(context.Background(), storage.ModeSetPin, chunks[0].Address(), chunks[1].Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Set(context.Background(), storage.ModeSetPin, chunks[2].Address())
+	if !errors.Is(err, ErrPinnedQuotaExceeded) {
+		t.Fatalf("got error %v, want %v", err, ErrPinnedQuotaExceeded)
+	}
+	if _, err := db.PinCounter(chunks[2].Address()); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, storage.ErrNotFound)
+	}
+
+	err = db.Set(context.Background(), storage.ModeSetPin, chunks[0].Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Set(context.Background(), storage.ModeSetUnpin, chunks[1].Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Set(context.Background(), storage.ModeSetPin, chunks[2].Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := db.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Pinned.Size != 2 {
+		t.Fatalf("got %d pinned chunks, want 2", usage.Pinned.Size)
+	}
+}
+
+// TestNamespaceSizesPersisted checks that the numbers of the pinned and the
+// uploaded chunks are counted when the database is opened.
+func TestNamespaceSizesPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localstore-quota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	baseKey := make([]byte, 32)
+	logger := logging.New(ioutil.Discard, 0)
+
+	db, err := New(dir, baseKey, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := generateTestRandomChunks(3)
+	_, err = db.Put(context.Background(), storage.ModePutUploadPin, chunks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = New(dir, baseKey, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	usage, err := db.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Pinned.Size != 3 || usage.Upload.Size != 3 {
+		t.Fatalf("got %d pinned and %d uploaded chunks, want 3 and 3", usage.Pinned.Size, usage.Upload.Size)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package localstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yanhuangpai/voyager/pkg/cac"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/shed"
+	"github.com/yanhuangpai/voyager/pkg/soc"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+// VerifyOptions controls the handling of the corrupted chunks found by
+// Verify.
+type VerifyOptions struct {
+	// Remove removes the corrupted chunks.
+	Remove bool
+	// Retrieve, if set, is used to retrieve the removed corrupted chunks
+	// again, which are then stored in place of the corrupted ones.
+	Retrieve func(ctx context.Context, addr infinity.Address) (infinity.Chunk, error)
+}
+
+// VerifyResult holds the result of the verification of the stored chunks.
+type VerifyResult struct {
+	// Total is the number of the verified chunks.
+	Total int
+	// Corrupted are the addresses of the chunks with the data not matching
+	// the address.
+	Corrupted []infinity.Address
+	// Removed is the number of the removed corrupted chunks.
+	Removed int
+	// Repaired is the number of the corrupted chunks which are retrieved
+	// and stored again.
+	Repaired int
+}
+
+// Verify iterates over all stored chunks and checks that their data matches
+// their content address or single owner chunk address. The corrupted chunks
+// are reported and, depending on the options, removed and retrieved again.
+func (db *DB) Verify(ctx context.Context, o VerifyOptions) (*VerifyResult, error) {
+	var r VerifyResult
+	err := db.retrievalDataIndex.Iterate(func(item shed.Item) (stop bool, err error) {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		default:
+		}
+
+		r.Total++
+		ch := infinity.NewChunk(infinity.NewAddress(item.Address), item.Data)
+		if cac.Valid(ch) || soc.Valid(ch) {
+			return false, nil
+		}
+		r.Corrupted = append(r.Corrupted, infinity.NewAddress(append([]byte(nil), item.Address...)))
+		return false, nil
+	}, nil)
+	if err != nil {
+		return &r, fmt.Errorf("verify: %w", err)
+	}
+
+	if !o.Remove && o.Retrieve == nil {
+		return &r, nil
+	}
+	for _, addr := range r.Corrupted {
+		if err := db.Set(ctx, storage.ModeSetRemove, addr); err != nil {
+			return &r, fmt.Errorf("verify: remove %s: %w", addr, err)
+		}
+		r.Removed++
+
+		if o.Retrieve == nil {
+			continue
+		}
+		ch, err := o.Retrieve(ctx, addr)
+		if err != nil {
+			db.logger.Debugf("localstore verify: retrieve %s: %v", addr, err)
+			continue
+		}
+		// the pinned chunks are kept out of the garbage collection as their
+		// pin counters are not removed
+		if _, err := db.Put(ctx, storage.ModePutRequest, ch); err != nil {
+			return &r, fmt.Errorf("verify: put %s: %w", addr, err)
+		}
+		r.Repaired++
+	}
+	return &r, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package localstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+// TestDB_Verify validates that the corrupted chunks are found, removed and
+// retrieved again.
+func TestDB_Verify(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		opts     func(retrieve func(context.Context, infinity.Address) (infinity.Chunk, error)) VerifyOptions
+		removed  int
+		repaired int
+		stored   bool
+	}{
+		{
+			name: "report",
+			opts: func(_ func(context.Context, infinity.Address) (infinity.Chunk, error)) VerifyOptions {
+				return VerifyOptions{}
+			},
+			stored: true,
+		},
+		{
+			name: "remove",
+			opts: func(_ func(context.Context, infinity.Address) (infinity.Chunk, error)) VerifyOptions {
+				return VerifyOptions{Remove: true}
+			},
+			removed: 1,
+		},
+		{
+			name: "retrieve",
+			opts: func(retrieve func(context.Context, infinity.Address) (infinity.Chunk, error)) VerifyOptions {
+				return VerifyOptions{Retrieve: retrieve}
+			},
+			removed:  1,
+			repaired: 1,
+			stored:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t, nil)
+			ctx := context.Background()
+
+			chunks := make([]infinity.Chunk, 10)
+			for i := range chunks {
+				chunks[i] = generateTestRandomChunk()
+			}
+			if _, err := db.Put(ctx, storage.ModePutUpload, chunks...); err != nil {
+				t.Fatal(err)
+			}
+
+			// corrupt the data of a chunk
+			corrupted := chunks[3]
+			item, err := db.retrievalDataIndex.Get(addressToItem(corrupted.Address()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			item.Data = append([]byte(nil), item.Data...)
+			item.Data[len(item.Data)-1]++
+			if err := db.retrievalDataIndex.Put(item); err != nil {
+				t.Fatal(err)
+			}
+
+			retrieve := func(_ context.Context, addr infinity.Address) (infinity.Chunk, error) {
+				if !addr.Equal(corrupted.Address()) {
+					return nil, errors.New("unexpected retrieval")
+				}
+				return corrupted, nil
+			}
+			r, err := db.Verify(ctx, tc.opts(retrieve))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Total != len(chunks) {
+				t.Errorf("got total %v, want %v", r.Total, len(chunks))
+			}
+			if len(r.Corrupted) != 1 || !r.Corrupted[0].Equal(corrupted.Address()) {
+				t.Fatalf("got corrupted %v, want %v", r.Corrupted, corrupted.Address())
+			}
+			if r.Removed != tc.removed {
+				t.Errorf("got removed %v, want %v", r.Removed, tc.removed)
+			}
+			if r.Repaired != tc.repaired {
+				t.Errorf("got repaired %v, want %v", r.Repaired, tc.repaired)
+			}
+
+			has, err := db.Has(ctx, corrupted.Address())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if has != tc.stored {
+				t.Fatalf("got stored %v, want %v", has, tc.stored)
+			}
+			if tc.repaired > 0 {
+				got, err := db.Get(ctx, storage.ModeGetRequest, corrupted.Address())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !got.Equal(corrupted) {
+					t.Fatal("repaired chunk data mismatch")
+				}
+			}
+		})
+	}
+}
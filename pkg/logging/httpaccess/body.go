@@ -0,0 +1,144 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpaccess
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/rand"
+	"mime"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	// ErrInvalidSampleRate is returned when the sample rate of the body
+	// capture is not between 0 and 1.
+	ErrInvalidSampleRate = errors.New("sample rate must be between 0 and 1")
+	// ErrInvalidMaxSize is returned when the maximal size of the captured
+	// bodies is negative.
+	ErrInvalidMaxSize = errors.New("max size must not be negative")
+)
+
+// redacted replaces the values of the secrets in the captured bodies.
+const redacted = "[REDACTED]"
+
+const secretNames = `[^"=&]*(?:password|passphrase|secret|token|private[-_]?key|authorization)[^"=&]*`
+
+var (
+	jsonSecretRe = regexp.MustCompile(`(?i)("` + secretNames + `"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	formSecretRe = regexp.MustCompile(`(?i)((?:^|&)` + secretNames + `=)[^&]*`)
+)
+
+// BodyCapture controls the capture of the request and response bodies of a
+// sample of the requests in the access log. It is safe to change it while the
+// requests are served.
+type BodyCapture struct {
+	mu         sync.RWMutex
+	sampleRate float64
+	maxSize    int
+}
+
+// NewBodyCapture returns a BodyCapture logging the bodies of the sampleRate
+// fraction of the requests, each one cut to the maxSize bytes.
+func NewBodyCapture(sampleRate float64, maxSize int) (*BodyCapture, error) {
+	c := new(BodyCapture)
+	if err := c.Set(sampleRate, maxSize); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Set changes the sample rate and the maximal size of the captured bodies.
+// The sample rate of 0 or the maximal size of 0 disables the capture.
+func (c *BodyCapture) Set(sampleRate float64, maxSize int) error {
+	if sampleRate < 0 || sampleRate > 1 {
+		return ErrInvalidSampleRate
+	}
+	if maxSize < 0 {
+		return ErrInvalidMaxSize
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sampleRate = sampleRate
+	c.maxSize = maxSize
+	return nil
+}
+
+// Get returns the sample rate and the maximal size of the captured bodies.
+func (c *BodyCapture) Get() (sampleRate float64, maxSize int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.sampleRate, c.maxSize
+}
+
+// sample returns the maximal size of the captured bodies if the bodies of the
+// request should be captured.
+func (c *BodyCapture) sample() (maxSize int, ok bool) {
+	if c == nil {
+		return 0, false
+	}
+	sampleRate, maxSize := c.Get()
+	if sampleRate <= 0 || maxSize <= 0 {
+		return 0, false
+	}
+	if sampleRate < 1 && rand.Float64() >= sampleRate {
+		return 0, false
+	}
+	return maxSize, true
+}
+
+// bodyBuffer keeps the first maxSize bytes written to it.
+type bodyBuffer struct {
+	buf       bytes.Buffer
+	maxSize   int
+	truncated bool
+}
+
+func newBodyBuffer(maxSize int) *bodyBuffer {
+	return &bodyBuffer{maxSize: maxSize}
+}
+
+func (b *bodyBuffer) Write(p []byte) (int, error) {
+	if n := b.maxSize - b.buf.Len(); len(p) > n {
+		b.buf.Write(p[:n])
+		b.truncated = true
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// String returns the captured body with the values of the secrets redacted.
+// Bodies which are not valid UTF-8 text are base64 encoded.
+func (b *bodyBuffer) String(contentType string) string {
+	data := b.buf.Bytes()
+	if !utf8.Valid(data) {
+		return "base64:" + base64.StdEncoding.EncodeToString(data)
+	}
+	s := jsonSecretRe.ReplaceAllString(string(data), `${1}"`+redacted+`"`)
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/x-www-form-urlencoded" {
+		s = formSecretRe.ReplaceAllString(s, "${1}"+redacted)
+	}
+	return s
+}
+
+// captureReader copies the read request body to the buffer.
+type captureReader struct {
+	io.ReadCloser
+	body *bodyBuffer
+}
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	_, _ = r.body.Write(p[:n])
+	return n, err
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package httpaccess_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/logging/httpaccess"
+)
+
+func TestBodyCapture(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		sampleRate       float64
+		maxSize          int
+		contentType      string
+		requestBody      string
+		wantRequestBody  interface{}
+		wantResponseBody interface{}
+		wantTruncated    interface{}
+	}{
+		{
+			name:             "disabled",
+			sampleRate:       0,
+			maxSize:          100,
+			requestBody:      `{"name":"voyager"}`,
+			wantRequestBody:  nil,
+			wantResponseBody: nil,
+		},
+		{
+			name:             "json",
+			sampleRate:       1,
+			maxSize:          100,
+			contentType:      "application/json",
+			requestBody:      `{"name":"voyager","password": "hunter2","accessToken":"abc"}`,
+			wantRequestBody:  `{"name":"voyager","password": "[REDACTED]","accessToken":"[REDACTED]"}`,
+			wantResponseBody: "response",
+		},
+		{
+			name:             "form",
+			sampleRate:       1,
+			maxSize:          100,
+			contentType:      "application/x-www-form-urlencoded",
+			requestBody:      "name=voyager&passphrase=hunter2",
+			wantRequestBody:  "name=voyager&passphrase=[REDACTED]",
+			wantResponseBody: "response",
+		},
+		{
+			name:             "binary",
+			sampleRate:       1,
+			maxSize:          100,
+			contentType:      "application/octet-stream",
+			requestBody:      "\xff\xfe",
+			wantRequestBody:  "base64://4=",
+			wantResponseBody: "response",
+		},
+		{
+			name:             "truncated",
+			sampleRate:       1,
+			maxSize:          4,
+			requestBody:      "voyager",
+			wantRequestBody:  "voya",
+			wantResponseBody: "resp",
+			wantTruncated:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := logging.New(&buf, logrus.InfoLevel, logging.WithJSONFormat())
+
+			c, err := httpaccess.NewBodyCapture(tc.sampleRate, tc.maxSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := httpaccess.NewHTTPAccessLogHandler(logger, logrus.InfoLevel, nil, "api access", httpaccess.WithBodyCapture(c))(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if _, err := ioutil.ReadAll(r.Body); err != nil {
+						t.Error(err)
+					}
+					_, _ = w.Write([]byte("response"))
+				}),
+			)
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.requestBody))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			var entry map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatal(err)
+			}
+			if v := entry["request-body"]; v != tc.wantRequestBody {
+				t.Errorf("got request body %v, want %v", v, tc.wantRequestBody)
+			}
+			if v := entry["response-body"]; v != tc.wantResponseBody {
+				t.Errorf("got response body %v, want %v", v, tc.wantResponseBody)
+			}
+			if v := entry["request-body-truncated"]; v != tc.wantTruncated {
+				t.Errorf("got request body truncated %v, want %v", v, tc.wantTruncated)
+			}
+			if v := entry["response-body-truncated"]; v != tc.wantTruncated {
+				t.Errorf("got response body truncated %v, want %v", v, tc.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestBodyCaptureSet(t *testing.T) {
+	c, err := httpaccess.NewBodyCapture(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Set(1.5, 10); !errors.Is(err, httpaccess.ErrInvalidSampleRate) {
+		t.Errorf("got error %v, want %v", err, httpaccess.ErrInvalidSampleRate)
+	}
+	if err := c.Set(0.5, -1); !errors.Is(err, httpaccess.ErrInvalidMaxSize) {
+		t.Errorf("got error %v, want %v", err, httpaccess.ErrInvalidMaxSize)
+	}
+	if err := c.Set(0.5, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	sampleRate, maxSize := c.Get()
+	if sampleRate != 0.5 || maxSize != 10 {
+		t.Errorf("got sample rate %v and max size %v, want 0.5 and 10", sampleRate, maxSize)
+	}
+}
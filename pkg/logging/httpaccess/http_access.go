@@ -16,13 +16,41 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/tracing"
 )
 
+// Option is a function that applies an option to the access log handler.
+type Option func(*options)
+
+type options struct {
+	bodyCapture *BodyCapture
+}
+
+// WithBodyCapture logs the request and response bodies of the requests
+// sampled by the body capture.
+func WithBodyCapture(c *BodyCapture) Option {
+	return func(o *options) {
+		o.bodyCapture = c
+	}
+}
+
 // NewHTTPAccessLogHandler creates a handler that will log a message after a
 // request has voyagern served.
-func NewHTTPAccessLogHandler(logger logging.Logger, level logrus.Level, tracer *tracing.Tracer, message string) func(h http.Handler) http.Handler {
+func NewHTTPAccessLogHandler(logger logging.Logger, level logrus.Level, tracer *tracing.Tracer, message string, opts ...Option) func(h http.Handler) http.Handler {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			startTime := time.Now()
-			rl := &responseLogger{w, 0, 0, level}
+			rl := &responseLogger{w: w, level: level}
+
+			var requestBody *bodyBuffer
+			if maxSize, ok := o.bodyCapture.sample(); ok {
+				requestBody = newBodyBuffer(maxSize)
+				rl.body = newBodyBuffer(maxSize)
+				if r.Body != nil && r.Body != http.NoBody {
+					r.Body = &captureReader{ReadCloser: r.Body, body: requestBody}
+				}
+			}
 
 			h.ServeHTTP(rl, r)
 
@@ -63,6 +91,16 @@ func NewHTTPAccessLogHandler(logger logging.Logger, level logrus.Level, tracer *
 			if v := r.Header.Get("X-Real-Ip"); v != "" {
 				fields["x-real-ip"] = v
 			}
+			if requestBody != nil {
+				fields["request-body"] = requestBody.String(r.Header.Get("Content-Type"))
+				if requestBody.truncated {
+					fields["request-body-truncated"] = true
+				}
+				fields["response-body"] = rl.body.String(rl.Header().Get("Content-Type"))
+				if rl.body.truncated {
+					fields["response-body-truncated"] = true
+				}
+			}
 
 			logger.WithFields(fields).Log(rl.level, message)
 		})
@@ -88,6 +126,7 @@ type responseLogger struct {
 	status int
 	size   int
 	level  logrus.Level
+	body   *bodyBuffer // captured response body, if sampled
 }
 
 func (l *responseLogger) Header() http.Header {
@@ -115,6 +154,9 @@ func (l *responseLogger) Push(target string, opts *http.PushOptions) error {
 func (l *responseLogger) Write(b []byte) (int, error) {
 	size, err := l.w.Write(b)
 	l.size += size
+	if l.body != nil {
+		_, _ = l.body.Write(b[:size])
+	}
 	return size, err
 }
 
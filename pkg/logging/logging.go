@@ -7,11 +7,28 @@
 package logging
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"io"
+	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
+// SubsystemField is the name of the field with the subsystem name in the log
+// entries of the subsystem loggers.
+const SubsystemField = "subsystem"
+
+// Fields of the log entries of the protocol handlers, so that all entries
+// logged for a peer, or while handling one of its requests, can be found by
+// them.
+const (
+	PeerField    = "overlay"
+	RequestField = "request"
+)
+
 type Logger interface {
 	Tracef(format string, args ...interface{})
 	Trace(args ...interface{})
@@ -27,28 +44,196 @@ type Logger interface {
 	WithFields(fields logrus.Fields) *logrus.Entry
 	WriterLevel(logrus.Level) *io.PipeWriter
 	NewEntry() *logrus.Entry
+	SetLevel(logrus.Level)
+	// Subsystem returns the logger of the named subsystem, which has its
+	// own log level and marks its entries with the subsystem field.
+	Subsystem(name string) Logger
+	// SetSubsystemLevel sets the log level of the named subsystem, which
+	// does not follow the level of the node logger anymore.
+	SetSubsystemLevel(name string, level logrus.Level)
+	// Levels returns the level of the node logger and the levels of all
+	// subsystems.
+	Levels() (level logrus.Level, subsystems map[string]logrus.Level)
+}
+
+// Option is a function that applies an option to the logger.
+type Option func(*logrus.Logger)
+
+// WithJSONFormat formats the log entries as JSON objects, one per line.
+func WithJSONFormat() Option {
+	return func(l *logrus.Logger) {
+		l.Formatter = &logrus.JSONFormatter{}
+	}
 }
 
 type logger struct {
-	*logrus.Logger
-	metrics metrics
+	*logrus.Entry
+	metrics  metrics
+	name     string // subsystem name, empty for the node logger
+	registry *registry
+}
+
+// registry keeps the subsystem loggers which share the output, the format
+// and the hooks of the node logger.
+type registry struct {
+	mu      sync.Mutex
+	root    *logrus.Logger
+	loggers map[string]*logger
+	levels  map[string]logrus.Level // levels set explicitly
 }
 
-func New(w io.Writer, level logrus.Level) Logger {
+func New(w io.Writer, level logrus.Level, opts ...Option) Logger {
 	l := logrus.New()
 	l.SetOutput(w)
 	l.SetLevel(level)
 	l.Formatter = &logrus.TextFormatter{
 		FullTimestamp: true,
 	}
+	for _, o := range opts {
+		o(l)
+	}
 	metrics := newMetrics()
 	l.AddHook(metrics)
 	return &logger{
-		Logger:  l,
+		Entry:   logrus.NewEntry(l),
 		metrics: metrics,
+		registry: &registry{
+			root:    l,
+			loggers: make(map[string]*logger),
+			levels:  make(map[string]logrus.Level),
+		},
 	}
 }
 
 func (l *logger) NewEntry() *logrus.Entry {
-	return logrus.NewEntry(l.Logger)
+	return l.Entry.WithFields(nil)
+}
+
+// SetLevel sets the level of the logger. The node logger level applies to all
+// subsystems without an explicitly set level.
+func (l *logger) SetLevel(level logrus.Level) {
+	if l.name != "" {
+		l.SetSubsystemLevel(l.name, level)
+		return
+	}
+
+	r := l.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.root.SetLevel(level)
+	for name, s := range r.loggers {
+		if _, ok := r.levels[name]; !ok {
+			s.Logger.SetLevel(level)
+		}
+	}
+}
+
+func (l *logger) Subsystem(name string) Logger {
+	r := l.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s, ok := r.loggers[name]; ok {
+		return s
+	}
+
+	sl := logrus.New()
+	sl.SetOutput(r.root.Out)
+	sl.Formatter = r.root.Formatter
+	sl.ReplaceHooks(r.root.Hooks)
+	sl.SetLevel(r.root.GetLevel())
+	if level, ok := r.levels[name]; ok {
+		sl.SetLevel(level)
+	}
+	s := &logger{
+		Entry:    logrus.NewEntry(sl).WithField(SubsystemField, name),
+		metrics:  l.metrics,
+		name:     name,
+		registry: r,
+	}
+	r.loggers[name] = s
+	return s
+}
+
+func (l *logger) SetSubsystemLevel(name string, level logrus.Level) {
+	r := l.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.levels[name] = level
+	if s, ok := r.loggers[name]; ok {
+		s.Logger.SetLevel(level)
+	}
+}
+
+func (l *logger) Levels() (logrus.Level, map[string]logrus.Level) {
+	r := l.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subsystems := make(map[string]logrus.Level, len(r.loggers))
+	for name, s := range r.loggers {
+		subsystems[name] = s.Logger.GetLevel()
+	}
+	for name, level := range r.levels {
+		subsystems[name] = level
+	}
+	return r.root.GetLevel(), subsystems
+}
+
+// PeerEntry returns the log entry of the logger with the overlay address of the
+// peer and the id of the request being handled for it.
+func PeerEntry(l Logger, overlay fmt.Stringer, requestID string) *logrus.Entry {
+	return l.WithFields(logrus.Fields{
+		PeerField:    overlay.String(),
+		RequestField: requestID,
+	})
+}
+
+// NewRequestID returns a random id of a request, which is short as it is only
+// used to tell the requests apart in the logs.
+func NewRequestID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ParseVerbosity returns the log level for the verbosity name or number.
+func ParseVerbosity(verbosity string) (logrus.Level, error) {
+	switch v := strings.ToLower(verbosity); v {
+	case "0", "silent":
+		return 0, nil
+	case "1", "error":
+		return logrus.ErrorLevel, nil
+	case "2", "warn":
+		return logrus.WarnLevel, nil
+	case "3", "info":
+		return logrus.InfoLevel, nil
+	case "4", "debug":
+		return logrus.DebugLevel, nil
+	case "5", "trace":
+		return logrus.TraceLevel, nil
+	default:
+		return 0, fmt.Errorf("unknown verbosity level %q", v)
+	}
+}
+
+// ParseSubsystemLevels parses the subsystem levels in the form of
+// subsystem=verbosity, for example kademlia=debug.
+func ParseSubsystemLevels(values []string) (map[string]logrus.Level, error) {
+	levels := make(map[string]logrus.Level, len(values))
+	for _, v := range values {
+		i := strings.Index(v, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid subsystem log level %q", v)
+		}
+		name := strings.TrimSpace(v[:i])
+		level, err := ParseVerbosity(strings.TrimSpace(v[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("subsystem %s: %w", name, err)
+		}
+		levels[name] = level
+	}
+	return levels, nil
 }
@@ -0,0 +1,126 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+)
+
+func TestSubsystemLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, logrus.InfoLevel)
+
+	kademlia := logger.Subsystem("kademlia")
+	if logger.Subsystem("kademlia") != kademlia {
+		t.Fatal("got a new subsystem logger for the same name")
+	}
+
+	kademlia.Debug("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("got %q, want no output", buf.String())
+	}
+
+	logger.SetSubsystemLevel("kademlia", logrus.DebugLevel)
+	kademlia.Debug("shown")
+	if !strings.Contains(buf.String(), "subsystem=kademlia") || !strings.Contains(buf.String(), "shown") {
+		t.Fatalf("got %q, want the debug entry of the subsystem", buf.String())
+	}
+	buf.Reset()
+
+	// the level of a subsystem is kept when the node level changes
+	logger.SetLevel(logrus.WarnLevel)
+	api := logger.Subsystem("api")
+	api.Info("hidden")
+	kademlia.Debug("shown")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Fatalf("got %q", buf.String())
+	}
+
+	// the level set before the subsystem logger is created applies to it
+	logger.SetSubsystemLevel("pss", logrus.ErrorLevel)
+
+	level, subsystems := logger.Levels()
+	if level != logrus.WarnLevel {
+		t.Errorf("got level %v, want %v", level, logrus.WarnLevel)
+	}
+	want := map[string]logrus.Level{
+		"kademlia": logrus.DebugLevel,
+		"api":      logrus.WarnLevel,
+		"pss":      logrus.ErrorLevel,
+	}
+	if !reflect.DeepEqual(subsystems, want) {
+		t.Errorf("got subsystem levels %v, want %v", subsystems, want)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, logrus.InfoLevel, logging.WithJSONFormat())
+
+	logger.Subsystem("api").WithField("peer", "abcd").Info("test message")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unmarshal %q: %v", buf.String(), err)
+	}
+	for k, v := range map[string]string{
+		"msg":                  "test message",
+		"level":                "info",
+		"peer":                 "abcd",
+		logging.SubsystemField: "api",
+	} {
+		if entry[k] != v {
+			t.Errorf("got %s %v, want %v", k, entry[k], v)
+		}
+	}
+}
+
+func TestPeerEntry(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(&buf, logrus.InfoLevel)
+
+	id := logging.NewRequestID()
+	if len(id) != 8 {
+		t.Fatalf("got request id %q, want 8 characters", id)
+	}
+	if logging.NewRequestID() == id {
+		t.Fatal("got the same request id twice")
+	}
+
+	logging.PeerEntry(logger.Subsystem("pushsync"), infinity.MustParseHexAddress("abcd"), id).Info("test message")
+	for _, want := range []string{"overlay=abcd", "request=" + id, "subsystem=pushsync", "test message"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("got %q, want %q", buf.String(), want)
+		}
+	}
+}
+
+func TestParseSubsystemLevels(t *testing.T) {
+	got, err := logging.ParseSubsystemLevels([]string{"kademlia=debug", " api = 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]logrus.Level{
+		"kademlia": logrus.DebugLevel,
+		"api":      logrus.WarnLevel,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, v := range []string{"kademlia", "=debug", "kademlia=loud"} {
+		if _, err := logging.ParseSubsystemLevels([]string{v}); err == nil {
+			t.Errorf("%q: got no error", v)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package manifest
+
+import (
+	"context"
+	"fmt"
+)
+
+// Difference holds the paths of the entries which differ between two
+// manifests, in the lexical order.
+type Difference struct {
+	// Added are the paths only in the second manifest.
+	Added []string
+	// Removed are the paths only in the first manifest.
+	Removed []string
+	// Changed are the paths in both manifests with a different reference
+	// or metadata.
+	Changed []string
+}
+
+// Diff returns the difference between the manifests a and b, computed by
+// walking the entries of both. All entries of a are held in memory while b is
+// walked, so the memory used grows with the size of a.
+func Diff(ctx context.Context, a, b Interface) (*Difference, error) {
+	var old []pathEntry
+	if err := a.IterateEntries(ctx, "", func(path string, e Entry) error {
+		old = append(old, pathEntry{path: path, entry: e})
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("iterate first manifest: %w", err)
+	}
+
+	d := &Difference{
+		Added:   make([]string, 0),
+		Removed: make([]string, 0),
+		Changed: make([]string, 0),
+	}
+	i := 0
+	if err := b.IterateEntries(ctx, "", func(path string, e Entry) error {
+		for ; i < len(old) && old[i].path < path; i++ {
+			d.Removed = append(d.Removed, old[i].path)
+		}
+		if i < len(old) && old[i].path == path {
+			if !entriesEqual(old[i].entry, e) {
+				d.Changed = append(d.Changed, path)
+			}
+			i++
+			return nil
+		}
+		d.Added = append(d.Added, path)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("iterate second manifest: %w", err)
+	}
+	for ; i < len(old); i++ {
+		d.Removed = append(d.Removed, old[i].path)
+	}
+	return d, nil
+}
+
+// entriesEqual returns true if the entries have the same reference and
+// metadata.
+func entriesEqual(a, b Entry) bool {
+	if !a.Reference().Equal(b.Reference()) {
+		return false
+	}
+	am, bm := a.Metadata(), b.Metadata()
+	if len(am) != len(bm) {
+		return false
+	}
+	for k, v := range am {
+		if w, ok := bm[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
@@ -10,6 +10,7 @@ package manifest
 import (
 	"context"
 	"errors"
+	"sort"
 
 	"github.com/yanhuangpai/voyager/pkg/file"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
@@ -34,6 +35,10 @@ var (
 // the Store function.
 type StoreSizeFunc func(int64) error
 
+// EntryIterFunc is a callback on every entry visited by the IterateEntries
+// function. Iteration stops if it returns an error.
+type EntryIterFunc func(path string, entry Entry) error
+
 // Interface for operations with manifest.
 type Interface interface {
 	// Type returns manifest implementation type information
@@ -51,6 +56,9 @@ type Interface interface {
 	// IterateAddresses is used to iterate over chunks addresses for
 	// the manifest.
 	IterateAddresses(context.Context, infinity.AddressIterFunc) error
+	// IterateEntries is used to iterate over the entries with paths that
+	// start with the specified prefix, in the lexical order of paths.
+	IterateEntries(context.Context, string, EntryIterFunc) error
 }
 
 // Entry represents a single manifest entry.
@@ -121,3 +129,21 @@ func (e *manifestEntry) Reference() infinity.Address {
 func (e *manifestEntry) Metadata() map[string]string {
 	return e.metadata
 }
+
+type pathEntry struct {
+	path  string
+	entry Entry
+}
+
+// iterateSorted calls fn for the entries sorted by their paths.
+func iterateSorted(entries []pathEntry, fn EntryIterFunc) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].path < entries[j].path
+	})
+	for _, e := range entries {
+		if err := fn(e.path, e.entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@
 package manifest
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -18,12 +19,21 @@ const (
 	// ManifestMantarayContentType represents content type used for noting that
 	// specific file should be processed as mantaray manifest.
 	ManifestMantarayContentType = "application/ifi-manifest-mantaray+octet-stream"
+
+	// mantarayFlushThreshold is the number of added entries after which the
+	// trie is saved in order to release the memory of the stored nodes. It is
+	// used only with the file.DeferredLoadSaver.
+	mantarayFlushThreshold = 1000
 )
 
 type mantarayManifest struct {
 	trie *mantaray.Node
 
 	ls file.LoadSaver
+
+	added      int     // number of entries added since the last trie save
+	savedSizes []int64 // sizes of nodes saved before Store
+	rootSaved  bool    // last element of savedSizes belongs to the unflushed root
 }
 
 // NewMantarayManifest creates a new mantaray-based manifest.
@@ -62,7 +72,50 @@ func (m *mantarayManifest) Add(ctx context.Context, path string, entry Entry) er
 	p := []byte(path)
 	e := entry.Reference().Bytes()
 
-	return m.trie.Add(ctx, p, e, entry.Metadata(), m.ls)
+	if err := m.trie.Add(ctx, p, e, entry.Metadata(), m.ls); err != nil {
+		return err
+	}
+
+	dls, ok := m.ls.(file.DeferredLoadSaver)
+	if !ok {
+		return nil
+	}
+
+	m.added++
+	if m.added < mantarayFlushThreshold {
+		return nil
+	}
+
+	// save the trie so that the stored nodes are released from memory, the
+	// root is kept unflushed until Store is called
+	m.discardRoot(dls)
+	ls := &mantarayLoadSaver{
+		ls: m.ls,
+		storeSizeFn: []StoreSizeFunc{func(size int64) error {
+			m.savedSizes = append(m.savedSizes, size)
+			m.rootSaved = true
+			return nil
+		}},
+	}
+	if err := m.trie.Save(ctx, ls); err != nil {
+		return fmt.Errorf("manifest save error: %w", err)
+	}
+	m.added = 0
+
+	return nil
+}
+
+// discardRoot drops the unflushed root of the previous trie save if the trie
+// has been changed since.
+func (m *mantarayManifest) discardRoot(dls file.DeferredLoadSaver) {
+	if m.trie.Reference() != nil {
+		return
+	}
+	dls.Discard()
+	if m.rootSaved {
+		m.savedSizes = m.savedSizes[:len(m.savedSizes)-1]
+		m.rootSaved = false
+	}
 }
 
 func (m *mantarayManifest) Remove(ctx context.Context, path string) error {
@@ -107,6 +160,22 @@ func (m *mantarayManifest) HasPrefix(ctx context.Context, prefix string) (bool,
 }
 
 func (m *mantarayManifest) Store(ctx context.Context, storeSizeFn ...StoreSizeFunc) (infinity.Address, error) {
+	dls, deferred := m.ls.(file.DeferredLoadSaver)
+	if deferred {
+		m.discardRoot(dls)
+
+		// report the sizes of the nodes that were saved while adding entries
+		for _, size := range m.savedSizes {
+			for i := range storeSizeFn {
+				if err := storeSizeFn[i](size); err != nil {
+					return infinity.ZeroAddress, fmt.Errorf("manifest store size func: %w", err)
+				}
+			}
+		}
+		m.savedSizes = nil
+		m.rootSaved = false
+	}
+
 	var ls mantaray.LoadSaver
 	if len(storeSizeFn) > 0 {
 		ls = &mantarayLoadSaver{
@@ -122,6 +191,13 @@ func (m *mantarayManifest) Store(ctx context.Context, storeSizeFn ...StoreSizeFu
 		return infinity.ZeroAddress, fmt.Errorf("manifest save error: %w", err)
 	}
 
+	if deferred {
+		if err := dls.Flush(ctx); err != nil {
+			return infinity.ZeroAddress, fmt.Errorf("manifest flush error: %w", err)
+		}
+		m.added = 0
+	}
+
 	address := infinity.NewAddress(m.trie.Reference())
 
 	return address, nil
@@ -169,6 +245,40 @@ func (m *mantarayManifest) IterateAddresses(ctx context.Context, fn infinity.Add
 	return nil
 }
 
+func (m *mantarayManifest) IterateEntries(ctx context.Context, prefix string, fn EntryIterFunc) error {
+	p := []byte(prefix)
+
+	var entries []pathEntry
+	walker := func(path []byte, node *mantaray.Node, err error) error {
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if node == nil || !node.IsValueType() || !bytes.HasPrefix(path, p) {
+			return nil
+		}
+
+		entries = append(entries, pathEntry{
+			path:  string(path),
+			entry: NewEntry(infinity.NewAddress(node.Entry()), node.Metadata()),
+		})
+		return nil
+	}
+
+	err := m.trie.WalkNode(ctx, []byte{}, m.ls, walker)
+	if err != nil {
+		return fmt.Errorf("manifest iterate entries: %w", err)
+	}
+
+	return iterateSorted(entries, fn)
+}
+
 type mantarayLoadSaver struct {
 	ls          file.LoadSaver
 	storeSizeFn []StoreSizeFunc
@@ -8,6 +8,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/ethersphere/manifest/simple"
 	"github.com/yanhuangpai/voyager/pkg/file"
@@ -144,6 +145,37 @@ func (m *simpleManifest) IterateAddresses(ctx context.Context, fn infinity.Addre
 	return nil
 }
 
+func (m *simpleManifest) IterateEntries(_ context.Context, prefix string, fn EntryIterFunc) error {
+	var entries []pathEntry
+	walker := func(path string, entry simple.Entry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !strings.HasPrefix(path, prefix) {
+			return nil
+		}
+
+		ref, err := infinity.ParseHexAddress(entry.Reference())
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, pathEntry{
+			path:  path,
+			entry: NewEntry(ref, entry.Metadata()),
+		})
+		return nil
+	}
+
+	err := m.manifest.WalkEntry("", walker)
+	if err != nil {
+		return fmt.Errorf("manifest iterate entries: %w", err)
+	}
+
+	return iterateSorted(entries, fn)
+}
+
 func (m *simpleManifest) load(ctx context.Context, reference infinity.Address) error {
 	buf, err := m.ls.Load(ctx, reference.Bytes())
 	if err != nil {
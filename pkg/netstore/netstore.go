@@ -28,6 +28,12 @@ type store struct {
 	recoveryCallback recovery.Callback // this is the callback to be executed when a chunk fails to be retrieved
 }
 
+// coldRestorer is implemented by the local stores which restore the chunks
+// offloaded to the cold tier.
+type coldRestorer interface {
+	RestoreCold(ctx context.Context, addr infinity.Address) (infinity.Chunk, error)
+}
+
 var (
 	ErrRecoveryAttempt = errors.New("failed to retrieve chunk, recovery initiated")
 )
@@ -38,11 +44,24 @@ func New(s storage.Storer, rcb recovery.Callback, r retrieval.Interface, logger
 }
 
 // Get retrieves a given chunk address.
-// It will request a chunk from the network whenever it cannot be found locally.
+// It will request a chunk from the network whenever it cannot be found locally,
+// unless it is restored from the cold tier of the local store.
+// The retrieval retries with the next closest peers, and the recovery is
+// initiated only after all of its attempts failed.
 func (s *store) Get(ctx context.Context, mode storage.ModeGet, addr infinity.Address) (ch infinity.Chunk, err error) {
 	ch, err = s.Storer.Get(ctx, mode, addr)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
+			if cr, ok := s.Storer.(coldRestorer); ok {
+				ch, err = cr.RestoreCold(ctx, addr)
+				if err == nil {
+					return ch, nil
+				}
+				if !errors.Is(err, storage.ErrNotFound) {
+					s.logger.Debugf("netstore: restore cold chunk %s: %v", addr, err)
+				}
+			}
+
 			// request from network
 			ch, err = s.retrieval.RetrieveChunk(ctx, addr)
 			if err != nil {
@@ -122,7 +122,8 @@ func InitCPUAwardService(
 }
 
 // InitChequebookService will initialize the chequebook service with the given
-// chequebook factory and chain backend.
+// chequebook factory and chain backend. The issued cheques are kept within the
+// limits.
 func InitChequebookService(
 	ctx context.Context,
 	logger logging.Logger,
@@ -134,6 +135,7 @@ func InitChequebookService(
 	transactionService transaction.Service,
 	chequebookFactory chequebook.Factory,
 	initialDeposit string,
+	limits chequebook.Limits,
 ) (chequebook.Service, error) {
 	chequeSigner := chequebook.NewChequeSigner(signer, chainID)
 
@@ -154,6 +156,7 @@ func InitChequebookService(
 		overlayEthAddress,
 		chequeSigner,
 		chequebook.NewSimpleSwapBindings,
+		limits,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("chequebook init: %w", err)
@@ -162,6 +165,59 @@ func InitChequebookService(
 	return chequebookService, nil
 }
 
+// LoadChequebookService returns the chequebook service of the chequebook
+// deployed with the deploy command, or chequebook.ErrNoChequebook if there is
+// none. The issued cheques are kept within the limits.
+func LoadChequebookService(
+	ctx context.Context,
+	stateStore storage.StateStorer,
+	signer crypto.Signer,
+	chainID int64,
+	backend *ethclient.Client,
+	overlayEthAddress common.Address,
+	transactionService transaction.Service,
+	chequebookFactory chequebook.Factory,
+	limits chequebook.Limits,
+) (chequebook.Service, error) {
+	chequebookService, err := chequebook.Load(
+		ctx,
+		chequebookFactory,
+		stateStore,
+		transactionService,
+		backend,
+		overlayEthAddress,
+		chequebook.NewChequeSigner(signer, chainID),
+		chequebook.NewSimpleSwapBindings,
+		limits,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("chequebook load: %w", err)
+	}
+	return chequebookService, nil
+}
+
+// ParseChequebookLimits parses the limits of the issued cheques given in token
+// base units, an empty limit is not enforced.
+func ParseChequebookLimits(maxCheque, maxDailyIssuance string) (limits chequebook.Limits, err error) {
+	parse := func(s string) (*big.Int, error) {
+		if s == "" {
+			return nil, nil
+		}
+		v, ok := new(big.Int).SetString(s, 10)
+		if !ok || v.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid amount %q", s)
+		}
+		return v, nil
+	}
+	if limits.MaxCheque, err = parse(maxCheque); err != nil {
+		return limits, fmt.Errorf("max cheque: %w", err)
+	}
+	if limits.MaxDailyIssuance, err = parse(maxDailyIssuance); err != nil {
+		return limits, fmt.Errorf("max daily issuance: %w", err)
+	}
+	return limits, nil
+}
+
 func initChequeStoreCashout(
 	stateStore storage.StateStorer,
 	swapBackend transaction.Backend,
@@ -197,11 +253,19 @@ func InitSwap(
 	stateStore storage.StateStorer,
 	networkID uint64,
 	overlayEthAddress common.Address,
+	overlayNonce []byte,
 	chequebookService chequebook.Service,
 	chequeStore chequebook.ChequeStore,
 	cashoutService chequebook.CashoutService,
 ) (*swap.Service, error) {
-	swapProtocol := swapprotocol.New(p2ps, logger, overlayEthAddress)
+	// the chequebook is announced to the peers only if there is one
+	var chequebookAddress common.Address
+	if chequebookService != nil {
+		chequebookAddress = chequebookService.Address()
+	}
+
+	swapProtocol := swapprotocol.New(p2ps, logger, overlayEthAddress, chequebookAddress)
+	swapProtocol.SetOverlayNonce(overlayNonce)
 	swapAddressBook := swap.NewAddressbook(stateStore)
 
 	swapService := swap.New(
@@ -0,0 +1,83 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/localstore"
+	"github.com/yanhuangpai/voyager/pkg/resolver/multiresolver"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+const healthCheckKey = "health-check"
+
+var errResolverNotConnected = errors.New("not connected")
+
+// registerHealthChecks adds the checks of the services the node depends on
+// to the verbose health endpoint of the debug api.
+func registerHealthChecks(debugAPIService *debugapi.Service, swapBackend *ethclient.Client, multiResolver *multiresolver.MultiResolver, stateStore storage.StateStorer, storer *localstore.DB, localstorePath string, op Options) {
+	if swapBackend != nil {
+		debugAPIService.AddHealthCheck("chain", func(ctx context.Context) error {
+			_, err := swapBackend.BlockNumber(ctx)
+			return err
+		})
+	}
+
+	for _, c := range op.ResolverConnectionCfgs {
+		c := c
+		debugAPIService.AddHealthCheck(fmt.Sprintf("resolver %s", c.Endpoint), func(context.Context) error {
+			if !multiResolver.IsConnected(c.TLD, c.Endpoint) {
+				return errResolverNotConnected
+			}
+			return nil
+		})
+	}
+
+	debugAPIService.AddHealthCheck("statestore", func(context.Context) error {
+		if err := stateStore.Put(healthCheckKey, true); err != nil {
+			return err
+		}
+		return stateStore.Delete(healthCheckKey)
+	})
+
+	// the localstore is ready once its indexes are warmed up after the start
+	debugAPIService.AddHealthCheck("localstore warm-up", func(context.Context) error {
+		return storer.ReadyCheck()
+	})
+
+	// the localstore is kept in memory without the data directory
+	if localstorePath != "" {
+		debugAPIService.AddHealthCheck("localstore", func(context.Context) error {
+			return checkDirWritable(localstorePath)
+		})
+	}
+}
+
+// checkDirWritable writes and syncs a temporary file in the directory to
+// check that the disk accepts writes.
+func checkDirWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, "."+healthCheckKey+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write([]byte{0}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
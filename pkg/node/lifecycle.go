@@ -0,0 +1,109 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/logging"
+)
+
+// shutdownReportInterval is the interval of logging the component which
+// blocks the shutdown.
+var shutdownReportInterval = 5 * time.Second
+
+// component is a part of the node stopped on shutdown.
+type component struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// lifecycle stops the components of the node in the order they are added,
+// which has to follow their dependencies, so that no component is stopped
+// while the components still running use it.
+type lifecycle struct {
+	logger     logging.Logger
+	components []component
+}
+
+func newLifecycle(logger logging.Logger) *lifecycle {
+	return &lifecycle{logger: logger}
+}
+
+// add adds the component with the stop function.
+func (l *lifecycle) add(name string, stop func(ctx context.Context) error) {
+	l.components = append(l.components, component{name: name, stop: stop})
+}
+
+// addCloser adds the component stopped by closing it. A nil closer is not
+// added.
+func (l *lifecycle) addCloser(name string, c io.Closer) {
+	if c == nil {
+		return
+	}
+	l.add(name, func(context.Context) error {
+		return c.Close()
+	})
+}
+
+// addFunc adds the component stopped by the function. A nil function is not
+// added.
+func (l *lifecycle) addFunc(name string, f func()) {
+	if f == nil {
+		return
+	}
+	l.add(name, func(context.Context) error {
+		f()
+		return nil
+	})
+}
+
+// shutdown stops the components one by one and reports the errors of all of
+// them. The components are waited for even after the context is done, as
+// the components depending on them can not be stopped safely before, but the
+// component blocking the shutdown is logged.
+func (l *lifecycle) shutdown(ctx context.Context) error {
+	errs := new(multiError)
+	for _, c := range l.components {
+		if err := l.stop(ctx, c); err != nil {
+			errs.add(fmt.Errorf("%s: %w", c.name, err))
+		}
+	}
+
+	if errs.hasErrors() {
+		return errs
+	}
+	return nil
+}
+
+func (l *lifecycle) stop(ctx context.Context, c component) error {
+	start := time.Now()
+	l.logger.Debugf("shutdown: stopping %s", c.name)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.stop(ctx)
+	}()
+
+	ticker := time.NewTicker(shutdownReportInterval)
+	defer ticker.Stop()
+
+	ctxDone := ctx.Done()
+	for {
+		select {
+		case err := <-done:
+			l.logger.Debugf("shutdown: %s stopped in %s", c.name, time.Since(start))
+			return err
+		case <-ticker.C:
+			l.logger.Infof("shutdown: waiting for %s to stop", c.name)
+		case <-ctxDone:
+			ctxDone = nil
+			l.logger.Errorf("shutdown: %s blocks shutdown", c.name)
+		}
+	}
+}
@@ -10,6 +10,7 @@ package node
 import (
 	"context"
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -26,15 +27,22 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/accounting"
 	"github.com/yanhuangpai/voyager/pkg/addressbook"
 	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/apikey"
+	"github.com/yanhuangpai/voyager/pkg/coldstore"
 	"github.com/yanhuangpai/voyager/pkg/cpc"
 	"github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/events"
+	"github.com/yanhuangpai/voyager/pkg/features"
 	"github.com/yanhuangpai/voyager/pkg/feeds/factory"
 	"github.com/yanhuangpai/voyager/pkg/hive"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/kademlia"
+	"github.com/yanhuangpai/voyager/pkg/keystore"
+	"github.com/yanhuangpai/voyager/pkg/latency"
 	"github.com/yanhuangpai/voyager/pkg/localstore"
 	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/logging/httpaccess"
 	"github.com/yanhuangpai/voyager/pkg/metrics"
 	"github.com/yanhuangpai/voyager/pkg/netstore"
 	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p"
@@ -56,35 +64,54 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/settlement/swap/transaction"
 	"github.com/yanhuangpai/voyager/pkg/storage"
 	"github.com/yanhuangpai/voyager/pkg/tags"
+	"github.com/yanhuangpai/voyager/pkg/telemetry"
 	"github.com/yanhuangpai/voyager/pkg/tracing"
 	"github.com/yanhuangpai/voyager/pkg/traversal"
-	"golang.org/x/sync/errgroup"
 )
 
+// peerRemovedEventsBuffer is the number of the buffered peer removed events
+// of the accounting, the events above it are dropped.
+const peerRemovedEventsBuffer = 256
+
 type Voyager struct {
-	p2pService            io.Closer
-	p2pCancel             context.CancelFunc
-	apiCloser             io.Closer
-	apiServer             *http.Server
-	debugAPIServer        *http.Server
-	resolverCloser        io.Closer
-	errorLogWriter        *io.PipeWriter
-	tracerCloser          io.Closer
-	tagsCloser            io.Closer
-	stateStoreCloser      io.Closer
-	localstoreCloser      io.Closer
-	topologyCloser        io.Closer
-	pusherCloser          io.Closer
-	pullerCloser          io.Closer
-	pullSyncCloser        io.Closer
-	pssCloser             io.Closer
-	ethClientCloser       func()
-	recoveryHandleCleanup func()
+	p2pService              io.Closer
+	p2pCancel               context.CancelFunc
+	apiService              api.Service
+	apiServer               *http.Server
+	debugAPIServer          *http.Server
+	resolverCloser          io.Closer
+	errorLogWriter          *io.PipeWriter
+	tracerCloser            io.Closer
+	tagsCloser              io.Closer
+	stateStoreCloser        io.Closer
+	apiKeysCloser           io.Closer
+	localstoreCloser        io.Closer
+	topologyCloser          io.Closer
+	pusherCloser            io.Closer
+	pullerCloser            io.Closer
+	pullSyncCloser          io.Closer
+	pssCloser               io.Closer
+	telemetryCloser         io.Closer
+	latencyCloser           io.Closer
+	addressbookCloser       io.Closer
+	accountingEventsCleanup func()
+	ethClientCloser         func()
+	recoveryHandleCleanup   func()
+	recoveryResponseCleanup func()
+	drainPeriod             time.Duration
+	logger                  logging.Logger
+	reloader                *reloader
+	reloadConfig            func() (RuntimeOptions, error)
+	eventBus                *events.Bus
 }
 
 type Options struct {
 	DataDir                   string
 	DBCapacity                uint64
+	DBPinnedCapacity          uint64
+	DBUploadCapacity          uint64
+	PullSyncWithinDepth       bool
+	PullSyncCapacity          uint64
 	DBOpenFilesLimit          uint64
 	DBWriteBufferSize         uint64
 	DBBlockCacheCapacity      uint64
@@ -114,14 +141,64 @@ type Options struct {
 	SwapFactoryAddress        string
 	SwapInitialDeposit        string
 	SwapEnable                bool
+	SwapSettlementAlertLimit  int
+	SwapSettlementAlertWindow time.Duration
+	SwapIssueCheques          bool
+	SwapMaxCheque             string
+	SwapMaxDailyIssuance      string
 	Password                  string
 	ClefSignerEnable          bool
 	ClefSignerEndpoint        string
 	ClefSignerEthereumAddress string
 	NetworkID                 uint64
+	NetworkName               string
+	OverlayNonce              []byte
 	LogicalCores              int
 	MHZ                       float64
 	TotalFree                 uint64
+	P2PPeerRateLimit          int64
+	P2PUserAgent              string
+	P2PDialPreference         string
+	P2PAdvertisePolicy        string
+	P2PConnectionEvents       int
+	P2PStreamPriorityMaxWait  time.Duration
+	P2PStreamPriorityWeight   int
+	TelemetryEnabled          bool
+	TelemetryEndpoint         string
+	APICompression            bool
+	APIMaxUploadSize          int64
+	APIMaxImportSize          int64
+	APIUploadReadTimeout      time.Duration
+	APIWriteTimeout           time.Duration
+	APIBodySampleRate         float64
+	APIBodyMaxSize            int
+	APIPrefetchChunks         int
+	RecoveryResponderEnabled  bool
+	DBVerify                  bool
+	ShutdownDrainPeriod       time.Duration
+	ReloadConfig              func() (RuntimeOptions, error)
+	RetrievalMaxAttempts      int
+	RetrievalRetryBackoff     time.Duration
+	RetrievalCacheProbability float64
+	RetrievalCacheProximity   uint8
+	LatencyProbeInterval      time.Duration
+	AddressbookExpiry         time.Duration
+	Features                  []string
+	GatewayRateLimit          float64
+	GatewayRateBurst          int
+	GatewayMaxUploadSize      int64
+	GatewayAllowedEndpoints   []string
+	BootnodeResolveInterval   time.Duration
+	GatewayAPIKeys            bool
+	ColdStoreEndpoint         string
+	ColdStoreRegion           string
+	ColdStoreBucket           string
+	ColdStorePrefix           string
+	ColdStoreAccessKey        string
+	ColdStoreSecretKey        string
+	ColdStoreMinProximity     uint8
+	ColdStoreMaxIdle          time.Duration
+	ColdStoreMaxAge           time.Duration
 }
 
 type Chequebook struct {
@@ -143,6 +220,14 @@ type Services struct {
 	tagService        *tags.Tags
 	pullSync          *pullsync.Syncer
 	puller            *puller.Puller
+	telemetry         *telemetry.Service
+	latency           *latency.Service
+	addressBook       addressbook.Interface
+	features          *features.Service
+	apiKeys           *apikey.Service
+	janitor           *addressbook.Janitor
+	recoveryResponder *recovery.Responder
+	eventBus          *events.Bus
 }
 
 func NewVoyager(
@@ -152,8 +237,7 @@ func NewVoyager(
 	signer crypto.Signer,
 	networkID uint64,
 	logger logging.Logger,
-	libp2pPrivateKey,
-	pssPrivateKey *ecdsa.PrivateKey,
+	keys keystore.Keys,
 	op Options, flg *cpc.InterruptFlag) (voyager *Voyager, cpuawardService cpc.Service, ownerAddress *common.Address, err error) {
 	var (
 		services          Services
@@ -188,15 +272,35 @@ func NewVoyager(
 			p2pCancel()
 		}
 	}()
+	eventBus := events.New()
+	services.eventBus = eventBus
 	voyager = &Voyager{
 		p2pCancel:      p2pCancel,
 		errorLogWriter: logger.WriterLevel(logrus.ErrorLevel),
 		tracerCloser:   tracerCloser,
+		drainPeriod:    op.ShutdownDrainPeriod,
+		logger:         logger,
+		reloadConfig:   op.ReloadConfig,
+		eventBus:       eventBus,
 	}
 	overlayEthAddress, err = signer.EthereumAddress()
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("eth address: %w", err)
 	}
+
+	// the private keys are decrypted from the unlocked keystore only here and
+	// held by the services which use them
+	pssPrivateKey, created, err := keys.Key("pss")
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("pss key: %w", err)
+	}
+	if created {
+		logger.Debugf("new pss key created")
+	} else {
+		logger.Debugf("using existing pss key")
+	}
+	logger.Infof("pss public key %x", crypto.EncodeSecp256k1PublicKey(&pssPrivateKey.PublicKey))
+
 	if token != "" {
 		err = cpc.RegisterNode(testnetAddr, token, mac, owner_Address, infinityAddress, publicKey)
 		if err != nil {
@@ -209,7 +313,7 @@ func NewVoyager(
 	if op.DebugAPIAddr != "" {
 
 		// set up basic debug api endpoints for debugging and /health endpoint
-		debugAPIService = debugapi.New(infinityAddress, *publicKey, pssPrivateKey.PublicKey, overlayEthAddress, logger, tracer, op.CORSAllowedOrigins)
+		debugAPIService = debugapi.New(infinityAddress, *publicKey, pssPrivateKey.PublicKey, overlayEthAddress, logger.Subsystem("debugapi"), tracer, op.CORSAllowedOrigins)
 		services.debugAPIService = debugAPIService
 		debugAPIListener, err := net.Listen("tcp", op.DebugAPIAddr)
 		if err != nil {
@@ -243,15 +347,59 @@ func NewVoyager(
 		fmt.Println(err)
 		return nil, nil, nil, err
 	}
-	addressbook := addressbook.New(stateStore)
+	addressBook := addressbook.New(stateStore)
+	services.addressBook = addressBook
+
+	featureFlags, err := features.New(stateStore, op.Features, logger.Subsystem("features"))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("features: %w", err)
+	}
+	services.features = featureFlags
+
+	apiKeys, err := apikey.New(stateStore, logger.Subsystem("apikeys"))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("api keys: %w", err)
+	}
+	services.apiKeys = apiKeys
+	voyager.apiKeysCloser = apiKeys
+
+	dialPreference, err := libp2p.ParseDialPreference(op.P2PDialPreference)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("p2p dial preference: %w", err)
+	}
+	advertisePolicy, err := libp2p.ParseAdvertisePolicy(op.P2PAdvertisePolicy)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("p2p advertise policy: %w", err)
+	}
 
-	p2ps, err := libp2p.New(p2pCtx, signer, networkID, infinityAddress, addr, addressbook, stateStore, logger, tracer, libp2p.Options{
+	// the pss messages are sent and received through the api, which does
+	// not serve pss in the gateway mode
+	pssEnabled := op.APIAddr != "" && !op.GatewayMode
+
+	libp2pPrivateKey, _, err := keys.Key("libp2p")
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("libp2p key: %w", err)
+	}
+	p2ps, err := libp2p.New(p2pCtx, signer, networkID, infinityAddress, addr, addressBook, stateStore, logger.Subsystem("p2p"), tracer, libp2p.Options{
 		PrivateKey:     libp2pPrivateKey,
 		NATAddr:        op.NATAddr,
 		EnableWS:       op.EnableWS,
 		EnableQUIC:     op.EnableQUIC,
 		Standalone:     op.Standalone,
+		BootnodeMode:   op.BootnodeMode,
+		PssEnabled:     pssEnabled,
+		ChainEnabled:   op.SwapEnable,
 		WelcomeMessage: op.WelcomeMessage,
+		PeerRateLimit:  op.P2PPeerRateLimit,
+		DialPreference: dialPreference,
+
+		AdvertisePolicy:       advertisePolicy,
+		ConnectionEvents:      op.P2PConnectionEvents,
+		StreamPriorityMaxWait: op.P2PStreamPriorityMaxWait,
+		StreamPriorityWeight:  op.P2PStreamPriorityWeight,
+		OverlayNonce:          op.OverlayNonce,
+		UserAgent:             op.P2PUserAgent,
+		NetworkName:           op.NetworkName,
 	})
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("p2p service: %w", err)
@@ -271,6 +419,7 @@ func NewVoyager(
 			stateStore,
 			networkID,
 			overlayEthAddress,
+			op.OverlayNonce,
 			chequebookService,
 			chequeStore,
 			cashoutService,
@@ -278,6 +427,8 @@ func NewVoyager(
 		if err != nil {
 			return nil, nil, nil, err
 		}
+		swapService.SetEventBus(eventBus)
+		swapService.SetSettlementAlert(op.SwapSettlementAlertLimit, op.SwapSettlementAlertWindow)
 		settlement = swapService
 	} else {
 		pseudosettleService := pseudosettle.New(p2ps, logger, stateStore)
@@ -302,7 +453,7 @@ func NewVoyager(
 		}
 	}
 	// Construct protocols.
-	pingPong, hive, paymentThreshold, pricing, err := buildProtocols(p2ps, logger, tracer, addressbook, networkID, op)
+	pingPong, hive, paymentThreshold, pricing, err := buildProtocols(p2ps, logger, tracer, addressBook, networkID, op)
 	services.pingPong = pingPong
 	if op.Standalone {
 		logger.Info("Starting node in standalone mode, no p2p connections will be made or accepted")
@@ -331,7 +482,7 @@ func NewVoyager(
 		paymentThreshold,
 		paymentTolerance,
 		paymentEarly,
-		logger,
+		logger.Subsystem("accounting"),
 		stateStore,
 		settlement,
 		pricing,
@@ -341,8 +492,26 @@ func NewVoyager(
 	}
 	settlement.SetNotifyPaymentFunc(acc.AsyncNotifyPayment)
 	pricing.SetPaymentThresholdObserver(acc)
-	kad := kademlia.New(infinityAddress, addressbook, hive, p2ps, logger, kademlia.Options{Bootnodes: bootnodes, StandaloneMode: op.Standalone, BootnodeMode: op.BootnodeMode})
+	if swapService != nil {
+		swapService.SetSwapDisabledFunc(acc.SwapDisabled)
+	}
+
+	// the traffic statistics of the disconnected peers are dropped
+	peerRemovedEvents, unsubscribePeerRemoved := eventBus.Subscribe(peerRemovedEventsBuffer, events.TopicPeerRemoved)
+	go func() {
+		for e := range peerRemovedEvents {
+			if d, ok := e.Data.(events.PeerData); ok {
+				acc.Disconnected(d.Peer)
+			}
+		}
+	}()
+	voyager.accountingEventsCleanup = unsubscribePeerRemoved
+
+	kad := kademlia.New(infinityAddress, addressBook, hive, p2ps, logger.Subsystem("kademlia"), kademlia.Options{Bootnodes: bootnodes, StandaloneMode: op.Standalone, BootnodeMode: op.BootnodeMode, Streamer: p2ps, EventBus: eventBus, BootnodeResolveInterval: op.BootnodeResolveInterval})
 	voyager.topologyCloser = kad
+	if err = p2ps.AddProtocol(kad.Protocol()); err != nil {
+		return nil, nil, nil, fmt.Errorf("kademlia service: %w", err)
+	}
 	hive.SetAddPeersHandler(kad.AddPeers)
 	p2ps.SetPickyNotifier(kad)
 	addrs, err := p2ps.Addresses()
@@ -358,17 +527,84 @@ func NewVoyager(
 	}
 	lo := &localstore.Options{
 		Capacity:               op.DBCapacity,
+		PinnedCapacity:         op.DBPinnedCapacity,
+		UploadCapacity:         op.DBUploadCapacity,
 		OpenFilesLimit:         op.DBOpenFilesLimit,
 		BlockCacheCapacity:     op.DBBlockCacheCapacity,
 		WriteBufferSize:        op.DBWriteBufferSize,
 		DisableSeeksCompaction: op.DBDisableSeeksCompaction,
 	}
-	storer, err := localstore.New(path, infinityAddress.Bytes(), lo, logger)
+	if op.ColdStoreEndpoint != "" {
+		cold, err := coldstore.NewS3(coldstore.S3Options{
+			Endpoint:  op.ColdStoreEndpoint,
+			Region:    op.ColdStoreRegion,
+			Bucket:    op.ColdStoreBucket,
+			Prefix:    op.ColdStorePrefix,
+			AccessKey: op.ColdStoreAccessKey,
+			SecretKey: op.ColdStoreSecretKey,
+		})
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("cold store: %w", err)
+		}
+		lo.ColdStore = cold
+		lo.ColdPolicy = coldstore.Policy{
+			MinProximity: op.ColdStoreMinProximity,
+			MaxIdle:      op.ColdStoreMaxIdle,
+			MaxAge:       op.ColdStoreMaxAge,
+		}
+		logger.Infof("offloading the collected chunks to the cold store %s", op.ColdStoreEndpoint)
+	}
+	storer, err := localstore.New(path, infinityAddress.Bytes(), lo, logger.Subsystem("localstore"))
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("localstore: %w", err)
 	}
 	voyager.localstoreCloser = storer
-	retrieve := retrieval.New(infinityAddress, storer, p2ps, kad, logger, acc, accounting.NewFixedPricer(infinityAddress, 1000000000), tracer)
+
+	storageSize := func() (int64, error) {
+		if path == "" {
+			// in memory storage
+			return 0, nil
+		}
+		return telemetry.DirSize(path)
+	}
+	telemetryService := telemetry.New(p2ps, storageSize, logger, telemetry.Options{
+		Enabled:  op.TelemetryEnabled,
+		Endpoint: op.TelemetryEndpoint,
+	})
+	services.telemetry = telemetryService
+	voyager.telemetryCloser = telemetryService
+	if telemetryService.Enabled() {
+		logger.Infof("sending anonymous usage reports to %s", op.TelemetryEndpoint)
+	}
+	telemetryService.Start()
+	latencyService := latency.New(services.pingPong, p2ps, logger.Subsystem("latency"), latency.Options{
+		Interval: op.LatencyProbeInterval,
+	})
+	latencyService.SetEventBus(eventBus)
+	kad.SetLatencyEstimator(latencyService)
+	services.latency = latencyService
+	voyager.latencyCloser = latencyService
+	latencyService.Start()
+	if op.AddressbookExpiry > 0 {
+		janitor := addressbook.NewJanitor(addressBook, p2ps, logger.Subsystem("addressbook"), addressbook.JanitorOptions{
+			Expiry: op.AddressbookExpiry,
+		})
+		services.janitor = janitor
+		voyager.addressbookCloser = janitor
+		janitor.Start()
+	}
+	pricer := accounting.NewFixedPricer(infinityAddress, 1000000000)
+	retrieve := retrieval.New(infinityAddress, storer, p2ps, kad, logger.Subsystem("retrieval"), acc, pricer, tracer, retrieval.Options{
+		MaxAttempts:  op.RetrievalMaxAttempts,
+		RetryBackoff: op.RetrievalRetryBackoff,
+		Latency:      latencyService,
+		Features:     featureFlags,
+		Cache: retrieval.CachePolicy{
+			Probability:     op.RetrievalCacheProbability,
+			ProximityCutoff: op.RetrievalCacheProximity,
+		},
+		StateStore: stateStore,
+	})
 	services.retrieve = retrieve
 	tagService := tags.NewTags(stateStore, logger)
 	services.tagService = tagService
@@ -377,21 +613,27 @@ func NewVoyager(
 	if err = p2ps.AddProtocol(retrieve.Protocol()); err != nil {
 		return nil, nil, nil, fmt.Errorf("retrieval service: %w", err)
 	}
-	pssService := pss.New(pssPrivateKey, logger)
+	if err = p2ps.AddProtocol(retrieve.DeprecatedProtocol()); err != nil {
+		return nil, nil, nil, fmt.Errorf("retrieval service: %w", err)
+	}
+	pssService := pss.New(pssPrivateKey, logger.Subsystem("pss"))
 	services.pssService = pssService
 	voyager.pssCloser = pssService
 
 	if op.GlobalPinningEnabled {
 		// create recovery callback for content repair
-		recoverFunc := recovery.NewCallback(pssService)
-		ns = netstore.New(storer, recoverFunc, retrieve, logger)
+		recoveryRequester := recovery.NewRequester(pssService, signer, infinityAddress, op.OverlayNonce, logger)
+		voyager.recoveryResponseCleanup = pssService.Register(recovery.ResponseTopic, recoveryRequester.HandleResponse)
+		ns = netstore.New(storer, recoveryRequester.Callback(), retrieve, logger)
 	} else {
 		ns = netstore.New(storer, nil, retrieve, logger)
 	}
 
 	traversalService := traversal.NewService(ns)
 
-	pushSyncProtocol := pushsync.New(p2ps, storer, kad, tagService, pssService.TryUnwrap, logger, acc, accounting.NewFixedPricer(infinityAddress, 1000000000), tracer)
+	pushSyncProtocol := pushsync.New(infinityAddress, signer, networkID, p2ps, storer, kad, kad, tagService, pssService.TryUnwrap, logger.Subsystem("pushsync"), acc, pricer, tracer)
+	pushSyncProtocol.SetEventBus(eventBus)
+	pushSyncProtocol.SetOverlayNonce(op.OverlayNonce)
 
 	// set the pushSyncer in the PSS
 	pssService.SetPushSyncer(pushSyncProtocol)
@@ -399,27 +641,55 @@ func NewVoyager(
 	if err = p2ps.AddProtocol(pushSyncProtocol.Protocol()); err != nil {
 		return nil, nil, nil, fmt.Errorf("pushsync service: %w", err)
 	}
+	if err = p2ps.AddProtocol(pushSyncProtocol.DeprecatedProtocol()); err != nil {
+		return nil, nil, nil, fmt.Errorf("pushsync service: %w", err)
+	}
 
-	if op.GlobalPinningEnabled {
-		// register function for chunk repair upon receiving a trojan message
-		chunkRepairHandler := recovery.NewRepairHandler(ns, logger, pushSyncProtocol)
-		voyager.recoveryHandleCleanup = pssService.Register(recovery.Topic, chunkRepairHandler)
+	if op.RecoveryResponderEnabled {
+		// act as a pinner node and repair the chunks upon receiving a trojan message
+		recoveryResponder := recovery.NewResponder(ns, logger, pushSyncProtocol, pssService, networkID)
+		services.recoveryResponder = recoveryResponder
+		voyager.recoveryHandleCleanup = pssService.Register(recovery.Topic, recoveryResponder.Handle)
 	}
-	pushSyncPusher := pusher.New(storer, kad, pushSyncProtocol, tagService, logger, tracer)
+	pushSyncPusher := pusher.New(storer, kad, pushSyncProtocol, tagService, logger.Subsystem("pusher"), tracer)
 	services.pushSyncPusher = pushSyncPusher
 	voyager.pusherCloser = pushSyncPusher
 
 	pullStorage := pullstorage.New(storer)
 
-	pullSync := pullsync.New(p2ps, pullStorage, pssService.TryUnwrap, logger)
+	pullSync := pullsync.New(p2ps, pullStorage, pssService.TryUnwrap, logger.Subsystem("pullsync"), tracer)
+	pullSync.SetEventBus(eventBus)
+	pullSync.SetOfferFilter(infinityAddress)
+	pullSync.SetBlocklister(p2ps)
+	var wantPolicies []pullsync.WantPolicy
+	if op.PullSyncWithinDepth {
+		wantPolicies = append(wantPolicies, pullsync.DepthWantPolicy(infinityAddress, kad.NeighborhoodDepth))
+	}
+	if op.PullSyncCapacity > 0 {
+		wantPolicies = append(wantPolicies, pullsync.CapacityWantPolicy(func() uint64 {
+			usage, err := storer.Usage()
+			if err != nil {
+				// do not pull the chunks while the usage is unknown
+				logger.Debugf("pullsync want policy: usage: %v", err)
+				return op.PullSyncCapacity
+			}
+			return usage.Cache.Size
+		}, op.PullSyncCapacity))
+	}
+	if len(wantPolicies) > 0 {
+		pullSync.SetWantPolicy(pullsync.AllWantPolicies(wantPolicies...))
+	}
 	services.pullSync = pullSync
 	voyager.pullSyncCloser = pullSync
 
 	if err = p2ps.AddProtocol(pullSync.Protocol()); err != nil {
 		return nil, nil, nil, fmt.Errorf("pullsync protocol: %w", err)
 	}
+	if err = p2ps.AddProtocol(pullSync.DeprecatedProtocol()); err != nil {
+		return nil, nil, nil, fmt.Errorf("pullsync deprecated protocol: %w", err)
+	}
 
-	puller := puller.New(stateStore, kad, pullSync, logger, puller.Options{})
+	puller := puller.New(stateStore, kad, pullSync, logger.Subsystem("puller"), puller.Options{})
 	services.puller = puller
 	voyager.pullerCloser = puller
 
@@ -429,13 +699,33 @@ func NewVoyager(
 	)
 	voyager.resolverCloser = multiResolver
 	if op.APIAddr != "" {
-		apiServer, apiService := APIServer(ns, tagService, multiResolver, pssService, traversalService, logger, tracer, op, *voyager, flg)
+		bodyCapture, err := httpaccess.NewBodyCapture(op.APIBodySampleRate, op.APIBodyMaxSize)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("api body capture: %w", err)
+		}
+		if debugAPIService != nil {
+			debugAPIService.SetBodyCapture(bodyCapture)
+		}
+		apiServer, apiService := APIServer(ns, tagService, multiResolver, pssService, traversalService, signer, logger, tracer, op, bodyCapture, featureFlags, apiKeys, pricer, *voyager, flg)
 		voyager.apiServer = apiServer
-		voyager.apiCloser = apiService
+		voyager.apiService = apiService
 		services.apiService = apiService
 	}
 
+	voyager.reloader = &reloader{
+		logger:          logger,
+		apiService:      voyager.apiService,
+		debugAPIService: debugAPIService,
+		accounting:      acc,
+		pricing:         pricing,
+		kad:             kad,
+		p2p:             p2ps,
+		standalone:      op.Standalone,
+	}
+
 	if debugAPIService != nil {
+		debugAPIService.SetConfigReloader(voyager.Reload)
+		registerHealthChecks(debugAPIService, swapBackend, multiResolver, stateStore, storer, path, op)
 		registerMetrics(services, acc, storer, pushSyncProtocol, logger, settlement, kad, op)
 	}
 
@@ -443,105 +733,95 @@ func NewVoyager(
 		return nil, nil, nil, err
 	}
 	p2ps.Ready()
-	return voyager, cpuawardService, ownerAddress, nil
-}
-
-func (voyager *Voyager) Shutdown(ctx context.Context) error {
-	errs := new(multiError)
-
-	if voyager.apiCloser != nil {
-		if err := voyager.apiCloser.Close(); err != nil {
-			errs.add(fmt.Errorf("api: %w", err))
-		}
-	}
-
-	var eg errgroup.Group
-	if voyager.apiServer != nil {
-		eg.Go(func() error {
-			if err := voyager.apiServer.Shutdown(ctx); err != nil {
-				return fmt.Errorf("api server: %w", err)
-			}
-			return nil
-		})
-	}
-	if voyager.debugAPIServer != nil {
-		eg.Go(func() error {
-			if err := voyager.debugAPIServer.Shutdown(ctx); err != nil {
-				return fmt.Errorf("debug api server: %w", err)
-			}
-			return nil
-		})
-	}
-
-	if err := eg.Wait(); err != nil {
-		errs.add(err)
-	}
-
-	if voyager.recoveryHandleCleanup != nil {
-		voyager.recoveryHandleCleanup()
-	}
-
-	if err := voyager.pusherCloser.Close(); err != nil {
-		errs.add(fmt.Errorf("pusher: %w", err))
-	}
-
-	if err := voyager.pullerCloser.Close(); err != nil {
-		errs.add(fmt.Errorf("puller: %w", err))
-	}
-
-	if err := voyager.pullSyncCloser.Close(); err != nil {
-		errs.add(fmt.Errorf("pull sync: %w", err))
-	}
-
-	if err := voyager.pssCloser.Close(); err != nil {
-		errs.add(fmt.Errorf("pss: %w", err))
-	}
-
-	voyager.p2pCancel()
-	if err := voyager.p2pService.Close(); err != nil {
-		errs.add(fmt.Errorf("p2p server: %w", err))
-	}
-
-	if c := voyager.ethClientCloser; c != nil {
-		c()
-	}
 
-	if err := voyager.tracerCloser.Close(); err != nil {
-		errs.add(fmt.Errorf("tracer: %w", err))
+	if op.DBVerify {
+		go verifyLocalstore(p2pCtx, storer, retrieve, kad, logger)
 	}
+	return voyager, cpuawardService, ownerAddress, nil
+}
 
-	if err := voyager.tagsCloser.Close(); err != nil {
-		errs.add(fmt.Errorf("tag persistence: %w", err))
+// verifyLocalstore verifies the integrity of the stored chunks once the node
+// is connected to peers and retrieves the corrupted chunks again.
+func verifyLocalstore(ctx context.Context, storer *localstore.DB, retrieve retrieval.Interface, kad *kademlia.Kad, logger logging.Logger) {
+	c, unsubscribe := kad.SubscribePeersChange()
+	defer unsubscribe()
+	select {
+	case <-c:
+	case <-ctx.Done():
+		return
 	}
 
-	if err := voyager.stateStoreCloser.Close(); err != nil {
-		errs.add(fmt.Errorf("statestore: %w", err))
+	r, err := storer.Verify(ctx, localstore.VerifyOptions{
+		Retrieve: retrieve.RetrieveChunk,
+	})
+	if err != nil {
+		logger.Debugf("localstore verify: %v", err)
+		logger.Error("localstore verify failed")
+		return
 	}
-
-	if err := voyager.localstoreCloser.Close(); err != nil {
-		errs.add(fmt.Errorf("localstore: %w", err))
+	for _, addr := range r.Corrupted {
+		logger.Debugf("localstore verify: corrupted chunk %s", addr)
 	}
+	logger.Infof("localstore verify: %d chunks verified, %d corrupted, %d repaired", r.Total, len(r.Corrupted), r.Repaired)
+}
 
-	if err := voyager.topologyCloser.Close(); err != nil {
-		errs.add(fmt.Errorf("topology driver: %w", err))
-	}
+// SubscribeEvents returns the channel with the internal node events of the
+// topics, or of all topics if none is given. Returned function unsubscribes
+// and closes the channel.
+func (voyager *Voyager) SubscribeEvents(size int, topics ...events.Topic) (c <-chan events.Event, unsubscribe func()) {
+	return voyager.eventBus.Subscribe(size, topics...)
+}
 
-	if err := voyager.errorLogWriter.Close(); err != nil {
-		errs.add(fmt.Errorf("error log writer: %w", err))
+// Shutdown stops the components of the node in the order of their
+// dependencies. The API first stops accepting new requests and waits for the
+// requests in flight for the drain period.
+func (voyager *Voyager) Shutdown(ctx context.Context) error {
+	l := newLifecycle(voyager.logger)
+
+	if voyager.apiService != nil {
+		l.add("api", func(ctx context.Context) error {
+			drainCtx, cancel := context.WithTimeout(ctx, voyager.drainPeriod)
+			defer cancel()
+			if err := voyager.apiService.Drain(drainCtx); err != nil {
+				voyager.logger.Debugf("api drain: %v", err)
+				voyager.logger.Warning("api requests in flight not finished in the drain period")
+			}
+			return voyager.apiService.Close()
+		})
 	}
-
-	// Shutdown the resolver service only if it has voyagern initialized.
-	if voyager.resolverCloser != nil {
-		if err := voyager.resolverCloser.Close(); err != nil {
-			errs.add(fmt.Errorf("resolver service: %w", err))
-		}
+	if voyager.apiServer != nil {
+		l.add("api server", voyager.apiServer.Shutdown)
 	}
-
-	if errs.hasErrors() {
-		return errs
+	if voyager.debugAPIServer != nil {
+		l.add("debug api server", voyager.debugAPIServer.Shutdown)
 	}
-
-	return nil
+	l.addFunc("recovery responder", voyager.recoveryHandleCleanup)
+	l.addFunc("recovery requester", voyager.recoveryResponseCleanup)
+	l.addCloser("telemetry", voyager.telemetryCloser)
+	l.addCloser("latency prober", voyager.latencyCloser)
+	l.addCloser("addressbook janitor", voyager.addressbookCloser)
+	l.addFunc("accounting events", voyager.accountingEventsCleanup)
+	l.addCloser("pusher", voyager.pusherCloser)
+	l.addCloser("puller", voyager.pullerCloser)
+	l.addCloser("pull sync", voyager.pullSyncCloser)
+	l.addCloser("pss", voyager.pssCloser)
+	// topology is closed before the p2p service to announce the departure
+	// to the neighbors while the connections are still open
+	l.addCloser("topology driver", voyager.topologyCloser)
+	l.add("p2p server", func(context.Context) error {
+		voyager.p2pCancel()
+		return voyager.p2pService.Close()
+	})
+	l.addFunc("eth client", voyager.ethClientCloser)
+	l.addCloser("tracer", voyager.tracerCloser)
+	l.addCloser("tag persistence", voyager.tagsCloser)
+	l.addCloser("api keys", voyager.apiKeysCloser)
+	l.addCloser("statestore", voyager.stateStoreCloser)
+	l.addCloser("localstore", voyager.localstoreCloser)
+	l.addCloser("error log writer", voyager.errorLogWriter)
+	l.addCloser("resolver service", voyager.resolverCloser)
+
+	return l.shutdown(ctx)
 }
 
 type multiError struct {
@@ -574,6 +854,10 @@ func EnableSwap(p2pCtx context.Context, logger logging.Logger, stateStore storag
 		transactionService transaction.Service
 		chequebookFactory  chequebook.Factory
 	)
+	limits, err := ParseChequebookLimits(op.SwapMaxCheque, op.SwapMaxDailyIssuance)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
 	swapBackend, overlayEthAddress, chainID, transactionService, err := InitChain(
 		p2pCtx,
 		logger,
@@ -617,9 +901,31 @@ func EnableSwap(p2pCtx context.Context, logger logging.Logger, stateStore storag
 		overlayEthAddress,
 		transactionService,
 	)
+	// the cheques are issued only if it is enabled, from the chequebook
+	// deployed with the deploy command
+	var chequebookService chequebook.Service
+	if op.SwapIssueCheques {
+		chequebookService, err = LoadChequebookService(
+			p2pCtx,
+			stateStore,
+			signer,
+			chainID,
+			swapBackend,
+			overlayEthAddress,
+			transactionService,
+			chequebookFactory,
+			limits,
+		)
+		if err != nil {
+			if !errors.Is(err, chequebook.ErrNoChequebook) {
+				return nil, nil, nil, nil, err
+			}
+			logger.Info("no chequebook deployed, cheques are not issued")
+		}
+	}
+
 	chequebook := Chequebook{
-		// Service:        chequebookService,
-		Service:        nil,
+		Service:        chequebookService,
 		Store:          chequeStore,
 		CashoutService: cashoutService,
 	}
@@ -635,7 +941,7 @@ func buildProtocols(p2ps *libp2p.Service, logger logging.Logger, tracer *tracing
 		return nil, nil, nil, nil, err
 	}
 
-	hive := hive.New(p2ps, addressbook, networkID, logger)
+	hive := hive.New(p2ps, addressbook, networkID, logger.Subsystem("hive"))
 	if err = p2ps.AddProtocol(hive.Protocol()); err != nil {
 		fmt.Errorf("hive service: %w", err)
 		return nil, nil, nil, nil, err
@@ -646,7 +952,7 @@ func buildProtocols(p2ps *libp2p.Service, logger logging.Logger, tracer *tracing
 		fmt.Errorf("invalid payment threshold: %s", paymentThreshold)
 		return nil, nil, nil, nil, err
 	}
-	pricing := pricing.New(p2ps, logger, paymentThreshold)
+	pricing := pricing.New(p2ps, logger.Subsystem("pricing"), paymentThreshold)
 	if err := p2ps.AddProtocol(pricing.Protocol()); err != nil {
 		fmt.Errorf("pricing service: %w", err)
 		return nil, nil, nil, nil, err
@@ -654,14 +960,32 @@ func buildProtocols(p2ps *libp2p.Service, logger logging.Logger, tracer *tracing
 	return pingPong, hive, paymentThreshold, pricing, nil
 }
 
-func APIServer(ns storage.Storer, tagService *tags.Tags, multiResolver *multiresolver.MultiResolver, pssService pss.Interface, traversalService traversal.Service, logger logging.Logger, tracer *tracing.Tracer, op Options, voyager Voyager, flg *cpc.InterruptFlag) (*http.Server, api.Service) {
+func APIServer(ns storage.Storer, tagService *tags.Tags, multiResolver *multiresolver.MultiResolver, pssService pss.Interface, traversalService traversal.Service, signer crypto.Signer, logger logging.Logger, tracer *tracing.Tracer, op Options, bodyCapture *httpaccess.BodyCapture, featureFlags features.Checker, apiKeys *apikey.Service, pricer accounting.Pricer, voyager Voyager, flg *cpc.InterruptFlag) (*http.Server, api.Service) {
 	// API server
 	feedFactory := factory.New(ns)
-	apiService := api.New(tagService, ns, multiResolver, pssService, traversalService, feedFactory, logger, tracer, api.Options{
+	apiOptions := api.Options{
 		CORSAllowedOrigins: op.CORSAllowedOrigins,
 		GatewayMode:        op.GatewayMode,
 		WsPingPeriod:       60 * time.Second,
-	}, flg)
+		Compression:        op.APICompression,
+		MaxUploadSize:      op.APIMaxUploadSize,
+		MaxImportSize:      op.APIMaxImportSize,
+		UploadReadTimeout:  op.APIUploadReadTimeout,
+		WriteTimeout:       op.APIWriteTimeout,
+		BodyCapture:        bodyCapture,
+		PrefetchChunks:     op.APIPrefetchChunks,
+		Features:           featureFlags,
+		Pricer:             pricer,
+
+		GatewayRateLimit:        op.GatewayRateLimit,
+		GatewayRateBurst:        op.GatewayRateBurst,
+		GatewayMaxUploadSize:    op.GatewayMaxUploadSize,
+		GatewayAllowedEndpoints: op.GatewayAllowedEndpoints,
+	}
+	if op.GatewayAPIKeys {
+		apiOptions.GatewayAPIKeys = apiKeys
+	}
+	apiService := api.New(tagService, ns, multiResolver, pssService, traversalService, feedFactory, signer, logger.Subsystem("api"), tracer, apiOptions, flg)
 	apiListener, err := net.Listen("tcp", op.APIAddr)
 	if err != nil {
 		fmt.Errorf("api listener: %w", err)
@@ -672,6 +996,7 @@ func APIServer(ns storage.Storer, tagService *tags.Tags, multiResolver *multires
 		IdleTimeout:       30 * time.Second,
 		ReadHeaderTimeout: 3 * time.Second,
 		Handler:           apiService,
+		ConnContext:       api.ConnContext,
 		ErrorLog:          log.New(voyager.errorLogWriter, "", 0),
 	}
 
@@ -708,6 +1033,15 @@ func registerMetrics(
 	debugAPIService.MustRegisterMetrics(services.pushSyncPusher.Metrics()...)
 	debugAPIService.MustRegisterMetrics(services.pullSync.Metrics()...)
 	debugAPIService.MustRegisterMetrics(services.retrieve.Metrics()...)
+	debugAPIService.MustRegisterMetrics(services.latency.Metrics()...)
+	debugAPIService.MustRegisterMetrics(services.eventBus.Metrics()...)
+	debugAPIService.MustRegisterMetrics(kad.Metrics()...)
+	if services.janitor != nil {
+		debugAPIService.MustRegisterMetrics(services.janitor.Metrics()...)
+	}
+	if services.recoveryResponder != nil {
+		debugAPIService.MustRegisterMetrics(services.recoveryResponder.Metrics()...)
+	}
 
 	if pssServiceMetrics, ok := services.pssService.(metrics.Collector); ok {
 		debugAPIService.MustRegisterMetrics(pssServiceMetrics.Metrics()...)
@@ -715,6 +1049,7 @@ func registerMetrics(
 
 	if services.apiService != nil {
 		debugAPIService.MustRegisterMetrics(services.apiService.Metrics()...)
+		debugAPIService.SetAPIRoutes(services.apiService)
 	}
 	if l, ok := logger.(metrics.Collector); ok {
 		debugAPIService.MustRegisterMetrics(l.Metrics()...)
@@ -724,6 +1059,10 @@ func registerMetrics(
 		debugAPIService.MustRegisterMetrics(l.Metrics()...)
 	}
 
+	debugAPIService.SetAddressBook(services.addressBook)
+	debugAPIService.SetFeatures(services.features)
+	debugAPIService.SetAPIKeys(services.apiKeys)
+
 	// inject dependencies and configure full debug api http path routes
-	debugAPIService.Configure(services.p2ps, services.pingPong, kad, storer, services.tagService, acc, settlement, op.SwapEnable, services.swapService, services.chequebookService)
+	debugAPIService.Configure(services.p2ps, services.pingPong, kad, storer, services.tagService, acc, settlement, op.SwapEnable, services.swapService, services.chequebookService, services.pullSync, services.telemetry)
 }
@@ -0,0 +1,117 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/sirupsen/logrus"
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/api"
+	"github.com/yanhuangpai/voyager/pkg/debugapi"
+	"github.com/yanhuangpai/voyager/pkg/kademlia"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/pricing"
+)
+
+var errReloadNotSupported = errors.New("configuration reloading not supported")
+
+// RuntimeOptions are the options which can be changed without restarting the
+// node.
+type RuntimeOptions struct {
+	LogLevel           logrus.Level
+	SubsystemLogLevels map[string]logrus.Level
+	CORSAllowedOrigins []string
+	GatewayMode        bool
+	Bootnodes          []string
+	PaymentThreshold   string
+	PaymentTolerance   string
+	PaymentEarly       string
+}
+
+// reloader applies the runtime options to the running components.
+type reloader struct {
+	logger          logging.Logger
+	apiService      api.Service
+	debugAPIService *debugapi.Service
+	accounting      *accounting.Accounting
+	pricing         *pricing.Service
+	kad             *kademlia.Kad
+	p2p             p2p.Service
+	standalone      bool
+}
+
+// apply validates all runtime options before changing any of them, so that an
+// invalid configuration is not applied partially.
+func (r *reloader) apply(ctx context.Context, o RuntimeOptions) error {
+	paymentThreshold, ok := new(big.Int).SetString(o.PaymentThreshold, 10)
+	if !ok {
+		return fmt.Errorf("invalid payment threshold: %s", o.PaymentThreshold)
+	}
+	paymentTolerance, ok := new(big.Int).SetString(o.PaymentTolerance, 10)
+	if !ok {
+		return fmt.Errorf("invalid payment tolerance: %s", o.PaymentTolerance)
+	}
+	paymentEarly, ok := new(big.Int).SetString(o.PaymentEarly, 10)
+	if !ok {
+		return fmt.Errorf("invalid payment early: %s", o.PaymentEarly)
+	}
+	var bootnodes []ma.Multiaddr
+	for _, a := range o.Bootnodes {
+		addr, err := ma.NewMultiaddr(a)
+		if err != nil {
+			return fmt.Errorf("invalid bootnode address %s: %w", a, err)
+		}
+		bootnodes = append(bootnodes, addr)
+	}
+
+	r.logger.SetLevel(o.LogLevel)
+	for name, level := range o.SubsystemLogLevels {
+		r.logger.SetSubsystemLevel(name, level)
+	}
+	if r.apiService != nil {
+		r.apiService.SetCORSAllowedOrigins(o.CORSAllowedOrigins)
+		r.apiService.SetGatewayMode(o.GatewayMode)
+	}
+	if r.debugAPIService != nil {
+		r.debugAPIService.SetCORSAllowedOrigins(o.CORSAllowedOrigins)
+	}
+
+	announce := r.pricing.PaymentThreshold().Cmp(paymentThreshold) != 0
+	r.accounting.SetPaymentThresholds(paymentThreshold, paymentTolerance, paymentEarly)
+	r.pricing.SetPaymentThreshold(paymentThreshold)
+	if announce {
+		for _, p := range r.p2p.Peers() {
+			if err := r.pricing.AnnouncePaymentThreshold(ctx, p.Address, paymentThreshold); err != nil {
+				r.logger.Debugf("reload: announce payment threshold to peer %s: %v", p.Address, err)
+			}
+		}
+	}
+
+	if !r.standalone {
+		r.kad.SetBootnodes(bootnodes)
+	}
+
+	r.logger.Info("configuration reloaded")
+	return nil
+}
+
+// Reload reads the runtime options with the ReloadConfig function of the node
+// options and applies them to the running node.
+func (voyager *Voyager) Reload(ctx context.Context) error {
+	if voyager.reloadConfig == nil || voyager.reloader == nil {
+		return errReloadNotSupported
+	}
+	o, err := voyager.reloadConfig()
+	if err != nil {
+		return fmt.Errorf("read configuration: %w", err)
+	}
+	return voyager.reloader.apply(ctx, o)
+}
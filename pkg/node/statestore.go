@@ -46,3 +46,38 @@ func CheckOverlayWithStore(overlay infinity.Address, storer storage.StateStorer)
 	}
 	return nil
 }
+
+const overlayNonceKey = "overlay_nonce"
+
+// ErrOverlayInUse is returned if the overlay nonce is set after the node was
+// started with its overlay address.
+var ErrOverlayInUse = errors.New("overlay address already in use")
+
+// OverlayNonce returns the nonce the overlay address of the node is derived
+// with, nil if the node uses the overlay address without the nonce.
+func OverlayNonce(storer storage.StateStorer) ([]byte, error) {
+	var nonce []byte
+	err := storer.Get(overlayNonceKey, &nonce)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// SetOverlayNonce stores the nonce the overlay address of the node is derived
+// with. The nonce can not be changed once the node is started with its
+// overlay address, as the data of the node is bound to it.
+func SetOverlayNonce(nonce []byte, storer storage.StateStorer) error {
+	var storedOverlay infinity.Address
+	err := storer.Get(overlayKey, &storedOverlay)
+	if err == nil {
+		return fmt.Errorf("%w: %s", ErrOverlayInUse, storedOverlay)
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return storer.Put(overlayNonceKey, nonce)
+}
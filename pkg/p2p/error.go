@@ -16,6 +16,9 @@ var (
 	ErrPeerNotFound = errors.New("peer not found")
 	// ErrAlreadyConnected is returned if connect was called for already connected node.
 	ErrAlreadyConnected = errors.New("already connected")
+	// ErrListenerNotFound is returned if there is no listener on the address
+	// which should be closed.
+	ErrListenerNotFound = errors.New("listener not found")
 )
 
 // ConnectionBackoffError indicates that connection calls will not be executed until `tryAfter` timetamp.
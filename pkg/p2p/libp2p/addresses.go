@@ -0,0 +1,133 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	libp2ppeer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p/internal/handshake"
+)
+
+// AdvertisePolicy selects the underlay addresses which are advertised to the
+// peers in the handshake.
+type AdvertisePolicy string
+
+const (
+	// AdvertisePrivateAllowed advertises the underlay observed by the peer,
+	// even if it is in a private network.
+	AdvertisePrivateAllowed AdvertisePolicy = "private-allowed"
+	// AdvertisePublicOnly advertises only the publicly routable underlays,
+	// the public address of the node is advertised instead of the private
+	// one observed by the peers in the same network.
+	AdvertisePublicOnly AdvertisePolicy = "public-only"
+)
+
+var errNoAdvertisableAddress = errors.New("no advertisable address")
+
+// ParseAdvertisePolicy returns the advertise policy with the name, the
+// private addresses are allowed if the name is empty.
+func ParseAdvertisePolicy(name string) (AdvertisePolicy, error) {
+	switch p := AdvertisePolicy(name); p {
+	case "":
+		return AdvertisePrivateAllowed, nil
+	case AdvertisePrivateAllowed, AdvertisePublicOnly:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown advertise policy %q", name)
+}
+
+// allows returns true if the underlay can be advertised with the policy.
+// The DNS underlays are considered public.
+func (p AdvertisePolicy) allows(underlay ma.Multiaddr) bool {
+	if p != AdvertisePublicOnly {
+		return true
+	}
+	switch c, _ := ma.SplitFirst(underlay); c.Protocol().Code {
+	case ma.P_DNS, ma.P_DNS4, ma.P_DNS6, ma.P_DNSADDR:
+		return true
+	}
+	return manet.IsPublicAddr(underlay)
+}
+
+// listenAddresses returns the multiaddrs to listen on for the comma separated
+// list of host and port pairs. A pair with an IPv4 or IPv6 host listens on
+// that address only, otherwise on all IPv4 and IPv6 addresses.
+func listenAddresses(addr string, enableWS, enableQUIC bool) ([]string, error) {
+	var listenAddrs []string
+	for _, a := range strings.Split(addr, ",") {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(a))
+		if err != nil {
+			return nil, fmt.Errorf("address: %w", err)
+		}
+
+		ips := []string{"/ip4/0.0.0.0", "/ip6/::"}
+		if ip := net.ParseIP(host); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ips = []string{"/ip4/" + ip4.String()}
+			} else {
+				ips = []string{"/ip6/" + ip.String()}
+			}
+		}
+
+		for _, ip := range ips {
+			listenAddrs = append(listenAddrs, fmt.Sprintf("%s/tcp/%s", ip, port))
+			if enableWS {
+				listenAddrs = append(listenAddrs, fmt.Sprintf("%s/tcp/%s/ws", ip, port))
+			}
+			if enableQUIC {
+				listenAddrs = append(listenAddrs, fmt.Sprintf("%s/udp/%s/quic", ip, port))
+			}
+		}
+	}
+	return listenAddrs, nil
+}
+
+// policyAddressResolver applies the advertise policy to the underlays
+// resolved by the wrapped resolver. An underlay not allowed by the policy is
+// replaced with an allowed address of the node, preferably of the same
+// address family as the observed one.
+type policyAddressResolver struct {
+	resolver handshake.AdvertisableAddressResolver
+	policy   AdvertisePolicy
+	addrs    func() []ma.Multiaddr // addresses of the node without the peer id
+}
+
+func (r *policyAddressResolver) Resolve(observedAddress ma.Multiaddr) (ma.Multiaddr, error) {
+	underlay, err := r.resolver.Resolve(observedAddress)
+	if err != nil {
+		return nil, err
+	}
+	if r.policy.allows(underlay) {
+		return underlay, nil
+	}
+
+	info, err := libp2ppeer.AddrInfoFromP2pAddr(underlay)
+	if err != nil {
+		return nil, err
+	}
+
+	var other ma.Multiaddr
+	for _, a := range r.addrs() {
+		if !r.policy.allows(a) {
+			continue
+		}
+		if isIPv6(a) == isIPv6(underlay) {
+			return buildUnderlayAddress(a, info.ID)
+		}
+		if other == nil {
+			other = a
+		}
+	}
+	if other != nil {
+		return buildUnderlayAddress(other, info.ID)
+	}
+	return nil, fmt.Errorf("%w for the %s policy: %s", errNoAdvertisableAddress, r.policy, underlay)
+}
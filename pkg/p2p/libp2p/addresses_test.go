@@ -0,0 +1,235 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p"
+)
+
+func TestListenAddresses(t *testing.T) {
+	for _, tc := range []struct {
+		addr      string
+		ws        bool
+		want      []string
+		wantError bool
+	}{
+		{
+			addr: ":1634",
+			want: []string{"/ip4/0.0.0.0/tcp/1634", "/ip6/::/tcp/1634"},
+		},
+		{
+			addr: "127.0.0.1:1634",
+			want: []string{"/ip4/127.0.0.1/tcp/1634"},
+		},
+		{
+			addr: "[::1]:1634",
+			ws:   true,
+			want: []string{"/ip6/::1/tcp/1634", "/ip6/::1/tcp/1634/ws"},
+		},
+		{
+			addr: "0.0.0.0:1634, [::]:1635",
+			want: []string{"/ip4/0.0.0.0/tcp/1634", "/ip6/::/tcp/1635"},
+		},
+		{
+			addr:      "1634",
+			wantError: true,
+		},
+	} {
+		t.Run(tc.addr, func(t *testing.T) {
+			got, err := libp2p.ListenAddresses(tc.addr, tc.ws, false)
+			if tc.wantError {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseAdvertisePolicy(t *testing.T) {
+	for name, want := range map[string]libp2p.AdvertisePolicy{
+		"":                libp2p.AdvertisePrivateAllowed,
+		"private-allowed": libp2p.AdvertisePrivateAllowed,
+		"public-only":     libp2p.AdvertisePublicOnly,
+	} {
+		got, err := libp2p.ParseAdvertisePolicy(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("got policy %q for %q, want %q", got, name, want)
+		}
+	}
+
+	if _, err := libp2p.ParseAdvertisePolicy("public"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+// observedResolver resolves the observed address to itself.
+type observedResolver struct{}
+
+func (observedResolver) Resolve(observed ma.Multiaddr) (ma.Multiaddr, error) {
+	return observed, nil
+}
+
+func TestPolicyAddressResolver(t *testing.T) {
+	const peerID = "/p2p/16Uiu2HAkx8ULY8cTXhdVAcMmLcH9AsTKz6uBQ7DPLKRjMLgBVYkA"
+
+	hostAddrs := []ma.Multiaddr{
+		ma.StringCast("/ip4/192.168.1.2/tcp/1634"),
+		ma.StringCast("/ip6/fd00::2/tcp/1634"),
+		ma.StringCast("/ip4/5.6.7.8/tcp/1634"),
+		ma.StringCast("/ip6/2a00:1450::2/tcp/1634"),
+	}
+
+	for _, tc := range []struct {
+		name     string
+		policy   libp2p.AdvertisePolicy
+		addrs    []ma.Multiaddr
+		observed string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "private allowed",
+			policy:   libp2p.AdvertisePrivateAllowed,
+			addrs:    hostAddrs,
+			observed: "/ip4/192.168.1.2/tcp/1634",
+			want:     "/ip4/192.168.1.2/tcp/1634",
+		},
+		{
+			name:     "public observed",
+			policy:   libp2p.AdvertisePublicOnly,
+			addrs:    hostAddrs,
+			observed: "/ip4/1.2.3.4/tcp/1634",
+			want:     "/ip4/1.2.3.4/tcp/1634",
+		},
+		{
+			name:     "private ipv4 observed",
+			policy:   libp2p.AdvertisePublicOnly,
+			addrs:    hostAddrs,
+			observed: "/ip4/192.168.1.2/tcp/1634",
+			want:     "/ip4/5.6.7.8/tcp/1634",
+		},
+		{
+			name:     "private ipv6 observed",
+			policy:   libp2p.AdvertisePublicOnly,
+			addrs:    hostAddrs,
+			observed: "/ip6/fd00::2/tcp/1634",
+			want:     "/ip6/2a00:1450::2/tcp/1634",
+		},
+		{
+			name:     "ipv6 only node",
+			policy:   libp2p.AdvertisePublicOnly,
+			addrs:    hostAddrs[3:],
+			observed: "/ip4/192.168.1.2/tcp/1634",
+			want:     "/ip6/2a00:1450::2/tcp/1634",
+		},
+		{
+			name:     "dns",
+			policy:   libp2p.AdvertisePublicOnly,
+			observed: "/dns4/example.com/tcp/1634",
+			want:     "/dns4/example.com/tcp/1634",
+		},
+		{
+			name:     "no public address",
+			policy:   libp2p.AdvertisePublicOnly,
+			addrs:    hostAddrs[:2],
+			observed: "/ip4/192.168.1.2/tcp/1634",
+			wantErr:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := libp2p.NewPolicyAddressResolver(observedResolver{}, tc.policy, func() []ma.Multiaddr {
+				return tc.addrs
+			})
+
+			got, err := r.Resolve(ma.StringCast(tc.observed + peerID))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := ma.StringCast(tc.want + peerID); !got.Equal(want) {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// TestConnectIPv6Only tests the connections between an IPv6 only node and a
+// dual-stack node, and that the IPv6 only node advertises its IPv6 underlay.
+func TestConnectIPv6Only(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s1, overlay1 := newService(t, 1, libp2pServiceOpts{listenAddr: "[::1]:0"})
+	s2, overlay2 := newService(t, 1, libp2pServiceOpts{})
+
+	for _, a := range s1.ListenAddresses() {
+		if _, err := a.ValueForProtocol(ma.P_IP4); err == nil {
+			t.Fatalf("ipv6 only node listens on %s", a)
+		}
+	}
+
+	ifiAddr, err := s2.Connect(ctx, serviceUnderlayAddress(t, s1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isIPv6(ifiAddr.Underlay) {
+		t.Fatalf("got advertised underlay %s, want ipv6", ifiAddr.Underlay)
+	}
+	expectPeers(t, s2, overlay1)
+	expectPeersEventually(t, s1, overlay2)
+
+	if err := s2.Disconnect(overlay1); err != nil {
+		t.Fatal(err)
+	}
+	expectPeers(t, s2)
+	expectPeersEventually(t, s1)
+
+	// the ipv6 only node connects to the ipv6 address of the dual-stack node
+	var addr ma.Multiaddr
+	addrs, err := s2.Addresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range addrs {
+		if isIPv6(a) {
+			addr = a
+			break
+		}
+	}
+	if addr == nil {
+		t.Fatal("dual-stack node has no ipv6 address")
+	}
+	if _, err := s1.Connect(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+	expectPeers(t, s1, overlay2)
+	expectPeersEventually(t, s2, overlay1)
+}
+
+func isIPv6(a ma.Multiaddr) bool {
+	_, err := a.ValueForProtocol(ma.P_IP6)
+	return err == nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+)
+
+// bandwidthMeter counts bytes transferred over streams per peer and per
+// protocol, and optionally limits the rate of data transfer for every peer,
+// separately in each direction.
+type bandwidthMeter struct {
+	peers     map[string]*bandwidthCounter
+	protocols map[string]*bandwidthCounter
+	limiters  map[string]*peerRateLimiters
+	peerLimit int64 // bytes per second, 0 disables rate limiting
+	received  *prometheus.CounterVec
+	sent      *prometheus.CounterVec
+	mu        sync.Mutex
+}
+
+type bandwidthCounter struct {
+	in, out uint64
+}
+
+// peerRateLimiters limit the rates of the data received from and sent to a
+// peer.
+type peerRateLimiters struct {
+	read, write *rateLimiter
+}
+
+func newBandwidthMeter(peerLimit int64, received, sent *prometheus.CounterVec) *bandwidthMeter {
+	return &bandwidthMeter{
+		peers:     make(map[string]*bandwidthCounter),
+		protocols: make(map[string]*bandwidthCounter),
+		limiters:  make(map[string]*peerRateLimiters),
+		peerLimit: peerLimit,
+		received:  received,
+		sent:      sent,
+	}
+}
+
+// stream returns the bandwidth accounting for a new stream of the protocol
+// with the peer. The transfers delayed by the rate limit fail when the context
+// is done.
+func (m *bandwidthMeter) stream(ctx context.Context, overlay infinity.Address, protocol string) *streamBandwidth {
+	key := overlay.ByteString()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	peer, ok := m.peers[key]
+	if !ok {
+		peer = new(bandwidthCounter)
+		m.peers[key] = peer
+	}
+	proto, ok := m.protocols[protocol]
+	if !ok {
+		proto = new(bandwidthCounter)
+		m.protocols[protocol] = proto
+	}
+	b := &streamBandwidth{
+		ctx:      ctx,
+		peer:     peer,
+		protocol: proto,
+		received: m.received.WithLabelValues(protocol),
+		sent:     m.sent.WithLabelValues(protocol),
+	}
+	if m.peerLimit > 0 {
+		limiters, ok := m.limiters[key]
+		if !ok {
+			limiters = &peerRateLimiters{
+				read:  newRateLimiter(m.peerLimit),
+				write: newRateLimiter(m.peerLimit),
+			}
+			m.limiters[key] = limiters
+		}
+		b.readLimiter, b.writeLimiter = limiters.read, limiters.write
+	}
+	return b
+}
+
+// remove drops the counters of a disconnected peer.
+func (m *bandwidthMeter) remove(overlay infinity.Address) {
+	key := overlay.ByteString()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.peers, key)
+	delete(m.limiters, key)
+}
+
+func (m *bandwidthMeter) bandwidth() p2p.Bandwidth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b := p2p.Bandwidth{
+		Peers:     make(map[string]p2p.BandwidthStats, len(m.peers)),
+		Protocols: make(map[string]p2p.BandwidthStats, len(m.protocols)),
+	}
+	for k, c := range m.peers {
+		b.Peers[infinity.NewAddress([]byte(k)).String()] = c.stats()
+	}
+	for k, c := range m.protocols {
+		b.Protocols[k] = c.stats()
+	}
+	return b
+}
+
+func (c *bandwidthCounter) stats() p2p.BandwidthStats {
+	return p2p.BandwidthStats{
+		In:  atomic.LoadUint64(&c.in),
+		Out: atomic.LoadUint64(&c.out),
+	}
+}
+
+// streamBandwidth accounts bytes transferred over a single stream.
+type streamBandwidth struct {
+	ctx          context.Context
+	peer         *bandwidthCounter
+	protocol     *bandwidthCounter
+	readLimiter  *rateLimiter
+	writeLimiter *rateLimiter
+	received     prometheus.Counter
+	sent         prometheus.Counter
+}
+
+// read accounts the n bytes read from the stream, returning the context error
+// if the wait for the rate limit is canceled.
+func (b *streamBandwidth) read(n int) error {
+	atomic.AddUint64(&b.peer.in, uint64(n))
+	atomic.AddUint64(&b.protocol.in, uint64(n))
+	b.received.Add(float64(n))
+	if b.readLimiter == nil {
+		return nil
+	}
+	return b.readLimiter.wait(b.ctx, n)
+}
+
+// write accounts the n bytes written to the stream, returning the context
+// error if the wait for the rate limit is canceled.
+func (b *streamBandwidth) write(n int) error {
+	atomic.AddUint64(&b.peer.out, uint64(n))
+	atomic.AddUint64(&b.protocol.out, uint64(n))
+	b.sent.Add(float64(n))
+	if b.writeLimiter == nil {
+		return nil
+	}
+	return b.writeLimiter.wait(b.ctx, n)
+}
+
+// rateLimiter is a token bucket which allows transfer of the configured
+// number of bytes per second with the burst of the same size.
+type rateLimiter struct {
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until the transfer of n bytes is within the rate limit or the
+// context is done. The tokens of the canceled wait are returned.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens += float64(n)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
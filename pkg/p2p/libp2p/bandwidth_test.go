@@ -0,0 +1,117 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p"
+)
+
+func TestBandwidth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s1, overlay1 := newService(t, 1, libp2pServiceOpts{})
+
+	s2, overlay2 := newService(t, 1, libp2pServiceOpts{})
+
+	data := []byte("some data that is sent over the stream")
+
+	handled := make(chan struct{})
+	if err := s1.AddProtocol(newTestProtocol(func(_ context.Context, _ p2p.Peer, stream p2p.Stream) error {
+		defer close(handled)
+		defer stream.FullClose()
+		_, err := ioutil.ReadAll(stream)
+		return err
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := serviceUnderlayAddress(t, s1)
+
+	if _, err := s2.Connect(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := s2.NewStream(ctx, overlay1, nil, testProtocolName, testProtocolVersion, testStreamName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.FullClose(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-handled:
+	case <-time.After(30 * time.Second):
+		t.Fatal("timeout waiting for handler")
+	}
+
+	// the exchanged headers are metered as well
+	sent := s2.Bandwidth()
+	if got := sent.Protocols[testProtocolName].Out; got < uint64(len(data)) {
+		t.Errorf("got %v sent bytes, want at least %v", got, len(data))
+	}
+	if got := sent.Peers[overlay1.String()].Out; got < uint64(len(data)) {
+		t.Errorf("got %v sent bytes to peer, want at least %v", got, len(data))
+	}
+
+	received := s1.Bandwidth()
+	if got := received.Protocols[testProtocolName].In; got < uint64(len(data)) {
+		t.Errorf("got %v received bytes, want at least %v", got, len(data))
+	}
+	if got := received.Peers[overlay2.String()].In; got < uint64(len(data)) {
+		t.Errorf("got %v received bytes from peer, want at least %v", got, len(data))
+	}
+
+	if err := s2.Disconnect(overlay1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s2.Bandwidth().Peers[overlay1.String()]; ok {
+		t.Error("bandwidth of disconnected peer not removed")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := libp2p.NewRateLimiter(1000)
+
+	start := time.Now()
+
+	// the burst is not limited
+	if err := l.Wait(context.Background(), 1000); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("burst limited for %v", d)
+	}
+
+	// the next transfer is delayed until the tokens are refilled
+	if err := l.Wait(context.Background(), 200); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Fatalf("transfer over the limit not delayed, took %v", d)
+	}
+
+	// the delayed transfer fails when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if err := l.Wait(ctx, 5000); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("canceled transfer delayed for %v", d)
+	}
+}
@@ -59,6 +59,50 @@ func TestConnectDisconnect(t *testing.T) {
 	expectPeersEventually(t, s1)
 }
 
+func TestConnectCapabilities(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s1, overlay1 := newService(t, 1, libp2pServiceOpts{libp2pOpts: libp2p.Options{
+		BootnodeMode: true,
+		PssEnabled:   true,
+	}})
+	if err := s1.AddProtocol(newTestProtocol(func(_ context.Context, _ p2p.Peer, _ p2p.Stream) error {
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	s2, overlay2 := newService(t, 1, libp2pServiceOpts{libp2pOpts: libp2p.Options{
+		LightNode: true,
+	}})
+
+	addr := serviceUnderlayAddress(t, s1)
+
+	if _, err := s2.Connect(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	expectPeers(t, s2, overlay1)
+	expectPeersEventually(t, s1, overlay2)
+
+	p1 := s2.Peers()[0]
+	if !p1.HasCapabilities(p2p.CapabilityBootnode|p2p.CapabilityPss) || p1.HasCapabilities(p2p.CapabilityLightNode) {
+		t.Fatalf("got peer capabilities %b", p1.Capabilities)
+	}
+	if v, ok := p1.FeatureVersion(testProtocolName); !ok || v != testProtocolVersion {
+		t.Fatalf("got feature version %q (%v), want %q", v, ok, testProtocolVersion)
+	}
+
+	p2 := s1.Peers()[0]
+	if p2.Capabilities != p2p.CapabilityLightNode {
+		t.Fatalf("got peer capabilities %b, want %b", p2.Capabilities, p2p.CapabilityLightNode)
+	}
+	if _, ok := p2.FeatureVersion(testProtocolName); ok {
+		t.Fatal("unexpected feature version")
+	}
+}
+
 func TestDoubleConnect(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -511,6 +555,138 @@ func TestTopologyOverSaturated(t *testing.T) {
 	waitAddrSet(t, &n2disconnectedPeer.Address, &mtx, overlay1)
 }
 
+func TestReconnectAlternateUnderlay(t *testing.T) {
+	for _, preference := range []libp2p.DialPreference{
+		libp2p.DialPreferenceNone,
+		libp2p.DialPreferenceIPv4,
+		libp2p.DialPreferenceIPv6,
+	} {
+		t.Run(string(preference), func(t *testing.T) {
+			testReconnectAlternateUnderlay(t, preference)
+		})
+	}
+}
+
+func testReconnectAlternateUnderlay(t *testing.T, preference libp2p.DialPreference) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s1, overlay1 := newService(t, 1, libp2pServiceOpts{})
+
+	ab2 := addressbook.New(mock.NewStateStore())
+	s2, overlay2 := newService(t, 1, libp2pServiceOpts{
+		Addressbook: ab2,
+		libp2pOpts:  libp2p.Options{DialPreference: preference},
+	})
+
+	addr := serviceUnderlayAddress(t, s1)
+
+	if _, err := s2.Connect(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	expectPeers(t, s2, overlay1)
+	expectPeersEventually(t, s1, overlay2)
+
+	if err := s2.Disconnect(overlay1); err != nil {
+		t.Fatal(err)
+	}
+
+	expectPeers(t, s2)
+	expectPeersEventually(t, s1)
+
+	// replace the stored underlay with the one that is not reachable
+	info, err := libp2ppeer.AddrInfoFromP2pAddr(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	staleAddr, err := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/1/p2p/" + info.ID.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ifiAddr, err := ab2.Get(overlay1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ifiAddr.Underlay = staleAddr
+	if err := ab2.Put(overlay1, *ifiAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s2.Reconnect(ctx, overlay1); err != nil {
+		t.Fatal(err)
+	}
+
+	expectPeers(t, s2, overlay1)
+	expectPeersEventually(t, s1, overlay2)
+
+	if err := s2.Disconnect(overlay1); err != nil {
+		t.Fatal(err)
+	}
+
+	expectPeers(t, s2)
+	expectPeersEventually(t, s1)
+
+	// connect reaches the peer on its other underlays
+	address, err := s2.Connect(ctx, staleAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !address.Overlay.Equal(overlay1) {
+		t.Fatalf("got overlay %s, want %s", address.Overlay, overlay1)
+	}
+
+	expectPeers(t, s2, overlay1)
+	expectPeersEventually(t, s1, overlay2)
+}
+
+func TestReconnectUnknownPeer(t *testing.T) {
+	s, _ := newService(t, 1, libp2pServiceOpts{})
+
+	overlay := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+
+	if err := s.Reconnect(context.Background(), overlay); !errors.Is(err, addressbook.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, addressbook.ErrNotFound)
+	}
+}
+
+func TestReconnectThroughNotifier(t *testing.T) {
+	s, _ := newService(t, 1, libp2pServiceOpts{})
+
+	overlay := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+	errBackoff := errors.New("backoff")
+
+	var reconnected []infinity.Address
+	s.SetPickyNotifier(&reconnectNotifiee{
+		PickyNotifier: mockNotifier(
+			func(context.Context, p2p.Peer) error { return nil },
+			func(p2p.Peer) {},
+			true,
+		),
+		reconnect: func(_ context.Context, overlay infinity.Address) error {
+			reconnected = append(reconnected, overlay)
+			return errBackoff
+		},
+	})
+
+	// the notifier decides whether and how to reconnect
+	if err := s.Reconnect(context.Background(), overlay); !errors.Is(err, errBackoff) {
+		t.Fatalf("got error %v, want %v", err, errBackoff)
+	}
+	if len(reconnected) != 1 || !reconnected[0].Equal(overlay) {
+		t.Fatalf("got reconnected peers %v, want %s", reconnected, overlay)
+	}
+}
+
+type reconnectNotifiee struct {
+	p2p.PickyNotifier
+	reconnect func(context.Context, infinity.Address) error
+}
+
+func (n *reconnectNotifiee) Reconnect(ctx context.Context, overlay infinity.Address) error {
+	return n.reconnect(ctx, overlay)
+}
+
 func expectZeroAddress(t *testing.T, addrs ...infinity.Address) {
 	t.Helper()
 	for i, a := range addrs {
@@ -0,0 +1,175 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	libp2ppeer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/yanhuangpai/voyager/pkg/ifi"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+)
+
+// DialPreference is the address family of the underlays dialed first when
+// connecting to a peer with both IPv4 and IPv6 underlays.
+type DialPreference string
+
+const (
+	// DialPreferenceNone dials the underlays one after another in the order
+	// they are known.
+	DialPreferenceNone DialPreference = ""
+	// DialPreferenceIPv4 dials the IPv4 and IPv6 underlays in parallel,
+	// starting with an IPv4 one.
+	DialPreferenceIPv4 DialPreference = "ipv4"
+	// DialPreferenceIPv6 dials the IPv4 and IPv6 underlays in parallel,
+	// starting with an IPv6 one.
+	DialPreferenceIPv6 DialPreference = "ipv6"
+)
+
+// happyEyeballsDelay is the time to wait for a dial to connect before the
+// next underlay is dialed in parallel, as recommended in RFC 8305.
+var happyEyeballsDelay = 250 * time.Millisecond
+
+// ParseDialPreference returns the dial preference with the name.
+func ParseDialPreference(name string) (DialPreference, error) {
+	switch p := DialPreference(name); p {
+	case DialPreferenceNone, DialPreferenceIPv4, DialPreferenceIPv6:
+		return p, nil
+	}
+	return DialPreferenceNone, fmt.Errorf("unknown dial preference %q", name)
+}
+
+// orderUnderlays returns the underlays ordered for dialing with the
+// preference. The address families are interleaved starting with the
+// preferred one, keeping the order of the underlays within each family.
+func orderUnderlays(underlays []ma.Multiaddr, preference DialPreference) []ma.Multiaddr {
+	if preference == DialPreferenceNone {
+		return underlays
+	}
+
+	var preferred, other []ma.Multiaddr
+	for _, u := range underlays {
+		if isIPv6(u) == (preference == DialPreferenceIPv6) {
+			preferred = append(preferred, u)
+		} else {
+			other = append(other, u)
+		}
+	}
+
+	ordered := make([]ma.Multiaddr, 0, len(underlays))
+	for i := 0; i < len(preferred) || i < len(other); i++ {
+		if i < len(preferred) {
+			ordered = append(ordered, preferred[i])
+		}
+		if i < len(other) {
+			ordered = append(ordered, other[i])
+		}
+	}
+	return ordered
+}
+
+// isIPv6 returns true if the underlay is an IPv6 address or a DNS name
+// resolved only to IPv6 addresses.
+func isIPv6(underlay ma.Multiaddr) bool {
+	if _, err := underlay.ValueForProtocol(ma.P_IP6); err == nil {
+		return true
+	}
+	_, err := underlay.ValueForProtocol(ma.P_DNS6)
+	return err == nil
+}
+
+// dialUnderlays connects to the peer on one of the underlays. If the overlay
+// address is not zero, a connection to a peer with a different overlay address
+// is treated as a failed dial. Without a dial preference the underlays are
+// dialed one after another. With a preference they are dialed in the happy
+// eyeballs style: the next underlay is dialed if the previous dials did not
+// connect within the happy eyeballs delay or as soon as one of them fails, and
+// the first successful connection is used while the other dials are canceled.
+// The p2p.ErrAlreadyConnected error is returned together with the address of
+// the peer, as by Connect.
+func (s *Service) dialUnderlays(ctx context.Context, overlay infinity.Address, underlays []ma.Multiaddr) (*ifi.Address, error) {
+	type result struct {
+		underlay ma.Multiaddr
+		address  *ifi.Address
+		err      error
+	}
+
+	underlays = orderUnderlays(underlays, s.dialPreference)
+	parallel := s.dialPreference != DialPreferenceNone
+
+	// the dials still in progress are canceled on return
+	dialCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the results channel is buffered for all dials, so that the dials
+	// still in progress after the first connection do not block
+	results := make(chan result, len(underlays))
+	dial := func(underlay ma.Multiaddr) {
+		address, err := s.connect(dialCtx, underlay)
+		results <- result{underlay: underlay, address: address, err: err}
+	}
+
+	err := p2p.ErrPeerNotFound
+	next, pending := 0, 0
+	for next < len(underlays) || pending > 0 {
+		if next < len(underlays) && (pending == 0 || parallel) {
+			go dial(underlays[next])
+			next++
+			pending++
+		}
+
+		var (
+			timer *time.Timer
+			delay <-chan time.Time
+		)
+		if parallel && next < len(underlays) {
+			timer = time.NewTimer(happyEyeballsDelay)
+			delay = timer.C
+		}
+
+		var r *result
+		select {
+		case res := <-results:
+			r = &res
+		case <-delay:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if r == nil {
+			continue
+		}
+
+		pending--
+		if r.err != nil && !errors.Is(r.err, p2p.ErrAlreadyConnected) {
+			s.logger.Tracef("dial: peer %s on underlay %s: %v", overlay, r.underlay, r.err)
+			err = r.err
+			continue
+		}
+		if !overlay.IsZero() && !r.address.Overlay.Equal(overlay) {
+			err = fmt.Errorf("underlay %s: unexpected overlay %s", r.underlay, r.address.Overlay)
+			continue
+		}
+		return r.address, r.err
+	}
+	return nil, err
+}
+
+// closeFailedDial closes the connections to the peer opened by a failed or
+// canceled dial, unless the peer was connected in the meantime, as by another
+// dial of its underlays.
+func (s *Service) closeFailedDial(peerID libp2ppeer.ID) {
+	if _, found := s.peers.overlay(peerID); found {
+		return
+	}
+	_ = s.host.Network().ClosePeer(peerID)
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p_test
+
+import (
+	"reflect"
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p"
+)
+
+func TestOrderUnderlays(t *testing.T) {
+	var (
+		ip4a = ma.StringCast("/ip4/10.0.0.1/tcp/1634")
+		ip4b = ma.StringCast("/ip4/10.0.0.2/tcp/1634")
+		ip6a = ma.StringCast("/ip6/fd00::1/tcp/1634")
+		ip6b = ma.StringCast("/dns6/example.com/tcp/1634")
+	)
+	underlays := []ma.Multiaddr{ip4a, ip4b, ip6a, ip6b}
+
+	for _, tc := range []struct {
+		preference libp2p.DialPreference
+		want       []ma.Multiaddr
+	}{
+		{libp2p.DialPreferenceNone, []ma.Multiaddr{ip4a, ip4b, ip6a, ip6b}},
+		{libp2p.DialPreferenceIPv4, []ma.Multiaddr{ip4a, ip6a, ip4b, ip6b}},
+		{libp2p.DialPreferenceIPv6, []ma.Multiaddr{ip6a, ip4a, ip6b, ip4b}},
+	} {
+		t.Run(string(tc.preference), func(t *testing.T) {
+			got := libp2p.OrderUnderlays(underlays, tc.preference)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseDialPreference(t *testing.T) {
+	for _, name := range []string{"", "ipv4", "ipv6"} {
+		if _, err := libp2p.ParseDialPreference(name); err != nil {
+			t.Errorf("%q: %v", name, err)
+		}
+	}
+	if _, err := libp2p.ParseDialPreference("ipv5"); err == nil {
+		t.Error("expected error for unknown preference")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p/internal/handshake"
+)
+
+// defaultConnectionEventsSize is the number of the recent connection events
+// kept if it is not configured.
+const defaultConnectionEventsSize = 1000
+
+// Reasons of the disconnects recorded in the connection events.
+const (
+	disconnectLocal       = "local"       // requested by this node
+	disconnectRemote      = "remote"      // closed by the peer or lost
+	disconnectBlocklisted = "blocklisted" // the peer is blocklisted
+	disconnectRejected    = "rejected"    // the peer is not wanted by the topology
+	disconnectHandshake   = "handshake"   // the handshake could not be completed
+)
+
+var errPeerBlocklisted = errors.New("peer blocklisted")
+
+// connectionEvents is a ring buffer of the recent connection events.
+type connectionEvents struct {
+	events []p2p.ConnectionEvent
+	next   int  // index of the next event
+	full   bool // the oldest events are overwritten
+	mu     sync.Mutex
+}
+
+func newConnectionEvents(size int) *connectionEvents {
+	if size <= 0 {
+		size = defaultConnectionEventsSize
+	}
+	return &connectionEvents{
+		events: make([]p2p.ConnectionEvent, size),
+	}
+}
+
+// add records the event, overwriting the oldest one if the buffer is full.
+func (c *connectionEvents) add(e p2p.ConnectionEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.events[c.next] = e
+	c.next = (c.next + 1) % len(c.events)
+	if c.next == 0 {
+		c.full = true
+	}
+}
+
+// all returns the recorded events from the oldest to the newest.
+func (c *connectionEvents) all() []p2p.ConnectionEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.full {
+		return append([]p2p.ConnectionEvent(nil), c.events[:c.next]...)
+	}
+	events := make([]p2p.ConnectionEvent, 0, len(c.events))
+	events = append(events, c.events[c.next:]...)
+	return append(events, c.events[:c.next]...)
+}
+
+// ConnectionEvents returns the recent connection events, from the oldest to
+// the newest.
+func (s *Service) ConnectionEvents() []p2p.ConnectionEvent {
+	return s.connectionEvents.all()
+}
+
+// connectionFailed records the failed inbound or outbound connection.
+func (s *Service) connectionFailed(inbound bool, overlay infinity.Address, underlay ma.Multiaddr, err error) {
+	s.connectionEvents.add(p2p.ConnectionEvent{
+		Type:      p2p.ConnectionEventFailed,
+		Inbound:   inbound,
+		Overlay:   overlay,
+		Underlay:  underlay,
+		ErrorType: errorType(err),
+		Reason:    err.Error(),
+	})
+}
+
+// errorType returns the class of the connection error.
+func errorType(err error) string {
+	var errBackoff *p2p.ConnectionBackoffError
+	var errNet net.Error
+	switch {
+	case errors.As(err, &errBackoff):
+		return "backoff"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &errNet) && errNet.Timeout():
+		return "timeout"
+	case errors.Is(err, errPeerBlocklisted):
+		return "blocklisted"
+	case errors.Is(err, handshake.ErrNetworkIDIncompatible),
+		errors.Is(err, handshake.ErrHandshakeDuplicate),
+		errors.Is(err, handshake.ErrInvalidAck),
+		errors.Is(err, handshake.ErrInvalidSyn):
+		return "handshake"
+	}
+
+	// the dial errors are not wrapped by all transports
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "dial backoff"):
+		return "backoff"
+	case strings.Contains(msg, "connection refused"):
+		return "refused"
+	case strings.Contains(msg, "no route to host"), strings.Contains(msg, "network is unreachable"):
+		return "unreachable"
+	case strings.Contains(msg, "i/o timeout"):
+		return "timeout"
+	case strings.Contains(msg, "handshake"):
+		return "handshake"
+	}
+	return "other"
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p"
+)
+
+func TestConnectionEvents(t *testing.T) {
+	s1, overlay1 := newService(t, 1, libp2pServiceOpts{})
+	s2, overlay2 := newService(t, 1, libp2pServiceOpts{})
+
+	addr1 := serviceUnderlayAddress(t, s1)
+
+	if _, err := s2.Connect(context.Background(), addr1); err != nil {
+		t.Fatal(err)
+	}
+	expectPeersEventually(t, s1, overlay2)
+
+	if err := s2.Blocklist(overlay1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s2.Connect(context.Background(), addr1); err == nil {
+		t.Fatal("expected error during connection, got nil")
+	}
+
+	expectConnectionEvents(t, s2.ConnectionEvents(), []p2p.ConnectionEvent{
+		{Type: p2p.ConnectionEventDial, Underlay: addr1},
+		{Type: p2p.ConnectionEventConnected, Overlay: overlay1, Underlay: addr1},
+		{Type: p2p.ConnectionEventBlocklisted, Overlay: overlay1, Reason: "blocklisted for 0s"},
+		{Type: p2p.ConnectionEventDisconnected, Overlay: overlay1, Reason: "blocklisted"},
+		{Type: p2p.ConnectionEventDial, Underlay: addr1},
+		{Type: p2p.ConnectionEventFailed, Underlay: addr1, ErrorType: "blocklisted", Reason: "peer blocklisted"},
+	})
+
+	events := s1.ConnectionEvents()
+	if len(events) == 0 {
+		t.Fatal("no connection events")
+	}
+	if e := events[0]; e.Type != p2p.ConnectionEventConnected || !e.Inbound || !e.Overlay.Equal(overlay2) {
+		t.Fatalf("got first event %+v, want inbound connection from %s", e, overlay2)
+	}
+}
+
+func TestConnectionEventsSize(t *testing.T) {
+	s1, _ := newService(t, 1, libp2pServiceOpts{})
+	s2, _ := newService(t, 2, libp2pServiceOpts{libp2pOpts: libp2p.Options{
+		ConnectionEvents: 3,
+	}})
+
+	addr1 := serviceUnderlayAddress(t, s1)
+
+	// every failed connection attempt records the dial and the failure
+	for i := 0; i < 2; i++ {
+		if _, err := s2.Connect(context.Background(), addr1); err == nil {
+			t.Fatal("connect attempt should result with an error")
+		}
+	}
+
+	expectConnectionEvents(t, s2.ConnectionEvents(), []p2p.ConnectionEvent{
+		{Type: p2p.ConnectionEventFailed, Underlay: addr1, ErrorType: "handshake"},
+		{Type: p2p.ConnectionEventDial, Underlay: addr1},
+		{Type: p2p.ConnectionEventFailed, Underlay: addr1, ErrorType: "handshake"},
+	})
+}
+
+// expectConnectionEvents compares the events without their times, and without
+// the reasons of the failures which are not set in the expected events.
+func expectConnectionEvents(t *testing.T, got, want []p2p.ConnectionEvent) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d events %+v, want %d", len(got), got, len(want))
+	}
+	for i, g := range got {
+		w := want[i]
+		if g.Time.IsZero() {
+			t.Errorf("event %d: zero time", i)
+		}
+		if i > 0 && g.Time.Before(got[i-1].Time) {
+			t.Errorf("event %d: time %s before the previous event", i, g.Time)
+		}
+		if w.Type == p2p.ConnectionEventFailed && w.Reason == "" {
+			g.Reason = ""
+		}
+		g.Time = w.Time
+		if g.Type != w.Type || g.Inbound != w.Inbound || !g.Overlay.Equal(w.Overlay) ||
+			!equalUnderlay(g, w) || g.ErrorType != w.ErrorType || g.Reason != w.Reason {
+			t.Errorf("event %d: got %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func equalUnderlay(a, b p2p.ConnectionEvent) bool {
+	if a.Underlay == nil || b.Underlay == nil {
+		return a.Underlay == nil && b.Underlay == nil
+	}
+	return a.Underlay.Equal(b.Underlay)
+}
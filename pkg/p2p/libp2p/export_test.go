@@ -9,6 +9,9 @@ import (
 
 	"github.com/libp2p/go-libp2p-core/network"
 	libp2ppeer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
 	handshake "github.com/yanhuangpai/voyager/pkg/p2p/libp2p/internal/handshake"
 )
 
@@ -23,3 +26,31 @@ func (s *Service) NewStreamForPeerID(peerID libp2ppeer.ID, protocolName, protoco
 type StaticAddressResolver = staticAddressResolver
 
 var NewStaticAddressResolver = newStaticAddressResolver
+
+type Scheduler = scheduler
+
+var NewScheduler = newScheduler
+
+func (s *scheduler) Admit(ctx context.Context, p p2p.Priority) (queued bool, done func(), err error) {
+	return s.admit(ctx, p)
+}
+
+type RateLimiter = rateLimiter
+
+var NewRateLimiter = newRateLimiter
+
+func (l *rateLimiter) Wait(ctx context.Context, n int) error {
+	return l.wait(ctx, n)
+}
+
+func (s *Service) Reconnect(ctx context.Context, overlay infinity.Address) error {
+	return s.reconnectPeer(ctx, overlay)
+}
+
+var OrderUnderlays = orderUnderlays
+
+var ListenAddresses = listenAddresses
+
+func NewPolicyAddressResolver(r handshake.AdvertisableAddressResolver, policy AdvertisePolicy, addrs func() []ma.Multiaddr) handshake.AdvertisableAddressResolver {
+	return &policyAddressResolver{resolver: r, policy: policy, addrs: addrs}
+}
@@ -178,3 +178,83 @@ func TestHeadler(t *testing.T) {
 		t.Errorf("got sent headers %+v, want %+v", gotSentHeaders, sentHeaders)
 	}
 }
+
+func TestHeaders_deadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s1, overlay1 := newService(t, 1, libp2pServiceOpts{})
+
+	s2, _ := newService(t, 1, libp2pServiceOpts{})
+
+	gotDeadline := make(chan bool, 1)
+	if err := s1.AddProtocol(newTestProtocol(func(ctx context.Context, _ p2p.Peer, _ p2p.Stream) error {
+		_, ok := ctx.Deadline()
+		gotDeadline <- ok
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := serviceUnderlayAddress(t, s1)
+
+	if _, err := s2.Connect(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	headers := make(p2p.Headers)
+	headers.SetDeadline(time.Now().Add(time.Minute))
+
+	stream, err := s2.NewStream(ctx, overlay1, headers, testProtocolName, testProtocolVersion, testStreamName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	select {
+	case ok := <-gotDeadline:
+		if !ok {
+			t.Error("handler context has no deadline")
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("timeout waiting for handler")
+	}
+}
+
+func TestHeaders_deadlineExceeded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s1, overlay1 := newService(t, 1, libp2pServiceOpts{})
+
+	s2, _ := newService(t, 1, libp2pServiceOpts{})
+
+	handled := make(chan struct{})
+	if err := s1.AddProtocol(newTestProtocol(func(_ context.Context, _ p2p.Peer, _ p2p.Stream) error {
+		close(handled)
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := serviceUnderlayAddress(t, s1)
+
+	if _, err := s2.Connect(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+
+	headers := make(p2p.Headers)
+	headers.SetDeadline(time.Now().Add(-time.Second))
+
+	// error is not checked as the stream may be reset by s1 before or after
+	// the headers are exchanged
+	if stream, err := s2.NewStream(ctx, overlay1, headers, testProtocolName, testProtocolVersion, testStreamName); err == nil {
+		defer stream.Close()
+	}
+
+	select {
+	case <-handled:
+		t.Fatal("handler called for expired stream")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handshake
+
+var MaxSessions = &maxSessions
+
+// Sessions returns the number of the resumable sessions.
+func (s *Service) Sessions() int {
+	return s.sessions.len()
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,8 +29,10 @@ import (
 const (
 	// ProtocolName is the text of the name of the handshake protocol.
 	ProtocolName = "handshake"
-	// ProtocolVersion is the current handshake protocol version.
-	ProtocolVersion = "2.0.0"
+	// ProtocolVersion is the current handshake protocol version. The peers
+	// on the previous versions do not know the overlay nonce, so they could
+	// not verify the mined overlay addresses and are not connected to.
+	ProtocolVersion = "3.0.0"
 	// StreamName is the name of the stream used for handshake purposes.
 	StreamName = "handshake"
 	// MaxWelcomeMessageLength is maximum number of characters allowed in the welcome message.
@@ -64,11 +67,18 @@ type Service struct {
 	signer                crypto.Signer
 	advertisableAddresser AdvertisableAddressResolver
 	overlay               infinity.Address
-	lightNode             bool
+	nonce                 []byte
+	capabilities          p2p.Capabilities
 	networkID             uint64
 	welcomeMessage        atomic.Value
+	featureVersions       map[string]string
+	protocolVersions      map[string][]string
+	featureVersionsMu     sync.RWMutex
 	receivedHandshakes    map[libp2ppeer.ID]struct{}
 	receivedHandshakesMu  sync.Mutex
+	sessions              *sessionCache
+	localAddr             *ifi.Address
+	localAddressMu        sync.Mutex
 	logger                logging.Logger
 
 	network.Notifiee // handshake service can be the receiver for network.Notify
@@ -76,12 +86,17 @@ type Service struct {
 
 // Info contains the information received from the handshake.
 type Info struct {
-	IfiAddress *ifi.Address
-	Light      bool
+	IfiAddress       *ifi.Address
+	Light            bool
+	Capabilities     p2p.Capabilities
+	FeatureVersions  map[string]string
+	ProtocolVersions map[string][]string
 }
 
-// New creates a new handshake Service.
-func New(signer crypto.Signer, advertisableAddresser AdvertisableAddressResolver, overlay infinity.Address, networkID uint64, lighNode bool, welcomeMessage string, logger logging.Logger) (*Service, error) {
+// New creates a new handshake Service. The provided capabilities are
+// advertised to every peer that the handshake is performed with. The nonce is
+// the one the overlay address is derived with, nil for the legacy address.
+func New(signer crypto.Signer, advertisableAddresser AdvertisableAddressResolver, overlay infinity.Address, nonce []byte, networkID uint64, capabilities p2p.Capabilities, welcomeMessage string, logger logging.Logger) (*Service, error) {
 	if len(welcomeMessage) > MaxWelcomeMessageLength {
 		return nil, ErrWelcomeMessageLength
 	}
@@ -90,9 +105,13 @@ func New(signer crypto.Signer, advertisableAddresser AdvertisableAddressResolver
 		signer:                signer,
 		advertisableAddresser: advertisableAddresser,
 		overlay:               overlay,
+		nonce:                 nonce,
 		networkID:             networkID,
-		lightNode:             lighNode,
+		capabilities:          capabilities,
+		featureVersions:       make(map[string]string),
+		protocolVersions:      make(map[string][]string),
 		receivedHandshakes:    make(map[libp2ppeer.ID]struct{}),
+		sessions:              newSessionCache(),
 		logger:                logger,
 		Notifiee:              new(network.NoopNotifiee),
 	}
@@ -128,7 +147,7 @@ func (s *Service) Handshake(ctx context.Context, stream p2p.Stream, peerMultiadd
 		return nil, fmt.Errorf("read synack message: %w", err)
 	}
 
-	remoteIfiAddress, err := s.parseCheckAck(resp.Ack)
+	remoteIfiAddress, err := s.parseCheckAck(resp.Ack, peerID)
 	if err != nil {
 		return nil, err
 	}
@@ -143,7 +162,7 @@ func (s *Service) Handshake(ctx context.Context, stream p2p.Stream, peerMultiadd
 		return nil, err
 	}
 
-	ifiAddress, err := ifi.NewAddress(s.signer, advertisableUnderlay, s.overlay, s.networkID)
+	ifiAddress, err := s.localAddress(advertisableUnderlay)
 	if err != nil {
 		return nil, err
 	}
@@ -160,10 +179,14 @@ func (s *Service) Handshake(ctx context.Context, stream p2p.Stream, peerMultiadd
 			Underlay:  advertisableUnderlayBytes,
 			Overlay:   ifiAddress.Overlay.Bytes(),
 			Signature: ifiAddress.Signature,
+			Nonce:     ifiAddress.Nonce,
 		},
-		NetworkID:      s.networkID,
-		Light:          s.lightNode,
-		WelcomeMessage: welcomeMessage,
+		NetworkID:       s.networkID,
+		Light:           s.capabilities.Has(p2p.CapabilityLightNode),
+		Capabilities:    uint64(s.capabilities),
+		FeatureVersions: s.getFeatureVersions(),
+		Protocols:       s.getProtocolVersions(),
+		WelcomeMessage:  welcomeMessage,
 	}); err != nil {
 		return nil, fmt.Errorf("write ack message: %w", err)
 	}
@@ -173,10 +196,7 @@ func (s *Service) Handshake(ctx context.Context, stream p2p.Stream, peerMultiadd
 		s.logger.Infof("greeting \"%s\" from peer: %s", resp.Ack.WelcomeMessage, remoteIfiAddress.Overlay.String())
 	}
 
-	return &Info{
-		IfiAddress: remoteIfiAddress,
-		Light:      resp.Ack.Light,
-	}, nil
+	return newInfo(remoteIfiAddress, resp.Ack), nil
 }
 
 // Handle handles an incoming handshake from a peer.
@@ -218,7 +238,7 @@ func (s *Service) Handle(ctx context.Context, stream p2p.Stream, remoteMultiaddr
 		return nil, err
 	}
 
-	ifiAddress, err := ifi.NewAddress(s.signer, advertisableUnderlay, s.overlay, s.networkID)
+	ifiAddress, err := s.localAddress(advertisableUnderlay)
 	if err != nil {
 		return nil, err
 	}
@@ -239,10 +259,14 @@ func (s *Service) Handle(ctx context.Context, stream p2p.Stream, remoteMultiaddr
 				Underlay:  advertisableUnderlayBytes,
 				Overlay:   ifiAddress.Overlay.Bytes(),
 				Signature: ifiAddress.Signature,
+				Nonce:     ifiAddress.Nonce,
 			},
-			NetworkID:      s.networkID,
-			Light:          s.lightNode,
-			WelcomeMessage: welcomeMessage,
+			NetworkID:       s.networkID,
+			Light:           s.capabilities.Has(p2p.CapabilityLightNode),
+			Capabilities:    uint64(s.capabilities),
+			FeatureVersions: s.getFeatureVersions(),
+			Protocols:       s.getProtocolVersions(),
+			WelcomeMessage:  welcomeMessage,
 		},
 	}); err != nil {
 		return nil, fmt.Errorf("write synack message: %w", err)
@@ -253,17 +277,14 @@ func (s *Service) Handle(ctx context.Context, stream p2p.Stream, remoteMultiaddr
 		return nil, fmt.Errorf("read ack message: %w", err)
 	}
 
-	remoteIfiAddress, err := s.parseCheckAck(&ack)
+	remoteIfiAddress, err := s.parseCheckAck(&ack, remotePeerID)
 	if err != nil {
 		return nil, err
 	}
 
 	s.logger.Tracef("handshake finished for peer (inbound) %s", remoteIfiAddress.Overlay.String())
 
-	return &Info{
-		IfiAddress: remoteIfiAddress,
-		Light:      ack.Light,
-	}, nil
+	return newInfo(remoteIfiAddress, &ack), nil
 }
 
 // Disconnected is called when the peer disconnects.
@@ -287,19 +308,103 @@ func (s *Service) GetWelcomeMessage() string {
 	return s.welcomeMessage.Load().(string)
 }
 
+// SetFeatureVersion sets the version of the named feature that is advertised
+// to peers in subsequent handshakes.
+func (s *Service) SetFeatureVersion(name, version string) {
+	s.featureVersionsMu.Lock()
+	defer s.featureVersionsMu.Unlock()
+	s.featureVersions[name] = version
+}
+
+func (s *Service) getFeatureVersions() map[string]string {
+	s.featureVersionsMu.RLock()
+	defer s.featureVersionsMu.RUnlock()
+
+	if len(s.featureVersions) == 0 {
+		return nil
+	}
+	versions := make(map[string]string, len(s.featureVersions))
+	for k, v := range s.featureVersions {
+		versions[k] = v
+	}
+	return versions
+}
+
+// SetProtocolVersions sets all versions of the named protocol that are
+// advertised to peers in subsequent handshakes.
+func (s *Service) SetProtocolVersions(name string, versions []string) {
+	s.featureVersionsMu.Lock()
+	defer s.featureVersionsMu.Unlock()
+	s.protocolVersions[name] = append([]string(nil), versions...)
+}
+
+func (s *Service) getProtocolVersions() []*pb.ProtocolVersions {
+	s.featureVersionsMu.RLock()
+	defer s.featureVersionsMu.RUnlock()
+
+	if len(s.protocolVersions) == 0 {
+		return nil
+	}
+	protocols := make([]*pb.ProtocolVersions, 0, len(s.protocolVersions))
+	for name, versions := range s.protocolVersions {
+		protocols = append(protocols, &pb.ProtocolVersions{
+			Name:     name,
+			Versions: versions,
+		})
+	}
+	sort.Slice(protocols, func(i, j int) bool {
+		return protocols[i].Name < protocols[j].Name
+	})
+	return protocols
+}
+
+func newInfo(address *ifi.Address, ack *pb.Ack) *Info {
+	capabilities := p2p.Capabilities(ack.Capabilities)
+	// peers which do not advertise capabilities still report the light mode
+	if ack.Light {
+		capabilities |= p2p.CapabilityLightNode
+	}
+	var protocolVersions map[string][]string
+	if len(ack.Protocols) > 0 {
+		protocolVersions = make(map[string][]string, len(ack.Protocols))
+		for _, p := range ack.Protocols {
+			protocolVersions[p.Name] = p.Versions
+		}
+	}
+	return &Info{
+		IfiAddress:       address,
+		Light:            capabilities.Has(p2p.CapabilityLightNode),
+		Capabilities:     capabilities,
+		FeatureVersions:  ack.FeatureVersions,
+		ProtocolVersions: protocolVersions,
+	}
+}
+
 func buildFullMA(addr ma.Multiaddr, peerID libp2ppeer.ID) (ma.Multiaddr, error) {
 	return ma.NewMultiaddr(fmt.Sprintf("%s/p2p/%s", addr.String(), peerID.Pretty()))
 }
 
-func (s *Service) parseCheckAck(ack *pb.Ack) (*ifi.Address, error) {
+// parseCheckAck verifies the address of the peer in the ack, unless the same
+// address has been verified for the peer recently.
+func (s *Service) parseCheckAck(ack *pb.Ack, peerID libp2ppeer.ID) (*ifi.Address, error) {
 	if ack.NetworkID != s.networkID {
 		return nil, ErrNetworkIDIncompatible
 	}
+	if ack.Address == nil {
+		return nil, ErrInvalidAck
+	}
+
+	key := sessionKey{peerID: peerID, networkID: s.networkID}
+	if ifiAddress, ok := s.sessions.get(key, ack.Address); ok {
+		return ifiAddress, nil
+	}
 
-	ifiAddress, err := ifi.ParseAddress(ack.Address.Underlay, ack.Address.Overlay, ack.Address.Signature, s.networkID)
+	ifiAddress, err := ifi.ParseAddress(ack.Address.Underlay, ack.Address.Overlay, ack.Address.Signature, ack.Address.Nonce, s.networkID)
 	if err != nil {
+		s.sessions.remove(key)
 		return nil, ErrInvalidAck
 	}
+	s.sessions.put(key, ack.Address, ifiAddress)
 
 	return ifiAddress, nil
 }
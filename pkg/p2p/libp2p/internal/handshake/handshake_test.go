@@ -10,11 +10,13 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"reflect"
 	"testing"
 
 	"github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/ifi"
 	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
 	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p/internal/handshake"
 	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p/internal/handshake/mock"
 	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p/internal/handshake/pb"
@@ -64,19 +66,19 @@ func TestHandshake(t *testing.T) {
 
 	signer1 := crypto.NewDefaultSigner(privateKey1)
 	signer2 := crypto.NewDefaultSigner(privateKey2)
-	addr, err := crypto.NewOverlayAddress(privateKey1.PublicKey, networkID)
+	addr, err := crypto.NewOverlayAddress(privateKey1.PublicKey, networkID, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	node1IfiAddress, err := ifi.NewAddress(signer1, node1ma, addr, networkID)
+	node1IfiAddress, err := ifi.NewAddress(signer1, node1ma, addr, networkID, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	addr2, err := crypto.NewOverlayAddress(privateKey2.PublicKey, networkID)
+	addr2, err := crypto.NewOverlayAddress(privateKey2.PublicKey, networkID, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
-	node2IfiAddress, err := ifi.NewAddress(signer2, node2ma, addr2, networkID)
+	node2IfiAddress, err := ifi.NewAddress(signer2, node2ma, addr2, networkID, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -92,7 +94,7 @@ func TestHandshake(t *testing.T) {
 
 	aaddresser := &AdvertisableAddresserMock{}
 
-	handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, networkID, false, testWelcomeMessage, logger)
+	handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, 0, testWelcomeMessage, logger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -156,11 +158,86 @@ func TestHandshake(t *testing.T) {
 		}
 	})
 
+	t.Run("Handshake - capabilities", func(t *testing.T) {
+		capabilities := p2p.CapabilityBootnode | p2p.CapabilityPss
+		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, capabilities, "", logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		handshakeService.SetFeatureVersion("pushsync", "1.0.0")
+		handshakeService.SetProtocolVersions("pushsync", []string{"1.1.0", "1.0.0"})
+
+		var buffer1 bytes.Buffer
+		var buffer2 bytes.Buffer
+		stream1 := mock.NewStream(&buffer1, &buffer2)
+		stream2 := mock.NewStream(&buffer2, &buffer1)
+
+		w, r := protobuf.NewWriterAndReader(stream2)
+		if err := w.WriteMsg(&pb.SynAck{
+			Syn: &pb.Syn{
+				ObservedUnderlay: node1maBinary,
+			},
+			Ack: &pb.Ack{
+				Address: &pb.IfiAddress{
+					Underlay:  node2maBinary,
+					Overlay:   node2IfiAddress.Overlay.Bytes(),
+					Signature: node2IfiAddress.Signature,
+				},
+				NetworkID:       networkID,
+				Light:           true,
+				Capabilities:    uint64(p2p.CapabilityChain),
+				FeatureVersions: map[string]string{"pullsync": "1.0.0"},
+				Protocols: []*pb.ProtocolVersions{
+					{Name: "pullsync", Versions: []string{"1.0.0", "0.9.0"}},
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := handshakeService.Handshake(context.Background(), stream1, node2AddrInfo.Addrs[0], node2AddrInfo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testInfo(t, *res, handshake.Info{
+			IfiAddress:   node2IfiAddress,
+			Light:        true,
+			Capabilities: p2p.CapabilityChain | p2p.CapabilityLightNode,
+		})
+		if v := res.FeatureVersions["pullsync"]; v != "1.0.0" {
+			t.Fatalf("got pullsync feature version %q, want %q", v, "1.0.0")
+		}
+		if v := res.ProtocolVersions["pullsync"]; !reflect.DeepEqual(v, []string{"1.0.0", "0.9.0"}) {
+			t.Fatalf("got pullsync protocol versions %v, want %v", v, []string{"1.0.0", "0.9.0"})
+		}
+
+		var syn pb.Syn
+		if err := r.ReadMsg(&syn); err != nil {
+			t.Fatal(err)
+		}
+
+		var ack pb.Ack
+		if err := r.ReadMsg(&ack); err != nil {
+			t.Fatal(err)
+		}
+
+		if p2p.Capabilities(ack.Capabilities) != capabilities || ack.Light {
+			t.Fatalf("got ack capabilities %b light %v, want %b light false", ack.Capabilities, ack.Light, capabilities)
+		}
+		if v := ack.FeatureVersions["pushsync"]; v != "1.0.0" {
+			t.Fatalf("got ack pushsync feature version %q, want %q", v, "1.0.0")
+		}
+		if len(ack.Protocols) != 1 || ack.Protocols[0].Name != "pushsync" || !reflect.DeepEqual(ack.Protocols[0].Versions, []string{"1.1.0", "1.0.0"}) {
+			t.Fatalf("got ack protocol versions %v, want pushsync %v", ack.Protocols, []string{"1.1.0", "1.0.0"})
+		}
+	})
+
 	t.Run("Handshake - welcome message too long", func(t *testing.T) {
 		const LongMessage = "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Morbi consectetur urna ut lorem sollicitudin posuere. Donec sagittis laoreet sapien."
 
 		expectedErr := handshake.ErrWelcomeMessageLength
-		_, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, networkID, false, LongMessage, logger)
+		_, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, 0, LongMessage, logger)
 		if err == nil || err.Error() != expectedErr.Error() {
 			t.Fatal("expected:", expectedErr, "got:", err)
 		}
@@ -368,7 +445,7 @@ func TestHandshake(t *testing.T) {
 	})
 
 	t.Run("Handle - OK", func(t *testing.T) {
-		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, networkID, false, "", logger)
+		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, 0, "", logger)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -413,7 +490,7 @@ func TestHandshake(t *testing.T) {
 			t.Fatalf("got bad syn")
 		}
 
-		ifiAddress, err := ifi.ParseAddress(got.Ack.Address.Underlay, got.Ack.Address.Overlay, got.Ack.Address.Signature, got.Ack.NetworkID)
+		ifiAddress, err := ifi.ParseAddress(got.Ack.Address.Underlay, got.Ack.Address.Overlay, got.Ack.Address.Signature, got.Ack.Address.Nonce, got.Ack.NetworkID)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -425,7 +502,7 @@ func TestHandshake(t *testing.T) {
 	})
 
 	t.Run("Handle - read error ", func(t *testing.T) {
-		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, networkID, false, "", logger)
+		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, 0, "", logger)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -444,7 +521,7 @@ func TestHandshake(t *testing.T) {
 	})
 
 	t.Run("Handle - write error ", func(t *testing.T) {
-		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, networkID, false, "", logger)
+		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, 0, "", logger)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -471,7 +548,7 @@ func TestHandshake(t *testing.T) {
 	})
 
 	t.Run("Handle - ack read error ", func(t *testing.T) {
-		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, networkID, false, "", logger)
+		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, 0, "", logger)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -500,7 +577,7 @@ func TestHandshake(t *testing.T) {
 	})
 
 	t.Run("Handle - networkID mismatch ", func(t *testing.T) {
-		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, networkID, false, "", logger)
+		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, 0, "", logger)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -539,7 +616,7 @@ func TestHandshake(t *testing.T) {
 	})
 
 	t.Run("Handle - duplicate handshake", func(t *testing.T) {
-		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, networkID, false, "", logger)
+		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, 0, "", logger)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -584,7 +661,7 @@ func TestHandshake(t *testing.T) {
 			t.Fatalf("got bad syn")
 		}
 
-		ifiAddress, err := ifi.ParseAddress(got.Ack.Address.Underlay, got.Ack.Address.Overlay, got.Ack.Address.Signature, got.Ack.NetworkID)
+		ifiAddress, err := ifi.ParseAddress(got.Ack.Address.Underlay, got.Ack.Address.Overlay, got.Ack.Address.Signature, got.Ack.Address.Nonce, got.Ack.NetworkID)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -601,7 +678,7 @@ func TestHandshake(t *testing.T) {
 	})
 
 	t.Run("Handle - invalid ack", func(t *testing.T) {
-		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, networkID, false, "", logger)
+		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, 0, "", logger)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -636,7 +713,7 @@ func TestHandshake(t *testing.T) {
 	})
 
 	t.Run("Handle - advertisable error", func(t *testing.T) {
-		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, networkID, false, "", logger)
+		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, 0, "", logger)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -667,12 +744,84 @@ func TestHandshake(t *testing.T) {
 			t.Fatal("expected nil res")
 		}
 	})
+
+	t.Run("Handshake - session resumption", func(t *testing.T) {
+		defer func(max int) {
+			*handshake.MaxSessions = max
+		}(*handshake.MaxSessions)
+		*handshake.MaxSessions = 1
+
+		handshakeService, err := handshake.New(signer1, aaddresser, node1Info.IfiAddress.Overlay, nil, networkID, 0, "", logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		doHandshake := func(peerID libp2ppeer.ID, signature []byte) (*handshake.Info, error) {
+			var buffer1 bytes.Buffer
+			var buffer2 bytes.Buffer
+			stream1 := mock.NewStream(&buffer1, &buffer2)
+			stream2 := mock.NewStream(&buffer2, &buffer1)
+
+			w := protobuf.NewWriter(stream2)
+			if err := w.WriteMsg(&pb.SynAck{
+				Syn: &pb.Syn{
+					ObservedUnderlay: node1maBinary,
+				},
+				Ack: &pb.Ack{
+					Address: &pb.IfiAddress{
+						Underlay:  node2maBinary,
+						Overlay:   node2IfiAddress.Overlay.Bytes(),
+						Signature: signature,
+					},
+					NetworkID: networkID,
+				},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			return handshakeService.Handshake(context.Background(), stream1, node2AddrInfo.Addrs[0], peerID)
+		}
+
+		// the verified address is resumed on reconnect
+		for i := 0; i < 2; i++ {
+			res, err := doHandshake(node2AddrInfo.ID, node2IfiAddress.Signature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testInfo(t, *res, node2Info)
+			if got := handshakeService.Sessions(); got != 1 {
+				t.Fatalf("got %d sessions, want 1", got)
+			}
+		}
+
+		// the changed address record is verified again and invalidates the
+		// session
+		if _, err := doHandshake(node2AddrInfo.ID, node1IfiAddress.Signature); err != handshake.ErrInvalidAck {
+			t.Fatalf("expected %s, got %v", handshake.ErrInvalidAck, err)
+		}
+		if got := handshakeService.Sessions(); got != 0 {
+			t.Fatalf("got %d sessions, want 0", got)
+		}
+
+		// the number of sessions is bounded
+		node1AddrInfo, err := libp2ppeer.AddrInfoFromP2pAddr(node1ma)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, peerID := range []libp2ppeer.ID{node2AddrInfo.ID, node1AddrInfo.ID} {
+			if _, err := doHandshake(peerID, node2IfiAddress.Signature); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := handshakeService.Sessions(); got != 1 {
+			t.Fatalf("got %d sessions, want 1", got)
+		}
+	})
 }
 
 // testInfo validates if two Info instances are equal.
 func testInfo(t *testing.T, got, want handshake.Info) {
 	t.Helper()
-	if !got.IfiAddress.Equal(want.IfiAddress) || got.Light != want.Light {
+	if !got.IfiAddress.Equal(want.IfiAddress) || got.Light != want.Light || got.Capabilities != want.Capabilities {
 		t.Fatalf("got info %+v, want %+v", got, want)
 	}
 }
@@ -67,10 +67,13 @@ func (m *Syn) GetObservedUnderlay() []byte {
 }
 
 type Ack struct {
-	Address        *IfiAddress `protobuf:"bytes,1,opt,name=Address,proto3" json:"Address,omitempty"`
-	NetworkID      uint64      `protobuf:"varint,2,opt,name=NetworkID,proto3" json:"NetworkID,omitempty"`
-	Light          bool        `protobuf:"varint,3,opt,name=Light,proto3" json:"Light,omitempty"`
-	WelcomeMessage string      `protobuf:"bytes,99,opt,name=WelcomeMessage,proto3" json:"WelcomeMessage,omitempty"`
+	Address         *IfiAddress         `protobuf:"bytes,1,opt,name=Address,proto3" json:"Address,omitempty"`
+	NetworkID       uint64              `protobuf:"varint,2,opt,name=NetworkID,proto3" json:"NetworkID,omitempty"`
+	Light           bool                `protobuf:"varint,3,opt,name=Light,proto3" json:"Light,omitempty"`
+	Capabilities    uint64              `protobuf:"varint,4,opt,name=Capabilities,proto3" json:"Capabilities,omitempty"`
+	FeatureVersions map[string]string   `protobuf:"bytes,5,rep,name=FeatureVersions,proto3" json:"FeatureVersions,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Protocols       []*ProtocolVersions `protobuf:"bytes,6,rep,name=Protocols,proto3" json:"Protocols,omitempty"`
+	WelcomeMessage  string              `protobuf:"bytes,99,opt,name=WelcomeMessage,proto3" json:"WelcomeMessage,omitempty"`
 }
 
 func (m *Ack) Reset()         { *m = Ack{} }
@@ -127,6 +130,27 @@ func (m *Ack) GetLight() bool {
 	return false
 }
 
+func (m *Ack) GetCapabilities() uint64 {
+	if m != nil {
+		return m.Capabilities
+	}
+	return 0
+}
+
+func (m *Ack) GetFeatureVersions() map[string]string {
+	if m != nil {
+		return m.FeatureVersions
+	}
+	return nil
+}
+
+func (m *Ack) GetProtocols() []*ProtocolVersions {
+	if m != nil {
+		return m.Protocols
+	}
+	return nil
+}
+
 func (m *Ack) GetWelcomeMessage() string {
 	if m != nil {
 		return m.WelcomeMessage
@@ -134,6 +158,58 @@ func (m *Ack) GetWelcomeMessage() string {
 	return ""
 }
 
+type ProtocolVersions struct {
+	Name     string   `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
+	Versions []string `protobuf:"bytes,2,rep,name=Versions,proto3" json:"Versions,omitempty"`
+}
+
+func (m *ProtocolVersions) Reset()         { *m = ProtocolVersions{} }
+func (m *ProtocolVersions) String() string { return proto.CompactTextString(m) }
+func (*ProtocolVersions) ProtoMessage()    {}
+func (*ProtocolVersions) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a77305914d5d202f, []int{2}
+}
+func (m *ProtocolVersions) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ProtocolVersions) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_ProtocolVersions.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *ProtocolVersions) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProtocolVersions.Merge(m, src)
+}
+func (m *ProtocolVersions) XXX_Size() int {
+	return m.Size()
+}
+func (m *ProtocolVersions) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProtocolVersions.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProtocolVersions proto.InternalMessageInfo
+
+func (m *ProtocolVersions) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ProtocolVersions) GetVersions() []string {
+	if m != nil {
+		return m.Versions
+	}
+	return nil
+}
+
 type SynAck struct {
 	Syn *Syn `protobuf:"bytes,1,opt,name=Syn,proto3" json:"Syn,omitempty"`
 	Ack *Ack `protobuf:"bytes,2,opt,name=Ack,proto3" json:"Ack,omitempty"`
@@ -143,7 +219,7 @@ func (m *SynAck) Reset()         { *m = SynAck{} }
 func (m *SynAck) String() string { return proto.CompactTextString(m) }
 func (*SynAck) ProtoMessage()    {}
 func (*SynAck) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a77305914d5d202f, []int{2}
+	return fileDescriptor_a77305914d5d202f, []int{3}
 }
 func (m *SynAck) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -190,13 +266,14 @@ type IfiAddress struct {
 	Underlay  []byte `protobuf:"bytes,1,opt,name=Underlay,proto3" json:"Underlay,omitempty"`
 	Signature []byte `protobuf:"bytes,2,opt,name=Signature,proto3" json:"Signature,omitempty"`
 	Overlay   []byte `protobuf:"bytes,3,opt,name=Overlay,proto3" json:"Overlay,omitempty"`
+	Nonce     []byte `protobuf:"bytes,4,opt,name=Nonce,proto3" json:"Nonce,omitempty"`
 }
 
 func (m *IfiAddress) Reset()         { *m = IfiAddress{} }
 func (m *IfiAddress) String() string { return proto.CompactTextString(m) }
 func (*IfiAddress) ProtoMessage()    {}
 func (*IfiAddress) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a77305914d5d202f, []int{3}
+	return fileDescriptor_a77305914d5d202f, []int{4}
 }
 func (m *IfiAddress) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -246,9 +323,18 @@ func (m *IfiAddress) GetOverlay() []byte {
 	return nil
 }
 
+func (m *IfiAddress) GetNonce() []byte {
+	if m != nil {
+		return m.Nonce
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Syn)(nil), "handshake.Syn")
 	proto.RegisterType((*Ack)(nil), "handshake.Ack")
+	proto.RegisterMapType((map[string]string)(nil), "handshake.Ack.FeatureVersionsEntry")
+	proto.RegisterType((*ProtocolVersions)(nil), "handshake.ProtocolVersions")
 	proto.RegisterType((*SynAck)(nil), "handshake.SynAck")
 	proto.RegisterType((*IfiAddress)(nil), "handshake.IfiAddress")
 }
@@ -256,26 +342,35 @@ func init() {
 func init() { proto.RegisterFile("handshake.proto", fileDescriptor_a77305914d5d202f) }
 
 var fileDescriptor_a77305914d5d202f = []byte{
-	// 302 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x64, 0x91, 0x4d, 0x4b, 0xc3, 0x30,
-	0x1c, 0xc6, 0x97, 0x55, 0xf7, 0xf2, 0x77, 0x4c, 0x09, 0x0a, 0x45, 0x46, 0x08, 0x3d, 0x48, 0xf1,
-	0x30, 0x51, 0x3f, 0xc1, 0x86, 0x17, 0x61, 0x3a, 0x48, 0x11, 0xc1, 0x93, 0x7d, 0x09, 0xed, 0xe8,
-	0x4c, 0x47, 0x52, 0x27, 0xdd, 0xa7, 0x10, 0x3f, 0x95, 0xc7, 0x1d, 0x3d, 0x4a, 0xfb, 0x45, 0xa4,
-	0xd9, 0x4b, 0x75, 0x1e, 0x9f, 0x97, 0x36, 0xbf, 0x27, 0x81, 0xc3, 0xc8, 0x15, 0x81, 0x8a, 0xdc,
-	0x98, 0xf7, 0x67, 0x32, 0x49, 0x13, 0xdc, 0xde, 0x1a, 0xd6, 0x25, 0x18, 0x4e, 0x26, 0xf0, 0x39,
-	0x1c, 0x8d, 0x3d, 0xc5, 0xe5, 0x9c, 0x07, 0x0f, 0x22, 0xe0, 0x72, 0xea, 0x66, 0x26, 0xa2, 0xc8,
-	0xee, 0xb0, 0x7f, 0xbe, 0xf5, 0x81, 0xc0, 0x18, 0xf8, 0x31, 0xbe, 0x80, 0xe6, 0x20, 0x08, 0x24,
-	0x57, 0x4a, 0x57, 0x0f, 0xae, 0x4e, 0xfa, 0xd5, 0x41, 0xc3, 0xc5, 0x62, 0x1d, 0xb2, 0x4d, 0x0b,
-	0xf7, 0xa0, 0x7d, 0xcf, 0xd3, 0xb7, 0x44, 0xc6, 0xb7, 0x37, 0x66, 0x9d, 0x22, 0x7b, 0x8f, 0x55,
-	0x06, 0x3e, 0x86, 0xfd, 0xd1, 0x24, 0x8c, 0x52, 0xd3, 0xa0, 0xc8, 0x6e, 0xb1, 0x95, 0xc0, 0x67,
-	0xd0, 0x7d, 0xe4, 0x53, 0x3f, 0x79, 0xe1, 0x77, 0x5c, 0x29, 0x37, 0xe4, 0xa6, 0x4f, 0x91, 0xdd,
-	0x66, 0x3b, 0xae, 0x35, 0x82, 0x86, 0x93, 0x89, 0x12, 0x8b, 0xea, 0x45, 0x6b, 0xa4, 0xee, 0x2f,
-	0x24, 0x27, 0x13, 0x4c, 0x8f, 0xa5, 0x9a, 0x5f, 0x13, 0xfc, 0x6d, 0x0c, 0xfc, 0x98, 0x95, 0x91,
-	0xf5, 0x0c, 0x50, 0x0d, 0xc0, 0xa7, 0xd0, 0xda, 0xb9, 0x94, 0xad, 0x2e, 0x37, 0x39, 0x93, 0x50,
-	0xb8, 0xe9, 0xab, 0xe4, 0xfa, 0x8f, 0x1d, 0x56, 0x19, 0xd8, 0x84, 0xe6, 0x78, 0xbe, 0xfa, 0xd0,
-	0xd0, 0xd9, 0x46, 0x0e, 0x7b, 0x9f, 0x39, 0x41, 0xcb, 0x9c, 0xa0, 0xef, 0x9c, 0xa0, 0xf7, 0x82,
-	0xd4, 0x96, 0x05, 0xa9, 0x7d, 0x15, 0xa4, 0xf6, 0x54, 0x9f, 0x79, 0x5e, 0x43, 0xbf, 0xd3, 0xf5,
-	0x4f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x9d, 0x61, 0xea, 0x46, 0xba, 0x01, 0x00, 0x00,
+	// 448 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x52, 0x4d, 0x6f, 0xd3, 0x40,
+	0x10, 0xed, 0xda, 0x69, 0x5a, 0x4f, 0xa3, 0x36, 0x5a, 0x15, 0x69, 0x55, 0x2a, 0xcb, 0x32, 0x12,
+	0xb2, 0x38, 0x04, 0x51, 0x2e, 0xc0, 0xcd, 0xe1, 0x43, 0xaa, 0xd4, 0xa6, 0x68, 0x23, 0x40, 0xe2,
+	0xb6, 0xb1, 0x87, 0xc4, 0xb2, 0xbb, 0x8e, 0xbc, 0x6e, 0x90, 0xef, 0xfc, 0x00, 0x7e, 0x16, 0xc7,
+	0x1e, 0x39, 0xa2, 0xe4, 0x8f, 0xa0, 0x5d, 0x27, 0x71, 0x70, 0xb9, 0xf9, 0xbd, 0x79, 0x33, 0x3b,
+	0x7e, 0xf3, 0xe0, 0x64, 0x26, 0x64, 0xac, 0x66, 0x22, 0xc5, 0xc1, 0xbc, 0xc8, 0xcb, 0x9c, 0x3a,
+	0x5b, 0xc2, 0x7f, 0x01, 0xf6, 0xb8, 0x92, 0xf4, 0x19, 0xf4, 0x6f, 0x26, 0x0a, 0x8b, 0x05, 0xc6,
+	0x9f, 0x64, 0x8c, 0x45, 0x26, 0x2a, 0x46, 0x3c, 0x12, 0xf4, 0xf8, 0x03, 0xde, 0xff, 0x61, 0x83,
+	0x1d, 0x46, 0x29, 0x7d, 0x0e, 0x07, 0x61, 0x1c, 0x17, 0xa8, 0x94, 0x91, 0x1e, 0x5d, 0x3c, 0x1a,
+	0x34, 0x0f, 0x5d, 0x7e, 0x4b, 0xd6, 0x45, 0xbe, 0x51, 0xd1, 0x73, 0x70, 0x46, 0x58, 0x7e, 0xcf,
+	0x8b, 0xf4, 0xf2, 0x1d, 0xb3, 0x3c, 0x12, 0x74, 0x78, 0x43, 0xd0, 0x53, 0xd8, 0xbf, 0x4a, 0xa6,
+	0xb3, 0x92, 0xd9, 0x1e, 0x09, 0x0e, 0x79, 0x0d, 0xa8, 0x0f, 0xbd, 0xb7, 0x62, 0x2e, 0x26, 0x49,
+	0x96, 0x94, 0x09, 0x2a, 0xd6, 0x31, 0x6d, 0xff, 0x70, 0xf4, 0x1a, 0x4e, 0x3e, 0xa0, 0x28, 0xef,
+	0x0a, 0xfc, 0x8c, 0x85, 0x4a, 0x72, 0xa9, 0xd8, 0xbe, 0x67, 0x07, 0x47, 0x17, 0x4f, 0x76, 0x16,
+	0x0a, 0xa3, 0x74, 0xd0, 0x52, 0xbd, 0x97, 0x65, 0x51, 0xf1, 0x76, 0x2f, 0x7d, 0x0d, 0xce, 0x47,
+	0x6d, 0x53, 0x94, 0x67, 0x8a, 0x75, 0xcd, 0xa0, 0xc7, 0x3b, 0x83, 0x36, 0xb5, 0x8d, 0x9e, 0x37,
+	0x6a, 0xfa, 0x14, 0x8e, 0xbf, 0x60, 0x16, 0xe5, 0xb7, 0x78, 0x8d, 0x4a, 0x89, 0x29, 0xb2, 0xc8,
+	0x23, 0x81, 0xc3, 0x5b, 0xec, 0xd9, 0x10, 0x4e, 0xff, 0xb7, 0x0b, 0xed, 0x83, 0x9d, 0x62, 0xed,
+	0xbc, 0xc3, 0xf5, 0xa7, 0x76, 0x65, 0x21, 0xb2, 0x3b, 0x34, 0x7e, 0x39, 0xbc, 0x06, 0x6f, 0xac,
+	0x57, 0xc4, 0x1f, 0x42, 0xbf, 0xbd, 0x0a, 0xa5, 0xd0, 0x19, 0x89, 0x5b, 0x5c, 0x0f, 0x30, 0xdf,
+	0xf4, 0x0c, 0x0e, 0xb7, 0xb6, 0x58, 0x9e, 0x1d, 0x38, 0x7c, 0x8b, 0xfd, 0x2b, 0xe8, 0x8e, 0x2b,
+	0xa9, 0x8f, 0xe9, 0x99, 0x1c, 0xac, 0x0f, 0x79, 0xbc, 0xf3, 0xbb, 0xe3, 0x4a, 0x72, 0x5d, 0xd2,
+	0x8a, 0x30, 0x4a, 0x99, 0xf5, 0x40, 0x11, 0x46, 0x29, 0xd7, 0x25, 0x7f, 0x01, 0xd0, 0x9c, 0x5d,
+	0xbf, 0xdb, 0x8a, 0xd2, 0x16, 0xeb, 0x24, 0x8c, 0x93, 0xa9, 0x34, 0x0e, 0x98, 0x89, 0x3d, 0xde,
+	0x10, 0x94, 0xc1, 0xc1, 0xcd, 0xa2, 0x6e, 0xb4, 0x4d, 0x6d, 0x03, 0xb5, 0x1b, 0xa3, 0x5c, 0x46,
+	0x68, 0x62, 0xd0, 0xe3, 0x35, 0x18, 0x9e, 0xff, 0x5a, 0xba, 0xe4, 0x7e, 0xe9, 0x92, 0x3f, 0x4b,
+	0x97, 0xfc, 0x5c, 0xb9, 0x7b, 0xf7, 0x2b, 0x77, 0xef, 0xf7, 0xca, 0xdd, 0xfb, 0x6a, 0xcd, 0x27,
+	0x93, 0xae, 0xc9, 0xfc, 0xcb, 0xbf, 0x03, 0x00, 0xca, 0x62, 0x0f, 0xa5, 0x06, 0x03, 0x00, 0x00,
 }
 
 func (m *Syn) Marshal() (dAtA []byte, err error) {
@@ -337,6 +432,44 @@ func (m *Ack) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i--
 		dAtA[i] = 0x9a
 	}
+	if len(m.Protocols) > 0 {
+		for iNdEx := len(m.Protocols) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Protocols[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintHandshake(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x32
+		}
+	}
+	if len(m.FeatureVersions) > 0 {
+		for k := range m.FeatureVersions {
+			v := m.FeatureVersions[k]
+			baseI := i
+			i -= len(v)
+			copy(dAtA[i:], v)
+			i = encodeVarintHandshake(dAtA, i, uint64(len(v)))
+			i--
+			dAtA[i] = 0x12
+			i -= len(k)
+			copy(dAtA[i:], k)
+			i = encodeVarintHandshake(dAtA, i, uint64(len(k)))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintHandshake(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0x2a
+		}
+	}
+	if m.Capabilities != 0 {
+		i = encodeVarintHandshake(dAtA, i, uint64(m.Capabilities))
+		i--
+		dAtA[i] = 0x20
+	}
 	if m.Light {
 		i--
 		if m.Light {
@@ -367,6 +500,45 @@ func (m *Ack) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *ProtocolVersions) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ProtocolVersions) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ProtocolVersions) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Versions) > 0 {
+		for iNdEx := len(m.Versions) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Versions[iNdEx])
+			copy(dAtA[i:], m.Versions[iNdEx])
+			i = encodeVarintHandshake(dAtA, i, uint64(len(m.Versions[iNdEx])))
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.Name) > 0 {
+		i -= len(m.Name)
+		copy(dAtA[i:], m.Name)
+		i = encodeVarintHandshake(dAtA, i, uint64(len(m.Name)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
 func (m *SynAck) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -434,6 +606,13 @@ func (m *IfiAddress) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Nonce) > 0 {
+		i -= len(m.Nonce)
+		copy(dAtA[i:], m.Nonce)
+		i = encodeVarintHandshake(dAtA, i, uint64(len(m.Nonce)))
+		i--
+		dAtA[i] = 0x22
+	}
 	if len(m.Overlay) > 0 {
 		i -= len(m.Overlay)
 		copy(dAtA[i:], m.Overlay)
@@ -498,6 +677,23 @@ func (m *Ack) Size() (n int) {
 	if m.Light {
 		n += 2
 	}
+	if m.Capabilities != 0 {
+		n += 1 + sovHandshake(uint64(m.Capabilities))
+	}
+	if len(m.FeatureVersions) > 0 {
+		for k, v := range m.FeatureVersions {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovHandshake(uint64(len(k))) + 1 + len(v) + sovHandshake(uint64(len(v)))
+			n += mapEntrySize + 1 + sovHandshake(uint64(mapEntrySize))
+		}
+	}
+	if len(m.Protocols) > 0 {
+		for _, e := range m.Protocols {
+			l = e.Size()
+			n += 1 + l + sovHandshake(uint64(l))
+		}
+	}
 	l = len(m.WelcomeMessage)
 	if l > 0 {
 		n += 2 + l + sovHandshake(uint64(l))
@@ -505,6 +701,25 @@ func (m *Ack) Size() (n int) {
 	return n
 }
 
+func (m *ProtocolVersions) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Name)
+	if l > 0 {
+		n += 1 + l + sovHandshake(uint64(l))
+	}
+	if len(m.Versions) > 0 {
+		for _, s := range m.Versions {
+			l = len(s)
+			n += 1 + l + sovHandshake(uint64(l))
+		}
+	}
+	return n
+}
+
 func (m *SynAck) Size() (n int) {
 	if m == nil {
 		return 0
@@ -540,6 +755,10 @@ func (m *IfiAddress) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovHandshake(uint64(l))
 	}
+	l = len(m.Nonce)
+	if l > 0 {
+		n += 1 + l + sovHandshake(uint64(l))
+	}
 	return n
 }
 
@@ -740,6 +959,186 @@ func (m *Ack) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.Light = bool(v != 0)
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Capabilities", wireType)
+			}
+			m.Capabilities = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHandshake
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Capabilities |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FeatureVersions", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHandshake
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.FeatureVersions == nil {
+				m.FeatureVersions = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowHandshake
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowHandshake
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthHandshake
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthHandshake
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowHandshake
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthHandshake
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthHandshake
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipHandshake(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthHandshake
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.FeatureVersions[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Protocols", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHandshake
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Protocols = append(m.Protocols, &ProtocolVersions{})
+			if err := m.Protocols[len(m.Protocols)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		case 99:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field WelcomeMessage", wireType)
@@ -796,6 +1195,123 @@ func (m *Ack) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *ProtocolVersions) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowHandshake
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ProtocolVersions: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ProtocolVersions: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHandshake
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Versions", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHandshake
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Versions = append(m.Versions, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipHandshake(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func (m *SynAck) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
@@ -1052,6 +1568,40 @@ func (m *IfiAddress) Unmarshal(dAtA []byte) error {
 				m.Overlay = []byte{}
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Nonce", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHandshake
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHandshake
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Nonce = append(m.Nonce[:0], dAtA[iNdEx:postIndex]...)
+			if m.Nonce == nil {
+				m.Nonce = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipHandshake(dAtA[iNdEx:])
@@ -0,0 +1,145 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handshake
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/ifi"
+	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p/internal/handshake/pb"
+
+	libp2ppeer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var (
+	// sessionTTL is the time a verified peer address is resumed without
+	// the signature verification.
+	sessionTTL = 30 * time.Second
+	// maxSessions is the maximal number of the resumable sessions.
+	maxSessions = 1000
+)
+
+type sessionKey struct {
+	peerID    libp2ppeer.ID
+	networkID uint64
+}
+
+type session struct {
+	underlay  []byte
+	overlay   []byte
+	signature []byte
+	nonce     []byte
+	address   *ifi.Address
+	expires   time.Time
+}
+
+// sessionCache keeps the recently verified peer addresses, so that the
+// handshakes of the rapid reconnects skip the signature verification. The
+// session is resumed only if the peer sends exactly the same address record,
+// any change of the record is verified again.
+type sessionCache struct {
+	mu       sync.Mutex
+	sessions map[sessionKey]session
+}
+
+func newSessionCache() *sessionCache {
+	return &sessionCache{
+		sessions: make(map[sessionKey]session),
+	}
+}
+
+// get returns the verified address of the peer if the session is not expired
+// and the address record in the ack is unchanged.
+func (c *sessionCache) get(key sessionKey, a *pb.IfiAddress) (*ifi.Address, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.sessions[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(s.expires) ||
+		!bytes.Equal(s.underlay, a.Underlay) ||
+		!bytes.Equal(s.overlay, a.Overlay) ||
+		!bytes.Equal(s.signature, a.Signature) ||
+		!bytes.Equal(s.nonce, a.Nonce) {
+		delete(c.sessions, key)
+		return nil, false
+	}
+	return s.address, true
+}
+
+// put stores the verified address of the peer, evicting the expired sessions
+// and, if the cache is still full, the session closest to the expiry.
+func (c *sessionCache) put(key sessionKey, a *pb.IfiAddress, address *ifi.Address) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := c.sessions[key]; !ok && len(c.sessions) >= maxSessions {
+		var (
+			oldest    sessionKey
+			oldestExp time.Time
+		)
+		for k, s := range c.sessions {
+			if now.After(s.expires) {
+				delete(c.sessions, k)
+				continue
+			}
+			if oldestExp.IsZero() || s.expires.Before(oldestExp) {
+				oldest, oldestExp = k, s.expires
+			}
+		}
+		if len(c.sessions) >= maxSessions {
+			delete(c.sessions, oldest)
+		}
+	}
+
+	c.sessions[key] = session{
+		underlay:  a.Underlay,
+		overlay:   a.Overlay,
+		signature: a.Signature,
+		nonce:     a.Nonce,
+		address:   address,
+		expires:   now.Add(sessionTTL),
+	}
+}
+
+// remove invalidates the session of the peer.
+func (c *sessionCache) remove(key sessionKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.sessions, key)
+}
+
+func (c *sessionCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.sessions)
+}
+
+// localAddress returns the signed address of this node with the underlay,
+// reusing the signature of the previous handshake if the underlay has not
+// changed.
+func (s *Service) localAddress(underlay ma.Multiaddr) (*ifi.Address, error) {
+	s.localAddressMu.Lock()
+	defer s.localAddressMu.Unlock()
+
+	if s.localAddr != nil && s.localAddr.Underlay.Equal(underlay) {
+		return s.localAddr, nil
+	}
+
+	a, err := ifi.NewAddress(s.signer, underlay, s.overlay, s.networkID, s.nonce)
+	if err != nil {
+		return nil, err
+	}
+	s.localAddr = a
+	return a, nil
+}
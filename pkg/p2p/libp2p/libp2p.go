@@ -9,7 +9,6 @@ import (
 	"crypto/ecdsa"
 	"errors"
 	"fmt"
-	"net"
 	"sync"
 	"time"
 
@@ -22,6 +21,7 @@ import (
 	"github.com/libp2p/go-libp2p-core/peerstore"
 	protocol "github.com/libp2p/go-libp2p-core/protocol"
 	"github.com/libp2p/go-libp2p-peerstore/pstoremem"
+	"github.com/yanhuangpai/voyager"
 	"github.com/yanhuangpai/voyager/pkg/addressbook"
 	voyagercrypto "github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/ifi"
@@ -53,11 +53,12 @@ type Service struct {
 	host              host.Host
 	natManager        basichost.NATManager
 	natAddrResolver   *staticAddressResolver
+	listeners         *listenerRegistry
 	libp2pPeerstore   peerstore.Peerstore
 	metrics           metrics
 	networkID         uint64
 	handshakeService  *handshake.Service
-	addressbook       addressbook.Putter
+	addressbook       addressbook.GetPutter
 	peers             *peerRegistry
 	connectionBreaker breaker.Interface
 	blocklist         *blocklist.Blocklist
@@ -65,6 +66,11 @@ type Service struct {
 	notifier          p2p.PickyNotifier
 	logger            logging.Logger
 	tracer            *tracing.Tracer
+	scheduler         *scheduler
+	bandwidth         *bandwidthMeter
+	dialPreference    DialPreference
+	connectionEvents  *connectionEvents
+	versions          *protocolVersions
 	ready             chan struct{}
 
 	protocolsmu sync.RWMutex
@@ -77,48 +83,76 @@ type Options struct {
 	EnableQUIC     bool
 	Standalone     bool
 	LightNode      bool
+	BootnodeMode   bool
+	PssEnabled     bool
+	ChainEnabled   bool
 	WelcomeMessage string
+	PeerRateLimit  int64          // bytes per second per peer, 0 disables the limit
+	DialPreference DialPreference // address family dialed first on the peers with both IPv4 and IPv6 underlays
+
+	// AdvertisePolicy selects the underlays advertised in the handshake,
+	// the private addresses are allowed if not set.
+	AdvertisePolicy AdvertisePolicy
+
+	// ConnectionEvents is the number of the recent connection events kept
+	// for debugging, defaultConnectionEventsSize if not set.
+	ConnectionEvents int
+
+	// StreamPriorityMaxWait is the maximal time the low priority incoming
+	// streams, such as the pull syncing ones, wait for the high priority
+	// streams of the retrieval and the push syncing to be handled,
+	// defaultLowPriorityMaxWait if not set.
+	StreamPriorityMaxWait time.Duration
+	// StreamPriorityWeight is the number of the high priority incoming
+	// streams handled for every queued low priority stream, the low priority
+	// streams wait for all high priority ones if it is not set.
+	StreamPriorityWeight int
+
+	// OverlayNonce is the nonce the overlay address is derived with, which
+	// is advertised in the handshake, the legacy address is used if not set.
+	OverlayNonce []byte
+
+	// UserAgent is reported to the peers in the libp2p identify, the
+	// voyager version if not set.
+	UserAgent string
+	// NetworkName is the name of the network the node is connected to,
+	// reported to the peers in the libp2p identify after the user agent.
+	NetworkName string
 }
 
-func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, overlay infinity.Address, addr string, ab addressbook.Putter, storer storage.StateStorer, logger logging.Logger, tracer *tracing.Tracer, o Options) (*Service, error) {
-	host, port, err := net.SplitHostPort(addr)
-	if err != nil {
-		return nil, fmt.Errorf("address: %w", err)
+// capabilities returns the capabilities advertised to peers in the handshake.
+func (o Options) capabilities() (c p2p.Capabilities) {
+	if o.LightNode {
+		c |= p2p.CapabilityLightNode
 	}
-
-	ip4Addr := "0.0.0.0"
-	ip6Addr := "::"
-
-	if host != "" {
-		ip := net.ParseIP(host)
-		if ip4 := ip.To4(); ip4 != nil {
-			ip4Addr = ip4.String()
-			ip6Addr = ""
-		} else if ip6 := ip.To16(); ip6 != nil {
-			ip6Addr = ip6.String()
-			ip4Addr = ""
-		}
+	if o.BootnodeMode {
+		c |= p2p.CapabilityBootnode
+	}
+	if o.PssEnabled {
+		c |= p2p.CapabilityPss
 	}
+	if o.ChainEnabled {
+		c |= p2p.CapabilityChain
+	}
+	return c
+}
 
-	var listenAddrs []string
-	if ip4Addr != "" {
-		listenAddrs = append(listenAddrs, fmt.Sprintf("/ip4/%s/tcp/%s", ip4Addr, port))
-		if o.EnableWS {
-			listenAddrs = append(listenAddrs, fmt.Sprintf("/ip4/%s/tcp/%s/ws", ip4Addr, port))
-		}
-		if o.EnableQUIC {
-			listenAddrs = append(listenAddrs, fmt.Sprintf("/ip4/%s/udp/%s/quic", ip4Addr, port))
-		}
+// userAgent returns the user agent reported to the peers in the identify.
+func (o Options) userAgent() string {
+	ua := o.UserAgent
+	if ua == "" {
+		ua = "voyager/" + voyager.Version
 	}
+	if o.NetworkName != "" {
+		ua += " network/" + o.NetworkName
+	}
+	return ua
+}
 
-	if ip6Addr != "" {
-		listenAddrs = append(listenAddrs, fmt.Sprintf("/ip6/%s/tcp/%s", ip6Addr, port))
-		if o.EnableWS {
-			listenAddrs = append(listenAddrs, fmt.Sprintf("/ip6/%s/tcp/%s/ws", ip6Addr, port))
-		}
-		if o.EnableQUIC {
-			listenAddrs = append(listenAddrs, fmt.Sprintf("/ip6/%s/udp/%s/quic", ip6Addr, port))
-		}
+func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, overlay infinity.Address, addr string, ab addressbook.GetPutter, storer storage.StateStorer, logger logging.Logger, tracer *tracing.Tracer, o Options) (*Service, error) {
+	listenAddrs, err := listenAddresses(addr, o.EnableWS, o.EnableQUIC)
+	if err != nil {
+		return nil, err
 	}
 
 	security := libp2p.DefaultSecurity
@@ -131,6 +165,7 @@ func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, ove
 		security,
 		// Use dedicated peerstore instead the global DefaultPeerstore
 		libp2p.Peerstore(libp2pPeerstore),
+		libp2p.UserAgent(o.userAgent()),
 	}
 
 	if o.NATAddr == "" {
@@ -148,12 +183,14 @@ func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, ove
 		)
 	}
 
+	newTCPTransport := func(u *tptu.Upgrader) *tcp.TcpTransport {
+		t := tcp.NewTCPTransport(u)
+		t.DisableReuseport = true
+		return t
+	}
+
 	transports := []libp2p.Option{
-		libp2p.Transport(func(u *tptu.Upgrader) *tcp.TcpTransport {
-			t := tcp.NewTCPTransport(u)
-			t.DisableReuseport = true
-			return t
-		}),
+		libp2p.Transport(newTCPTransport),
 	}
 
 	if o.EnableWS {
@@ -168,7 +205,18 @@ func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, ove
 		opts = append(opts, libp2p.NoListenAddrs)
 	}
 
-	opts = append(opts, transports...)
+	// the host transports register their listeners so that the listen
+	// addresses can be changed at runtime, websocket is always supported
+	// to allow enabling it without a restart
+	listeners := newListenerRegistry()
+	opts = append(opts,
+		libp2p.Transport(func(u *tptu.Upgrader) *trackedTransport {
+			return listeners.transport(newTCPTransport(u))
+		}),
+		libp2p.Transport(func(u *tptu.Upgrader) *trackedTransport {
+			return listeners.transport(ws.New(u))
+		}),
+	)
 
 	h, err := libp2p.New(ctx, opts...)
 	if err != nil {
@@ -203,27 +251,42 @@ func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, ove
 		advertisableAddresser = natAddrResolver
 	}
 
-	handshakeService, err := handshake.New(signer, advertisableAddresser, overlay, networkID, o.LightNode, o.WelcomeMessage, logger)
+	if o.AdvertisePolicy == AdvertisePublicOnly {
+		advertisableAddresser = &policyAddressResolver{
+			resolver: advertisableAddresser,
+			policy:   o.AdvertisePolicy,
+			addrs:    h.Addrs,
+		}
+	}
+
+	handshakeService, err := handshake.New(signer, advertisableAddresser, overlay, o.OverlayNonce, networkID, o.capabilities(), o.WelcomeMessage, logger)
 	if err != nil {
 		return nil, fmt.Errorf("handshake service: %w", err)
 	}
 
 	peerRegistry := newPeerRegistry()
+	metrics := newMetrics()
 	s := &Service{
 		ctx:               ctx,
 		host:              h,
 		natManager:        natManager,
 		natAddrResolver:   natAddrResolver,
+		listeners:         listeners,
 		handshakeService:  handshakeService,
 		libp2pPeerstore:   libp2pPeerstore,
-		metrics:           newMetrics(),
+		metrics:           metrics,
 		networkID:         networkID,
 		peers:             peerRegistry,
 		addressbook:       ab,
 		blocklist:         blocklist.NewBlocklist(storer),
 		logger:            logger,
 		tracer:            tracer,
+		scheduler:         newScheduler(o.StreamPriorityMaxWait, o.StreamPriorityWeight),
+		bandwidth:         newBandwidthMeter(o.PeerRateLimit, metrics.ProtocolReceivedBytes, metrics.ProtocolSentBytes),
 		connectionBreaker: breaker.NewBreaker(breaker.Options{}), // use default options
+		dialPreference:    o.DialPreference,
+		connectionEvents:  newConnectionEvents(o.ConnectionEvents),
+		versions:          newProtocolVersions(),
 		ready:             make(chan struct{}),
 	}
 
@@ -244,9 +307,11 @@ func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, ove
 			return
 		}
 		peerID := stream.Conn().RemotePeer()
+		remoteAddr := stream.Conn().RemoteMultiaddr()
 		handshakeStream := NewStream(stream)
-		i, err := s.handshakeService.Handle(ctx, handshakeStream, stream.Conn().RemoteMultiaddr(), peerID)
+		i, err := s.handshakeService.Handle(ctx, handshakeStream, remoteAddr, peerID)
 		if err != nil {
+			s.connectionFailed(true, infinity.ZeroAddress, remoteAddr, fmt.Errorf("handshake: %w", err))
 			s.logger.Debugf("handshake: handle %s: %v", peerID, err)
 			s.logger.Errorf("unable to handshake with peer %v", peerID)
 			_ = handshakeStream.Reset()
@@ -256,6 +321,7 @@ func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, ove
 
 		blocked, err := s.blocklist.Exists(i.IfiAddress.Overlay)
 		if err != nil {
+			s.connectionFailed(true, i.IfiAddress.Overlay, remoteAddr, fmt.Errorf("blocklist: %w", err))
 			s.logger.Debugf("blocklisting: exists %s: %v", peerID, err)
 			s.logger.Errorf("internal error while connecting with peer %s", peerID)
 			_ = handshakeStream.Reset()
@@ -264,14 +330,18 @@ func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, ove
 		}
 
 		if blocked {
+			s.connectionFailed(true, i.IfiAddress.Overlay, remoteAddr, errPeerBlocklisted)
 			s.logger.Errorf("blocked connection from blocklisted peer %s", peerID)
 			_ = handshakeStream.Reset()
 			_ = s.host.Network().ClosePeer(peerID)
 			return
 		}
 
+		peer := newPeer(i)
+
 		if s.notifier != nil {
-			if !s.notifier.Pick(p2p.Peer{Address: i.IfiAddress.Overlay}) {
+			if !s.notifier.Pick(peer) {
+				s.connectionFailed(true, i.IfiAddress.Overlay, remoteAddr, errors.New("peer not wanted"))
 				s.logger.Errorf("don't want incoming peer %s. disconnecting", peerID)
 				_ = handshakeStream.Reset()
 				_ = s.host.Network().ClosePeer(peerID)
@@ -279,11 +349,11 @@ func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, ove
 			}
 		}
 
-		if exists := s.peers.addIfNotExists(stream.Conn(), i.IfiAddress.Overlay); exists {
+		if exists := s.peers.addIfNotExists(stream.Conn(), i.IfiAddress.Overlay, newPeerInfo(i)); exists {
 			if err = handshakeStream.FullClose(); err != nil {
 				s.logger.Debugf("handshake: could not close stream %s: %v", peerID, err)
 				s.logger.Errorf("unable to handshake with peer %v", peerID)
-				_ = s.Disconnect(i.IfiAddress.Overlay)
+				_ = s.disconnect(i.IfiAddress.Overlay, disconnectHandshake)
 			}
 			return
 		}
@@ -291,19 +361,24 @@ func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, ove
 		if err = handshakeStream.FullClose(); err != nil {
 			s.logger.Debugf("handshake: could not close stream %s: %v", peerID, err)
 			s.logger.Errorf("unable to handshake with peer %v", peerID)
-			_ = s.Disconnect(i.IfiAddress.Overlay)
+			_ = s.disconnect(i.IfiAddress.Overlay, disconnectHandshake)
 			return
 		}
 
-		err = s.addressbook.Put(i.IfiAddress.Overlay, *i.IfiAddress)
+		err = s.addressbook.Connected(i.IfiAddress.Overlay, *i.IfiAddress)
 		if err != nil {
 			s.logger.Debugf("handshake: addressbook put error %s: %v", peerID, err)
 			s.logger.Errorf("unable to persist peer %v", peerID)
-			_ = s.Disconnect(i.IfiAddress.Overlay)
+			_ = s.disconnect(i.IfiAddress.Overlay, disconnectHandshake)
 			return
 		}
 
-		peer := p2p.Peer{Address: i.IfiAddress.Overlay}
+		s.connectionEvents.add(p2p.ConnectionEvent{
+			Type:     p2p.ConnectionEventConnected,
+			Inbound:  true,
+			Overlay:  i.IfiAddress.Overlay,
+			Underlay: remoteAddr,
+		})
 
 		s.protocolsmu.RLock()
 		for _, tn := range s.protocols {
@@ -327,7 +402,7 @@ func New(ctx context.Context, signer voyagercrypto.Signer, networkID uint64, ove
 				// interface, in addition to the possibility of deciding whether
 				// a peer connection is wanted prior to adding the peer to the
 				// peer registry and starting the protocols.
-				_ = s.Disconnect(i.IfiAddress.Overlay)
+				_ = s.disconnect(i.IfiAddress.Overlay, disconnectRejected)
 				return
 			}
 		}
@@ -352,6 +427,11 @@ func (s *Service) SetPickyNotifier(n p2p.PickyNotifier) {
 }
 
 func (s *Service) AddProtocol(p p2p.ProtocolSpec) (err error) {
+	versions, err := s.versions.add(p)
+	if err != nil {
+		return fmt.Errorf("protocol version %s/%s: %w", p.Name, p.Version, err)
+	}
+
 	for _, ss := range p.StreamSpecs {
 		ss := ss
 		id := protocol.ID(p2p.NewInfinityStreamName(p.Name, p.Version, ss.Name))
@@ -370,7 +450,14 @@ func (s *Service) AddProtocol(p p2p.ProtocolSpec) (err error) {
 				return
 			}
 
+			ctx, cancel := context.WithCancel(s.ctx)
+
+			s.peers.addStream(peerID, streamlibp2p, cancel)
+			defer s.peers.removeStream(peerID, streamlibp2p)
+
 			stream := newStream(streamlibp2p)
+			stream.bandwidth = s.bandwidth.stream(ctx, overlay, p.Name)
+			stream.version = protocolIDVersion(streamlibp2p.Protocol())
 
 			// exchange headers
 			if err := handleHeaders(ss.Headler, stream); err != nil {
@@ -379,10 +466,20 @@ func (s *Service) AddProtocol(p p2p.ProtocolSpec) (err error) {
 				return
 			}
 
-			ctx, cancel := context.WithCancel(s.ctx)
-
-			s.peers.addStream(peerID, streamlibp2p, cancel)
-			defer s.peers.removeStream(peerID, streamlibp2p)
+			// enforce the deadline requested by the peer
+			if timeout, ok := stream.Headers().Deadline(); ok {
+				if timeout <= 0 {
+					s.metrics.ExpiredStreamCount.Inc()
+					s.logger.Tracef("handle protocol %s/%s: stream %s: peer %s: deadline exceeded", p.Name, p.Version, ss.Name, overlay)
+					_ = stream.Reset()
+					return
+				}
+				deadline := time.Now().Add(timeout)
+				var cancelDeadline context.CancelFunc
+				ctx, cancelDeadline = context.WithDeadline(ctx, deadline)
+				defer cancelDeadline()
+				_ = stream.SetDeadline(deadline)
+			}
 
 			// tracing: get span tracing context and add it to the context
 			// silently ignore if the peer is not providing tracing
@@ -395,8 +492,23 @@ func (s *Service) AddProtocol(p p2p.ProtocolSpec) (err error) {
 
 			logger := tracing.NewLoggerWithTraceID(ctx, s.logger)
 
+			// queue low priority streams behind high priority ones
+			start := time.Now()
+			queued, done, err := s.scheduler.admit(ctx, stream.Headers().Priority())
+			if queued {
+				s.metrics.QueuedStreamCount.Inc()
+			}
+			s.metrics.StreamQueueDuration.WithLabelValues(p.Name).Observe(time.Since(start).Seconds())
+			if err != nil {
+				logger.Debugf("handle protocol %s/%s: stream %s: peer %s: schedule: %v", p.Name, p.Version, ss.Name, overlay, err)
+				_ = stream.Reset()
+				return
+			}
+			defer done()
+
 			s.metrics.HandledStreamCount.Inc()
-			if err := ss.Handler(ctx, p2p.Peer{Address: overlay}, stream); err != nil {
+			s.logDeprecated(overlay, p.Name, p.Version)
+			if err := ss.Handler(ctx, s.peers.peer(overlay), stream); err != nil {
 				var de *p2p.DisconnectError
 				if errors.As(err, &de) {
 					_ = s.Disconnect(overlay)
@@ -420,6 +532,11 @@ func (s *Service) AddProtocol(p p2p.ProtocolSpec) (err error) {
 	s.protocolsmu.Lock()
 	s.protocols = append(s.protocols, p)
 	s.protocolsmu.Unlock()
+
+	// advertise the protocol versions to peers in the handshake, the highest
+	// one to the peers which know of a single version
+	s.handshakeService.SetFeatureVersion(p.Name, versions[0])
+	s.handshakeService.SetProtocolVersions(p.Name, versions)
 	return nil
 }
 
@@ -443,6 +560,39 @@ func (s *Service) Addresses() (addreses []ma.Multiaddr, err error) {
 	return addreses, nil
 }
 
+// ListenAddresses returns the addresses the node listens on.
+func (s *Service) ListenAddresses() []ma.Multiaddr {
+	return s.host.Network().ListenAddresses()
+}
+
+// Listen starts listening on the address in addition to the current listen
+// addresses.
+func (s *Service) Listen(addr ma.Multiaddr) error {
+	if err := s.host.Network().Listen(addr); err != nil {
+		return err
+	}
+	s.signalAddressChange()
+	return nil
+}
+
+// CloseListener stops listening on the address. The existing connections
+// are not closed.
+func (s *Service) CloseListener(addr ma.Multiaddr) error {
+	if err := s.listeners.close(addr); err != nil {
+		return err
+	}
+	s.signalAddressChange()
+	return nil
+}
+
+// signalAddressChange notifies the connected peers about the changed
+// addresses of the host.
+func (s *Service) signalAddressChange() {
+	if h, ok := s.host.(interface{ SignalAddressChange() }); ok {
+		h.SignalAddressChange()
+	}
+}
+
 func (s *Service) NATManager() basichost.NATManager {
 	return s.natManager
 }
@@ -450,12 +600,17 @@ func (s *Service) NATManager() basichost.NATManager {
 func (s *Service) Blocklist(overlay infinity.Address, duration time.Duration) error {
 	if err := s.blocklist.Add(overlay, duration); err != nil {
 		s.metrics.BlocklistedPeerErrCount.Inc()
-		_ = s.Disconnect(overlay)
+		_ = s.disconnect(overlay, disconnectBlocklisted)
 		return fmt.Errorf("blocklist peer %s: %v", overlay, err)
 	}
 	s.metrics.BlocklistedPeerCount.Inc()
+	s.connectionEvents.add(p2p.ConnectionEvent{
+		Type:    p2p.ConnectionEventBlocklisted,
+		Overlay: overlay,
+		Reason:  fmt.Sprintf("blocklisted for %s", duration),
+	})
 
-	_ = s.Disconnect(overlay)
+	_ = s.disconnect(overlay, disconnectBlocklisted)
 	return nil
 }
 
@@ -473,7 +628,24 @@ func buildUnderlayAddress(addr ma.Multiaddr, peerID libp2ppeer.ID) (ma.Multiaddr
 	return addr.Encapsulate(hostAddr), nil
 }
 
+// Connect connects to the peer on the underlay address. With a dial
+// preference the other underlay addresses known for the same peer are dialed
+// in parallel as well.
 func (s *Service) Connect(ctx context.Context, addr ma.Multiaddr) (address *ifi.Address, err error) {
+	if s.dialPreference == DialPreferenceNone {
+		return s.connect(ctx, addr)
+	}
+
+	underlays, err := s.peerUnderlays(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.dialUnderlays(ctx, infinity.ZeroAddress, underlays)
+}
+
+// connect connects to the peer on the underlay address only.
+func (s *Service) connect(ctx context.Context, addr ma.Multiaddr) (address *ifi.Address, err error) {
 	// Extract the peer ID from the multiaddr.
 	info, err := libp2ppeer.AddrInfoFromP2pAddr(addr)
 	if err != nil {
@@ -495,6 +667,26 @@ func (s *Service) Connect(ctx context.Context, addr ma.Multiaddr) (address *ifi.
 		return address, p2p.ErrAlreadyConnected
 	}
 
+	s.connectionEvents.add(p2p.ConnectionEvent{
+		Type:     p2p.ConnectionEventDial,
+		Underlay: addr,
+	})
+	defer func() {
+		if err != nil {
+			var overlay infinity.Address
+			if address != nil {
+				overlay = address.Overlay
+			}
+			s.connectionFailed(false, overlay, addr, err)
+			return
+		}
+		s.connectionEvents.add(p2p.ConnectionEvent{
+			Type:     p2p.ConnectionEventConnected,
+			Overlay:  address.Overlay,
+			Underlay: addr,
+		})
+	}()
+
 	if err := s.connectionBreaker.Execute(func() error { return s.host.Connect(ctx, *info) }); err != nil {
 		if errors.Is(err, breaker.ErrClosed) {
 			s.metrics.ConnectBreakerCount.Inc()
@@ -505,7 +697,7 @@ func (s *Service) Connect(ctx context.Context, addr ma.Multiaddr) (address *ifi.
 
 	stream, err := s.newStreamForPeerID(ctx, info.ID, handshake.ProtocolName, handshake.ProtocolVersion, handshake.StreamName)
 	if err != nil {
-		_ = s.host.Network().ClosePeer(info.ID)
+		s.closeFailedDial(info.ID)
 		return nil, fmt.Errorf("connect new stream: %w", err)
 	}
 
@@ -513,7 +705,7 @@ func (s *Service) Connect(ctx context.Context, addr ma.Multiaddr) (address *ifi.
 	i, err := s.handshakeService.Handshake(ctx, handshakeStream, stream.Conn().RemoteMultiaddr(), stream.Conn().RemotePeer())
 	if err != nil {
 		_ = handshakeStream.Reset()
-		_ = s.host.Network().ClosePeer(info.ID)
+		s.closeFailedDial(info.ID)
 		return nil, fmt.Errorf("handshake: %w", err)
 	}
 
@@ -522,20 +714,20 @@ func (s *Service) Connect(ctx context.Context, addr ma.Multiaddr) (address *ifi.
 		s.logger.Debugf("blocklisting: exists %s: %v", info.ID, err)
 		s.logger.Errorf("internal error while connecting with peer %s", info.ID)
 		_ = handshakeStream.Reset()
-		_ = s.host.Network().ClosePeer(info.ID)
-		return nil, fmt.Errorf("peer blocklisted")
+		s.closeFailedDial(info.ID)
+		return nil, errPeerBlocklisted
 	}
 
 	if blocked {
 		s.logger.Errorf("blocked connection from blocklisted peer %s", info.ID)
 		_ = handshakeStream.Reset()
-		_ = s.host.Network().ClosePeer(info.ID)
-		return nil, fmt.Errorf("peer blocklisted")
+		s.closeFailedDial(info.ID)
+		return nil, errPeerBlocklisted
 	}
 
-	if exists := s.peers.addIfNotExists(stream.Conn(), i.IfiAddress.Overlay); exists {
+	if exists := s.peers.addIfNotExists(stream.Conn(), i.IfiAddress.Overlay, newPeerInfo(i)); exists {
 		if err := handshakeStream.FullClose(); err != nil {
-			_ = s.Disconnect(i.IfiAddress.Overlay)
+			_ = s.disconnect(i.IfiAddress.Overlay, disconnectHandshake)
 			return nil, fmt.Errorf("peer exists, full close: %w", err)
 		}
 
@@ -543,20 +735,20 @@ func (s *Service) Connect(ctx context.Context, addr ma.Multiaddr) (address *ifi.
 	}
 
 	if err := handshakeStream.FullClose(); err != nil {
-		_ = s.Disconnect(i.IfiAddress.Overlay)
+		_ = s.disconnect(i.IfiAddress.Overlay, disconnectHandshake)
 		return nil, fmt.Errorf("connect full close %w", err)
 	}
 
-	err = s.addressbook.Put(i.IfiAddress.Overlay, *i.IfiAddress)
+	err = s.addressbook.Connected(i.IfiAddress.Overlay, *i.IfiAddress)
 	if err != nil {
-		_ = s.Disconnect(i.IfiAddress.Overlay)
+		_ = s.disconnect(i.IfiAddress.Overlay, disconnectHandshake)
 		return nil, fmt.Errorf("storing ifi address: %w", err)
 	}
 
 	s.protocolsmu.RLock()
 	for _, tn := range s.protocols {
 		if tn.ConnectOut != nil {
-			if err := tn.ConnectOut(ctx, p2p.Peer{Address: i.IfiAddress.Overlay}); err != nil {
+			if err := tn.ConnectOut(ctx, newPeer(i)); err != nil {
 				s.logger.Debugf("connectOut: protocol: %s, version:%s, peer: %s: %v", tn.Name, tn.Version, i.IfiAddress.Overlay, err)
 			}
 		}
@@ -571,13 +763,25 @@ func (s *Service) Connect(ctx context.Context, addr ma.Multiaddr) (address *ifi.
 }
 
 func (s *Service) Disconnect(overlay infinity.Address) error {
+	return s.disconnect(overlay, disconnectLocal)
+}
+
+// disconnect disconnects the peer and records the reason in the connection
+// events.
+func (s *Service) disconnect(overlay infinity.Address, reason string) error {
 	s.metrics.DisconnectCount.Inc()
 	found, peerID := s.peers.remove(overlay)
 	if !found {
 		return p2p.ErrPeerNotFound
 	}
+	s.connectionEvents.add(p2p.ConnectionEvent{
+		Type:    p2p.ConnectionEventDisconnected,
+		Overlay: overlay,
+		Reason:  reason,
+	})
 
 	_ = s.host.Network().ClosePeer(peerID)
+	s.bandwidth.remove(overlay)
 
 	peer := p2p.Peer{Address: overlay}
 
@@ -600,6 +804,13 @@ func (s *Service) Disconnect(overlay infinity.Address) error {
 
 // disconnected is a registered peer registry event
 func (s *Service) disconnected(address infinity.Address) {
+	s.bandwidth.remove(address)
+	s.connectionEvents.add(p2p.ConnectionEvent{
+		Type:    p2p.ConnectionEventDisconnected,
+		Overlay: address,
+		Reason:  disconnectRemote,
+	})
+
 	peer := p2p.Peer{Address: address}
 	s.protocolsmu.RLock()
 	for _, tn := range s.protocols {
@@ -621,6 +832,21 @@ func (s *Service) Peers() []p2p.Peer {
 	return s.peers.peers()
 }
 
+// UserAgent returns the user agent reported by the connected peer in the
+// identify, empty if the peer is not connected or not identified yet.
+func (s *Service) UserAgent(overlay infinity.Address) string {
+	peerID, found := s.peers.peerID(overlay)
+	if !found {
+		return ""
+	}
+	v, err := s.libp2pPeerstore.Get(peerID, "AgentVersion")
+	if err != nil {
+		return ""
+	}
+	ua, _ := v.(string)
+	return ua
+}
+
 func (s *Service) BlocklistedPeers() ([]p2p.Peer, error) {
 	return s.blocklist.Peers()
 }
@@ -631,12 +857,30 @@ func (s *Service) NewStream(ctx context.Context, overlay infinity.Address, heade
 		return nil, p2p.ErrPeerNotFound
 	}
 
-	streamlibp2p, err := s.newStreamForPeerID(ctx, peerID, protocolName, protocolVersion, streamName)
+	protocolVersion, fallback := s.negotiateVersion(overlay, protocolName, protocolVersion)
+	streamlibp2p, err := s.newStreamForPeerID(ctx, peerID, protocolName, protocolVersion, streamName, fallback...)
 	if err != nil {
-		return nil, fmt.Errorf("new stream for peerid: %w", err)
+		if !s.connectionLost(ctx, peerID, err) {
+			return nil, fmt.Errorf("new stream for peerid: %w", err)
+		}
+		if rerr := s.reconnectPeer(ctx, overlay); rerr != nil {
+			s.logger.Debugf("new stream: reconnect to peer %s: %v", overlay, rerr)
+			return nil, fmt.Errorf("new stream for peerid: %w", err)
+		}
+		if peerID, found = s.peers.peerID(overlay); !found {
+			return nil, p2p.ErrPeerNotFound
+		}
+		streamlibp2p, err = s.newStreamForPeerID(ctx, peerID, protocolName, protocolVersion, streamName, fallback...)
+		if err != nil {
+			return nil, fmt.Errorf("new stream for peerid: %w", err)
+		}
+		s.metrics.ReconnectedStreamCount.Inc()
 	}
 
 	stream := newStream(streamlibp2p)
+	stream.bandwidth = s.bandwidth.stream(ctx, overlay, protocolName)
+	stream.version = protocolIDVersion(streamlibp2p.Protocol())
+	s.logDeprecated(overlay, protocolName, stream.version)
 
 	// tracing: add span context header
 	if headers == nil {
@@ -655,9 +899,85 @@ func (s *Service) NewStream(ctx context.Context, overlay infinity.Address, heade
 	return stream, nil
 }
 
-func (s *Service) newStreamForPeerID(ctx context.Context, peerID libp2ppeer.ID, protocolName, protocolVersion, streamName string) (network.Stream, error) {
+// connectionLost returns true if the stream to the peer could not be created
+// because there is no live connection to it anymore.
+func (s *Service) connectionLost(ctx context.Context, peerID libp2ppeer.ID, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var errIncompatible *p2p.IncompatibleStreamError
+	if errors.As(err, &errIncompatible) {
+		return false
+	}
+	return s.host.Network().Connectedness(peerID) != network.Connected
+}
+
+// reconnectPeer connects to the peer with the lost connection through the
+// notifier if it manages the connections, so that its connection backoff is
+// respected, or directly otherwise.
+func (s *Service) reconnectPeer(ctx context.Context, overlay infinity.Address) error {
+	if r, ok := s.notifier.(p2p.Reconnecter); ok {
+		return r.Reconnect(ctx, overlay)
+	}
+	return s.reconnect(ctx, overlay)
+}
+
+// reconnect connects to the peer with the overlay address by dialing all known
+// underlay addresses of the peer until one succeeds.
+func (s *Service) reconnect(ctx context.Context, overlay infinity.Address) error {
+	underlays, err := s.underlays(overlay)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.dialUnderlays(ctx, overlay, underlays); err != nil && !errors.Is(err, p2p.ErrAlreadyConnected) {
+		return err
+	}
+	return nil
+}
+
+// underlays returns the underlay address of the peer stored in the
+// addressbook followed by other underlay addresses known to the peerstore
+// for the same peer.
+func (s *Service) underlays(overlay infinity.Address) ([]ma.Multiaddr, error) {
+	ifiAddr, err := s.addressbook.Get(overlay)
+	if err != nil {
+		return nil, fmt.Errorf("addressbook: %w", err)
+	}
+
+	return s.peerUnderlays(ifiAddr.Underlay)
+}
+
+// peerUnderlays returns the underlay address followed by other underlay
+// addresses known to the peerstore for the same peer.
+func (s *Service) peerUnderlays(addr ma.Multiaddr) ([]ma.Multiaddr, error) {
+	info, err := libp2ppeer.AddrInfoFromP2pAddr(addr)
+	if err != nil {
+		return nil, fmt.Errorf("addr from p2p: %w", err)
+	}
+
+	underlays := []ma.Multiaddr{addr}
+	for _, a := range s.host.Peerstore().Addrs(info.ID) {
+		underlay, err := buildUnderlayAddress(a, info.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !underlay.Equal(addr) {
+			underlays = append(underlays, underlay)
+		}
+	}
+	return underlays, nil
+}
+
+// newStreamForPeerID opens the stream on the protocol version, or on the first
+// of the fallback versions the peer supports if it does not support it.
+func (s *Service) newStreamForPeerID(ctx context.Context, peerID libp2ppeer.ID, protocolName, protocolVersion, streamName string, fallbackVersions ...string) (network.Stream, error) {
 	infinityStreamName := p2p.NewInfinityStreamName(protocolName, protocolVersion, streamName)
-	st, err := s.host.NewStream(ctx, peerID, protocol.ID(infinityStreamName))
+	pids := []protocol.ID{protocol.ID(infinityStreamName)}
+	for _, v := range fallbackVersions {
+		pids = append(pids, protocol.ID(p2p.NewInfinityStreamName(protocolName, v, streamName)))
+	}
+	st, err := s.host.NewStream(ctx, peerID, pids...)
 	if err != nil {
 		if st != nil {
 			s.logger.Debug("stream experienced unexpected early close")
@@ -684,11 +1004,34 @@ func (s *Service) SetWelcomeMessage(val string) error {
 	return s.handshakeService.SetWelcomeMessage(val)
 }
 
+// Bandwidth returns the number of bytes transferred over streams per
+// connected peer and per protocol.
+func (s *Service) Bandwidth() p2p.Bandwidth {
+	return s.bandwidth.bandwidth()
+}
+
 // GetWelcomeMessage returns the value of the welcome message.
 func (s *Service) GetWelcomeMessage() string {
 	return s.handshakeService.GetWelcomeMessage()
 }
 
+func newPeerInfo(i *handshake.Info) peerInfo {
+	return peerInfo{
+		capabilities:     i.Capabilities,
+		featureVersions:  i.FeatureVersions,
+		protocolVersions: i.ProtocolVersions,
+	}
+}
+
+func newPeer(i *handshake.Info) p2p.Peer {
+	return p2p.Peer{
+		Address:          i.IfiAddress.Overlay,
+		Capabilities:     i.Capabilities,
+		FeatureVersions:  i.FeatureVersions,
+		ProtocolVersions: i.ProtocolVersions,
+	}
+}
+
 func (s *Service) Ready() {
 	close(s.ready)
 }
@@ -28,6 +28,7 @@ type libp2pServiceOpts struct {
 	Addressbook addressbook.Interface
 	PrivateKey  *ecdsa.PrivateKey
 	libp2pOpts  libp2p.Options
+	listenAddr  string
 }
 
 // newService constructs a new libp2p service.
@@ -39,12 +40,15 @@ func newService(t *testing.T, networkID uint64, o libp2pServiceOpts) (s *libp2p.
 		t.Fatal(err)
 	}
 
-	overlay, err = crypto.NewOverlayAddress(infinityKey.PublicKey, networkID)
+	overlay, err = crypto.NewOverlayAddress(infinityKey.PublicKey, networkID, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	addr := ":0"
+	if o.listenAddr != "" {
+		addr = o.listenAddr
+	}
 
 	if o.Logger == nil {
 		o.Logger = logging.New(ioutil.Discard, 0)
@@ -0,0 +1,80 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p
+
+import (
+	"sync"
+
+	"github.com/libp2p/go-libp2p-core/transport"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+)
+
+// listenerRegistry keeps the listeners of the host transports, so that they
+// can be closed at runtime, which the swarm does not provide.
+type listenerRegistry struct {
+	mu        sync.Mutex
+	listeners map[*trackedListener]struct{}
+}
+
+func newListenerRegistry() *listenerRegistry {
+	return &listenerRegistry{
+		listeners: make(map[*trackedListener]struct{}),
+	}
+}
+
+// transport returns the transport which registers its listeners.
+func (r *listenerRegistry) transport(t transport.Transport) *trackedTransport {
+	return &trackedTransport{Transport: t, registry: r}
+}
+
+// close closes the listener on the address. The swarm removes the listener
+// once its accept loop ends.
+func (r *listenerRegistry) close(addr ma.Multiaddr) error {
+	r.mu.Lock()
+	var l *trackedListener
+	for tl := range r.listeners {
+		if tl.Multiaddr().Equal(addr) {
+			l = tl
+			break
+		}
+	}
+	r.mu.Unlock()
+
+	if l == nil {
+		return p2p.ErrListenerNotFound
+	}
+	return l.Close()
+}
+
+type trackedTransport struct {
+	transport.Transport
+	registry *listenerRegistry
+}
+
+func (t *trackedTransport) Listen(addr ma.Multiaddr) (transport.Listener, error) {
+	l, err := t.Transport.Listen(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tl := &trackedListener{Listener: l, registry: t.registry}
+	t.registry.mu.Lock()
+	t.registry.listeners[tl] = struct{}{}
+	t.registry.mu.Unlock()
+	return tl, nil
+}
+
+type trackedListener struct {
+	transport.Listener
+	registry *listenerRegistry
+}
+
+func (l *trackedListener) Close() error {
+	l.registry.mu.Lock()
+	delete(l.registry.listeners, l)
+	l.registry.mu.Unlock()
+	return l.Listener.Close()
+}
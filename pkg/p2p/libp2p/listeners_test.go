@@ -0,0 +1,101 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p"
+)
+
+func TestListen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s1, overlay1 := newService(t, 1, libp2pServiceOpts{})
+	s2, overlay2 := newService(t, 1, libp2pServiceOpts{})
+
+	if err := s1.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/ws")); err != nil {
+		t.Fatal(err)
+	}
+
+	var listenAddr ma.Multiaddr
+	for _, a := range s1.ListenAddresses() {
+		if strings.HasSuffix(a.String(), "/ws") {
+			listenAddr = a
+		}
+	}
+	if listenAddr == nil {
+		t.Fatal("websocket listen address not found")
+	}
+
+	addr := serviceWSUnderlayAddress(t, s1)
+	if _, err := s2.Connect(ctx, addr); err != nil {
+		t.Fatal(err)
+	}
+	expectPeers(t, s2, overlay1)
+	expectPeersEventually(t, s1, overlay2)
+
+	if err := s1.CloseListener(listenAddr); err != nil {
+		t.Fatal(err)
+	}
+	waitListenAddressRemoved(t, s1, listenAddr)
+
+	s3, _ := newService(t, 1, libp2pServiceOpts{})
+	if _, err := s3.Connect(ctx, addr); err == nil {
+		t.Fatal("connected to a closed listener")
+	}
+
+	// existing connections are kept
+	expectPeers(t, s2, overlay1)
+
+	if err := s1.CloseListener(listenAddr); !errors.Is(err, p2p.ErrListenerNotFound) {
+		t.Fatalf("got error %v, want %v", err, p2p.ErrListenerNotFound)
+	}
+}
+
+// serviceWSUnderlayAddress returns the websocket underlay address of the
+// service.
+func serviceWSUnderlayAddress(t *testing.T, s *libp2p.Service) ma.Multiaddr {
+	t.Helper()
+
+	addrs, err := s.Addresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range addrs {
+		if strings.Contains(a.String(), "/ws/") {
+			return a
+		}
+	}
+	t.Fatal("websocket underlay address not found")
+	return nil
+}
+
+// waitListenAddressRemoved waits until the service stops listening on the
+// address.
+func waitListenAddressRemoved(t *testing.T, s *libp2p.Service, addr ma.Multiaddr) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		found := false
+		for _, a := range s.ListenAddresses() {
+			if a.Equal(addr) {
+				found = true
+			}
+		}
+		if !found {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("still listening on %s", addr)
+}
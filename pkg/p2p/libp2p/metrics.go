@@ -21,6 +21,13 @@ type metrics struct {
 	BlocklistedPeerErrCount prometheus.Counter
 	DisconnectCount         prometheus.Counter
 	ConnectBreakerCount     prometheus.Counter
+	ExpiredStreamCount      prometheus.Counter
+	QueuedStreamCount       prometheus.Counter
+	ReconnectedStreamCount  prometheus.Counter
+	ProtocolReceivedBytes   *prometheus.CounterVec
+	ProtocolSentBytes       *prometheus.CounterVec
+	StreamQueueDuration     *prometheus.HistogramVec
+	DeprecatedStreamCount   *prometheus.CounterVec
 }
 
 func newMetrics() metrics {
@@ -75,6 +82,49 @@ func newMetrics() metrics {
 			Name:      "connect_breaker_count",
 			Help:      "Number of times we got a closed breaker while connecting to another peer.",
 		}),
+		ExpiredStreamCount: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "expired_stream_count",
+			Help:      "Number of incoming streams reset because their deadline has passed.",
+		}),
+		QueuedStreamCount: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "queued_stream_count",
+			Help:      "Number of low priority incoming streams queued behind high priority ones.",
+		}),
+		ReconnectedStreamCount: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "reconnected_stream_count",
+			Help:      "Number of outgoing streams created after reconnecting to a peer with a lost connection.",
+		}),
+		ProtocolReceivedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "protocol_received_bytes",
+			Help:      "Number of bytes received over streams per protocol.",
+		}, []string{"protocol"}),
+		ProtocolSentBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "protocol_sent_bytes",
+			Help:      "Number of bytes sent over streams per protocol.",
+		}, []string{"protocol"}),
+		StreamQueueDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "stream_queue_duration_seconds",
+			Help:      "Time the incoming streams wait for the higher priority streams before they are handled per protocol.",
+			Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+		}, []string{"protocol"}),
+		DeprecatedStreamCount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "deprecated_stream_count",
+			Help:      "Number of the streams on the deprecated protocol versions per protocol and version.",
+		}, []string{"protocol", "version"}),
 	}
 }
 
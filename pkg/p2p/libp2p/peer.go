@@ -20,6 +20,7 @@ import (
 type peerRegistry struct {
 	underlays   map[string]libp2ppeer.ID                    // map overlay address to underlay peer id
 	overlays    map[libp2ppeer.ID]infinity.Address          // map underlay peer id to overlay address
+	infos       map[libp2ppeer.ID]peerInfo                  // map underlay peer id to information received in the handshake
 	connections map[libp2ppeer.ID]map[network.Conn]struct{} // list of connections for safe removal on Disconnect notification
 	streams     map[libp2ppeer.ID]map[network.Stream]context.CancelFunc
 	mu          sync.RWMutex
@@ -29,6 +30,13 @@ type peerRegistry struct {
 	network.Notifiee              // peerRegistry can be the receiver for network.Notify
 }
 
+// peerInfo holds the peer information exchanged in the handshake.
+type peerInfo struct {
+	capabilities     p2p.Capabilities
+	featureVersions  map[string]string
+	protocolVersions map[string][]string
+}
+
 type disconnecter interface {
 	disconnected(infinity.Address)
 }
@@ -37,6 +45,7 @@ func newPeerRegistry() *peerRegistry {
 	return &peerRegistry{
 		underlays:   make(map[string]libp2ppeer.ID),
 		overlays:    make(map[libp2ppeer.ID]infinity.Address),
+		infos:       make(map[libp2ppeer.ID]peerInfo),
 		connections: make(map[libp2ppeer.ID]map[network.Conn]struct{}),
 		streams:     make(map[libp2ppeer.ID]map[network.Stream]context.CancelFunc),
 
@@ -73,6 +82,7 @@ func (r *peerRegistry) Disconnected(_ network.Network, c network.Conn) {
 	delete(r.connections, peerID)
 	overlay := r.overlays[peerID]
 	delete(r.overlays, peerID)
+	delete(r.infos, peerID)
 	delete(r.underlays, overlay.ByteString())
 	for _, cancel := range r.streams[peerID] {
 		cancel()
@@ -115,10 +125,8 @@ func (r *peerRegistry) removeStream(peerID libp2ppeer.ID, stream network.Stream)
 func (r *peerRegistry) peers() []p2p.Peer {
 	r.mu.RLock()
 	peers := make([]p2p.Peer, 0, len(r.overlays))
-	for _, a := range r.overlays {
-		peers = append(peers, p2p.Peer{
-			Address: a,
-		})
+	for peerID, a := range r.overlays {
+		peers = append(peers, r.newPeer(peerID, a))
 	}
 	r.mu.RUnlock()
 	sort.Slice(peers, func(i, j int) bool {
@@ -127,7 +135,28 @@ func (r *peerRegistry) peers() []p2p.Peer {
 	return peers
 }
 
-func (r *peerRegistry) addIfNotExists(c network.Conn, overlay infinity.Address) (exists bool) {
+// peer returns the p2p.Peer with the handshake information for the given
+// overlay address.
+func (r *peerRegistry) peer(overlay infinity.Address) p2p.Peer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.newPeer(r.underlays[overlay.ByteString()], overlay)
+}
+
+// newPeer constructs p2p.Peer from the stored handshake information.
+// It must be called with the read lock held.
+func (r *peerRegistry) newPeer(peerID libp2ppeer.ID, overlay infinity.Address) p2p.Peer {
+	i := r.infos[peerID]
+	return p2p.Peer{
+		Address:          overlay,
+		Capabilities:     i.capabilities,
+		FeatureVersions:  i.featureVersions,
+		ProtocolVersions: i.protocolVersions,
+	}
+}
+
+func (r *peerRegistry) addIfNotExists(c network.Conn, overlay infinity.Address, info peerInfo) (exists bool) {
 	peerID := c.RemotePeer()
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -146,6 +175,7 @@ func (r *peerRegistry) addIfNotExists(c network.Conn, overlay infinity.Address)
 	r.streams[peerID] = make(map[network.Stream]context.CancelFunc)
 	r.underlays[overlay.ByteString()] = peerID
 	r.overlays[peerID] = overlay
+	r.infos[peerID] = info
 	return false
 
 }
@@ -197,6 +227,7 @@ func (r *peerRegistry) remove(overlay infinity.Address) (bool, libp2ppeer.ID) {
 	r.mu.Lock()
 	peerID, found := r.underlays[overlay.ByteString()]
 	delete(r.overlays, peerID)
+	delete(r.infos, peerID)
 	delete(r.underlays, overlay.ByteString())
 	delete(r.connections, peerID)
 	for _, cancel := range r.streams[peerID] {
@@ -13,7 +13,9 @@ import (
 	"time"
 
 	"github.com/multiformats/go-multistream"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p"
 )
 
 func TestNewStream(t *testing.T) {
@@ -187,6 +189,122 @@ func TestNewStream_semanticVersioning(t *testing.T) {
 	}
 }
 
+func TestNewStream_versionNegotiation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	handled := make(map[string]string) // handler version to the stream version
+	versionedProtocol := func(version string, deprecated bool) p2p.ProtocolSpec {
+		return p2p.ProtocolSpec{
+			Name:       testProtocolName,
+			Version:    version,
+			Deprecated: deprecated,
+			StreamSpecs: []p2p.StreamSpec{
+				{
+					Name: testStreamName,
+					Handler: func(_ context.Context, _ p2p.Peer, s p2p.Stream) error {
+						mu.Lock()
+						handled[version] = p2p.StreamVersion(s, "")
+						mu.Unlock()
+						return s.FullClose()
+					},
+				},
+			},
+		}
+	}
+	expectHandled := func(version, streamVersion string) {
+		t.Helper()
+		mu.Lock()
+		defer mu.Unlock()
+		if got, ok := handled[version]; !ok || got != streamVersion {
+			t.Fatalf("got handled %v, want version %s handling stream version %s", handled, version, streamVersion)
+		}
+		for k := range handled {
+			delete(handled, k)
+		}
+	}
+	newStream := func(s *libp2p.Service, overlay infinity.Address, version, want string) {
+		t.Helper()
+		stream, err := s.NewStream(ctx, overlay, nil, testProtocolName, version, testStreamName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p2p.StreamVersion(stream, version); got != want {
+			t.Fatalf("got stream version %s, want %s", got, want)
+		}
+		if err := stream.FullClose(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the old node supports only the deprecated version
+	s1, overlay1 := newService(t, 1, libp2pServiceOpts{})
+	if err := s1.AddProtocol(versionedProtocol("1.0.0", false)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the newer versions are added first to check that the streams on the
+	// older ones are still handled by their handlers
+	s2, overlay2 := newService(t, 1, libp2pServiceOpts{})
+	for _, p := range []p2p.ProtocolSpec{
+		versionedProtocol("2.0.0", false),
+		versionedProtocol("1.1.0", false),
+		versionedProtocol("1.0.0", true),
+	} {
+		if err := s2.AddProtocol(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s3, _ := newService(t, 1, libp2pServiceOpts{})
+	for _, p := range []p2p.ProtocolSpec{
+		versionedProtocol("1.1.0", false),
+		versionedProtocol("2.0.0", false),
+	} {
+		if err := s3.AddProtocol(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s2.Connect(ctx, serviceUnderlayAddress(t, s1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s3.Connect(ctx, serviceUnderlayAddress(t, s2)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the highest version supported by the old node is selected
+	newStream(s2, overlay1, "2.0.0", "1.0.0")
+	expectHandled("1.0.0", "1.0.0")
+
+	// the old node opens the streams on the only version it has
+	newStream(s1, overlay2, "1.0.0", "1.0.0")
+	expectHandled("1.0.0", "1.0.0")
+
+	// the highest mutual version is selected
+	newStream(s3, overlay2, "2.0.0", "2.0.0")
+	expectHandled("2.0.0", "2.0.0")
+
+	// not higher than the requested version
+	newStream(s3, overlay2, "1.1.0", "1.1.0")
+	expectHandled("1.1.0", "1.1.0")
+
+	// the node on an older release does not advertise the versions in the
+	// handshake, as its protocols are added after the connection here
+	s4, overlay4 := newService(t, 1, libp2pServiceOpts{})
+	if _, err := s2.Connect(ctx, serviceUnderlayAddress(t, s4)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s4.AddProtocol(versionedProtocol("1.0.0", false)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lower versions are offered to it as the fallback
+	newStream(s2, overlay4, "2.0.0", "1.0.0")
+	expectHandled("1.0.0", "1.0.0")
+}
+
 func TestDisconnectError(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -0,0 +1,135 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+)
+
+// defaultLowPriorityMaxWait is the maximal duration that a low priority
+// stream handler waits for high priority handlers to finish, if it is not
+// configured, so that low priority streams are not starved by constant high
+// priority traffic.
+const defaultLowPriorityMaxWait = 2 * time.Second
+
+// scheduler admits incoming stream handlers based on their priority. High
+// priority handlers are run immediately, while low priority ones are queued
+// until there are no high priority handlers in flight. If the weight is set,
+// a queued low priority handler is admitted after every weight high priority
+// handlers, so that the low priority streams keep a share of the handlers
+// under constant high priority traffic. A turn is only given while low
+// priority handlers are queued and it is taken back when the last of them
+// leaves the queue without it, so that it is not used by a later handler.
+type scheduler struct {
+	high     int           // number of high priority handlers in flight
+	idle     chan struct{} // closed when there are no high priority handlers
+	turns    chan struct{} // receives the turns of the queued low priority handlers
+	queued   int           // number of queued low priority handlers
+	admitted int           // high priority handlers admitted since the last turn
+	weight   int
+	maxWait  time.Duration
+	mu       sync.Mutex
+}
+
+func newScheduler(maxWait time.Duration, weight int) *scheduler {
+	if maxWait <= 0 {
+		maxWait = defaultLowPriorityMaxWait
+	}
+	idle := make(chan struct{})
+	close(idle)
+	return &scheduler{
+		idle:    idle,
+		turns:   make(chan struct{}, 1),
+		weight:  weight,
+		maxWait: maxWait,
+	}
+}
+
+// admit blocks until the handler with the provided priority can be run. It
+// returns true if the handler has been queued and a function that must be
+// called when the handler is done.
+func (s *scheduler) admit(ctx context.Context, p p2p.Priority) (queued bool, done func(), err error) {
+	switch p {
+	case p2p.PriorityHigh:
+		s.mu.Lock()
+		if s.high == 0 {
+			s.idle = make(chan struct{})
+		}
+		s.high++
+		if s.weight > 0 {
+			s.admitted++
+			if s.admitted >= s.weight {
+				s.admitted = 0
+				if s.queued > 0 {
+					select {
+					case s.turns <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}
+		s.mu.Unlock()
+
+		return false, func() {
+			s.mu.Lock()
+			s.high--
+			if s.high == 0 {
+				close(s.idle)
+			}
+			s.mu.Unlock()
+		}, nil
+	case p2p.PriorityLow:
+		s.mu.Lock()
+		idle := s.idle
+		select {
+		case <-idle:
+			s.mu.Unlock()
+			return false, func() {}, nil
+		default:
+		}
+		s.queued++
+		s.mu.Unlock()
+
+		timer := time.NewTimer(s.maxWait)
+		defer timer.Stop()
+
+		select {
+		case <-s.turns:
+			s.mu.Lock()
+			s.queued--
+			s.mu.Unlock()
+		case <-idle:
+			s.leave()
+		case <-timer.C:
+			s.leave()
+		case <-ctx.Done():
+			s.leave()
+			return true, nil, ctx.Err()
+		}
+		return true, func() {}, nil
+	default:
+		return false, func() {}, nil
+	}
+}
+
+// leave removes a low priority handler from the queue that has not taken a
+// turn. The turn given while it was queued is left to the other queued
+// handlers, or taken back if there are none.
+func (s *scheduler) leave() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queued--
+	if s.queued == 0 {
+		select {
+		case <-s.turns:
+		default:
+		}
+	}
+}
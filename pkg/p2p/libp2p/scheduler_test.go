@@ -0,0 +1,186 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p"
+)
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	s := libp2p.NewScheduler(time.Minute, 0)
+
+	// low priority is not queued if there are no high priority handlers
+	queued, done, err := s.Admit(ctx, p2p.PriorityLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued {
+		t.Fatal("low priority queued")
+	}
+	done()
+
+	_, doneHigh, err := s.Admit(ctx, p2p.PriorityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// normal priority is never queued
+	queued, done, err = s.Admit(ctx, p2p.PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued {
+		t.Fatal("normal priority queued")
+	}
+	done()
+
+	admitted := make(chan bool)
+	go func() {
+		queued, done, err := s.Admit(ctx, p2p.PriorityLow)
+		if err != nil {
+			t.Error(err)
+		}
+		done()
+		admitted <- queued
+	}()
+
+	select {
+	case <-admitted:
+		t.Fatal("low priority admitted while high priority is in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	doneHigh()
+
+	select {
+	case queued := <-admitted:
+		if !queued {
+			t.Error("low priority not queued")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("low priority not admitted")
+	}
+}
+
+func TestScheduler_maxWait(t *testing.T) {
+	ctx := context.Background()
+	s := libp2p.NewScheduler(50*time.Millisecond, 0)
+
+	_, doneHigh, err := s.Admit(ctx, p2p.PriorityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doneHigh()
+
+	queued, done, err := s.Admit(ctx, p2p.PriorityLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !queued {
+		t.Fatal("low priority not queued")
+	}
+	done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	s = libp2p.NewScheduler(time.Minute, 0)
+	_, doneHigh, err = s.Admit(context.Background(), p2p.PriorityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doneHigh()
+
+	if _, _, err := s.Admit(ctx, p2p.PriorityLow); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestScheduler_weight(t *testing.T) {
+	ctx := context.Background()
+	s := libp2p.NewScheduler(time.Minute, 2)
+
+	_, doneHigh, err := s.Admit(ctx, p2p.PriorityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doneHigh()
+
+	admitted := make(chan bool)
+	go func() {
+		queued, done, err := s.Admit(ctx, p2p.PriorityLow)
+		if err != nil {
+			t.Error(err)
+		}
+		done()
+		admitted <- queued
+	}()
+
+	select {
+	case <-admitted:
+		t.Fatal("low priority admitted before its turn")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the second high priority handler gives the turn to the low priority one
+	_, doneHigh2, err := s.Admit(ctx, p2p.PriorityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doneHigh2()
+
+	select {
+	case queued := <-admitted:
+		if !queued {
+			t.Error("low priority not queued")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("low priority not admitted on its turn")
+	}
+}
+
+func TestScheduler_weightNoQueue(t *testing.T) {
+	s := libp2p.NewScheduler(time.Minute, 1)
+
+	_, doneHigh, err := s.Admit(context.Background(), p2p.PriorityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doneHigh()
+
+	// a low priority handler gives up waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, _, err := s.Admit(ctx, p2p.PriorityLow)
+		errc <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+
+	// the turn of a high priority handler admitted without queued low
+	// priority ones is not kept for the later low priority handlers
+	_, doneHigh2, err := s.Admit(context.Background(), p2p.PriorityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doneHigh2()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, _, err := s.Admit(ctx, p2p.PriorityLow); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
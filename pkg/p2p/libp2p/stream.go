@@ -21,7 +21,9 @@ var _ p2p.Stream = (*stream)(nil)
 
 type stream struct {
 	network.Stream
-	headers map[string][]byte
+	headers   map[string][]byte
+	bandwidth *streamBandwidth
+	version   string
 }
 
 func NewStream(s network.Stream) p2p.Stream {
@@ -35,6 +37,31 @@ func (s *stream) Headers() p2p.Headers {
 	return s.headers
 }
 
+// Version returns the version of the protocol the stream is opened on.
+func (s *stream) Version() string {
+	return s.version
+}
+
+func (s *stream) Read(p []byte) (int, error) {
+	n, err := s.Stream.Read(p)
+	if n > 0 && s.bandwidth != nil {
+		if limitErr := s.bandwidth.read(n); limitErr != nil && err == nil {
+			err = limitErr
+		}
+	}
+	return n, err
+}
+
+func (s *stream) Write(p []byte) (int, error) {
+	n, err := s.Stream.Write(p)
+	if n > 0 && s.bandwidth != nil {
+		if limitErr := s.bandwidth.write(n); limitErr != nil && err == nil {
+			err = limitErr
+		}
+	}
+	return n, err
+}
+
 func (s *stream) FullClose() error {
 	// close the stream to make sure it is gc'd
 	defer s.Close()
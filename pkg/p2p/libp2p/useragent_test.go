@@ -0,0 +1,58 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package libp2p_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/p2p/libp2p"
+)
+
+func TestUserAgent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s1, overlay1 := newService(t, 1, libp2pServiceOpts{libp2pOpts: libp2p.Options{
+		UserAgent:   "voyager/test",
+		NetworkName: "testnet",
+	}})
+	s2, overlay2 := newService(t, 1, libp2pServiceOpts{})
+
+	if got := s2.UserAgent(overlay1); got != "" {
+		t.Fatalf("got user agent %q of a peer which is not connected", got)
+	}
+
+	if _, err := s2.Connect(ctx, serviceUnderlayAddress(t, s1)); err != nil {
+		t.Fatal(err)
+	}
+	expectPeersEventually(t, s1, overlay2)
+
+	if got, want := expectUserAgentEventually(t, s2, overlay1), "voyager/test network/testnet"; got != want {
+		t.Errorf("got user agent %q, want %q", got, want)
+	}
+	// the go version and the platform are not reported by default
+	if got, want := expectUserAgentEventually(t, s1, overlay2), "voyager/"+voyager.Version; got != want {
+		t.Errorf("got user agent %q, want %q", got, want)
+	}
+}
+
+// expectUserAgentEventually waits for the peer to be identified and returns
+// its user agent.
+func expectUserAgentEventually(t *testing.T, s *libp2p.Service, overlay infinity.Address) string {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if ua := s.UserAgent(overlay); ua != "" {
+			return ua
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("peer %s not identified", overlay)
+	return ""
+}
@@ -6,10 +6,14 @@ package libp2p
 
 import (
 	"errors"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/coreos/go-semver/semver"
 	protocol "github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
 )
 
 // protocolSemverMatcher returns a matcher function for a given base protocol.
@@ -18,7 +22,8 @@ import (
 // The matcher function will return a boolean indicating whether a protocol ID
 // matches the base protocol. A given protocol ID matches the base protocol if
 // the IDs are the same and if the semantic version of the base protocol is the
-// same or higher than that of the protocol ID provided.
+// same or higher than that of the protocol ID provided, unless a lower version
+// of the protocol added for the same stream matches it as well.
 func (s *Service) protocolSemverMatcher(base protocol.ID) (func(string) bool, error) {
 	parts := strings.Split(string(base), "/")
 	partsLen := len(parts)
@@ -52,6 +57,169 @@ func (s *Service) protocolSemverMatcher(base protocol.ID) (func(string) bool, er
 			return false
 		}
 
-		return vers.Major == chvers.Major && vers.Minor >= chvers.Minor
+		if vers.Major != chvers.Major || vers.Minor < chvers.Minor {
+			return false
+		}
+		if partsLen < 3 {
+			return true
+		}
+		// the closest version added for the stream handles it
+		return !s.versions.handledByLower(parts[partsLen-3], parts[partsLen-1], vers, chvers)
 	}, nil
 }
+
+// protocolIDVersion returns the version part of the protocol ID.
+func protocolIDVersion(id protocol.ID) string {
+	parts := strings.Split(string(id), "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[len(parts)-2]
+}
+
+// protocolVersions holds the versions of the protocols added to the service.
+type protocolVersions struct {
+	mu         sync.RWMutex
+	protocols  map[string][]string          // protocol name to its versions from the highest
+	streams    map[string][]*semver.Version // protocol and stream name to the versions handling the stream
+	deprecated map[string]bool              // protocol name and version to whether it is deprecated
+	logged     map[string]struct{}          // deprecated protocol versions already logged as used
+}
+
+func newProtocolVersions() *protocolVersions {
+	return &protocolVersions{
+		protocols:  make(map[string][]string),
+		streams:    make(map[string][]*semver.Version),
+		deprecated: make(map[string]bool),
+		logged:     make(map[string]struct{}),
+	}
+}
+
+// add adds the version of the protocol and returns all versions of it from
+// the highest.
+func (v *protocolVersions) add(p p2p.ProtocolSpec) ([]string, error) {
+	vers, err := semver.NewVersion(p.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, ss := range p.StreamSpecs {
+		key := p.Name + "/" + ss.Name
+		v.streams[key] = append(v.streams[key], vers)
+	}
+	v.deprecated[p.Name+"/"+p.Version] = p.Deprecated
+
+	for _, e := range v.protocols[p.Name] {
+		if e == p.Version {
+			return v.protocols[p.Name], nil
+		}
+	}
+	// the returned versions are not modified, a new slice is stored
+	versions := append([]string{p.Version}, v.protocols[p.Name]...)
+	sort.Slice(versions, func(i, j int) bool {
+		return semver.New(versions[j]).LessThan(*semver.New(versions[i]))
+	})
+	v.protocols[p.Name] = versions
+	return versions, nil
+}
+
+// versions returns the added versions of the protocol from the highest.
+func (v *protocolVersions) versions(name string) []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.protocols[name]
+}
+
+// handledByLower returns true if a version of the protocol lower than the
+// base handles the stream requested on the version.
+func (v *protocolVersions) handledByLower(name, stream string, base, requested *semver.Version) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, e := range v.streams[name+"/"+stream] {
+		if e.LessThan(*base) && e.Major == requested.Major && e.Minor >= requested.Minor {
+			return true
+		}
+	}
+	return false
+}
+
+// used returns whether the version of the protocol is deprecated and whether
+// it is used for the first time since it was deprecated.
+func (v *protocolVersions) used(name, version string) (deprecated, first bool) {
+	key := name + "/" + version
+
+	v.mu.RLock()
+	deprecated = v.deprecated[key]
+	_, logged := v.logged[key]
+	v.mu.RUnlock()
+	if !deprecated || logged {
+		return deprecated, false
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, logged := v.logged[key]; logged {
+		return true, false
+	}
+	v.logged[key] = struct{}{}
+	return true, true
+}
+
+// negotiateVersion returns the version of the protocol the stream to the peer
+// is opened on. If several versions of the protocol are added, it is the
+// highest of them, not higher than the requested one, that the peer supports
+// according to the handshake. The peers which do not advertise the versions
+// in the handshake, like the ones on the older releases, are also offered the
+// lower added versions as the fallback, from the highest, and the stream is
+// opened on the first one the peer supports.
+func (s *Service) negotiateVersion(overlay infinity.Address, name, requested string) (version string, fallback []string) {
+	local := s.versions.versions(name)
+	if len(local) < 2 {
+		return requested, nil
+	}
+	remote := s.peers.peer(overlay).SupportedVersions(name)
+	if len(remote) == 0 {
+		return requested, lowerVersions(local, requested)
+	}
+	version, ok := p2p.MutualVersion(local, remote, requested)
+	if !ok {
+		return requested, nil
+	}
+	return version, nil
+}
+
+// lowerVersions returns the versions lower than the given one, in the order
+// of the versions, which are from the highest.
+func lowerVersions(versions []string, version string) []string {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return nil
+	}
+	var lower []string
+	for _, e := range versions {
+		if ev, err := semver.NewVersion(e); err == nil && ev.LessThan(*v) {
+			lower = append(lower, e)
+		}
+	}
+	return lower
+}
+
+// logDeprecated logs the use of the deprecated version of the protocol with
+// the peer, at the info level for the first use only.
+func (s *Service) logDeprecated(overlay infinity.Address, name, version string) {
+	deprecated, first := s.versions.used(name, version)
+	if !deprecated {
+		return
+	}
+	s.metrics.DeprecatedStreamCount.WithLabelValues(name, version).Inc()
+	if first {
+		s.logger.Infof("deprecated protocol %s/%s used by peer %s, the peers on it should upgrade", name, version, overlay)
+		return
+	}
+	s.logger.Tracef("deprecated protocol %s/%s used by peer %s", name, version, overlay)
+}
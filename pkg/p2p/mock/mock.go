@@ -27,6 +27,12 @@ type Service struct {
 	setWelcomeMessageFunc func(string) error
 	getWelcomeMessageFunc func() string
 	blocklistFunc         func(infinity.Address, time.Duration) error
+	bandwidthFunc         func() p2p.Bandwidth
+	listenAddressesFunc   func() []ma.Multiaddr
+	listenFunc            func(ma.Multiaddr) error
+	closeListenerFunc     func(ma.Multiaddr) error
+	connectionEventsFunc  func() []p2p.ConnectionEvent
+	userAgentFunc         func(infinity.Address) string
 	welcomeMessage        string
 }
 
@@ -99,6 +105,48 @@ func WithBlocklistFunc(f func(infinity.Address, time.Duration) error) Option {
 	})
 }
 
+// WithBandwidthFunc sets the mock implementation of the Bandwidth function
+func WithBandwidthFunc(f func() p2p.Bandwidth) Option {
+	return optionFunc(func(s *Service) {
+		s.bandwidthFunc = f
+	})
+}
+
+// WithListenAddressesFunc sets the mock implementation of the ListenAddresses function
+func WithListenAddressesFunc(f func() []ma.Multiaddr) Option {
+	return optionFunc(func(s *Service) {
+		s.listenAddressesFunc = f
+	})
+}
+
+// WithListenFunc sets the mock implementation of the Listen function
+func WithListenFunc(f func(ma.Multiaddr) error) Option {
+	return optionFunc(func(s *Service) {
+		s.listenFunc = f
+	})
+}
+
+// WithCloseListenerFunc sets the mock implementation of the CloseListener function
+func WithCloseListenerFunc(f func(ma.Multiaddr) error) Option {
+	return optionFunc(func(s *Service) {
+		s.closeListenerFunc = f
+	})
+}
+
+// WithConnectionEventsFunc sets the mock implementation of the ConnectionEvents function
+func WithConnectionEventsFunc(f func() []p2p.ConnectionEvent) Option {
+	return optionFunc(func(s *Service) {
+		s.connectionEventsFunc = f
+	})
+}
+
+// WithUserAgentFunc sets the mock implementation of the UserAgent function
+func WithUserAgentFunc(f func(infinity.Address) string) Option {
+	return optionFunc(func(s *Service) {
+		s.userAgentFunc = f
+	})
+}
+
 // New will create a new mock P2P Service with the given options
 func New(opts ...Option) *Service {
 	s := new(Service)
@@ -166,6 +214,48 @@ func (s *Service) GetWelcomeMessage() string {
 	return s.welcomeMessage
 }
 
+func (s *Service) Bandwidth() p2p.Bandwidth {
+	if s.bandwidthFunc == nil {
+		return p2p.Bandwidth{}
+	}
+	return s.bandwidthFunc()
+}
+
+func (s *Service) ListenAddresses() []ma.Multiaddr {
+	if s.listenAddressesFunc == nil {
+		return nil
+	}
+	return s.listenAddressesFunc()
+}
+
+func (s *Service) Listen(addr ma.Multiaddr) error {
+	if s.listenFunc == nil {
+		return errors.New("function Listen not configured")
+	}
+	return s.listenFunc(addr)
+}
+
+func (s *Service) CloseListener(addr ma.Multiaddr) error {
+	if s.closeListenerFunc == nil {
+		return errors.New("function CloseListener not configured")
+	}
+	return s.closeListenerFunc(addr)
+}
+
+func (s *Service) ConnectionEvents() []p2p.ConnectionEvent {
+	if s.connectionEventsFunc == nil {
+		return nil
+	}
+	return s.connectionEventsFunc()
+}
+
+func (s *Service) UserAgent(overlay infinity.Address) string {
+	if s.userAgentFunc == nil {
+		return ""
+	}
+	return s.userAgentFunc(overlay)
+}
+
 func (s *Service) Blocklist(overlay infinity.Address, duration time.Duration) error {
 	if s.blocklistFunc == nil {
 		return errors.New("function blocklist not configured")
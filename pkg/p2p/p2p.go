@@ -8,6 +8,7 @@ package p2p
 
 import (
 	"context"
+	"encoding/binary"
 	"io"
 	"time"
 
@@ -46,11 +47,64 @@ type Notifier interface {
 	Disconnected(Peer)
 }
 
+// Reconnecter is implemented by the notifiers which manage the connections to
+// the peers, so that the peers with the lost connections are connected to
+// again through them.
+type Reconnecter interface {
+	Reconnect(ctx context.Context, overlay infinity.Address) error
+}
+
 // DebugService extends the Service with method used for debugging.
 type DebugService interface {
 	Service
 	SetWelcomeMessage(val string) error
 	GetWelcomeMessage() string
+	Bandwidth() Bandwidth
+	ListenAddresses() []ma.Multiaddr
+	Listen(addr ma.Multiaddr) error
+	CloseListener(addr ma.Multiaddr) error
+	// ConnectionEvents returns the recent connection events, from the
+	// oldest to the newest.
+	ConnectionEvents() []ConnectionEvent
+	// UserAgent returns the user agent reported by the connected peer in
+	// the libp2p identify, empty if it is not known yet.
+	UserAgent(overlay infinity.Address) string
+}
+
+// Bandwidth holds the number of bytes transferred over streams per peer and
+// per protocol.
+type Bandwidth struct {
+	Peers     map[string]BandwidthStats `json:"peers"`
+	Protocols map[string]BandwidthStats `json:"protocols"`
+}
+
+// BandwidthStats holds the number of received and sent bytes.
+type BandwidthStats struct {
+	In  uint64 `json:"in"`
+	Out uint64 `json:"out"`
+}
+
+// ConnectionEventType is the type of a connection event.
+type ConnectionEventType string
+
+const (
+	ConnectionEventDial         ConnectionEventType = "dial"
+	ConnectionEventConnected    ConnectionEventType = "connected"
+	ConnectionEventFailed       ConnectionEventType = "failed"
+	ConnectionEventDisconnected ConnectionEventType = "disconnected"
+	ConnectionEventBlocklisted  ConnectionEventType = "blocklisted"
+)
+
+// ConnectionEvent is a connection event of a peer recorded for diagnosing
+// the connectivity.
+type ConnectionEvent struct {
+	Time      time.Time
+	Type      ConnectionEventType
+	Inbound   bool
+	Overlay   infinity.Address // zero if the peer is not known yet
+	Underlay  ma.Multiaddr     // nil if not known
+	ErrorType string           // class of the error of a failed connection
+	Reason    string           // error of a failed connection or the reason of a disconnect
 }
 
 // Streamer is able to create a new Stream.
@@ -73,6 +127,10 @@ type Stream interface {
 }
 
 // ProtocolSpec defines a collection of Stream specifications with handlers.
+// A protocol can be added in several versions, each with its own handlers,
+// and the streams are opened on the highest version supported by the peer.
+// The connect and disconnect hooks are called for every added version, so
+// they are set on one of them.
 type ProtocolSpec struct {
 	Name          string
 	Version       string
@@ -81,6 +139,9 @@ type ProtocolSpec struct {
 	ConnectOut    func(context.Context, Peer) error
 	DisconnectIn  func(Peer) error
 	DisconnectOut func(Peer) error
+	// Deprecated marks the version kept only for the peers which do not
+	// support the newer ones, the peers using it are logged.
+	Deprecated bool
 }
 
 // StreamSpec defines a Stream handling within the protocol.
@@ -92,7 +153,44 @@ type StreamSpec struct {
 
 // Peer holds information about a Peer.
 type Peer struct {
-	Address infinity.Address `json:"address"`
+	Address         infinity.Address  `json:"address"`
+	Capabilities    Capabilities      `json:"capabilities,omitempty"`
+	FeatureVersions map[string]string `json:"featureVersions,omitempty"`
+	// ProtocolVersions holds all versions of the protocols advertised by
+	// the peer in the handshake.
+	ProtocolVersions map[string][]string `json:"protocolVersions,omitempty"`
+	// UserAgent is the user agent reported by the peer in the libp2p
+	// identify, it is set only by the debug API.
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+// HasCapabilities returns true if the peer advertised all of the provided
+// capabilities in the handshake.
+func (p Peer) HasCapabilities(c Capabilities) bool {
+	return p.Capabilities.Has(c)
+}
+
+// FeatureVersion returns the version of the named feature advertised by the
+// peer in the handshake and whether the feature is supported at all.
+func (p Peer) FeatureVersion(name string) (version string, ok bool) {
+	version, ok = p.FeatureVersions[name]
+	return version, ok
+}
+
+// Capabilities is a bitfield of node capabilities exchanged in the handshake.
+type Capabilities uint64
+
+// Capabilities that a node can advertise to its peers.
+const (
+	CapabilityLightNode Capabilities = 1 << iota
+	CapabilityBootnode
+	CapabilityPss
+	CapabilityChain
+)
+
+// Has returns true if all bits of c are set.
+func (c Capabilities) Has(f Capabilities) bool {
+	return c&f == f
 }
 
 // HandlerFunc handles a received Stream from a Peer.
@@ -111,8 +209,55 @@ type Headers map[string][]byte
 // Common header names.
 const (
 	HeaderNameTracingSpanContext = "tracing-span-context"
+	HeaderNamePriority           = "priority"
+	HeaderNameDeadline           = "deadline"
+)
+
+// Priority is the stream priority that is sent in headers to the peer which
+// schedules handling of the stream accordingly.
+type Priority uint8
+
+// Stream priorities. Streams without the priority header have the normal
+// priority.
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
 )
 
+// Priority returns the stream priority from headers.
+func (h Headers) Priority() Priority {
+	v, ok := h[HeaderNamePriority]
+	if !ok || len(v) != 1 {
+		return PriorityNormal
+	}
+	return Priority(v[0])
+}
+
+// SetPriority sets the stream priority header.
+func (h Headers) SetPriority(p Priority) {
+	h[HeaderNamePriority] = []byte{byte(p)}
+}
+
+// Deadline returns the duration within which the stream needs to be handled,
+// if the header is set. The deadline is sent relative to the time when the
+// headers are created so that it does not depend on peers' clocks being in
+// sync.
+func (h Headers) Deadline() (timeout time.Duration, ok bool) {
+	v, ok := h[HeaderNameDeadline]
+	if !ok || len(v) != 8 {
+		return 0, false
+	}
+	return time.Duration(binary.BigEndian.Uint64(v)), true
+}
+
+// SetDeadline sets the stream deadline header.
+func (h Headers) SetDeadline(t time.Time) {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(time.Until(t)))
+	h[HeaderNameDeadline] = v
+}
+
 // NewInfinityStreamName constructs a libp2p compatible stream name out of
 // protocol name and version and stream name.
 func NewInfinityStreamName(protocol, version, stream string) string {
@@ -5,7 +5,9 @@
 package p2p_test
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/yanhuangpai/voyager/pkg/p2p"
 )
@@ -18,3 +20,83 @@ func TestNewInfinityStreamName(t *testing.T) {
 		t.Errorf("got %s, want %s", got, want)
 	}
 }
+
+func TestHeaders_priority(t *testing.T) {
+	h := make(p2p.Headers)
+	if got := h.Priority(); got != p2p.PriorityNormal {
+		t.Errorf("got priority %v, want %v", got, p2p.PriorityNormal)
+	}
+
+	h.SetPriority(p2p.PriorityHigh)
+	if got := h.Priority(); got != p2p.PriorityHigh {
+		t.Errorf("got priority %v, want %v", got, p2p.PriorityHigh)
+	}
+}
+
+func TestHeaders_deadline(t *testing.T) {
+	h := make(p2p.Headers)
+	if _, ok := h.Deadline(); ok {
+		t.Fatal("got deadline, want none")
+	}
+
+	h.SetDeadline(time.Now().Add(time.Minute))
+	timeout, ok := h.Deadline()
+	if !ok {
+		t.Fatal("got no deadline")
+	}
+	if timeout <= 0 || timeout > time.Minute {
+		t.Errorf("got timeout %v, want up to %v", timeout, time.Minute)
+	}
+
+	h.SetDeadline(time.Now().Add(-time.Minute))
+	if timeout, _ := h.Deadline(); timeout > 0 {
+		t.Errorf("got timeout %v, want expired", timeout)
+	}
+}
+
+func TestMutualVersion(t *testing.T) {
+	local := []string{"2.0.0", "1.1.0", "1.0.0"}
+	for _, tc := range []struct {
+		remote    []string
+		requested string
+		want      string
+		ok        bool
+	}{
+		{remote: []string{"2.0.0", "1.1.0"}, requested: "2.0.0", want: "2.0.0", ok: true},
+		{remote: []string{"1.1.0"}, requested: "2.0.0", want: "1.1.0", ok: true},
+		{remote: []string{"1.2.0"}, requested: "2.0.0", want: "1.1.0", ok: true},
+		{remote: []string{"1.0.0"}, requested: "2.0.0", want: "1.0.0", ok: true},
+		{remote: []string{"1.1.0"}, requested: "1.0.0", want: "1.0.0", ok: true},
+		{remote: []string{"0.9.0"}, requested: "2.0.0", ok: false},
+		{remote: []string{"3.0.0"}, requested: "2.0.0", ok: false},
+		{remote: nil, requested: "2.0.0", ok: false},
+		{remote: []string{"2.0.0"}, requested: "invalid", ok: false},
+	} {
+		got, ok := p2p.MutualVersion(local, tc.remote, tc.requested)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("remote %v requested %s: got %q %v, want %q %v", tc.remote, tc.requested, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestPeer_SupportedVersions(t *testing.T) {
+	p := p2p.Peer{
+		FeatureVersions: map[string]string{
+			"pullsync": "1.1.0",
+			"pushsync": "1.0.0",
+		},
+		ProtocolVersions: map[string][]string{
+			"pullsync": {"1.1.0", "1.0.0"},
+		},
+	}
+
+	if got, want := p.SupportedVersions("pullsync"), []string{"1.1.0", "1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got pullsync versions %v, want %v", got, want)
+	}
+	if got, want := p.SupportedVersions("pushsync"), []string{"1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got pushsync versions %v, want %v", got, want)
+	}
+	if got := p.SupportedVersions("hive"); got != nil {
+		t.Errorf("got hive versions %v, want none", got)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protobuf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	// fieldsReaderThreshold is the size of the messages above which the
+	// fields are decoded while the message is read, the smaller messages
+	// are read whole before they are decoded.
+	fieldsReaderThreshold = 16 * 1024
+	// fieldsReaderMaxSize is the max size of the message which fields are
+	// decoded.
+	fieldsReaderMaxSize = 16 * 1024 * 1024
+)
+
+// Protocol Buffers wire types of the field values.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errUnsupportedWireType = errors.New("unsupported wire type")
+
+// Field is a field of the message decoded by ReadFields. Every element of the
+// repeated fields is a separate field with the same number.
+type Field struct {
+	// Number is the number of the field in the message definition.
+	Number int32
+	// Value is the value of the varint and the fixed size fields.
+	Value uint64
+	// Len is the length of the value of the length delimited field, the
+	// value is read from the field itself.
+	Len int
+
+	r io.Reader
+}
+
+// Read reads the value of the length delimited field.
+func (f Field) Read(p []byte) (int, error) {
+	if f.r == nil {
+		return 0, io.EOF
+	}
+	return f.r.Read(p)
+}
+
+// Unmarshal reads the value of the length delimited field into the message,
+// as the elements of the repeated message fields are encoded.
+func (f Field) Unmarshal(m proto.Message) error {
+	if f.Len > delimitedReaderMaxSize {
+		return io.ErrShortBuffer
+	}
+	b := make([]byte, f.Len)
+	if _, err := io.ReadFull(f, b); err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+// ReadFields reads the next delimited message, calling fn for each of its
+// fields in the order they are read. The message is not kept in memory as a
+// whole if it is larger than the threshold, so that the messages with many
+// elements of the repeated fields are processed as they arrive. The part of
+// the field value not read by fn is skipped. Decoding stops with the error
+// returned by fn.
+func (r Reader) ReadFields(fn func(Field) error) error {
+	length, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return err
+	}
+	if length > fieldsReaderMaxSize {
+		return io.ErrShortBuffer
+	}
+
+	var src byteReader = r.r
+	if length <= fieldsReaderThreshold {
+		b := make([]byte, length)
+		if _, err := io.ReadFull(r.r, b); err != nil {
+			return err
+		}
+		src = bytes.NewReader(b)
+	}
+	m := &messageReader{r: src, n: int64(length)}
+
+	for m.n > 0 {
+		tag, err := binary.ReadUvarint(m)
+		if err != nil {
+			return fmt.Errorf("field tag: %w", err)
+		}
+		f := Field{Number: int32(tag >> 3)}
+
+		switch wireType := tag & 7; wireType {
+		case wireVarint:
+			if f.Value, err = binary.ReadUvarint(m); err != nil {
+				return fmt.Errorf("field %d: %w", f.Number, err)
+			}
+		case wireFixed64:
+			var b [8]byte
+			if _, err := io.ReadFull(m, b[:]); err != nil {
+				return fmt.Errorf("field %d: %w", f.Number, err)
+			}
+			f.Value = binary.LittleEndian.Uint64(b[:])
+		case wireFixed32:
+			var b [4]byte
+			if _, err := io.ReadFull(m, b[:]); err != nil {
+				return fmt.Errorf("field %d: %w", f.Number, err)
+			}
+			f.Value = uint64(binary.LittleEndian.Uint32(b[:]))
+		case wireBytes:
+			l, err := binary.ReadUvarint(m)
+			if err != nil {
+				return fmt.Errorf("field %d length: %w", f.Number, err)
+			}
+			if l > uint64(m.n) {
+				return fmt.Errorf("field %d: %w", f.Number, io.ErrUnexpectedEOF)
+			}
+			f.Len = int(l)
+			f.r = io.LimitReader(m, int64(l))
+		default:
+			return fmt.Errorf("field %d: %w %d", f.Number, errUnsupportedWireType, wireType)
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+		if f.r != nil {
+			if _, err := io.Copy(ioutil.Discard, f.r); err != nil {
+				return fmt.Errorf("field %d: %w", f.Number, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ReadFieldsWithContext reads the fields of the next delimited message as
+// ReadFields does, no more fields are passed to fn once the context is done.
+func (r Reader) ReadFieldsWithContext(ctx context.Context, fn func(Field) error) error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- r.ReadFields(func(f Field) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return fn(f)
+		})
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// messageReader reads up to the n remaining bytes of the message.
+type messageReader struct {
+	r byteReader
+	n int64
+}
+
+func (m *messageReader) Read(p []byte) (int, error) {
+	if m.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > m.n {
+		p = p[:m.n]
+	}
+	n, err := m.r.Read(p)
+	m.n -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (m *messageReader) ReadByte() (byte, error) {
+	if m.n <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b, err := m.r.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	m.n--
+	return b, nil
+}
@@ -5,6 +5,7 @@
 package protobuf
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"io"
@@ -25,13 +26,29 @@ func NewWriterAndReader(s p2p.Stream) (Writer, Reader) {
 }
 
 func NewReader(r io.Reader) Reader {
-	return newReader(ggio.NewDelimitedReader(r, delimitedReaderMaxSize))
+	// the delimited reader uses the same buffered reader, as it is not
+	// wrapped again, so that the messages are read by ReadMsg and
+	// ReadFields from the same buffer
+	br := bufio.NewReader(r)
+	return newReader(ggio.NewDelimitedReader(br, delimitedReaderMaxSize), br)
 }
 
 func NewWriter(w io.Writer) Writer {
 	return newWriter(ggio.NewDelimitedWriter(w))
 }
 
+// NewHeaders returns the standard stream headers with the given priority and
+// the deadline of the context, if it has one. The tracing span context header
+// is added by the p2p service when the stream is created.
+func NewHeaders(ctx context.Context, priority p2p.Priority) p2p.Headers {
+	h := make(p2p.Headers)
+	h.SetPriority(priority)
+	if deadline, ok := ctx.Deadline(); ok {
+		h.SetDeadline(deadline)
+	}
+	return h
+}
+
 func ReadMessages(r io.Reader, newMessage func() Message) (m []Message, err error) {
 	pr := NewReader(r)
 	for {
@@ -49,10 +66,11 @@ func ReadMessages(r io.Reader, newMessage func() Message) (m []Message, err erro
 
 type Reader struct {
 	ggio.Reader
+	r *bufio.Reader
 }
 
-func newReader(r ggio.Reader) Reader {
-	return Reader{Reader: r}
+func newReader(r ggio.Reader, br *bufio.Reader) Reader {
+	return Reader{Reader: r, r: br}
 }
 
 func (r Reader) ReadMsgWithContext(ctx context.Context, msg proto.Message) error {
@@ -5,7 +5,9 @@
 package protobuf_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"testing"
@@ -244,6 +246,137 @@ func TestReadMessages(t *testing.T) {
 	}
 }
 
+func TestReader_ReadFields(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		count int
+	}{
+		{
+			name:  "small message",
+			count: 10,
+		},
+		{
+			name:  "large message",
+			count: 10000, // above the threshold of the fields reader
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			want := &pb.Messages{
+				Count: uint64(tc.count),
+				Data:  bytes.Repeat([]byte{1}, tc.count),
+			}
+			for i := 0; i < tc.count; i++ {
+				want.Messages = append(want.Messages, &pb.Message{Text: fmt.Sprintf("message %d", i)})
+			}
+
+			var buf bytes.Buffer
+			w := protobuf.NewWriter(&buf)
+			if err := w.WriteMsg(want); err != nil {
+				t.Fatal(err)
+			}
+			// the message after the decoded one is read from the same buffer
+			if err := w.WriteMsg(&pb.Message{Text: "next"}); err != nil {
+				t.Fatal(err)
+			}
+
+			r := protobuf.NewReader(&buf)
+			var (
+				got  pb.Messages
+				data []byte
+			)
+			if err := r.ReadFields(func(f protobuf.Field) error {
+				switch f.Number {
+				case 1:
+					var m pb.Message
+					if err := f.Unmarshal(&m); err != nil {
+						return err
+					}
+					got.Messages = append(got.Messages, &m)
+				case 2:
+					got.Count = f.Value
+				case 3:
+					// only a part of the value is read, the rest is skipped
+					data = make([]byte, f.Len/2)
+					if _, err := io.ReadFull(f, data); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got.Messages) != tc.count {
+				t.Fatalf("got %d messages, want %d", len(got.Messages), tc.count)
+			}
+			for i, m := range got.Messages {
+				if m.Text != want.Messages[i].Text {
+					t.Fatalf("got message %q, want %q", m.Text, want.Messages[i].Text)
+				}
+			}
+			if got.Count != want.Count {
+				t.Errorf("got count %d, want %d", got.Count, want.Count)
+			}
+			if !bytes.Equal(data, want.Data[:len(want.Data)/2]) {
+				t.Error("got invalid data")
+			}
+
+			var next pb.Message
+			if err := r.ReadMsg(&next); err != nil {
+				t.Fatal(err)
+			}
+			if next.Text != "next" {
+				t.Errorf("got message %q, want %q", next.Text, "next")
+			}
+
+			if err := r.ReadFields(func(protobuf.Field) error { return nil }); err != io.EOF {
+				t.Fatalf("got error %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestReader_ReadFieldsError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := protobuf.NewWriter(&buf).WriteMsg(&pb.Messages{Count: 1, Data: []byte{1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	errTest := errors.New("test error")
+	var fields int
+	err := protobuf.NewReader(&buf).ReadFieldsWithContext(context.Background(), func(protobuf.Field) error {
+		fields++
+		return errTest
+	})
+	if !errors.Is(err, errTest) {
+		t.Fatalf("got error %v, want %v", err, errTest)
+	}
+	if fields != 1 {
+		t.Errorf("got %d fields, want 1", fields)
+	}
+}
+
+func TestNewHeaders(t *testing.T) {
+	h := protobuf.NewHeaders(context.Background(), p2p.PriorityLow)
+	if got := h.Priority(); got != p2p.PriorityLow {
+		t.Errorf("got priority %v, want %v", got, p2p.PriorityLow)
+	}
+	if _, ok := h.Deadline(); ok {
+		t.Error("got deadline, want none")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	h = protobuf.NewHeaders(ctx, p2p.PriorityHigh)
+	if got := h.Priority(); got != p2p.PriorityHigh {
+		t.Errorf("got priority %v, want %v", got, p2p.PriorityHigh)
+	}
+	if timeout, ok := h.Deadline(); !ok || timeout <= 0 || timeout > time.Minute {
+		t.Errorf("got timeout %v, want up to %v", timeout, time.Minute)
+	}
+}
+
 func newMessageReader(messages []string, delay time.Duration) io.Reader {
 	r, pipe := io.Pipe()
 	w := protobuf.NewWriter(pipe)
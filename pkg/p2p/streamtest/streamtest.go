@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/coreos/go-semver/semver"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
 )
@@ -21,6 +22,7 @@ var (
 	ErrStreamNotSupported     = errors.New("stream not supported")
 	ErrStreamClosed           = errors.New("stream closed")
 	ErrStreamFullcloseTimeout = errors.New("fullclose timeout")
+	ErrStreamReset            = errors.New("stream reset")
 	fullCloseTimeout          = fullCloseTimeoutDefault // timeout of fullclose
 	fullCloseTimeoutDefault   = 5 * time.Second         // default timeout used for helper function to reset timeout when changed
 
@@ -78,21 +80,13 @@ func (r *Recorder) NewStream(ctx context.Context, addr infinity.Address, h p2p.H
 	streamOut := newStream(recordIn, recordOut)
 	streamIn := newStream(recordOut, recordIn)
 
-	var handler p2p.HandlerFunc
-	var headler p2p.HeadlerFunc
-	for _, p := range r.protocols {
-		if p.Name == protocolName && p.Version == protocolVersion {
-			for _, s := range p.StreamSpecs {
-				if s.Name == streamName {
-					handler = s.Handler
-					headler = s.Headler
-				}
-			}
-		}
-	}
-	if handler == nil {
+	spec, version, ok := r.negotiate(protocolName, protocolVersion, streamName)
+	if !ok {
 		return nil, ErrStreamNotSupported
 	}
+	handler, headler := spec.Handler, spec.Headler
+	streamOut.version = version
+	streamIn.version = version
 	for i := len(r.middlewares) - 1; i >= 0; i-- {
 		handler = r.middlewares[i](handler)
 	}
@@ -120,6 +114,52 @@ func (r *Recorder) NewStream(ctx context.Context, addr infinity.Address, h p2p.H
 	return streamOut, nil
 }
 
+// negotiate returns the spec of the stream handling the requested protocol
+// version and the version the stream is opened on, like the libp2p streams
+// do. It is the requested version if it is added, or the closest higher minor
+// version of the same major, or the highest lower version, which the peers
+// offer as the fallback to the older ones.
+func (r *Recorder) negotiate(protocolName, protocolVersion, streamName string) (spec p2p.StreamSpec, version string, ok bool) {
+	requested, err := semver.NewVersion(protocolVersion)
+	var (
+		handling, lower *semver.Version
+		exact           *p2p.StreamSpec
+	)
+	for _, p := range r.protocols {
+		if p.Name != protocolName {
+			continue
+		}
+		for i, s := range p.StreamSpecs {
+			if s.Name != streamName {
+				continue
+			}
+			if p.Version == protocolVersion {
+				// the last added spec handles the stream
+				exact = &p.StreamSpecs[i]
+				continue
+			}
+			v, verr := semver.NewVersion(p.Version)
+			if err != nil || verr != nil {
+				continue
+			}
+			switch {
+			case p2p.VersionAccepts(p.Version, protocolVersion):
+				if handling == nil || v.LessThan(*handling) {
+					handling, spec, version, ok = v, s, protocolVersion, true
+				}
+			case handling == nil && v.LessThan(*requested):
+				if lower == nil || lower.LessThan(*v) {
+					lower, spec, version, ok = v, s, p.Version, true
+				}
+			}
+		}
+	}
+	if exact != nil {
+		return *exact, protocolVersion, true
+	}
+	return spec, version, ok
+}
+
 func (r *Recorder) Records(addr infinity.Address, protocolName, protocolVersio, streamName string) ([]*Record, error) {
 	id := addr.String() + p2p.NewInfinityStreamName(protocolName, protocolVersio, streamName)
 
@@ -197,12 +237,18 @@ type stream struct {
 	in      *record
 	out     *record
 	headers p2p.Headers
+	version string
 }
 
 func newStream(in, out *record) *stream {
 	return &stream{in: in, out: out}
 }
 
+// Version returns the protocol version the stream is opened on.
+func (s *stream) Version() string {
+	return s.version
+}
+
 func (s *stream) Read(p []byte) (int, error) {
 	return s.out.Read(p)
 }
@@ -229,6 +275,9 @@ func (s *stream) FullClose() error {
 
 	for {
 		if s.out.Closed() {
+			if s.out.isReset() {
+				return ErrStreamReset
+			}
 			return nil
 		}
 
@@ -241,6 +290,9 @@ func (s *stream) FullClose() error {
 }
 
 func (s *stream) Reset() (err error) {
+	s.in.setReset()
+	s.out.setReset()
+
 	if err := s.in.Close(); err != nil {
 		_ = s.out.Close()
 		return err
@@ -253,6 +305,7 @@ type record struct {
 	b       []byte
 	c       int
 	closed  bool
+	reset   bool
 	closeMu sync.RWMutex
 	cond    *sync.Cond
 }
@@ -315,6 +368,19 @@ func (r *record) Closed() bool {
 	return r.closed
 }
 
+func (r *record) setReset() {
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
+	r.reset = true
+}
+
+// isReset returns true if the record is closed by a stream reset.
+func (r *record) isReset() bool {
+	r.closeMu.RLock()
+	defer r.closeMu.RUnlock()
+	return r.reset
+}
+
 func (r *record) bytes() []byte {
 	r.cond.L.Lock()
 	defer r.cond.L.Unlock()
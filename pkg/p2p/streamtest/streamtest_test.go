@@ -121,6 +121,82 @@ func TestRecorder_errStreamNotSupported(t *testing.T) {
 	}
 }
 
+func TestRecorder_versionNegotiation(t *testing.T) {
+	handled := make(chan string, 1)
+	versionedProtocol := func(version string) p2p.ProtocolSpec {
+		return p2p.ProtocolSpec{
+			Name:    testProtocolName,
+			Version: version,
+			StreamSpecs: []p2p.StreamSpec{
+				{
+					Name: testStreamName,
+					Handler: func(_ context.Context, _ p2p.Peer, s p2p.Stream) error {
+						handled <- version
+						return s.FullClose()
+					},
+				},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name        string
+		versions    []string
+		requested   string
+		wantHandled string
+		wantVersion string
+	}{
+		{
+			name:        "exact",
+			versions:    []string{"1.2.0", "1.0.0"},
+			requested:   "1.0.0",
+			wantHandled: "1.0.0",
+			wantVersion: "1.0.0",
+		},
+		{
+			name:        "higher minor",
+			versions:    []string{"1.2.0", "2.0.0"},
+			requested:   "1.1.0",
+			wantHandled: "1.2.0",
+			wantVersion: "1.1.0",
+		},
+		{
+			name:        "fallback",
+			versions:    []string{"1.0.0", "0.9.0"},
+			requested:   "1.2.0",
+			wantHandled: "1.0.0",
+			wantVersion: "1.0.0",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var protocols []p2p.ProtocolSpec
+			for _, v := range tc.versions {
+				protocols = append(protocols, versionedProtocol(v))
+			}
+			recorder := streamtest.New(streamtest.WithProtocols(protocols...))
+
+			stream, err := recorder.NewStream(context.Background(), infinity.ZeroAddress, nil, testProtocolName, tc.requested, testStreamName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p2p.StreamVersion(stream, ""); got != tc.wantVersion {
+				t.Errorf("got stream version %s, want %s", got, tc.wantVersion)
+			}
+			if err := stream.FullClose(); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case got := <-handled:
+				if got != tc.wantHandled {
+					t.Errorf("got handled by version %s, want %s", got, tc.wantHandled)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for the handler")
+			}
+		})
+	}
+}
+
 func TestRecorder_fullcloseWithRemoteClose(t *testing.T) {
 	recorder := streamtest.New(
 		streamtest.WithProtocols(
@@ -215,6 +291,41 @@ func TestRecorder_fullcloseWithoutRemoteClose(t *testing.T) {
 	}, nil)
 }
 
+func TestRecorder_fullcloseWithRemoteReset(t *testing.T) {
+	recorder := streamtest.New(
+		streamtest.WithProtocols(
+			newTestProtocol(func(_ context.Context, peer p2p.Peer, stream p2p.Stream) error {
+				if _, err := bufio.NewReader(stream).ReadString('\n'); err != nil {
+					return err
+				}
+				return stream.Reset()
+			}),
+		),
+	)
+
+	request := func(ctx context.Context, s p2p.Streamer, address infinity.Address) (err error) {
+		stream, err := s.NewStream(ctx, address, nil, testProtocolName, testProtocolVersion, testStreamName)
+		if err != nil {
+			return fmt.Errorf("new stream: %w", err)
+		}
+
+		rw := bufio.NewReadWriter(bufio.NewReader(stream), bufio.NewWriter(stream))
+		if _, err := rw.WriteString("message\n"); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+		if err := rw.Flush(); err != nil {
+			return fmt.Errorf("flush: %w", err)
+		}
+
+		return stream.FullClose()
+	}
+
+	err := request(context.Background(), recorder, infinity.ZeroAddress)
+	if err != streamtest.ErrStreamReset {
+		t.Fatalf("got error %v, want %v", err, streamtest.ErrStreamReset)
+	}
+}
+
 func TestRecorder_multipleParallelFullCloseAndClose(t *testing.T) {
 	recorder := streamtest.New(
 		streamtest.WithProtocols(
@@ -0,0 +1,90 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"sort"
+
+	"github.com/coreos/go-semver/semver"
+)
+
+// VersionedStream is implemented by the streams which report the version of
+// the protocol negotiated with the peer.
+type VersionedStream interface {
+	Stream
+	Version() string
+}
+
+// StreamVersion returns the version of the protocol the stream is opened on,
+// the requested version if the stream does not report it.
+func StreamVersion(s Stream, requested string) string {
+	if vs, ok := s.(VersionedStream); ok && vs.Version() != "" {
+		return vs.Version()
+	}
+	return requested
+}
+
+// SupportedVersions returns the versions of the named protocol advertised by
+// the peer in the handshake, from the highest. The peers which advertise a
+// single version of every protocol report it in the feature versions.
+func (p Peer) SupportedVersions(name string) []string {
+	if versions, ok := p.ProtocolVersions[name]; ok {
+		return versions
+	}
+	if version, ok := p.FeatureVersion(name); ok {
+		return []string{version}
+	}
+	return nil
+}
+
+// VersionAccepts returns true if the handler of the protocol version handles
+// the streams opened on the requested version, which is the case for the
+// same major and not a higher minor version.
+func VersionAccepts(handler, requested string) bool {
+	h, err := semver.NewVersion(handler)
+	if err != nil {
+		return false
+	}
+	r, err := semver.NewVersion(requested)
+	if err != nil {
+		return false
+	}
+	return h.Major == r.Major && h.Minor >= r.Minor
+}
+
+// MutualVersion returns the highest of the local versions of a protocol, not
+// higher than the requested one, which is accepted by one of the remote
+// versions, and false if there is none.
+func MutualVersion(local, remote []string, requested string) (string, bool) {
+	max, err := semver.NewVersion(requested)
+	if err != nil {
+		return "", false
+	}
+
+	type version struct {
+		name string
+		v    *semver.Version
+	}
+	versions := make([]version, 0, len(local))
+	for _, l := range local {
+		v, err := semver.NewVersion(l)
+		if err != nil || max.LessThan(*v) {
+			continue
+		}
+		versions = append(versions, version{name: l, v: v})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[j].v.LessThan(*versions[i].v)
+	})
+
+	for _, v := range versions {
+		for _, r := range remote {
+			if VersionAccepts(r, v.name) {
+				return v.name, true
+			}
+		}
+	}
+	return "", false
+}
@@ -17,6 +17,7 @@ type metrics struct {
 	PongSentCount     prometheus.Counter
 	PingReceivedCount prometheus.Counter
 	PongReceivedCount prometheus.Counter
+	ProbePayloadBytes prometheus.Counter
 }
 
 func newMetrics() metrics {
@@ -47,6 +48,12 @@ func newMetrics() metrics {
 			Name:      "pong_received_count",
 			Help:      "Number of pong responses received.",
 		}),
+		ProbePayloadBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "probe_payload_bytes",
+			Help:      "Number of payload bytes transferred by bandwidth probes.",
+		}),
 	}
 }
 
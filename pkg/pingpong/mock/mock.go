@@ -9,16 +9,31 @@ import (
 	"time"
 
 	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/pingpong"
 )
 
 type Service struct {
-	pingFunc func(ctx context.Context, address infinity.Address, msgs ...string) (rtt time.Duration, err error)
+	pingFunc  func(ctx context.Context, address infinity.Address, msgs ...string) (rtt time.Duration, err error)
+	probeFunc func(ctx context.Context, address infinity.Address, size, count int) (pingpong.Measurement, error)
 }
 
 func New(pingFunc func(ctx context.Context, address infinity.Address, msgs ...string) (rtt time.Duration, err error)) *Service {
 	return &Service{pingFunc: pingFunc}
 }
 
+// WithProbeFunc sets the function called on Probe.
+func (s *Service) WithProbeFunc(f func(ctx context.Context, address infinity.Address, size, count int) (pingpong.Measurement, error)) *Service {
+	s.probeFunc = f
+	return s
+}
+
 func (s *Service) Ping(ctx context.Context, address infinity.Address, msgs ...string) (rtt time.Duration, err error) {
 	return s.pingFunc(ctx, address, msgs...)
 }
+
+func (s *Service) Probe(ctx context.Context, address infinity.Address, size, count int) (pingpong.Measurement, error) {
+	if s.probeFunc == nil {
+		return pingpong.Measurement{}, nil
+	}
+	return s.probeFunc(ctx, address, size, count)
+}
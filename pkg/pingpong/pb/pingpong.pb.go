@@ -24,6 +24,7 @@ const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
 type Ping struct {
 	Greeting string `protobuf:"bytes,1,opt,name=Greeting,proto3" json:"Greeting,omitempty"`
+	Payload  []byte `protobuf:"bytes,2,opt,name=Payload,proto3" json:"Payload,omitempty"`
 }
 
 func (m *Ping) Reset()         { *m = Ping{} }
@@ -66,8 +67,16 @@ func (m *Ping) GetGreeting() string {
 	return ""
 }
 
+func (m *Ping) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
 type Pong struct {
 	Response string `protobuf:"bytes,1,opt,name=Response,proto3" json:"Response,omitempty"`
+	Payload  []byte `protobuf:"bytes,2,opt,name=Payload,proto3" json:"Payload,omitempty"`
 }
 
 func (m *Pong) Reset()         { *m = Pong{} }
@@ -110,6 +119,13 @@ func (m *Pong) GetResponse() string {
 	return ""
 }
 
+func (m *Pong) GetPayload() []byte {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Ping)(nil), "pingpong.Ping")
 	proto.RegisterType((*Pong)(nil), "pingpong.Pong")
@@ -118,15 +134,17 @@ func init() {
 func init() { proto.RegisterFile("pingpong.proto", fileDescriptor_1cfbf639ab46154b) }
 
 var fileDescriptor_1cfbf639ab46154b = []byte{
-	// 126 bytes of a gzipped FileDescriptorProto
+	// 145 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2b, 0xc8, 0xcc, 0x4b,
-	0x2f, 0xc8, 0xcf, 0x4b, 0xd7, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x80, 0xf1, 0x95, 0x94,
+	0x2f, 0xc8, 0xcf, 0x4b, 0xd7, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x80, 0xf1, 0x95, 0x6c,
 	0xb8, 0x58, 0x02, 0x32, 0xf3, 0xd2, 0x85, 0xa4, 0xb8, 0x38, 0xdc, 0x8b, 0x52, 0x53, 0x4b, 0x32,
-	0xf3, 0xd2, 0x25, 0x18, 0x15, 0x18, 0x35, 0x38, 0x83, 0xe0, 0x7c, 0xb0, 0x9a, 0x7c, 0x88, 0x9a,
-	0xa0, 0xd4, 0xe2, 0x82, 0xfc, 0xbc, 0xe2, 0x54, 0x98, 0x1a, 0x18, 0xdf, 0x49, 0xe6, 0xc4, 0x23,
-	0x39, 0xc6, 0x0b, 0x8f, 0xe4, 0x18, 0x1f, 0x3c, 0x92, 0x63, 0x9c, 0xf0, 0x58, 0x8e, 0xe1, 0xc2,
-	0x63, 0x39, 0x86, 0x1b, 0x8f, 0xe5, 0x18, 0xa2, 0x98, 0x0a, 0x92, 0x92, 0xd8, 0xc0, 0xd6, 0x1a,
-	0x03, 0x02, 0x00, 0x00, 0xff, 0xff, 0x08, 0xee, 0x5c, 0x58, 0x88, 0x00, 0x00, 0x00,
+	0xf3, 0xd2, 0x25, 0x18, 0x15, 0x18, 0x35, 0x38, 0x83, 0xe0, 0x7c, 0x21, 0x09, 0x2e, 0xf6, 0x80,
+	0xc4, 0xca, 0x9c, 0xfc, 0xc4, 0x14, 0x09, 0x26, 0x05, 0x46, 0x0d, 0x9e, 0x20, 0x18, 0x17, 0xac,
+	0x3b, 0x1f, 0xa2, 0x3b, 0x28, 0xb5, 0xb8, 0x20, 0x3f, 0xaf, 0x38, 0x15, 0xa6, 0x1b, 0xc6, 0xc7,
+	0xad, 0xdb, 0x49, 0xe6, 0xc4, 0x23, 0x39, 0xc6, 0x0b, 0x8f, 0xe4, 0x18, 0x1f, 0x3c, 0x92, 0x63,
+	0x9c, 0xf0, 0x58, 0x8e, 0xe1, 0xc2, 0x63, 0x39, 0x86, 0x1b, 0x8f, 0xe5, 0x18, 0xa2, 0x98, 0x0a,
+	0x92, 0x92, 0xd8, 0xc0, 0x4e, 0x35, 0x06, 0x0c, 0x00, 0xd1, 0x1c, 0x43, 0xa7, 0xbc, 0x00, 0x00,
+	0x00,
 }
 
 func (m *Ping) Marshal() (dAtA []byte, err error) {
@@ -149,6 +167,13 @@ func (m *Ping) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Payload) > 0 {
+		i -= len(m.Payload)
+		copy(dAtA[i:], m.Payload)
+		i = encodeVarintPingpong(dAtA, i, uint64(len(m.Payload)))
+		i--
+		dAtA[i] = 0x12
+	}
 	if len(m.Greeting) > 0 {
 		i -= len(m.Greeting)
 		copy(dAtA[i:], m.Greeting)
@@ -179,6 +204,13 @@ func (m *Pong) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Payload) > 0 {
+		i -= len(m.Payload)
+		copy(dAtA[i:], m.Payload)
+		i = encodeVarintPingpong(dAtA, i, uint64(len(m.Payload)))
+		i--
+		dAtA[i] = 0x12
+	}
 	if len(m.Response) > 0 {
 		i -= len(m.Response)
 		copy(dAtA[i:], m.Response)
@@ -210,6 +242,10 @@ func (m *Ping) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovPingpong(uint64(l))
 	}
+	l = len(m.Payload)
+	if l > 0 {
+		n += 1 + l + sovPingpong(uint64(l))
+	}
 	return n
 }
 
@@ -223,6 +259,10 @@ func (m *Pong) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovPingpong(uint64(l))
 	}
+	l = len(m.Payload)
+	if l > 0 {
+		n += 1 + l + sovPingpong(uint64(l))
+	}
 	return n
 }
 
@@ -293,6 +333,40 @@ func (m *Ping) Unmarshal(dAtA []byte) error {
 			}
 			m.Greeting = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Payload", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPingpong
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPingpong
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPingpong
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Payload = append(m.Payload[:0], dAtA[iNdEx:postIndex]...)
+			if m.Payload == nil {
+				m.Payload = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPingpong(dAtA[iNdEx:])
@@ -378,6 +452,40 @@ func (m *Pong) Unmarshal(dAtA []byte) error {
 			}
 			m.Response = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Payload", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPingpong
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPingpong
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPingpong
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Payload = append(m.Payload[:0], dAtA[iNdEx:postIndex]...)
+			if m.Payload == nil {
+				m.Payload = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPingpong(dAtA[iNdEx:])
@@ -3,11 +3,14 @@
 // license that can be found in the LICENSE file.
 
 // Package pingpong exposes the simple ping-pong protocol
-// which measures round-trip-time with other peers.
+// which measures round-trip-time and bandwidth with other peers.
 package pingpong
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -26,8 +29,36 @@ const (
 	streamName      = "pingpong"
 )
 
+const (
+	// MaxPayloadSize is the largest payload of a single probe message.
+	MaxPayloadSize = 64 * 1024
+	// MaxProbeCount is the largest number of the probe messages.
+	MaxProbeCount = 100
+)
+
+var (
+	// ErrInvalidProbe is returned when the probe payload size or message
+	// count are out of bounds.
+	ErrInvalidProbe = errors.New("invalid probe")
+	// ErrPayloadNotEchoed is returned when the peer does not respond with
+	// the probe payload, which is the case for peers which do not support
+	// the payloads.
+	ErrPayloadNotEchoed = errors.New("payload not echoed")
+)
+
 type Interface interface {
 	Ping(ctx context.Context, address infinity.Address, msgs ...string) (rtt time.Duration, err error)
+	Probe(ctx context.Context, address infinity.Address, size, count int) (Measurement, error)
+}
+
+// Measurement is the result of a probe.
+type Measurement struct {
+	// RTT is the round-trip-time of a message without payload.
+	RTT time.Duration
+	// Bandwidth is the estimated transfer rate in bytes per second of the
+	// payloads sent to the peer and echoed back. It is zero when the
+	// transfer time could not be distinguished from the round-trip-time.
+	Bandwidth float64
 }
 
 type Service struct {
@@ -96,6 +127,78 @@ func (s *Service) Ping(ctx context.Context, address infinity.Address, msgs ...st
 	return time.Since(start), nil
 }
 
+// Probe measures the round-trip-time with an empty message and then sends
+// count messages with payloads of the given size, which are echoed by the
+// peer, to estimate the bandwidth.
+func (s *Service) Probe(ctx context.Context, address infinity.Address, size, count int) (m Measurement, err error) {
+	if size <= 0 || size > MaxPayloadSize || count <= 0 || count > MaxProbeCount {
+		return Measurement{}, ErrInvalidProbe
+	}
+
+	span, _, ctx := s.tracer.StartSpanFromContext(ctx, "pingpong-p2p-probe", s.logger)
+	defer span.Finish()
+
+	stream, err := s.streamer.NewStream(ctx, address, nil, protocolName, protocolVersion, streamName)
+	if err != nil {
+		return Measurement{}, fmt.Errorf("new stream: %w", err)
+	}
+	defer func() {
+		go stream.FullClose()
+	}()
+
+	w, r := protobuf.NewWriterAndReader(stream)
+
+	exchange := func(payload []byte) (time.Duration, error) {
+		start := time.Now()
+		if err := w.WriteMsgWithContext(ctx, &pb.Ping{
+			Payload: payload,
+		}); err != nil {
+			return 0, fmt.Errorf("write message: %w", err)
+		}
+		s.metrics.PingSentCount.Inc()
+
+		var pong pb.Pong
+		if err := r.ReadMsgWithContext(ctx, &pong); err != nil {
+			return 0, fmt.Errorf("read message: %w", err)
+		}
+		s.metrics.PongReceivedCount.Inc()
+		if !bytes.Equal(pong.Payload, payload) {
+			return 0, ErrPayloadNotEchoed
+		}
+		return time.Since(start), nil
+	}
+
+	rtt, err := exchange(nil)
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	payload := make([]byte, size)
+	if _, err := rand.Read(payload); err != nil {
+		return Measurement{}, fmt.Errorf("payload: %w", err)
+	}
+
+	// the time above the round-trip-time is spent transferring the payload
+	// in both directions
+	var transfer time.Duration
+	for i := 0; i < count; i++ {
+		d, err := exchange(payload)
+		if err != nil {
+			return Measurement{}, err
+		}
+		if d > rtt {
+			transfer += d - rtt
+		}
+	}
+	s.metrics.ProbePayloadBytes.Add(float64(2 * size * count))
+
+	m.RTT = rtt
+	if transfer > 0 {
+		m.Bandwidth = float64(2*size*count) / transfer.Seconds()
+	}
+	return m, nil
+}
+
 func (s *Service) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) error {
 	w, r := protobuf.NewWriterAndReader(stream)
 	defer stream.FullClose()
@@ -114,9 +217,15 @@ func (s *Service) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) er
 		logger.Tracef("got ping: %q", ping.Greeting)
 		s.metrics.PingReceivedCount.Inc()
 
-		if err := w.WriteMsgWithContext(ctx, &pb.Pong{
+		if len(ping.Payload) > MaxPayloadSize {
+			return fmt.Errorf("payload size %d: %w", len(ping.Payload), ErrInvalidProbe)
+		}
+
+		pong := &pb.Pong{
 			Response: "{" + ping.Greeting + "}",
-		}); err != nil {
+			Payload:  ping.Payload,
+		}
+		if err := w.WriteMsgWithContext(ctx, pong); err != nil {
 			return fmt.Errorf("write message: %w", err)
 		}
 		s.metrics.PongSentCount.Inc()
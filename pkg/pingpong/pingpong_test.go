@@ -7,6 +7,7 @@ package pingpong_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"runtime"
@@ -110,3 +111,106 @@ func TestPing(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestProbe(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+
+	server := pingpong.New(nil, logger, nil)
+	recorder := streamtest.New(streamtest.WithProtocols(server.Protocol()))
+	client := pingpong.New(recorder, logger, nil)
+
+	addr := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+	size, count := 4096, 3
+	m, err := client.Probe(context.Background(), addr, size, count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.RTT <= 0 {
+		t.Errorf("invalid RTT value %v", m.RTT)
+	}
+	if m.Bandwidth < 0 {
+		t.Errorf("invalid bandwidth value %v", m.Bandwidth)
+	}
+
+	records, err := recorder.Records(addr, "pingpong", "1.0.0", "pingpong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(records); l != 1 {
+		t.Fatalf("got %v records, want %v", l, 1)
+	}
+	record := records[0]
+
+	// the first message measures the round-trip-time without payload
+	messages, err := protobuf.ReadMessages(
+		bytes.NewReader(record.In()),
+		func() protobuf.Message { return new(pb.Ping) },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(messages); l != count+1 {
+		t.Fatalf("got %v pings, want %v", l, count+1)
+	}
+	for i, m := range messages {
+		want := size
+		if i == 0 {
+			want = 0
+		}
+		if l := len(m.(*pb.Ping).Payload); l != want {
+			t.Errorf("ping %v: got payload size %v, want %v", i, l, want)
+		}
+	}
+
+	if err := record.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProbeInvalid(t *testing.T) {
+	client := pingpong.New(nil, logging.New(ioutil.Discard, 0), nil)
+	addr := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+
+	for _, tc := range []struct {
+		size, count int
+	}{
+		{size: 0, count: 1},
+		{size: pingpong.MaxPayloadSize + 1, count: 1},
+		{size: 1, count: 0},
+		{size: 1, count: pingpong.MaxProbeCount + 1},
+	} {
+		_, err := client.Probe(context.Background(), addr, tc.size, tc.count)
+		if !errors.Is(err, pingpong.ErrInvalidProbe) {
+			t.Errorf("size %v count %v: got error %v, want %v", tc.size, tc.count, err, pingpong.ErrInvalidProbe)
+		}
+	}
+}
+
+func TestProbeNotEchoed(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+
+	// a peer which does not support the payloads
+	server := pingpong.New(nil, logger, nil)
+	spec := server.Protocol()
+	spec.StreamSpecs[0].Handler = func(ctx context.Context, p p2p.Peer, stream p2p.Stream) error {
+		defer stream.FullClose()
+		w, r := protobuf.NewWriterAndReader(stream)
+		for {
+			var ping pb.Ping
+			if err := r.ReadMsgWithContext(ctx, &ping); err != nil {
+				return nil
+			}
+			if err := w.WriteMsgWithContext(ctx, &pb.Pong{Response: ping.Greeting}); err != nil {
+				return err
+			}
+		}
+	}
+	recorder := streamtest.New(streamtest.WithProtocols(spec))
+	client := pingpong.New(recorder, logger, nil)
+
+	addr := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+	_, err := client.Probe(context.Background(), addr, 1024, 1)
+	if !errors.Is(err, pingpong.ErrPayloadNotEchoed) {
+		t.Fatalf("got error %v, want %v", err, pingpong.ErrPayloadNotEchoed)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/yanhuangpai/voyager/pkg/infinity"
@@ -39,6 +40,7 @@ type Service struct {
 	streamer                 p2p.Streamer
 	logger                   logging.Logger
 	paymentThreshold         *big.Int
+	paymentThresholdMu       sync.RWMutex
 	paymentThresholdObserver PaymentThresholdObserver
 }
 
@@ -87,7 +89,7 @@ func (s *Service) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (e
 }
 
 func (s *Service) init(ctx context.Context, p p2p.Peer) error {
-	err := s.AnnouncePaymentThreshold(ctx, p.Address, s.paymentThreshold)
+	err := s.AnnouncePaymentThreshold(ctx, p.Address, s.PaymentThreshold())
 	if err != nil {
 		s.logger.Warningf("could not send payment threshold announcement to peer %v", p.Address)
 	}
@@ -124,3 +126,19 @@ func (s *Service) AnnouncePaymentThreshold(ctx context.Context, peer infinity.Ad
 func (s *Service) SetPaymentThresholdObserver(observer PaymentThresholdObserver) {
 	s.paymentThresholdObserver = observer
 }
+
+// PaymentThreshold returns the payment threshold announced to the connected
+// peers.
+func (s *Service) PaymentThreshold() *big.Int {
+	s.paymentThresholdMu.RLock()
+	defer s.paymentThresholdMu.RUnlock()
+	return s.paymentThreshold
+}
+
+// SetPaymentThreshold changes the payment threshold announced to the newly
+// connected peers. It has to be announced to the connected peers separately.
+func (s *Service) SetPaymentThreshold(paymentThreshold *big.Int) {
+	s.paymentThresholdMu.Lock()
+	defer s.paymentThresholdMu.Unlock()
+	s.paymentThreshold = new(big.Int).Set(paymentThreshold)
+}
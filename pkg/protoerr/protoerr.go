@@ -0,0 +1,160 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package protoerr provides the errors shared by the chunk protocols, pushsync,
+// pullsync and retrieval, and their codes sent in the protocol responses, so
+// that the cause of a failure on the remote peer is known to the requester
+// without parsing the error messages.
+package protoerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+var (
+	// ErrPeerOverdrawn is the error if the balance with a peer would exceed
+	// the thresholds.
+	ErrPeerOverdrawn = errors.New("peer overdrawn")
+	// ErrInvalidChunk is the error if the chunk data does not match its
+	// address.
+	ErrInvalidChunk = infinity.ErrInvalidChunk
+	// ErrTimeout is the error if the request did not complete in time.
+	ErrTimeout = errors.New("timeout")
+	// ErrOutOfDepth is the error if the chunk is outside of the neighborhood
+	// of the peer which was expected to store it.
+	ErrOutOfDepth = errors.New("out of depth")
+	// ErrNotFound is the error if the chunk was not found.
+	ErrNotFound = storage.ErrNotFound
+	// ErrHopLimit is the error if the request was forwarded through more
+	// peers than allowed.
+	ErrHopLimit = errors.New("hop limit exceeded")
+	// ErrLoop is the error if the request was forwarded back to a peer it
+	// was already forwarded through.
+	ErrLoop = errors.New("forwarding loop")
+	// ErrNoForwarder is the error if there is no peer to forward the request
+	// to.
+	ErrNoForwarder = errors.New("no eligible forwarder")
+	// ErrUnknown is the error sent with the codes unknown to this node.
+	ErrUnknown = errors.New("unknown protocol error")
+)
+
+// Code is the error code sent in the protocol responses. The zero code means
+// no error, so that it is omitted from the successful responses.
+type Code int32
+
+const (
+	CodeNone Code = iota
+	CodeUnknown
+	CodePeerOverdrawn
+	CodeInvalidChunk
+	CodeTimeout
+	CodeOutOfDepth
+	CodeNotFound
+	CodeHopLimit
+	CodeLoop
+	CodeNoForwarder
+)
+
+var codeErrors = map[Code]error{
+	CodePeerOverdrawn: ErrPeerOverdrawn,
+	CodeInvalidChunk:  ErrInvalidChunk,
+	CodeTimeout:       ErrTimeout,
+	CodeOutOfDepth:    ErrOutOfDepth,
+	CodeNotFound:      ErrNotFound,
+	CodeHopLimit:      ErrHopLimit,
+	CodeLoop:          ErrLoop,
+	CodeNoForwarder:   ErrNoForwarder,
+}
+
+var codeNames = map[Code]string{
+	CodeNone:          "none",
+	CodeUnknown:       "unknown",
+	CodePeerOverdrawn: "peer_overdrawn",
+	CodeInvalidChunk:  "invalid_chunk",
+	CodeTimeout:       "timeout",
+	CodeOutOfDepth:    "out_of_depth",
+	CodeNotFound:      "not_found",
+	CodeHopLimit:      "hop_limit",
+	CodeLoop:          "loop",
+	CodeNoForwarder:   "no_forwarder",
+}
+
+// String returns the name of the code, used also as the metrics label.
+func (c Code) String() string {
+	if n, ok := codeNames[c]; ok {
+		return n
+	}
+	return codeNames[CodeUnknown]
+}
+
+// Err returns the error of the code, nil for CodeNone and ErrUnknown for the
+// unknown codes.
+func (c Code) Err() error {
+	if c == CodeNone {
+		return nil
+	}
+	if err, ok := codeErrors[c]; ok {
+		return err
+	}
+	return ErrUnknown
+}
+
+// CodeOf returns the code of the error, CodeNone for nil and CodeUnknown for
+// the errors without a code.
+func CodeOf(err error) Code {
+	switch {
+	case err == nil:
+		return CodeNone
+	case errors.Is(err, ErrPeerOverdrawn),
+		errors.Is(err, accounting.ErrOverdraft),
+		errors.Is(err, accounting.ErrDisconnectThresholdExceeded):
+		return CodePeerOverdrawn
+	case errors.Is(err, ErrInvalidChunk):
+		return CodeInvalidChunk
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, ErrOutOfDepth):
+		return CodeOutOfDepth
+	case errors.Is(err, ErrNotFound):
+		return CodeNotFound
+	case errors.Is(err, ErrHopLimit):
+		return CodeHopLimit
+	case errors.Is(err, ErrLoop):
+		return CodeLoop
+	case errors.Is(err, ErrNoForwarder):
+		return CodeNoForwarder
+	}
+	return CodeUnknown
+}
+
+// Error is the error received from a peer in a protocol response.
+type Error struct {
+	Peer infinity.Address
+	Code Code
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("peer %s: %v", e.Peer, e.Code.Err())
+}
+
+// Unwrap returns the error of the code, so that errors.Is can be used to check
+// the cause.
+func (e *Error) Unwrap() error {
+	return e.Code.Err()
+}
+
+// FromCode returns the error received from the peer with the code in a
+// protocol response, nil for CodeNone.
+func FromCode(peer infinity.Address, code int32) error {
+	if Code(code) == CodeNone {
+		return nil
+	}
+	return &Error{Peer: peer, Code: Code(code)}
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package protoerr_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/accounting"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/protoerr"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+func TestCodeOf(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want protoerr.Code
+	}{
+		{err: nil, want: protoerr.CodeNone},
+		{err: errors.New("some error"), want: protoerr.CodeUnknown},
+		{err: fmt.Errorf("reserve: %w", accounting.ErrOverdraft), want: protoerr.CodePeerOverdrawn},
+		{err: protoerr.ErrPeerOverdrawn, want: protoerr.CodePeerOverdrawn},
+		{err: infinity.ErrInvalidChunk, want: protoerr.CodeInvalidChunk},
+		{err: fmt.Errorf("retrieval: %w", context.DeadlineExceeded), want: protoerr.CodeTimeout},
+		{err: protoerr.ErrOutOfDepth, want: protoerr.CodeOutOfDepth},
+		{err: storage.ErrNotFound, want: protoerr.CodeNotFound},
+		{err: protoerr.ErrHopLimit, want: protoerr.CodeHopLimit},
+		{err: protoerr.ErrLoop, want: protoerr.CodeLoop},
+		{err: fmt.Errorf("push: %w", protoerr.ErrNoForwarder), want: protoerr.CodeNoForwarder},
+	} {
+		if got := protoerr.CodeOf(tc.err); got != tc.want {
+			t.Errorf("error %v: got code %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestFromCode(t *testing.T) {
+	peer := infinity.MustParseHexAddress("abcd")
+
+	if err := protoerr.FromCode(peer, int32(protoerr.CodeNone)); err != nil {
+		t.Fatalf("got error %v, want none", err)
+	}
+
+	for _, code := range []protoerr.Code{
+		protoerr.CodePeerOverdrawn,
+		protoerr.CodeInvalidChunk,
+		protoerr.CodeTimeout,
+		protoerr.CodeOutOfDepth,
+		protoerr.CodeNotFound,
+		protoerr.CodeHopLimit,
+		protoerr.CodeLoop,
+		protoerr.CodeNoForwarder,
+	} {
+		err := protoerr.FromCode(peer, int32(code))
+		if !errors.Is(err, code.Err()) {
+			t.Errorf("code %v: got error %v, want %v", code, err, code.Err())
+		}
+		// the code survives forwarding the error to the next peer
+		if got := protoerr.CodeOf(fmt.Errorf("forward: %w", err)); got != code {
+			t.Errorf("code %v: got code %v of the received error", code, got)
+		}
+		var perr *protoerr.Error
+		if !errors.As(err, &perr) || !perr.Peer.Equal(peer) {
+			t.Errorf("code %v: got error %v, want error from peer %s", code, err, peer)
+		}
+	}
+
+	if err := protoerr.FromCode(peer, 100); !errors.Is(err, protoerr.ErrUnknown) {
+		t.Errorf("got error %v, want %v", err, protoerr.ErrUnknown)
+	}
+}
@@ -3,3 +3,21 @@
 // license that can be found in the LICENSE file.
 
 package pullsync
+
+const (
+	MaxIncidents               = maxIncidents
+	IncidentsBlocklistDuration = incidentsBlocklistDuration
+	IncidentsTTL               = incidentsTTL
+	ProtocolVersion            = protocolVersion
+	DeprecatedProtocolVersion  = deprecatedProtocolVersion
+	FilteredPrefixSize         = filteredPrefixSize
+	MaxFilterChunks            = maxFilterChunks
+)
+
+var (
+	ErrOfferTooLarge = errOfferTooLarge
+
+	HeldBins = heldBins
+	TimeNow  = &timeNow
+	MaxPage  = &maxPage
+)
@@ -0,0 +1,173 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pullsync
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/bloom"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+const (
+	// maxFilterChunks is the maximal number of the held chunks added to
+	// the filter sent with a range request.
+	maxFilterChunks = 1024
+	// filterFalsePositiveRate is the rate of the chunks wrongly taken for
+	// held by the filter. They are offered by their prefixes as the held
+	// ones, and the syncer wants them as they are not in the held set it
+	// built the filter from.
+	filterFalsePositiveRate = 0.001
+	// maxFilterSize is the maximal size of the received filter, the larger
+	// ones are ignored.
+	maxFilterSize = 64 * 1024
+	// filteredPrefixSize is the size of the address prefixes by which the
+	// chunks in the filter are offered.
+	filteredPrefixSize = 8
+	// heldCacheTTL is the time for which the held chunks of a bin are
+	// reused for the filters before the bin is scanned again.
+	heldCacheTTL = time.Minute
+)
+
+// heldChunks are the most recent chunks of a local bin added to the filters.
+type heldChunks struct {
+	addrs []infinity.Address
+	at    time.Time
+}
+
+// SetOfferFilter makes the syncer send the Bloom filter of the chunks it holds
+// with the range requests, so that the peers offer them by the prefixes of
+// their addresses only. The base is the overlay address of this node. The
+// peers on the older versions of the protocol ignore the filter and offer all
+// chunks.
+func (s *Syncer) SetOfferFilter(base infinity.Address) {
+	s.base = base
+}
+
+// heldFilter returns the encoded filter of the chunks held by this node which
+// are in the bin of the peer and nil if there are none, with the set of the
+// address prefixes of the chunks in it.
+func (s *Syncer) heldFilter(ctx context.Context, peer infinity.Address, bin uint8) ([]byte, map[string]struct{}, error) {
+	var held []infinity.Address
+	po := infinity.Proximity(s.base.Bytes(), peer.Bytes())
+	from, to := heldBins(s.base, peer, bin)
+	for b := int(from); b <= int(to) && len(held) < maxFilterChunks; b++ {
+		chs, err := s.heldChunks(ctx, uint8(b))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, a := range chs {
+			// the local bin of the peer holds the chunks of all its
+			// bins past it
+			if bin > po && infinity.Proximity(a.Bytes(), peer.Bytes()) != bin {
+				continue
+			}
+			held = append(held, a)
+			if len(held) == maxFilterChunks {
+				break
+			}
+		}
+	}
+	if len(held) == 0 {
+		return nil, nil, nil
+	}
+
+	var tweak [4]byte
+	if _, err := rand.Read(tweak[:]); err != nil {
+		return nil, nil, fmt.Errorf("crypto rand: %w", err)
+	}
+	f := bloom.New(len(held), filterFalsePositiveRate, binary.BigEndian.Uint32(tweak[:]))
+	prefixes := make(map[string]struct{}, len(held))
+	for _, a := range held {
+		f.Add(a)
+		prefixes[filteredPrefix(a.Bytes())] = struct{}{}
+	}
+	b, err := f.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, prefixes, nil
+}
+
+// heldChunks returns the most recent chunks of the local bin, which are the
+// ones the peers offer when the live intervals are synced. The bin is scanned
+// again only once the previous scan is older than heldCacheTTL.
+func (s *Syncer) heldChunks(ctx context.Context, bin uint8) ([]infinity.Address, error) {
+	s.heldMtx.Lock()
+	defer s.heldMtx.Unlock()
+
+	if h, ok := s.held[bin]; ok && time.Since(h.at) < heldCacheTTL {
+		return h.addrs, nil
+	}
+
+	s.metrics.DbOpsCounter.Inc()
+	cursors, err := s.storage.Cursors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cursors: %w", err)
+	}
+	var addrs []infinity.Address
+	if int(bin) < len(cursors) && cursors[bin] > 0 {
+		var from uint64
+		if cursors[bin] > maxFilterChunks {
+			from = cursors[bin] - maxFilterChunks + 1
+		}
+		s.metrics.DbOpsCounter.Inc()
+		addrs, _, err = s.storage.IntervalChunks(ctx, bin, from, cursors[bin], maxFilterChunks)
+		if err != nil {
+			return nil, fmt.Errorf("interval chunks: %w", err)
+		}
+	}
+	s.held[bin] = heldChunks{addrs: addrs, at: time.Now()}
+	return addrs, nil
+}
+
+// filteredPrefix returns the prefix by which the chunk with the address is
+// offered if it is in the filter.
+func filteredPrefix(addr []byte) string {
+	if len(addr) < filteredPrefixSize {
+		return ""
+	}
+	return string(addr[:filteredPrefixSize])
+}
+
+// heldBins returns the range of the local bins of this node with the base
+// address which hold the chunks that are in the bin of the peer.
+func heldBins(base, peer infinity.Address, bin uint8) (from, to uint8) {
+	po := infinity.Proximity(base.Bytes(), peer.Bytes())
+	switch {
+	case bin < po:
+		// closer to both by the same proximity
+		return bin, bin
+	case bin == po:
+		// the chunk shares the bit in which the base and the peer
+		// differ with the base
+		return po + 1, infinity.MaxPO
+	default:
+		// as close to the base as the peer is
+		return po, po
+	}
+}
+
+// offerFilter returns the filter of the chunks held by the peer received with
+// the range request and nil if it is not sent or it is invalid.
+func (s *Syncer) offerFilter(b []byte) *bloom.Filter {
+	if len(b) == 0 {
+		return nil
+	}
+	if len(b) > maxFilterSize {
+		s.logger.Tracef("pullsync: ignoring filter of %d bytes", len(b))
+		return nil
+	}
+	f := new(bloom.Filter)
+	if err := f.UnmarshalBinary(b); err != nil {
+		s.logger.Tracef("pullsync: ignoring filter: %v", err)
+		return nil
+	}
+	return f
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pullsync
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+)
+
+const (
+	// maxIncidents is the number of the unsolicited and the invalid chunks
+	// delivered by a peer after which the peer is blocklisted.
+	maxIncidents = 3
+	// incidentsBlocklistDuration is the duration for which the peers
+	// delivering too many unsolicited or invalid chunks are blocklisted.
+	incidentsBlocklistDuration = time.Hour
+	// incidentsTTL is the time after the last incident of a peer for which
+	// its incidents are kept.
+	incidentsTTL = 24 * time.Hour
+)
+
+var timeNow = time.Now
+
+// Types of the incidents.
+const (
+	incidentUnsolicited = "unsolicited"
+	incidentInvalid     = "invalid"
+)
+
+// IncidentsGetter gets the incidents of the peers.
+type IncidentsGetter interface {
+	// Incidents returns the incidents of all peers which had any.
+	Incidents() []Incidents
+}
+
+// DebugService gets the pull sync state of the node for debugging.
+type DebugService interface {
+	CursorsGetter
+	IncidentsGetter
+}
+
+// Incidents are the numbers of the unsolicited and the invalid chunks
+// delivered by a peer while syncing, and the number of the times the peer was
+// blocklisted for them.
+type Incidents struct {
+	Peer        infinity.Address
+	Unsolicited uint64
+	Invalid     uint64
+	Sanctions   uint64
+	Last        time.Time
+}
+
+// incidents are the incidents of the peers. A peer is blocklisted every time
+// it has maxIncidents incidents since it was blocklisted the last time. The
+// incidents of a peer are forgotten incidentsTTL after its last incident.
+type incidents struct {
+	mu      sync.Mutex
+	peers   map[string]*Incidents
+	pending map[string]int // incidents since the last sanction
+}
+
+func newIncidents() *incidents {
+	return &incidents{
+		peers:   make(map[string]*Incidents),
+		pending: make(map[string]int),
+	}
+}
+
+// add records the incident of the type and reports whether the peer is to be
+// sanctioned for it.
+func (i *incidents) add(peer infinity.Address, typ string) (sanction bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := timeNow()
+	i.expire(now)
+
+	key := peer.ByteString()
+	p, ok := i.peers[key]
+	if !ok {
+		p = &Incidents{Peer: peer}
+		i.peers[key] = p
+	}
+	switch typ {
+	case incidentUnsolicited:
+		p.Unsolicited++
+	case incidentInvalid:
+		p.Invalid++
+	}
+	p.Last = now
+
+	i.pending[key]++
+	if i.pending[key] < maxIncidents {
+		return false
+	}
+	i.pending[key] = 0
+	p.Sanctions++
+	return true
+}
+
+// all returns the incidents of all peers sorted by the peer addresses.
+func (i *incidents) all() []Incidents {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.expire(timeNow())
+
+	all := make([]Incidents, 0, len(i.peers))
+	for _, p := range i.peers {
+		all = append(all, *p)
+	}
+	sort.Slice(all, func(a, b int) bool {
+		return bytes.Compare(all[a].Peer.Bytes(), all[b].Peer.Bytes()) < 0
+	})
+	return all
+}
+
+// expire removes the incidents of the peers which had none for incidentsTTL.
+// It must be called with the mutex held.
+func (i *incidents) expire(now time.Time) {
+	for key, p := range i.peers {
+		if now.Sub(p.Last) > incidentsTTL {
+			delete(i.peers, key)
+			delete(i.pending, key)
+		}
+	}
+}
+
+// SetBlocklister sets the blocklister of the peers which deliver too many
+// unsolicited or invalid chunks. The peers are not blocklisted if it is not
+// set.
+func (s *Syncer) SetBlocklister(b p2p.Disconnecter) {
+	s.blocklister = b
+}
+
+// Incidents returns the incidents of all peers which delivered unsolicited or
+// invalid chunks.
+func (s *Syncer) Incidents() []Incidents {
+	return s.incidents.all()
+}
+
+// incident records the incident of the peer and blocklists the peer if it has
+// too many of them.
+func (s *Syncer) incident(peer infinity.Address, typ string) {
+	s.metrics.Incidents.WithLabelValues(typ).Inc()
+	if !s.incidents.add(peer, typ) || s.blocklister == nil {
+		return
+	}
+
+	s.metrics.Sanctions.Inc()
+	s.logger.Warningf("pullsync: blocklisting peer %s: too many unsolicited or invalid chunks", peer)
+	if err := s.blocklister.Blocklist(peer, incidentsBlocklistDuration); err != nil {
+		s.logger.Debugf("pullsync: blocklist peer %s: %v", peer, err)
+	}
+}
@@ -12,8 +12,13 @@ import (
 type metrics struct {
 	OfferCounter    prometheus.Counter // number of chunks offered
 	WantCounter     prometheus.Counter // number of chunks wanted
+	SkipCounter     prometheus.Counter // number of chunks skipped by the want policy
+	FilteredCounter prometheus.Counter // number of chunks left out of the offers as held by the peers
 	DeliveryCounter prometheus.Counter // number of chunk deliveries
 	DbOpsCounter    prometheus.Counter // number of db ops
+	PeerErrors      *prometheus.CounterVec
+	Incidents       *prometheus.CounterVec // number of unsolicited and invalid chunks delivered by the peers
+	Sanctions       prometheus.Counter     // number of peers blocklisted for the incidents
 }
 
 func newMetrics() metrics {
@@ -32,6 +37,18 @@ func newMetrics() metrics {
 			Name:      "chunks_wanted",
 			Help:      "Total chunks wanted.",
 		}),
+		SkipCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "chunks_skipped",
+			Help:      "Total chunks skipped by the want policy.",
+		}),
+		FilteredCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "chunks_filtered",
+			Help:      "Total chunks left out of the offers as held by the peers.",
+		}),
 		DeliveryCounter: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: m.Namespace,
 			Subsystem: subsystem,
@@ -43,7 +60,32 @@ func newMetrics() metrics {
 			Subsystem: subsystem,
 			Name:      "db_ops",
 			Help:      "Total Db Ops.",
-		})}
+		}),
+		PeerErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: m.Namespace,
+				Subsystem: subsystem,
+				Name:      "peer_errors",
+				Help:      "Number of the failed range requests reported by the peers instead of an offer, by the error code.",
+			},
+			[]string{"code"},
+		),
+		Incidents: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: m.Namespace,
+				Subsystem: subsystem,
+				Name:      "incidents",
+				Help:      "Number of the unsolicited and the invalid chunks delivered by the peers, by the type.",
+			},
+			[]string{"type"},
+		),
+		Sanctions: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "sanctions",
+			Help:      "Number of the times the peers were blocklisted for the unsolicited or the invalid chunks.",
+		}),
+	}
 }
 
 func (s *Syncer) Metrics() []prometheus.Collector {
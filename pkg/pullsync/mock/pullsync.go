@@ -13,7 +13,11 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/pullsync"
 )
 
-var _ pullsync.Interface = (*PullSyncMock)(nil)
+var (
+	_ pullsync.Interface     = (*PullSyncMock)(nil)
+	_ pullsync.CursorsGetter = (*PullSyncMock)(nil)
+	_ pullsync.DebugService  = (*PullSyncMock)(nil)
+)
 
 func WithCursors(v []uint64) Option {
 	return optionFunc(func(p *PullSyncMock) {
@@ -21,6 +25,31 @@ func WithCursors(v []uint64) Option {
 	})
 }
 
+// WithLocalCursors sets the local cursors returned by Cursors.
+func WithLocalCursors(v []uint64) Option {
+	return optionFunc(func(p *PullSyncMock) {
+		p.localCursors = v
+	})
+}
+
+// WithPeerCursors sets cursors returned by GetCursors for a specific peer,
+// cursors set by WithCursors are returned for the other peers.
+func WithPeerCursors(peer infinity.Address, v []uint64) Option {
+	return optionFunc(func(p *PullSyncMock) {
+		if p.peerCursors == nil {
+			p.peerCursors = make(map[string][]uint64)
+		}
+		p.peerCursors[peer.String()] = v
+	})
+}
+
+// WithIncidents sets the incidents of the peers returned by Incidents.
+func WithIncidents(v ...pullsync.Incidents) Option {
+	return optionFunc(func(p *PullSyncMock) {
+		p.incidents = v
+	})
+}
+
 // WithAutoReply means that the pull syncer will automatically reply
 // to incoming range requests with a top = from+limit.
 // This is in order to force the requester to request a subsequent range.
@@ -80,7 +109,10 @@ type PullSyncMock struct {
 	mtx             sync.Mutex
 	syncCalls       []SyncCall
 	cursors         []uint64
+	localCursors    []uint64
+	peerCursors     map[string][]uint64
 	getCursorsPeers []infinity.Address
+	incidents       []pullsync.Incidents
 	autoReply       bool
 	blockLiveSync   bool
 	liveSyncReplies []uint64
@@ -194,9 +226,20 @@ func (p *PullSyncMock) GetCursors(_ context.Context, peer infinity.Address) ([]u
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
 	p.getCursorsPeers = append(p.getCursorsPeers, peer)
+	if c, ok := p.peerCursors[peer.String()]; ok {
+		return c, nil
+	}
 	return p.cursors, nil
 }
 
+func (p *PullSyncMock) Cursors(_ context.Context) ([]uint64, error) {
+	return p.localCursors, nil
+}
+
+func (p *PullSyncMock) Incidents() []pullsync.Incidents {
+	return p.incidents
+}
+
 func (p *PullSyncMock) SyncCalls(peer infinity.Address) (res []SyncCall) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
@@ -191,9 +191,10 @@ func (m *Cancel) GetRuid() uint32 {
 }
 
 type GetRange struct {
-	Bin  int32  `protobuf:"varint,1,opt,name=Bin,proto3" json:"Bin,omitempty"`
-	From uint64 `protobuf:"varint,2,opt,name=From,proto3" json:"From,omitempty"`
-	To   uint64 `protobuf:"varint,3,opt,name=To,proto3" json:"To,omitempty"`
+	Bin    int32  `protobuf:"varint,1,opt,name=Bin,proto3" json:"Bin,omitempty"`
+	From   uint64 `protobuf:"varint,2,opt,name=From,proto3" json:"From,omitempty"`
+	To     uint64 `protobuf:"varint,3,opt,name=To,proto3" json:"To,omitempty"`
+	Filter []byte `protobuf:"bytes,4,opt,name=Filter,proto3" json:"Filter,omitempty"`
 }
 
 func (m *GetRange) Reset()         { *m = GetRange{} }
@@ -250,9 +251,18 @@ func (m *GetRange) GetTo() uint64 {
 	return 0
 }
 
+func (m *GetRange) GetFilter() []byte {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
 type Offer struct {
-	Topmost uint64 `protobuf:"varint,1,opt,name=Topmost,proto3" json:"Topmost,omitempty"`
-	Hashes  []byte `protobuf:"bytes,2,opt,name=Hashes,proto3" json:"Hashes,omitempty"`
+	Topmost   uint64 `protobuf:"varint,1,opt,name=Topmost,proto3" json:"Topmost,omitempty"`
+	Hashes    []byte `protobuf:"bytes,2,opt,name=Hashes,proto3" json:"Hashes,omitempty"`
+	ErrorCode int32  `protobuf:"varint,3,opt,name=ErrorCode,proto3" json:"ErrorCode,omitempty"`
+	Filtered  []byte `protobuf:"bytes,4,opt,name=Filtered,proto3" json:"Filtered,omitempty"`
 }
 
 func (m *Offer) Reset()         { *m = Offer{} }
@@ -302,6 +312,20 @@ func (m *Offer) GetHashes() []byte {
 	return nil
 }
 
+func (m *Offer) GetErrorCode() int32 {
+	if m != nil {
+		return m.ErrorCode
+	}
+	return 0
+}
+
+func (m *Offer) GetFiltered() []byte {
+	if m != nil {
+		return m.Filtered
+	}
+	return nil
+}
+
 type Want struct {
 	BitVector []byte `protobuf:"bytes,1,opt,name=BitVector,proto3" json:"BitVector,omitempty"`
 }
@@ -412,26 +436,28 @@ func init() {
 func init() { proto.RegisterFile("pullsync.proto", fileDescriptor_d1dee042cf9c065c) }
 
 var fileDescriptor_d1dee042cf9c065c = []byte{
-	// 295 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x90, 0xbf, 0x4e, 0xf3, 0x30,
-	0x10, 0xc0, 0xeb, 0x24, 0xed, 0xd7, 0xef, 0x54, 0x2a, 0xe4, 0x01, 0x45, 0xa8, 0x32, 0x95, 0xc5,
-	0xd0, 0x89, 0x85, 0x05, 0x36, 0x9a, 0x56, 0xc0, 0x86, 0x64, 0x22, 0x90, 0xd8, 0xdc, 0xc4, 0x85,
-	0x88, 0xd4, 0x8e, 0x6c, 0x07, 0x29, 0x6f, 0xc1, 0x63, 0x31, 0x76, 0x64, 0x44, 0xc9, 0x8b, 0xa0,
-	0x98, 0x44, 0x2c, 0x4c, 0xfe, 0xfd, 0xee, 0x7c, 0x7f, 0x74, 0x30, 0x2d, 0xca, 0x3c, 0x37, 0x95,
-	0x4c, 0xce, 0x0a, 0xad, 0xac, 0xc2, 0xe3, 0xde, 0xe9, 0x10, 0xfc, 0xfb, 0x4a, 0xd2, 0x13, 0xf0,
-	0x97, 0xc9, 0x2b, 0x0e, 0xe1, 0xdf, 0xaa, 0xd4, 0x46, 0x69, 0x13, 0xa2, 0xb9, 0xbf, 0x08, 0x58,
-	0xaf, 0xf4, 0x18, 0x02, 0x56, 0x66, 0x29, 0xc6, 0x3f, 0x6f, 0x88, 0xe6, 0x68, 0x71, 0xc0, 0x1c,
-	0xd3, 0x19, 0x8c, 0x56, 0x5c, 0x26, 0x22, 0xff, 0x33, 0x7b, 0x05, 0xe3, 0x1b, 0x61, 0x19, 0x97,
-	0xcf, 0x02, 0x1f, 0x82, 0x1f, 0x65, 0xd2, 0xa5, 0x87, 0xac, 0xc5, 0xb6, 0xe2, 0x5a, 0xab, 0x5d,
-	0xe8, 0xcd, 0xd1, 0x22, 0x60, 0x8e, 0xf1, 0x14, 0xbc, 0x58, 0x85, 0xbe, 0x8b, 0x78, 0xb1, 0xa2,
-	0x97, 0x30, 0xbc, 0xdb, 0x6e, 0x85, 0x6e, 0xd7, 0x8b, 0x55, 0xb1, 0x53, 0xc6, 0xba, 0x16, 0x01,
-	0xeb, 0x15, 0x1f, 0xc1, 0xe8, 0x96, 0x9b, 0x17, 0x61, 0x5c, 0xa3, 0x09, 0xeb, 0x8c, 0x9e, 0x42,
-	0xf0, 0xc8, 0xa5, 0xc5, 0x33, 0xf8, 0x1f, 0x65, 0xf6, 0x41, 0x24, 0x56, 0x69, 0x57, 0x3b, 0x61,
-	0xbf, 0x01, 0x7a, 0x01, 0xe3, 0xb5, 0xc8, 0xb3, 0x37, 0xa1, 0xab, 0x76, 0xc6, 0x32, 0x4d, 0xb5,
-	0x30, 0xa6, 0xfb, 0xd7, 0x6b, 0xbb, 0xea, 0x9a, 0x5b, 0xde, 0x4d, 0x70, 0x1c, 0xcd, 0x3e, 0x6a,
-	0x82, 0xf6, 0x35, 0x41, 0x5f, 0x35, 0x41, 0xef, 0x0d, 0x19, 0xec, 0x1b, 0x32, 0xf8, 0x6c, 0xc8,
-	0xe0, 0xc9, 0x2b, 0x36, 0x9b, 0x91, 0xbb, 0xf6, 0xf9, 0x77, 0x00, 0x00, 0x00, 0xff, 0xff, 0xe5,
-	0x7c, 0x69, 0x94, 0x7f, 0x01, 0x00, 0x00,
+	// 326 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x91, 0xbd, 0x4e, 0xc3, 0x30,
+	0x14, 0x85, 0xeb, 0xfc, 0x94, 0x70, 0x55, 0x2a, 0xe4, 0x01, 0x45, 0x55, 0x15, 0x22, 0x8b, 0x21,
+	0x13, 0x0b, 0x0b, 0x6b, 0x7f, 0x28, 0x6c, 0x48, 0xa6, 0x02, 0xc4, 0x96, 0x26, 0x2e, 0x44, 0xa4,
+	0x76, 0x64, 0xbb, 0x48, 0x7d, 0x0b, 0x1e, 0x8b, 0xb1, 0x23, 0x23, 0x6a, 0x5e, 0x04, 0xd9, 0x4d,
+	0xe8, 0xc2, 0xe4, 0xf3, 0xdd, 0xeb, 0x7b, 0xcf, 0xb1, 0x0c, 0xfd, 0x6a, 0x5d, 0x96, 0x6a, 0xc3,
+	0xb3, 0xcb, 0x4a, 0x0a, 0x2d, 0x70, 0xd0, 0x32, 0xf1, 0xc1, 0x7d, 0xd8, 0x70, 0x72, 0x0e, 0xee,
+	0x28, 0x7b, 0xc7, 0x21, 0x1c, 0x4d, 0xd6, 0x52, 0x09, 0xa9, 0x42, 0x14, 0xbb, 0x89, 0x47, 0x5b,
+	0x24, 0x03, 0xf0, 0xe8, 0xba, 0xc8, 0x31, 0xde, 0x9f, 0x21, 0x8a, 0x51, 0x72, 0x42, 0xad, 0x26,
+	0x43, 0xe8, 0x4e, 0x52, 0x9e, 0xb1, 0xf2, 0xdf, 0xee, 0x33, 0x04, 0xb7, 0x4c, 0xd3, 0x94, 0xbf,
+	0x32, 0x7c, 0x0a, 0xee, 0xb8, 0xe0, 0xb6, 0xed, 0x53, 0x23, 0xcd, 0xc4, 0x4c, 0x8a, 0x55, 0xe8,
+	0xc4, 0x28, 0xf1, 0xa8, 0xd5, 0xb8, 0x0f, 0xce, 0x5c, 0x84, 0xae, 0xad, 0x38, 0x73, 0x81, 0xcf,
+	0xa0, 0x3b, 0x2b, 0x4a, 0xcd, 0x64, 0xe8, 0xc5, 0x28, 0xe9, 0xd1, 0x86, 0x88, 0x02, 0xff, 0x7e,
+	0xb9, 0x64, 0xd2, 0xc4, 0x9e, 0x8b, 0x6a, 0x25, 0x94, 0xb6, 0xab, 0x3d, 0xda, 0xa2, 0x19, 0xbd,
+	0x4b, 0xd5, 0x1b, 0x53, 0xd6, 0xa0, 0x47, 0x1b, 0xc2, 0x43, 0x38, 0xbe, 0x91, 0x52, 0xc8, 0x89,
+	0xc8, 0x99, 0x75, 0xf2, 0xe9, 0xa1, 0x80, 0x07, 0x10, 0xec, 0x2d, 0x58, 0xde, 0x58, 0xfe, 0x31,
+	0xb9, 0x00, 0xef, 0x29, 0xe5, 0xda, 0x6c, 0x18, 0x17, 0xfa, 0x91, 0x65, 0x5a, 0x48, 0xeb, 0xda,
+	0xa3, 0x87, 0x02, 0xb9, 0x86, 0x60, 0xca, 0xca, 0xe2, 0x83, 0xc9, 0x8d, 0x49, 0x37, 0xca, 0x73,
+	0xc9, 0x94, 0x6a, 0xee, 0xb5, 0x68, 0x1e, 0x3f, 0x4d, 0x75, 0xda, 0x64, 0xb3, 0x7a, 0x3c, 0xfc,
+	0xda, 0x45, 0x68, 0xbb, 0x8b, 0xd0, 0xcf, 0x2e, 0x42, 0x9f, 0x75, 0xd4, 0xd9, 0xd6, 0x51, 0xe7,
+	0xbb, 0x8e, 0x3a, 0x2f, 0x4e, 0xb5, 0x58, 0x74, 0xed, 0xff, 0x5d, 0xfd, 0x0e, 0x00, 0x5a, 0xb0,
+	0xac, 0x04, 0xd1, 0x01, 0x00, 0x00,
 }
 
 func (m *Syn) Marshal() (dAtA []byte, err error) {
@@ -574,6 +600,13 @@ func (m *GetRange) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Filter) > 0 {
+		i -= len(m.Filter)
+		copy(dAtA[i:], m.Filter)
+		i = encodeVarintPullsync(dAtA, i, uint64(len(m.Filter)))
+		i--
+		dAtA[i] = 0x22
+	}
 	if m.To != 0 {
 		i = encodeVarintPullsync(dAtA, i, uint64(m.To))
 		i--
@@ -612,6 +645,18 @@ func (m *Offer) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Filtered) > 0 {
+		i -= len(m.Filtered)
+		copy(dAtA[i:], m.Filtered)
+		i = encodeVarintPullsync(dAtA, i, uint64(len(m.Filtered)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if m.ErrorCode != 0 {
+		i = encodeVarintPullsync(dAtA, i, uint64(m.ErrorCode))
+		i--
+		dAtA[i] = 0x18
+	}
 	if len(m.Hashes) > 0 {
 		i -= len(m.Hashes)
 		copy(dAtA[i:], m.Hashes)
@@ -769,6 +814,10 @@ func (m *GetRange) Size() (n int) {
 	if m.To != 0 {
 		n += 1 + sovPullsync(uint64(m.To))
 	}
+	l = len(m.Filter)
+	if l > 0 {
+		n += 1 + l + sovPullsync(uint64(l))
+	}
 	return n
 }
 
@@ -785,6 +834,13 @@ func (m *Offer) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovPullsync(uint64(l))
 	}
+	if m.ErrorCode != 0 {
+		n += 1 + sovPullsync(uint64(m.ErrorCode))
+	}
+	l = len(m.Filtered)
+	if l > 0 {
+		n += 1 + l + sovPullsync(uint64(l))
+	}
 	return n
 }
 
@@ -1236,6 +1292,40 @@ func (m *GetRange) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Filter", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPullsync
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPullsync
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPullsync
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Filter = append(m.Filter[:0], dAtA[iNdEx:postIndex]...)
+			if m.Filter == nil {
+				m.Filter = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPullsync(dAtA[iNdEx:])
@@ -1342,6 +1432,59 @@ func (m *Offer) Unmarshal(dAtA []byte) error {
 				m.Hashes = []byte{}
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ErrorCode", wireType)
+			}
+			m.ErrorCode = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPullsync
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ErrorCode |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Filtered", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPullsync
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPullsync
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPullsync
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Filtered = append(m.Filtered[:0], dAtA[iNdEx:postIndex]...)
+			if m.Filtered == nil {
+				m.Filtered = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPullsync(dAtA[iNdEx:])
@@ -0,0 +1,44 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pullsync
+
+import (
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+// WantPolicy decides whether a chunk offered by a peer should be requested.
+// It is consulted only for the chunks which are not already stored. Chunks
+// which are not wanted are skipped and are not offered again for the same
+// interval.
+type WantPolicy func(addr infinity.Address) bool
+
+// DepthWantPolicy returns a policy which wants only the chunks within the
+// neighborhood depth of the base address.
+func DepthWantPolicy(base infinity.Address, depth func() uint8) WantPolicy {
+	return func(addr infinity.Address) bool {
+		return infinity.Proximity(base.Bytes(), addr.Bytes()) >= depth()
+	}
+}
+
+// CapacityWantPolicy returns a policy which wants the chunks only while the
+// number of the stored chunks is below the capacity.
+func CapacityWantPolicy(count func() uint64, capacity uint64) WantPolicy {
+	return func(_ infinity.Address) bool {
+		return count() < capacity
+	}
+}
+
+// AllWantPolicies returns a policy which wants the chunks wanted by all of
+// the given policies.
+func AllWantPolicies(policies ...WantPolicy) WantPolicy {
+	return func(addr infinity.Address) bool {
+		for _, p := range policies {
+			if !p(addr) {
+				return false
+			}
+		}
+		return true
+	}
+}
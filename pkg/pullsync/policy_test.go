@@ -0,0 +1,48 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pullsync_test
+
+import (
+	"testing"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity/test"
+	"github.com/yanhuangpai/voyager/pkg/pullsync"
+)
+
+func TestWantPolicies(t *testing.T) {
+	var (
+		base    = test.RandomAddress()
+		depth   = uint8(4)
+		count   = uint64(0)
+		inside  = test.RandomAddressAt(base, 5)
+		outside = test.RandomAddressAt(base, 3)
+
+		depthPolicy    = pullsync.DepthWantPolicy(base, func() uint8 { return depth })
+		capacityPolicy = pullsync.CapacityWantPolicy(func() uint64 { return count }, 10)
+		policy         = pullsync.AllWantPolicies(depthPolicy, capacityPolicy)
+	)
+
+	if !depthPolicy(inside) {
+		t.Error("chunk within depth not wanted")
+	}
+	if depthPolicy(outside) {
+		t.Error("chunk outside of depth wanted")
+	}
+
+	if !policy(inside) {
+		t.Error("chunk within depth and capacity not wanted")
+	}
+	if policy(outside) {
+		t.Error("chunk outside of depth wanted")
+	}
+
+	count = 10
+	if capacityPolicy(inside) {
+		t.Error("chunk over capacity wanted")
+	}
+	if policy(inside) {
+		t.Error("chunk over capacity wanted")
+	}
+}
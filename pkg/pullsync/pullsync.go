@@ -13,38 +13,58 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/opentracing/opentracing-go"
 	"github.com/yanhuangpai/voyager/pkg/bitvector"
 	"github.com/yanhuangpai/voyager/pkg/cac"
+	"github.com/yanhuangpai/voyager/pkg/events"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
 	"github.com/yanhuangpai/voyager/pkg/p2p/protobuf"
+	"github.com/yanhuangpai/voyager/pkg/protoerr"
 	"github.com/yanhuangpai/voyager/pkg/pullsync/pb"
 	"github.com/yanhuangpai/voyager/pkg/pullsync/pullstorage"
 	"github.com/yanhuangpai/voyager/pkg/soc"
 	"github.com/yanhuangpai/voyager/pkg/storage"
+	"github.com/yanhuangpai/voyager/pkg/tracing"
 )
 
 const (
 	protocolName     = "pullsync"
-	protocolVersion  = "1.0.0"
+	protocolVersion  = "1.1.0"
 	streamName       = "pullsync"
 	cursorStreamName = "cursors"
 	cancelStreamName = "cancel"
+
+	// deprecatedProtocolVersion is the version of the peers which do not
+	// know the error codes, the stream is reset instead of sending them
+	// the failed offer, which they would take for an empty interval.
+	deprecatedProtocolVersion = "1.0.0"
 )
 
 var (
 	ErrUnsolicitedChunk = errors.New("peer sent unsolicited chunk")
 
+	errOfferTooLarge = errors.New("offer too large")
+
 	cancellationTimeout = 5 * time.Second // explicit ruid cancellation message timeout
 )
 
 // how many maximum chunks in a batch
 var maxPage = 50
 
+// numbers of the fields of the pb.Offer message
+const (
+	offerTopmostField   = 1
+	offerHashesField    = 2
+	offerErrorCodeField = 3
+	offerFilteredField  = 4
+)
+
 // Interface is the PullSync interface.
 type Interface interface {
 	// SyncInterval syncs a requested interval from the given peer.
@@ -59,6 +79,15 @@ type Interface interface {
 	CancelRuid(ctx context.Context, peer infinity.Address, ruid uint32) error
 }
 
+// CursorsGetter gets the local pull sync cursors and the cursors of the
+// downstream peers.
+type CursorsGetter interface {
+	// Cursors returns the local cursors of all bins.
+	Cursors(ctx context.Context) ([]uint64, error)
+	// GetCursors retrieves all cursors from a downstream peer.
+	GetCursors(ctx context.Context, peer infinity.Address) ([]uint64, error)
+}
+
 type Syncer struct {
 	streamer p2p.Streamer
 	metrics  metrics
@@ -67,6 +96,15 @@ type Syncer struct {
 	quit     chan struct{}
 	wg       sync.WaitGroup
 	unwrap   func(infinity.Chunk)
+	want     WantPolicy
+	tracer   *tracing.Tracer
+	eventBus *events.Bus
+	base     infinity.Address // set if the offer filter is sent
+	heldMtx  sync.Mutex
+	held     map[uint8]heldChunks
+
+	incidents   *incidents
+	blocklister p2p.Disconnecter
 
 	ruidMtx sync.Mutex
 	ruidCtx map[uint32]func()
@@ -75,19 +113,33 @@ type Syncer struct {
 	io.Closer
 }
 
-func New(streamer p2p.Streamer, storage pullstorage.Storer, unwrap func(infinity.Chunk), logger logging.Logger) *Syncer {
+func New(streamer p2p.Streamer, storage pullstorage.Storer, unwrap func(infinity.Chunk), logger logging.Logger, tracer *tracing.Tracer) *Syncer {
 	return &Syncer{
-		streamer: streamer,
-		storage:  storage,
-		metrics:  newMetrics(),
-		unwrap:   unwrap,
-		logger:   logger,
-		ruidCtx:  make(map[uint32]func()),
-		wg:       sync.WaitGroup{},
-		quit:     make(chan struct{}),
+		streamer:  streamer,
+		storage:   storage,
+		metrics:   newMetrics(),
+		unwrap:    unwrap,
+		logger:    logger,
+		tracer:    tracer,
+		ruidCtx:   make(map[uint32]func()),
+		held:      make(map[uint8]heldChunks),
+		incidents: newIncidents(),
+		wg:        sync.WaitGroup{},
+		quit:      make(chan struct{}),
 	}
 }
 
+// SetWantPolicy sets the policy consulted for every offered chunk which is not
+// already stored. By default all such chunks are wanted.
+func (s *Syncer) SetWantPolicy(p WantPolicy) {
+	s.want = p
+}
+
+// SetEventBus sets the bus on which the synced intervals are published.
+func (s *Syncer) SetEventBus(b *events.Bus) {
+	s.eventBus = b
+}
+
 func (s *Syncer) Protocol() p2p.ProtocolSpec {
 	return p2p.ProtocolSpec{
 		Name:    protocolName,
@@ -109,12 +161,34 @@ func (s *Syncer) Protocol() p2p.ProtocolSpec {
 	}
 }
 
+// DeprecatedProtocol returns the spec of the previous version of the
+// protocol, which is kept for the peers that do not support the current one.
+func (s *Syncer) DeprecatedProtocol() p2p.ProtocolSpec {
+	p := s.Protocol()
+	p.Version = deprecatedProtocolVersion
+	p.Deprecated = true
+	return p
+}
+
 // SyncInterval syncs a requested interval from the given peer.
 // It returns the BinID of highest chunk that was synced from the given interval.
 // If the requested interval is too large, the downstream peer has the liberty to
 // provide less chunks than requested.
 func (s *Syncer) SyncInterval(ctx context.Context, peer infinity.Address, bin uint8, from, to uint64) (topmost uint64, ruid uint32, err error) {
-	stream, err := s.streamer.NewStream(ctx, peer, nil, protocolName, protocolVersion, streamName)
+	span, _, ctx := s.tracer.StartSpanFromContext(ctx, "pullsync-sync-interval", s.logger,
+		opentracing.Tag{Key: "peer", Value: peer.String()},
+		opentracing.Tag{Key: "bin", Value: bin},
+		opentracing.Tag{Key: "from", Value: from},
+		opentracing.Tag{Key: "to", Value: to},
+	)
+	defer func() { tracing.FinishSpan(span, err) }()
+	defer func() {
+		if err == nil {
+			s.eventBus.Publish(events.TopicIntervalComplete, events.IntervalData{Peer: peer, Bin: bin, From: from, To: topmost})
+		}
+	}()
+
+	stream, err := s.streamer.NewStream(ctx, peer, protobuf.NewHeaders(ctx, p2p.PriorityLow), protocolName, protocolVersion, streamName)
 	if err != nil {
 		return 0, 0, fmt.Errorf("new stream: %w", err)
 	}
@@ -142,57 +216,40 @@ func (s *Syncer) SyncInterval(ctx context.Context, peer infinity.Address, bin ui
 	}
 
 	rangeMsg := &pb.GetRange{Bin: int32(bin), From: from, To: to}
+	var held map[string]struct{}
+	if !s.base.IsZero() {
+		// the interval is synced without the filter if it fails
+		rangeMsg.Filter, held, err = s.heldFilter(ctx, peer, bin)
+		if err != nil {
+			s.logger.Debugf("pullsync: offer filter for peer %s: %v", peer, err)
+		}
+	}
 	if err = w.WriteMsgWithContext(ctx, rangeMsg); err != nil {
 		return 0, ru.Ruid, fmt.Errorf("write get range: %w", err)
 	}
 
-	var offer pb.Offer
-	if err = r.ReadMsgWithContext(ctx, &offer); err != nil {
+	offer, err := s.readOffer(ctx, r, held)
+	if err != nil {
 		return 0, ru.Ruid, fmt.Errorf("read offer: %w", err)
 	}
-
-	if len(offer.Hashes)%infinity.HashSize != 0 {
-		return 0, ru.Ruid, fmt.Errorf("inconsistent hash length")
+	if err := protoerr.FromCode(peer, offer.errorCode); err != nil {
+		s.metrics.PeerErrors.WithLabelValues(protoerr.Code(offer.errorCode).String()).Inc()
+		return 0, ru.Ruid, fmt.Errorf("offer: %w", err)
 	}
 
 	// empty interval (no chunks present in interval).
 	// return the end of the requested range as topmost.
-	if len(offer.Hashes) == 0 {
-		return offer.Topmost, ru.Ruid, nil
+	if offer.want == nil {
+		return offer.topmost, ru.Ruid, nil
 	}
 
 	var (
-		bvLen      = len(offer.Hashes) / infinity.HashSize
-		wantChunks = make(map[string]struct{})
-		ctr        = 0
+		bv           = offer.want
+		wantChunks   = offer.wantChunks
+		wantPrefixes = offer.wantPrefixes
+		ctr          = len(offer.wantChunks) + len(offer.wantPrefixes)
 	)
 
-	bv, err := bitvector.New(bvLen)
-	if err != nil {
-		return 0, ru.Ruid, fmt.Errorf("new bitvector: %w", err)
-	}
-
-	for i := 0; i < len(offer.Hashes); i += infinity.HashSize {
-		a := infinity.NewAddress(offer.Hashes[i : i+infinity.HashSize])
-		if a.Equal(infinity.ZeroAddress) {
-			// i'd like to have this around to see we don't see any of these in the logs
-			s.logger.Errorf("syncer got a zero address hash on offer")
-			return 0, ru.Ruid, fmt.Errorf("zero address on offer")
-		}
-		s.metrics.OfferCounter.Inc()
-		s.metrics.DbOpsCounter.Inc()
-		have, err := s.storage.Has(ctx, a)
-		if err != nil {
-			return 0, ru.Ruid, fmt.Errorf("storage has: %w", err)
-		}
-		if !have {
-			wantChunks[a.String()] = struct{}{}
-			ctr++
-			s.metrics.WantCounter.Inc()
-			bv.Set(i / infinity.HashSize)
-		}
-	}
-
 	wantMsg := &pb.Want{BitVector: bv.Bytes()}
 	if err = w.WriteMsgWithContext(ctx, wantMsg); err != nil {
 		return 0, ru.Ruid, fmt.Errorf("write want: %w", err)
@@ -215,13 +272,17 @@ func (s *Syncer) SyncInterval(ctx context.Context, peer infinity.Address, bin ui
 		}
 
 		addr := infinity.NewAddress(delivery.Address)
-		if _, ok := wantChunks[addr.String()]; !ok {
+		prefix := filteredPrefix(delivery.Address)
+		if _, ok := wantChunks[addr.String()]; ok {
+			delete(wantChunks, addr.String())
+		} else if _, ok := wantPrefixes[prefix]; ok {
+			delete(wantPrefixes, prefix)
+		} else {
 			// this is fatal for the entire batch, return the
 			// error and don't write the partial batch.
+			s.incident(peer, incidentUnsolicited)
 			return 0, ru.Ruid, ErrUnsolicitedChunk
 		}
-
-		delete(wantChunks, addr.String())
 		s.metrics.DeliveryCounter.Inc()
 
 		chunk := infinity.NewChunk(addr, delivery.Data)
@@ -230,6 +291,7 @@ func (s *Syncer) SyncInterval(ctx context.Context, peer infinity.Address, bin ui
 		} else if !soc.Valid(chunk) {
 			// this is fatal for the entire batch, return the
 			// error and don't write the partial batch.
+			s.incident(peer, incidentInvalid)
 			return 0, ru.Ruid, infinity.ErrInvalidChunk
 		}
 		chunksToPut = append(chunksToPut, chunk)
@@ -249,11 +311,14 @@ func (s *Syncer) SyncInterval(ctx context.Context, peer infinity.Address, bin ui
 		return 0, ru.Ruid, err
 	}
 
-	return offer.Topmost, ru.Ruid, nil
+	return offer.topmost, ru.Ruid, nil
 }
 
 // handler handles an incoming request to sync an interval
 func (s *Syncer) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (err error) {
+	span, _, ctx := s.tracer.StartSpanFromContext(ctx, "pullsync-handler", s.logger, opentracing.Tag{Key: "peer", Value: p.Address.String()})
+	defer func() { tracing.FinishSpan(span, err) }()
+
 	w, r := protobuf.NewWriterAndReader(stream)
 	defer func() {
 		if err != nil {
@@ -266,6 +331,8 @@ func (s *Syncer) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (er
 	if err := r.ReadMsgWithContext(ctx, &ru); err != nil {
 		return fmt.Errorf("send ruid: %w", err)
 	}
+	// the ruid identifies the request also when it is canceled by the peer
+	logger := logging.PeerEntry(s.logger, p.Address, strconv.FormatUint(uint64(ru.Ruid), 10))
 
 	ctx, cancel := context.WithCancel(ctx)
 	s.ruidMtx.Lock()
@@ -298,11 +365,22 @@ func (s *Syncer) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (er
 	if err := r.ReadMsgWithContext(ctx, &rn); err != nil {
 		return fmt.Errorf("read get range: %w", err)
 	}
+	logger.Tracef("pullsync: received range request bin %d from %d to %d", rn.Bin, rn.From, rn.To)
 
 	// make an offer to the upstream peer in return for the requested range
-	offer, _, err := s.makeOffer(ctx, rn)
+	offer, offered, err := s.makeOffer(ctx, rn)
 	if err != nil {
-		return fmt.Errorf("make offer: %w", err)
+		if p2p.StreamVersion(stream, protocolVersion) == deprecatedProtocolVersion {
+			return fmt.Errorf("make offer: %w", err)
+		}
+		// let the peer know why the range is not offered
+		logger.Tracef("pullsync: make offer: %v", err)
+		if err := w.WriteMsgWithContext(ctx, &pb.Offer{
+			ErrorCode: int32(protoerr.CodeOf(err)),
+		}); err != nil {
+			return fmt.Errorf("write offer error: %w", err)
+		}
+		return nil
 	}
 
 	if err := w.WriteMsgWithContext(ctx, offer); err != nil {
@@ -311,7 +389,7 @@ func (s *Syncer) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (er
 
 	// we don't have any hashes to offer in this range (the
 	// interval is empty). nothing more to do
-	if len(offer.Hashes) == 0 {
+	if len(offered) == 0 {
 		return nil
 	}
 
@@ -320,7 +398,7 @@ func (s *Syncer) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (er
 		return fmt.Errorf("read want: %w", err)
 	}
 
-	chs, err := s.processWant(ctx, offer, &want)
+	chs, err := s.processWant(ctx, offered, &want)
 	if err != nil {
 		return fmt.Errorf("process want: %w", err)
 	}
@@ -336,34 +414,178 @@ func (s *Syncer) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (er
 	return nil
 }
 
-// makeOffer tries to assemble an offer for a given requested interval.
-func (s *Syncer) makeOffer(ctx context.Context, rn pb.GetRange) (o *pb.Offer, addrs []infinity.Address, err error) {
-	chs, top, err := s.storage.IntervalChunks(ctx, uint8(rn.Bin), rn.From, rn.To, maxPage)
+// receivedOffer is the offer of the chunks of the interval, with the chunks
+// wanted from it.
+type receivedOffer struct {
+	topmost      uint64
+	errorCode    int32
+	want         *bitvector.BitVector // nil if no hashes are offered
+	wantChunks   map[string]struct{}
+	wantPrefixes map[string]struct{} // the chunks in the filter which are not held
+}
+
+// readOffer reads the offer, checking the offered hashes as they are read,
+// so that the large offers are not held in memory as a whole. The chunks
+// offered by their prefixes are wanted unless their prefixes are in the held
+// set the filter is built from.
+func (s *Syncer) readOffer(ctx context.Context, r protobuf.Reader, held map[string]struct{}) (*receivedOffer, error) {
+	o := &receivedOffer{
+		wantChunks:   make(map[string]struct{}),
+		wantPrefixes: make(map[string]struct{}),
+	}
+	var (
+		hashes, filtered         int
+		wantHashes, wantFiltered []int
+		// makeOffer continues past the pages of the filtered chunks until
+		// there are at least maxFilterChunks of them
+		maxFiltered = maxFilterChunks + maxPage
+	)
+	err := r.ReadFieldsWithContext(ctx, func(f protobuf.Field) (err error) {
+		switch f.Number {
+		case offerTopmostField:
+			o.topmost = f.Value
+		case offerErrorCodeField:
+			o.errorCode = int32(f.Value)
+		case offerHashesField:
+			if f.Len%infinity.HashSize != 0 {
+				return fmt.Errorf("inconsistent hash length")
+			}
+			hashes = f.Len / infinity.HashSize
+			if hashes > maxPage {
+				return fmt.Errorf("%w: %d hashes", errOfferTooLarge, hashes)
+			}
+			for i := 0; i < hashes; i++ {
+				h := make([]byte, infinity.HashSize)
+				if _, err := io.ReadFull(f, h); err != nil {
+					return err
+				}
+				a := infinity.NewAddress(h)
+				if a.Equal(infinity.ZeroAddress) {
+					// i'd like to have this around to see we don't see any of these in the logs
+					s.logger.Errorf("syncer got a zero address hash on offer")
+					return fmt.Errorf("zero address on offer")
+				}
+				s.metrics.OfferCounter.Inc()
+				s.metrics.DbOpsCounter.Inc()
+				have, err := s.storage.Has(ctx, a)
+				if err != nil {
+					return fmt.Errorf("storage has: %w", err)
+				}
+				if !have && s.want != nil && !s.want(a) {
+					s.metrics.SkipCounter.Inc()
+					continue
+				}
+				if !have {
+					o.wantChunks[a.String()] = struct{}{}
+					s.metrics.WantCounter.Inc()
+					wantHashes = append(wantHashes, i)
+				}
+			}
+		case offerFilteredField:
+			if f.Len%filteredPrefixSize != 0 {
+				return fmt.Errorf("inconsistent prefix length")
+			}
+			filtered = f.Len / filteredPrefixSize
+			if filtered > maxFiltered {
+				return fmt.Errorf("%w: %d prefixes", errOfferTooLarge, filtered)
+			}
+			for i := 0; i < filtered; i++ {
+				p := make([]byte, filteredPrefixSize)
+				if _, err := io.ReadFull(f, p); err != nil {
+					return err
+				}
+				s.metrics.OfferCounter.Inc()
+				if _, ok := held[string(p)]; ok {
+					continue
+				}
+				// a false positive of the filter
+				o.wantPrefixes[string(p)] = struct{}{}
+				s.metrics.WantCounter.Inc()
+				wantFiltered = append(wantFiltered, i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if hashes+filtered == 0 {
+		return o, nil
+	}
+	// the prefixes follow the hashes in the bit vector
+	o.want, err = bitvector.New(hashes + filtered)
 	if err != nil {
-		return o, nil, err
+		return nil, fmt.Errorf("new bitvector: %w", err)
+	}
+	for _, i := range wantHashes {
+		o.want.Set(i)
+	}
+	for _, i := range wantFiltered {
+		o.want.Set(hashes + i)
 	}
+	return o, nil
+}
+
+// makeOffer tries to assemble an offer for a given requested interval. The
+// chunks in the filter of the held chunks sent by the peer are offered by the
+// prefixes of their addresses, which follow the hashes of the others in the
+// returned addresses.
+func (s *Syncer) makeOffer(ctx context.Context, rn pb.GetRange) (o *pb.Offer, addrs []infinity.Address, err error) {
+	filter := s.offerFilter(rn.Filter)
+
+	var (
+		chs, filtered []infinity.Address
+		top           uint64
+		from          = rn.From
+	)
+	for {
+		page, t, err := s.storage.IntervalChunks(ctx, uint8(rn.Bin), from, rn.To, maxPage)
+		if err != nil {
+			return o, nil, err
+		}
+		top = t
+		for _, a := range page {
+			if filter != nil && filter.Has(a) {
+				s.metrics.FilteredCounter.Inc()
+				filtered = append(filtered, a)
+				continue
+			}
+			chs = append(chs, a)
+		}
+		// continue past the full pages of the chunks held by the peer,
+		// instead of offering them by their prefixes only
+		if len(chs) > 0 || len(page) < maxPage || top >= rn.To || len(filtered) >= maxFilterChunks {
+			break
+		}
+		from = top + 1
+	}
+
 	o = new(pb.Offer)
 	o.Topmost = top
-	o.Hashes = make([]byte, 0)
+	o.Hashes = make([]byte, 0, len(chs)*infinity.HashSize)
 	for _, v := range chs {
 		o.Hashes = append(o.Hashes, v.Bytes()...)
 	}
-	return o, chs, nil
+	if len(filtered) > 0 {
+		o.Filtered = make([]byte, 0, len(filtered)*filteredPrefixSize)
+		for _, v := range filtered {
+			o.Filtered = append(o.Filtered, filteredPrefix(v.Bytes())...)
+		}
+	}
+	return o, append(chs, filtered...), nil
 }
 
-// processWant compares a received Want to a sent Offer and returns
-// the appropriate chunks from the local store.
-func (s *Syncer) processWant(ctx context.Context, o *pb.Offer, w *pb.Want) ([]infinity.Chunk, error) {
-	l := len(o.Hashes) / infinity.HashSize
-	bv, err := bitvector.NewFromBytes(w.BitVector, l)
+// processWant compares a received Want to the addresses of a sent Offer and
+// returns the appropriate chunks from the local store.
+func (s *Syncer) processWant(ctx context.Context, offered []infinity.Address, w *pb.Want) ([]infinity.Chunk, error) {
+	bv, err := bitvector.NewFromBytes(w.BitVector, len(offered))
 	if err != nil {
 		return nil, err
 	}
 
 	var addrs []infinity.Address
-	for i := 0; i < len(o.Hashes); i += infinity.HashSize {
-		if bv.Get(i / infinity.HashSize) {
-			a := infinity.NewAddress(o.Hashes[i : i+infinity.HashSize])
+	for i, a := range offered {
+		if bv.Get(i) {
 			addrs = append(addrs, a)
 		}
 	}
@@ -371,7 +593,15 @@ func (s *Syncer) processWant(ctx context.Context, o *pb.Offer, w *pb.Want) ([]in
 	return s.storage.Get(ctx, storage.ModeGetSync, addrs...)
 }
 
+// Cursors returns the local cursors of all bins.
+func (s *Syncer) Cursors(ctx context.Context) ([]uint64, error) {
+	return s.storage.Cursors(ctx)
+}
+
 func (s *Syncer) GetCursors(ctx context.Context, peer infinity.Address) (retr []uint64, err error) {
+	span, _, ctx := s.tracer.StartSpanFromContext(ctx, "pullsync-get-cursors", s.logger, opentracing.Tag{Key: "peer", Value: peer.String()})
+	defer func() { tracing.FinishSpan(span, err) }()
+
 	stream, err := s.streamer.NewStream(ctx, peer, nil, protocolName, protocolVersion, cursorStreamName)
 	if err != nil {
 		return nil, fmt.Errorf("new stream: %w", err)
@@ -401,6 +631,9 @@ func (s *Syncer) GetCursors(ctx context.Context, peer infinity.Address) (retr []
 }
 
 func (s *Syncer) cursorHandler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (err error) {
+	span, _, ctx := s.tracer.StartSpanFromContext(ctx, "pullsync-cursor-handler", s.logger, opentracing.Tag{Key: "peer", Value: p.Address.String()})
+	defer func() { tracing.FinishSpan(span, err) }()
+
 	w, r := protobuf.NewWriterAndReader(stream)
 	defer func() {
 		if err != nil {
@@ -475,6 +708,7 @@ func (s *Syncer) cancelHandler(ctx context.Context, p p2p.Peer, stream p2p.Strea
 	defer s.ruidMtx.Unlock()
 
 	if cancel, ok := s.ruidCtx[c.Ruid]; ok {
+		logging.PeerEntry(s.logger, p.Address, strconv.FormatUint(uint64(c.Ruid), 10)).Trace("pullsync: request canceled")
 		cancel()
 	}
 	delete(s.ruidCtx, c.Ruid)
@@ -5,18 +5,26 @@
 package pullsync_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"testing"
+	"time"
 
+	"github.com/yanhuangpai/voyager/pkg/bitvector"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/p2p/protobuf"
 	"github.com/yanhuangpai/voyager/pkg/p2p/streamtest"
+	"github.com/yanhuangpai/voyager/pkg/protoerr"
 	"github.com/yanhuangpai/voyager/pkg/pullsync"
+	"github.com/yanhuangpai/voyager/pkg/pullsync/pb"
 	"github.com/yanhuangpai/voyager/pkg/pullsync/pullstorage/mock"
+	"github.com/yanhuangpai/voyager/pkg/storage"
 	testingc "github.com/yanhuangpai/voyager/pkg/storage/testing"
 )
 
@@ -114,6 +122,179 @@ func TestIncoming_WantOne(t *testing.T) {
 	}
 }
 
+// TestIncoming_WantPolicy tests that only the chunks wanted by the policy
+// are requested and that the interval is sealed anyway.
+func TestIncoming_WantPolicy(t *testing.T) {
+	var (
+		mockTopmost        = uint64(5)
+		ps, _              = newPullSync(nil, mock.WithIntervalsResp(addrs, mockTopmost, nil), mock.WithChunks(chunks...))
+		recorder           = streamtest.New(streamtest.WithProtocols(ps.Protocol()))
+		psClient, clientDb = newPullSync(recorder)
+	)
+	psClient.SetWantPolicy(func(addr infinity.Address) bool {
+		return addr.Equal(addrs[0]) || addr.Equal(addrs[2])
+	})
+
+	topmost, _, err := psClient.SyncInterval(context.Background(), infinity.ZeroAddress, 0, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if topmost != mockTopmost {
+		t.Fatalf("got offer topmost %d but want %d", topmost, mockTopmost)
+	}
+
+	haveChunks(t, clientDb, addrs[0], addrs[2])
+	for _, a := range []infinity.Address{addrs[1], addrs[3], addrs[4]} {
+		if have, _ := clientDb.Has(context.Background(), a); have {
+			t.Errorf("storage has skipped chunk %s", a)
+		}
+	}
+}
+
+// TestIncoming_OfferFilter tests that the chunks held by the client are
+// offered by their prefixes only and that the others are synced.
+func TestIncoming_OfferFilter(t *testing.T) {
+	var (
+		mockTopmost = uint64(5)
+		ps, _       = newPullSync(nil, mock.WithIntervalsResp(addrs, mockTopmost, nil), mock.WithChunks(chunks...))
+		recorder    = streamtest.New(streamtest.WithProtocols(ps.Protocol()))
+		// the client holds the chunks in its bin 0, where the chunks of
+		// the bin 0 of the peer are
+		psClient, clientDb = newPullSync(recorder,
+			mock.WithChunks(someChunks(1, 2, 3)...),
+			mock.WithCursors([]uint64{3}),
+			mock.WithIntervalsResp(addrs[1:4], 3, nil),
+		)
+		base = infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000000")
+		peer = infinity.MustParseHexAddress("4000000000000000000000000000000000000000000000000000000000000000")
+	)
+	psClient.SetOfferFilter(base)
+
+	topmost, _, err := psClient.SyncInterval(context.Background(), peer, 0, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topmost != mockTopmost {
+		t.Fatalf("got offer topmost %d but want %d", topmost, mockTopmost)
+	}
+	haveChunks(t, clientDb, addrs...)
+
+	records, err := recorder.Records(peer, "pullsync", pullsync.ProtocolVersion, "pullsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offer pb.Offer
+	if err := protobuf.NewReader(bytes.NewReader(records[0].Out())).ReadMsg(&offer); err != nil {
+		t.Fatal(err)
+	}
+	want := append(append([]byte(nil), addrs[0].Bytes()...), addrs[4].Bytes()...)
+	if !bytes.Equal(offer.Hashes, want) {
+		t.Fatalf("got offered hashes %x, want %x", offer.Hashes, want)
+	}
+	var filtered []byte
+	for _, a := range addrs[1:4] {
+		filtered = append(filtered, a.Bytes()[:pullsync.FilteredPrefixSize]...)
+	}
+	if !bytes.Equal(offer.Filtered, filtered) {
+		t.Fatalf("got filtered prefixes %x, want %x", offer.Filtered, filtered)
+	}
+}
+
+// TestIncoming_OfferFilterFalsePositive tests that the chunks offered by their
+// prefixes which the client does not hold are wanted and synced, so that the
+// false positives of the filter are not lost.
+func TestIncoming_OfferFilterFalsePositive(t *testing.T) {
+	var (
+		base = infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000000")
+		peer = infinity.MustParseHexAddress("4000000000000000000000000000000000000000000000000000000000000000")
+	)
+
+	// the server offers the chunk 2, which the client holds, and the
+	// chunk 1, which it does not, by their prefixes
+	server := p2p.ProtocolSpec{
+		Name:    "pullsync",
+		Version: pullsync.ProtocolVersion,
+		StreamSpecs: []p2p.StreamSpec{{
+			Name: "pullsync",
+			Handler: func(ctx context.Context, p p2p.Peer, stream p2p.Stream) error {
+				w, r := protobuf.NewWriterAndReader(stream)
+				var ru pb.Ruid
+				if err := r.ReadMsgWithContext(ctx, &ru); err != nil {
+					return err
+				}
+				var rn pb.GetRange
+				if err := r.ReadMsgWithContext(ctx, &rn); err != nil {
+					return err
+				}
+				if len(rn.Filter) == 0 {
+					return errors.New("no filter")
+				}
+				offer := &pb.Offer{
+					Topmost:  5,
+					Hashes:   addrs[0].Bytes(),
+					Filtered: append(append([]byte(nil), addrs[1].Bytes()[:pullsync.FilteredPrefixSize]...), addrs[2].Bytes()[:pullsync.FilteredPrefixSize]...),
+				}
+				if err := w.WriteMsgWithContext(ctx, offer); err != nil {
+					return err
+				}
+				var want pb.Want
+				if err := r.ReadMsgWithContext(ctx, &want); err != nil {
+					return err
+				}
+				bv, err := bitvector.NewFromBytes(want.BitVector, 3)
+				if err != nil {
+					return err
+				}
+				if !bv.Get(0) || !bv.Get(1) || bv.Get(2) {
+					return fmt.Errorf("got want %b", want.BitVector)
+				}
+				for _, c := range someChunks(0, 1) {
+					if err := w.WriteMsgWithContext(ctx, &pb.Delivery{Address: c.Address().Bytes(), Data: c.Data()}); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}},
+	}
+	recorder := streamtest.New(streamtest.WithProtocols(server))
+	psClient, clientDb := newPullSync(recorder,
+		mock.WithChunks(someChunks(2)...),
+		mock.WithCursors([]uint64{1}),
+		mock.WithIntervalsResp(addrs[2:3], 1, nil),
+	)
+	psClient.SetOfferFilter(base)
+
+	topmost, _, err := psClient.SyncInterval(context.Background(), peer, 0, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topmost != 5 {
+		t.Fatalf("got offer topmost %d but want %d", topmost, 5)
+	}
+	haveChunks(t, clientDb, addrs[:3]...)
+}
+
+func TestHeldBins(t *testing.T) {
+	base := infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000000")
+	peer := infinity.MustParseHexAddress("0400000000000000000000000000000000000000000000000000000000000000") // po 5
+
+	for _, tc := range []struct {
+		bin      uint8
+		from, to uint8
+	}{
+		{bin: 3, from: 3, to: 3},
+		{bin: 5, from: 6, to: infinity.MaxPO},
+		{bin: 7, from: 5, to: 5},
+	} {
+		from, to := pullsync.HeldBins(base, peer, tc.bin)
+		if from != tc.from || to != tc.to {
+			t.Errorf("bin %d: got bins %d-%d, want %d-%d", tc.bin, from, to, tc.from, tc.to)
+		}
+	}
+}
+
 func TestIncoming_WantAll(t *testing.T) {
 	var (
 		mockTopmost        = uint64(5)
@@ -156,6 +337,173 @@ func TestIncoming_UnsolicitedChunk(t *testing.T) {
 	}
 }
 
+func TestIncoming_Incidents(t *testing.T) {
+	peer := infinity.MustParseHexAddress("ca1e9f3938cc1425c6061b96ad9eb93e134dfe8734ad490164ef20af9d1cf59c")
+
+	// newClient returns a client syncing from a peer which delivers the evil
+	// chunk instead of the last offered one in every interval
+	newClient := func(evil infinity.Chunk) (*pullsync.Syncer, *streamtest.RecorderDisconnecter) {
+		opts := []mock.Option{mock.WithChunks(chunks...), mock.WithEvilChunk(addrs[4], evil)}
+		for i := 0; i < pullsync.MaxIncidents; i++ {
+			opts = append(opts, mock.WithIntervalsResp(addrs, 5, nil))
+		}
+		ps, _ := newPullSync(nil, opts...)
+		recorder := streamtest.NewRecorderDisconnecter(streamtest.New(streamtest.WithProtocols(ps.Protocol())))
+		client, _ := newPullSync(recorder)
+		client.SetBlocklister(recorder)
+		return client, recorder
+	}
+
+	t.Run("unsolicited", func(t *testing.T) {
+		unsolicited := infinity.NewChunk(infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000666"), []byte{0x66})
+		client, recorder := newClient(unsolicited)
+
+		for i := 0; i < pullsync.MaxIncidents; i++ {
+			if blocklisted, _ := recorder.IsBlocklisted(peer); blocklisted {
+				t.Fatalf("peer blocklisted after %d incidents", i)
+			}
+			if _, _, err := client.SyncInterval(context.Background(), peer, 0, 0, 5); !errors.Is(err, pullsync.ErrUnsolicitedChunk) {
+				t.Fatalf("expected ErrUnsolicitedChunk but got %v", err)
+			}
+		}
+
+		blocklisted, d := recorder.IsBlocklisted(peer)
+		if !blocklisted {
+			t.Fatal("peer not blocklisted")
+		}
+		if d != pullsync.IncidentsBlocklistDuration {
+			t.Fatalf("got blocklist duration %v, want %v", d, pullsync.IncidentsBlocklistDuration)
+		}
+
+		incidents := client.Incidents()
+		if len(incidents) != 1 {
+			t.Fatalf("got %d peers with incidents, want 1", len(incidents))
+		}
+		if got := incidents[0]; !got.Peer.Equal(peer) || got.Unsolicited != pullsync.MaxIncidents || got.Invalid != 0 || got.Sanctions != 1 || got.Last.IsZero() {
+			t.Fatalf("got incidents %+v", got)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		// the address of the chunk is offered, but its data does not match it
+		invalid := infinity.NewChunk(addrs[4], []byte{0x66})
+		client, recorder := newClient(invalid)
+
+		if _, _, err := client.SyncInterval(context.Background(), peer, 0, 0, 5); !errors.Is(err, infinity.ErrInvalidChunk) {
+			t.Fatalf("expected ErrInvalidChunk but got %v", err)
+		}
+		if blocklisted, _ := recorder.IsBlocklisted(peer); blocklisted {
+			t.Fatal("peer blocklisted after a single incident")
+		}
+
+		incidents := client.Incidents()
+		if len(incidents) != 1 {
+			t.Fatalf("got %d peers with incidents, want 1", len(incidents))
+		}
+		if got := incidents[0]; !got.Peer.Equal(peer) || got.Unsolicited != 0 || got.Invalid != 1 || got.Sanctions != 0 {
+			t.Fatalf("got incidents %+v", got)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		invalid := infinity.NewChunk(addrs[4], []byte{0x66})
+		client, _ := newClient(invalid)
+
+		if _, _, err := client.SyncInterval(context.Background(), peer, 0, 0, 5); !errors.Is(err, infinity.ErrInvalidChunk) {
+			t.Fatalf("expected ErrInvalidChunk but got %v", err)
+		}
+
+		defer func(f func() time.Time) { *pullsync.TimeNow = f }(*pullsync.TimeNow)
+		*pullsync.TimeNow = func() time.Time { return time.Now().Add(pullsync.IncidentsTTL + time.Minute) }
+
+		if incidents := client.Incidents(); len(incidents) != 0 {
+			t.Fatalf("got %d peers with incidents, want 0", len(incidents))
+		}
+	})
+}
+
+// TestIncoming_OfferError tests that the peers are told why the interval is
+// not offered, unless they are on the deprecated version of the protocol,
+// which would take the error for an empty interval.
+func TestIncoming_OfferError(t *testing.T) {
+	t.Run("error code", func(t *testing.T) {
+		var (
+			ps, _       = newPullSync(nil, mock.WithIntervalsResp(nil, 0, storage.ErrNotFound))
+			recorder    = streamtest.New(streamtest.WithProtocols(ps.Protocol(), ps.DeprecatedProtocol()))
+			psClient, _ = newPullSync(recorder)
+		)
+
+		_, _, err := psClient.SyncInterval(context.Background(), infinity.ZeroAddress, 0, 0, 5)
+		if !errors.Is(err, protoerr.ErrNotFound) {
+			t.Fatalf("got error %v, want %v", err, protoerr.ErrNotFound)
+		}
+	})
+
+	t.Run("deprecated version", func(t *testing.T) {
+		var (
+			ps, _       = newPullSync(nil, mock.WithIntervalsResp(nil, 0, storage.ErrNotFound))
+			recorder    = streamtest.New(streamtest.WithProtocols(ps.DeprecatedProtocol()))
+			psClient, _ = newPullSync(recorder)
+		)
+
+		_, _, err := psClient.SyncInterval(context.Background(), infinity.ZeroAddress, 0, 0, 5)
+		if err == nil {
+			t.Fatal("expected error but got none")
+		}
+		if errors.Is(err, protoerr.ErrNotFound) {
+			t.Fatalf("got error code on version %s", pullsync.DeprecatedProtocolVersion)
+		}
+	})
+}
+
+// TestIncoming_OfferTooLarge tests that the offers with more hashes or prefixes
+// than a single offer can have are rejected.
+func TestIncoming_OfferTooLarge(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		offer *pb.Offer
+	}{
+		{
+			name:  "hashes",
+			offer: &pb.Offer{Topmost: 5, Hashes: make([]byte, (*pullsync.MaxPage+1)*infinity.HashSize)},
+		},
+		{
+			name:  "prefixes",
+			offer: &pb.Offer{Topmost: 5, Filtered: make([]byte, (pullsync.MaxFilterChunks+*pullsync.MaxPage+1)*pullsync.FilteredPrefixSize)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := streamtest.New(streamtest.WithProtocols(p2p.ProtocolSpec{
+				Name:    "pullsync",
+				Version: pullsync.ProtocolVersion,
+				StreamSpecs: []p2p.StreamSpec{{
+					Name: "pullsync",
+					Handler: func(ctx context.Context, _ p2p.Peer, stream p2p.Stream) error {
+						w, r := protobuf.NewWriterAndReader(stream)
+						var ru pb.Ruid
+						if err := r.ReadMsgWithContext(ctx, &ru); err != nil {
+							return err
+						}
+						var rn pb.GetRange
+						if err := r.ReadMsgWithContext(ctx, &rn); err != nil {
+							return err
+						}
+						return w.WriteMsgWithContext(ctx, tc.offer)
+					},
+				}},
+			}))
+			psClient, clientDb := newPullSync(recorder)
+
+			if _, _, err := psClient.SyncInterval(context.Background(), infinity.ZeroAddress, 0, 0, 5); !errors.Is(err, pullsync.ErrOfferTooLarge) {
+				t.Fatalf("got error %v, want %v", err, pullsync.ErrOfferTooLarge)
+			}
+			if calls := clientDb.PutCalls(); calls != 0 {
+				t.Fatalf("got %d put calls, want none", calls)
+			}
+		})
+	}
+}
+
 func TestGetCursors(t *testing.T) {
 	var (
 		mockCursors = []uint64{100, 101, 102, 103}
@@ -214,5 +562,5 @@ func newPullSync(s p2p.Streamer, o ...mock.Option) (*pullsync.Syncer, *mock.Pull
 	storage := mock.NewPullStorage(o...)
 	logger := logging.New(ioutil.Discard, 0)
 	unwrap := func(infinity.Chunk) {}
-	return pullsync.New(s, storage, unwrap, logger), storage
+	return pullsync.New(s, storage, unwrap, logger, nil), storage
 }
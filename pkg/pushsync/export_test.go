@@ -4,8 +4,50 @@
 
 package pushsync
 
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
 var (
-	ProtocolName    = protocolName
-	ProtocolVersion = protocolVersion
-	StreamName      = streamName
+	ProtocolName              = protocolName
+	ProtocolVersion           = protocolVersion
+	DeprecatedProtocolVersion = deprecatedProtocolVersion
+	StreamName                = streamName
+)
+
+const (
+	MaxHops     = maxHops
+	ReceiptsTTL = receiptsTTL
 )
+
+func SetTimeNow(f func() time.Time) {
+	timeNow = f
+}
+
+// overlays are the overlay addresses of the test nodes by their public keys,
+// as the addresses of the test nodes are not derived from their keys.
+var overlays sync.Map
+
+func init() {
+	overlayAddress = func(p ecdsa.PublicKey, networkID uint64, nonce []byte) (infinity.Address, error) {
+		if a, ok := overlays.Load(publicKeyID(&p)); ok {
+			return a.(infinity.Address), nil
+		}
+		return crypto.NewOverlayAddress(p, networkID, nonce)
+	}
+}
+
+// SetOverlayAddress sets the overlay address of the node with the public key.
+func SetOverlayAddress(p *ecdsa.PublicKey, overlay infinity.Address) {
+	overlays.Store(publicKeyID(p), overlay)
+}
+
+func publicKeyID(p *ecdsa.PublicKey) string {
+	return hex.EncodeToString(crypto.EncodeSecp256k1PublicKey(p))
+}
@@ -10,9 +10,15 @@ import (
 )
 
 type metrics struct {
-	TotalSent     prometheus.Counter
-	TotalReceived prometheus.Counter
-	TotalErrors   prometheus.Counter
+	TotalSent         prometheus.Counter
+	TotalReceived     prometheus.Counter
+	TotalErrors       prometheus.Counter
+	InvalidReceipts   prometheus.Counter
+	InvalidSignatures prometheus.Counter
+	PeerErrors        *prometheus.CounterVec
+	HopLimitHit       prometheus.Counter
+	LoopsDetected     prometheus.Counter
+	DedupHits         prometheus.Counter
 }
 
 func newMetrics() metrics {
@@ -37,6 +43,45 @@ func newMetrics() metrics {
 			Name:      "total_errors",
 			Help:      "Total no of time error received while sending chunk.",
 		}),
+		InvalidReceipts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "invalid_receipts",
+			Help:      "Total receipts rejected because of the storer not responsible for the chunk or the invalid signature.",
+		}),
+		InvalidSignatures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "invalid_receipt_signatures",
+			Help:      "Total receipts rejected because of the signature not made by the storer.",
+		}),
+		PeerErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: m.Namespace,
+				Subsystem: subsystem,
+				Name:      "peer_errors",
+				Help:      "Number of the failed pushes reported by the peers instead of a receipt, by the error code.",
+			},
+			[]string{"code"},
+		),
+		HopLimitHit: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "hop_limit_hit",
+			Help:      "Total chunks not forwarded as they were forwarded through the maximal number of peers.",
+		}),
+		LoopsDetected: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "loops_detected",
+			Help:      "Total chunks not forwarded as they were already forwarded through this node.",
+		}),
+		DedupHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "dedup_hits",
+			Help:      "Total repeated pushes of the chunks recently stored or forwarded by this node, which are answered with the cached receipts.",
+		}),
 	}
 }
 
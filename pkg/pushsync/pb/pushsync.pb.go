@@ -23,8 +23,10 @@ var _ = math.Inf
 const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
 type Delivery struct {
-	Address []byte `protobuf:"bytes,1,opt,name=Address,proto3" json:"Address,omitempty"`
-	Data    []byte `protobuf:"bytes,2,opt,name=Data,proto3" json:"Data,omitempty"`
+	Address []byte   `protobuf:"bytes,1,opt,name=Address,proto3" json:"Address,omitempty"`
+	Data    []byte   `protobuf:"bytes,2,opt,name=Data,proto3" json:"Data,omitempty"`
+	Skip    [][]byte `protobuf:"bytes,3,rep,name=Skip,proto3" json:"Skip,omitempty"`
+	Hops    uint32   `protobuf:"varint,4,opt,name=Hops,proto3" json:"Hops,omitempty"`
 }
 
 func (m *Delivery) Reset()         { *m = Delivery{} }
@@ -74,8 +76,27 @@ func (m *Delivery) GetData() []byte {
 	return nil
 }
 
+func (m *Delivery) GetSkip() [][]byte {
+	if m != nil {
+		return m.Skip
+	}
+	return nil
+}
+
+func (m *Delivery) GetHops() uint32 {
+	if m != nil {
+		return m.Hops
+	}
+	return 0
+}
+
 type Receipt struct {
-	Address []byte `protobuf:"bytes,1,opt,name=Address,proto3" json:"Address,omitempty"`
+	Address   []byte `protobuf:"bytes,1,opt,name=Address,proto3" json:"Address,omitempty"`
+	Storer    []byte `protobuf:"bytes,2,opt,name=Storer,proto3" json:"Storer,omitempty"`
+	ErrorCode int32  `protobuf:"varint,3,opt,name=ErrorCode,proto3" json:"ErrorCode,omitempty"`
+	Signature []byte `protobuf:"bytes,4,opt,name=Signature,proto3" json:"Signature,omitempty"`
+	Duplicate bool   `protobuf:"varint,5,opt,name=Duplicate,proto3" json:"Duplicate,omitempty"`
+	Nonce     []byte `protobuf:"bytes,6,opt,name=Nonce,proto3" json:"Nonce,omitempty"`
 }
 
 func (m *Receipt) Reset()         { *m = Receipt{} }
@@ -118,6 +139,41 @@ func (m *Receipt) GetAddress() []byte {
 	return nil
 }
 
+func (m *Receipt) GetStorer() []byte {
+	if m != nil {
+		return m.Storer
+	}
+	return nil
+}
+
+func (m *Receipt) GetErrorCode() int32 {
+	if m != nil {
+		return m.ErrorCode
+	}
+	return 0
+}
+
+func (m *Receipt) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func (m *Receipt) GetDuplicate() bool {
+	if m != nil {
+		return m.Duplicate
+	}
+	return false
+}
+
+func (m *Receipt) GetNonce() []byte {
+	if m != nil {
+		return m.Nonce
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Delivery)(nil), "pushsync.Delivery")
 	proto.RegisterType((*Receipt)(nil), "pushsync.Receipt")
@@ -126,16 +182,23 @@ func init() {
 func init() { proto.RegisterFile("pushsync.proto", fileDescriptor_723cf31bfc02bfd6) }
 
 var fileDescriptor_723cf31bfc02bfd6 = []byte{
-	// 139 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2b, 0x28, 0x2d, 0xce,
-	0x28, 0xae, 0xcc, 0x4b, 0xd6, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x80, 0xf1, 0x95, 0x2c,
-	0xb8, 0x38, 0x5c, 0x52, 0x73, 0x32, 0xcb, 0x52, 0x8b, 0x2a, 0x85, 0x24, 0xb8, 0xd8, 0x1d, 0x53,
-	0x52, 0x8a, 0x52, 0x8b, 0x8b, 0x25, 0x18, 0x15, 0x18, 0x35, 0x78, 0x82, 0x60, 0x5c, 0x21, 0x21,
-	0x2e, 0x16, 0x97, 0xc4, 0x92, 0x44, 0x09, 0x26, 0xb0, 0x30, 0x98, 0xad, 0xa4, 0xcc, 0xc5, 0x1e,
-	0x94, 0x9a, 0x9c, 0x9a, 0x59, 0x50, 0x82, 0x5b, 0xa3, 0x93, 0xcc, 0x89, 0x47, 0x72, 0x8c, 0x17,
-	0x1e, 0xc9, 0x31, 0x3e, 0x78, 0x24, 0xc7, 0x38, 0xe1, 0xb1, 0x1c, 0xc3, 0x85, 0xc7, 0x72, 0x0c,
-	0x37, 0x1e, 0xcb, 0x31, 0x44, 0x31, 0x15, 0x24, 0x25, 0xb1, 0x81, 0x5d, 0x63, 0x0c, 0x08, 0x00,
-	0x00, 0xff, 0xff, 0x1b, 0x63, 0x50, 0x4a, 0x9f, 0x00, 0x00, 0x00,
+	// 250 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x90, 0x31, 0x4e, 0xc3, 0x30,
+	0x14, 0x86, 0xe3, 0xa6, 0x49, 0x83, 0x55, 0x18, 0x2c, 0x84, 0x3c, 0x54, 0x56, 0xd4, 0x29, 0x13,
+	0x0b, 0x27, 0x00, 0x82, 0xc4, 0xc4, 0xe0, 0x6c, 0x4c, 0xa4, 0xc9, 0x13, 0x58, 0x54, 0xb1, 0xf5,
+	0xec, 0x20, 0xf5, 0x16, 0xdc, 0x83, 0x8b, 0x30, 0x76, 0x64, 0x44, 0xc9, 0x45, 0x90, 0x4d, 0xab,
+	0x6c, 0xdd, 0xfe, 0xef, 0xfb, 0xf5, 0xf4, 0x4b, 0x8f, 0x5e, 0x98, 0xde, 0xbe, 0xd9, 0x5d, 0xd7,
+	0x5c, 0x1b, 0xd4, 0x4e, 0xb3, 0xec, 0xc8, 0xeb, 0x17, 0x9a, 0x95, 0xb0, 0x55, 0x1f, 0x80, 0x3b,
+	0xc6, 0xe9, 0xe2, 0xb6, 0x6d, 0x11, 0xac, 0xe5, 0x24, 0x27, 0xc5, 0x52, 0x1e, 0x91, 0x31, 0x3a,
+	0x2f, 0x6b, 0x57, 0xf3, 0x59, 0xd0, 0x21, 0x7b, 0x57, 0xbd, 0x2b, 0xc3, 0xe3, 0x3c, 0xf6, 0xce,
+	0x67, 0xef, 0x1e, 0xb5, 0xb1, 0x7c, 0x9e, 0x93, 0xe2, 0x5c, 0x86, 0xbc, 0xfe, 0x22, 0x74, 0x21,
+	0xa1, 0x01, 0x65, 0xdc, 0x89, 0x85, 0x2b, 0x9a, 0x56, 0x4e, 0x23, 0xe0, 0x61, 0xe3, 0x40, 0x6c,
+	0x45, 0xcf, 0x1e, 0x10, 0x35, 0xde, 0xeb, 0x16, 0x78, 0x9c, 0x93, 0x22, 0x91, 0x93, 0xf0, 0x6d,
+	0xa5, 0x5e, 0xbb, 0xda, 0xf5, 0x08, 0x61, 0x74, 0x29, 0x27, 0xe1, 0xdb, 0xb2, 0x37, 0x5b, 0xd5,
+	0xd4, 0x0e, 0x78, 0x92, 0x93, 0x22, 0x93, 0x93, 0x60, 0x97, 0x34, 0x79, 0xd2, 0x5d, 0x03, 0x3c,
+	0x0d, 0x77, 0xff, 0x70, 0xb7, 0xfa, 0x1e, 0x04, 0xd9, 0x0f, 0x82, 0xfc, 0x0e, 0x82, 0x7c, 0x8e,
+	0x22, 0xda, 0x8f, 0x22, 0xfa, 0x19, 0x45, 0xf4, 0x3c, 0x33, 0x9b, 0x4d, 0x1a, 0xde, 0x77, 0xf3,
+	0x37, 0x00, 0x51, 0x22, 0xfe, 0xc7, 0x50, 0x01, 0x00, 0x00,
 }
 
 func (m *Delivery) Marshal() (dAtA []byte, err error) {
@@ -158,6 +221,20 @@ func (m *Delivery) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Hops != 0 {
+		i = encodeVarintPushsync(dAtA, i, uint64(m.Hops))
+		i--
+		dAtA[i] = 0x20
+	}
+	if len(m.Skip) > 0 {
+		for iNdEx := len(m.Skip) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Skip[iNdEx])
+			copy(dAtA[i:], m.Skip[iNdEx])
+			i = encodeVarintPushsync(dAtA, i, uint64(len(m.Skip[iNdEx])))
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
 	if len(m.Data) > 0 {
 		i -= len(m.Data)
 		copy(dAtA[i:], m.Data)
@@ -195,6 +272,42 @@ func (m *Receipt) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Nonce) > 0 {
+		i -= len(m.Nonce)
+		copy(dAtA[i:], m.Nonce)
+		i = encodeVarintPushsync(dAtA, i, uint64(len(m.Nonce)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if m.Duplicate {
+		i--
+		if m.Duplicate {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x28
+	}
+	if len(m.Signature) > 0 {
+		i -= len(m.Signature)
+		copy(dAtA[i:], m.Signature)
+		i = encodeVarintPushsync(dAtA, i, uint64(len(m.Signature)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if m.ErrorCode != 0 {
+		i = encodeVarintPushsync(dAtA, i, uint64(m.ErrorCode))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.Storer) > 0 {
+		i -= len(m.Storer)
+		copy(dAtA[i:], m.Storer)
+		i = encodeVarintPushsync(dAtA, i, uint64(len(m.Storer)))
+		i--
+		dAtA[i] = 0x12
+	}
 	if len(m.Address) > 0 {
 		i -= len(m.Address)
 		copy(dAtA[i:], m.Address)
@@ -230,6 +343,15 @@ func (m *Delivery) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovPushsync(uint64(l))
 	}
+	if len(m.Skip) > 0 {
+		for _, b := range m.Skip {
+			l = len(b)
+			n += 1 + l + sovPushsync(uint64(l))
+		}
+	}
+	if m.Hops != 0 {
+		n += 1 + sovPushsync(uint64(m.Hops))
+	}
 	return n
 }
 
@@ -243,6 +365,24 @@ func (m *Receipt) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovPushsync(uint64(l))
 	}
+	l = len(m.Storer)
+	if l > 0 {
+		n += 1 + l + sovPushsync(uint64(l))
+	}
+	if m.ErrorCode != 0 {
+		n += 1 + sovPushsync(uint64(m.ErrorCode))
+	}
+	l = len(m.Signature)
+	if l > 0 {
+		n += 1 + l + sovPushsync(uint64(l))
+	}
+	if m.Duplicate {
+		n += 2
+	}
+	l = len(m.Nonce)
+	if l > 0 {
+		n += 1 + l + sovPushsync(uint64(l))
+	}
 	return n
 }
 
@@ -349,6 +489,57 @@ func (m *Delivery) Unmarshal(dAtA []byte) error {
 				m.Data = []byte{}
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Skip", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPushsync
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPushsync
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPushsync
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Skip = append(m.Skip, make([]byte, postIndex-iNdEx))
+			copy(m.Skip[len(m.Skip)-1], dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hops", wireType)
+			}
+			m.Hops = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPushsync
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Hops |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPushsync(dAtA[iNdEx:])
@@ -436,6 +627,147 @@ func (m *Receipt) Unmarshal(dAtA []byte) error {
 				m.Address = []byte{}
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Storer", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPushsync
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPushsync
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPushsync
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Storer = append(m.Storer[:0], dAtA[iNdEx:postIndex]...)
+			if m.Storer == nil {
+				m.Storer = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ErrorCode", wireType)
+			}
+			m.ErrorCode = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPushsync
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ErrorCode |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Signature", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPushsync
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPushsync
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPushsync
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Signature = append(m.Signature[:0], dAtA[iNdEx:postIndex]...)
+			if m.Signature == nil {
+				m.Signature = []byte{}
+			}
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Duplicate", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPushsync
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Duplicate = bool(v != 0)
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Nonce", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowPushsync
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthPushsync
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthPushsync
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Nonce = append(m.Nonce[:0], dAtA[iNdEx:postIndex]...)
+			if m.Nonce == nil {
+				m.Nonce = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipPushsync(dAtA[iNdEx:])
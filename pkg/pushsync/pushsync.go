@@ -13,12 +13,16 @@ import (
 	"time"
 
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/sirupsen/logrus"
 	"github.com/yanhuangpai/voyager/pkg/accounting"
 	"github.com/yanhuangpai/voyager/pkg/cac"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/events"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
 	"github.com/yanhuangpai/voyager/pkg/p2p/protobuf"
+	"github.com/yanhuangpai/voyager/pkg/protoerr"
 	"github.com/yanhuangpai/voyager/pkg/pushsync/pb"
 	"github.com/yanhuangpai/voyager/pkg/soc"
 	"github.com/yanhuangpai/voyager/pkg/storage"
@@ -29,26 +33,68 @@ import (
 
 const (
 	protocolName    = "pushsync"
-	protocolVersion = "1.0.0"
+	protocolVersion = "1.2.0"
 	streamName      = "pushsync"
+
+	// deprecatedProtocolVersion is the version of the peers which do not
+	// sign the receipts, their receipts are accepted by the address of the
+	// chunk only and they are not passed back to the peers on the current
+	// version.
+	deprecatedProtocolVersion = "1.0.0"
 )
 
 const (
 	maxPeers = 5
+
+	// maxHops is the maximal number of the peers a chunk is forwarded
+	// through, so that it is not forwarded indefinitely in a loop or in a
+	// pathological topology.
+	maxHops = 16
+
+	// invalidReceiptBlocklistDuration is the duration for which the peers
+	// returning receipts from storers outside of the neighborhood of the
+	// chunk are blocklisted.
+	invalidReceiptBlocklistDuration = time.Hour
+)
+
+var (
+	// ErrInvalidReceipt is returned when the receipt does not prove that the
+	// chunk was stored by a node responsible for it.
+	ErrInvalidReceipt = errors.New("invalid receipt")
+
+	errReceiptStorerSelf     = errors.New("storer is this node")
+	errReceiptStorerTooFar   = fmt.Errorf("storer too far from the chunk: %w", protoerr.ErrOutOfDepth)
+	errReceiptStorerNotValid = errors.New("storer not valid")
+	errReceiptSignature      = errors.New("signature not valid")
+
+	// ErrNoForwarder is returned to the originator of the chunk if there is
+	// no peer to push the chunk to, or the peers have none to forward it to.
+	ErrNoForwarder = protoerr.ErrNoForwarder
 )
 
 type PushSyncer interface {
 	PushChunkToClosest(ctx context.Context, ch infinity.Chunk) (*Receipt, error)
 }
 
+// Receipt is the proof that the chunk is stored by the storer, which signed
+// the chunk address and its own overlay address. The nonce is the one the
+// overlay address of the storer is derived with.
 type Receipt struct {
-	Address infinity.Address
+	Address   infinity.Address
+	Storer    infinity.Address
+	Signature []byte
+	Nonce     []byte
 }
 
 type PushSync struct {
+	address       infinity.Address
+	nonce         []byte
+	signer        crypto.Signer
+	networkID     uint64
 	streamer      p2p.StreamerDisconnecter
 	storer        storage.Putter
 	peerSuggester topology.ClosestPeerer
+	depther       topology.NeighborhoodDepther
 	tagger        *tags.Tags
 	unwrap        func(infinity.Chunk)
 	logger        logging.Logger
@@ -56,15 +102,25 @@ type PushSync struct {
 	pricer        accounting.Pricer
 	metrics       metrics
 	tracer        *tracing.Tracer
+	eventBus      *events.Bus
+	receipts      *receiptCache
 }
 
 var timeToLive = 5 * time.Second // request time to live
 
-func New(streamer p2p.StreamerDisconnecter, storer storage.Putter, closestPeerer topology.ClosestPeerer, tagger *tags.Tags, unwrap func(infinity.Chunk), logger logging.Logger, accounting accounting.Interface, pricer accounting.Pricer, tracer *tracing.Tracer) *PushSync {
+// overlayAddress derives the overlay address of the storer from the public
+// key recovered from the receipt signature, it is replaced in tests.
+var overlayAddress = crypto.NewOverlayAddress
+
+func New(address infinity.Address, signer crypto.Signer, networkID uint64, streamer p2p.StreamerDisconnecter, storer storage.Putter, closestPeerer topology.ClosestPeerer, depther topology.NeighborhoodDepther, tagger *tags.Tags, unwrap func(infinity.Chunk), logger logging.Logger, accounting accounting.Interface, pricer accounting.Pricer, tracer *tracing.Tracer) *PushSync {
 	ps := &PushSync{
+		address:       address,
+		signer:        signer,
+		networkID:     networkID,
 		streamer:      streamer,
 		storer:        storer,
 		peerSuggester: closestPeerer,
+		depther:       depther,
 		tagger:        tagger,
 		unwrap:        unwrap,
 		logger:        logger,
@@ -72,10 +128,22 @@ func New(streamer p2p.StreamerDisconnecter, storer storage.Putter, closestPeerer
 		pricer:        pricer,
 		metrics:       newMetrics(),
 		tracer:        tracer,
+		receipts:      newReceiptCache(),
 	}
 	return ps
 }
 
+// SetEventBus sets the bus on which the received receipts are published.
+func (s *PushSync) SetEventBus(b *events.Bus) {
+	s.eventBus = b
+}
+
+// SetOverlayNonce sets the nonce the overlay address of the node is derived
+// with, which is sent in the receipts so that they can be verified.
+func (s *PushSync) SetOverlayNonce(nonce []byte) {
+	s.nonce = nonce
+}
+
 func (s *PushSync) Protocol() p2p.ProtocolSpec {
 	return p2p.ProtocolSpec{
 		Name:    protocolName,
@@ -89,9 +157,19 @@ func (s *PushSync) Protocol() p2p.ProtocolSpec {
 	}
 }
 
+// DeprecatedProtocol returns the spec of the previous version of the
+// protocol, which is kept for the peers that do not support the current one.
+func (s *PushSync) DeprecatedProtocol() p2p.ProtocolSpec {
+	p := s.Protocol()
+	p.Version = deprecatedProtocolVersion
+	p.Deprecated = true
+	return p
+}
+
 // handler handles chunk delivery from other node and forwards to its destination node.
 // If the current node is the destination, it stores in the local store and sends a receipt.
 func (ps *PushSync) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (err error) {
+	logger := logging.PeerEntry(ps.logger, p.Address, logging.NewRequestID())
 	w, r := protobuf.NewWriterAndReader(stream)
 	ctx, cancel := context.WithTimeout(ctx, timeToLive)
 	defer cancel()
@@ -103,6 +181,10 @@ func (ps *PushSync) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream)
 			_ = stream.FullClose()
 		}
 	}()
+	// the peer on the deprecated version does not require the receipts
+	// signed by the storer
+	deprecated := p2p.StreamVersion(stream, protocolVersion) == deprecatedProtocolVersion
+
 	var ch pb.Delivery
 	if err = r.ReadMsgWithContext(ctx, &ch); err != nil {
 		return fmt.Errorf("pushsync read delivery: %w", err)
@@ -116,56 +198,145 @@ func (ps *PushSync) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream)
 			go ps.unwrap(chunk)
 		}
 	} else if !soc.Valid(chunk) {
-		return infinity.ErrInvalidChunk
+		return ps.writeError(ctx, logger, w, p, chunk.Address(), infinity.ErrInvalidChunk)
 	}
 
-	span, _, ctx := ps.tracer.StartSpanFromContext(ctx, "pushsync-handler", ps.logger, opentracing.Tag{Key: "address", Value: chunk.Address().String()})
-	defer span.Finish()
+	logger.Tracef("pushsync: received chunk %s, %d hops", chunk.Address(), ch.Hops)
 
-	receipt, err := ps.pushToClosest(ctx, chunk)
+	span, _, ctx := ps.tracer.StartSpanFromContext(ctx, "pushsync-handler", ps.logger, opentracing.Tag{Key: "address", Value: chunk.Address().String()}, opentracing.Tag{Key: "peer", Value: p.Address.String()})
+	defer func() { tracing.FinishSpan(span, err) }()
+
+	// the peers the chunk was pushed through are not pushed to again, which
+	// would loop the chunk between the peers at the same distance to it
+	skipPeers := append(topology.DecodeSkipPeers(ch.Skip), p.Address)
+	for _, a := range skipPeers {
+		if a.Equal(ps.address) {
+			ps.metrics.LoopsDetected.Inc()
+			return ps.writeError(ctx, logger, w, p, chunk.Address(), protoerr.ErrLoop)
+		}
+	}
+
+	// the chunk delivered again by the same or another peer shortly after it
+	// was stored or forwarded is answered with the cached receipt, it is not
+	// stored or forwarded again and the duplicate receipt is not paid for
+	if receipt, ok := ps.receipts.get(chunk); ok && (deprecated || len(receipt.Signature) > 0) {
+		ps.metrics.DedupHits.Inc()
+		receipt.Duplicate = true
+		if err := w.WriteMsgWithContext(ctx, receipt); err != nil {
+			return fmt.Errorf("send receipt to peer %s: %w", p.Address.String(), err)
+		}
+		logger.Tracef("pushsync: chunk %s already pushed", chunk.Address())
+		return nil
+	}
+
+	receipt, err := ps.pushToClosest(ctx, chunk, skipPeers, ch.Hops+1)
 	if err != nil {
 		if errors.Is(err, topology.ErrWantSelf) {
 			_, err = ps.storer.Put(ctx, storage.ModePutSync, chunk)
 			if err != nil {
 				return fmt.Errorf("chunk store: %w", err)
 			}
-
-			receipt := pb.Receipt{Address: chunk.Address().Bytes()}
-			if err := w.WriteMsgWithContext(ctx, &receipt); err != nil {
+			receipt, err := ps.storerReceipt(chunk.Address())
+			if err != nil {
+				return err
+			}
+			ps.receipts.add(chunk, receipt)
+			if err := w.WriteMsgWithContext(ctx, receipt); err != nil {
 				return fmt.Errorf("send receipt to peer %s: %w", p.Address.String(), err)
 			}
+			logger.Tracef("pushsync: stored chunk %s", chunk.Address())
 
+			ps.accounting.Served(p.Address, len(chunk.Data()))
 			return ps.accounting.Debit(p.Address, ps.pricer.Price(chunk.Address()))
 		}
-		return fmt.Errorf("handler: push to closest: %w", err)
+		if errors.Is(err, topology.ErrNotFound) {
+			err = fmt.Errorf("%w: %v", protoerr.ErrNoForwarder, err)
+		}
+		return ps.writeError(ctx, logger, w, p, chunk.Address(), fmt.Errorf("handler: push to closest: %w", err))
+	}
+
+	if !deprecated && len(receipt.Signature) == 0 {
+		// the receipt of the peer on the deprecated version would be
+		// rejected by the peer as not signed
+		return ps.writeError(ctx, logger, w, p, chunk.Address(), fmt.Errorf("unsigned receipt: %w", protoerr.ErrNoForwarder))
 	}
 
-	// pass back the receipt
+	// pass back the receipt, which is paid for to this node even if it is
+	// a duplicate one not paid for by this node
+	receipt.Duplicate = false
+	ps.receipts.add(chunk, receipt)
 	if err := w.WriteMsgWithContext(ctx, receipt); err != nil {
 		return fmt.Errorf("send receipt to peer %s: %w", p.Address.String(), err)
 	}
+	logger.Tracef("pushsync: forwarded chunk %s, stored by %s", chunk.Address(), infinity.NewAddress(receipt.Storer))
 
+	ps.accounting.Served(p.Address, len(chunk.Data()))
 	return ps.accounting.Debit(p.Address, ps.pricer.Price(chunk.Address()))
 }
 
+// storerReceipt returns the receipt of the chunk stored by this node, signed
+// by this node.
+func (ps *PushSync) storerReceipt(chunk infinity.Address) (*pb.Receipt, error) {
+	signature, err := ps.signer.Sign(receiptSignData(chunk.Bytes(), ps.address.Bytes()))
+	if err != nil {
+		return nil, fmt.Errorf("receipt signature: %w", err)
+	}
+	return &pb.Receipt{Address: chunk.Bytes(), Storer: ps.address.Bytes(), Signature: signature, Nonce: ps.nonce}, nil
+}
+
+// writeError lets the peer know why the chunk was not pushed, sending the
+// error code instead of the receipt. The receipt is sent without the chunk
+// address, so that the peers which do not know the error codes do not take it
+// for a valid one.
+func (ps *PushSync) writeError(ctx context.Context, logger *logrus.Entry, w protobuf.Writer, p p2p.Peer, chunk infinity.Address, err error) error {
+	ps.metrics.TotalErrors.Inc()
+	logger.Tracef("pushsync: chunk %s: %v", chunk, err)
+	receipt := pb.Receipt{ErrorCode: int32(protoerr.CodeOf(err))}
+	if err := w.WriteMsgWithContext(ctx, &receipt); err != nil {
+		return fmt.Errorf("send receipt error to peer %s: %w", p.Address.String(), err)
+	}
+	return nil
+}
+
 // PushChunkToClosest sends chunk to the closest peer by opening a stream. It then waits for
 // a receipt from that peer and returns error or nil based on the receiving and
-// the validity of the receipt.
+// the validity of the receipt. ErrNoForwarder is returned if there is no peer
+// the chunk could be pushed to. The chunk pushed again shortly after it was
+// pushed is not pushed again, the cached receipt is returned instead.
 func (ps *PushSync) PushChunkToClosest(ctx context.Context, ch infinity.Chunk) (*Receipt, error) {
-	r, err := ps.pushToClosest(ctx, ch)
-	if err != nil {
-		return nil, err
+	r, ok := ps.receipts.get(ch)
+	if ok {
+		ps.metrics.DedupHits.Inc()
+	} else {
+		var err error
+		r, err = ps.pushToClosest(ctx, ch, nil, 0)
+		if err != nil {
+			if errors.Is(err, topology.ErrNotFound) {
+				return nil, fmt.Errorf("%w: %v", ErrNoForwarder, err)
+			}
+			return nil, err
+		}
+		ps.receipts.add(ch, r)
 	}
-	return &Receipt{Address: infinity.NewAddress(r.Address)}, nil
+	return &Receipt{
+		Address:   infinity.NewAddress(r.Address),
+		Storer:    infinity.NewAddress(r.Storer),
+		Signature: r.Signature,
+		Nonce:     r.Nonce,
+	}, nil
 }
 
-func (ps *PushSync) pushToClosest(ctx context.Context, ch infinity.Chunk) (rr *pb.Receipt, reterr error) {
+// pushToClosest pushes the chunk to the closest peer which is not in
+// skipPeers, trying the next closest one if the push fails. The skipped peers
+// and the peers tried before are sent with the chunk to be skipped by the peer
+// when it forwards the chunk, as well as the number of hops the chunk was
+// forwarded through, zero if this node is the originator. This node is added
+// to them only if it forwards the chunk, not to reveal the originator.
+func (ps *PushSync) pushToClosest(ctx context.Context, ch infinity.Chunk, skipPeers []infinity.Address, hops uint32) (rr *pb.Receipt, reterr error) {
 	span, logger, ctx := ps.tracer.StartSpanFromContext(ctx, "push-closest", ps.logger, opentracing.Tag{Key: "address", Value: ch.Address().String()})
 	defer span.Finish()
-	var (
-		skipPeers []infinity.Address
-		lastErr   error
-	)
+	var lastErr error
+	skipPeers = append(skipPeers[:0:0], skipPeers...)
 
 	deferFuncs := make([]func(), 0)
 	defersFn := func() {
@@ -195,6 +366,18 @@ func (ps *PushSync) pushToClosest(ctx context.Context, ch infinity.Chunk) (rr *p
 			// if ErrWantSelf is returned, it means we are the closest peer.
 			return nil, fmt.Errorf("closest peer: %w", err)
 		}
+		if hops > maxHops {
+			// the chunk is stored by this node if it is the closest
+			// one, but it is not forwarded any further
+			ps.metrics.HopLimitHit.Inc()
+			return nil, fmt.Errorf("%d hops: %w", hops, protoerr.ErrHopLimit)
+		}
+
+		skipped := skipPeers[:len(skipPeers):len(skipPeers)]
+		if hops > 0 {
+			skipped = append(skipped, ps.address)
+		}
+		skip := topology.EncodeSkipPeers(skipped)
 
 		// save found peer (to be skipped if there is some error with him)
 		skipPeers = append(skipPeers, peer)
@@ -214,12 +397,28 @@ func (ps *PushSync) pushToClosest(ctx context.Context, ch infinity.Chunk) (rr *p
 		}
 		deferFuncs = append(deferFuncs, func() { ps.accounting.Release(peer, receiptPrice) })
 
-		streamer, err := ps.streamer.NewStream(ctx, peer, nil, protocolName, protocolVersion, streamName)
+		// the span of the push to this peer is the parent of the handler
+		// span of the peer, as its context is sent in the stream headers
+		peerSpan, _, ctx := ps.tracer.StartSpanFromContext(ctx, "push-chunk-to-peer", ps.logger, opentracing.Tag{Key: "address", Value: ch.Address().String()}, opentracing.Tag{Key: "peer", Value: peer.String()})
+		deferFuncs = append(deferFuncs, func() {
+			switch {
+			case rr != nil:
+				peerSpan.Finish()
+			case reterr != nil:
+				tracing.FinishSpan(peerSpan, reterr)
+			default:
+				// the push failed and the next peer is tried
+				tracing.FinishSpan(peerSpan, lastErr)
+			}
+		})
+
+		streamer, err := ps.streamer.NewStream(ctx, peer, protobuf.NewHeaders(ctx, p2p.PriorityHigh), protocolName, protocolVersion, streamName)
 		if err != nil {
 			lastErr = fmt.Errorf("new stream for peer %s: %w", peer.String(), err)
 			continue
 		}
 		deferFuncs = append(deferFuncs, func() { go streamer.FullClose() })
+		deprecated := p2p.StreamVersion(streamer, protocolVersion) == deprecatedProtocolVersion
 
 		w, r := protobuf.NewWriterAndReader(streamer)
 		ctxd, canceld := context.WithTimeout(ctx, timeToLive)
@@ -227,6 +426,8 @@ func (ps *PushSync) pushToClosest(ctx context.Context, ch infinity.Chunk) (rr *p
 		if err := w.WriteMsgWithContext(ctxd, &pb.Delivery{
 			Address: ch.Address().Bytes(),
 			Data:    ch.Data(),
+			Skip:    skip,
+			Hops:    hops,
 		}); err != nil {
 			_ = streamer.Reset()
 			lastErr = fmt.Errorf("chunk %s deliver to peer %s: %w", ch.Address().String(), peer.String(), err)
@@ -253,17 +454,56 @@ func (ps *PushSync) pushToClosest(ctx context.Context, ch infinity.Chunk) (rr *p
 			continue
 		}
 
+		if err := protoerr.FromCode(peer, receipt.ErrorCode); err != nil {
+			ps.metrics.PeerErrors.WithLabelValues(protoerr.Code(receipt.ErrorCode).String()).Inc()
+			lastErr = fmt.Errorf("chunk %s: %w", ch.Address().String(), err)
+			continue
+		}
+
 		if !ch.Address().Equal(infinity.NewAddress(receipt.Address)) {
 			// if the receipt is invalid, try to push to the next peer
+			// and let the peer know that it is not paid for
+			_ = streamer.Reset()
 			lastErr = fmt.Errorf("invalid receipt. chunk %s, peer %s", ch.Address().String(), peer.String())
 			continue
 		}
 
-		err = ps.accounting.Credit(peer, receiptPrice)
-		if err != nil {
-			return nil, err
+		if deprecated {
+			// the peer does not sign the receipts nor knows the
+			// duplicate ones
+			receipt.Storer, receipt.Signature, receipt.Nonce, receipt.Duplicate = nil, nil, nil, false
+		} else if err := ps.validateReceipt(&receipt); err != nil {
+			_ = streamer.Reset()
+			ps.metrics.InvalidReceipts.Inc()
+			if errors.Is(err, errReceiptSignature) {
+				ps.metrics.InvalidSignatures.Inc()
+			}
+			lastErr = fmt.Errorf("chunk %s receipt from peer %s: %w: %v", ch.Address().String(), peer.String(), ErrInvalidReceipt, err)
+			if !errors.Is(err, errReceiptStorerNotValid) {
+				// the peer would not accept such receipt from the
+				// storer if it validated it, so it is not to be trusted
+				logger.Warningf("pushsync: blocklisting peer %s: %v", peer, lastErr)
+				if err := ps.streamer.Blocklist(peer, invalidReceiptBlocklistDuration); err != nil {
+					logger.Debugf("pushsync: blocklist peer %s: %v", peer, err)
+				}
+			}
+			continue
+		}
+
+		// the peer does not charge for the duplicate receipt of the chunk it
+		// has already stored
+		if !receipt.Duplicate {
+			ps.accounting.Requested(peer, len(ch.Data()))
+
+			err = ps.accounting.Credit(peer, receiptPrice)
+			if err != nil {
+				_ = streamer.Reset()
+				return nil, err
+			}
+			accounting.AddCost(ctx, receiptPrice)
 		}
 
+		ps.eventBus.Publish(events.TopicReceiptReceived, events.ReceiptData{Chunk: ch.Address(), Peer: peer})
 		return &receipt, nil
 	}
 
@@ -275,3 +515,53 @@ func (ps *PushSync) pushToClosest(ctx context.Context, ch infinity.Chunk) (rr *p
 
 	return nil, topology.ErrNotFound
 }
+
+// validateReceipt checks that the receipt is signed by its storer and that
+// the storer is responsible for the chunk.
+func (ps *PushSync) validateReceipt(receipt *pb.Receipt) error {
+	chunk := infinity.NewAddress(receipt.Address)
+	if err := ps.validateReceiptStorer(chunk, receipt.Storer); err != nil {
+		return err
+	}
+
+	publicKey, err := crypto.Recover(receipt.Signature, receiptSignData(receipt.Address, receipt.Storer))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errReceiptSignature, err)
+	}
+	if len(receipt.Nonce) > crypto.MaxOverlayNonceSize {
+		return fmt.Errorf("%w: nonce too long", errReceiptSignature)
+	}
+	signer, err := overlayAddress(*publicKey, ps.networkID, receipt.Nonce)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errReceiptSignature, err)
+	}
+	if !signer.Equal(infinity.NewAddress(receipt.Storer)) {
+		return fmt.Errorf("%w: signed by %s", errReceiptSignature, signer)
+	}
+	return nil
+}
+
+// receiptSignData returns the data signed by the storer of the chunk, which
+// is the chunk address followed by the overlay address of the storer.
+func receiptSignData(chunk, storer []byte) []byte {
+	data := make([]byte, 0, len(chunk)+len(storer))
+	data = append(data, chunk...)
+	return append(data, storer...)
+}
+
+// validateReceiptStorer checks that the storer of the chunk issuing the
+// receipt is responsible for the chunk, as it is closer to the chunk than this
+// node or it is within the neighborhood depth of the chunk.
+func (ps *PushSync) validateReceiptStorer(chunk infinity.Address, storer []byte) error {
+	if len(storer) != len(ps.address.Bytes()) {
+		return errReceiptStorerNotValid
+	}
+	if ps.address.Equal(infinity.NewAddress(storer)) {
+		return errReceiptStorerSelf
+	}
+	po := infinity.Proximity(storer, chunk.Bytes())
+	if po < infinity.Proximity(ps.address.Bytes(), chunk.Bytes()) && po < ps.depther.NeighborhoodDepth() {
+		return errReceiptStorerTooFar
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ package pushsync_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"testing"
@@ -14,14 +15,18 @@ import (
 
 	"github.com/yanhuangpai/voyager/pkg/accounting"
 	accountingmock "github.com/yanhuangpai/voyager/pkg/accounting/mock"
+	"github.com/yanhuangpai/voyager/pkg/cac"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/localstore"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
 	"github.com/yanhuangpai/voyager/pkg/p2p/protobuf"
 	"github.com/yanhuangpai/voyager/pkg/p2p/streamtest"
+	"github.com/yanhuangpai/voyager/pkg/protoerr"
 	"github.com/yanhuangpai/voyager/pkg/pushsync"
 	"github.com/yanhuangpai/voyager/pkg/pushsync/pb"
+	"github.com/yanhuangpai/voyager/pkg/soc"
 	statestore "github.com/yanhuangpai/voyager/pkg/statestore/mock"
 	testingc "github.com/yanhuangpai/voyager/pkg/storage/testing"
 	"github.com/yanhuangpai/voyager/pkg/tags"
@@ -64,6 +69,12 @@ func TestSendChunkAndReceiveReceipt(t *testing.T) {
 	if !chunk.Address().Equal(receipt.Address) {
 		t.Fatal("invalid receipt")
 	}
+	if !closestPeer.Equal(receipt.Storer) {
+		t.Fatalf("got receipt storer %s, want %s", receipt.Storer, closestPeer)
+	}
+	if len(receipt.Signature) == 0 {
+		t.Fatal("receipt not signed")
+	}
 
 	// this intercepts the outgoing delivery message
 	waitOnRecordAndTest(t, closestPeer, recorder, chunk.Address(), chunk.Data())
@@ -384,7 +395,562 @@ func TestHandler(t *testing.T) {
 	}
 }
 
+// TestHandlerSkipPeers checks that the chunk is not forwarded back to the
+// peers it was pushed through, even if they are the closest peers to the
+// chunk, and that they are sent with the forwarded chunk. The originator does
+// not send its own address.
+//
+// Chunk moves from   TriggerPeer -> PivotPeer -> ClosestPeer
+func TestHandlerSkipPeers(t *testing.T) {
+	chunk := testingc.FixtureChunk("7000")
+
+	pivotPeer := infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000000")
+	triggerPeer := infinity.MustParseHexAddress("6000000000000000000000000000000000000000000000000000000000000000")
+	closestPeer := infinity.MustParseHexAddress("f000000000000000000000000000000000000000000000000000000000000000")
+
+	psClosestPeer, closestStorerPeerDB, _, _ := createPushSyncNode(t, closestPeer, nil, nil, mock.WithClosestPeerErr(topology.ErrWantSelf))
+	defer closestStorerPeerDB.Close()
+
+	closestRecorder := streamtest.New(streamtest.WithProtocols(psClosestPeer.Protocol()), streamtest.WithBaseAddr(pivotPeer))
+
+	// the trigger peer is the one the pivot peer would push to if it was
+	// not skipped
+	psPivot, storerPivotDB, _, _ := createPushSyncNode(t, pivotPeer, closestRecorder, nil, mock.WithPeers(closestPeer, triggerPeer))
+	defer storerPivotDB.Close()
+
+	pivotRecorder := streamtest.New(streamtest.WithProtocols(psPivot.Protocol()), streamtest.WithBaseAddr(triggerPeer))
+
+	psTriggerPeer, triggerStorerDB, _, _ := createPushSyncNode(t, triggerPeer, pivotRecorder, nil, mock.WithClosestPeer(pivotPeer))
+	defer triggerStorerDB.Close()
+
+	receipt, err := psTriggerPeer.PushChunkToClosest(context.Background(), chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chunk.Address().Equal(receipt.Address) {
+		t.Fatal("invalid receipt")
+	}
+
+	for _, tc := range []struct {
+		peer     infinity.Address
+		recorder *streamtest.Recorder
+		want     []infinity.Address
+		hops     uint32
+	}{
+		{peer: pivotPeer, recorder: pivotRecorder, want: nil, hops: 0},
+		{peer: closestPeer, recorder: closestRecorder, want: []infinity.Address{triggerPeer, pivotPeer}, hops: 1},
+	} {
+		records := tc.recorder.WaitRecords(t, tc.peer, pushsync.ProtocolName, pushsync.ProtocolVersion, pushsync.StreamName, 1, 5)
+		messages, err := protobuf.ReadMessages(
+			bytes.NewReader(records[0].In()),
+			func() protobuf.Message { return new(pb.Delivery) },
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		delivery := messages[0].(*pb.Delivery)
+		if delivery.Hops != tc.hops {
+			t.Fatalf("peer %s: got hops %v, want %v", tc.peer, delivery.Hops, tc.hops)
+		}
+		skip := topology.DecodeSkipPeers(delivery.Skip)
+		if len(skip) != len(tc.want) {
+			t.Fatalf("peer %s: got skip peers %v, want %v", tc.peer, skip, tc.want)
+		}
+		for i, a := range tc.want {
+			if !skip[i].Equal(a) {
+				t.Fatalf("peer %s: got skip peers %v, want %v", tc.peer, skip, tc.want)
+			}
+		}
+	}
+}
+
+// TestHandlerRejectLoops tests that the chunks forwarded through the maximal
+// number of peers or already forwarded through the node are not forwarded.
+func TestHandlerRejectLoops(t *testing.T) {
+	chunk := testingc.FixtureChunk("7000")
+
+	pivotPeer := infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000000")
+	triggerPeer := infinity.MustParseHexAddress("6000000000000000000000000000000000000000000000000000000000000000")
+	closestPeer := infinity.MustParseHexAddress("f000000000000000000000000000000000000000000000000000000000000000")
+
+	psClosestPeer, closestStorerPeerDB, _, _ := createPushSyncNode(t, closestPeer, nil, nil, mock.WithClosestPeerErr(topology.ErrWantSelf))
+	defer closestStorerPeerDB.Close()
+
+	closestRecorder := streamtest.New(streamtest.WithProtocols(psClosestPeer.Protocol()), streamtest.WithBaseAddr(pivotPeer))
+
+	psPivot, storerPivotDB, _, _ := createPushSyncNode(t, pivotPeer, closestRecorder, nil, mock.WithClosestPeer(closestPeer))
+	defer storerPivotDB.Close()
+
+	recorder := streamtest.New(streamtest.WithProtocols(psPivot.Protocol()), streamtest.WithBaseAddr(triggerPeer))
+
+	for _, tc := range []struct {
+		name     string
+		delivery *pb.Delivery
+		want     protoerr.Code
+	}{
+		// the hop limit is pushed first, as the forwarded chunk is answered
+		// with its cached receipt later
+		{
+			name:     "hop limit",
+			delivery: &pb.Delivery{Hops: pushsync.MaxHops},
+			want:     protoerr.CodeHopLimit,
+		},
+		{
+			name:     "forwarded",
+			delivery: &pb.Delivery{Hops: pushsync.MaxHops - 1}, // the last allowed hop
+		},
+		{
+			name:     "loop",
+			delivery: &pb.Delivery{Skip: topology.EncodeSkipPeers([]infinity.Address{pivotPeer})},
+			want:     protoerr.CodeLoop,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.delivery.Address = chunk.Address().Bytes()
+			tc.delivery.Data = chunk.Data()
+
+			stream, err := recorder.NewStream(context.Background(), pivotPeer, nil, pushsync.ProtocolName, pushsync.ProtocolVersion, pushsync.StreamName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+
+			w, r := protobuf.NewWriterAndReader(stream)
+			if err := w.WriteMsg(tc.delivery); err != nil {
+				t.Fatal(err)
+			}
+			var receipt pb.Receipt
+			if err := r.ReadMsg(&receipt); err != nil {
+				t.Fatal(err)
+			}
+			if got := protoerr.Code(receipt.ErrorCode); got != tc.want {
+				t.Fatalf("got error code %v, want %v", got, tc.want)
+			}
+			// the peers which do not know the error codes reject the
+			// receipt without the chunk address
+			if tc.want != protoerr.CodeNone && len(receipt.Address) != 0 {
+				t.Fatalf("got receipt address %x with error code %v", receipt.Address, tc.want)
+			}
+		})
+	}
+}
+
+// TestPushChunkNoForwarder tests that the originator of the chunk gets a
+// distinct error if there is no peer to push the chunk to.
+func TestPushChunkNoForwarder(t *testing.T) {
+	chunk := testingc.FixtureChunk("7000")
+
+	psTrigger, triggerStorerDB, _, _ := createPushSyncNode(t, infinity.MustParseHexAddress("6000"), nil, nil, mock.WithClosestPeerErr(topology.ErrNotFound))
+	defer triggerStorerDB.Close()
+
+	_, err := psTrigger.PushChunkToClosest(context.Background(), chunk)
+	if !errors.Is(err, pushsync.ErrNoForwarder) {
+		t.Fatalf("got error %v, want %v", err, pushsync.ErrNoForwarder)
+	}
+}
+
+// TestReceiptStorerValidation tests that the receipts issued by the storers
+// farther from the chunk than the pivot node and outside of its neighborhood
+// depth are rejected and the peers returning them are blocklisted.
+func TestHandlerDeduplicate(t *testing.T) {
+	defer pushsync.SetTimeNow(time.Now)
+	now := time.Now()
+	pushsync.SetTimeNow(func() time.Time { return now })
+
+	chunk := testingc.FixtureChunk("7000")
+
+	storerNode := infinity.MustParseHexAddress("7000")
+	firstNode := infinity.MustParseHexAddress("0000")
+	secondNode := infinity.MustParseHexAddress("1000")
+
+	psStorer, storerDB, _, storerAccounting := createPushSyncNode(t, storerNode, nil, nil, mock.WithClosestPeerErr(topology.ErrWantSelf))
+	defer storerDB.Close()
+
+	push := func(t *testing.T, node infinity.Address) accounting.Interface {
+		t.Helper()
+
+		recorder := streamtest.New(streamtest.WithProtocols(psStorer.Protocol()), streamtest.WithBaseAddr(node))
+		ps, db, _, nodeAccounting := createPushSyncNode(t, node, recorder, nil, mock.WithClosestPeer(storerNode))
+		defer db.Close()
+
+		receipt, err := ps.PushChunkToClosest(context.Background(), chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !storerNode.Equal(receipt.Storer) {
+			t.Fatalf("got receipt storer %s, want %s", receipt.Storer, storerNode)
+		}
+		return nodeAccounting
+	}
+
+	expectBalance := func(t *testing.T, a accounting.Interface, peer infinity.Address, want int64) {
+		t.Helper()
+
+		balance, err := a.Balance(peer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Int64() != want {
+			t.Fatalf("got balance %d with peer %s, want %d", balance, peer, want)
+		}
+	}
+
+	firstAccounting := push(t, firstNode)
+	expectBalance(t, firstAccounting, storerNode, -int64(fixedPrice))
+	expectBalance(t, storerAccounting, firstNode, int64(fixedPrice))
+
+	// the chunk is not paid for again by the other peer
+	secondAccounting := push(t, secondNode)
+	expectBalance(t, secondAccounting, storerNode, 0)
+	expectBalance(t, storerAccounting, secondNode, 0)
+
+	// the chunk is stored and paid for again once it is forgotten
+	now = now.Add(pushsync.ReceiptsTTL)
+	secondAccounting = push(t, secondNode)
+	expectBalance(t, secondAccounting, storerNode, -int64(fixedPrice))
+	expectBalance(t, storerAccounting, secondNode, int64(fixedPrice))
+}
+
+// TestHandlerCachedReceipt tests that the chunk pushed again through the
+// forwarder is answered with the cached receipt, without forwarding it again.
+func TestHandlerCachedReceipt(t *testing.T) {
+	defer pushsync.SetTimeNow(time.Now)
+	now := time.Now()
+	pushsync.SetTimeNow(func() time.Time { return now })
+
+	chunk := testingc.FixtureChunk("7000")
+
+	pivotNode := infinity.MustParseHexAddress("0000")
+	storerNode := infinity.MustParseHexAddress("7000")
+	triggerNode := infinity.MustParseHexAddress("6000")
+
+	psStorer, storerDB, _, _ := createPushSyncNode(t, storerNode, nil, nil, mock.WithClosestPeerErr(topology.ErrWantSelf))
+	defer storerDB.Close()
+	storerRecorder := streamtest.New(streamtest.WithProtocols(psStorer.Protocol()), streamtest.WithBaseAddr(pivotNode))
+
+	psPivot, pivotDB, _, pivotAccounting := createPushSyncNode(t, pivotNode, storerRecorder, nil, mock.WithClosestPeer(storerNode))
+	defer pivotDB.Close()
+	pivotRecorder := streamtest.New(streamtest.WithProtocols(psPivot.Protocol()), streamtest.WithBaseAddr(triggerNode))
+
+	psTrigger, triggerDB, _, _ := createPushSyncNode(t, triggerNode, pivotRecorder, nil, mock.WithClosestPeer(pivotNode))
+	defer triggerDB.Close()
+
+	push := func(t *testing.T, ps *pushsync.PushSync) {
+		t.Helper()
+
+		receipt, err := ps.PushChunkToClosest(context.Background(), chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !storerNode.Equal(receipt.Storer) {
+			t.Fatalf("got receipt storer %s, want %s", receipt.Storer, storerNode)
+		}
+	}
+
+	expectStreams := func(t *testing.T, recorder *streamtest.Recorder, peer infinity.Address, want int) {
+		t.Helper()
+
+		records, err := recorder.Records(peer, pushsync.ProtocolName, pushsync.ProtocolVersion, pushsync.StreamName)
+		if err != nil && want > 0 {
+			t.Fatal(err)
+		}
+		if len(records) != want {
+			t.Fatalf("got %d streams to peer %s, want %d", len(records), peer, want)
+		}
+	}
+
+	push(t, psTrigger)
+	expectStreams(t, pivotRecorder, pivotNode, 1)
+	expectStreams(t, storerRecorder, storerNode, 1)
+
+	balance, err := pivotAccounting.Balance(triggerNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the originator pushes the chunk once
+	push(t, psTrigger)
+	expectStreams(t, pivotRecorder, pivotNode, 1)
+
+	// the forwarder answers the other originator with the cached receipt
+	psOther, otherDB, _, _ := createPushSyncNode(t, triggerNode, pivotRecorder, nil, mock.WithClosestPeer(pivotNode))
+	defer otherDB.Close()
+	push(t, psOther)
+	expectStreams(t, pivotRecorder, pivotNode, 2)
+	expectStreams(t, storerRecorder, storerNode, 1)
+
+	// the cached receipt is not paid for
+	got, err := pivotAccounting.Balance(triggerNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(balance) != 0 {
+		t.Fatalf("got balance %d with the originator, want %d", got, balance)
+	}
+
+	// the chunk is forwarded again once the receipt expires
+	now = now.Add(pushsync.ReceiptsTTL)
+	push(t, psOther)
+	expectStreams(t, pivotRecorder, pivotNode, 3)
+	expectStreams(t, storerRecorder, storerNode, 2)
+}
+
+// TestCachedReceiptSOCUpdate tests that the single owner chunk uploaded again
+// with the same address and new data is pushed again, and not answered with
+// the receipt cached for the previous data.
+func TestCachedReceiptSOCUpdate(t *testing.T) {
+	privKey, err := crypto.GenerateSecp256k1Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := crypto.NewDefaultSigner(privKey)
+	id := make([]byte, soc.IdSize)
+	newSOC := func(t *testing.T, payload string) infinity.Chunk {
+		t.Helper()
+
+		ch, err := cac.New([]byte(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sch, err := soc.New(id, ch).Sign(signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sch
+	}
+
+	triggerNode := infinity.MustParseHexAddress("6000")
+	storerNode := infinity.MustParseHexAddress("7000")
+
+	psStorer, storerDB, _, _ := createPushSyncNode(t, storerNode, nil, nil, mock.WithClosestPeerErr(topology.ErrWantSelf))
+	defer storerDB.Close()
+	recorder := streamtest.New(streamtest.WithProtocols(psStorer.Protocol()), streamtest.WithBaseAddr(triggerNode))
+
+	psTrigger, triggerDB, _, _ := createPushSyncNode(t, triggerNode, recorder, nil, mock.WithClosestPeer(storerNode))
+	defer triggerDB.Close()
+
+	first, second := newSOC(t, "first"), newSOC(t, "second")
+	if !first.Address().Equal(second.Address()) {
+		t.Fatal("single owner chunks with different addresses")
+	}
+
+	for i, tc := range []struct {
+		chunk       infinity.Chunk
+		wantStreams int
+	}{
+		{chunk: first, wantStreams: 1},
+		{chunk: first, wantStreams: 1}, // answered with the cached receipt
+		{chunk: second, wantStreams: 2},
+	} {
+		if _, err := psTrigger.PushChunkToClosest(context.Background(), tc.chunk); err != nil {
+			t.Fatal(err)
+		}
+		records, err := recorder.Records(storerNode, pushsync.ProtocolName, pushsync.ProtocolVersion, pushsync.StreamName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != tc.wantStreams {
+			t.Fatalf("push %d: got %d streams, want %d", i, len(records), tc.wantStreams)
+		}
+	}
+}
+
+func TestReceiptStorerValidation(t *testing.T) {
+	chunk := testingc.FixtureChunk("7000")
+
+	pivotNode := infinity.MustParseHexAddress("7000000000000000000000000000000000000000000000000000000000000000")
+	storerPeer := infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000000") // po 1 with the chunk
+
+	for _, tc := range []struct {
+		name    string
+		depth   uint8
+		invalid bool
+	}{
+		{
+			name:  "within depth",
+			depth: 1,
+		},
+		{
+			name:    "too far",
+			depth:   8,
+			invalid: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			psPeer, storerPeerDB, _, _ := createPushSyncNode(t, storerPeer, nil, nil, mock.WithClosestPeerErr(topology.ErrWantSelf))
+			defer storerPeerDB.Close()
+
+			recorder := streamtest.NewRecorderDisconnecter(streamtest.New(streamtest.WithProtocols(psPeer.Protocol()), streamtest.WithBaseAddr(pivotNode)))
+
+			logger := logging.New(ioutil.Discard, 0)
+			storerPivot, err := localstore.New("", pivotNode.Bytes(), nil, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer storerPivot.Close()
+
+			mockTopology := mock.NewTopologyDriver(mock.WithClosestPeer(storerPeer), mock.WithNeighborhoodDepth(tc.depth))
+			mtag := tags.NewTags(statestore.NewStateStore(), logger)
+			psPivot := pushsync.New(pivotNode, newTestSigner(t, pivotNode), 0, recorder, storerPivot, mockTopology, mockTopology, mtag, func(infinity.Chunk) {}, logger, accountingmock.NewAccounting(), accountingmock.NewPricer(fixedPrice, fixedPrice), nil)
+
+			// there is no other peer to push the chunk to after the
+			// invalid receipt
+			_, err = psPivot.PushChunkToClosest(context.Background(), chunk)
+			if tc.invalid != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, tc.invalid)
+			}
+
+			if blocklisted, _ := recorder.IsBlocklisted(storerPeer); blocklisted != tc.invalid {
+				t.Errorf("got blocklisted %v, want %v", blocklisted, tc.invalid)
+			}
+		})
+	}
+}
+
+func TestReceiptSignatureValidation(t *testing.T) {
+	chunk := testingc.FixtureChunk("7000")
+
+	pivotNode := infinity.MustParseHexAddress("0000")
+	storerPeer := infinity.MustParseHexAddress("6000")
+	otherPeer := infinity.MustParseHexAddress("6100")
+
+	// the storer signs the receipts with the key of another node
+	psPeer, storerPeerDB, _, _ := createPushSyncNodeWithSigner(t, storerPeer, newTestSigner(t, otherPeer), nil, nil, mock.WithClosestPeerErr(topology.ErrWantSelf))
+	defer storerPeerDB.Close()
+
+	recorder := streamtest.NewRecorderDisconnecter(streamtest.New(streamtest.WithProtocols(psPeer.Protocol()), streamtest.WithBaseAddr(pivotNode)))
+
+	logger := logging.New(ioutil.Discard, 0)
+	storerPivot, err := localstore.New("", pivotNode.Bytes(), nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storerPivot.Close()
+
+	mockTopology := mock.NewTopologyDriver(mock.WithClosestPeer(storerPeer))
+	mtag := tags.NewTags(statestore.NewStateStore(), logger)
+	pivotAccounting := accountingmock.NewAccounting()
+	psPivot := pushsync.New(pivotNode, newTestSigner(t, pivotNode), 0, recorder, storerPivot, mockTopology, mockTopology, mtag, func(infinity.Chunk) {}, logger, pivotAccounting, accountingmock.NewPricer(fixedPrice, fixedPrice), nil)
+
+	// there is no other peer to push the chunk to after the invalid receipt
+	if _, err := psPivot.PushChunkToClosest(context.Background(), chunk); err == nil {
+		t.Fatal("got no error")
+	}
+
+	if blocklisted, _ := recorder.IsBlocklisted(storerPeer); !blocklisted {
+		t.Error("peer not blocklisted")
+	}
+
+	// the receipt is not paid for
+	balance, err := pivotAccounting.Balance(storerPeer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Int64() != 0 {
+		t.Fatalf("got balance %d, want 0", balance)
+	}
+}
+
+// TestPushChunkDeprecatedVersion tests that the chunks are pushed to the peers
+// on the deprecated version of the protocol, which do not sign the receipts,
+// and that their receipts are passed back only to the peers on the same
+// version.
+func TestPushChunkDeprecatedVersion(t *testing.T) {
+	chunk := testingc.FixtureChunk("7000")
+
+	pivotPeer := infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000000")
+	triggerPeer := infinity.MustParseHexAddress("6000000000000000000000000000000000000000000000000000000000000000")
+	closestPeer := infinity.MustParseHexAddress("f000000000000000000000000000000000000000000000000000000000000000")
+
+	// the closest peer answers with the receipt of the chunk address only
+	deprecated := p2p.ProtocolSpec{
+		Name:    pushsync.ProtocolName,
+		Version: pushsync.DeprecatedProtocolVersion,
+		StreamSpecs: []p2p.StreamSpec{{
+			Name: pushsync.StreamName,
+			Handler: func(ctx context.Context, p p2p.Peer, stream p2p.Stream) error {
+				w, r := protobuf.NewWriterAndReader(stream)
+				var d pb.Delivery
+				if err := r.ReadMsgWithContext(ctx, &d); err != nil {
+					return err
+				}
+				return w.WriteMsgWithContext(ctx, &pb.Receipt{Address: d.Address})
+			},
+		}},
+	}
+	closestRecorder := streamtest.New(streamtest.WithProtocols(deprecated), streamtest.WithBaseAddr(pivotPeer))
+
+	psPivot, storerPivotDB, _, pivotAccounting := createPushSyncNode(t, pivotPeer, closestRecorder, nil, mock.WithClosestPeer(closestPeer))
+	defer storerPivotDB.Close()
+
+	receipt, err := psPivot.PushChunkToClosest(context.Background(), chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chunk.Address().Equal(receipt.Address) {
+		t.Fatal("invalid receipt")
+	}
+	balance, err := pivotAccounting.Balance(closestPeer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Int64() != -int64(fixedPrice) {
+		t.Fatalf("got balance %d, want %d", balance, -int64(fixedPrice))
+	}
+
+	recorder := streamtest.New(streamtest.WithProtocols(psPivot.Protocol(), psPivot.DeprecatedProtocol()), streamtest.WithBaseAddr(triggerPeer))
+
+	for _, tc := range []struct {
+		version string
+		want    protoerr.Code
+	}{
+		{version: pushsync.ProtocolVersion, want: protoerr.CodeNoForwarder},
+		{version: pushsync.DeprecatedProtocolVersion},
+	} {
+		t.Run(tc.version, func(t *testing.T) {
+			stream, err := recorder.NewStream(context.Background(), pivotPeer, nil, pushsync.ProtocolName, tc.version, pushsync.StreamName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stream.Close()
+
+			w, r := protobuf.NewWriterAndReader(stream)
+			if err := w.WriteMsg(&pb.Delivery{Address: chunk.Address().Bytes(), Data: chunk.Data()}); err != nil {
+				t.Fatal(err)
+			}
+			var receipt pb.Receipt
+			if err := r.ReadMsg(&receipt); err != nil {
+				t.Fatal(err)
+			}
+			if got := protoerr.Code(receipt.ErrorCode); got != tc.want {
+				t.Fatalf("got error code %v, want %v", got, tc.want)
+			}
+			if tc.want == protoerr.CodeNone && !bytes.Equal(receipt.Address, chunk.Address().Bytes()) {
+				t.Fatalf("got receipt address %x, want %s", receipt.Address, chunk.Address())
+			}
+		})
+	}
+}
+
+// newTestSigner returns the signer with a new key, which is the key of the
+// node with the overlay address in the tests.
+func newTestSigner(t *testing.T, addr infinity.Address) crypto.Signer {
+	t.Helper()
+
+	key, err := crypto.GenerateSecp256k1Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushsync.SetOverlayAddress(&key.PublicKey, addr)
+	return crypto.NewDefaultSigner(key)
+}
+
 func createPushSyncNode(t *testing.T, addr infinity.Address, recorder *streamtest.Recorder, unwrap func(infinity.Chunk), mockOpts ...mock.Option) (*pushsync.PushSync, *localstore.DB, *tags.Tags, accounting.Interface) {
+	t.Helper()
+	return createPushSyncNodeWithSigner(t, addr, newTestSigner(t, addr), recorder, unwrap, mockOpts...)
+}
+
+func createPushSyncNodeWithSigner(t *testing.T, addr infinity.Address, signer crypto.Signer, recorder *streamtest.Recorder, unwrap func(infinity.Chunk), mockOpts ...mock.Option) (*pushsync.PushSync, *localstore.DB, *tags.Tags, accounting.Interface) {
 	t.Helper()
 	logger := logging.New(ioutil.Discard, 0)
 
@@ -404,7 +970,7 @@ func createPushSyncNode(t *testing.T, addr infinity.Address, recorder *streamtes
 		unwrap = func(infinity.Chunk) {}
 	}
 
-	return pushsync.New(recorderDisconnecter, storer, mockTopology, mtag, unwrap, logger, mockAccounting, mockPricer, nil), storer, mtag, mockAccounting
+	return pushsync.New(addr, signer, 0, recorderDisconnecter, storer, mockTopology, mockTopology, mtag, unwrap, logger, mockAccounting, mockPricer, nil), storer, mtag, mockAccounting
 }
 
 func waitOnRecordAndTest(t *testing.T, peer infinity.Address, recorder *streamtest.Recorder, add infinity.Address, data []byte) {
@@ -436,7 +1002,7 @@ func waitOnRecordAndTest(t *testing.T, peer infinity.Address, recorder *streamte
 		}
 	} else {
 		messages, err := protobuf.ReadMessages(
-			bytes.NewReader(records[0].In()),
+			bytes.NewReader(records[0].Out()),
 			func() protobuf.Message { return new(pb.Receipt) },
 		)
 		if err != nil {
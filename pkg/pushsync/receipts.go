@@ -0,0 +1,98 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pushsync
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/pushsync/pb"
+)
+
+const (
+	// receiptsTTL is the duration for which the repeated pushes of a chunk
+	// are answered with the receipt issued for it, without storing or
+	// forwarding the chunk and paying for it again.
+	receiptsTTL = time.Minute
+	// maxReceipts is the max number of the cached receipts.
+	maxReceipts = 10000
+)
+
+// timeNow is used to deterministically mock time.Now() in tests.
+var timeNow = time.Now
+
+// receiptCache holds the receipts recently issued by this node for the chunks
+// it stored or received from the peers for the chunks it forwarded, the
+// oldest are forgotten first. The receipts are cached by the address and the
+// data of the chunk, as the single owner chunks uploaded again with the same
+// address and new data must be pushed again.
+type receiptCache struct {
+	receipts map[string]cachedReceipt
+	order    []cachedReceiptKey
+	mu       sync.Mutex
+}
+
+type cachedReceipt struct {
+	receipt pb.Receipt
+	expires time.Time
+}
+
+type cachedReceiptKey struct {
+	key     string
+	expires time.Time
+}
+
+func newReceiptCache() *receiptCache {
+	return &receiptCache{
+		receipts: make(map[string]cachedReceipt),
+	}
+}
+
+// add caches the receipt of the chunk.
+func (c *receiptCache) add(ch infinity.Chunk, r *pb.Receipt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := timeNow()
+	c.prune(now)
+
+	k := cachedReceiptKey{key: receiptKey(ch), expires: now.Add(receiptsTTL)}
+	c.receipts[k.key] = cachedReceipt{receipt: *r, expires: k.expires}
+	c.order = append(c.order, k)
+}
+
+// get returns a copy of the receipt of the chunk, if it has not expired.
+func (c *receiptCache) get(ch infinity.Chunk) (*pb.Receipt, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r, ok := c.receipts[receiptKey(ch)]
+	if !ok || !timeNow().Before(r.expires) {
+		return nil, false
+	}
+	receipt := r.receipt
+	return &receipt, true
+}
+
+// receiptKey returns the cache key of the receipt of the chunk.
+func receiptKey(ch infinity.Chunk) string {
+	h := sha256.Sum256(ch.Data())
+	return ch.Address().ByteString() + string(h[:])
+}
+
+// prune forgets the expired receipts and the oldest ones over the max number.
+// It must be called with the lock held.
+func (c *receiptCache) prune(now time.Time) {
+	for len(c.order) > 0 && (len(c.order) >= maxReceipts || !now.Before(c.order[0].expires)) {
+		k := c.order[0]
+		// the receipt is not forgotten if it was cached again later
+		if c.receipts[k.key].expires.Equal(k.expires) {
+			delete(c.receipts, k.key)
+		}
+		c.order = c.order[1:]
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recovery
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	m "github.com/yanhuangpai/voyager/pkg/metrics"
+)
+
+type metrics struct {
+	RequestsReceived prometheus.Counter // number of recovery requests received
+	RequestsInvalid  prometheus.Counter // number of malformed recovery requests
+	ChunksNotFound   prometheus.Counter // number of requested chunks not in the local store
+	ChunksRepaired   prometheus.Counter // number of chunks pushed to the network
+	RepairErrors     prometheus.Counter // number of failed chunk repairs
+	ResponsesSent    prometheus.Counter // number of responses sent to the requesters
+	ResponseErrors   prometheus.Counter // number of responses failed to be sent
+}
+
+func newMetrics() metrics {
+	subsystem := "recovery"
+
+	return metrics{
+		RequestsReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "requests_received",
+			Help:      "Total recovery requests received.",
+		}),
+		RequestsInvalid: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "requests_invalid",
+			Help:      "Total malformed recovery requests.",
+		}),
+		ChunksNotFound: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "chunks_not_found",
+			Help:      "Total requested chunks not found in the local store.",
+		}),
+		ChunksRepaired: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "chunks_repaired",
+			Help:      "Total requested chunks pushed to the network.",
+		}),
+		RepairErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "repair_errors",
+			Help:      "Total failed chunk repairs.",
+		}),
+		ResponsesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "responses_sent",
+			Help:      "Total responses sent to the requesters.",
+		}),
+		ResponseErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "response_errors",
+			Help:      "Total responses failed to be sent.",
+		}),
+	}
+}
+
+// Metrics returns the metrics of the responder.
+func (r *Responder) Metrics() []prometheus.Collector {
+	return m.PrometheusCollectorsFromFields(r.metrics)
+}
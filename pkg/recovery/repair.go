@@ -5,67 +5,269 @@
 package recovery
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/pss"
 	"github.com/yanhuangpai/voyager/pkg/pushsync"
+	"github.com/yanhuangpai/voyager/pkg/recovery/pb"
 	"github.com/yanhuangpai/voyager/pkg/storage"
 )
 
 const (
 	// TopicText is the string used to construct the recovery topic.
 	TopicText = "RECOVERY"
+	// ResponseTopicText is the string used to construct the recovery
+	// response topic.
+	ResponseTopicText = "RECOVERY_RESPONSE"
+	// Version is the version of the repair request and response messages.
+	Version = 1
+	// MaxTargetLength is the maximal length of the targets of the responses,
+	// which limits the work of mining the response messages.
+	MaxTargetLength = 2
+	// RequestTTL is the maximal difference between the timestamp of a request
+	// and the time it is handled at.
+	RequestTTL = time.Minute
+
+	// requestIDSize is the size of the random ID which distinguishes the
+	// requests signed at the same time.
+	requestIDSize = 16
 )
 
+// timeNow is used to deterministically mock time.Now() in tests.
+var timeNow = time.Now
+
 var (
 	// Topic is the topic used for repairing globally pinned chunks.
 	Topic = pss.NewTopic(TopicText)
+	// ResponseTopic is the topic used for the responses to the repair
+	// requests.
+	ResponseTopic = pss.NewTopic(ResponseTopicText)
+
+	errInvalidVersion  = errors.New("unsupported message version")
+	errInvalidAddress  = errors.New("invalid chunk address")
+	errInvalidTargets  = errors.New("invalid targets")
+	errInvalidProof    = errors.New("invalid proof")
+	errStaleRequest    = errors.New("stale request")
+	errReplayedRequest = errors.New("replayed request")
 )
 
-// Callback defines code to be executed upon failing to retrieve chunks.
-type Callback func(chunkAddress infinity.Address, targets pss.Targets)
+// recipient returns the public key the messages on the topic are encrypted
+// to, which every node handling the topic can decrypt.
+func recipient(text string) *ecdsa.PublicKey {
+	return &crypto.Secp256k1PrivateKeyFromBytes([]byte(text)).PublicKey
+}
 
-// NewsCallback returns a new Callback with the sender function defined.
-func NewCallback(pssSender pss.Sender) Callback {
-	privk := crypto.Secp256k1PrivateKeyFromBytes([]byte(TopicText))
-	recipient := privk.PublicKey
-	return func(chunkAddress infinity.Address, targets pss.Targets) {
-		payload := chunkAddress
-		ctx := context.Background()
-		_ = pssSender.Send(ctx, Topic, payload.Bytes(), &recipient, targets)
+// proofData returns the data signed by the requester as the proof of the need
+// of the chunk. The timestamp and the ID make every request unique, so that it
+// can not be replayed.
+func proofData(addr []byte, targets [][]byte, timestamp int64, id []byte) []byte {
+	data := append([]byte(nil), addr...)
+	for _, t := range targets {
+		data = append(data, t...)
 	}
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, uint64(timestamp))
+	data = append(data, ts...)
+	return append(data, id...)
 }
 
-// NewRepairHandler creates a repair function to re-upload globally pinned chunks to the network with the given store.
-func NewRepairHandler(s storage.Storer, logger logging.Logger, pushSyncer pushsync.PushSyncer) pss.Handler {
-	return func(ctx context.Context, m []byte) {
-		chAddr := m
+// Responder repairs the globally pinned chunks on the requests of the nodes
+// which failed to retrieve them. A node acting as a pinner of the content
+// responds to the requests for the chunks it stores by pushing them to their
+// neighborhood again and reports the result to the requester.
+type Responder struct {
+	storer     storage.Storer
+	logger     logging.Logger
+	pushSyncer pushsync.PushSyncer
+	sender     pss.Sender
+	networkID  uint64
+	metrics    metrics
 
-		// check if the chunk exists in the local store and proceed.
-		// otherwise the Get will trigger a unnecessary network retrieve
-		exists, err := s.Has(ctx, infinity.NewAddress(chAddr))
-		if err != nil {
-			return
-		}
-		if !exists {
-			return
-		}
+	mu       sync.Mutex
+	inflight map[string]struct{}  // chunks being repaired
+	seen     map[string]time.Time // expiry of the handled requests by their proofs
+	pruned   time.Time            // last time the expired requests were pruned
+}
+
+// NewResponder returns a Responder repairing the chunks from the storer and
+// sending the responses with the sender. The requests must be signed by the
+// nodes in the network with the network ID.
+func NewResponder(s storage.Storer, logger logging.Logger, pushSyncer pushsync.PushSyncer, sender pss.Sender, networkID uint64) *Responder {
+	return &Responder{
+		storer:     s,
+		logger:     logger,
+		pushSyncer: pushSyncer,
+		sender:     sender,
+		networkID:  networkID,
+		metrics:    newMetrics(),
+		inflight:   make(map[string]struct{}),
+		seen:       make(map[string]time.Time),
+	}
+}
+
+// Handle handles the repair request message. It is to be registered as the
+// pss handler of the recovery Topic. Requests for a chunk which is already
+// being repaired are ignored, as well as the stale and the replayed requests.
+func (r *Responder) Handle(ctx context.Context, m []byte) {
+	r.metrics.RequestsReceived.Inc()
+
+	var req pb.Request
+	if err := req.Unmarshal(m); err != nil {
+		r.metrics.RequestsInvalid.Inc()
+		r.logger.Tracef("chunk repair: unmarshal request: %v", err)
+		return
+	}
+	if err := r.validate(&req); err != nil {
+		r.metrics.RequestsInvalid.Inc()
+		r.logger.Tracef("chunk repair: invalid request: %v", err)
+		return
+	}
+	addr := infinity.NewAddress(req.Address)
+
+	r.mu.Lock()
+	if err := r.markSeen(&req); err != nil {
+		r.mu.Unlock()
+		r.metrics.RequestsInvalid.Inc()
+		r.logger.Tracef("chunk repair: invalid request: %v", err)
+		return
+	}
+	if _, ok := r.inflight[addr.ByteString()]; ok {
+		r.mu.Unlock()
+		return
+	}
+	r.inflight[addr.ByteString()] = struct{}{}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.inflight, addr.ByteString())
+		r.mu.Unlock()
+	}()
+
+	repaired := r.repair(ctx, addr)
+
+	payload, err := (&pb.Response{
+		Version:  Version,
+		Address:  addr.Bytes(),
+		Repaired: repaired,
+	}).Marshal()
+	if err != nil {
+		r.logger.Tracef("chunk repair: marshal response: %v", err)
+		return
+	}
+	targets := make(pss.Targets, len(req.Targets))
+	for i, t := range req.Targets {
+		targets[i] = t
+	}
+	if err := r.sender.Send(ctx, ResponseTopic, payload, recipient(ResponseTopicText), targets); err != nil {
+		r.metrics.ResponseErrors.Inc()
+		r.logger.Tracef("chunk repair: send response for chunk %s: %v", addr, err)
+		return
+	}
+	r.metrics.ResponsesSent.Inc()
+}
+
+// validate checks the version and the address of the request and verifies
+// that it is signed by a node in the neighborhood of the targets of the
+// response.
+func (r *Responder) validate(req *pb.Request) error {
+	if req.Version != Version {
+		return fmt.Errorf("%w: %d", errInvalidVersion, req.Version)
+	}
+	if len(req.Address) != infinity.HashSize {
+		return errInvalidAddress
+	}
+	if len(req.Targets) == 0 {
+		return errInvalidTargets
+	}
 
-		// retrieve the chunk from the local store
-		ch, err := s.Get(ctx, storage.ModeGetRequest, infinity.NewAddress(chAddr))
-		if err != nil {
-			logger.Tracef("chunk repair: error while getting chunk for repairing: %v", err)
-			return
+	if len(req.Nonce) > crypto.MaxOverlayNonceSize {
+		return fmt.Errorf("%w: nonce too long", errInvalidProof)
+	}
+	if len(req.ID) != requestIDSize {
+		return fmt.Errorf("%w: invalid id", errInvalidProof)
+	}
+	if age := timeNow().Sub(time.Unix(0, req.Timestamp)); age > RequestTTL || age < -RequestTTL {
+		return fmt.Errorf("%w: %v old", errStaleRequest, age)
+	}
+	pubKey, err := crypto.Recover(req.Proof, proofData(req.Address, req.Targets, req.Timestamp, req.ID))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidProof, err)
+	}
+	overlay, err := crypto.NewOverlayAddress(*pubKey, r.networkID, req.Nonce)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errInvalidProof, err)
+	}
+	for _, t := range req.Targets {
+		if len(t) == 0 || len(t) > MaxTargetLength || !bytes.HasPrefix(overlay.Bytes(), t) {
+			return errInvalidTargets
 		}
+	}
+	return nil
+}
 
-		// push the chunk using push sync so that it reaches it destination in network
-		_, err = pushSyncer.PushChunkToClosest(ctx, ch)
-		if err != nil {
-			logger.Tracef("chunk repair: error while sending chunk or receiving receipt: %v", err)
-			return
+// markSeen records the validated request until its timestamp becomes stale,
+// returning an error if it is already recorded. It must be called with the
+// mutex held.
+func (r *Responder) markSeen(req *pb.Request) error {
+	now := timeNow()
+	if now.Sub(r.pruned) > RequestTTL {
+		for k, expiry := range r.seen {
+			if now.After(expiry) {
+				delete(r.seen, k)
+			}
 		}
+		r.pruned = now
+	}
+
+	key := string(req.Proof)
+	if _, ok := r.seen[key]; ok {
+		return errReplayedRequest
+	}
+	r.seen[key] = time.Unix(0, req.Timestamp).Add(RequestTTL)
+	return nil
+}
+
+// repair pushes the chunk from the local store to the network and reports
+// whether it succeeded.
+func (r *Responder) repair(ctx context.Context, addr infinity.Address) bool {
+	// check if the chunk exists in the local store and proceed.
+	// otherwise the Get will trigger a unnecessary network retrieve
+	exists, err := r.storer.Has(ctx, addr)
+	if err != nil {
+		r.metrics.RepairErrors.Inc()
+		r.logger.Tracef("chunk repair: error while checking chunk %s: %v", addr, err)
+		return false
+	}
+	if !exists {
+		r.metrics.ChunksNotFound.Inc()
+		return false
+	}
+
+	// retrieve the chunk from the local store
+	ch, err := r.storer.Get(ctx, storage.ModeGetRequest, addr)
+	if err != nil {
+		r.metrics.RepairErrors.Inc()
+		r.logger.Tracef("chunk repair: error while getting chunk for repairing: %v", err)
+		return false
+	}
+
+	// push the chunk using push sync so that it reaches it destination in network
+	_, err = r.pushSyncer.PushChunkToClosest(ctx, ch)
+	if err != nil {
+		r.metrics.RepairErrors.Inc()
+		r.logger.Tracef("chunk repair: error while sending chunk or receiving receipt: %v", err)
+		return false
 	}
+	r.metrics.ChunksRepaired.Inc()
+	return true
 }
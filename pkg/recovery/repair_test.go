@@ -5,6 +5,7 @@
 package recovery_test
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"errors"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	accountingmock "github.com/yanhuangpai/voyager/pkg/accounting/mock"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/netstore"
@@ -21,6 +23,7 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/pushsync"
 	pushsyncmock "github.com/yanhuangpai/voyager/pkg/pushsync/mock"
 	"github.com/yanhuangpai/voyager/pkg/recovery"
+	"github.com/yanhuangpai/voyager/pkg/recovery/pb"
 	"github.com/yanhuangpai/voyager/pkg/retrieval"
 	"github.com/yanhuangpai/voyager/pkg/sctx"
 	"github.com/yanhuangpai/voyager/pkg/storage"
@@ -43,7 +46,7 @@ func TestCallback(t *testing.T) {
 	}
 
 	// create recovery callback and call it
-	recoveryCallback := recovery.NewCallback(pssSender)
+	recoveryCallback := newTestRequester(t, pssSender).Callback()
 	go recoveryCallback(chunkAddr, targets)
 
 	select {
@@ -85,7 +88,7 @@ func TestCallbackCalls(t *testing.T) {
 			pssSender := &mockPssSender{
 				callbackC: callbackWasCalled,
 			}
-			recoverFunc := recovery.NewCallback(pssSender)
+			recoverFunc := newTestRequester(t, pssSender).Callback()
 			ns := newTestNetStore(t, recoverFunc)
 
 			// fetch test chunk
@@ -111,105 +114,284 @@ func TestCallbackCalls(t *testing.T) {
 	}
 }
 
-// TestNewRepairHandler tests the function of repairing a chunk when a request for chunk repair is received.
-func TestNewRepairHandler(t *testing.T) {
+// TestResponderRepair tests the function of repairing a chunk when a request for chunk repair is received.
+func TestResponderRepair(t *testing.T) {
 	logger := logging.New(ioutil.Discard, 0)
 
-	t.Run("repair-chunk", func(t *testing.T) {
-		// generate test chunk, store and publisher
-		c1 := chunktesting.GenerateTestRandomChunk()
+	for _, tc := range []struct {
+		name         string
+		stored       bool
+		pushErr      error
+		wantPushed   bool
+		wantRepaired bool
+	}{
+		{name: "repair-chunk", stored: true, wantPushed: true, wantRepaired: true},
+		{name: "repair-chunk-not-present"},
+		{name: "repair-chunk-closest-peer-not-present", stored: true, pushErr: errors.New("invalid receipt"), wantPushed: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := chunktesting.GenerateTestRandomChunk()
+
+			// create a mock storer and put a chunk that will be repaired
+			mockStorer := storemock.NewStorer()
+			defer mockStorer.Close()
+			if tc.stored {
+				if _, err := mockStorer.Put(context.Background(), storage.ModePutRequest, c); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// create a mock pushsync service to push the chunk to its destination
+			var pushed infinity.Address
+			pushSyncService := pushsyncmock.New(func(ctx context.Context, chunk infinity.Chunk) (*pushsync.Receipt, error) {
+				pushed = chunk.Address()
+				if tc.pushErr != nil {
+					return nil, tc.pushErr
+				}
+				return &pushsync.Receipt{Address: chunk.Address()}, nil
+			})
+
+			var response *pb.Response
+			responseSender := pssSenderFunc(func(_ context.Context, topic pss.Topic, payload []byte, _ *ecdsa.PublicKey, _ pss.Targets) error {
+				if topic != recovery.ResponseTopic {
+					t.Fatalf("got response topic %x, want %x", topic, recovery.ResponseTopic)
+				}
+				response = new(pb.Response)
+				return response.Unmarshal(payload)
+			})
+			responder := recovery.NewResponder(mockStorer, logger, pushSyncService, responseSender, testNetworkID)
+
+			// invoke the chunk repair handler
+			responder.Handle(context.Background(), newTestRequest(t, c.Address()))
+
+			if got := !pushed.IsZero(); got != tc.wantPushed {
+				t.Fatalf("got chunk pushed %v, want %v", got, tc.wantPushed)
+			}
+			if tc.wantPushed && !pushed.Equal(c.Address()) {
+				t.Fatalf("got pushed chunk %s, want %s", pushed, c.Address())
+			}
+			if response == nil {
+				t.Fatal("response not sent")
+			}
+			if !bytes.Equal(response.Address, c.Address().Bytes()) {
+				t.Fatalf("got response address %x, want %s", response.Address, c.Address())
+			}
+			if response.Repaired != tc.wantRepaired {
+				t.Fatalf("got repaired %v, want %v", response.Repaired, tc.wantRepaired)
+			}
+		})
+	}
+}
 
-		// create a mock storer and put a chunk that will be repaired
-		mockStorer := storemock.NewStorer()
-		defer mockStorer.Close()
-		_, err := mockStorer.Put(context.Background(), storage.ModePutRequest, c1)
+func TestResponder(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	c := chunktesting.GenerateTestRandomChunk()
+
+	mockStorer := storemock.NewStorer()
+	defer mockStorer.Close()
+	_, err := mockStorer.Put(context.Background(), storage.ModePutRequest, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		pushed  = make(chan infinity.Address, 10)
+		release = make(chan struct{})
+	)
+	pushSyncService := pushsyncmock.New(func(ctx context.Context, chunk infinity.Chunk) (*pushsync.Receipt, error) {
+		pushed <- chunk.Address()
+		<-release
+		return &pushsync.Receipt{Address: chunk.Address()}, nil
+	})
+	responseSender := pssSenderFunc(func(context.Context, pss.Topic, []byte, *ecdsa.PublicKey, pss.Targets) error {
+		return nil
+	})
+	responder := recovery.NewResponder(mockStorer, logger, pushSyncService, responseSender, testNetworkID)
+
+	t.Run("invalid request", func(t *testing.T) {
+		// signed by a node in another network
+		var req pb.Request
+		if err := req.Unmarshal(newTestRequest(t, c.Address())); err != nil {
+			t.Fatal(err)
+		}
+		req.Targets = [][]byte{{0xff, 0xff}}
+		invalidTargets, err := req.Marshal()
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		// create a mock pushsync service to push the chunk to its destination
-		var receipt *pushsync.Receipt
-		pushSyncService := pushsyncmock.New(func(ctx context.Context, chunk infinity.Chunk) (*pushsync.Receipt, error) {
-			receipt = &pushsync.Receipt{
-				Address: infinity.NewAddress(chunk.Address().Bytes()),
+		for _, m := range [][]byte{
+			{1, 2, 3},
+			c.Address().Bytes(),
+			invalidTargets,
+		} {
+			responder.Handle(context.Background(), m)
+
+			select {
+			case <-pushed:
+				t.Fatal("chunk pushed for invalid request")
+			default:
 			}
-			return receipt, nil
-		})
+		}
+	})
 
-		// create the chunk repair handler
-		repairHandler := recovery.NewRepairHandler(mockStorer, logger, pushSyncService)
+	t.Run("duplicate request", func(t *testing.T) {
+		request := newTestRequest(t, c.Address())
 
-		// invoke the chunk repair handler
-		repairHandler(context.Background(), c1.Address().Bytes())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			responder.Handle(context.Background(), request)
+		}()
 
-		// check if receipt is received
-		if receipt == nil {
-			t.Fatal("receipt not received")
+		select {
+		case addr := <-pushed:
+			if !addr.Equal(c.Address()) {
+				t.Fatalf("got pushed chunk %s, want %s", addr, c.Address())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("chunk not pushed")
 		}
 
-		if !receipt.Address.Equal(c1.Address()) {
-			t.Fatalf("invalid address in receipt: expected %s received %s", c1.Address(), receipt.Address)
+		// the chunk is being repaired
+		responder.Handle(context.Background(), request)
+		close(release)
+		<-done
+
+		select {
+		case <-pushed:
+			t.Fatal("chunk pushed for duplicate request")
+		default:
 		}
 
+		// the repair is done and the chunk can be repaired again on a new
+		// request
+		responder.Handle(context.Background(), newTestRequest(t, c.Address()))
+		select {
+		case <-pushed:
+		default:
+			t.Fatal("chunk not pushed after the repair")
+		}
 	})
 
-	t.Run("repair-chunk-not-present", func(t *testing.T) {
-		// generate test chunk, store and publisher
-		c2 := chunktesting.GenerateTestRandomChunk()
+	t.Run("replayed request", func(t *testing.T) {
+		request := newTestRequest(t, c.Address())
 
-		// create a mock storer
-		mockStorer := storemock.NewStorer()
-		defer mockStorer.Close()
+		responder.Handle(context.Background(), request)
+		select {
+		case <-pushed:
+		default:
+			t.Fatal("chunk not pushed")
+		}
 
-		// create a mock pushsync service
-		pushServiceCalled := false
-		pushSyncService := pushsyncmock.New(func(ctx context.Context, chunk infinity.Chunk) (*pushsync.Receipt, error) {
-			pushServiceCalled = true
-			return nil, nil
-		})
+		responder.Handle(context.Background(), request)
+		select {
+		case <-pushed:
+			t.Fatal("chunk pushed for replayed request")
+		default:
+		}
+	})
 
-		// create the chunk repair handler
-		repairHandler := recovery.NewRepairHandler(mockStorer, logger, pushSyncService)
+	t.Run("stale request", func(t *testing.T) {
+		defer recovery.SetTimeNow(time.Now)
 
-		// invoke the chunk repair handler
-		repairHandler(context.Background(), c2.Address().Bytes())
+		for _, d := range []time.Duration{-2 * recovery.RequestTTL, 2 * recovery.RequestTTL} {
+			recovery.SetTimeNow(func() time.Time { return time.Now().Add(d) })
+			request := newTestRequest(t, c.Address())
+			recovery.SetTimeNow(time.Now)
 
-		if pushServiceCalled {
-			t.Fatal("push service called even if the chunk is not present")
+			responder.Handle(context.Background(), request)
+			select {
+			case <-pushed:
+				t.Fatalf("chunk pushed for request signed %v from now", d)
+			default:
+			}
 		}
 	})
+}
 
-	t.Run("repair-chunk-closest-peer-not-present", func(t *testing.T) {
-		// generate test chunk, store and publisher
-		c3 := chunktesting.GenerateTestRandomChunk()
+func TestRequester(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	c := chunktesting.GenerateTestRandomChunk()
 
-		// create a mock storer
-		mockStorer := storemock.NewStorer()
-		defer mockStorer.Close()
-		_, err := mockStorer.Put(context.Background(), storage.ModePutRequest, c3)
-		if err != nil {
-			t.Fatal(err)
+	mockStorer := storemock.NewStorer()
+	defer mockStorer.Close()
+	_, err := mockStorer.Put(context.Background(), storage.ModePutRequest, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushSyncService := pushsyncmock.New(func(ctx context.Context, chunk infinity.Chunk) (*pushsync.Receipt, error) {
+		return &pushsync.Receipt{Address: chunk.Address()}, nil
+	})
+
+	// the messages are delivered directly between the requester and the
+	// responder
+	var requester *recovery.Requester
+	responder := recovery.NewResponder(mockStorer, logger, pushSyncService, pssSenderFunc(func(ctx context.Context, _ pss.Topic, payload []byte, _ *ecdsa.PublicKey, _ pss.Targets) error {
+		go requester.HandleResponse(ctx, payload)
+		return nil
+	}), testNetworkID)
+	requester = newTestRequester(t, pssSenderFunc(func(ctx context.Context, topic pss.Topic, payload []byte, _ *ecdsa.PublicKey, targets pss.Targets) error {
+		if topic != recovery.Topic {
+			t.Fatalf("got request topic %x, want %x", topic, recovery.Topic)
 		}
+		go responder.Handle(context.Background(), payload)
+		return nil
+	}))
 
-		// create a mock pushsync service
-		var receiptError error
-		pushSyncService := pushsyncmock.New(func(ctx context.Context, chunk infinity.Chunk) (*pushsync.Receipt, error) {
-			receiptError = errors.New("invalid receipt")
-			return nil, receiptError
-		})
+	t.Run("repaired", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
 
-		// create the chunk repair handler
-		repairHandler := recovery.NewRepairHandler(mockStorer, logger, pushSyncService)
+		if err := requester.Request(ctx, c.Address(), pss.Targets{{0x01}}); err != nil {
+			t.Fatal(err)
+		}
+	})
 
-		// invoke the chunk repair handler
-		repairHandler(context.Background(), c3.Address().Bytes())
+	t.Run("not repaired", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
 
-		if receiptError == nil {
-			t.Fatal("pushsync did not generate a receipt error")
+		missing := chunktesting.GenerateTestRandomChunk().Address()
+		if err := requester.Request(ctx, missing, pss.Targets{{0x01}}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
 		}
 	})
 }
 
+const testNetworkID = 1
+
+// newTestRequester returns a requester of a node with a random key and the
+// overlay address derived with a nonce.
+func newTestRequester(t *testing.T, sender pss.Sender) *recovery.Requester {
+	t.Helper()
+
+	key, err := crypto.GenerateSecp256k1Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte{1}
+	overlay, err := crypto.NewOverlayAddress(key.PublicKey, testNetworkID, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recovery.NewRequester(sender, crypto.NewDefaultSigner(key), overlay, nonce, logging.New(ioutil.Discard, 0))
+}
+
+// newTestRequest returns a signed repair request message for the chunk.
+func newTestRequest(t *testing.T, addr infinity.Address) (request []byte) {
+	t.Helper()
+
+	requester := newTestRequester(t, pssSenderFunc(func(_ context.Context, _ pss.Topic, payload []byte, _ *ecdsa.PublicKey, _ pss.Targets) error {
+		request = payload
+		return nil
+	}))
+	if err := requester.Send(context.Background(), addr, pss.Targets{{0x01}}); err != nil {
+		t.Fatal(err)
+	}
+	return request
+}
+
 // newTestNetStore creates a test store with a set RemoteGet func.
 func newTestNetStore(t *testing.T, recoveryFunc recovery.Callback) storage.Storer {
 	t.Helper()
@@ -225,11 +407,11 @@ func newTestNetStore(t *testing.T, recoveryFunc recovery.Callback) storage.Store
 		_, _, _ = f(peerID, 0)
 		return nil
 	}}
-	server := retrieval.New(infinity.ZeroAddress, mockStorer, nil, ps, logger, serverMockAccounting, nil, nil)
+	server := retrieval.New(infinity.ZeroAddress, mockStorer, nil, ps, logger, serverMockAccounting, nil, nil, retrieval.Options{})
 	recorder := streamtest.New(
 		streamtest.WithProtocols(server.Protocol()),
 	)
-	retrieve := retrieval.New(infinity.ZeroAddress, mockStorer, recorder, ps, logger, serverMockAccounting, pricerMock, nil)
+	retrieve := retrieval.New(infinity.ZeroAddress, mockStorer, recorder, ps, logger, serverMockAccounting, pricerMock, nil, retrieval.Options{})
 	ns := netstore.New(storer, recoveryFunc, retrieve, logger)
 	return ns
 }
@@ -244,6 +426,13 @@ func (s mockPeerSuggester) EachPeer(topology.EachPeerFunc) error {
 func (s mockPeerSuggester) EachPeerRev(f topology.EachPeerFunc) error {
 	return s.eachPeerRevFunc(f)
 }
+func (s mockPeerSuggester) EachPeerWith(f topology.EachPeerFunc, opts ...topology.EachPeerOption) error {
+	o := topology.NewEachPeerOptions(opts...)
+	if !o.Reverse {
+		return errors.New("not implemented")
+	}
+	return s.eachPeerRevFunc(o.Select(f, nil))
+}
 
 type mockPssSender struct {
 	callbackC chan bool
@@ -254,3 +443,9 @@ func (mp *mockPssSender) Send(ctx context.Context, topic pss.Topic, payload []by
 	mp.callbackC <- true
 	return nil
 }
+
+type pssSenderFunc func(ctx context.Context, topic pss.Topic, payload []byte, recipient *ecdsa.PublicKey, targets pss.Targets) error
+
+func (f pssSenderFunc) Send(ctx context.Context, topic pss.Topic, payload []byte, recipient *ecdsa.PublicKey, targets pss.Targets) error {
+	return f(ctx, topic, payload, recipient, targets)
+}
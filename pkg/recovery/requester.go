@@ -0,0 +1,150 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recovery
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+
+	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/pss"
+	"github.com/yanhuangpai/voyager/pkg/recovery/pb"
+)
+
+// Callback defines code to be executed upon failing to retrieve chunks.
+type Callback func(chunkAddress infinity.Address, targets pss.Targets)
+
+// Requester requests the repair of the chunks which failed to be retrieved
+// from the pinner nodes in the targets and receives their responses.
+type Requester struct {
+	sender  pss.Sender
+	signer  crypto.Signer
+	targets [][]byte // targets of the responses, the requester neighborhood
+	nonce   []byte   // nonce of the requester overlay address
+	logger  logging.Logger
+
+	mu      sync.Mutex
+	waiting map[string][]chan struct{} // requests waiting for the repair
+}
+
+// NewRequester returns a Requester sending the requests with the sender,
+// signed by the signer of the node with the overlay address derived with the
+// nonce.
+func NewRequester(sender pss.Sender, signer crypto.Signer, overlay infinity.Address, nonce []byte, logger logging.Logger) *Requester {
+	return &Requester{
+		sender:  sender,
+		signer:  signer,
+		targets: [][]byte{overlay.Bytes()[:MaxTargetLength]},
+		nonce:   nonce,
+		logger:  logger,
+		waiting: make(map[string][]chan struct{}),
+	}
+}
+
+// Send sends the request for the repair of the chunk to the pinners in the
+// targets.
+func (r *Requester) Send(ctx context.Context, addr infinity.Address, targets pss.Targets) error {
+	id := make([]byte, requestIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("request id: %w", err)
+	}
+	timestamp := timeNow().UnixNano()
+	proof, err := r.signer.Sign(proofData(addr.Bytes(), r.targets, timestamp, id))
+	if err != nil {
+		return fmt.Errorf("sign request: %w", err)
+	}
+	payload, err := (&pb.Request{
+		Version:   Version,
+		Address:   addr.Bytes(),
+		Targets:   r.targets,
+		Proof:     proof,
+		Nonce:     r.nonce,
+		Timestamp: timestamp,
+		ID:        id,
+	}).Marshal()
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	return r.sender.Send(ctx, Topic, payload, recipient(TopicText), targets)
+}
+
+// Request sends the request for the repair of the chunk and waits until a
+// pinner responds that the chunk is repaired or the context is done.
+func (r *Requester) Request(ctx context.Context, addr infinity.Address, targets pss.Targets) error {
+	c := make(chan struct{})
+	key := addr.ByteString()
+
+	r.mu.Lock()
+	r.waiting[key] = append(r.waiting[key], c)
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		waiting := r.waiting[key]
+		for i, w := range waiting {
+			if w == c {
+				waiting = append(waiting[:i], waiting[i+1:]...)
+				break
+			}
+		}
+		if len(waiting) == 0 {
+			delete(r.waiting, key)
+		} else {
+			r.waiting[key] = waiting
+		}
+	}()
+
+	if err := r.Send(ctx, addr, targets); err != nil {
+		return err
+	}
+
+	select {
+	case <-c:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// HandleResponse handles the response message of a pinner. It is to be
+// registered as the pss handler of the ResponseTopic.
+func (r *Requester) HandleResponse(_ context.Context, m []byte) {
+	var resp pb.Response
+	if err := resp.Unmarshal(m); err != nil {
+		r.logger.Tracef("chunk repair: unmarshal response: %v", err)
+		return
+	}
+	if resp.Version != Version || len(resp.Address) != infinity.HashSize {
+		r.logger.Tracef("chunk repair: invalid response")
+		return
+	}
+	addr := infinity.NewAddress(resp.Address)
+	if !resp.Repaired {
+		r.logger.Tracef("chunk repair: chunk %s not repaired by a pinner", addr)
+		return
+	}
+
+	r.mu.Lock()
+	waiting := r.waiting[addr.ByteString()]
+	delete(r.waiting, addr.ByteString())
+	r.mu.Unlock()
+
+	for _, c := range waiting {
+		close(c)
+	}
+}
+
+// Callback returns the Callback sending the repair requests.
+func (r *Requester) Callback() Callback {
+	return func(chunkAddress infinity.Address, targets pss.Targets) {
+		if err := r.Send(context.Background(), chunkAddress, targets); err != nil {
+			r.logger.Tracef("chunk repair: send request for chunk %s: %v", chunkAddress, err)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/resolver"
+	"github.com/yanhuangpai/voyager/pkg/resolver/client"
 	"github.com/yanhuangpai/voyager/pkg/resolver/client/ens"
 	"github.com/yanhuangpai/voyager/pkg/resolver/multiresolver/multierror"
 )
@@ -135,6 +136,17 @@ func (mr *MultiResolver) GetChain(tld string) []resolver.Interface {
 	return mr.resolvers[tld]
 }
 
+// IsConnected reports whether the resolution chain for the given TLD has a
+// resolver client connected to the endpoint.
+func (mr *MultiResolver) IsConnected(tld, endpoint string) bool {
+	for _, r := range mr.resolvers[tld] {
+		if c, ok := r.(client.Interface); ok && c.Endpoint() == endpoint {
+			return c.IsConnected()
+		}
+	}
+	return false
+}
+
 // Resolve will attempt to resolve a name to an address.
 // The resolution chain is selected based on the TLD of the name. If the name
 // does not end in a TLD, the default resolution chain is selected.
@@ -105,6 +105,37 @@ func TestPushResolver(t *testing.T) {
 	})
 }
 
+type testClient struct {
+	resolver.Interface
+	endpoint  string
+	connected bool
+}
+
+func (c *testClient) Endpoint() string  { return c.endpoint }
+func (c *testClient) IsConnected() bool { return c.connected }
+
+func TestIsConnected(t *testing.T) {
+	mr := multiresolver.NewMultiResolver()
+	mr.PushResolver(".tld", mock.NewResolver())
+	mr.PushResolver(".tld", &testClient{Interface: mock.NewResolver(), endpoint: "connected", connected: true})
+	mr.PushResolver(".tld", &testClient{Interface: mock.NewResolver(), endpoint: "disconnected"})
+
+	for _, tC := range []struct {
+		tld      string
+		endpoint string
+		want     bool
+	}{
+		{tld: ".tld", endpoint: "connected", want: true},
+		{tld: ".tld", endpoint: "disconnected", want: false},
+		{tld: ".tld", endpoint: "unknown", want: false},
+		{tld: "", endpoint: "connected", want: false},
+	} {
+		if got := mr.IsConnected(tC.tld, tC.endpoint); got != tC.want {
+			t.Errorf("tld %q endpoint %q: got %v, want %v", tC.tld, tC.endpoint, got, tC.want)
+		}
+	}
+}
+
 func TestResolve(t *testing.T) {
 	addr := newAddr("aaaabbbbccccdddd")
 	addrAlt := newAddr("ddddccccbbbbaaaa")
@@ -0,0 +1,99 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package retrieval
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+// maxCachedChunks is the number of the addresses of the most recently cached
+// forwarded chunks which are remembered to count the requests served from
+// the cache.
+const maxCachedChunks = 10000
+
+// CachePolicy decides which of the chunks retrieved for the requests forwarded
+// by this node are stored in the local store, so that the following requests
+// for them are served by this node and the chunks get closer to the nodes
+// requesting them.
+type CachePolicy struct {
+	// Probability is the probability of a forwarded chunk to be cached. The
+	// forwarded chunks are not cached if it is not set.
+	Probability float64
+	// ProximityCutoff is the minimal proximity of a forwarded chunk to this
+	// node for the chunk to be cached.
+	ProximityCutoff uint8
+}
+
+// cache reports whether the forwarded chunk with the address addr is cached
+// by the node with the base address.
+func (p CachePolicy) cache(base, addr infinity.Address) bool {
+	if p.Probability <= 0 {
+		return false
+	}
+	if infinity.Proximity(base.Bytes(), addr.Bytes()) < p.ProximityCutoff {
+		return false
+	}
+	return p.Probability >= 1 || rand.Float64() < p.Probability
+}
+
+// cachedChunks holds the addresses of the recently cached forwarded chunks,
+// the oldest are forgotten first.
+type cachedChunks struct {
+	addresses map[string]struct{}
+	order     []string
+	next      int
+	mu        sync.Mutex
+}
+
+func newCachedChunks() *cachedChunks {
+	return &cachedChunks{
+		addresses: make(map[string]struct{}),
+	}
+}
+
+func (c *cachedChunks) add(addr infinity.Address) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := addr.ByteString()
+	if _, ok := c.addresses[key]; ok {
+		return
+	}
+	if len(c.order) < maxCachedChunks {
+		c.order = append(c.order, key)
+	} else {
+		delete(c.addresses, c.order[c.next])
+		c.order[c.next] = key
+		c.next = (c.next + 1) % maxCachedChunks
+	}
+	c.addresses[key] = struct{}{}
+}
+
+func (c *cachedChunks) has(addr infinity.Address) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.addresses[addr.ByteString()]
+	return ok
+}
+
+// cacheForwarded stores the chunk retrieved for a forwarded request in the
+// local store if the cache policy allows it.
+func (s *Service) cacheForwarded(ctx context.Context, chunk infinity.Chunk) {
+	if !s.cachePolicy.cache(s.addr, chunk.Address()) {
+		return
+	}
+	if _, err := s.storer.Put(ctx, storage.ModePutRequest, chunk); err != nil {
+		s.logger.Debugf("retrieval: cache forwarded chunk %s: %v", chunk.Address(), err)
+		return
+	}
+	s.cachedChunks.add(chunk.Address())
+	s.metrics.ForwardedCachedCounter.Inc()
+}
@@ -6,6 +6,7 @@ package retrieval
 
 import (
 	"context"
+	"time"
 
 	"github.com/yanhuangpai/voyager/pkg/p2p"
 )
@@ -13,3 +14,29 @@ import (
 func (s *Service) Handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) error {
 	return s.handler(ctx, p, stream)
 }
+
+const (
+	ProtocolName              = protocolName
+	ProtocolVersion           = protocolVersion
+	StreamName                = streamName
+	DeprecatedProtocolVersion = deprecatedProtocolVersion
+)
+
+type SkipList = skipList
+
+var NewSkipList = newSkipList
+
+const (
+	SkipPenaltyMax    = skipPenaltyMax
+	SkipListKeyPrefix = skipListKeyPrefix
+)
+
+func SetTimeNow(f func() time.Time) {
+	timeNow = f
+}
+
+const HedgeMinSamples = hedgeMinSamples
+
+func (s *Service) AddLatency(d time.Duration) {
+	s.latencies.add(d)
+}
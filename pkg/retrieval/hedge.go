@@ -0,0 +1,102 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package retrieval
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/features"
+)
+
+const (
+	// hedgePercentile is the percentile of the recent retrieval latencies
+	// after which the next peer is requested.
+	hedgePercentile = 0.95
+	// hedgeMinSamples is the number of the recent retrievals required to
+	// estimate the latency percentile, the requests are not hedged before.
+	hedgeMinSamples = 20
+	// latencyWindowSize is the number of the recent retrievals the latency
+	// percentile is estimated from.
+	latencyWindowSize = 200
+)
+
+// errDelivered is returned for a chunk delivered by the peer after it was
+// already delivered by another one, so that only the first delivery is
+// accounted.
+var errDelivered = errors.New("chunk already delivered")
+
+// hedging returns true if the next peer is requested for a chunk when the
+// requested peer is slower than the most of the recent retrievals.
+func (s *Service) hedging() bool {
+	return s.features != nil && s.features.Enabled(features.RetrievalHedging)
+}
+
+// hedgeDelay returns the time to wait for the requested peer before the next
+// one is requested and false if there are not enough recent retrievals to
+// estimate it.
+func (s *Service) hedgeDelay() (time.Duration, bool) {
+	d, ok := s.latencies.percentile(hedgePercentile)
+	if !ok || d >= retrieveRetryIntervalDuration {
+		// the slow peer is retried after the retry interval anyway
+		return 0, false
+	}
+	return d, true
+}
+
+// latencyWindow holds the latencies of the recent retrievals from the peers.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func newLatencyWindow(size int) *latencyWindow {
+	return &latencyWindow{
+		samples: make([]time.Duration, 0, size),
+	}
+}
+
+// add records the latency of a retrieval, replacing the oldest one if the
+// window is full.
+func (w *latencyWindow) add(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.samples) < cap(w.samples) {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % len(w.samples)
+}
+
+// percentile returns the p-th percentile, p in the range (0, 1], of the
+// recorded latencies and false if fewer than hedgeMinSamples are recorded.
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mu.Lock()
+	if len(w.samples) < hedgeMinSamples {
+		w.mu.Unlock()
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), w.samples...)
+	w.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	// nearest rank
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i], true
+}
@@ -23,6 +23,17 @@ type metrics struct {
 	RetrieveChunkPOGainCounter prometheus.CounterVec
 	ChunkPrice                 prometheus.Summary
 	TotalErrors                prometheus.Counter
+	PeerPenaltyCounter         prometheus.Counter
+	PeerSkippedCounter         prometheus.Counter
+	RetryCounter               prometheus.Counter
+	RetriesExhaustedCounter    prometheus.Counter
+	AttemptsHistogram          prometheus.Histogram
+	PeerErrors                 *prometheus.CounterVec
+	ForwardedCachedCounter     prometheus.Counter
+	ForwardedCacheHitCounter   prometheus.Counter
+	HedgedRequestCounter       prometheus.Counter
+	HedgedRequestWinsCounter   prometheus.Counter
+	LateDeliveryCounter        prometheus.Counter
 }
 
 func newMetrics() metrics {
@@ -84,6 +95,76 @@ func newMetrics() metrics {
 			Name:      "total_errors",
 			Help:      "Total number of errors while retrieving chunk.",
 		}),
+		PeerPenaltyCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "peer_penalty_count",
+			Help:      "Number of times a peer was added to the skip list after a failed retrieval.",
+		}),
+		PeerSkippedCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "peer_skipped_count",
+			Help:      "Number of times a peer in the skip list was skipped while selecting the closest peer.",
+		}),
+		RetryCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "retry_count",
+			Help:      "Number of requests to the next peer after the previous ones failed or were slow to respond.",
+		}),
+		RetriesExhaustedCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "retries_exhausted_count",
+			Help:      "Number of chunks not retrieved from any of the requested peers.",
+		}),
+		AttemptsHistogram: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "attempts",
+			Help:      "Histogram of the number of peers requested for the retrieved chunks.",
+			Buckets:   []float64{1, 2, 3, 4, 5, 8, 10},
+		}),
+		PeerErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: m.Namespace,
+				Subsystem: subsystem,
+				Name:      "peer_errors",
+				Help:      "Number of the failed retrievals reported by the requested peers, by the error code.",
+			},
+			[]string{"code"},
+		),
+		ForwardedCachedCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "forwarded_cached_count",
+			Help:      "Number of chunks retrieved for the forwarded requests which were cached.",
+		}),
+		ForwardedCacheHitCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "forwarded_cache_hit_count",
+			Help:      "Number of requests served with the chunks cached from the forwarded requests.",
+		}),
+		HedgedRequestCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "hedged_request_count",
+			Help:      "Number of requests to the next peer after the requested one was slower than the latency percentile.",
+		}),
+		HedgedRequestWinsCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "hedged_request_wins_count",
+			Help:      "Number of hedged requests which delivered the chunk first.",
+		}),
+		LateDeliveryCounter: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "late_delivery_count",
+			Help:      "Number of chunks delivered after they were delivered by another peer, which are not paid for.",
+		}),
 	}
 }
 
@@ -23,7 +23,8 @@ var _ = math.Inf
 const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
 type Request struct {
-	Addr []byte `protobuf:"bytes,1,opt,name=Addr,proto3" json:"Addr,omitempty"`
+	Addr []byte   `protobuf:"bytes,1,opt,name=Addr,proto3" json:"Addr,omitempty"`
+	Skip [][]byte `protobuf:"bytes,2,rep,name=Skip,proto3" json:"Skip,omitempty"`
 }
 
 func (m *Request) Reset()         { *m = Request{} }
@@ -66,8 +67,16 @@ func (m *Request) GetAddr() []byte {
 	return nil
 }
 
+func (m *Request) GetSkip() [][]byte {
+	if m != nil {
+		return m.Skip
+	}
+	return nil
+}
+
 type Delivery struct {
-	Data []byte `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
+	Data      []byte `protobuf:"bytes,1,opt,name=Data,proto3" json:"Data,omitempty"`
+	ErrorCode int32  `protobuf:"varint,2,opt,name=ErrorCode,proto3" json:"ErrorCode,omitempty"`
 }
 
 func (m *Delivery) Reset()         { *m = Delivery{} }
@@ -110,6 +119,13 @@ func (m *Delivery) GetData() []byte {
 	return nil
 }
 
+func (m *Delivery) GetErrorCode() int32 {
+	if m != nil {
+		return m.ErrorCode
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Request)(nil), "retieval.Request")
 	proto.RegisterType((*Delivery)(nil), "retieval.Delivery")
@@ -118,16 +134,18 @@ func init() {
 func init() { proto.RegisterFile("retrieval.proto", fileDescriptor_fcade0a564e5dcd4) }
 
 var fileDescriptor_fcade0a564e5dcd4 = []byte{
-	// 134 bytes of a gzipped FileDescriptorProto
+	// 166 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2f, 0x4a, 0x2d, 0x29,
 	0xca, 0x4c, 0x2d, 0x4b, 0xcc, 0xd1, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x28, 0x4a, 0x2d,
-	0x01, 0xf3, 0x95, 0x64, 0xb9, 0xd8, 0x83, 0x52, 0x0b, 0x4b, 0x53, 0x8b, 0x4b, 0x84, 0x84, 0xb8,
-	0x58, 0x1c, 0x53, 0x52, 0x8a, 0x24, 0x18, 0x15, 0x18, 0x35, 0x78, 0x82, 0xc0, 0x6c, 0x25, 0x39,
-	0x2e, 0x0e, 0x97, 0xd4, 0x9c, 0xcc, 0xb2, 0xd4, 0xa2, 0x4a, 0x90, 0xbc, 0x4b, 0x62, 0x49, 0x22,
-	0x4c, 0x1e, 0xc4, 0x76, 0x92, 0x39, 0xf1, 0x48, 0x8e, 0xf1, 0xc2, 0x23, 0x39, 0xc6, 0x07, 0x8f,
-	0xe4, 0x18, 0x27, 0x3c, 0x96, 0x63, 0xb8, 0xf0, 0x58, 0x8e, 0xe1, 0xc6, 0x63, 0x39, 0x86, 0x28,
-	0xa6, 0x82, 0xa4, 0x24, 0x36, 0xb0, 0x6d, 0xc6, 0x80, 0x00, 0x00, 0x00, 0xff, 0xff, 0xe5, 0x88,
-	0xb0, 0x44, 0x80, 0x00, 0x00, 0x00,
+	0x01, 0xf3, 0x95, 0x0c, 0xb9, 0xd8, 0x83, 0x52, 0x0b, 0x4b, 0x53, 0x8b, 0x4b, 0x84, 0x84, 0xb8,
+	0x58, 0x1c, 0x53, 0x52, 0x8a, 0x24, 0x18, 0x15, 0x18, 0x35, 0x78, 0x82, 0xc0, 0x6c, 0x90, 0x58,
+	0x70, 0x76, 0x66, 0x81, 0x04, 0x93, 0x02, 0x33, 0x48, 0x0c, 0xc4, 0x56, 0xb2, 0xe1, 0xe2, 0x70,
+	0x49, 0xcd, 0xc9, 0x2c, 0x4b, 0x2d, 0xaa, 0x04, 0xc9, 0xbb, 0x24, 0x96, 0x24, 0xc2, 0xf4, 0x80,
+	0xd8, 0x42, 0x32, 0x5c, 0x9c, 0xae, 0x45, 0x45, 0xf9, 0x45, 0xce, 0xf9, 0x29, 0xa9, 0x12, 0x4c,
+	0x0a, 0x8c, 0x1a, 0xac, 0x41, 0x08, 0x01, 0x27, 0x99, 0x13, 0x8f, 0xe4, 0x18, 0x2f, 0x3c, 0x92,
+	0x63, 0x7c, 0xf0, 0x48, 0x8e, 0x71, 0xc2, 0x63, 0x39, 0x86, 0x0b, 0x8f, 0xe5, 0x18, 0x6e, 0x3c,
+	0x96, 0x63, 0x88, 0x62, 0x2a, 0x48, 0x4a, 0x62, 0x03, 0xbb, 0xcf, 0x18, 0x30, 0x00, 0x4f, 0xc2,
+	0x61, 0x79, 0xb2, 0x00, 0x00, 0x00,
 }
 
 func (m *Request) Marshal() (dAtA []byte, err error) {
@@ -150,6 +168,15 @@ func (m *Request) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Skip) > 0 {
+		for iNdEx := len(m.Skip) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Skip[iNdEx])
+			copy(dAtA[i:], m.Skip[iNdEx])
+			i = encodeVarintRetrieval(dAtA, i, uint64(len(m.Skip[iNdEx])))
+			i--
+			dAtA[i] = 0x12
+		}
+	}
 	if len(m.Addr) > 0 {
 		i -= len(m.Addr)
 		copy(dAtA[i:], m.Addr)
@@ -180,6 +207,11 @@ func (m *Delivery) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.ErrorCode != 0 {
+		i = encodeVarintRetrieval(dAtA, i, uint64(m.ErrorCode))
+		i--
+		dAtA[i] = 0x10
+	}
 	if len(m.Data) > 0 {
 		i -= len(m.Data)
 		copy(dAtA[i:], m.Data)
@@ -211,6 +243,12 @@ func (m *Request) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovRetrieval(uint64(l))
 	}
+	if len(m.Skip) > 0 {
+		for _, b := range m.Skip {
+			l = len(b)
+			n += 1 + l + sovRetrieval(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -224,6 +262,9 @@ func (m *Delivery) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovRetrieval(uint64(l))
 	}
+	if m.ErrorCode != 0 {
+		n += 1 + sovRetrieval(uint64(m.ErrorCode))
+	}
 	return n
 }
 
@@ -296,6 +337,38 @@ func (m *Request) Unmarshal(dAtA []byte) error {
 				m.Addr = []byte{}
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Skip", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRetrieval
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthRetrieval
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRetrieval
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Skip = append(m.Skip, make([]byte, postIndex-iNdEx))
+			copy(m.Skip[len(m.Skip)-1], dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRetrieval(dAtA[iNdEx:])
@@ -383,6 +456,25 @@ func (m *Delivery) Unmarshal(dAtA []byte) error {
 				m.Data = []byte{}
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ErrorCode", wireType)
+			}
+			m.ErrorCode = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRetrieval
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ErrorCode |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRetrieval(dAtA[iNdEx:])
@@ -12,16 +12,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
 	"github.com/yanhuangpai/voyager/pkg/accounting"
 	"github.com/yanhuangpai/voyager/pkg/cac"
+	"github.com/yanhuangpai/voyager/pkg/features"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/latency"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
 	"github.com/yanhuangpai/voyager/pkg/p2p/protobuf"
+	"github.com/yanhuangpai/voyager/pkg/protoerr"
 	pb "github.com/yanhuangpai/voyager/pkg/retrieval/pb"
 	"github.com/yanhuangpai/voyager/pkg/soc"
 	"github.com/yanhuangpai/voyager/pkg/storage"
@@ -32,10 +37,18 @@ import (
 
 type requestSourceContextKey struct{}
 
+// requestSkipPeersContextKey is the context key of the peers to be skipped
+// which are received with a forwarded request.
+type requestSkipPeersContextKey struct{}
+
 const (
 	protocolName    = "retrieval"
-	protocolVersion = "1.0.0"
+	protocolVersion = "1.1.0"
 	streamName      = "retrieval"
+	// deprecatedProtocolVersion is the version of the peers which do not
+	// know the error codes, the stream is reset instead of sending them
+	// the failed delivery, which they would take for an invalid chunk.
+	deprecatedProtocolVersion = "1.0.0"
 )
 
 var _ Interface = (*Service)(nil)
@@ -55,9 +68,51 @@ type Service struct {
 	pricer        accounting.Pricer
 	metrics       metrics
 	tracer        *tracing.Tracer
+	skipList      *skipList
+	maxAttempts   int
+	retryBackoff  time.Duration
+	latency       latency.Estimator
+	features      features.Checker
+	cachePolicy   CachePolicy
+	cachedChunks  *cachedChunks
+	latencies     *latencyWindow
+}
+
+// Options are the options of the retrieval service.
+type Options struct {
+	// MaxAttempts is the maximal number of peers requested for a chunk
+	// before the retrieval fails, maxPeers if not set.
+	MaxAttempts int
+	// RetryBackoff is the base delay before requesting the next peer after a
+	// failed attempt, which doubles with every failure and is jittered,
+	// retryBackoffBase if not set. A negative value disables the delay.
+	RetryBackoff time.Duration
+	// Latency estimates the round-trip-time to the peers. The peers with
+	// the lower latency are preferred among the peers at the same
+	// proximity to the chunk. The latency is not considered if not set.
+	Latency latency.Estimator
+	// Features enables the experimental retrieval features. None of them
+	// is enabled if not set.
+	Features features.Checker
+	// Cache is the policy of caching the chunks retrieved for the forwarded
+	// requests. They are not cached if not set.
+	Cache CachePolicy
+	// StateStore persists the skip list of the penalized peers across the
+	// restarts of the node. The skip list is kept in memory only if not set.
+	StateStore storage.StateStorer
 }
 
-func New(addr infinity.Address, storer storage.Storer, streamer p2p.Streamer, chunkPeerer topology.EachPeerer, logger logging.Logger, accounting accounting.Interface, pricer accounting.Pricer, tracer *tracing.Tracer) *Service {
+func New(addr infinity.Address, storer storage.Storer, streamer p2p.Streamer, chunkPeerer topology.EachPeerer, logger logging.Logger, accounting accounting.Interface, pricer accounting.Pricer, tracer *tracing.Tracer, o Options) *Service {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = maxPeers
+	}
+	if o.RetryBackoff == 0 {
+		o.RetryBackoff = retryBackoffBase
+	}
+	skipList, err := newSkipList(o.StateStore)
+	if err != nil {
+		logger.Errorf("retrieval: load skip list: %v", err)
+	}
 	return &Service{
 		addr:          addr,
 		streamer:      streamer,
@@ -68,6 +123,14 @@ func New(addr infinity.Address, storer storage.Storer, streamer p2p.Streamer, ch
 		pricer:        pricer,
 		metrics:       newMetrics(),
 		tracer:        tracer,
+		skipList:      skipList,
+		maxAttempts:   o.MaxAttempts,
+		retryBackoff:  o.RetryBackoff,
+		latency:       o.Latency,
+		features:      o.Features,
+		cachePolicy:   o.Cache,
+		cachedChunks:  newCachedChunks(),
+		latencies:     newLatencyWindow(latencyWindowSize),
 	}
 }
 
@@ -84,70 +147,160 @@ func (s *Service) Protocol() p2p.ProtocolSpec {
 	}
 }
 
+// DeprecatedProtocol returns the spec of the previous version of the
+// protocol, which is kept for the peers that do not support the current one.
+func (s *Service) DeprecatedProtocol() p2p.ProtocolSpec {
+	p := s.Protocol()
+	p.Version = deprecatedProtocolVersion
+	p.Deprecated = true
+	return p
+}
+
 const (
 	maxPeers             = 5
 	retrieveChunkTimeout = 10 * time.Second
 
 	retrieveRetryIntervalDuration = 5 * time.Second
+
+	retryBackoffBase = 250 * time.Millisecond
 )
 
 func (s *Service) RetrieveChunk(ctx context.Context, addr infinity.Address) (infinity.Chunk, error) {
 	s.metrics.RequestCounter.Inc()
 
-	v, err, _ := s.singleflight.Do(addr.String(), func() (interface{}, error) {
+	// the forwarded requests are joined only with the requests from the same
+	// peer with the same skipped peers, which they are retrieved with
+	key := addr.String()
+	if src, ok := ctx.Value(requestSourceContextKey{}).(string); ok {
+		key += "/" + src
+	}
+	if skip, ok := ctx.Value(requestSkipPeersContextKey{}).([]infinity.Address); ok {
+		for _, a := range skip {
+			key += "/" + a.String()
+		}
+	}
+
+	v, err, _ := s.singleflight.Do(key, func() (interface{}, error) {
 		span, logger, ctx := s.tracer.StartSpanFromContext(ctx, "retrieve-chunk", s.logger, opentracing.Tag{Key: "address", Value: addr.String()})
 		defer span.Finish()
 
+		// the peers requested in the previous attempts are skipped by the
+		// following ones, as well as the peers the request was forwarded
+		// through
 		sp := newSkipPeers()
+		if skip, ok := ctx.Value(requestSkipPeersContextKey{}).([]infinity.Address); ok {
+			for _, a := range skip {
+				sp.Add(a)
+			}
+		}
 
 		ticker := time.NewTicker(retrieveRetryIntervalDuration)
 		defer ticker.Stop()
 
 		var (
-			peerAttempt  int
-			peersResults int
-			resultC      = make(chan infinity.Chunk, maxPeers)
-			errC         = make(chan error, maxPeers)
+			attempts int
+			failures int
+			noPeers  bool // all peers have been requested
+			resultC  = make(chan infinity.Chunk, s.maxAttempts)
+			errC     = make(chan error, s.maxAttempts)
+			backoffC <-chan time.Time
+			hedgeC   <-chan time.Time
+			// set by the first attempt which receives the chunk, the
+			// deliveries of the other attempts are rejected
+			delivered int32
+			// the attempts still running are canceled once the chunk is
+			// delivered, so that only the first delivery is paid for
+			cancels []context.CancelFunc
 		)
+		defer func() {
+			for _, cancel := range cancels {
+				cancel()
+			}
+		}()
 
-		for {
-			if peerAttempt < maxPeers {
-				peerAttempt++
-
-				s.metrics.PeerRequestCounter.Inc()
+		attempt := func(hedged bool) {
+			attempts++
+			if attempts > 1 {
+				s.metrics.RetryCounter.Inc()
+			}
+			s.metrics.PeerRequestCounter.Inc()
+
+			ctx, cancel := context.WithCancel(ctx)
+			cancels = append(cancels, cancel)
+			go func() {
+				chunk, peer, err := s.retrieveChunk(ctx, addr, sp, &delivered)
+				if err != nil {
+					if !peer.IsZero() && !errors.Is(err, errDelivered) {
+						logger.Debugf("retrieval: failed to get chunk %s from peer %s: %v", addr, peer, err)
+					}
 
-				go func() {
-					chunk, peer, err := s.retrieveChunk(ctx, addr, sp)
-					if err != nil {
-						if !peer.IsZero() {
-							logger.Debugf("retrieval: failed to get chunk %s from peer %s: %v", addr, peer, err)
-						}
+					errC <- err
+					return
+				}
 
-						errC <- err
-						return
-					}
+				if hedged {
+					s.metrics.HedgedRequestWinsCounter.Inc()
+				}
+				resultC <- chunk
+			}()
+		}
+		canRetry := func() bool {
+			return !noPeers && attempts < s.maxAttempts
+		}
 
-					resultC <- chunk
-				}()
-			} else {
-				ticker.Stop()
+		attempt(false)
+		if s.racing() && canRetry() {
+			// request the next peer at once and use the first response
+			attempt(false)
+		} else if s.hedging() {
+			if d, ok := s.hedgeDelay(); ok {
+				hedgeC = time.After(d)
 			}
-
+		}
+		for {
 			select {
 			case <-ticker.C:
-				// break
+				// the peer is slow to respond, request the next one
+				// without waiting for the result
+				if canRetry() {
+					attempt(false)
+				}
+			case <-hedgeC:
+				// the peer is slower than the most of the recent
+				// retrievals, request the next one and use the first
+				// response
+				hedgeC = nil
+				if canRetry() {
+					s.metrics.HedgedRequestCounter.Inc()
+					attempt(true)
+				}
+			case <-backoffC:
+				backoffC = nil
+				if canRetry() {
+					attempt(false)
+				}
 			case chunk := <-resultC:
+				s.metrics.AttemptsHistogram.Observe(float64(attempts))
 				return chunk, nil
-			case <-errC:
-				peersResults++
+			case err := <-errC:
+				failures++
+				// the failed attempt is retried after the backoff
+				hedgeC = nil
+				if errors.Is(err, topology.ErrNotFound) {
+					noPeers = true
+				}
+				if canRetry() && backoffC == nil {
+					backoffC = time.After(s.backoff(failures))
+				}
 			case <-ctx.Done():
 				logger.Tracef("retrieval: failed to get chunk %s: %v", addr, ctx.Err())
 				return nil, fmt.Errorf("retrieval: %w", ctx.Err())
 			}
 
-			// all results received
-			if peersResults >= maxPeers {
-				logger.Tracef("retrieval: failed to get chunk %s", addr)
+			// all attempts failed
+			if failures >= attempts && !canRetry() {
+				s.metrics.RetriesExhaustedCounter.Inc()
+				logger.Tracef("retrieval: failed to get chunk %s after %d attempts", addr, attempts)
 				return nil, storage.ErrNotFound
 			}
 		}
@@ -159,7 +312,32 @@ func (s *Service) RetrieveChunk(ctx context.Context, addr infinity.Address) (inf
 	return v.(infinity.Chunk), nil
 }
 
-func (s *Service) retrieveChunk(ctx context.Context, addr infinity.Address, sp *skipPeers) (chunk infinity.Chunk, peer infinity.Address, err error) {
+// racing returns true if a chunk is requested from two peers at once.
+func (s *Service) racing() bool {
+	return s.features != nil && s.features.Enabled(features.RetrievalRacing)
+}
+
+// backoff returns the jittered delay before the next attempt after the given
+// number of failed attempts, which is between the half and the whole of the
+// exponentially growing delay limited to the retry interval.
+func (s *Service) backoff(failures int) time.Duration {
+	if s.retryBackoff < 0 {
+		return 0
+	}
+	d := s.retryBackoff
+	for i := 1; i < failures && d < retrieveRetryIntervalDuration; i++ {
+		d *= 2
+	}
+	if d > retrieveRetryIntervalDuration {
+		d = retrieveRetryIntervalDuration
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retrieveChunk requests the chunk from the closest peer which is not skipped.
+// The delivery is accounted only if the chunk was not delivered by another
+// attempt yet, which is marked with delivered.
+func (s *Service) retrieveChunk(ctx context.Context, addr infinity.Address, sp *skipPeers, delivered *int32) (chunk infinity.Chunk, peer infinity.Address, err error) {
 	startTimer := time.Now()
 
 	v := ctx.Value(requestSourceContextKey{})
@@ -168,6 +346,7 @@ func (s *Service) retrieveChunk(ctx context.Context, addr infinity.Address, sp *
 	// i.e. the request was not forwarded, to improve retrieval
 	// if this node is the closest to he chunk but still does not contain it
 	allowUpstream := true
+	forwarded := false
 	if src, ok := v.(string); ok {
 		sourcePeerAddr, err = infinity.ParseHexAddress(src)
 		if err == nil {
@@ -176,6 +355,7 @@ func (s *Service) retrieveChunk(ctx context.Context, addr infinity.Address, sp *
 		// do not allow upstream requests if the request was forwarded to this node
 		// to avoid the request loops
 		allowUpstream = false
+		forwarded = true
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, retrieveChunkTimeout)
@@ -201,6 +381,9 @@ func (s *Service) retrieveChunk(ctx context.Context, addr infinity.Address, sp *
 
 	sp.Add(peer)
 
+	span, _, ctx := s.tracer.StartSpanFromContext(ctx, "retrieve-chunk-from-peer", s.logger, opentracing.Tag{Key: "address", Value: addr.String()}, opentracing.Tag{Key: "peer", Value: peer.String()})
+	defer func() { tracing.FinishSpan(span, err) }()
+
 	// compute the price we pay for this chunk and reserve it for the rest of this function
 	chunkPrice := s.pricer.PeerPrice(peer, addr)
 	err = s.accounting.Reserve(ctx, peer, chunkPrice)
@@ -210,9 +393,10 @@ func (s *Service) retrieveChunk(ctx context.Context, addr infinity.Address, sp *
 	defer s.accounting.Release(peer, chunkPrice)
 
 	s.logger.Tracef("retrieval: requesting chunk %s from peer %s", addr, peer)
-	stream, err := s.streamer.NewStream(ctx, peer, nil, protocolName, protocolVersion, streamName)
+	stream, err := s.streamer.NewStream(ctx, peer, protobuf.NewHeaders(ctx, p2p.PriorityHigh), protocolName, protocolVersion, streamName)
 	if err != nil {
 		s.metrics.TotalErrors.Inc()
+		s.penalize(peer, addr, err)
 		return nil, peer, fmt.Errorf("new stream: %w", err)
 	}
 	defer func() {
@@ -223,19 +407,36 @@ func (s *Service) retrieveChunk(ctx context.Context, addr infinity.Address, sp *
 		}
 	}()
 
+	// the peer does not forward the request back to the peers already
+	// requested and to this node, which is not added if it is the
+	// originator of the request, not to reveal it
+	skip := sp.All()
+	if forwarded {
+		skip = append(skip, s.addr)
+	}
+
 	w, r := protobuf.NewWriterAndReader(stream)
 	if err := w.WriteMsgWithContext(ctx, &pb.Request{
 		Addr: addr.Bytes(),
+		Skip: topology.EncodeSkipPeers(skip),
 	}); err != nil {
 		s.metrics.TotalErrors.Inc()
+		s.penalize(peer, addr, err)
 		return nil, peer, fmt.Errorf("write request: %w peer %s", err, peer.String())
 	}
 
 	var d pb.Delivery
 	if err := r.ReadMsgWithContext(ctx, &d); err != nil {
 		s.metrics.TotalErrors.Inc()
+		s.penalize(peer, addr, err)
 		return nil, peer, fmt.Errorf("read delivery: %w peer %s", err, peer.String())
 	}
+	if err := protoerr.FromCode(peer, d.ErrorCode); err != nil {
+		s.metrics.PeerErrors.WithLabelValues(protoerr.Code(d.ErrorCode).String()).Inc()
+		s.metrics.TotalErrors.Inc()
+		s.penalize(peer, addr, err)
+		return nil, peer, err
+	}
 	s.metrics.RetrieveChunkPeerPOTimer.
 		WithLabelValues(strconv.Itoa(int(peerPO))).
 		Observe(time.Since(startTimer).Seconds())
@@ -246,37 +447,80 @@ func (s *Service) retrieveChunk(ctx context.Context, addr infinity.Address, sp *
 		if !soc.Valid(chunk) {
 			s.metrics.InvalidChunkRetrieved.Inc()
 			s.metrics.TotalErrors.Inc()
+			s.penalize(peer, addr, infinity.ErrInvalidChunk)
 			return nil, peer, infinity.ErrInvalidChunk
 		}
 	}
+	s.latencies.add(time.Since(startTimer))
+
+	s.accounting.Requested(peer, len(chunk.Data()))
+
+	if !atomic.CompareAndSwapInt32(delivered, 0, 1) {
+		s.metrics.LateDeliveryCounter.Inc()
+		return nil, peer, errDelivered
+	}
 
 	// credit the peer after successful delivery
 	err = s.accounting.Credit(peer, chunkPrice)
 	if err != nil {
+		// let the other attempts deliver the chunk
+		atomic.StoreInt32(delivered, 0)
 		return nil, peer, err
 	}
+	accounting.AddCost(ctx, chunkPrice)
 	s.metrics.ChunkPrice.Observe(float64(chunkPrice))
 
-	return chunk, peer, err
+	return chunk, peer, nil
+}
+
+// penalize adds the peer that failed to deliver the chunk to the skip list,
+// unless the retrieval has been canceled.
+func (s *Service) penalize(peer, addr infinity.Address, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	penalty, err := s.skipList.Add(peer, addr)
+	if err != nil {
+		s.logger.Debugf("retrieval: persist penalty of peer %s: %v", peer, err)
+	}
+	s.metrics.PeerPenaltyCounter.Inc()
+	s.logger.Tracef("retrieval: skipping peer %s for chunks in the neighborhood of %s for %v", peer, addr, penalty)
 }
 
 // closestPeer returns address of the peer that is closest to the chunk with
 // provided address addr. This function will ignore peers with addresses
 // provided in skipPeers and if allowUpstream is true, peers that are further of
 // the chunk than this node is, could also be returned, allowing the upstream
-// retrieve request.
+// retrieve request. Peers penalized in the skip list are returned only if
+// there are no other peers available.
 func (s *Service) closestPeer(addr infinity.Address, skipPeers []infinity.Address, allowUpstream bool) (infinity.Address, error) {
+	closest, err := s.closestPeerSkipping(addr, skipPeers, allowUpstream, true)
+	if errors.Is(err, topology.ErrNotFound) {
+		return s.closestPeerSkipping(addr, skipPeers, allowUpstream, false)
+	}
+	return closest, err
+}
+
+func (s *Service) closestPeerSkipping(addr infinity.Address, skipPeers []infinity.Address, allowUpstream, skipPenalized bool) (infinity.Address, error) {
 	closest := infinity.Address{}
-	err := s.peerSuggester.EachPeerRev(func(peer infinity.Address, po uint8) (bool, bool, error) {
-		for _, a := range skipPeers {
-			if a.Equal(peer) {
-				return false, false, nil
-			}
+	skipPenalizedPeers := func(peer infinity.Address, _ uint8) bool {
+		if skipPenalized && s.skipList.Skipped(peer, addr) {
+			s.metrics.PeerSkippedCounter.Inc()
+			return false
 		}
+		return true
+	}
+	err := s.peerSuggester.EachPeerWith(func(peer infinity.Address, po uint8) (bool, bool, error) {
 		if closest.IsZero() {
 			closest = peer
 			return false, false, nil
 		}
+		if faster, ok := s.faster(addr, peer, closest, allowUpstream); ok {
+			if faster {
+				closest = peer
+			}
+			return false, false, nil
+		}
 		dcmp, err := infinity.DistanceCmp(addr.Bytes(), closest.Bytes(), peer.Bytes())
 		if err != nil {
 			return false, false, fmt.Errorf("distance compare error. addr %s closest %s peer %s: %w", addr.String(), closest.String(), peer.String(), err)
@@ -292,7 +536,7 @@ func (s *Service) closestPeer(addr infinity.Address, skipPeers []infinity.Addres
 			// do nothing
 		}
 		return false, false, nil
-	})
+	}, topology.WithReverse(), topology.WithSkipPeers(skipPeers...), topology.WithFilter(skipPenalizedPeers))
 	if err != nil {
 		return infinity.Address{}, err
 	}
@@ -316,7 +560,38 @@ func (s *Service) closestPeer(addr infinity.Address, skipPeers []infinity.Addres
 	return closest, nil
 }
 
+// faster reports if the peer has lower latency than the closest peer found so
+// far. The result is valid only if both peers are at the same proximity to the
+// chunk and their latencies are known and different. Unless allowUpstream is true, the
+// proximity must also be higher than the proximity of this node, so that the
+// selected peer is guaranteed to be closer to the chunk than this node.
+func (s *Service) faster(addr, peer, closest infinity.Address, allowUpstream bool) (faster, ok bool) {
+	if s.latency == nil {
+		return false, false
+	}
+	po := infinity.Proximity(addr.Bytes(), peer.Bytes())
+	if po != infinity.Proximity(addr.Bytes(), closest.Bytes()) {
+		return false, false
+	}
+	if !allowUpstream && po <= infinity.Proximity(addr.Bytes(), s.addr.Bytes()) {
+		return false, false
+	}
+	peerRTT, ok := s.latency.RTT(peer)
+	if !ok {
+		return false, false
+	}
+	closestRTT, ok := s.latency.RTT(closest)
+	if !ok {
+		return false, false
+	}
+	if peerRTT == closestRTT {
+		return false, false
+	}
+	return peerRTT < closestRTT, true
+}
+
 func (s *Service) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (err error) {
+	logger := logging.PeerEntry(s.logger, p.Address, logging.NewRequestID())
 	w, r := protobuf.NewWriterAndReader(stream)
 	defer func() {
 		if err != nil {
@@ -329,22 +604,42 @@ func (s *Service) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (e
 	if err := r.ReadMsgWithContext(ctx, &req); err != nil {
 		return fmt.Errorf("read request: %w peer %s", err, p.Address.String())
 	}
-	span, _, ctx := s.tracer.StartSpanFromContext(ctx, "handle-retrieve-chunk", s.logger, opentracing.Tag{Key: "address", Value: infinity.NewAddress(req.Addr).String()})
-	defer span.Finish()
+	span, _, ctx := s.tracer.StartSpanFromContext(ctx, "handle-retrieve-chunk", s.logger, opentracing.Tag{Key: "address", Value: infinity.NewAddress(req.Addr).String()}, opentracing.Tag{Key: "peer", Value: p.Address.String()})
+	defer func() { tracing.FinishSpan(span, err) }()
 
 	ctx = context.WithValue(ctx, requestSourceContextKey{}, p.Address.String())
+	ctx = context.WithValue(ctx, requestSkipPeersContextKey{}, topology.DecodeSkipPeers(req.Skip))
 	addr := infinity.NewAddress(req.Addr)
+	logger.Tracef("retrieval: received request for chunk %s", addr)
 	chunk, err := s.storer.Get(ctx, storage.ModeGetRequest, addr)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			// forward the request
+			logger.Tracef("retrieval: forwarding request for chunk %s", addr)
 			chunk, err = s.RetrieveChunk(ctx, addr)
 			if err != nil {
-				return fmt.Errorf("retrieve chunk: %w", err)
+				err = fmt.Errorf("retrieve chunk: %w", err)
+			} else {
+				s.cacheForwarded(ctx, chunk)
 			}
 		} else {
-			return fmt.Errorf("get from store: %w", err)
+			err = fmt.Errorf("get from store: %w", err)
 		}
+		if err != nil {
+			if p2p.StreamVersion(stream, protocolVersion) == deprecatedProtocolVersion {
+				return err
+			}
+			// let the peer know why the chunk is not delivered
+			logger.Tracef("retrieval: chunk %s: %v", addr, err)
+			if err := w.WriteMsgWithContext(ctx, &pb.Delivery{
+				ErrorCode: int32(protoerr.CodeOf(err)),
+			}); err != nil {
+				return fmt.Errorf("write delivery error: %w peer %s", err, p.Address.String())
+			}
+			return nil
+		}
+	} else if s.cachedChunks.has(addr) {
+		s.metrics.ForwardedCacheHitCounter.Inc()
 	}
 
 	if err := w.WriteMsgWithContext(ctx, &pb.Delivery{
@@ -353,7 +648,9 @@ func (s *Service) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (e
 		return fmt.Errorf("write delivery: %w peer %s", err, p.Address.String())
 	}
 
-	s.logger.Tracef("retrieval protocol debiting peer %s", p.Address.String())
+	s.accounting.Served(p.Address, len(chunk.Data()))
+
+	logger.Tracef("retrieval: delivered chunk %s, debiting peer", addr)
 
 	// compute the price we charge for this chunk and debit it from p's balance
 	chunkPrice := s.pricer.Price(chunk.Address())
@@ -11,15 +11,20 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/yanhuangpai/voyager/pkg/accounting"
 	accountingmock "github.com/yanhuangpai/voyager/pkg/accounting/mock"
+	"github.com/yanhuangpai/voyager/pkg/features"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
 	"github.com/yanhuangpai/voyager/pkg/p2p/protobuf"
 	"github.com/yanhuangpai/voyager/pkg/p2p/streamtest"
+	"github.com/yanhuangpai/voyager/pkg/protoerr"
 	"github.com/yanhuangpai/voyager/pkg/retrieval"
 	pb "github.com/yanhuangpai/voyager/pkg/retrieval/pb"
 	"github.com/yanhuangpai/voyager/pkg/storage"
@@ -51,7 +56,7 @@ func TestDelivery(t *testing.T) {
 	}
 
 	// create the server that will handle the request and will serve the response
-	server := retrieval.New(infinity.MustParseHexAddress("0034"), mockStorer, nil, nil, logger, serverMockAccounting, pricerMock, nil)
+	server := retrieval.New(infinity.MustParseHexAddress("0034"), mockStorer, nil, nil, logger, serverMockAccounting, pricerMock, nil, retrieval.Options{})
 	recorder := streamtest.New(
 		streamtest.WithProtocols(server.Protocol()),
 		streamtest.WithBaseAddr(clientAddr),
@@ -68,7 +73,7 @@ func TestDelivery(t *testing.T) {
 		return nil
 	}}
 
-	client := retrieval.New(clientAddr, clientMockStorer, recorder, ps, logger, clientMockAccounting, pricerMock, nil)
+	client := retrieval.New(clientAddr, clientMockStorer, recorder, ps, logger, clientMockAccounting, pricerMock, nil, retrieval.Options{})
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
 	v, err := client.RetrieveChunk(ctx, chunk.Address())
@@ -78,7 +83,7 @@ func TestDelivery(t *testing.T) {
 	if !bytes.Equal(v.Data(), chunk.Data()) {
 		t.Fatalf("request and response data not equal. got %s want %s", v, chunk.Data())
 	}
-	records, err := recorder.Records(serverAddr, "retrieval", "1.0.0", "retrieval")
+	records, err := recorder.Records(serverAddr, retrieval.ProtocolName, retrieval.ProtocolVersion, retrieval.StreamName)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -131,6 +136,187 @@ func TestDelivery(t *testing.T) {
 	}
 }
 
+// TestDeliveryErrorCode tests that the peer which can not deliver the chunk
+// responds with the code of the error.
+func TestDeliveryErrorCode(t *testing.T) {
+	var (
+		logger     = logging.New(ioutil.Discard, 0)
+		pricer     = accountingmock.NewPricer(1, 1)
+		chunk      = testingc.FixtureChunk("0033")
+		clientAddr = infinity.MustParseHexAddress("9ee7add8")
+		serverAddr = infinity.MustParseHexAddress("9ee7add7")
+	)
+
+	// the server has neither the chunk nor the peers to forward the request to
+	noPeers := mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+		return nil
+	}}
+	server := retrieval.New(serverAddr, storemock.NewStorer(), nil, noPeers, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
+	recorder := streamtest.New(
+		streamtest.WithProtocols(server.Protocol()),
+		streamtest.WithBaseAddr(clientAddr),
+	)
+
+	clientAccounting := accountingmock.NewAccounting()
+	client := retrieval.New(clientAddr, storemock.NewStorer(), recorder, mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+		_, _, _ = f(serverAddr, 0)
+		return nil
+	}}, logger, clientAccounting, pricer, nil, retrieval.Options{MaxAttempts: 1})
+
+	_, err := client.RetrieveChunk(context.Background(), chunk.Address())
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("got error %v, want %v", err, storage.ErrNotFound)
+	}
+
+	records, err := recorder.Records(serverAddr, retrieval.ProtocolName, retrieval.ProtocolVersion, retrieval.StreamName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(records); l != 1 {
+		t.Fatalf("got %v records, want %v", l, 1)
+	}
+	messages, err := protobuf.ReadMessages(
+		bytes.NewReader(records[0].Out()),
+		func() protobuf.Message { return new(pb.Delivery) },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(messages); l != 1 {
+		t.Fatalf("got %v deliveries, want %v", l, 1)
+	}
+	if got, want := protoerr.Code(messages[0].(*pb.Delivery).ErrorCode), protoerr.CodeNotFound; got != want {
+		t.Fatalf("got error code %v, want %v", got, want)
+	}
+
+	// the undelivered chunk is not paid for
+	if balance, _ := clientAccounting.Balance(serverAddr); balance.Sign() != 0 {
+		t.Fatalf("got client balance %v, want 0", balance)
+	}
+}
+
+// TestDeliveryDeprecatedVersion tests that the chunks are retrieved from the
+// peers on the deprecated version of the protocol and that they are not sent
+// the error codes, which they do not know.
+func TestDeliveryDeprecatedVersion(t *testing.T) {
+	var (
+		logger     = logging.New(ioutil.Discard, 0)
+		pricer     = accountingmock.NewPricer(1, 1)
+		chunk      = testingc.FixtureChunk("0033")
+		clientAddr = infinity.MustParseHexAddress("9ee7add8")
+		serverAddr = infinity.MustParseHexAddress("9ee7add7")
+		noPeers    = mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+			return nil
+		}}
+	)
+
+	t.Run("retrieve", func(t *testing.T) {
+		serverStorer := storemock.NewStorer()
+		if _, err := serverStorer.Put(context.Background(), storage.ModePutUpload, chunk); err != nil {
+			t.Fatal(err)
+		}
+		// the server supports only the deprecated version
+		server := retrieval.New(serverAddr, serverStorer, nil, noPeers, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
+		recorder := streamtest.New(
+			streamtest.WithProtocols(server.DeprecatedProtocol()),
+			streamtest.WithBaseAddr(clientAddr),
+		)
+		client := retrieval.New(clientAddr, storemock.NewStorer(), recorder, mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+			_, _, _ = f(serverAddr, 0)
+			return nil
+		}}, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{MaxAttempts: 1})
+
+		got, err := client.RetrieveChunk(context.Background(), chunk.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Data(), chunk.Data()) {
+			t.Fatal("retrieved chunk data differs")
+		}
+	})
+
+	t.Run("no error code", func(t *testing.T) {
+		server := retrieval.New(serverAddr, storemock.NewStorer(), nil, noPeers, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
+		recorder := streamtest.New(
+			streamtest.WithProtocols(server.Protocol(), server.DeprecatedProtocol()),
+			streamtest.WithBaseAddr(clientAddr),
+		)
+
+		stream, err := recorder.NewStream(context.Background(), serverAddr, nil, retrieval.ProtocolName, retrieval.DeprecatedProtocolVersion, retrieval.StreamName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stream.Close()
+
+		w, r := protobuf.NewWriterAndReader(stream)
+		if err := w.WriteMsg(&pb.Request{Addr: chunk.Address().Bytes()}); err != nil {
+			t.Fatal(err)
+		}
+		// the stream is reset without the failed delivery
+		var d pb.Delivery
+		if err := r.ReadMsg(&d); err == nil {
+			t.Fatalf("got delivery with error code %v, want reset stream", protoerr.Code(d.ErrorCode))
+		}
+	})
+}
+
+// TestDeliveryResetDebited tests that the peer which resets the stream after
+// the chunk is delivered is still debited the price of the chunk.
+func TestDeliveryResetDebited(t *testing.T) {
+	var (
+		logger           = logging.New(ioutil.Discard, 0)
+		price            = uint64(10)
+		pricer           = accountingmock.NewPricer(price, price)
+		chunk            = testingc.FixtureChunk("0033")
+		clientAddr       = infinity.MustParseHexAddress("9ee7add8")
+		serverAddr       = infinity.MustParseHexAddress("9ee7add7")
+		serverAccounting = accountingmock.NewAccounting()
+	)
+
+	serverStorer := storemock.NewStorer()
+	if _, err := serverStorer.Put(context.Background(), storage.ModePutUpload, chunk); err != nil {
+		t.Fatal(err)
+	}
+	server := retrieval.New(serverAddr, serverStorer, nil, nil, logger, serverAccounting, pricer, nil, retrieval.Options{})
+	recorder := streamtest.New(
+		streamtest.WithProtocols(server.Protocol()),
+		streamtest.WithBaseAddr(clientAddr),
+	)
+
+	// the client fails to pay for the delivered chunk
+	clientAccounting := accountingmock.NewAccounting(accountingmock.WithCreditFunc(func(infinity.Address, uint64) error {
+		return errors.New("credit error")
+	}))
+	client := retrieval.New(clientAddr, storemock.NewStorer(), recorder, mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+		_, _, _ = f(serverAddr, 0)
+		return nil
+	}}, logger, clientAccounting, pricer, nil, retrieval.Options{MaxAttempts: 1})
+
+	if _, err := client.RetrieveChunk(context.Background(), chunk.Address()); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// the delivered chunk is paid for even if the client resets the stream
+	var debited uint64
+	for deadline := time.Now().Add(testTimeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		reconciliation, err := serverAccounting.Reconciliation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if debited = reconciliation[clientAddr.String()].Debited; debited > 0 {
+			break
+		}
+	}
+	if debited != price {
+		t.Fatalf("got debited %v, want %v", debited, price)
+	}
+	// give the handler the time to observe the reset stream
+	time.Sleep(50 * time.Millisecond)
+	if balance, _ := serverAccounting.Balance(clientAddr); balance.Uint64() != price {
+		t.Fatalf("got server balance %v, want %v", balance, price)
+	}
+}
+
 func TestRetrieveChunk(t *testing.T) {
 	var (
 		logger = logging.New(ioutil.Discard, 0)
@@ -149,14 +335,14 @@ func TestRetrieveChunk(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		server := retrieval.New(serverAddress, serverStorer, nil, nil, logger, accountingmock.NewAccounting(), pricer, nil)
+		server := retrieval.New(serverAddress, serverStorer, nil, nil, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
 		recorder := streamtest.New(streamtest.WithProtocols(server.Protocol()))
 
 		clientSuggester := mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
 			_, _, _ = f(serverAddress, 0)
 			return nil
 		}}
-		client := retrieval.New(clientAddress, nil, recorder, clientSuggester, logger, accountingmock.NewAccounting(), pricer, nil)
+		client := retrieval.New(clientAddress, nil, recorder, clientSuggester, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
 
 		got, err := client.RetrieveChunk(context.Background(), chunk.Address())
 		if err != nil {
@@ -189,6 +375,7 @@ func TestRetrieveChunk(t *testing.T) {
 			accountingmock.NewAccounting(),
 			pricer,
 			nil,
+			retrieval.Options{},
 		)
 
 		forwarder := retrieval.New(
@@ -203,6 +390,7 @@ func TestRetrieveChunk(t *testing.T) {
 			accountingmock.NewAccounting(),
 			pricer,
 			nil,
+			retrieval.Options{},
 		)
 
 		client := retrieval.New(
@@ -217,6 +405,7 @@ func TestRetrieveChunk(t *testing.T) {
 			accountingmock.NewAccounting(),
 			pricer,
 			nil,
+			retrieval.Options{},
 		)
 
 		got, err := client.RetrieveChunk(context.Background(), chunk.Address())
@@ -285,8 +474,8 @@ func TestRetrievePreemptiveRetry(t *testing.T) {
 		return peerSuggester
 	}
 
-	server1 := retrieval.New(serverAddress1, serverStorer1, nil, noPeerSuggester, logger, accountingmock.NewAccounting(), pricerMock, nil)
-	server2 := retrieval.New(serverAddress2, serverStorer2, nil, noPeerSuggester, logger, accountingmock.NewAccounting(), pricerMock, nil)
+	server1 := retrieval.New(serverAddress1, serverStorer1, nil, noPeerSuggester, logger, accountingmock.NewAccounting(), pricerMock, nil, retrieval.Options{})
+	server2 := retrieval.New(serverAddress2, serverStorer2, nil, noPeerSuggester, logger, accountingmock.NewAccounting(), pricerMock, nil, retrieval.Options{})
 
 	t.Run("peer not reachable", func(t *testing.T) {
 		recorder := streamtest.New(
@@ -312,7 +501,7 @@ func TestRetrievePreemptiveRetry(t *testing.T) {
 			),
 		)
 
-		client := retrieval.New(clientAddress, nil, recorder, peerSuggesterFn(peers...), logger, accountingmock.NewAccounting(), pricerMock, nil)
+		client := retrieval.New(clientAddress, nil, recorder, peerSuggesterFn(peers...), logger, accountingmock.NewAccounting(), pricerMock, nil, retrieval.Options{})
 
 		got, err := client.RetrieveChunk(context.Background(), chunk.Address())
 		if err != nil {
@@ -347,7 +536,7 @@ func TestRetrievePreemptiveRetry(t *testing.T) {
 			),
 		)
 
-		client := retrieval.New(clientAddress, nil, recorder, peerSuggesterFn(peers...), logger, accountingmock.NewAccounting(), pricerMock, nil)
+		client := retrieval.New(clientAddress, nil, recorder, peerSuggesterFn(peers...), logger, accountingmock.NewAccounting(), pricerMock, nil, retrieval.Options{})
 
 		got, err := client.RetrieveChunk(context.Background(), chunk.Address())
 		if err != nil {
@@ -376,8 +565,8 @@ func TestRetrievePreemptiveRetry(t *testing.T) {
 		server1MockAccounting := accountingmock.NewAccounting()
 		server2MockAccounting := accountingmock.NewAccounting()
 
-		server1 := retrieval.New(serverAddress1, serverStorer1, nil, noPeerSuggester, logger, server1MockAccounting, pricerMock, nil)
-		server2 := retrieval.New(serverAddress2, serverStorer2, nil, noPeerSuggester, logger, server2MockAccounting, pricerMock, nil)
+		server1 := retrieval.New(serverAddress1, serverStorer1, nil, noPeerSuggester, logger, server1MockAccounting, pricerMock, nil, retrieval.Options{})
+		server2 := retrieval.New(serverAddress2, serverStorer2, nil, noPeerSuggester, logger, server2MockAccounting, pricerMock, nil, retrieval.Options{})
 
 		// NOTE: must be more than retry duration
 		// (here one second more)
@@ -409,7 +598,7 @@ func TestRetrievePreemptiveRetry(t *testing.T) {
 
 		clientMockAccounting := accountingmock.NewAccounting()
 
-		client := retrieval.New(clientAddress, nil, recorder, peerSuggesterFn(peers...), logger, clientMockAccounting, pricerMock, nil)
+		client := retrieval.New(clientAddress, nil, recorder, peerSuggesterFn(peers...), logger, clientMockAccounting, pricerMock, nil, retrieval.Options{})
 
 		got, err := client.RetrieveChunk(context.Background(), chunk.Address())
 		if err != nil {
@@ -447,31 +636,556 @@ func TestRetrievePreemptiveRetry(t *testing.T) {
 
 	t.Run("peer forwards request", func(t *testing.T) {
 		// server 2 has the chunk
-		server2 := retrieval.New(serverAddress2, serverStorer2, nil, noPeerSuggester, logger, accountingmock.NewAccounting(), pricerMock, nil)
+		server2 := retrieval.New(serverAddress2, serverStorer2, nil, noPeerSuggester, logger, accountingmock.NewAccounting(), pricerMock, nil, retrieval.Options{})
 
 		server1Recorder := streamtest.New(
 			streamtest.WithProtocols(server2.Protocol()),
 		)
 
 		// server 1 will forward request to server 2
-		server1 := retrieval.New(serverAddress1, serverStorer1, server1Recorder, peerSuggesterFn(serverAddress2), logger, accountingmock.NewAccounting(), pricerMock, nil)
+		server1 := retrieval.New(serverAddress1, serverStorer1, server1Recorder, peerSuggesterFn(serverAddress2), logger, accountingmock.NewAccounting(), pricerMock, nil, retrieval.Options{})
 
 		clientRecorder := streamtest.New(
 			streamtest.WithProtocols(server1.Protocol()),
 		)
 
 		// client only knows about server 1
-		client := retrieval.New(clientAddress, nil, clientRecorder, peerSuggesterFn(serverAddress1), logger, accountingmock.NewAccounting(), pricerMock, nil)
+		client := retrieval.New(clientAddress, nil, clientRecorder, peerSuggesterFn(serverAddress1), logger, accountingmock.NewAccounting(), pricerMock, nil, retrieval.Options{})
+
+		got, err := client.RetrieveChunk(context.Background(), chunk.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got.Data(), chunk.Data()) {
+			t.Fatalf("got data %x, want %x", got.Data(), chunk.Data())
+		}
+	})
+}
+
+// TestRetrieveChunkSkipPenalizedPeer tests that a peer which failed to deliver
+// a chunk is skipped by subsequent requests for the same neighborhood.
+func TestRetrieveChunkSkipPenalizedPeer(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	pricer := accountingmock.NewPricer(1, 1)
+
+	chunk := testingc.FixtureChunk("02c2")
+	clientAddress := infinity.MustParseHexAddress("ff00000000000000000000000000000000000000000000000000000000000000")
+	// the failing peer is closer to the chunk
+	failingAddress := chunk.Address()
+	serverAddress := infinity.MustParseHexAddress("0f00000000000000000000000000000000000000000000000000000000000000")
+
+	serverStorer := storemock.NewStorer()
+	_, err := serverStorer.Put(context.Background(), storage.ModePutUpload, chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := retrieval.New(serverAddress, serverStorer, nil, nil, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
 
+	streamer := &failingStreamer{
+		Streamer: streamtest.New(streamtest.WithProtocols(server.Protocol())),
+		fail:     failingAddress,
+	}
+
+	clientSuggester := mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+		_, _, _ = f(serverAddress, 0)
+		_, _, _ = f(failingAddress, 0)
+		return nil
+	}}
+	client := retrieval.New(clientAddress, nil, streamer, clientSuggester, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
+
+	for i := 0; i < 2; i++ {
 		got, err := client.RetrieveChunk(context.Background(), chunk.Address())
 		if err != nil {
 			t.Fatal(err)
 		}
+		if !bytes.Equal(got.Data(), chunk.Data()) {
+			t.Fatalf("got data %x, want %x", got.Data(), chunk.Data())
+		}
+	}
+
+	if got := atomic.LoadInt32(&streamer.failed); got != 1 {
+		t.Fatalf("got %v requests to the failing peer, want %v", got, 1)
+	}
+}
+
+// TestRetrieveChunkSkipPeers tests that the forwarded request is not
+// forwarded to the peers already requested by the upstream nodes, and that
+// the originator of the request does not send its own address.
+func TestRetrieveChunkSkipPeers(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	pricer := accountingmock.NewPricer(1, 1)
+
+	chunk := testingc.FixtureChunk("0025")
+	// the failing peer is the closest to the chunk for both the client and
+	// the forwarder
+	failingAddress := infinity.MustParseHexAddress("0000000000000000000000000000000000000000000000000000000000000000")
+	serverAddress := infinity.MustParseHexAddress("0100000000000000000000000000000000000000000000000000000000000000")
+	forwarderAddress := infinity.MustParseHexAddress("0200000000000000000000000000000000000000000000000000000000000000")
+	clientAddress := infinity.MustParseHexAddress("0300000000000000000000000000000000000000000000000000000000000000")
+
+	serverStorer := storemock.NewStorer()
+	_, err := serverStorer.Put(context.Background(), storage.ModePutUpload, chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := retrieval.New(serverAddress, serverStorer, nil, nil, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
+
+	forwarderRecorder := streamtest.New(streamtest.WithProtocols(server.Protocol()), streamtest.WithBaseAddr(forwarderAddress))
+	forwarderStreamer := &failingStreamer{
+		Streamer: forwarderRecorder,
+		fail:     failingAddress,
+	}
+	forwarder := retrieval.New(forwarderAddress, storemock.NewStorer(), forwarderStreamer, mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+		_, _, _ = f(serverAddress, 0)
+		_, _, _ = f(failingAddress, 0)
+		return nil
+	}}, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
+
+	clientRecorder := streamtest.New(streamtest.WithProtocols(forwarder.Protocol()), streamtest.WithBaseAddr(clientAddress))
+	clientStreamer := &failingStreamer{
+		Streamer: clientRecorder,
+		fail:     failingAddress,
+	}
+	client := retrieval.New(clientAddress, nil, clientStreamer, mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+		_, _, _ = f(forwarderAddress, 0)
+		_, _, _ = f(failingAddress, 0)
+		return nil
+	}}, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{RetryBackoff: -1})
+
+	got, err := client.RetrieveChunk(context.Background(), chunk.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data(), chunk.Data()) {
+		t.Fatalf("got data %x, want %x", got.Data(), chunk.Data())
+	}
+
+	if got := atomic.LoadInt32(&clientStreamer.failed); got != 1 {
+		t.Fatalf("got %v requests to the failing peer from the client, want %v", got, 1)
+	}
+	if got := atomic.LoadInt32(&forwarderStreamer.failed); got != 0 {
+		t.Fatalf("got %v requests to the failing peer from the forwarder, want %v", got, 0)
+	}
+
+	for _, tc := range []struct {
+		recorder *streamtest.Recorder
+		peer     infinity.Address
+		want     []infinity.Address
+	}{
+		{recorder: clientRecorder, peer: forwarderAddress, want: []infinity.Address{failingAddress, forwarderAddress}},
+		{recorder: forwarderRecorder, peer: serverAddress, want: []infinity.Address{failingAddress, forwarderAddress, clientAddress, serverAddress}},
+	} {
+		records, err := tc.recorder.Records(tc.peer, retrieval.ProtocolName, retrieval.ProtocolVersion, retrieval.StreamName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages, err := protobuf.ReadMessages(
+			bytes.NewReader(records[0].In()),
+			func() protobuf.Message { return new(pb.Request) },
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		skip := topology.DecodeSkipPeers(messages[0].(*pb.Request).Skip)
+		if fmt.Sprint(skip) != fmt.Sprint(tc.want) {
+			t.Fatalf("peer %s: got skip peers %v, want %v", tc.peer, skip, tc.want)
+		}
+	}
+}
+
+// TestRetrieveChunkCacheForwarded tests that the chunks retrieved for the
+// forwarded requests are cached according to the cache policy and that the
+// following requests are served from the cache.
+func TestRetrieveChunkCacheForwarded(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	pricer := accountingmock.NewPricer(1, 1)
+
+	chunk := testingc.FixtureChunk("0025")
+	serverAddress := infinity.MustParseHexAddress("0100000000000000000000000000000000000000000000000000000000000000")
+	forwarderAddress := infinity.MustParseHexAddress("0200000000000000000000000000000000000000000000000000000000000000")
+	clientAddress := infinity.MustParseHexAddress("0300000000000000000000000000000000000000000000000000000000000000")
+	po := infinity.Proximity(forwarderAddress.Bytes(), chunk.Address().Bytes())
+
+	for _, tc := range []struct {
+		name   string
+		policy retrieval.CachePolicy
+		cached bool
+	}{
+		{
+			name:   "disabled",
+			policy: retrieval.CachePolicy{},
+		},
+		{
+			name:   "cached",
+			policy: retrieval.CachePolicy{Probability: 1, ProximityCutoff: po},
+			cached: true,
+		},
+		{
+			name:   "below proximity cutoff",
+			policy: retrieval.CachePolicy{Probability: 1, ProximityCutoff: po + 1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			serverStorer := storemock.NewStorer()
+			_, err := serverStorer.Put(context.Background(), storage.ModePutUpload, chunk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := retrieval.New(serverAddress, serverStorer, nil, nil, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
+
+			forwarderStorer := storemock.NewStorer()
+			forwarderRecorder := streamtest.New(streamtest.WithProtocols(server.Protocol()), streamtest.WithBaseAddr(forwarderAddress))
+			forwarder := retrieval.New(forwarderAddress, forwarderStorer, forwarderRecorder, mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+				_, _, _ = f(serverAddress, 0)
+				return nil
+			}}, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{Cache: tc.policy})
+
+			// a new client for every request, so that the chunk is not
+			// deduplicated by the client
+			for i := 0; i < 2; i++ {
+				client := retrieval.New(clientAddress, nil, streamtest.New(streamtest.WithProtocols(forwarder.Protocol()), streamtest.WithBaseAddr(clientAddress)), mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+					_, _, _ = f(forwarderAddress, 0)
+					return nil
+				}}, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
+
+				got, err := client.RetrieveChunk(context.Background(), chunk.Address())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got.Data(), chunk.Data()) {
+					t.Fatalf("got data %x, want %x", got.Data(), chunk.Data())
+				}
+			}
+
+			has, err := forwarderStorer.Has(context.Background(), chunk.Address())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if has != tc.cached {
+				t.Fatalf("got cached %v, want %v", has, tc.cached)
+			}
+
+			records, err := forwarderRecorder.Records(serverAddress, retrieval.ProtocolName, retrieval.ProtocolVersion, retrieval.StreamName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := 2
+			if tc.cached {
+				want = 1
+			}
+			if len(records) != want {
+				t.Fatalf("got %v requests to the server, want %v", len(records), want)
+			}
+		})
+	}
+}
+
+// TestRetrieveChunkRetry tests that the failed attempts are retried with the
+// next closest peers, which are not requested again in the following
+// attempts, up to the maximal number of attempts.
+func TestRetrieveChunkRetry(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	pricer := accountingmock.NewPricer(1, 1)
+
+	chunk := testingc.FixtureChunk("02c2")
+	clientAddress := infinity.MustParseHexAddress("ff00000000000000000000000000000000000000000000000000000000000000")
+	// the failing peers are closer to the chunk
+	failingAddress1 := chunk.Address()
+	failingAddress2 := infinity.MustParseHexAddress("02c3000000000000000000000000000000000000000000000000000000000000")
+	serverAddress := infinity.MustParseHexAddress("0f00000000000000000000000000000000000000000000000000000000000000")
+
+	serverStorer := storemock.NewStorer()
+	_, err := serverStorer.Put(context.Background(), storage.ModePutUpload, chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := retrieval.New(serverAddress, serverStorer, nil, nil, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
+
+	newClient := func(o retrieval.Options) (*retrieval.Service, *failingStreamer, *failingStreamer) {
+		streamer2 := &failingStreamer{
+			Streamer: streamtest.New(streamtest.WithProtocols(server.Protocol())),
+			fail:     failingAddress2,
+		}
+		streamer1 := &failingStreamer{
+			Streamer: streamer2,
+			fail:     failingAddress1,
+		}
+		suggester := mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+			_, _, _ = f(serverAddress, 0)
+			_, _, _ = f(failingAddress2, 0)
+			_, _, _ = f(failingAddress1, 0)
+			return nil
+		}}
+		client := retrieval.New(clientAddress, nil, streamer1, suggester, logger, accountingmock.NewAccounting(), pricer, nil, o)
+		return client, streamer1, streamer2
+	}
 
+	t.Run("retrieved", func(t *testing.T) {
+		client, streamer1, streamer2 := newClient(retrieval.Options{RetryBackoff: time.Millisecond})
+
+		got, err := client.RetrieveChunk(context.Background(), chunk.Address())
+		if err != nil {
+			t.Fatal(err)
+		}
 		if !bytes.Equal(got.Data(), chunk.Data()) {
 			t.Fatalf("got data %x, want %x", got.Data(), chunk.Data())
 		}
+		if got := atomic.LoadInt32(&streamer1.failed); got != 1 {
+			t.Errorf("got %v requests to the first failing peer, want %v", got, 1)
+		}
+		if got := atomic.LoadInt32(&streamer2.failed); got != 1 {
+			t.Errorf("got %v requests to the second failing peer, want %v", got, 1)
+		}
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		client, streamer1, streamer2 := newClient(retrieval.Options{MaxAttempts: 2, RetryBackoff: -1})
+
+		_, err := client.RetrieveChunk(context.Background(), chunk.Address())
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("got error %v, want %v", err, storage.ErrNotFound)
+		}
+		if got := atomic.LoadInt32(&streamer1.failed); got != 1 {
+			t.Errorf("got %v requests to the first failing peer, want %v", got, 1)
+		}
+		if got := atomic.LoadInt32(&streamer2.failed); got != 1 {
+			t.Errorf("got %v requests to the second failing peer, want %v", got, 1)
+		}
+	})
+
+	t.Run("racing", func(t *testing.T) {
+		// the second peer is requested at once, without waiting for the
+		// backoff after the failure of the first one
+		client, streamer1, streamer2 := newClient(retrieval.Options{
+			MaxAttempts:  2,
+			RetryBackoff: time.Hour,
+			Features:     enabledFeatures{features.RetrievalRacing: true},
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_, err := client.RetrieveChunk(ctx, chunk.Address())
+		if !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("got error %v, want %v", err, storage.ErrNotFound)
+		}
+		if got := atomic.LoadInt32(&streamer1.failed); got != 1 {
+			t.Errorf("got %v requests to the first failing peer, want %v", got, 1)
+		}
+		if got := atomic.LoadInt32(&streamer2.failed); got != 1 {
+			t.Errorf("got %v requests to the second failing peer, want %v", got, 1)
+		}
+	})
+}
+
+// TestRetrieveChunkHedging tests that the next peer is requested when the
+// requested one is slower than the recent retrievals, or at once with the
+// racing, and that only the first delivery is paid for, while the request to
+// the slower peer is canceled.
+func TestRetrieveChunkHedging(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	price := uint64(10)
+	pricer := accountingmock.NewPricer(price, price)
+
+	chunk := testingc.FixtureChunk("02c2")
+	clientAddress := flipBits(chunk.Address(), 0)
+	// the slow peer is closer to the chunk and requested first
+	slowAddress := flipBits(chunk.Address(), 250)
+	fastAddress := flipBits(chunk.Address(), 200)
+	slowDelay := 500 * time.Millisecond
+
+	newServer := func(addr infinity.Address) (*retrieval.Service, accounting.Interface) {
+		storer := storemock.NewStorer()
+		if _, err := storer.Put(context.Background(), storage.ModePutUpload, chunk); err != nil {
+			t.Fatal(err)
+		}
+		serverAccounting := accountingmock.NewAccounting()
+		return retrieval.New(addr, storer, nil, nil, logger, serverAccounting, pricer, nil, retrieval.Options{}), serverAccounting
+	}
+
+	newClient := func(t *testing.T, f enabledFeatures) (*retrieval.Service, accounting.Interface, func() []infinity.Address) {
+		t.Helper()
+
+		slowServer, slowAccounting := newServer(slowAddress)
+		fastServer, _ := newServer(fastAddress)
+		streamer := peerStreamer{
+			slowAddress.String(): streamtest.New(
+				streamtest.WithProtocols(slowServer.Protocol()),
+				streamtest.WithBaseAddr(clientAddress),
+				streamtest.WithMiddlewares(func(h p2p.HandlerFunc) p2p.HandlerFunc {
+					return func(ctx context.Context, peer p2p.Peer, stream p2p.Stream) error {
+						time.Sleep(slowDelay)
+						return h(ctx, peer, stream)
+					}
+				}),
+			),
+			fastAddress.String(): streamtest.New(
+				streamtest.WithProtocols(fastServer.Protocol()),
+				streamtest.WithBaseAddr(clientAddress),
+			),
+		}
+		suggester := mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+			_, _, _ = f(fastAddress, 0)
+			_, _, _ = f(slowAddress, 0)
+			return nil
+		}}
+
+		var (
+			mu       sync.Mutex
+			credited []infinity.Address
+		)
+		clientAccounting := accountingmock.NewAccounting(accountingmock.WithCreditFunc(func(peer infinity.Address, _ uint64) error {
+			mu.Lock()
+			defer mu.Unlock()
+			credited = append(credited, peer)
+			return nil
+		}))
+		client := retrieval.New(clientAddress, nil, streamer, suggester, logger, clientAccounting, pricer, nil, retrieval.Options{
+			Features: f,
+		})
+		return client, slowAccounting, func() []infinity.Address {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]infinity.Address(nil), credited...)
+		}
+	}
+
+	t.Run("hedged", func(t *testing.T) {
+		client, slowAccounting, credited := newClient(t, enabledFeatures{features.RetrievalHedging: true})
+		for i := 0; i < retrieval.HedgeMinSamples; i++ {
+			client.AddLatency(10 * time.Millisecond)
+		}
+
+		start := time.Now()
+		if _, err := client.RetrieveChunk(context.Background(), chunk.Address()); err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(start); d >= slowDelay {
+			t.Fatalf("retrieval took %v, the slow peer responds after %v", d, slowDelay)
+		}
+
+		// the request to the slow peer is canceled and only the winner
+		// is paid for
+		time.Sleep(2 * slowDelay)
+		if got := credited(); len(got) != 1 || !got[0].Equal(fastAddress) {
+			t.Fatalf("got credited peers %v, want %v", got, []infinity.Address{fastAddress})
+		}
+		if balance, _ := slowAccounting.Balance(clientAddress); balance.Sign() != 0 {
+			t.Fatalf("got slow peer balance %v, want 0", balance)
+		}
+	})
+
+	t.Run("racing", func(t *testing.T) {
+		client, slowAccounting, credited := newClient(t, enabledFeatures{features.RetrievalRacing: true})
+
+		start := time.Now()
+		if _, err := client.RetrieveChunk(context.Background(), chunk.Address()); err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(start); d >= slowDelay {
+			t.Fatalf("retrieval took %v, the slow peer responds after %v", d, slowDelay)
+		}
+
+		time.Sleep(2 * slowDelay)
+		if got := credited(); len(got) != 1 || !got[0].Equal(fastAddress) {
+			t.Fatalf("got credited peers %v, want %v", got, []infinity.Address{fastAddress})
+		}
+		if balance, _ := slowAccounting.Balance(clientAddress); balance.Sign() != 0 {
+			t.Fatalf("got slow peer balance %v, want 0", balance)
+		}
+	})
+
+	t.Run("not enough samples", func(t *testing.T) {
+		client, _, credited := newClient(t, enabledFeatures{features.RetrievalHedging: true})
+		for i := 0; i < retrieval.HedgeMinSamples-1; i++ {
+			client.AddLatency(10 * time.Millisecond)
+		}
+
+		if _, err := client.RetrieveChunk(context.Background(), chunk.Address()); err != nil {
+			t.Fatal(err)
+		}
+		if got := credited(); len(got) != 1 || !got[0].Equal(slowAddress) {
+			t.Fatalf("got credited peers %v, want %v", got, []infinity.Address{slowAddress})
+		}
+	})
+}
+
+type enabledFeatures map[features.Flag]bool
+
+func (f enabledFeatures) Enabled(flag features.Flag) bool {
+	return f[flag]
+}
+
+// TestRetrieveChunkPreferLowLatency tests that the peer with the lower latency
+// is requested among the peers at the same proximity to the chunk, even if it
+// is further from the chunk.
+func TestRetrieveChunkPreferLowLatency(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	pricer := accountingmock.NewPricer(1, 1)
+
+	chunk := testingc.FixtureChunk("02c2")
+	clientAddress := flipBits(chunk.Address(), 0)
+	slowAddress := flipBits(chunk.Address(), 200)
+	fastAddress := flipBits(chunk.Address(), 200, 250)
+
+	serverStorer := storemock.NewStorer()
+	_, err := serverStorer.Put(context.Background(), storage.ModePutUpload, chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := retrieval.New(slowAddress, serverStorer, nil, nil, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{})
+	recorder := streamtest.New(streamtest.WithProtocols(server.Protocol()))
+
+	clientSuggester := mockPeerSuggester{eachPeerRevFunc: func(f topology.EachPeerFunc) error {
+		_, _, _ = f(slowAddress, 0)
+		_, _, _ = f(fastAddress, 0)
+		return nil
+	}}
+	client := retrieval.New(clientAddress, nil, recorder, clientSuggester, logger, accountingmock.NewAccounting(), pricer, nil, retrieval.Options{
+		Latency: latencyEstimator{
+			slowAddress.String(): 100 * time.Millisecond,
+			fastAddress.String(): 10 * time.Millisecond,
+		},
 	})
+
+	if _, err := client.RetrieveChunk(context.Background(), chunk.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := recorder.Records(fastAddress, retrieval.ProtocolName, retrieval.ProtocolVersion, retrieval.StreamName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(records); l != 1 {
+		t.Fatalf("got %v records to the fast peer, want %v", l, 1)
+	}
+}
+
+// failingStreamer fails to create streams to the peer with the address fail.
+type failingStreamer struct {
+	p2p.Streamer
+	fail   infinity.Address
+	failed int32
+}
+
+func (s *failingStreamer) NewStream(ctx context.Context, addr infinity.Address, h p2p.Headers, protocolName, protocolVersion, streamName string) (p2p.Stream, error) {
+	if addr.Equal(s.fail) {
+		atomic.AddInt32(&s.failed, 1)
+		return nil, errors.New("peer not reachable")
+	}
+	return s.Streamer.NewStream(ctx, addr, h, protocolName, protocolVersion, streamName)
+}
+
+// peerStreamer creates the streams to the peers with their own streamers.
+type peerStreamer map[string]p2p.Streamer
+
+func (s peerStreamer) NewStream(ctx context.Context, addr infinity.Address, h p2p.Headers, protocolName, protocolVersion, streamName string) (p2p.Stream, error) {
+	streamer, ok := s[addr.String()]
+	if !ok {
+		return nil, fmt.Errorf("unknown peer %s", addr)
+	}
+	return streamer.NewStream(ctx, addr, h, protocolName, protocolVersion, streamName)
 }
 
 type mockPeerSuggester struct {
@@ -484,3 +1198,27 @@ func (s mockPeerSuggester) EachPeer(topology.EachPeerFunc) error {
 func (s mockPeerSuggester) EachPeerRev(f topology.EachPeerFunc) error {
 	return s.eachPeerRevFunc(f)
 }
+func (s mockPeerSuggester) EachPeerWith(f topology.EachPeerFunc, opts ...topology.EachPeerOption) error {
+	o := topology.NewEachPeerOptions(opts...)
+	if !o.Reverse {
+		return errors.New("not implemented")
+	}
+	return s.eachPeerRevFunc(o.Select(f, nil))
+}
+
+type latencyEstimator map[string]time.Duration
+
+func (e latencyEstimator) RTT(peer infinity.Address) (time.Duration, bool) {
+	rtt, ok := e[peer.String()]
+	return rtt, ok
+}
+
+// flipBits returns a copy of the address with the bits at the positions
+// flipped.
+func flipBits(addr infinity.Address, positions ...int) infinity.Address {
+	b := append([]byte(nil), addr.Bytes()...)
+	for _, p := range positions {
+		b[p/8] ^= 1 << (7 - uint(p%8))
+	}
+	return infinity.NewAddress(b)
+}
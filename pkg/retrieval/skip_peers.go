@@ -5,9 +5,14 @@
 package retrieval
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/storage"
 )
 
 type skipPeers struct {
@@ -38,3 +43,133 @@ func (s *skipPeers) Add(address infinity.Address) {
 
 	s.addresses = append(s.addresses, address)
 }
+
+// timeNow is used to deterministically mock time.Now() in tests.
+var timeNow = time.Now
+
+const (
+	// skipPenaltyBase is the duration for which a peer is skipped after its
+	// first failure to deliver a chunk from a neighborhood.
+	skipPenaltyBase = 30 * time.Second
+	// skipPenaltyMax is the maximal duration for which a peer is skipped.
+	skipPenaltyMax = 30 * time.Minute
+	// skipPenaltyMaxShift bounds the number of times the base penalty is
+	// doubled, so that the doubled penalty does not overflow the duration.
+	skipPenaltyMaxShift = 10
+	// skipListKeyPrefix is the prefix of the state store keys of the skip
+	// list entries.
+	skipListKeyPrefix = "retrieval_skip_"
+)
+
+// skipList holds peers that failed to deliver chunks and are skipped for the
+// neighborhood of the chunk for all requests until the penalty expires. The
+// penalty doubles with every failure of the peer in the same neighborhood
+// until it has been forgiven, which is after the maximal penalty duration
+// elapses since the last failure. The entries are persisted in the state
+// store, if it is set, so that the penalties outlive the restart of the node.
+type skipList struct {
+	entries map[string]*skipEntry // key is the peer and the neighborhood
+	store   storage.StateStorer
+	mu      sync.Mutex
+}
+
+type skipEntry struct {
+	Until    time.Time `json:"until"`
+	Last     time.Time `json:"last"`
+	Failures uint      `json:"failures"`
+}
+
+// newSkipList returns the skip list with the entries loaded from the state
+// store which are not forgiven yet. The returned skip list is usable even if
+// the error of loading the entries is returned.
+func newSkipList(store storage.StateStorer) (*skipList, error) {
+	l := &skipList{
+		entries: make(map[string]*skipEntry),
+		store:   store,
+	}
+	if store == nil {
+		return l, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := store.Iterate(skipListKeyPrefix, func(key, value []byte) (bool, error) {
+		e := new(skipEntry)
+		if err := json.Unmarshal(value, e); err != nil {
+			return true, fmt.Errorf("skip list entry: %w", err)
+		}
+		l.entries[strings.TrimPrefix(string(key), skipListKeyPrefix)] = e
+		return false, nil
+	}); err != nil {
+		return l, err
+	}
+	return l, l.prune(timeNow())
+}
+
+// Add penalizes the peer for the failure to deliver the chunk with the
+// address addr and returns the duration of the penalty. The peer is
+// penalized even if the error of persisting the penalty is returned.
+func (l *skipList) Add(peer, addr infinity.Address) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := timeNow()
+	pruneErr := l.prune(now)
+
+	key := skipKey(peer, addr)
+	e, ok := l.entries[key]
+	if !ok {
+		e = new(skipEntry)
+		l.entries[key] = e
+	}
+	e.Failures++
+
+	penalty := skipPenaltyMax
+	if shift := e.Failures - 1; shift < skipPenaltyMaxShift && skipPenaltyBase<<shift < skipPenaltyMax {
+		penalty = skipPenaltyBase << shift
+	}
+	e.Until = now.Add(penalty)
+	e.Last = now
+
+	if l.store != nil {
+		if err := l.store.Put(skipListKeyPrefix+key, e); err != nil {
+			return penalty, err
+		}
+	}
+	return penalty, pruneErr
+}
+
+// Skipped returns true if the peer is penalized for the neighborhood of the
+// chunk with the address addr.
+func (l *skipList) Skipped(peer, addr infinity.Address) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[skipKey(peer, addr)]
+	return ok && timeNow().Before(e.Until)
+}
+
+// prune removes entries of peers that are forgiven, also from the state
+// store. It must be called with the lock held.
+func (l *skipList) prune(now time.Time) (err error) {
+	for k, e := range l.entries {
+		if now.Sub(e.Last) <= skipPenaltyMax {
+			continue
+		}
+		delete(l.entries, k)
+		if l.store == nil {
+			continue
+		}
+		if deleteErr := l.store.Delete(skipListKeyPrefix + k); deleteErr != nil && err == nil {
+			err = deleteErr
+		}
+	}
+	return err
+}
+
+// skipKey returns the key of the peer for the neighborhood of the chunk which
+// is defined by the first byte of the chunk address.
+func skipKey(peer, addr infinity.Address) string {
+	return peer.ByteString() + string(addr.Bytes()[:1])
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package retrieval_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+	"github.com/yanhuangpai/voyager/pkg/retrieval"
+	"github.com/yanhuangpai/voyager/pkg/statestore/mock"
+)
+
+func TestSkipList(t *testing.T) {
+	peer := infinity.MustParseHexAddress("0100000000000000000000000000000000000000000000000000000000000000")
+	chunk := infinity.MustParseHexAddress("aa00000000000000000000000000000000000000000000000000000000000000")
+	sameNeighborhood := infinity.MustParseHexAddress("aaff000000000000000000000000000000000000000000000000000000000000")
+	otherNeighborhood := infinity.MustParseHexAddress("bb00000000000000000000000000000000000000000000000000000000000000")
+
+	now := time.Now()
+	retrieval.SetTimeNow(func() time.Time { return now })
+	defer retrieval.SetTimeNow(time.Now)
+
+	l, err := retrieval.NewSkipList(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l.Skipped(peer, chunk) {
+		t.Fatal("peer skipped before failure")
+	}
+
+	first := add(t, l, peer, chunk)
+	if !l.Skipped(peer, sameNeighborhood) {
+		t.Fatal("peer not skipped in the same neighborhood")
+	}
+	if l.Skipped(peer, otherNeighborhood) {
+		t.Fatal("peer skipped in other neighborhood")
+	}
+
+	// the penalty grows for repeated failures
+	second := add(t, l, peer, chunk)
+	if second != 2*first {
+		t.Fatalf("got penalty %v, want %v", second, 2*first)
+	}
+
+	now = now.Add(second)
+	if l.Skipped(peer, chunk) {
+		t.Fatal("peer skipped after penalty expired")
+	}
+
+	// the penalty is capped and does not overflow for many failures
+	var max time.Duration
+	for i := 0; i < 100; i++ {
+		penalty := add(t, l, peer, chunk)
+		if penalty < max {
+			t.Fatalf("got penalty %v after %v failures, want at least %v", penalty, i+3, max)
+		}
+		max = penalty
+	}
+	if got := add(t, l, peer, chunk); got != max {
+		t.Fatalf("got penalty %v, want capped %v", got, max)
+	}
+
+	// the peer is forgiven after the maximal penalty elapses
+	now = now.Add(2 * max)
+	if got := add(t, l, peer, chunk); got != first {
+		t.Fatalf("got penalty %v, want %v", got, first)
+	}
+}
+
+// TestSkipListPersistence tests that the penalties are restored from the
+// state store and that the forgiven entries are removed from it.
+func TestSkipListPersistence(t *testing.T) {
+	peer := infinity.MustParseHexAddress("0100000000000000000000000000000000000000000000000000000000000000")
+	chunk := infinity.MustParseHexAddress("aa00000000000000000000000000000000000000000000000000000000000000")
+
+	now := time.Now()
+	retrieval.SetTimeNow(func() time.Time { return now })
+	defer retrieval.SetTimeNow(time.Now)
+
+	store := mock.NewStateStore()
+	defer store.Close()
+
+	l, err := retrieval.NewSkipList(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := add(t, l, peer, chunk)
+
+	l, err = retrieval.NewSkipList(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Skipped(peer, chunk) {
+		t.Fatal("peer not skipped after the skip list is restored")
+	}
+	if got := add(t, l, peer, chunk); got != 2*first {
+		t.Fatalf("got penalty %v, want %v", got, 2*first)
+	}
+
+	// the peer is forgiven after the maximal penalty elapses
+	now = now.Add(retrieval.SkipPenaltyMax + time.Second)
+	l, err = retrieval.NewSkipList(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Skipped(peer, chunk) {
+		t.Fatal("peer skipped after it is forgiven")
+	}
+	var entries int
+	if err := store.Iterate(retrieval.SkipListKeyPrefix, func(_, _ []byte) (bool, error) {
+		entries++
+		return false, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if entries != 0 {
+		t.Fatalf("got %v persisted entries, want none", entries)
+	}
+}
+
+func add(t *testing.T, l *retrieval.SkipList, peer, addr infinity.Address) time.Duration {
+	t.Helper()
+
+	penalty, err := l.Add(peer, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return penalty
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package swap
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+// timeNow is used to deterministically mock time.Now() in tests.
+var timeNow = time.Now
+
+// settlementAlert records the recent settlements with the peers to detect the
+// peers requesting them at an anomalous rate.
+type settlementAlert struct {
+	mu          sync.Mutex
+	limit       int
+	window      time.Duration
+	settlements map[string][]time.Time
+}
+
+// SetSettlementAlert makes the service alert when more than limit settlements
+// with a peer are initiated within the window, which is a sign of an
+// accounting error or of a misbehaving peer. The alert is logged and counted
+// in the metrics. A zero limit disables the alerts.
+func (s *Service) SetSettlementAlert(limit int, window time.Duration) {
+	if limit <= 0 || window <= 0 {
+		s.alert = nil
+		return
+	}
+	s.alert = &settlementAlert{
+		limit:       limit,
+		window:      window,
+		settlements: make(map[string][]time.Time),
+	}
+}
+
+// recordSettlement records a settlement with the peer and alerts if the peer
+// requests the settlements at an anomalous rate.
+func (s *Service) recordSettlement(peer infinity.Address) {
+	if s.alert == nil {
+		return
+	}
+	if n, ok := s.alert.record(peer); !ok {
+		s.metrics.SettlementAlerts.Inc()
+		s.logger.Warningf("swap: peer %s requested %d settlements within %s", peer, n, s.alert.window)
+	}
+}
+
+// record records a settlement with the peer and returns the number of the
+// settlements within the window and false if it is over the limit. The
+// settlements are forgotten after the alert, so that the peer is alerted on
+// again only after another limit of them.
+func (a *settlementAlert) record(peer infinity.Address) (int, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := timeNow()
+	key := peer.ByteString()
+	recent := a.settlements[key][:0]
+	for _, t := range a.settlements[key] {
+		if now.Sub(t) < a.window {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+
+	if n := len(recent); n > a.limit {
+		delete(a.settlements, key)
+		return n, false
+	}
+	a.settlements[key] = recent
+	return len(recent), true
+}
@@ -47,6 +47,8 @@ type Service interface {
 	Withdraw(ctx context.Context, amount *big.Int) (hash common.Hash, err error)
 	// WaitForDeposit waits for the deposit transaction to confirm and verifies the result.
 	WaitForDeposit(ctx context.Context, txHash common.Hash) error
+	// WaitForWithdraw waits for the withdraw transaction to confirm and verifies the result.
+	WaitForWithdraw(ctx context.Context, txHash common.Hash) error
 	// Balance returns the token balance of the chequebook.
 	Balance(ctx context.Context) (*big.Int, error)
 	// AvailableBalance returns the token balance of the chequebook which is not yet used for uncashed cheques.
@@ -75,10 +77,13 @@ type service struct {
 	store               storage.StateStorer
 	chequeSigner        ChequeSigner
 	totalIssuedReserved *big.Int
+	limits              Limits
+	dailyReserved       map[common.Address]*big.Int
 }
 
 // New creates a new chequebook service for the provided chequebook contract.
-func New(backend transaction.Backend, transactionService transaction.Service, address, ownerAddress common.Address, store storage.StateStorer, chequeSigner ChequeSigner, erc20Service erc20.Service, simpleSwapBindingFunc SimpleSwapBindingFunc) (Service, error) {
+// The issued cheques are kept within the limits.
+func New(backend transaction.Backend, transactionService transaction.Service, address, ownerAddress common.Address, store storage.StateStorer, chequeSigner ChequeSigner, erc20Service erc20.Service, simpleSwapBindingFunc SimpleSwapBindingFunc, limits Limits) (Service, error) {
 	chequebookInstance, err := simpleSwapBindingFunc(address, backend)
 	if err != nil {
 		return nil, err
@@ -94,6 +99,8 @@ func New(backend transaction.Backend, transactionService transaction.Service, ad
 		store:               store,
 		chequeSigner:        chequeSigner,
 		totalIssuedReserved: big.NewInt(0),
+		limits:              limits,
+		dailyReserved:       make(map[common.Address]*big.Int),
 	}, nil
 }
 
@@ -152,6 +159,17 @@ func (s *service) AvailableBalance(ctx context.Context) (*big.Int, error) {
 
 // WaitForDeposit waits for the deposit transaction to confirm and verifies the result.
 func (s *service) WaitForDeposit(ctx context.Context, txHash common.Hash) error {
+	return s.waitForTransaction(ctx, txHash)
+}
+
+// WaitForWithdraw waits for the withdraw transaction to confirm and verifies the result.
+func (s *service) WaitForWithdraw(ctx context.Context, txHash common.Hash) error {
+	return s.waitForTransaction(ctx, txHash)
+}
+
+// waitForTransaction waits for the transaction to confirm and checks that it
+// was not reverted.
+func (s *service) waitForTransaction(ctx context.Context, txHash common.Hash) error {
 	receipt, err := s.transactionService.WaitForReceipt(ctx, txHash)
 	if err != nil {
 		return err
@@ -167,10 +185,14 @@ func lastIssuedChequeKey(beneficiary common.Address) string {
 	return fmt.Sprintf("%s%x", lastIssuedChequeKeyPrefix, beneficiary)
 }
 
-func (s *service) reserveTotalIssued(ctx context.Context, amount *big.Int) (*big.Int, error) {
+func (s *service) reserveTotalIssued(ctx context.Context, beneficiary common.Address, amount *big.Int) (*big.Int, error) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
+	if err := s.checkLimits(beneficiary, amount); err != nil {
+		return nil, err
+	}
+
 	availableBalance, err := s.AvailableBalance(ctx)
 	if err != nil {
 		return nil, err
@@ -181,25 +203,28 @@ func (s *service) reserveTotalIssued(ctx context.Context, amount *big.Int) (*big
 	}
 
 	s.totalIssuedReserved = s.totalIssuedReserved.Add(s.totalIssuedReserved, amount)
+	s.reserveDailyIssued(beneficiary, amount)
 	return big.NewInt(0).Sub(availableBalance, amount), nil
 }
 
-func (s *service) unreserveTotalIssued(amount *big.Int) {
+func (s *service) unreserveTotalIssued(beneficiary common.Address, amount *big.Int) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	s.totalIssuedReserved = s.totalIssuedReserved.Sub(s.totalIssuedReserved, amount)
+	s.unreserveDailyIssued(beneficiary, amount)
 }
 
 // Issue issues a new cheque and passes it to sendChequeFunc.
 // The cheque is considered sent and saved when sendChequeFunc succeeds.
 // The available balance which is available after sending the cheque is passed
 // to the caller for it to be communicated over metrics.
+// The cheques over the limits are not issued.
 func (s *service) Issue(ctx context.Context, beneficiary common.Address, amount *big.Int, sendChequeFunc SendChequeFunc) (*big.Int, error) {
-	availableBalance, err := s.reserveTotalIssued(ctx, amount)
+	availableBalance, err := s.reserveTotalIssued(ctx, beneficiary, amount)
 	if err != nil {
 		return nil, err
 	}
-	defer s.unreserveTotalIssued(amount)
+	defer s.unreserveTotalIssued(beneficiary, amount)
 
 	var cumulativePayout *big.Int
 	lastCheque, err := s.LastCheque(beneficiary)
@@ -253,7 +278,13 @@ func (s *service) Issue(ctx context.Context, beneficiary common.Address, amount
 		return nil, err
 	}
 	totalIssued = totalIssued.Add(totalIssued, amount)
-	return availableBalance, s.store.Put(totalIssuedKey, totalIssued)
+	if err := s.store.Put(totalIssuedKey, totalIssued); err != nil {
+		return nil, err
+	}
+
+	// the amount is added to the daily issuance before its reservation is
+	// released, as the cheque is sent
+	return availableBalance, s.addDailyIssued(beneficiary, amount)
 }
 
 // returns the total amount in cheques issued so far
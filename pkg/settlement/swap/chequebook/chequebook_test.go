@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
@@ -52,6 +53,7 @@ func newTestChequebook(
 			}
 			return simpleSwapBinding, nil
 		},
+		chequebook.Limits{},
 	)
 }
 
@@ -570,3 +572,129 @@ func TestChequebookWithdrawInsufficientFunds(t *testing.T) {
 		t.Fatalf("got wrong error. wanted %v, got %v", chequebook.ErrInsufficientFunds, err)
 	}
 }
+
+func TestChequebookWaitForWithdrawReverted(t *testing.T) {
+	address := common.HexToAddress("0xabcd")
+	ownerAdress := common.HexToAddress("0xfff")
+	txHash := common.HexToHash("0xdddd")
+	chequebookService, err := newTestChequebook(
+		t,
+		backendmock.New(),
+		transactionmock.New(
+			transactionmock.WithWaitForReceiptFunc(func(ctx context.Context, tx common.Hash) (*types.Receipt, error) {
+				if tx != txHash {
+					t.Fatalf("waiting for wrong transaction. wanted %x, got %x", txHash, tx)
+				}
+				return &types.Receipt{
+					Status: 0,
+				}, nil
+			}),
+		),
+		address,
+		ownerAdress,
+		nil,
+		&chequeSignerMock{},
+		erc20mock.New(),
+		&simpleSwapBindingMock{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chequebookService.WaitForWithdraw(context.Background(), txHash)
+	if !errors.Is(err, transaction.ErrTransactionReverted) {
+		t.Fatalf("wrong error. wanted %v, got %v", transaction.ErrTransactionReverted, err)
+	}
+}
+
+func TestChequebookIssueLimits(t *testing.T) {
+	address := common.HexToAddress("0xabcd")
+	beneficiary := common.HexToAddress("0xdddd")
+	other := common.HexToAddress("0xeeee")
+	ownerAdress := common.HexToAddress("0xfff")
+	store := storemock.NewStateStore()
+
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	chequebook.SetTimeNow(func() time.Time { return now })
+	defer chequebook.SetTimeNow(time.Now)
+
+	chequebookService, err := chequebook.New(
+		backendmock.New(),
+		transactionmock.New(),
+		address,
+		ownerAdress,
+		store,
+		&chequeSignerMock{
+			sign: func(cheque *chequebook.Cheque) ([]byte, error) {
+				return []byte{1}, nil
+			},
+		},
+		erc20mock.New(),
+		func(common.Address, bind.ContractBackend) (chequebook.SimpleSwapBinding, error) {
+			return &simpleSwapBindingMock{
+				balance: func(*bind.CallOpts) (*big.Int, error) {
+					return big.NewInt(1000), nil
+				},
+				totalPaidOut: func(*bind.CallOpts) (*big.Int, error) {
+					return big.NewInt(0), nil
+				},
+			}, nil
+		},
+		chequebook.Limits{
+			MaxCheque:        big.NewInt(50),
+			MaxDailyIssuance: big.NewInt(80),
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issue := func(beneficiary common.Address, amount int64) error {
+		_, err := chequebookService.Issue(context.Background(), beneficiary, big.NewInt(amount), func(*chequebook.SignedCheque) error {
+			return nil
+		})
+		return err
+	}
+
+	if err := issue(beneficiary, 51); !errors.Is(err, chequebook.ErrChequeLimitExceeded) {
+		t.Fatalf("wrong error. wanted %v, got %v", chequebook.ErrChequeLimitExceeded, err)
+	}
+	if err := issue(beneficiary, 50); err != nil {
+		t.Fatal(err)
+	}
+	if err := issue(beneficiary, 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := issue(beneficiary, 1); !errors.Is(err, chequebook.ErrDailyLimitExceeded) {
+		t.Fatalf("wrong error. wanted %v, got %v", chequebook.ErrDailyLimitExceeded, err)
+	}
+
+	// the limit is per beneficiary
+	if err := issue(other, 50); err != nil {
+		t.Fatal(err)
+	}
+
+	lastCheque, err := chequebookService.LastCheque(beneficiary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastCheque.CumulativePayout.Cmp(big.NewInt(80)) != 0 {
+		t.Fatalf("wrong cumulative payout. wanted %d, got %d", 80, lastCheque.CumulativePayout)
+	}
+
+	// the limit is reset on the next day, and the amount of the cheque
+	// being sent is counted for the concurrent ones
+	now = now.Add(24 * time.Hour)
+	_, err = chequebookService.Issue(context.Background(), beneficiary, big.NewInt(50), func(*chequebook.SignedCheque) error {
+		if err := issue(beneficiary, 31); !errors.Is(err, chequebook.ErrDailyLimitExceeded) {
+			t.Errorf("wrong error. wanted %v, got %v", chequebook.ErrDailyLimitExceeded, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := issue(beneficiary, 30); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -41,6 +41,9 @@ type ChequeStore interface {
 	LastCheque(chequebook common.Address) (*SignedCheque, error)
 	// LastCheques returns the last received cheques from every known chequebook.
 	LastCheques() (map[common.Address]*SignedCheque, error)
+	// VerifyChequebook checks that the chequebook was deployed by the factory
+	// so that it can be verified before the first cheque from it is received.
+	VerifyChequebook(ctx context.Context, chequebook common.Address) error
 }
 
 type chequeStore struct {
@@ -52,6 +55,9 @@ type chequeStore struct {
 	backend               transaction.Backend
 	beneficiary           common.Address // the beneficiary we expect in cheques sent to us
 	recoverChequeFunc     RecoverChequeFunc
+
+	verifiedMu sync.Mutex
+	verified   map[common.Address]struct{} // chequebooks verified with the factory
 }
 
 type RecoverChequeFunc func(cheque *SignedCheque, chainID int64) (common.Address, error)
@@ -73,6 +79,7 @@ func NewChequeStore(
 		simpleSwapBindingFunc: simpleSwapBindingFunc,
 		beneficiary:           beneficiary,
 		recoverChequeFunc:     recoverChequeFunc,
+		verified:              make(map[common.Address]struct{}),
 	}
 }
 
@@ -117,7 +124,7 @@ func (s *chequeStore) ReceiveCheque(ctx context.Context, cheque *SignedCheque) (
 		}
 
 		// if this is the first cheque from this chequebook, verify with the factory.
-		err = s.factory.VerifyChequebook(ctx, cheque.Chequebook)
+		err = s.VerifyChequebook(ctx, cheque.Chequebook)
 		if err != nil {
 			return nil, err
 		}
@@ -188,6 +195,26 @@ func (s *chequeStore) ReceiveCheque(ctx context.Context, cheque *SignedCheque) (
 	return amount, nil
 }
 
+// VerifyChequebook checks that the chequebook was deployed by the factory. The
+// factory is queried only once for every successfully verified chequebook.
+func (s *chequeStore) VerifyChequebook(ctx context.Context, chequebook common.Address) error {
+	s.verifiedMu.Lock()
+	_, ok := s.verified[chequebook]
+	s.verifiedMu.Unlock()
+	if ok {
+		return nil
+	}
+
+	if err := s.factory.VerifyChequebook(ctx, chequebook); err != nil {
+		return err
+	}
+
+	s.verifiedMu.Lock()
+	s.verified[chequebook] = struct{}{}
+	s.verifiedMu.Unlock()
+	return nil
+}
+
 // RecoverCheque recovers the issuer ethereum address from a signed cheque
 func RecoverCheque(cheque *SignedCheque, chaindID int64) (common.Address, error) {
 	eip712Data := eip712DataForCheque(&cheque.Cheque, chaindID)
@@ -129,6 +129,49 @@ func TestReceiveCheque(t *testing.T) {
 	}
 }
 
+func TestVerifyChequebook(t *testing.T) {
+	chequebookAddress := common.HexToAddress("0xeeee")
+
+	var (
+		verifyCalls int
+		verifyErr   = chequebook.ErrNotDeployedByFactory
+	)
+	chequestore := chequebook.NewChequeStore(
+		storemock.NewStateStore(),
+		backendmock.New(),
+		&factoryMock{
+			verifyChequebook: func(ctx context.Context, address common.Address) error {
+				if address != chequebookAddress {
+					t.Fatal("verifying wrong chequebook")
+				}
+				verifyCalls++
+				return verifyErr
+			},
+		},
+		1,
+		common.HexToAddress("0xffff"),
+		nil,
+		nil,
+	)
+
+	err := chequestore.VerifyChequebook(context.Background(), chequebookAddress)
+	if !errors.Is(err, chequebook.ErrNotDeployedByFactory) {
+		t.Fatalf("wrong error. wanted %v, got %v", chequebook.ErrNotDeployedByFactory, err)
+	}
+
+	// failed verifications are not cached
+	verifyErr = nil
+	for i := 0; i < 2; i++ {
+		if err := chequestore.VerifyChequebook(context.Background(), chequebookAddress); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if verifyCalls != 2 {
+		t.Fatalf("verified with factory %d times, wanted %d", verifyCalls, 2)
+	}
+}
+
 func TestReceiveChequeInvalidBeneficiary(t *testing.T) {
 	store := storemock.NewStateStore()
 	beneficiary := common.HexToAddress("0xffff")
@@ -6,6 +6,7 @@ package chequebook
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
@@ -17,6 +18,9 @@ import (
 	"github.com/yanhuangpai/voyager/pkg/storage"
 )
 
+// ErrNoChequebook is returned by Load if no chequebook has been deployed.
+var ErrNoChequebook = errors.New("no chequebook deployed")
+
 const (
 	chequebookKey           = "swap_chequebook"
 	chequebookDeploymentKey = "swap_chequebook_transaction_deployment"
@@ -118,6 +122,7 @@ func Init(
 	overlayEthAddress common.Address,
 	chequeSigner ChequeSigner,
 	simpleSwapBindingFunc SimpleSwapBindingFunc,
+	limits Limits,
 ) (chequebookService Service, err error) {
 	// verify that the supplied factory is valid
 	err = chequebookFactory.VerifyBytecode(ctx)
@@ -182,7 +187,7 @@ func Init(
 			return nil, err
 		}
 
-		chequebookService, err = New(swapBackend, transactionService, chequebookAddress, overlayEthAddress, stateStore, chequeSigner, erc20Service, simpleSwapBindingFunc)
+		chequebookService, err = New(swapBackend, transactionService, chequebookAddress, overlayEthAddress, stateStore, chequeSigner, erc20Service, simpleSwapBindingFunc, limits)
 		if err != nil {
 			return nil, err
 		}
@@ -203,7 +208,7 @@ func Init(
 			logger.Info("successfully deposited to chequebook")
 		}
 	} else {
-		chequebookService, err = New(swapBackend, transactionService, chequebookAddress, overlayEthAddress, stateStore, chequeSigner, erc20Service, simpleSwapBindingFunc)
+		chequebookService, err = New(swapBackend, transactionService, chequebookAddress, overlayEthAddress, stateStore, chequeSigner, erc20Service, simpleSwapBindingFunc, limits)
 		if err != nil {
 			return nil, err
 		}
@@ -219,3 +224,36 @@ func Init(
 
 	return chequebookService, nil
 }
+
+// Load returns the chequebook service of the chequebook deployed before by
+// Init. It does not deploy one, ErrNoChequebook is returned if there is none.
+func Load(
+	ctx context.Context,
+	chequebookFactory Factory,
+	stateStore storage.StateStorer,
+	transactionService transaction.Service,
+	swapBackend transaction.Backend,
+	overlayEthAddress common.Address,
+	chequeSigner ChequeSigner,
+	simpleSwapBindingFunc SimpleSwapBindingFunc,
+	limits Limits,
+) (Service, error) {
+	var chequebookAddress common.Address
+	if err := stateStore.Get(chequebookKey, &chequebookAddress); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrNoChequebook
+		}
+		return nil, err
+	}
+
+	if err := chequebookFactory.VerifyChequebook(ctx, chequebookAddress); err != nil {
+		return nil, err
+	}
+
+	erc20Address, err := chequebookFactory.ERC20Address(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(swapBackend, transactionService, chequebookAddress, overlayEthAddress, stateStore, chequeSigner, erc20.New(swapBackend, transactionService, erc20Address), simpleSwapBindingFunc, limits)
+}
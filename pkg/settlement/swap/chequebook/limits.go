@@ -0,0 +1,138 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chequebook
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/yanhuangpai/voyager/pkg/storage"
+)
+
+const dailyIssuedKeyPrefix = "swap_chequebook_daily_issued_"
+
+var (
+	// ErrChequeLimitExceeded is the error when the amount of a cheque is over
+	// the maximal amount of a single cheque.
+	ErrChequeLimitExceeded = errors.New("cheque amount over limit")
+	// ErrDailyLimitExceeded is the error when a cheque would take the amount
+	// issued to the beneficiary during the day over the daily limit.
+	ErrDailyLimitExceeded = errors.New("daily issuance over limit")
+)
+
+// timeNow is used to deterministically mock time.Now() in tests.
+var timeNow = time.Now
+
+// Limits are the sanity limits of the issued cheques, which keep an
+// accounting error from draining the chequebook. A nil limit is not enforced.
+type Limits struct {
+	// MaxCheque is the maximal amount of a single cheque.
+	MaxCheque *big.Int
+	// MaxDailyIssuance is the maximal amount issued to a beneficiary during
+	// a day in UTC.
+	MaxDailyIssuance *big.Int
+}
+
+// dailyIssuance is the amount issued to a beneficiary during the day.
+type dailyIssuance struct {
+	Day    int64    `json:"day"`
+	Amount *big.Int `json:"amount"`
+}
+
+// dailyIssuedKey computes the key where to store the amount issued to a
+// beneficiary during the day.
+func dailyIssuedKey(beneficiary common.Address) string {
+	return fmt.Sprintf("%s%x", dailyIssuedKeyPrefix, beneficiary)
+}
+
+// today returns the number of the current day in UTC.
+func today() int64 {
+	return timeNow().UTC().Unix() / int64(24*time.Hour/time.Second)
+}
+
+// checkLimits returns an error if the cheque of the amount for the
+// beneficiary is over the limits, counting the amounts reserved for the
+// cheques being issued. It must be called with the lock held.
+func (s *service) checkLimits(beneficiary common.Address, amount *big.Int) error {
+	if s.limits.MaxCheque != nil && amount.Cmp(s.limits.MaxCheque) > 0 {
+		return fmt.Errorf("%w: amount %d, limit %d", ErrChequeLimitExceeded, amount, s.limits.MaxCheque)
+	}
+	if s.limits.MaxDailyIssuance == nil {
+		return nil
+	}
+	issued, err := s.dailyIssued(beneficiary)
+	if err != nil {
+		return err
+	}
+	if reserved, ok := s.dailyReserved[beneficiary]; ok {
+		issued = new(big.Int).Add(issued, reserved)
+	}
+	if total := new(big.Int).Add(issued, amount); total.Cmp(s.limits.MaxDailyIssuance) > 0 {
+		return fmt.Errorf("%w: issued %d, amount %d, limit %d", ErrDailyLimitExceeded, issued, amount, s.limits.MaxDailyIssuance)
+	}
+	return nil
+}
+
+// reserveDailyIssued reserves the amount of the cheque being issued to the
+// beneficiary, so that the concurrent cheques are checked against it. It must
+// be called with the lock held.
+func (s *service) reserveDailyIssued(beneficiary common.Address, amount *big.Int) {
+	if s.limits.MaxDailyIssuance == nil {
+		return
+	}
+	reserved, ok := s.dailyReserved[beneficiary]
+	if !ok {
+		reserved = big.NewInt(0)
+	}
+	s.dailyReserved[beneficiary] = reserved.Add(reserved, amount)
+}
+
+// unreserveDailyIssued releases the amount reserved for the cheque issued to
+// the beneficiary. It must be called with the lock held.
+func (s *service) unreserveDailyIssued(beneficiary common.Address, amount *big.Int) {
+	reserved, ok := s.dailyReserved[beneficiary]
+	if !ok {
+		return
+	}
+	if reserved.Sub(reserved, amount).Sign() <= 0 {
+		delete(s.dailyReserved, beneficiary)
+	}
+}
+
+// dailyIssued returns the amount issued to the beneficiary today. It must be
+// called with the lock held.
+func (s *service) dailyIssued(beneficiary common.Address) (*big.Int, error) {
+	var issued dailyIssuance
+	err := s.store.Get(dailyIssuedKey(beneficiary), &issued)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return big.NewInt(0), nil
+		}
+		return nil, err
+	}
+	if issued.Day != today() || issued.Amount == nil {
+		return big.NewInt(0), nil
+	}
+	return issued.Amount, nil
+}
+
+// addDailyIssued adds the amount to the amount issued to the beneficiary
+// today. It must be called with the lock held.
+func (s *service) addDailyIssued(beneficiary common.Address, amount *big.Int) error {
+	if s.limits.MaxDailyIssuance == nil {
+		return nil
+	}
+	issued, err := s.dailyIssued(beneficiary)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(dailyIssuedKey(beneficiary), dailyIssuance{
+		Day:    today(),
+		Amount: new(big.Int).Add(issued, amount),
+	})
+}
@@ -21,6 +21,8 @@ type Service struct {
 	chequebookIssueFunc            func(ctx context.Context, beneficiary common.Address, amount *big.Int, sendChequeFunc chequebook.SendChequeFunc) (*big.Int, error)
 	chequebookWithdrawFunc         func(ctx context.Context, amount *big.Int) (hash common.Hash, err error)
 	chequebookDepositFunc          func(ctx context.Context, amount *big.Int) (hash common.Hash, err error)
+	chequebookWaitForDepositFunc   func(ctx context.Context, txHash common.Hash) error
+	chequebookWaitForWithdrawFunc  func(ctx context.Context, txHash common.Hash) error
 }
 
 // WithChequebook*Functions set the mock chequebook functions
@@ -48,6 +50,18 @@ func WithChequebookDepositFunc(f func(ctx context.Context, amount *big.Int) (has
 	})
 }
 
+func WithChequebookWaitForDepositFunc(f func(ctx context.Context, txHash common.Hash) error) Option {
+	return optionFunc(func(s *Service) {
+		s.chequebookWaitForDepositFunc = f
+	})
+}
+
+func WithChequebookWaitForWithdrawFunc(f func(ctx context.Context, txHash common.Hash) error) Option {
+	return optionFunc(func(s *Service) {
+		s.chequebookWaitForWithdrawFunc = f
+	})
+}
+
 func WithChequebookIssueFunc(f func(ctx context.Context, beneficiary common.Address, amount *big.Int, sendChequeFunc chequebook.SendChequeFunc) (*big.Int, error)) Option {
 	return optionFunc(func(s *Service) {
 		s.chequebookIssueFunc = f
@@ -94,6 +108,17 @@ func (s *Service) Deposit(ctx context.Context, amount *big.Int) (hash common.Has
 
 // WaitForDeposit mocks the chequebook .WaitForDeposit function
 func (s *Service) WaitForDeposit(ctx context.Context, txHash common.Hash) error {
+	if s.chequebookWaitForDepositFunc != nil {
+		return s.chequebookWaitForDepositFunc(ctx, txHash)
+	}
+	return errors.New("Error")
+}
+
+// WaitForWithdraw mocks the chequebook .WaitForWithdraw function
+func (s *Service) WaitForWithdraw(ctx context.Context, txHash common.Hash) error {
+	if s.chequebookWaitForWithdrawFunc != nil {
+		return s.chequebookWaitForWithdrawFunc(ctx, txHash)
+	}
 	return errors.New("Error")
 }
 
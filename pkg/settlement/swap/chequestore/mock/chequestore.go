@@ -17,6 +17,8 @@ type Service struct {
 	receiveCheque func(ctx context.Context, cheque *chequebook.SignedCheque) (*big.Int, error)
 	lastCheque    func(chequebook common.Address) (*chequebook.SignedCheque, error)
 	lastCheques   func() (map[common.Address]*chequebook.SignedCheque, error)
+
+	verifyChequebook func(ctx context.Context, chequebook common.Address) error
 }
 
 func WithRetrieveChequeFunc(f func(ctx context.Context, cheque *chequebook.SignedCheque) (*big.Int, error)) Option {
@@ -37,6 +39,12 @@ func WithLastChequesFunc(f func() (map[common.Address]*chequebook.SignedCheque,
 	})
 }
 
+func WithVerifyChequebookFunc(f func(ctx context.Context, chequebook common.Address) error) Option {
+	return optionFunc(func(s *Service) {
+		s.verifyChequebook = f
+	})
+}
+
 // NewChequeStore creates the mock chequeStore implementation
 func NewChequeStore(opts ...Option) chequebook.ChequeStore {
 	mock := new(Service)
@@ -58,6 +66,10 @@ func (s *Service) LastCheques() (map[common.Address]*chequebook.SignedCheque, er
 	return s.lastCheques()
 }
 
+func (s *Service) VerifyChequebook(ctx context.Context, chequebook common.Address) error {
+	return s.verifyChequebook(ctx, chequebook)
+}
+
 // Option is the option passed to the mock ChequeStore service
 type Option interface {
 	apply(*Service)
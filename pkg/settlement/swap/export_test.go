@@ -0,0 +1,21 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package swap
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func SetTimeNow(f func() time.Time) {
+	timeNow = f
+}
+
+// SettlementAlerts returns the number of the settlement alerts reported by
+// the metrics.
+func (s *Service) SettlementAlerts() int {
+	return int(testutil.ToFloat64(s.metrics.SettlementAlerts))
+}
@@ -16,6 +16,7 @@ type metrics struct {
 	ChequesSent      prometheus.Counter
 	ChequesRejected  prometheus.Counter
 	AvailableBalance prometheus.Gauge
+	SettlementAlerts prometheus.Counter
 }
 
 func newMetrics() metrics {
@@ -58,6 +59,12 @@ func newMetrics() metrics {
 			Name:      "available_balance",
 			Help:      "Currently availeble chequebook balance.",
 		}),
+		SettlementAlerts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: m.Namespace,
+			Subsystem: subsystem,
+			Name:      "settlement_alerts",
+			Help:      "Number of times a peer requested settlements at an anomalous rate",
+		}),
 	}
 }
 
@@ -29,7 +29,7 @@ type Service struct {
 	receiveChequeFunc    func(context.Context, infinity.Address, *chequebook.SignedCheque) error
 	payFunc              func(context.Context, infinity.Address, *big.Int) error
 	setNotifyPaymentFunc settlement.NotifyPaymentFunc
-	handshakeFunc        func(infinity.Address, common.Address) error
+	handshakeFunc        func(context.Context, infinity.Address, common.Address, common.Address, []byte) error
 	lastSentChequeFunc   func(infinity.Address) (*chequebook.SignedCheque, error)
 	lastSentChequesFunc  func() (map[string]*chequebook.SignedCheque, error)
 
@@ -85,7 +85,7 @@ func WithSetNotifyPaymentFunc(f settlement.NotifyPaymentFunc) Option {
 	})
 }
 
-func WithHandshakeFunc(f func(infinity.Address, common.Address) error) Option {
+func WithHandshakeFunc(f func(context.Context, infinity.Address, common.Address, common.Address, []byte) error) Option {
 	return optionFunc(func(s *Service) {
 		s.handshakeFunc = f
 	})
@@ -212,9 +212,9 @@ func (s *Service) SettlementsReceived() (map[string]*big.Int, error) {
 }
 
 // Handshake is called by the swap protocol when a handshake is received.
-func (s *Service) Handshake(peer infinity.Address, beneficiary common.Address) error {
+func (s *Service) Handshake(ctx context.Context, peer infinity.Address, beneficiary, chequebook common.Address, nonce []byte) error {
 	if s.handshakeFunc != nil {
-		return s.handshakeFunc(peer, beneficiary)
+		return s.handshakeFunc(ctx, peer, beneficiary, chequebook, nonce)
 	}
 	return nil
 }
@@ -9,9 +9,11 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/yanhuangpai/voyager/pkg/crypto"
+	"github.com/yanhuangpai/voyager/pkg/events"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
@@ -28,8 +30,17 @@ var (
 	ErrWrongBeneficiary = errors.New("wrong beneficiary")
 	// ErrUnknownBeneficary is the error if a peer has never announced a beneficiary.
 	ErrUnknownBeneficary = errors.New("unknown beneficiary for peer")
+	// ErrSwapDisabled is the error if the swap is disabled for the peer.
+	ErrSwapDisabled = errors.New("swap disabled for peer")
 )
 
+// SwapDisabledFunc returns true if the swap is disabled for the peer.
+type SwapDisabledFunc func(peer infinity.Address) (bool, error)
+
+// chequebookBlocklistDuration is the duration for which the peers announcing
+// an invalid chequebook are blocklisted.
+const chequebookBlocklistDuration = 24 * time.Hour
+
 type ApiInterface interface {
 	// LastSentCheque returns the last sent cheque for the peer
 	LastSentCheque(peer infinity.Address) (*chequebook.SignedCheque, error)
@@ -58,6 +69,9 @@ type Service struct {
 	p2pService        p2p.Service
 	addressbook       Addressbook
 	networkID         uint64
+	eventBus          *events.Bus
+	alert             *settlementAlert
+	swapDisabledFunc  SwapDisabledFunc
 }
 
 // New creates a new swap Service.
@@ -78,6 +92,17 @@ func New(proto swapprotocol.Interface, logger logging.Logger, store storage.Stat
 
 // ReceiveCheque is called by the swap protocol if a cheque is received.
 func (s *Service) ReceiveCheque(ctx context.Context, peer infinity.Address, cheque *chequebook.SignedCheque) (err error) {
+	if s.swapDisabledFunc != nil {
+		disabled, err := s.swapDisabledFunc(peer)
+		if err != nil {
+			return err
+		}
+		if disabled {
+			s.metrics.ChequesRejected.Inc()
+			return fmt.Errorf("rejecting cheque: %w", ErrSwapDisabled)
+		}
+	}
+
 	// check this is the same chequebook for this peer as previously
 	expectedChequebook, known, err := s.addressbook.Chequebook(peer)
 	if err != nil {
@@ -102,12 +127,25 @@ func (s *Service) ReceiveCheque(ctx context.Context, peer infinity.Address, cheq
 
 	s.metrics.TotalReceived.Add(float64(amount.Uint64()))
 	s.metrics.ChequesReceived.Inc()
+	s.eventBus.Publish(events.TopicChequeReceived, events.ChequeData{Peer: peer, Amount: amount})
 
 	return s.notifyPaymentFunc(peer, amount)
 }
 
+// SetEventBus sets the bus on which the received cheques are published.
+func (s *Service) SetEventBus(b *events.Bus) {
+	s.eventBus = b
+}
+
+// SetSwapDisabledFunc sets the function which tells the peers whose cheques are
+// rejected, as the swap with them is disabled.
+func (s *Service) SetSwapDisabledFunc(f SwapDisabledFunc) {
+	s.swapDisabledFunc = f
+}
+
 // Pay initiates a payment to the given peer
 func (s *Service) Pay(ctx context.Context, peer infinity.Address, amount *big.Int) error {
+	s.recordSettlement(peer)
 	beneficiary, known, err := s.addressbook.Beneficiary(peer)
 	if err != nil {
 		return err
@@ -222,11 +260,11 @@ func (s *Service) SettlementsReceived() (map[string]*big.Int, error) {
 }
 
 // Handshake is called by the swap protocol when a handshake is received.
-func (s *Service) Handshake(peer infinity.Address, beneficiary common.Address) error {
+func (s *Service) Handshake(ctx context.Context, peer infinity.Address, beneficiary, chequebookAddress common.Address, nonce []byte) error {
 	// check that the overlay address was derived from the beneficiary (implying they have the same private key)
 	// while this is not strictly necessary for correct functionality we need to ensure no two peers use the same beneficiary
 	// as long as we enforce this we might not need the handshake message if the p2p layer exposed the overlay public key
-	expectedOverlay := crypto.NewOverlayFromEthereumAddress(beneficiary[:], s.networkID)
+	expectedOverlay := crypto.NewOverlayFromEthereumAddress(beneficiary[:], s.networkID, nonce)
 	if !expectedOverlay.Equal(peer) {
 		return ErrWrongBeneficiary
 	}
@@ -237,14 +275,62 @@ func (s *Service) Handshake(peer infinity.Address, beneficiary common.Address) e
 	}
 	if !known {
 		s.logger.Tracef("initial swap handshake peer: %v beneficiary: %x", peer, beneficiary)
-		return s.addressbook.PutBeneficiary(peer, beneficiary)
-	}
-	if storedBeneficiary != beneficiary {
+		if err := s.addressbook.PutBeneficiary(peer, beneficiary); err != nil {
+			return err
+		}
+	} else if storedBeneficiary != beneficiary {
 		return ErrWrongBeneficiary
 	}
+
+	// peers without a chequebook can not send cheques
+	if chequebookAddress == (common.Address{}) {
+		return nil
+	}
+	return s.handshakeChequebook(ctx, peer, chequebookAddress)
+}
+
+// handshakeChequebook verifies the chequebook announced by the peer before the
+// first cheque from it is received. Peers announcing a chequebook different
+// from the known one or one not deployed by the factory are blocklisted. The
+// announced chequebook is not stored, as any chequebook deployed by the factory
+// could be announced, it becomes the known one once the first valid cheque
+// from it is received.
+func (s *Service) handshakeChequebook(ctx context.Context, peer infinity.Address, chequebookAddress common.Address) error {
+	storedChequebook, known, err := s.addressbook.Chequebook(peer)
+	if err != nil {
+		return err
+	}
+	if known {
+		if storedChequebook != chequebookAddress {
+			return s.blocklist(peer, ErrWrongChequebook)
+		}
+		return nil
+	}
+
+	err = s.chequeStore.VerifyChequebook(ctx, chequebookAddress)
+	if err != nil {
+		if errors.Is(err, chequebook.ErrNotDeployedByFactory) {
+			return s.blocklist(peer, err)
+		}
+		// the chequebook is verified again when the first cheque is received
+		s.logger.Debugf("swap handshake: verify chequebook %x of peer %v: %v", chequebookAddress, peer, err)
+		return nil
+	}
+
+	s.logger.Tracef("initial swap handshake peer: %v chequebook: %x", peer, chequebookAddress)
 	return nil
 }
 
+// blocklist blocklists the peer for announcing an invalid chequebook and
+// returns the reason.
+func (s *Service) blocklist(peer infinity.Address, reason error) error {
+	s.logger.Warningf("blocklisting swap peer %v: %v", peer, reason)
+	if err := s.p2pService.Blocklist(peer, chequebookBlocklistDuration); err != nil {
+		return fmt.Errorf("blocklist peer: %w", err)
+	}
+	return reason
+}
+
 // LastSentCheque returns the last sent cheque for the peer
 func (s *Service) LastSentCheque(peer infinity.Address) (*chequebook.SignedCheque, error) {
 
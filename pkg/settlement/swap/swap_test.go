@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/yanhuangpai/voyager/pkg/crypto"
@@ -441,7 +442,7 @@ func TestHandshake(t *testing.T) {
 
 	beneficiary := common.HexToAddress("0xcd")
 	networkID := uint64(1)
-	peer := crypto.NewOverlayFromEthereumAddress(beneficiary[:], networkID)
+	peer := crypto.NewOverlayFromEthereumAddress(beneficiary[:], networkID, nil)
 
 	var putCalled bool
 	swapService := swap.New(
@@ -464,7 +465,7 @@ func TestHandshake(t *testing.T) {
 		mockp2p.New(),
 	)
 
-	err := swapService.Handshake(peer, beneficiary)
+	err := swapService.Handshake(context.Background(), peer, beneficiary, common.Address{}, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -480,7 +481,7 @@ func TestHandshakeNewPeer(t *testing.T) {
 
 	beneficiary := common.HexToAddress("0xcd")
 	networkID := uint64(1)
-	peer := crypto.NewOverlayFromEthereumAddress(beneficiary[:], networkID)
+	peer := crypto.NewOverlayFromEthereumAddress(beneficiary[:], networkID, nil)
 
 	var putCalled bool
 	swapService := swap.New(
@@ -503,7 +504,7 @@ func TestHandshakeNewPeer(t *testing.T) {
 		mockp2p.New(),
 	)
 
-	err := swapService.Handshake(peer, beneficiary)
+	err := swapService.Handshake(context.Background(), peer, beneficiary, common.Address{}, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -533,12 +534,192 @@ func TestHandshakeWrongBeneficiary(t *testing.T) {
 		mockp2p.New(),
 	)
 
-	err := swapService.Handshake(peer, beneficiary)
+	err := swapService.Handshake(context.Background(), peer, beneficiary, common.Address{}, nil)
 	if !errors.Is(err, swap.ErrWrongBeneficiary) {
 		t.Fatalf("wrong error. wanted %v, got %v", swap.ErrWrongBeneficiary, err)
 	}
 }
 
+func TestHandshakeOverlayNonce(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	store := mockstore.NewStateStore()
+
+	beneficiary := common.HexToAddress("0xcd")
+	networkID := uint64(1)
+	nonce := []byte{1, 2, 3}
+	peer := crypto.NewOverlayFromEthereumAddress(beneficiary[:], networkID, nonce)
+
+	swapService := swap.New(
+		&swapProtocolMock{},
+		logger,
+		store,
+		mockchequebook.NewChequebook(),
+		mockchequestore.NewChequeStore(),
+		&addressbookMock{
+			beneficiary: func(p infinity.Address) (common.Address, bool, error) {
+				return beneficiary, true, nil
+			},
+		},
+		networkID,
+		&cashoutMock{},
+		mockp2p.New(),
+	)
+
+	err := swapService.Handshake(context.Background(), peer, beneficiary, common.Address{}, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the overlay address is not derived without the nonce
+	err = swapService.Handshake(context.Background(), peer, beneficiary, common.Address{}, nil)
+	if !errors.Is(err, swap.ErrWrongBeneficiary) {
+		t.Fatalf("wrong error. wanted %v, got %v", swap.ErrWrongBeneficiary, err)
+	}
+}
+
+func TestHandshakeChequebook(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	store := mockstore.NewStateStore()
+
+	beneficiary := common.HexToAddress("0xcd")
+	chequebookAddress := common.HexToAddress("0xee")
+	networkID := uint64(1)
+	peer := crypto.NewOverlayFromEthereumAddress(beneficiary[:], networkID, nil)
+
+	var verified, blocklisted bool
+	var putChequebook common.Address
+	swapService := swap.New(
+		&swapProtocolMock{},
+		logger,
+		store,
+		mockchequebook.NewChequebook(),
+		mockchequestore.NewChequeStore(
+			mockchequestore.WithVerifyChequebookFunc(func(ctx context.Context, c common.Address) error {
+				if c != chequebookAddress {
+					t.Fatalf("verifying wrong chequebook. wanted %x, got %x", chequebookAddress, c)
+				}
+				verified = true
+				return nil
+			}),
+		),
+		&addressbookMock{
+			beneficiary: func(p infinity.Address) (common.Address, bool, error) {
+				return beneficiary, true, nil
+			},
+			chequebook: func(p infinity.Address) (common.Address, bool, error) {
+				return common.Address{}, false, nil
+			},
+			putChequebook: func(p infinity.Address, c common.Address) error {
+				putChequebook = c
+				return nil
+			},
+		},
+		networkID,
+		&cashoutMock{},
+		mockp2p.New(mockp2p.WithBlocklistFunc(func(infinity.Address, time.Duration) error {
+			blocklisted = true
+			return nil
+		})),
+	)
+
+	err := swapService.Handshake(context.Background(), peer, beneficiary, chequebookAddress, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !verified {
+		t.Fatal("chequebook was not verified")
+	}
+	// the chequebook is stored once the first valid cheque is received
+	if putChequebook != (common.Address{}) {
+		t.Fatalf("stored chequebook %x before the first cheque", putChequebook)
+	}
+	if blocklisted {
+		t.Fatal("peer was blocklisted")
+	}
+}
+
+func TestHandshakeChequebookInvalid(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+
+	beneficiary := common.HexToAddress("0xcd")
+	chequebookAddress := common.HexToAddress("0xee")
+	networkID := uint64(1)
+	peer := crypto.NewOverlayFromEthereumAddress(beneficiary[:], networkID, nil)
+
+	for _, tc := range []struct {
+		name            string
+		knownChequebook common.Address
+		verifyErr       error
+		wantErr         error
+		wantBlocklisted bool
+	}{
+		{
+			name:            "not deployed by factory",
+			verifyErr:       chequebook.ErrNotDeployedByFactory,
+			wantErr:         chequebook.ErrNotDeployedByFactory,
+			wantBlocklisted: true,
+		},
+		{
+			name:            "different from known",
+			knownChequebook: common.HexToAddress("0xef"),
+			wantErr:         swap.ErrWrongChequebook,
+			wantBlocklisted: true,
+		},
+		{
+			name:      "verification failed",
+			verifyErr: errors.New("backend down"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var blocklisted, putCalled bool
+			swapService := swap.New(
+				&swapProtocolMock{},
+				logger,
+				mockstore.NewStateStore(),
+				mockchequebook.NewChequebook(),
+				mockchequestore.NewChequeStore(
+					mockchequestore.WithVerifyChequebookFunc(func(ctx context.Context, c common.Address) error {
+						return tc.verifyErr
+					}),
+				),
+				&addressbookMock{
+					beneficiary: func(p infinity.Address) (common.Address, bool, error) {
+						return beneficiary, true, nil
+					},
+					chequebook: func(p infinity.Address) (common.Address, bool, error) {
+						return tc.knownChequebook, tc.knownChequebook != common.Address{}, nil
+					},
+					putChequebook: func(p infinity.Address, c common.Address) error {
+						putCalled = true
+						return nil
+					},
+				},
+				networkID,
+				&cashoutMock{},
+				mockp2p.New(mockp2p.WithBlocklistFunc(func(p infinity.Address, d time.Duration) error {
+					if !p.Equal(peer) {
+						t.Fatalf("blocklisting wrong peer. wanted %v, got %v", peer, p)
+					}
+					blocklisted = true
+					return nil
+				})),
+			)
+
+			err := swapService.Handshake(context.Background(), peer, beneficiary, chequebookAddress, nil)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("wrong error. wanted %v, got %v", tc.wantErr, err)
+			}
+			if blocklisted != tc.wantBlocklisted {
+				t.Fatalf("got blocklisted %v, wanted %v", blocklisted, tc.wantBlocklisted)
+			}
+			if putCalled {
+				t.Fatal("chequebook was stored")
+			}
+		})
+	}
+}
+
 func TestCashout(t *testing.T) {
 	logger := logging.New(ioutil.Discard, 0)
 	store := mockstore.NewStateStore()
@@ -637,3 +818,133 @@ func TestCashoutStatus(t *testing.T) {
 		t.Fatalf("go wrong status. wanted %v, got %v", expectedStatus, returnedStatus)
 	}
 }
+
+func TestReceiveChequeSwapDisabled(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	store := mockstore.NewStateStore()
+
+	peer := infinity.MustParseHexAddress("abcd")
+	chequeStore := mockchequestore.NewChequeStore(
+		mockchequestore.WithRetrieveChequeFunc(func(ctx context.Context, c *chequebook.SignedCheque) (*big.Int, error) {
+			return big.NewInt(10), nil
+		}),
+	)
+	addressbook := &addressbookMock{
+		chequebook: func(p infinity.Address) (common.Address, bool, error) {
+			return common.Address{}, false, nil
+		},
+		putChequebook: func(p infinity.Address, chequebook common.Address) error {
+			return nil
+		},
+	}
+
+	swapService := swap.New(
+		&swapProtocolMock{},
+		logger,
+		store,
+		mockchequebook.NewChequebook(),
+		chequeStore,
+		addressbook,
+		1,
+		&cashoutMock{},
+		mockp2p.New(),
+	)
+	observer := &testObserver{}
+	swapService.SetNotifyPaymentFunc(observer.NotifyPayment)
+
+	disabled := true
+	swapService.SetSwapDisabledFunc(func(p infinity.Address) (bool, error) {
+		if !p.Equal(peer) {
+			t.Fatal("querying swap disabled for wrong peer")
+		}
+		return disabled, nil
+	})
+
+	if err := swapService.ReceiveCheque(context.Background(), peer, &chequebook.SignedCheque{}); !errors.Is(err, swap.ErrSwapDisabled) {
+		t.Fatalf("got error %v, want %v", err, swap.ErrSwapDisabled)
+	}
+	if observer.called {
+		t.Fatal("payment notified for peer with disabled swap")
+	}
+
+	disabled = false
+	if err := swapService.ReceiveCheque(context.Background(), peer, &chequebook.SignedCheque{}); err != nil {
+		t.Fatal(err)
+	}
+	if !observer.called {
+		t.Fatal("payment not notified")
+	}
+}
+
+func TestPaySettlementAlert(t *testing.T) {
+	logger := logging.New(ioutil.Discard, 0)
+	store := mockstore.NewStateStore()
+
+	now := time.Unix(1000, 0)
+	swap.SetTimeNow(func() time.Time { return now })
+	defer swap.SetTimeNow(time.Now)
+
+	peer := infinity.MustParseHexAddress("abcd")
+	other := infinity.MustParseHexAddress("dcba")
+	chequebookService := mockchequebook.NewChequebook(
+		mockchequebook.WithChequebookIssueFunc(func(ctx context.Context, b common.Address, a *big.Int, sendChequeFunc chequebook.SendChequeFunc) (*big.Int, error) {
+			return big.NewInt(0), sendChequeFunc(&chequebook.SignedCheque{})
+		}),
+	)
+	addressbook := &addressbookMock{
+		beneficiary: func(p infinity.Address) (common.Address, bool, error) {
+			return common.HexToAddress("0xcd"), true, nil
+		},
+	}
+
+	swapService := swap.New(
+		&swapProtocolMock{
+			emitCheque: func(ctx context.Context, p infinity.Address, c *chequebook.SignedCheque) error {
+				return nil
+			},
+		},
+		logger,
+		store,
+		chequebookService,
+		mockchequestore.NewChequeStore(),
+		addressbook,
+		1,
+		&cashoutMock{},
+		mockp2p.New(),
+	)
+	swapService.SetSettlementAlert(3, time.Minute)
+
+	pay := func(peer infinity.Address) {
+		t.Helper()
+		if err := swapService.Pay(context.Background(), peer, big.NewInt(10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		pay(peer)
+		pay(other)
+		now = now.Add(10 * time.Second)
+	}
+	if got := swapService.SettlementAlerts(); got != 0 {
+		t.Fatalf("got %d alerts, want none within the limit", got)
+	}
+
+	// the oldest settlement is out of the window
+	now = now.Add(35 * time.Second)
+	pay(peer)
+	if got := swapService.SettlementAlerts(); got != 0 {
+		t.Fatalf("got %d alerts, want none within the limit", got)
+	}
+
+	pay(peer)
+	if got := swapService.SettlementAlerts(); got != 1 {
+		t.Fatalf("got %d alerts, want 1", got)
+	}
+
+	// the settlements are not alerted on again until over the limit
+	pay(peer)
+	if got := swapService.SettlementAlerts(); got != 1 {
+		t.Fatalf("got %d alerts, want 1", got)
+	}
+}
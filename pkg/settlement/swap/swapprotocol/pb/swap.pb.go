@@ -68,6 +68,8 @@ func (m *EmitCheque) GetCheque() []byte {
 
 type Handshake struct {
 	Beneficiary []byte `protobuf:"bytes,1,opt,name=Beneficiary,proto3" json:"Beneficiary,omitempty"`
+	Chequebook  []byte `protobuf:"bytes,2,opt,name=Chequebook,proto3" json:"Chequebook,omitempty"`
+	Nonce       []byte `protobuf:"bytes,3,opt,name=Nonce,proto3" json:"Nonce,omitempty"`
 }
 
 func (m *Handshake) Reset()         { *m = Handshake{} }
@@ -110,6 +112,20 @@ func (m *Handshake) GetBeneficiary() []byte {
 	return nil
 }
 
+func (m *Handshake) GetChequebook() []byte {
+	if m != nil {
+		return m.Chequebook
+	}
+	return nil
+}
+
+func (m *Handshake) GetNonce() []byte {
+	if m != nil {
+		return m.Nonce
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*EmitCheque)(nil), "swapprotocol.EmitCheque")
 	proto.RegisterType((*Handshake)(nil), "swapprotocol.Handshake")
@@ -118,16 +134,18 @@ func init() {
 func init() { proto.RegisterFile("swap.proto", fileDescriptor_c35a3890a6e60fb7) }
 
 var fileDescriptor_c35a3890a6e60fb7 = []byte{
-	// 142 bytes of a gzipped FileDescriptorProto
+	// 169 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2a, 0x2e, 0x4f, 0x2c,
 	0xd0, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x01, 0xb1, 0xc1, 0xcc, 0xe4, 0xfc, 0x1c, 0x25,
 	0x15, 0x2e, 0x2e, 0xd7, 0xdc, 0xcc, 0x12, 0xe7, 0x8c, 0xd4, 0xc2, 0xd2, 0x54, 0x21, 0x31, 0x2e,
-	0x36, 0x08, 0x4b, 0x82, 0x51, 0x81, 0x51, 0x83, 0x27, 0x08, 0xca, 0x53, 0xd2, 0xe5, 0xe2, 0xf4,
+	0x36, 0x08, 0x4b, 0x82, 0x51, 0x81, 0x51, 0x83, 0x27, 0x08, 0xca, 0x53, 0x4a, 0xe6, 0xe2, 0xf4,
 	0x48, 0xcc, 0x4b, 0x29, 0xce, 0x48, 0xcc, 0x4e, 0x15, 0x52, 0xe0, 0xe2, 0x76, 0x4a, 0xcd, 0x4b,
-	0x4d, 0xcb, 0x4c, 0xce, 0x4c, 0x2c, 0xaa, 0x84, 0xaa, 0x44, 0x16, 0x72, 0x92, 0x39, 0xf1, 0x48,
-	0x8e, 0xf1, 0xc2, 0x23, 0x39, 0xc6, 0x07, 0x8f, 0xe4, 0x18, 0x27, 0x3c, 0x96, 0x63, 0xb8, 0xf0,
-	0x58, 0x8e, 0xe1, 0xc6, 0x63, 0x39, 0x86, 0x28, 0xa6, 0x82, 0xa4, 0x24, 0x36, 0xb0, 0xe5, 0xc6,
-	0x80, 0x00, 0x00, 0x00, 0xff, 0xff, 0x7a, 0xdd, 0x22, 0xd8, 0x95, 0x00, 0x00, 0x00,
+	0x4d, 0xcb, 0x4c, 0xce, 0x4c, 0x2c, 0xaa, 0x84, 0xaa, 0x44, 0x16, 0x12, 0x92, 0xe3, 0xe2, 0x82,
+	0x68, 0x4c, 0xca, 0xcf, 0xcf, 0x96, 0x60, 0x02, 0x2b, 0x40, 0x12, 0x11, 0x12, 0xe1, 0x62, 0xf5,
+	0xcb, 0xcf, 0x4b, 0x4e, 0x95, 0x60, 0x06, 0x4b, 0x41, 0x38, 0x4e, 0x32, 0x27, 0x1e, 0xc9, 0x31,
+	0x5e, 0x78, 0x24, 0xc7, 0xf8, 0xe0, 0x91, 0x1c, 0xe3, 0x84, 0xc7, 0x72, 0x0c, 0x17, 0x1e, 0xcb,
+	0x31, 0xdc, 0x78, 0x2c, 0xc7, 0x10, 0xc5, 0x54, 0x90, 0x94, 0xc4, 0x06, 0x76, 0xb2, 0x31, 0x60,
+	0x00, 0xd4, 0x3f, 0x7b, 0xab, 0xcb, 0x00, 0x00, 0x00,
 }
 
 func (m *EmitCheque) Marshal() (dAtA []byte, err error) {
@@ -180,6 +198,20 @@ func (m *Handshake) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Nonce) > 0 {
+		i -= len(m.Nonce)
+		copy(dAtA[i:], m.Nonce)
+		i = encodeVarintSwap(dAtA, i, uint64(len(m.Nonce)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Chequebook) > 0 {
+		i -= len(m.Chequebook)
+		copy(dAtA[i:], m.Chequebook)
+		i = encodeVarintSwap(dAtA, i, uint64(len(m.Chequebook)))
+		i--
+		dAtA[i] = 0x12
+	}
 	if len(m.Beneficiary) > 0 {
 		i -= len(m.Beneficiary)
 		copy(dAtA[i:], m.Beneficiary)
@@ -224,6 +256,14 @@ func (m *Handshake) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovSwap(uint64(l))
 	}
+	l = len(m.Chequebook)
+	if l > 0 {
+		n += 1 + l + sovSwap(uint64(l))
+	}
+	l = len(m.Nonce)
+	if l > 0 {
+		n += 1 + l + sovSwap(uint64(l))
+	}
 	return n
 }
 
@@ -383,6 +423,74 @@ func (m *Handshake) Unmarshal(dAtA []byte) error {
 				m.Beneficiary = []byte{}
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Chequebook", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSwap
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthSwap
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthSwap
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Chequebook = append(m.Chequebook[:0], dAtA[iNdEx:postIndex]...)
+			if m.Chequebook == nil {
+				m.Chequebook = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Nonce", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSwap
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthSwap
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthSwap
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Nonce = append(m.Nonce[:0], dAtA[iNdEx:postIndex]...)
+			if m.Nonce == nil {
+				m.Nonce = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipSwap(dAtA[iNdEx:])
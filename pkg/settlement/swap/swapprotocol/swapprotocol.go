@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/yanhuangpai/voyager/pkg/crypto"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
@@ -38,7 +39,9 @@ type Swap interface {
 	// ReceiveCheque is called by the swap protocol if a cheque is received.
 	ReceiveCheque(ctx context.Context, peer infinity.Address, cheque *chequebook.SignedCheque) error
 	// Handshake is called by the swap protocol when a handshake is received.
-	Handshake(peer infinity.Address, beneficiary common.Address) error
+	// The chequebook is the zero address if the peer did not announce one and
+	// the nonce is the one the overlay address of the peer is derived with.
+	Handshake(ctx context.Context, peer infinity.Address, beneficiary, chequebook common.Address, nonce []byte) error
 }
 
 // Service is the main implementation of the swap protocol.
@@ -47,14 +50,19 @@ type Service struct {
 	logger      logging.Logger
 	swap        Swap
 	beneficiary common.Address
+	chequebook  common.Address
+	nonce       []byte
 }
 
-// New creates a new swap protocol Service.
-func New(streamer p2p.Streamer, logger logging.Logger, beneficiary common.Address) *Service {
+// New creates a new swap protocol Service. The beneficiary and the chequebook
+// addresses are announced to the peers in the handshake. The chequebook is not
+// announced if it is the zero address.
+func New(streamer p2p.Streamer, logger logging.Logger, beneficiary, chequebook common.Address) *Service {
 	return &Service{
 		streamer:    streamer,
 		logger:      logger,
 		beneficiary: beneficiary,
+		chequebook:  chequebook,
 	}
 }
 
@@ -63,6 +71,12 @@ func (s *Service) SetSwap(swap Swap) {
 	s.swap = swap
 }
 
+// SetOverlayNonce sets the nonce the overlay address of the node is derived
+// with, which is announced to the peers in the handshake.
+func (s *Service) SetOverlayNonce(nonce []byte) {
+	s.nonce = nonce
+}
+
 func (s *Service) Protocol() p2p.ProtocolSpec {
 	return p2p.ProtocolSpec{
 		Name:    protocolName,
@@ -95,19 +109,17 @@ func (s *Service) initHandler(ctx context.Context, p p2p.Peer, stream p2p.Stream
 		return fmt.Errorf("read request from peer %v: %w", p.Address, err)
 	}
 
-	if len(req.Beneficiary) != 20 {
-		return errors.New("malformed beneficiary address")
+	beneficiary, chequebook, nonce, err := parseHandshake(&req)
+	if err != nil {
+		return err
 	}
 
-	err = w.WriteMsgWithContext(ctx, &pb.Handshake{
-		Beneficiary: s.beneficiary.Bytes(),
-	})
+	err = w.WriteMsgWithContext(ctx, s.handshake())
 	if err != nil {
 		return err
 	}
 
-	beneficiary := common.BytesToAddress(req.Beneficiary)
-	return s.swap.Handshake(p.Address, beneficiary)
+	return s.swap.Handshake(ctx, p.Address, beneficiary, chequebook, nonce)
 }
 
 // init is called on outgoing connections and triggers handshake exchange
@@ -128,9 +140,7 @@ func (s *Service) init(ctx context.Context, p p2p.Peer) error {
 	}()
 
 	w, r := protobuf.NewWriterAndReader(stream)
-	err = w.WriteMsgWithContext(ctx, &pb.Handshake{
-		Beneficiary: s.beneficiary.Bytes(),
-	})
+	err = w.WriteMsgWithContext(ctx, s.handshake())
 	if err != nil {
 		return err
 	}
@@ -140,14 +150,41 @@ func (s *Service) init(ctx context.Context, p p2p.Peer) error {
 		return fmt.Errorf("read request from peer %v: %w", p.Address, err)
 	}
 
-	// any 20-byte byte-sequence is a valid eth address
-	if len(req.Beneficiary) != 20 {
-		return errors.New("malformed beneficiary address")
+	beneficiary, chequebook, nonce, err := parseHandshake(&req)
+	if err != nil {
+		return err
 	}
 
-	beneficiary := common.BytesToAddress(req.Beneficiary)
+	return s.swap.Handshake(ctx, p.Address, beneficiary, chequebook, nonce)
+}
+
+// handshake returns the handshake message announcing our addresses.
+func (s *Service) handshake() *pb.Handshake {
+	msg := &pb.Handshake{
+		Beneficiary: s.beneficiary.Bytes(),
+		Nonce:       s.nonce,
+	}
+	if s.chequebook != (common.Address{}) {
+		msg.Chequebook = s.chequebook.Bytes()
+	}
+	return msg
+}
 
-	return s.swap.Handshake(p.Address, beneficiary)
+// parseHandshake returns the beneficiary and the optional chequebook addresses
+// and the overlay nonce announced in the handshake message.
+func parseHandshake(msg *pb.Handshake) (beneficiary, chequebook common.Address, nonce []byte, err error) {
+	// any 20-byte byte-sequence is a valid eth address
+	if len(msg.Beneficiary) != 20 {
+		return common.Address{}, common.Address{}, nil, errors.New("malformed beneficiary address")
+	}
+	// peers without a chequebook do not announce one
+	if len(msg.Chequebook) != 0 && len(msg.Chequebook) != 20 {
+		return common.Address{}, common.Address{}, nil, errors.New("malformed chequebook address")
+	}
+	if len(msg.Nonce) > crypto.MaxOverlayNonceSize {
+		return common.Address{}, common.Address{}, nil, errors.New("malformed overlay nonce")
+	}
+	return common.BytesToAddress(msg.Beneficiary), common.BytesToAddress(msg.Chequebook), msg.Nonce, nil
 }
 
 func (s *Service) handler(ctx context.Context, p p2p.Peer, stream p2p.Stream) (err error) {
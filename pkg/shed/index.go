@@ -44,6 +44,7 @@ type Item struct {
 	StoreTimestamp  int64
 	BinID           uint64
 	PinCounter      uint64 // maintains the no of time a chunk is pinned
+	AccessCount     uint64 // maintains the no of time a chunk is requested
 	Tag             uint32
 }
 
@@ -69,6 +70,9 @@ func (i Item) Merge(i2 Item) Item {
 	if i.PinCounter == 0 {
 		i.PinCounter = i2.PinCounter
 	}
+	if i.AccessCount == 0 {
+		i.AccessCount = i2.AccessCount
+	}
 	if i.Tag == 0 {
 		i.Tag = i2.Tag
 	}
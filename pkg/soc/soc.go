@@ -65,11 +65,26 @@ func (s *SOC) address() (infinity.Address, error) {
 	return CreateAddress(s.id, s.owner)
 }
 
+// ID returns the SOC id.
+func (s *SOC) ID() ID {
+	return s.id
+}
+
+// OwnerAddress returns the ethereum address of the SOC owner.
+func (s *SOC) OwnerAddress() []byte {
+	return s.owner
+}
+
 // WrappedChunk returns the chunk wrapped by the SOC.
 func (s *SOC) WrappedChunk() infinity.Chunk {
 	return s.chunk
 }
 
+// Signature returns the signature of the SOC.
+func (s *SOC) Signature() []byte {
+	return s.signature
+}
+
 // Chunk returns the SOC chunk.
 func (s *SOC) Chunk() (infinity.Chunk, error) {
 	socAddress, err := s.address()
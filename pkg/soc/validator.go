@@ -5,19 +5,40 @@
 package soc
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 )
 
+// ErrInvalidChunk is returned by VerifyChunk for the chunks which are not
+// valid single-owner chunks, wrapped with the reason.
+var ErrInvalidChunk = errors.New("soc: invalid chunk")
+
 // Valid checks if the chunk is a valid single-owner chunk.
 func Valid(ch infinity.Chunk) bool {
+	_, err := VerifyChunk(ch)
+	return err == nil
+}
+
+// VerifyChunk checks that the chunk is a valid single-owner chunk, which is
+// the case if the wrapped chunk is a valid content-addressed chunk and the
+// chunk address is derived from the id and from the owner recovered from the
+// signature of the id and the wrapped chunk address. It returns the parsed
+// chunk, or the reason why it is not valid. It can be used to check the
+// chunks of other owners, as the owner is not known in advance.
+func VerifyChunk(ch infinity.Chunk) (*SOC, error) {
 	s, err := FromChunk(ch)
 	if err != nil {
-		return false
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChunk, err)
 	}
 
 	address, err := s.address()
 	if err != nil {
-		return false
+		return nil, fmt.Errorf("%w: %v", ErrInvalidChunk, err)
+	}
+	if !ch.Address().Equal(address) {
+		return nil, fmt.Errorf("%w: address %s does not match the id %x and the owner %x of the signature, want %s", ErrInvalidChunk, ch.Address(), s.id, s.owner, address)
 	}
-	return ch.Address().Equal(address)
+	return s, nil
 }
@@ -5,9 +5,12 @@
 package soc_test
 
 import (
+	"bytes"
+	"errors"
 	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 	"github.com/yanhuangpai/voyager/pkg/soc"
 )
@@ -27,6 +30,20 @@ func TestValid(t *testing.T) {
 	if !soc.Valid(sch) {
 		t.Fatal("valid chunk evaluates to invalid")
 	}
+
+	s, err := soc.VerifyChunk(sch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := make([]byte, soc.IdSize); !bytes.Equal(s.ID(), id) {
+		t.Fatalf("got id %x, want %x", s.ID(), id)
+	}
+	if owner := common.HexToAddress("8d3766440f0d7b949a5e32995d09619a7f86e632").Bytes(); !bytes.Equal(s.OwnerAddress(), owner) {
+		t.Fatalf("got owner %x, want %x", s.OwnerAddress(), owner)
+	}
+	if got, want := s.WrappedChunk().Data(), []byte{3, 0, 0, 0, 0, 0, 0, 0, 102, 111, 111}; !bytes.Equal(got, want) {
+		t.Fatalf("got wrapped chunk data %v, want %v", got, want)
+	}
 }
 
 // TestInvalid verifies that the validator can detect chunks
@@ -106,9 +123,13 @@ func TestInvalid(t *testing.T) {
 		},
 	} {
 		t.Run(c.name, func(t *testing.T) {
-			if soc.Valid(c.chunk()) {
+			ch := c.chunk()
+			if soc.Valid(ch) {
 				t.Fatal("chunk with invalid data evaluates to valid")
 			}
+			if _, err := soc.VerifyChunk(ch); !errors.Is(err, soc.ErrInvalidChunk) {
+				t.Fatalf("got error %v, want %v", err, soc.ErrInvalidChunk)
+			}
 		})
 	}
 }
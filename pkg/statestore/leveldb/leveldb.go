@@ -22,6 +22,7 @@ var _ storage.StateStorer = (*store)(nil)
 // store uses LevelDB to store values.
 type store struct {
 	db     *leveldb.DB
+	batch  *leveldb.Batch // collects the writes of a migration if set
 	logger logging.Logger
 }
 
@@ -99,11 +100,19 @@ func (s *store) Put(key string, i interface{}) (err error) {
 		return err
 	}
 
+	if s.batch != nil {
+		s.batch.Put([]byte(key), bytes)
+		return nil
+	}
 	return s.db.Put([]byte(key), bytes, nil)
 }
 
 // Delete removes entries stored under a specific key.
 func (s *store) Delete(key string) (err error) {
+	if s.batch != nil {
+		s.batch.Delete([]byte(key))
+		return nil
+	}
 	return s.db.Delete([]byte(key), nil)
 }
 
@@ -17,21 +17,30 @@
 package leveldb
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/yanhuangpai/voyager/pkg/addressbook"
+	"github.com/yanhuangpai/voyager/pkg/storage"
 )
 
 var errMissingCurrentSchema = errors.New("could not find current db schema")
 var errMissingTargetSchema = errors.New("could not find target db schema")
+var errDuplicateSchema = errors.New("duplicate db schema")
 
 const (
-	dbSchemaKey = "statestore_schema"
+	dbSchemaKey        = "statestore_schema"
+	dbSchemaHistoryKey = "statestore_schema_history"
 
-	dbSchemaGrace = "grace"
+	dbSchemaGrace              = "grace"
+	dbSchemaAddressbookEntries = "addressbook-entries"
 )
 
 var (
-	dbSchemaCurrent = dbSchemaGrace
+	dbSchemaCurrent = dbSchemaAddressbookEntries
 )
 
 type migration struct {
@@ -39,15 +48,33 @@ type migration struct {
 	fn   func(s *store) error // the migration function that needs to be performed in order to get to the current schema name
 }
 
+// appliedMigration records a migration applied to the statestore.
+type appliedMigration struct {
+	Name    string    `json:"name"`
+	Applied time.Time `json:"applied"`
+}
+
 // schemaMigrations contains an ordered list of the database schemes, that is
-// in order to run data migrations in the correct sequence
+// in order to run data migrations in the correct sequence. A new migration is
+// appended to the list and its name is set as dbSchemaCurrent. The statestore
+// with a schema not in the list, written by a newer version, is not opened.
 var schemaMigrations = []migration{
 	{name: dbSchemaGrace, fn: func(s *store) error { return nil }},
+	{name: dbSchemaAddressbookEntries, fn: migrateAddressbookEntries},
+}
+
+// migrateAddressbookEntries adds the liveness metadata to the addressbook
+// entries.
+func migrateAddressbookEntries(s *store) error {
+	return addressbook.MigrateEntries(s)
 }
 
 func (s *store) migrate(schemaName string) error {
 	migrations, err := getMigrations(schemaName, dbSchemaCurrent, schemaMigrations, s)
 	if err != nil {
+		if errors.Is(err, errMissingCurrentSchema) {
+			return fmt.Errorf("unknown current schema (%s), the statestore was probably written by a newer version: %w", schemaName, err)
+		}
 		return fmt.Errorf("error getting migrations for current schema (%s): %w", schemaName, err)
 	}
 
@@ -56,15 +83,10 @@ func (s *store) migrate(schemaName string) error {
 		return nil
 	}
 
-	s.logger.Infof("statestore: need to run %d data migrations to schema %s", len(migrations), schemaName)
+	s.logger.Infof("statestore: need to run %d data migrations to schema %s", len(migrations), dbSchemaCurrent)
 	for i := 0; i < len(migrations); i++ {
-		err := migrations[i].fn(s)
-		if err != nil {
-			return err
-		}
-		err = s.putSchemaName(migrations[i].name) // put the name of the current schema
-		if err != nil {
-			return err
+		if err := s.runMigration(migrations[i]); err != nil {
+			return fmt.Errorf("migration to schema %s: %w", migrations[i].name, err)
 		}
 		schemaName, err = s.getSchemaName()
 		if err != nil {
@@ -75,21 +97,66 @@ func (s *store) migrate(schemaName string) error {
 	return nil
 }
 
+// runMigration runs the migration with its writes collected in a batch, which
+// is written together with the name of the new schema and the record of the
+// applied migration. The migration is either applied completely or not at
+// all, while the migration reads the data as it was before the migration.
+func (s *store) runMigration(m migration) error {
+	history, err := s.appliedMigrations()
+	if err != nil {
+		return err
+	}
+
+	batch := new(leveldb.Batch)
+	if err := m.fn(&store{db: s.db, batch: batch, logger: s.logger}); err != nil {
+		return err
+	}
+
+	history = append(history, appliedMigration{
+		Name:    m.name,
+		Applied: time.Now(),
+	})
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	batch.Put([]byte(dbSchemaHistoryKey), data)
+	batch.Put([]byte(dbSchemaKey), []byte(m.name))
+
+	return s.db.Write(batch, nil)
+}
+
+// appliedMigrations returns the records of the migrations applied to the
+// statestore, in the order they were applied.
+func (s *store) appliedMigrations() (history []appliedMigration, err error) {
+	if err := s.Get(dbSchemaHistoryKey, &history); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return history, nil
+}
+
 // getMigrations returns an ordered list of migrations that need be executed
 // with no errors in order to bring the statestore to the most up-to-date
 // schema definition
 func getMigrations(currentSchema, targetSchema string, allSchemeMigrations []migration, store *store) (migrations []migration, err error) {
 	foundCurrent := false
 	foundTarget := false
+	names := make(map[string]struct{}, len(allSchemeMigrations))
+	for _, v := range allSchemeMigrations {
+		if _, ok := names[v.name]; ok {
+			return nil, fmt.Errorf("%w: %s", errDuplicateSchema, v.name)
+		}
+		names[v.name] = struct{}{}
+	}
 	if currentSchema == dbSchemaCurrent {
 		return nil, nil
 	}
 	for i, v := range allSchemeMigrations {
 		switch v.name {
 		case currentSchema:
-			if foundCurrent {
-				return nil, errors.New("found schema name for the second time when looking for migrations")
-			}
 			foundCurrent = true
 			store.logger.Infof("statestore migration: found current schema %s, migrate to %s, total migrations %d", currentSchema, dbSchemaCurrent, len(allSchemeMigrations)-i)
 			continue // current schema migration should not be executed (already has voyagern when schema was migrated to)
@@ -19,9 +19,11 @@ package leveldb
 import (
 	"errors"
 	"io/ioutil"
+	"reflect"
 	"testing"
 
 	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/storage"
 )
 
 func TestOneMigration(t *testing.T) {
@@ -282,3 +284,162 @@ func TestMigrationErrorTo(t *testing.T) {
 		t.Errorf("migration ran but shouldnt have")
 	}
 }
+
+// TestMultiStepMigration checks that the migrations are applied in batches in
+// sequence, each migration seeing the writes of the previous ones, and that
+// the applied migrations are recorded.
+func TestMultiStepMigration(t *testing.T) {
+	defer func(v []migration, s string) {
+		schemaMigrations = v
+		dbSchemaCurrent = s
+	}(schemaMigrations, dbSchemaCurrent)
+
+	dbSchemaCurrent = "code"
+	schemaMigrations = []migration{
+		{name: "code", fn: func(db *store) error { return nil }},
+		{name: "one", fn: func(db *store) error {
+			return db.Put("counter", 1)
+		}},
+		{name: "two", fn: func(db *store) error {
+			var counter int
+			if err := db.Get("counter", &counter); err != nil {
+				return err
+			}
+			if err := db.Put("counter", counter+1); err != nil {
+				return err
+			}
+			// the writes of the migration are not visible before it is applied
+			if err := db.Get("counter", &counter); err != nil {
+				return err
+			}
+			if counter != 1 {
+				return errors.New("batched write visible in the migration")
+			}
+			return nil
+		}},
+	}
+
+	dir := t.TempDir()
+	logger := logging.New(ioutil.Discard, 0)
+
+	db, err := NewStateStore(dir, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dbSchemaCurrent = "two"
+
+	db, err = NewStateStore(dir, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var counter int
+	if err := db.Get("counter", &counter); err != nil {
+		t.Fatal(err)
+	}
+	if counter != 2 {
+		t.Errorf("got counter %d, want 2", counter)
+	}
+
+	history, err := db.(*store).appliedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range history {
+		names = append(names, m.Name)
+	}
+	if want := []string{"one", "two"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got applied migrations %v, want %v", names, want)
+	}
+}
+
+// TestMigrationFailure checks that the writes of a failed migration are
+// discarded and that the schema stays at the last applied migration.
+func TestMigrationFailure(t *testing.T) {
+	defer func(v []migration, s string) {
+		schemaMigrations = v
+		dbSchemaCurrent = s
+	}(schemaMigrations, dbSchemaCurrent)
+
+	errMigration := errors.New("migration failed")
+	dbSchemaCurrent = "code"
+	schemaMigrations = []migration{
+		{name: "code", fn: func(db *store) error { return nil }},
+		{name: "one", fn: func(db *store) error {
+			return db.Put("one", true)
+		}},
+		{name: "two", fn: func(db *store) error {
+			if err := db.Put("two", true); err != nil {
+				return err
+			}
+			return errMigration
+		}},
+	}
+
+	dir := t.TempDir()
+	logger := logging.New(ioutil.Discard, 0)
+
+	db, err := NewStateStore(dir, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dbSchemaCurrent = "two"
+
+	if _, err := NewStateStore(dir, logger); !errors.Is(err, errMigration) {
+		t.Fatalf("got error %v, want %v", err, errMigration)
+	}
+
+	dbSchemaCurrent = "one"
+
+	db, err = NewStateStore(dir, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	schemaName, err := db.(*store).getSchemaName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schemaName != "one" {
+		t.Errorf("got schema %s, want one", schemaName)
+	}
+
+	var v bool
+	if err := db.Get("one", &v); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Get("two", &v); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("got error %v, want %v", err, storage.ErrNotFound)
+	}
+}
+
+func TestMigrationDuplicateSchema(t *testing.T) {
+	defer func(v []migration, s string) {
+		schemaMigrations = v
+		dbSchemaCurrent = s
+	}(schemaMigrations, dbSchemaCurrent)
+
+	dbSchemaCurrent = "one"
+	schemaMigrations = []migration{
+		{name: "code", fn: func(db *store) error { return nil }},
+		{name: "one", fn: func(db *store) error { return nil }},
+		{name: "one", fn: func(db *store) error { return nil }},
+	}
+
+	logger := logging.New(ioutil.Discard, 0)
+
+	if _, err := NewStateStore(t.TempDir(), logger); !errors.Is(err, errDuplicateSchema) {
+		t.Fatalf("got error %v, want %v", err, errDuplicateSchema)
+	}
+}
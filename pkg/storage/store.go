@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/yanhuangpai/voyager/pkg/infinity"
 )
@@ -19,6 +20,7 @@ var (
 	ErrNotFound        = errors.New("storage: not found")
 	ErrInvalidChunk    = errors.New("storage: invalid chunk")
 	ErrReferenceLength = errors.New("invalid reference length")
+	ErrQuotaExceeded   = errors.New("storage: quota exceeded")
 )
 
 // ModeGet enumerates different Getter modes.
@@ -147,6 +149,44 @@ type Storer interface {
 	io.Closer
 }
 
+// NamespaceUsage holds the number of chunks in a namespace of the local store
+// and its capacity, which is zero if it is not limited.
+type NamespaceUsage struct {
+	Size     uint64
+	Capacity uint64
+}
+
+// Usage holds the usage of the namespaces of the local store: the pinned
+// chunks, the cache of the synced and the requested chunks, and the uploaded
+// chunks which are not synced yet.
+type Usage struct {
+	Pinned NamespaceUsage
+	Cache  NamespaceUsage
+	Upload NamespaceUsage
+}
+
+// UsageReporter is implemented by the stores which report the usage of their
+// namespaces.
+type UsageReporter interface {
+	Usage() (Usage, error)
+}
+
+// ChunkAccess holds the number of the requests of a chunk from the local
+// store and the time of the last one.
+type ChunkAccess struct {
+	Address    infinity.Address
+	Count      uint64
+	LastAccess time.Time
+}
+
+// PopularityReporter is implemented by the stores which count the requests
+// of the chunks.
+type PopularityReporter interface {
+	// MostAccessed returns at most n chunks with the most requests, ordered
+	// from the most requested.
+	MostAccessed(n int) ([]ChunkAccess, error)
+}
+
 type Putter interface {
 	Put(ctx context.Context, mode ModePut, chs ...infinity.Chunk) (exist []bool, err error)
 }
@@ -21,6 +21,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -38,6 +39,15 @@ var (
 	errNoETA  = errors.New("unable to calculate ETA")
 )
 
+var (
+	// syncRateWindow is the period of the recent syncing which the sync
+	// rate of a tag is estimated from.
+	syncRateWindow = 30 * time.Second
+	// syncSampleInterval is the minimal interval between the samples of the
+	// synced chunks count.
+	syncSampleInterval = time.Second
+)
+
 // State is the enum type for chunk states
 type State = uint32
 
@@ -69,6 +79,15 @@ type Tag struct {
 	spanOnce   sync.Once           // make sure we close root span only once
 	stateStore storage.StateStorer // to persist the tag
 	logger     logging.Logger      // logger instance for logging
+
+	syncMu      sync.Mutex   // protects syncSamples
+	syncSamples []syncSample // recent synced chunk counts, oldest first
+}
+
+// syncSample is the count of the synced chunks of a tag at a point in time.
+type syncSample struct {
+	at     time.Time
+	synced int64
 }
 
 // NewTag creates a new tag, and returns it
@@ -123,6 +142,7 @@ func (t *Tag) IncN(state State, n int64) error {
 		total := atomic.LoadInt64(&t.Total)
 		seen := atomic.LoadInt64(&t.Seen)
 		synced := atomic.LoadInt64(&t.Synced)
+		t.sampleSynced(time.Now(), synced)
 		totalUnique := total - seen
 		if synced >= totalUnique {
 			return t.saveTag()
@@ -205,6 +225,13 @@ func (t *Tag) DoneSplit(address infinity.Address) (int64, error) {
 	return total, nil
 }
 
+// SetAddress sets the associated Smart Chain hash for this tag before the
+// split is done, for the uploads of which the root address is known in advance
+func (t *Tag) SetAddress(address infinity.Address) error {
+	t.Address = address
+	return t.saveTag()
+}
+
 // Status returns the value of state and the total count
 func (t *Tag) Status(state State) (int64, int64, error) {
 	count, seen, total := t.Get(state), atomic.LoadInt64(&t.Seen), atomic.LoadInt64(&t.Total)
@@ -233,11 +260,79 @@ func (t *Tag) ETA(state State) (time.Time, error) {
 	if cnt == 0 || total == 0 {
 		return time.Time{}, errNoETA
 	}
-	diff := time.Since(t.StartedAt)
-	dur := time.Duration(total) * diff / time.Duration(cnt)
+	dur, ok := scaleDuration(time.Since(t.StartedAt), total, cnt)
+	if !ok {
+		return time.Time{}, errNoETA
+	}
 	return t.StartedAt.Add(dur), nil
 }
 
+// SyncETA returns the time of completion of the syncing estimated from the
+// rate of syncing in the recent period, so that the pauses in the syncing
+// do not distort the estimation for long.
+func (t *Tag) SyncETA() (time.Time, error) {
+	return t.syncETA(time.Now())
+}
+
+func (t *Tag) syncETA(now time.Time) (time.Time, error) {
+	total := atomic.LoadInt64(&t.Total)
+	if total == 0 {
+		return time.Time{}, errNA
+	}
+	remaining := total - atomic.LoadInt64(&t.Seen) - atomic.LoadInt64(&t.Synced)
+	if remaining <= 0 {
+		return now, nil
+	}
+
+	t.syncMu.Lock()
+	defer t.syncMu.Unlock()
+
+	if len(t.syncSamples) < 2 {
+		return time.Time{}, errNoETA
+	}
+	// the rate decreases while the syncing is paused
+	first, last := t.syncSamples[0], t.syncSamples[len(t.syncSamples)-1]
+	synced, elapsed := last.synced-first.synced, now.Sub(first.at)
+	if synced <= 0 || elapsed <= 0 {
+		return time.Time{}, errNoETA
+	}
+	dur, ok := scaleDuration(elapsed, remaining, synced)
+	if !ok {
+		return time.Time{}, errNoETA
+	}
+	return now.Add(dur), nil
+}
+
+// scaleDuration returns the duration multiplied by the ratio of n and d, or
+// false if the result does not fit in a duration. It is computed in floating
+// point, as the product of the duration and n may overflow the integers.
+func scaleDuration(dur time.Duration, n, d int64) (time.Duration, bool) {
+	f := float64(dur) * float64(n) / float64(d)
+	if math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return time.Duration(f), true
+}
+
+// sampleSynced records the count of the synced chunks. The samples older
+// than the sync rate window are dropped, unless they are needed for the
+// estimation of the rate.
+func (t *Tag) sampleSynced(now time.Time, synced int64) {
+	t.syncMu.Lock()
+	defer t.syncMu.Unlock()
+
+	if n := len(t.syncSamples); n > 1 && now.Sub(t.syncSamples[n-2].at) < syncSampleInterval {
+		// replace the newest sample as the one before it is recent
+		t.syncSamples[n-1] = syncSample{at: now, synced: synced}
+	} else {
+		t.syncSamples = append(t.syncSamples, syncSample{at: now, synced: synced})
+	}
+
+	for len(t.syncSamples) > 2 && now.Sub(t.syncSamples[0].at) > syncRateWindow {
+		t.syncSamples = t.syncSamples[1:]
+	}
+}
+
 // MarshalBinary marshals the tag into a byte slice
 func (tag *Tag) MarshalBinary() (data []byte, err error) {
 	buffer := make([]byte, 4)
@@ -19,6 +19,7 @@ package tags
 import (
 	"context"
 	"io/ioutil"
+	"math"
 	"sync"
 	"testing"
 	"time"
@@ -309,3 +310,59 @@ func TestMarshallingNoAddr(t *testing.T) {
 		t.Fatalf("expected tag addresses to be equal length")
 	}
 }
+
+// tests that the sync ETA is estimated from the recent sync rate
+func TestTagSyncETA(t *testing.T) {
+	tg := &Tag{Total: 120}
+	if _, err := tg.syncETA(time.Now()); err != errNoETA {
+		t.Fatalf("got error %v, want %v", err, errNoETA)
+	}
+
+	// sync slowly before the window and then 10 chunks per second
+	start := time.Now()
+	tg.Synced = 50
+	tg.sampleSynced(start, tg.Synced)
+	for i := 1; i <= 5; i++ {
+		tg.Synced += 10
+		tg.sampleSynced(start.Add(time.Minute+time.Duration(i)*time.Second), tg.Synced)
+	}
+	if len(tg.syncSamples) != 5 {
+		t.Fatalf("got %d samples, want %d", len(tg.syncSamples), 5)
+	}
+
+	now := start.Add(time.Minute + 5*time.Second)
+	eta, err := tg.syncETA(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(2 * time.Second); !eta.Equal(want) {
+		t.Fatalf("got eta %v, want %v", eta, want)
+	}
+
+	// the rate decreases while the syncing is paused
+	now = now.Add(36 * time.Second)
+	eta, err = tg.syncETA(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Add(20 * time.Second); !eta.Equal(want) {
+		t.Fatalf("got eta %v, want %v", eta, want)
+	}
+
+	// the estimation overflowing the duration is not available
+	tg.Total = math.MaxInt64
+	if _, err := tg.syncETA(now.Add(1000 * time.Hour)); err != errNoETA {
+		t.Fatalf("got error %v, want %v", err, errNoETA)
+	}
+	tg.Total = 120
+
+	// all chunks synced
+	tg.Synced = 120
+	eta, err = tg.syncETA(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !eta.Equal(now) {
+		t.Fatalf("got eta %v, want %v", eta, now)
+	}
+}
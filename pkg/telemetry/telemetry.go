@@ -0,0 +1,238 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package telemetry provides anonymous usage reporting of the node. Reporting
+// is strictly opt-in: no data is sent unless it is explicitly enabled and an
+// endpoint is configured.
+//
+// The report is sent as a JSON object in an HTTP POST request to the
+// configured endpoint and contains only the fields of the Report type:
+//   - schema: version of the report schema, currently 1
+//   - version: version of the node software
+//   - peers: bucket of the number of connected peers
+//   - storage: bucket of the size of the local storage in bytes
+//
+// No addresses, keys or identifiers of the node are reported.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/yanhuangpai/voyager"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+)
+
+// SchemaVersion is the version of the report schema.
+const SchemaVersion = 1
+
+const (
+	defaultInterval = 24 * time.Hour
+	sendTimeout     = 30 * time.Second
+)
+
+var errUnexpectedStatus = errors.New("unexpected response status")
+
+// Report is the anonymous usage report. It is the complete set of the
+// reported data.
+type Report struct {
+	Schema  int    `json:"schema"`
+	Version string `json:"version"`
+	Peers   string `json:"peers"`
+	Storage string `json:"storage"`
+}
+
+// Peerer returns the connected peers.
+type Peerer interface {
+	Peers() []p2p.Peer
+}
+
+// StorageSizeFunc returns the size of the local storage in bytes.
+type StorageSizeFunc func() (int64, error)
+
+// Options are the telemetry configuration options.
+type Options struct {
+	Enabled  bool
+	Endpoint string
+	Interval time.Duration
+}
+
+// Service collects and periodically sends the usage reports if reporting is
+// enabled.
+type Service struct {
+	peerer      Peerer
+	storageSize StorageSizeFunc
+	logger      logging.Logger
+	enabled     bool
+	endpoint    string
+	interval    time.Duration
+	client      *http.Client
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a new telemetry service.
+func New(peerer Peerer, storageSize StorageSizeFunc, logger logging.Logger, o Options) *Service {
+	if o.Interval <= 0 {
+		o.Interval = defaultInterval
+	}
+	return &Service{
+		peerer:      peerer,
+		storageSize: storageSize,
+		logger:      logger,
+		enabled:     o.Enabled && o.Endpoint != "",
+		endpoint:    o.Endpoint,
+		interval:    o.Interval,
+		client:      &http.Client{Timeout: sendTimeout},
+		quit:        make(chan struct{}),
+	}
+}
+
+// Enabled reports whether the reports are sent.
+func (s *Service) Enabled() bool {
+	return s.enabled
+}
+
+// Endpoint returns the endpoint the reports are sent to.
+func (s *Service) Endpoint() string {
+	return s.endpoint
+}
+
+// Report returns the report exactly as it would be sent.
+func (s *Service) Report() (Report, error) {
+	size, err := s.storageSize()
+	if err != nil {
+		return Report{}, fmt.Errorf("storage size: %w", err)
+	}
+	return Report{
+		Schema:  SchemaVersion,
+		Version: voyager.Version,
+		Peers:   peersBucket(len(s.peerer.Peers())),
+		Storage: storageBucket(size),
+	}, nil
+}
+
+// Start starts sending the reports periodically if reporting is enabled.
+func (s *Service) Start() {
+	if !s.enabled {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.quit:
+				return
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			if err := s.send(ctx); err != nil {
+				s.logger.Debugf("telemetry: send report: %v", err)
+				s.logger.Error("telemetry: send report")
+			}
+			cancel()
+		}
+	}()
+}
+
+func (s *Service) send(ctx context.Context) error {
+	r, err := s.Report()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: %s", errUnexpectedStatus, resp.Status)
+	}
+	return nil
+}
+
+// Close stops sending the reports.
+func (s *Service) Close() error {
+	close(s.quit)
+	s.wg.Wait()
+	return nil
+}
+
+// DirSize returns the total size of the regular files in the directory.
+func DirSize(dir string) (size int64, err error) {
+	err = filepath.Walk(dir, func(_ string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// peersBucket returns the coarse bucket of the number of peers.
+func peersBucket(n int) string {
+	switch {
+	case n == 0:
+		return "0"
+	case n <= 10:
+		return "1-10"
+	case n <= 50:
+		return "11-50"
+	case n <= 100:
+		return "51-100"
+	case n <= 500:
+		return "101-500"
+	default:
+		return "500+"
+	}
+}
+
+// storageBucket returns the coarse bucket of the storage size in bytes.
+func storageBucket(size int64) string {
+	const gb = 1 << 30
+	switch {
+	case size < gb:
+		return "0-1GB"
+	case size < 10*gb:
+		return "1-10GB"
+	case size < 100*gb:
+		return "10-100GB"
+	case size < 1000*gb:
+		return "100-1000GB"
+	default:
+		return "1000GB+"
+	}
+}
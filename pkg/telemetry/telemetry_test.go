@@ -0,0 +1,157 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package telemetry_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yanhuangpai/voyager"
+	"github.com/yanhuangpai/voyager/pkg/logging"
+	"github.com/yanhuangpai/voyager/pkg/p2p"
+	"github.com/yanhuangpai/voyager/pkg/telemetry"
+)
+
+type peerer int
+
+func (p peerer) Peers() []p2p.Peer {
+	return make([]p2p.Peer, p)
+}
+
+func storageSize(size int64) telemetry.StorageSizeFunc {
+	return func() (int64, error) {
+		return size, nil
+	}
+}
+
+func TestReport(t *testing.T) {
+	for _, tc := range []struct {
+		peers       int
+		size        int64
+		wantPeers   string
+		wantStorage string
+	}{
+		{peers: 0, size: 0, wantPeers: "0", wantStorage: "0-1GB"},
+		{peers: 10, size: 1 << 30, wantPeers: "1-10", wantStorage: "1-10GB"},
+		{peers: 11, size: 20 << 30, wantPeers: "11-50", wantStorage: "10-100GB"},
+		{peers: 100, size: 500 << 30, wantPeers: "51-100", wantStorage: "100-1000GB"},
+		{peers: 101, size: 1000 << 30, wantPeers: "101-500", wantStorage: "1000GB+"},
+		{peers: 501, size: 1 << 50, wantPeers: "500+", wantStorage: "1000GB+"},
+	} {
+		s := telemetry.New(peerer(tc.peers), storageSize(tc.size), logging.New(ioutil.Discard, 0), telemetry.Options{})
+		r, err := s.Report()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := telemetry.Report{
+			Schema:  telemetry.SchemaVersion,
+			Version: voyager.Version,
+			Peers:   tc.wantPeers,
+			Storage: tc.wantStorage,
+		}
+		if r != want {
+			t.Errorf("got report %+v, want %+v", r, want)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	reports := make(chan telemetry.Report, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report telemetry.Report
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Error(err)
+		}
+		reports <- report
+	}))
+	defer server.Close()
+
+	t.Run("enabled", func(t *testing.T) {
+		s := telemetry.New(peerer(5), storageSize(0), logging.New(ioutil.Discard, 0), telemetry.Options{
+			Enabled:  true,
+			Endpoint: server.URL,
+			Interval: 10 * time.Millisecond,
+		})
+		if !s.Enabled() {
+			t.Fatal("not enabled")
+		}
+		s.Start()
+		defer s.Close()
+
+		want, err := s.Report()
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-reports:
+			if got != want {
+				t.Errorf("got report %+v, want %+v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	})
+
+	for _, tc := range []struct {
+		name string
+		o    telemetry.Options
+	}{
+		{name: "disabled", o: telemetry.Options{Endpoint: server.URL, Interval: 10 * time.Millisecond}},
+		{name: "no endpoint", o: telemetry.Options{Enabled: true, Interval: 10 * time.Millisecond}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := telemetry.New(peerer(5), storageSize(0), logging.New(ioutil.Discard, 0), tc.o)
+			if s.Enabled() {
+				t.Fatal("enabled")
+			}
+			s.Start()
+			defer s.Close()
+
+			// drain reports sent by the previous test
+			time.Sleep(100 * time.Millisecond)
+			for len(reports) > 0 {
+				<-reports
+			}
+
+			select {
+			case r := <-reports:
+				t.Fatalf("got report %+v", r)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestDirSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telemetry-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "a"), make([]byte, 100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sub", "b"), make([]byte, 23), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := telemetry.DirSize(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 123 {
+		t.Errorf("got size %v, want %v", size, 123)
+	}
+}
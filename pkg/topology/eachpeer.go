@@ -0,0 +1,143 @@
+// Copyright 2021 The Smart Chain Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package topology
+
+import (
+	"github.com/yanhuangpai/voyager/pkg/infinity"
+)
+
+// EachPeerOptions select the peers iterated over by EachPeerWith and the order
+// of the iteration. The default options select all connected peers, from the
+// closest bin to the farthest.
+type EachPeerOptions struct {
+	Reverse   bool  // iterate from the farthest bin to the closest
+	Known     bool  // iterate over all known peers, not only the connected ones
+	Reachable bool  // skip the known peers which could not be dialed on the last attempt
+	MinBin    uint8 // skip the peers in the bins below
+	MaxBin    uint8 // skip the peers in the bins above
+	MaxPeers  int   // stop after so many peers, not limited if zero
+	Filter    func(peer infinity.Address, po uint8) bool
+}
+
+// EachPeerOption sets an option of the peer iteration.
+type EachPeerOption func(*EachPeerOptions)
+
+// NewEachPeerOptions returns the default options with the given ones set.
+func NewEachPeerOptions(opts ...EachPeerOption) *EachPeerOptions {
+	o := &EachPeerOptions{
+		MaxBin: infinity.MaxPO,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithReverse iterates over the peers from the farthest bin to the closest.
+func WithReverse() EachPeerOption {
+	return func(o *EachPeerOptions) {
+		o.Reverse = true
+	}
+}
+
+// WithKnownPeers iterates over all known peers, including the ones which are
+// not connected.
+func WithKnownPeers() EachPeerOption {
+	return func(o *EachPeerOptions) {
+		o.Known = true
+	}
+}
+
+// WithReachableOnly skips the peers which could not be dialed on the last
+// attempt.
+func WithReachableOnly() EachPeerOption {
+	return func(o *EachPeerOptions) {
+		o.Reachable = true
+	}
+}
+
+// WithBins iterates over the peers in the bins from min to max, inclusive.
+func WithBins(min, max uint8) EachPeerOption {
+	return func(o *EachPeerOptions) {
+		o.MinBin, o.MaxBin = min, max
+	}
+}
+
+// WithMaxPeers stops the iteration after n peers.
+func WithMaxPeers(n int) EachPeerOption {
+	return func(o *EachPeerOptions) {
+		o.MaxPeers = n
+	}
+}
+
+// WithFilter skips the peers for which the filter returns false. The filter
+// is called only with the peers selected by the other options and the filters
+// set before.
+func WithFilter(f func(peer infinity.Address, po uint8) bool) EachPeerOption {
+	return func(o *EachPeerOptions) {
+		prev := o.Filter
+		if prev == nil {
+			o.Filter = f
+			return
+		}
+		o.Filter = func(peer infinity.Address, po uint8) bool {
+			return prev(peer, po) && f(peer, po)
+		}
+	}
+}
+
+// WithSkipPeers skips the given peers.
+func WithSkipPeers(skip ...infinity.Address) EachPeerOption {
+	return WithFilter(func(peer infinity.Address, _ uint8) bool {
+		for _, a := range skip {
+			if a.Equal(peer) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Select returns the function calling f only with the peers selected by the
+// options, to be passed to an iteration over all peers in the order of the
+// options. The reachable function reports whether a peer is reachable, it is
+// called only if the reachable peers are selected.
+func (o *EachPeerOptions) Select(f EachPeerFunc, reachable func(infinity.Address) bool) EachPeerFunc {
+	var (
+		n    int
+		done bool // the iteration is stopped, in case it is not honored
+	)
+	return func(peer infinity.Address, po uint8) (bool, bool, error) {
+		if done {
+			return true, false, nil
+		}
+		if po < o.MinBin {
+			// the peers in the bins below follow only in reverse
+			return !o.Reverse, o.Reverse, nil
+		}
+		if po > o.MaxBin {
+			// the peers in the bins above follow only in the closest first
+			return o.Reverse, !o.Reverse, nil
+		}
+		if o.Reachable && reachable != nil && !reachable(peer) {
+			return false, false, nil
+		}
+		if o.Filter != nil && !o.Filter(peer, po) {
+			return false, false, nil
+		}
+
+		stop, next, err := f(peer, po)
+		if err != nil || stop {
+			done = true
+			return stop, next, err
+		}
+		n++
+		if o.MaxPeers > 0 && n >= o.MaxPeers {
+			done = true
+			return true, false, nil
+		}
+		return false, next, nil
+	}
+}
@@ -6,6 +6,7 @@ package mock
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/yanhuangpai/voyager/pkg/infinity"
@@ -17,6 +18,7 @@ type mock struct {
 	closestPeer     infinity.Address
 	closestPeerErr  error
 	addPeersErr     error
+	depth           uint8
 	marshalJSONFunc func() ([]byte, error)
 	mtx             sync.Mutex
 }
@@ -45,6 +47,12 @@ func WithClosestPeerErr(err error) Option {
 	})
 }
 
+func WithNeighborhoodDepth(depth uint8) Option {
+	return optionFunc(func(d *mock) {
+		d.depth = depth
+	})
+}
+
 func WithMarshalJSONFunc(f func() ([]byte, error)) Option {
 	return optionFunc(func(d *mock) {
 		d.marshalJSONFunc = f
@@ -86,13 +94,11 @@ func (d *mock) Peers() []infinity.Address {
 }
 
 func (d *mock) ClosestPeer(_ infinity.Address, skipPeers ...infinity.Address) (peerAddr infinity.Address, err error) {
-	if len(skipPeers) == 0 {
-		if d.closestPeerErr != nil {
-			return d.closestPeer, d.closestPeerErr
-		}
-		if !d.closestPeer.Equal(infinity.ZeroAddress) {
-			return d.closestPeer, nil
-		}
+	if d.closestPeerErr != nil {
+		return d.closestPeer, d.closestPeerErr
+	}
+	if !d.closestPeer.Equal(infinity.ZeroAddress) && !containsAddress(skipPeers, d.closestPeer) {
+		return d.closestPeer, nil
 	}
 
 	d.mtx.Lock()
@@ -120,12 +126,47 @@ func (d *mock) ClosestPeer(_ infinity.Address, skipPeers ...infinity.Address) (p
 	return peerAddr, nil
 }
 
+func (d *mock) ClosestPeers(addr infinity.Address, n int, skipPeers ...infinity.Address) (peerAddrs []infinity.Address, err error) {
+	if n <= 0 {
+		return nil, topology.ErrNotFound
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	for _, p := range d.peers {
+		skipPeer := false
+		for _, a := range skipPeers {
+			if a.Equal(p) {
+				skipPeer = true
+				break
+			}
+		}
+		if !skipPeer {
+			peerAddrs = append(peerAddrs, p)
+		}
+	}
+
+	sort.SliceStable(peerAddrs, func(i, j int) bool {
+		dcmp, _ := infinity.DistanceCmp(addr.Bytes(), peerAddrs[i].Bytes(), peerAddrs[j].Bytes())
+		return dcmp == 1
+	})
+	if len(peerAddrs) > n {
+		peerAddrs = peerAddrs[:n]
+	}
+
+	if len(peerAddrs) == 0 {
+		return nil, topology.ErrNotFound
+	}
+	return peerAddrs, nil
+}
+
 func (d *mock) SubscribePeersChange() (c <-chan struct{}, unsubscribe func()) {
-	return c, unsubscribe
+	return c, func() {}
 }
 
-func (*mock) NeighborhoodDepth() uint8 {
-	return 0
+func (d *mock) NeighborhoodDepth() uint8 {
+	return d.depth
 }
 
 // EachPeer iterates from closest bin to farthest
@@ -158,6 +199,15 @@ func (d *mock) EachPeerRev(f topology.EachPeerFunc) (err error) {
 	return nil
 }
 
+// EachPeerWith iterates over the peers selected by the options
+func (d *mock) EachPeerWith(f topology.EachPeerFunc, opts ...topology.EachPeerOption) error {
+	o := topology.NewEachPeerOptions(opts...)
+	if o.Reverse {
+		return d.EachPeerRev(o.Select(f, nil))
+	}
+	return d.EachPeer(o.Select(f, nil))
+}
+
 func (d *mock) MarshalJSON() ([]byte, error) {
 	return d.marshalJSONFunc()
 }
@@ -173,3 +223,12 @@ type Option interface {
 type optionFunc func(*mock)
 
 func (f optionFunc) apply(r *mock) { f(r) }
+
+func containsAddress(addrs []infinity.Address, addr infinity.Address) bool {
+	for _, a := range addrs {
+		if a.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
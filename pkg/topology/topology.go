@@ -24,7 +24,7 @@ type Driver interface {
 	PeerAdder
 	ClosestPeerer
 	EachPeerer
-	NeighborhoodDepth() uint8
+	NeighborhoodDepther
 	SubscribePeersChange() (c <-chan struct{}, unsubscribe func())
 	io.Closer
 }
@@ -40,6 +40,16 @@ type ClosestPeerer interface {
 	// This function will ignore peers with addresses provided in skipPeers.
 	// Returns topology.ErrWantSelf in case base is the closest to the address.
 	ClosestPeer(addr infinity.Address, skipPeers ...infinity.Address) (peerAddr infinity.Address, err error)
+	// ClosestPeers returns at most n closest connected peers we have in
+	// relation to a given chunk address, sorted by distance from the closest.
+	// This function will ignore peers with addresses provided in skipPeers.
+	// Returns topology.ErrNotFound if there are no peers to return.
+	ClosestPeers(addr infinity.Address, n int, skipPeers ...infinity.Address) (peerAddrs []infinity.Address, err error)
+}
+
+type NeighborhoodDepther interface {
+	// NeighborhoodDepth returns the depth of the neighborhood of the node.
+	NeighborhoodDepth() uint8
 }
 
 type EachPeerer interface {
@@ -47,7 +57,51 @@ type EachPeerer interface {
 	EachPeer(EachPeerFunc) error
 	// EachPeerRev iterates from farthest bin to closest
 	EachPeerRev(EachPeerFunc) error
+	// EachPeerWith iterates over the peers selected by the options, from
+	// the closest bin to the farthest unless the order is reversed.
+	EachPeerWith(f EachPeerFunc, opts ...EachPeerOption) error
 }
 
 // EachPeerFunc is a callback that is called with a peer and its PO
 type EachPeerFunc func(infinity.Address, uint8) (stop, jumpToNext bool, err error)
+
+// MaxSkipPeers is the maximal number of the peers sent with a forwarded
+// request to be skipped by the downstream nodes.
+const MaxSkipPeers = 16
+
+// EncodeSkipPeers returns the addresses of the peers to be skipped by the
+// downstream nodes as sent in the requests, without the duplicates. Only the
+// last MaxSkipPeers peers are kept, as they are the closest to the chunk on
+// the forwarding chain.
+func EncodeSkipPeers(peers []infinity.Address) [][]byte {
+	b := make([][]byte, 0, len(peers))
+	seen := make(map[string]struct{}, len(peers))
+	for _, p := range peers {
+		if _, ok := seen[p.ByteString()]; ok {
+			continue
+		}
+		seen[p.ByteString()] = struct{}{}
+		b = append(b, p.Bytes())
+	}
+	if len(b) > MaxSkipPeers {
+		b = b[len(b)-MaxSkipPeers:]
+	}
+	return b
+}
+
+// DecodeSkipPeers returns the addresses of the peers to be skipped received
+// with a request. At most MaxSkipPeers addresses are returned and the invalid
+// ones are ignored.
+func DecodeSkipPeers(b [][]byte) []infinity.Address {
+	if len(b) > MaxSkipPeers {
+		b = b[len(b)-MaxSkipPeers:]
+	}
+	peers := make([]infinity.Address, 0, len(b))
+	for _, a := range b {
+		if len(a) != infinity.HashSize {
+			continue
+		}
+		peers = append(peers, infinity.NewAddress(a))
+	}
+	return peers
+}
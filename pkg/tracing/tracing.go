@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 	"github.com/sirupsen/logrus"
 	"github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
@@ -108,6 +109,16 @@ func (t *Tracer) StartSpanFromContext(ctx context.Context, operationName string,
 	return span, loggerWithTraceID(sc, l), WithContext(ctx, sc)
 }
 
+// FinishSpan marks the span as failed if the error is not nil and finishes
+// it.
+func FinishSpan(span opentracing.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("error", err.Error())
+	}
+	span.Finish()
+}
+
 // AddContextHeader adds a tracing span context to provided p2p Headers from
 // the go context. If the tracing span context is not present in go context,
 // ErrContextNotFound is returned.
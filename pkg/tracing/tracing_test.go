@@ -6,11 +6,13 @@ package tracing_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"testing"
 
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/uber/jaeger-client-go"
 	"github.com/yanhuangpai/voyager/pkg/logging"
 	"github.com/yanhuangpai/voyager/pkg/p2p"
@@ -202,6 +204,28 @@ func TestNewLoggerWithTraceID_nilLogger(t *testing.T) {
 	}
 }
 
+func TestFinishSpan(t *testing.T) {
+	tracer := mocktracer.New()
+
+	tracing.FinishSpan(tracer.StartSpan("ok"), nil)
+	tracing.FinishSpan(tracer.StartSpan("failed"), errors.New("test error"))
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %v finished spans, want 2", len(spans))
+	}
+	if v := spans[0].Tag("error"); v != nil {
+		t.Errorf("got error tag %v, want none", v)
+	}
+	if v := spans[1].Tag("error"); v != true {
+		t.Errorf("got error tag %v, want true", v)
+	}
+	logs := spans[1].Logs()
+	if len(logs) != 1 || len(logs[0].Fields) != 1 || logs[0].Fields[0].ValueString != "test error" {
+		t.Errorf("got logs %+v, want the error", logs)
+	}
+}
+
 func newTracer(t *testing.T) (*tracing.Tracer, io.Closer) {
 	t.Helper()
 